@@ -1,23 +1,52 @@
-import { Container, Flex, Heading, SimpleGrid, Text } from "@chakra-ui/react";
+import { useState } from "react";
+import { Button, Container, Flex, Heading, SimpleGrid, Text } from "@chakra-ui/react";
+import { useQueryClient } from "@tanstack/react-query";
 import SystemOverview from "./components/SystemOverview";
 import FormLibrary from "./components/FormLibrary";
 import WorkflowDesigner from "./components/WorkflowDesigner";
 import TicketDashboard from "./components/TicketDashboard";
+import LoginForm from "./components/LoginForm";
+import { getSession, logout } from "./lib/api";
 
 function App() {
+  const queryClient = useQueryClient();
+  const [session, setSession] = useState(getSession);
+
+  const handleLogout = async () => {
+    setSession(null);
+    queryClient.clear();
+    await logout().catch(() => undefined);
+  };
+
   return (
     <Container maxW="6xl" py={8} gap={6}>
       <Flex direction="column" gap={6}>
-        <Heading>PFlow 控制台</Heading>
+        <Flex justify="space-between" align="center">
+          <Heading>PFlow 控制台</Heading>
+          {session && (
+            <Flex align="center" gap={3}>
+              <Text color="gray.600">{session.user.name}</Text>
+              <Button size="sm" variant="outline" onClick={handleLogout}>
+                退出登录
+              </Button>
+            </Flex>
+          )}
+        </Flex>
         <Text color="gray.600">
           拖拽表单、流程编排、工单生命周期一体化的流程引擎 &amp; 工单管理平台。
         </Text>
-        <SystemOverview />
-        <SimpleGrid columns={{ base: 1, md: 2 }} spacing={6}>
-          <FormLibrary />
-          <WorkflowDesigner />
-        </SimpleGrid>
-        <TicketDashboard />
+        {session ? (
+          <>
+            <SystemOverview />
+            <SimpleGrid columns={{ base: 1, md: 2 }} spacing={6}>
+              <FormLibrary />
+              <WorkflowDesigner />
+            </SimpleGrid>
+            <TicketDashboard />
+          </>
+        ) : (
+          <LoginForm onLogin={setSession} />
+        )}
       </Flex>
     </Container>
   );
@@ -0,0 +1,58 @@
+import { useState } from "react";
+import { Box, Button, FormControl, FormLabel, Heading, Input, Stack, useToast } from "@chakra-ui/react";
+import { useMutation } from "@tanstack/react-query";
+import { AuthSession, login } from "../lib/api";
+
+interface LoginFormProps {
+  onLogin: (session: AuthSession) => void;
+}
+
+export default function LoginForm({ onLogin }: LoginFormProps) {
+  const toast = useToast();
+  const [email, setEmail] = useState("");
+  const [password, setPassword] = useState("");
+
+  const loginMutation = useMutation({
+    mutationFn: () => login(email.trim(), password),
+    onSuccess: (session) => {
+      setPassword("");
+      onLogin(session);
+    },
+    onError: (error: { response?: { status?: number } }) => {
+      const locked = error.response?.status === 423;
+      toast({
+        status: "error",
+        title: locked ? "账号已临时锁定" : "登录失败",
+        description: locked ? "连续登录失败次数过多，请稍后再试" : "邮箱或密码错误",
+      });
+    },
+  });
+
+  return (
+    <Box borderWidth="1px" borderRadius="md" p={6} bg="white" shadow="sm" maxW="md" mx="auto">
+      <Heading size="md" mb={4}>
+        登录
+      </Heading>
+      <Stack
+        as="form"
+        spacing={4}
+        onSubmit={(event) => {
+          event.preventDefault();
+          loginMutation.mutate();
+        }}
+      >
+        <FormControl isRequired>
+          <FormLabel>邮箱</FormLabel>
+          <Input type="email" value={email} onChange={(event) => setEmail(event.target.value)} />
+        </FormControl>
+        <FormControl isRequired>
+          <FormLabel>密码</FormLabel>
+          <Input type="password" value={password} onChange={(event) => setPassword(event.target.value)} />
+        </FormControl>
+        <Button type="submit" colorScheme="blue" isLoading={loginMutation.isPending}>
+          登录
+        </Button>
+      </Stack>
+    </Box>
+  );
+}
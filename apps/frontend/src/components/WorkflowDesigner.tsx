@@ -22,6 +22,7 @@ import {
   createWorkflow,
   listWorkflows,
   publishWorkflow,
+  validateWorkflowBlueprint,
   WorkflowDefinition,
 } from "../lib/api";
 
@@ -83,7 +84,7 @@ export default function WorkflowDesigner() {
     };
   }, [workflowSteps]);
 
-  const handleCreateWorkflow = () => {
+  const handleCreateWorkflow = async () => {
     if (!workflowName.trim()) {
       toast({ status: "warning", title: "请输入流程名称" });
       return;
@@ -93,6 +94,21 @@ export default function WorkflowDesigner() {
       return;
     }
 
+    try {
+      const { data: validation } = await validateWorkflowBlueprint(blueprint);
+      if (!validation.valid) {
+        toast({
+          status: "warning",
+          title: "流程蓝图校验未通过",
+          description: validation.errors.map((issue) => issue.message).join("；"),
+        });
+        return;
+      }
+    } catch {
+      toast({ status: "error", title: "流程蓝图校验失败", description: "请稍后重试" });
+      return;
+    }
+
     createMutation.mutate({
       name: workflowName.trim(),
       description: workflowDescription.trim(),
@@ -211,7 +227,7 @@ function WorkflowCard({
         <Box>
           <Heading size="sm">{workflow.name}</Heading>
           <Text fontSize="sm" color="gray.500">
-            v{workflow.version} · {workflow.description || "无描述"}
+            {workflow.version > 0 ? `v${workflow.version}` : "草稿"} · {workflow.description || "无描述"}
           </Text>
         </Box>
         <HStack>
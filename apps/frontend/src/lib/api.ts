@@ -1,49 +1,281 @@
 import axios from "axios";
 
 const API_BASE_URL = import.meta.env.VITE_GATEWAY_URL ?? "http://localhost:8080/api";
+// Tenant used before login; afterwards the signed-in user's tenant applies.
+const DEFAULT_TENANT_ID: string | undefined = import.meta.env.VITE_TENANT_ID;
 
 export const apiClient = axios.create({
   baseURL: API_BASE_URL,
   timeout: 10_000,
 });
 
+const SESSION_STORAGE_KEY = "pflow.session";
+
+export interface AuthSession {
+  tokenType: string;
+  accessToken: string;
+  expiresAt: string;
+  refreshToken: string;
+  refreshExpiresAt: string;
+  user: User;
+}
+
+export function getSession(): AuthSession | null {
+  const raw = localStorage.getItem(SESSION_STORAGE_KEY);
+  return raw ? (JSON.parse(raw) as AuthSession) : null;
+}
+
+function storeSession(session: AuthSession | null) {
+  if (session) {
+    localStorage.setItem(SESSION_STORAGE_KEY, JSON.stringify(session));
+  } else {
+    localStorage.removeItem(SESSION_STORAGE_KEY);
+  }
+}
+
+apiClient.interceptors.request.use((config) => {
+  const session = getSession();
+  if (session && !config.headers.Authorization) {
+    config.headers.Authorization = `Bearer ${session.accessToken}`;
+  }
+  const tenantId = session?.user.tenantId ?? DEFAULT_TENANT_ID;
+  if (tenantId && !config.headers["X-Tenant-ID"]) {
+    config.headers["X-Tenant-ID"] = tenantId;
+  }
+  return config;
+});
+
+// Access tokens are short-lived: on a 401, rotate the refresh token once and
+// replay the request. Concurrent failures share the same refresh.
+let pendingRefresh: Promise<AuthSession | null> | null = null;
+
+apiClient.interceptors.response.use(undefined, async (error) => {
+  const original = error.config;
+  const session = getSession();
+  if (error.response?.status !== 401 || !session || !original || original._retried || original.url?.startsWith("/auth/")) {
+    return Promise.reject(error);
+  }
+
+  pendingRefresh ??= refreshSession(session.refreshToken).finally(() => {
+    pendingRefresh = null;
+  });
+  const refreshed = await pendingRefresh;
+  if (!refreshed) {
+    return Promise.reject(error);
+  }
+  original._retried = true;
+  original.headers.Authorization = `Bearer ${refreshed.accessToken}`;
+  return apiClient(original);
+});
+
 export interface FormSchema {
   [key: string]: unknown;
 }
 
 export interface Form {
   id: string;
+  tenantId: string;
   name: string;
   description: string;
   schema: FormSchema;
+  version: number;
   createdAt: string;
   updatedAt: string;
+  deletedAt?: string;
+}
+
+export interface FormVersion {
+  id: string;
+  formId: string;
+  version: number;
+  schema: FormSchema;
+  createdAt: string;
 }
 
 export interface User {
   id: string;
+  tenantId: string;
   name: string;
   email: string;
-  role: string;
+  roles: string[];
+  skills: string[];
+  hasPassword?: boolean;
+  lockedUntil?: string;
   createdAt: string;
   updatedAt: string;
+  deletedAt?: string;
+}
+
+export interface Role {
+  id: string;
+  name: string;
+  description: string;
+  system: boolean;
+  permissions: string[];
+  createdAt: string;
+  updatedAt: string;
+}
+
+export interface Permission {
+  key: string;
+  description: string;
+}
+
+export interface RolePayload {
+  name: string;
+  description?: string;
+  permissions: string[];
 }
 
 export interface Ticket {
   id: string;
+  tenantId: string;
   title: string;
   status: string;
   formId: string;
+  formVersion: number;
   assigneeId: string;
   priority: string;
   metadata?: Record<string, unknown>;
   createdAt: string;
   updatedAt: string;
   resolvedAt?: string;
+  slaPolicyId?: string;
+  firstResponseDueAt?: string;
+  firstRespondedAt?: string;
+  dueAt?: string;
+  breached: boolean;
+  responseBreachedAt?: string;
+  resolutionBreachedAt?: string;
+  deletedAt?: string;
+}
+
+export interface BusinessHours {
+  day: "monday" | "tuesday" | "wednesday" | "thursday" | "friday" | "saturday" | "sunday";
+  start: string;
+  end: string;
+}
+
+export interface BusinessCalendar {
+  timezone?: string;
+  hours?: BusinessHours[];
+  holidays?: string[];
+}
+
+export interface SLAPolicy {
+  id: string;
+  tenantId: string;
+  name: string;
+  priority: string;
+  formId?: string;
+  firstResponseMinutes: number;
+  resolutionMinutes: number;
+  calendar: BusinessCalendar;
+  createdAt: string;
+  updatedAt: string;
+}
+
+export type SLAPolicyPayload = Partial<
+  Pick<SLAPolicy, "name" | "priority" | "formId" | "firstResponseMinutes" | "resolutionMinutes" | "calendar">
+>;
+
+export type AssignmentStrategy = "round_robin" | "least_open" | "skill";
+
+export interface AssignmentRule {
+  id: string;
+  tenantId: string;
+  name: string;
+  position: number;
+  enabled: boolean;
+  formId?: string;
+  priority?: string;
+  metadataMatch?: Record<string, unknown>;
+  group: string;
+  strategy: AssignmentStrategy;
+  skills: string[];
+  skillsField?: string;
+  createdAt: string;
+  updatedAt: string;
+}
+
+export type AssignmentRulePayload = Partial<
+  Pick<
+    AssignmentRule,
+    "name" | "position" | "enabled" | "formId" | "priority" | "metadataMatch" | "group" | "strategy" | "skills" | "skillsField"
+  >
+>;
+
+export interface Webhook {
+  id: string;
+  tenantId: string;
+  url: string;
+  description: string;
+  eventTypes: string[];
+  enabled: boolean;
+  consecutiveFailures: number;
+  failingSince?: string;
+  disabledAt?: string;
+  disabledReason: string;
+  secret?: string;
+  createdAt: string;
+  updatedAt: string;
+}
+
+export type WebhookPayload = Partial<Pick<Webhook, "url" | "description" | "eventTypes" | "enabled" | "secret">>;
+
+export type WebhookDeliveryStatus = "pending" | "succeeded" | "failed";
+
+export interface WebhookDelivery {
+  id: string;
+  webhookId: string;
+  eventId: string;
+  eventType: string;
+  payload: Record<string, unknown>;
+  status: WebhookDeliveryStatus;
+  attempts: number;
+  nextAttemptAt?: string;
+  lastAttemptAt?: string;
+  responseCode: number;
+  latencyMs: number;
+  lastError: string;
+  redeliveryOf?: string;
+  createdAt: string;
+  updatedAt: string;
+}
+
+export interface AuditChange {
+  before?: unknown;
+  after?: unknown;
+}
+
+export interface AuditEntry {
+  id: string;
+  tenantId: string;
+  seq: number;
+  service: string;
+  actor: string;
+  action: string;
+  resourceType: string;
+  resourceId: string;
+  changes: Record<string, AuditChange>;
+  requestId: string;
+  ip: string;
+  prevHash: string;
+  hash: string;
+  createdAt: string;
+}
+
+export interface AuditVerification {
+  valid: boolean;
+  entries: number;
+  head: string;
+  brokenAt: number;
+  reason: string;
 }
 
 export interface TicketSubmission {
   id: string;
+  tenantId: string;
   clientReference: string;
   status: string;
   ticketId?: string;
@@ -53,6 +285,40 @@ export interface TicketSubmission {
   completedAt?: string;
 }
 
+export type CommentVisibility = "public" | "internal";
+
+export interface TicketComment {
+  id: string;
+  ticketId: string;
+  authorId: string;
+  body?: string;
+  visibility: CommentVisibility;
+  edited: boolean;
+  editedAt?: string;
+  deleted: boolean;
+  deletedAt?: string;
+  createdAt: string;
+  updatedAt: string;
+}
+
+export interface TicketAttachment {
+  id: string;
+  ticketId: string;
+  fileName: string;
+  contentType: string;
+  size: number;
+  sha256: string;
+  uploadedBy?: string;
+  createdAt: string;
+}
+
+export interface TimelineEntry {
+  type: "comment" | "status" | "assignment";
+  at: string;
+  actor?: string;
+  data: Record<string, unknown>;
+}
+
 export interface TicketQueueMetrics {
   pending: number;
   processing: number;
@@ -63,17 +329,68 @@ export interface TicketQueueMetrics {
 
 export interface WorkflowDefinition {
   id: string;
+  tenantId: string;
   name: string;
   version: number;
   description: string;
   published: boolean;
   blueprint: Record<string, unknown>;
+  processKey?: string;
+  engineVersion?: number;
+  deployedAt?: string | null;
   createdAt: string;
   updatedAt: string;
+  deletedAt?: string;
+}
+
+export interface WorkflowVersion {
+  id: string;
+  tenantId: string;
+  definitionId: string;
+  name: string;
+  version: number;
+  description: string;
+  blueprint: Record<string, unknown>;
+  sourceVersion?: number;
+  publishedBy: string;
+  processKey?: string;
+  engineVersion?: number;
+  createdAt: string;
+}
+
+export interface FieldChange {
+  from: unknown;
+  to: unknown;
+}
+
+export interface WorkflowDiff {
+  from: number | "draft";
+  to: number | "draft";
+  identical: boolean;
+  fields: Record<string, FieldChange>;
+  steps: {
+    start?: FieldChange;
+    added: Record<string, unknown>[];
+    removed: Record<string, unknown>[];
+    changed: { id: string; fields: Record<string, FieldChange> }[];
+  };
+}
+
+export interface BlueprintIssue {
+  nodeId?: string;
+  code: string;
+  message: string;
+}
+
+export interface BlueprintValidation {
+  valid: boolean;
+  errors: BlueprintIssue[];
 }
 
 export interface ListResponse<T> {
   data: T[];
+  nextCursor?: string | null;
+  total?: number;
 }
 
 export interface ItemResponse<T> {
@@ -99,6 +416,7 @@ export interface CreateTicketPayload {
   title: string;
   status?: string;
   formId: string;
+  formVersion?: number;
   assigneeId?: string;
   priority?: string;
   metadata?: Record<string, unknown>;
@@ -107,12 +425,40 @@ export interface CreateTicketPayload {
 export interface CreateWorkflowPayload {
   name: string;
   description?: string;
-  version?: number;
   blueprint: Record<string, unknown>;
 }
 
-export async function listForms() {
-  const { data } = await apiClient.get<ListResponse<Form>>("/forms");
+export async function login(email: string, password: string) {
+  const { data } = await apiClient.post<ItemResponse<AuthSession>>("/auth/login", { email, password });
+  storeSession(data.data);
+  return data.data;
+}
+
+async function refreshSession(refreshToken: string) {
+  try {
+    const { data } = await apiClient.post<ItemResponse<AuthSession>>("/auth/refresh", { refreshToken });
+    storeSession(data.data);
+    return data.data;
+  } catch {
+    storeSession(null);
+    return null;
+  }
+}
+
+export async function logout() {
+  const session = getSession();
+  storeSession(null);
+  if (session) {
+    await apiClient.post("/auth/logout", { refreshToken: session.refreshToken });
+  }
+}
+
+export async function changePassword(currentPassword: string, newPassword: string) {
+  await apiClient.post("/auth/password", { currentPassword, newPassword });
+}
+
+export async function listForms(params?: { includeDeleted?: boolean }) {
+  const { data } = await apiClient.get<ListResponse<Form>>("/forms", { params });
   return data;
 }
 
@@ -121,16 +467,95 @@ export async function createForm(payload: CreateFormPayload) {
   return data;
 }
 
-export async function listUsers() {
-  const { data } = await apiClient.get<ListResponse<User>>("/users");
+// deleteForm fails with 409 while open tickets reference the form, unless forced.
+export async function deleteForm(id: string, options?: { force?: boolean }) {
+  await apiClient.delete(`/forms/${id}`, { params: options?.force ? { force: true } : undefined });
+}
+
+export async function restoreForm(id: string) {
+  const { data } = await apiClient.post<ItemResponse<Form>>(`/forms/${id}/restore`);
+  return data;
+}
+
+export async function getFormVersion(id: string, version: number) {
+  const { data } = await apiClient.get<ItemResponse<FormVersion>>(`/forms/${id}/versions/${version}`);
+  return data;
+}
+
+export async function listUsers(params?: { includeDeleted?: boolean }) {
+  const { data } = await apiClient.get<ListResponse<User>>("/users", { params });
+  return data;
+}
+
+export async function restoreUser(id: string) {
+  const { data } = await apiClient.post<ItemResponse<User>>(`/users/${id}/restore`);
+  return data;
+}
+
+export async function listRoles() {
+  const { data } = await apiClient.get<ListResponse<Role>>("/roles");
+  return data;
+}
+
+export async function listPermissions() {
+  const { data } = await apiClient.get<ListResponse<Permission>>("/roles/permissions");
+  return data;
+}
+
+export async function createRole(payload: RolePayload) {
+  const { data } = await apiClient.post<ItemResponse<Role>>("/roles", payload);
+  return data;
+}
+
+export async function updateRole(id: string, payload: Partial<RolePayload>) {
+  const { data } = await apiClient.put<ItemResponse<Role>>(`/roles/${id}`, payload);
   return data;
 }
 
-export async function listTickets(params?: { status?: string; assigneeId?: string }) {
+export async function deleteRole(id: string) {
+  await apiClient.delete(`/roles/${id}`);
+}
+
+export async function listAuditEntries(params?: {
+  resource?: string;
+  actor?: string;
+  action?: string;
+  service?: string;
+  from?: string;
+  to?: string;
+  cursor?: string;
+  limit?: number;
+}) {
+  const { data } = await apiClient.get<ListResponse<AuditEntry>>("/audit", { params });
+  return data;
+}
+
+export async function verifyAuditLog() {
+  const { data } = await apiClient.get<ItemResponse<AuditVerification>>("/audit/verify");
+  return data;
+}
+
+export async function setUserRoles(id: string, roles: string[]) {
+  const { data } = await apiClient.put<ItemResponse<User>>(`/users/${id}`, { roles });
+  return data;
+}
+
+export async function listTickets(params?: {
+  status?: string;
+  assigneeId?: string;
+  breached?: boolean;
+  dueBefore?: string;
+  includeDeleted?: boolean;
+}) {
   const { data } = await apiClient.get<ListResponse<Ticket>>("/tickets", { params });
   return data;
 }
 
+export async function restoreTicket(id: string) {
+  const { data } = await apiClient.post<ItemResponse<Ticket>>(`/tickets/${id}/restore`);
+  return data;
+}
+
 export async function createTicket(payload: CreateTicketPayload) {
   const { data } = await apiClient.post<ItemResponse<Ticket>>("/tickets", payload);
   return data;
@@ -151,12 +576,142 @@ export async function getTicketQueueMetrics() {
   return data.data;
 }
 
+export async function listSLAPolicies() {
+  const { data } = await apiClient.get<{ data: SLAPolicy[] }>("/tickets/sla-policies");
+  return data.data;
+}
+
+export async function createSLAPolicy(payload: SLAPolicyPayload) {
+  const { data } = await apiClient.post<ItemResponse<SLAPolicy>>("/tickets/sla-policies", payload);
+  return data.data;
+}
+
+export async function updateSLAPolicy(id: string, payload: SLAPolicyPayload) {
+  const { data } = await apiClient.patch<ItemResponse<SLAPolicy>>(`/tickets/sla-policies/${id}`, payload);
+  return data.data;
+}
+
+export async function deleteSLAPolicy(id: string) {
+  await apiClient.delete(`/tickets/sla-policies/${id}`);
+}
+
+export async function listAssignmentRules() {
+  const { data } = await apiClient.get<{ data: AssignmentRule[] }>("/tickets/assignment-rules");
+  return data.data;
+}
+
+export async function createAssignmentRule(payload: AssignmentRulePayload) {
+  const { data } = await apiClient.post<ItemResponse<AssignmentRule>>("/tickets/assignment-rules", payload);
+  return data.data;
+}
+
+export async function updateAssignmentRule(id: string, payload: AssignmentRulePayload) {
+  const { data } = await apiClient.patch<ItemResponse<AssignmentRule>>(`/tickets/assignment-rules/${id}`, payload);
+  return data.data;
+}
+
+export async function deleteAssignmentRule(id: string) {
+  await apiClient.delete(`/tickets/assignment-rules/${id}`);
+}
+
+export async function listWebhooks() {
+  const { data } = await apiClient.get<{ data: Webhook[] }>("/tickets/webhooks");
+  return data.data;
+}
+
+export async function createWebhook(payload: WebhookPayload) {
+  const { data } = await apiClient.post<ItemResponse<Webhook>>("/tickets/webhooks", payload);
+  return data.data;
+}
+
+export async function updateWebhook(id: string, payload: WebhookPayload) {
+  const { data } = await apiClient.patch<ItemResponse<Webhook>>(`/tickets/webhooks/${id}`, payload);
+  return data.data;
+}
+
+export async function deleteWebhook(id: string) {
+  await apiClient.delete(`/tickets/webhooks/${id}`);
+}
+
+export async function listWebhookDeliveries(
+  id: string,
+  params?: { status?: WebhookDeliveryStatus; eventType?: string; cursor?: string; limit?: number }
+) {
+  const { data } = await apiClient.get<ListResponse<WebhookDelivery>>(`/tickets/webhooks/${id}/deliveries`, { params });
+  return data;
+}
+
+export async function redeliverWebhookDelivery(id: string, deliveryId: string) {
+  const { data } = await apiClient.post<ItemResponse<WebhookDelivery>>(
+    `/tickets/webhooks/${id}/deliveries/${deliveryId}/redeliver`
+  );
+  return data.data;
+}
+
+export async function assignTicket(id: string, assigneeId?: string) {
+  const { data } = await apiClient.post<ItemResponse<Ticket>>(`/tickets/${id}/assign`, assigneeId === undefined ? {} : { assigneeId });
+  return data;
+}
+
 export async function resolveTicket(id: string) {
   const { data } = await apiClient.post<ItemResponse<Ticket>>(`/tickets/${id}/resolve`);
   return data;
 }
 
-export async function listWorkflows(params?: { published?: boolean }) {
+export async function listTicketComments(id: string) {
+  const { data } = await apiClient.get<{ data: TicketComment[] }>(`/tickets/${id}/comments`);
+  return data.data;
+}
+
+export async function createTicketComment(id: string, payload: { body: string; visibility?: CommentVisibility }) {
+  const { data } = await apiClient.post<ItemResponse<TicketComment>>(`/tickets/${id}/comments`, payload);
+  return data;
+}
+
+export async function updateTicketComment(
+  id: string,
+  commentId: string,
+  payload: { body?: string; visibility?: CommentVisibility },
+) {
+  const { data } = await apiClient.patch<ItemResponse<TicketComment>>(`/tickets/${id}/comments/${commentId}`, payload);
+  return data;
+}
+
+export async function deleteTicketComment(id: string, commentId: string) {
+  await apiClient.delete(`/tickets/${id}/comments/${commentId}`);
+}
+
+export async function listTicketAttachments(id: string) {
+  const { data } = await apiClient.get<{ data: TicketAttachment[] }>(`/tickets/${id}/attachments`);
+  return data.data;
+}
+
+export async function uploadTicketAttachment(id: string, file: File) {
+  const form = new FormData();
+  form.append("file", file);
+  const { data } = await apiClient.post<ItemResponse<TicketAttachment>>(`/tickets/${id}/attachments`, form, {
+    headers: { "Content-Type": "multipart/form-data" },
+  });
+  return data.data;
+}
+
+export async function downloadTicketAttachment(id: string, attachmentId: string) {
+  const { data } = await apiClient.get<Blob>(`/tickets/${id}/attachments/${attachmentId}`, {
+    responseType: "blob",
+  });
+  return data;
+}
+
+export async function deleteTicketAttachment(id: string, attachmentId: string) {
+  await apiClient.delete(`/tickets/${id}/attachments/${attachmentId}`);
+}
+
+export async function getTicketTimeline(id: string) {
+  const { data } = await apiClient.get<{ data: TimelineEntry[] }>(`/tickets/${id}/timeline`);
+  return data.data;
+}
+
+export async function listWorkflows(params?: { published?: boolean; includeDeleted?: boolean }) {
   const { data } = await apiClient.get<ListResponse<WorkflowDefinition>>("/workflows", { params });
   return data;
 }
@@ -166,11 +721,36 @@ export async function createWorkflow(payload: CreateWorkflowPayload) {
   return data;
 }
 
+export async function restoreWorkflow(id: string) {
+  const { data } = await apiClient.post<ItemResponse<WorkflowDefinition>>(`/workflows/${id}/restore`);
+  return data;
+}
+
 export async function publishWorkflow(id: string) {
   const { data } = await apiClient.post<ItemResponse<WorkflowDefinition>>(`/workflows/${id}/publish`);
   return data;
 }
 
+export async function listWorkflowVersions(id: string) {
+  const { data } = await apiClient.get<ListResponse<WorkflowVersion>>(`/workflows/${id}/versions`);
+  return data;
+}
+
+export async function diffWorkflowVersions(id: string, params?: { from?: number | "draft"; to?: number | "draft" }) {
+  const { data } = await apiClient.get<ItemResponse<WorkflowDiff>>(`/workflows/${id}/diff`, { params });
+  return data;
+}
+
+export async function rollbackWorkflow(id: string, version: number) {
+  const { data } = await apiClient.post<ItemResponse<WorkflowDefinition>>(`/workflows/${id}/versions/${version}/rollback`);
+  return data;
+}
+
+export async function validateWorkflowBlueprint(blueprint: Record<string, unknown>) {
+  const { data } = await apiClient.post<ItemResponse<BlueprintValidation>>("/workflows/validate", { blueprint });
+  return data;
+}
+
 export async function getOverview() {
   const { data } = await apiClient.get<OverviewResponse>("/overview");
   return data;
@@ -0,0 +1,24 @@
+package form
+
+import (
+    "gorm.io/gorm"
+
+    "github.com/pflow/components/outbox"
+)
+
+// eventSource is the source of the domain events the form component emits.
+const eventSource = "/pflow/form"
+
+// Domain events emitted after form changes, with the form ID as subject.
+const (
+    EventFormCreated  = "form.created"
+    EventFormUpdated  = "form.updated"
+    EventFormDeleted  = "form.deleted"
+    EventFormRestored = "form.restored"
+)
+
+// NewEventOutbox constructs the outbox the form events are recorded in, in the
+// transaction of the change they announce.
+func NewEventOutbox(db *gorm.DB) *outbox.EventStore {
+    return outbox.NewEventStore(db, eventSource)
+}
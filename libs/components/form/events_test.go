@@ -0,0 +1,85 @@
+package form
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "net/http"
+    "net/http/httptest"
+    "testing"
+
+    "github.com/go-chi/chi/v5"
+
+    "github.com/pflow/components/outbox"
+    "github.com/pflow/shared/mq"
+)
+
+type recordingPublisher struct {
+    events []mq.Event
+}
+
+func (p *recordingPublisher) Publish(ctx context.Context, key string, value []byte, headers map[string]string) error {
+    var event mq.Event
+    if err := json.Unmarshal(value, &event); err != nil {
+        return err
+    }
+    p.events = append(p.events, event)
+    return nil
+}
+
+func TestHandlerRecordsEventsInTheOutbox(t *testing.T) {
+    db := newTestDB(t)
+    router := chi.NewRouter()
+    NewHandler(NewGormRepository(db), WithEvents(NewEventOutbox(db)), WithReferences(openTickets(1), false)).Mount(router, "")
+
+    serve := func(method, path string, body any) (int, map[string]any) {
+        var payload bytes.Buffer
+        if body != nil {
+            json.NewEncoder(&payload).Encode(body)
+        }
+        rec := httptest.NewRecorder()
+        router.ServeHTTP(rec, httptest.NewRequest(method, path, &payload))
+        var decoded map[string]any
+        json.Unmarshal(rec.Body.Bytes(), &decoded)
+        return rec.Code, decoded
+    }
+
+    code, created := serve(http.MethodPost, "/forms", map[string]any{"name": "Laptop request", "schema": map[string]any{"fields": []any{}}})
+    if code != http.StatusCreated {
+        t.Fatalf("create: %d %v", code, created)
+    }
+    id := created["data"].(map[string]any)["id"].(string)
+
+    if code, body := serve(http.MethodPut, "/forms/"+id, map[string]any{"name": "Laptop order"}); code != http.StatusOK {
+        t.Fatalf("update: %d %v", code, body)
+    }
+    if code, _ := serve(http.MethodDelete, "/forms/"+id, nil); code != http.StatusConflict {
+        t.Fatalf("expected a referenced form to be kept, got %d", code)
+    }
+    if code, _ := serve(http.MethodDelete, "/forms/"+id+"?force=true", nil); code != http.StatusNoContent {
+        t.Fatalf("forced delete: %d", code)
+    }
+    if code, _ := serve(http.MethodPost, "/forms/"+id+"/restore", nil); code != http.StatusOK {
+        t.Fatalf("restore: %d", code)
+    }
+
+    publisher := &recordingPublisher{}
+    relay := outbox.NewRelay(outbox.NewRepository(db), outbox.Routes{outbox.Events: publisher}, outbox.RelayConfig{})
+    if _, err := relay.RelayOnce(context.Background()); err != nil {
+        t.Fatalf("relay: %v", err)
+    }
+
+    want := []string{EventFormCreated, EventFormUpdated, EventFormDeleted, EventFormRestored}
+    if len(publisher.events) != len(want) {
+        t.Fatalf("expected %d events, got %+v", len(want), publisher.events)
+    }
+    for i, event := range publisher.events {
+        if event.Type != want[i] || event.Subject != id || event.Source != eventSource {
+            t.Fatalf("unexpected event %d: %+v", i, event)
+        }
+    }
+    var updated map[string]any
+    if err := json.Unmarshal(publisher.events[1].Data, &updated); err != nil || updated["name"] != "Laptop order" {
+        t.Fatalf("expected the updated form as data, got %s", publisher.events[1].Data)
+    }
+}
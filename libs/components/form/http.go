@@ -1,26 +1,98 @@
 package form
 
 import (
+    "context"
     "encoding/json"
     "errors"
+    "fmt"
     "io"
+    "log"
     "net/http"
+    "strconv"
     "strings"
 
     "github.com/go-chi/chi/v5"
     "gorm.io/datatypes"
 
+    "github.com/pflow/components/outbox"
+    "github.com/pflow/shared/audit"
+    "github.com/pflow/shared/auth"
+    "github.com/pflow/shared/database"
     "github.com/pflow/shared/httpx"
 )
 
+var formListSpec = httpx.ListSpec{
+    SortFields: map[string]string{
+        "createdAt": "created_at",
+        "updatedAt": "updated_at",
+        "name":      "name",
+    },
+    DefaultSort:   "createdAt",
+    DefaultDesc:   true,
+    SearchColumns: []string{"name"},
+    SoftDelete:    true,
+}
+
+// References counts the open tickets submitted through a form.
+type References interface {
+    OpenTickets(ctx context.Context, formID string) (int64, error)
+}
+
 // Handler exposes reusable HTTP endpoints for form management.
 type Handler struct {
-    repo Repository
+    repo   Repository
+    authz  auth.Enforcer
+    events *outbox.EventStore
+    audit  *audit.Recorder
+    refs   References
+    // refsAllowOnError lets deletes through when refs fails to count.
+    refsAllowOnError bool
+}
+
+// HandlerOption customises the handler behaviour.
+type HandlerOption func(*Handler)
+
+// WithAuthorization guards every route with the permission it requires.
+func WithAuthorization() HandlerOption {
+    return func(h *Handler) {
+        h.authz.Enabled = true
+    }
+}
+
+// WithEvents records an event for every change in the transaction of the
+// change.
+func WithEvents(events *outbox.EventStore) HandlerOption {
+    return func(h *Handler) {
+        h.events = events
+    }
+}
+
+// WithAudit records every successful change in the audit log.
+func WithAudit(recorder *audit.Recorder) HandlerOption {
+    return func(h *Handler) {
+        h.audit = recorder
+    }
+}
+
+// WithReferences refuses to delete forms that open tickets still reference,
+// unless the request passes force=true. When the references cannot be counted
+// the delete is refused with 502, or, with allowOnError, logged and allowed.
+func WithReferences(refs References, allowOnError bool) HandlerOption {
+    return func(h *Handler) {
+        h.refs = refs
+        h.refsAllowOnError = allowOnError
+    }
 }
 
 // NewHandler constructs a Handler backed by the provided repository.
-func NewHandler(repo Repository) *Handler {
-    return &Handler{repo: repo}
+func NewHandler(repo Repository, opts ...HandlerOption) *Handler {
+    handler := &Handler{repo: repo}
+    for _, opt := range opts {
+        if opt != nil {
+            opt(handler)
+        }
+    }
+    return handler
 }
 
 // Mount registers the form routes on the provided router under the supplied base path.
@@ -30,13 +102,17 @@ func (h *Handler) Mount(router chi.Router, basePath string) {
         path = "/forms"
     }
 
+    require := h.authz.Require
     router.Route(path, func(r chi.Router) {
-        r.Get("/", h.listForms)
-        r.Post("/", h.createForm)
+        r.With(require(auth.PermissionFormView)).Get("/", h.listForms)
+        r.With(require(auth.PermissionFormEdit)).Post("/", h.createForm)
         r.Route("/{id}", func(r chi.Router) {
-            r.Get("/", h.getForm)
-            r.Put("/", h.updateForm)
-            r.Delete("/", h.deleteForm)
+            r.With(require(auth.PermissionFormView)).Get("/", h.getForm)
+            r.With(require(auth.PermissionFormEdit)).Put("/", h.updateForm)
+            r.With(require(auth.PermissionFormDelete)).Delete("/", h.deleteForm)
+            r.With(require(auth.PermissionFormDelete)).Post("/restore", h.restoreForm)
+            r.With(require(auth.PermissionFormView)).Get("/versions", h.listVersions)
+            r.With(require(auth.PermissionFormView)).Get("/versions/{version}", h.getVersion)
         })
     })
 }
@@ -54,8 +130,17 @@ type updateFormRequest struct {
 }
 
 func (h *Handler) listForms(w http.ResponseWriter, r *http.Request) {
-    search := strings.TrimSpace(r.URL.Query().Get("search"))
-    forms, err := h.repo.List(r.Context(), search)
+    query, err := httpx.ParseListQuery(r, formListSpec)
+    if err != nil {
+        httpx.Error(w, http.StatusBadRequest, err.Error())
+        return
+    }
+    if query.IncludeDeleted && !h.authz.Allows(r, auth.PermissionFormDelete) {
+        httpx.Error(w, http.StatusForbidden, "listing deleted forms requires "+auth.PermissionFormDelete)
+        return
+    }
+
+    forms, page, err := h.repo.List(r.Context(), query)
     if err != nil {
         httpx.Error(w, http.StatusInternalServerError, err.Error())
         return
@@ -66,7 +151,7 @@ func (h *Handler) listForms(w http.ResponseWriter, r *http.Request) {
         items = append(items, entity.ToDTO())
     }
 
-    httpx.JSON(w, http.StatusOK, map[string]any{"data": items})
+    httpx.List(w, items, page)
 }
 
 func (h *Handler) createForm(w http.ResponseWriter, r *http.Request) {
@@ -82,6 +167,10 @@ func (h *Handler) createForm(w http.ResponseWriter, r *http.Request) {
         return
     }
 
+    if !checkSchema(w, payload.Schema) {
+        return
+    }
+
     entity := &Form{
         Name:        name,
         Description: strings.TrimSpace(payload.Description),
@@ -90,10 +179,17 @@ func (h *Handler) createForm(w http.ResponseWriter, r *http.Request) {
         entity.Schema = datatypes.JSONMap(payload.Schema)
     }
 
-    if err := h.repo.Create(r.Context(), entity); err != nil {
+    err := h.events.Transaction(r.Context(), func(ctx context.Context) error {
+        if err := h.repo.Create(ctx, entity); err != nil {
+            return err
+        }
+        return h.events.Emit(ctx, EventFormCreated, entity.ID, entity.ToDTO())
+    })
+    if err != nil {
         httpx.Error(w, http.StatusInternalServerError, err.Error())
         return
     }
+    h.audit.Record(r.Context(), audit.Change{Action: EventFormCreated, ResourceID: entity.ID, After: entity.ToDTO()})
 
     httpx.JSON(w, http.StatusCreated, map[string]any{"data": entity.ToDTO()})
 }
@@ -134,6 +230,9 @@ func (h *Handler) updateForm(w http.ResponseWriter, r *http.Request) {
         updates["description"] = strings.TrimSpace(*payload.Description)
     }
     if payload.Schema != nil {
+        if !checkSchema(w, payload.Schema) {
+            return
+        }
         updates["schema"] = datatypes.JSONMap(payload.Schema)
     }
     if len(updates) == 0 {
@@ -141,7 +240,23 @@ func (h *Handler) updateForm(w http.ResponseWriter, r *http.Request) {
         return
     }
 
-    entity, err := h.repo.Update(r.Context(), id, updates)
+    before, err := h.repo.Find(r.Context(), id)
+    if err != nil {
+        if IsNotFound(err) {
+            httpx.Error(w, http.StatusNotFound, "form not found")
+            return
+        }
+        httpx.Error(w, http.StatusInternalServerError, err.Error())
+        return
+    }
+    var entity *Form
+    err = h.events.Transaction(r.Context(), func(ctx context.Context) error {
+        var err error
+        if entity, err = h.repo.Update(ctx, id, updates); err != nil {
+            return err
+        }
+        return h.events.Emit(ctx, EventFormUpdated, entity.ID, entity.ToDTO())
+    })
     if err != nil {
         if IsNotFound(err) {
             httpx.Error(w, http.StatusNotFound, "form not found")
@@ -150,13 +265,15 @@ func (h *Handler) updateForm(w http.ResponseWriter, r *http.Request) {
         httpx.Error(w, http.StatusInternalServerError, err.Error())
         return
     }
+    h.audit.Record(r.Context(), audit.Change{Action: EventFormUpdated, ResourceID: entity.ID, Before: before.ToDTO(), After: entity.ToDTO()})
 
     httpx.JSON(w, http.StatusOK, map[string]any{"data": entity.ToDTO()})
 }
 
 func (h *Handler) deleteForm(w http.ResponseWriter, r *http.Request) {
     id := chi.URLParam(r, "id")
-    if err := h.repo.Delete(r.Context(), id); err != nil {
+    before, err := h.repo.Find(r.Context(), id)
+    if err != nil {
         if IsNotFound(err) {
             httpx.Error(w, http.StatusNotFound, "form not found")
             return
@@ -164,10 +281,121 @@ func (h *Handler) deleteForm(w http.ResponseWriter, r *http.Request) {
         httpx.Error(w, http.StatusInternalServerError, err.Error())
         return
     }
+    if h.refs != nil && r.URL.Query().Get("force") != "true" {
+        open, err := h.refs.OpenTickets(r.Context(), id)
+        switch {
+        case err != nil && h.refsAllowOnError:
+            log.Printf("form references: deleting form %s without checking its tickets: %v", id, err)
+        case err != nil:
+            httpx.Error(w, http.StatusBadGateway, fmt.Sprintf("cannot check the tickets of the form: %v; pass force=true to delete it anyway", err))
+            return
+        case open > 0:
+            httpx.Error(w, http.StatusConflict, fmt.Sprintf("form is referenced by %d open tickets; pass force=true to delete it anyway", open))
+            return
+        }
+    }
+    err = h.events.Transaction(r.Context(), func(ctx context.Context) error {
+        if err := h.repo.Delete(ctx, id); err != nil {
+            return err
+        }
+        return h.events.Emit(ctx, EventFormDeleted, id, map[string]any{"id": id})
+    })
+    if err != nil {
+        if IsNotFound(err) {
+            httpx.Error(w, http.StatusNotFound, "form not found")
+            return
+        }
+        httpx.Error(w, http.StatusInternalServerError, err.Error())
+        return
+    }
+    h.audit.Record(r.Context(), audit.Change{Action: EventFormDeleted, ResourceID: id, Before: before.ToDTO()})
 
     w.WriteHeader(http.StatusNoContent)
 }
 
+// restoreForm undoes the deletion of a form that was not purged yet.
+func (h *Handler) restoreForm(w http.ResponseWriter, r *http.Request) {
+    var entity *Form
+    err := h.events.Transaction(r.Context(), func(ctx context.Context) error {
+        var err error
+        if entity, err = h.repo.Restore(ctx, chi.URLParam(r, "id")); err != nil {
+            return err
+        }
+        return h.events.Emit(ctx, EventFormRestored, entity.ID, entity.ToDTO())
+    })
+    if err != nil {
+        switch {
+        case IsNotFound(err):
+            httpx.Error(w, http.StatusNotFound, "form not found")
+        case errors.Is(err, database.ErrNotDeleted):
+            httpx.Error(w, http.StatusConflict, "form is not deleted")
+        default:
+            httpx.Error(w, http.StatusInternalServerError, err.Error())
+        }
+        return
+    }
+    h.audit.Record(r.Context(), audit.Change{Action: EventFormRestored, ResourceID: entity.ID, After: entity.ToDTO()})
+
+    httpx.JSON(w, http.StatusOK, map[string]any{"data": entity.ToDTO()})
+}
+
+func (h *Handler) listVersions(w http.ResponseWriter, r *http.Request) {
+    id := chi.URLParam(r, "id")
+    versions, err := h.repo.Versions(r.Context(), id)
+    if err != nil {
+        if IsNotFound(err) {
+            httpx.Error(w, http.StatusNotFound, "form not found")
+            return
+        }
+        httpx.Error(w, http.StatusInternalServerError, err.Error())
+        return
+    }
+
+    items := make([]map[string]any, 0, len(versions))
+    for _, version := range versions {
+        items = append(items, version.ToDTO())
+    }
+
+    httpx.JSON(w, http.StatusOK, map[string]any{"data": items})
+}
+
+func (h *Handler) getVersion(w http.ResponseWriter, r *http.Request) {
+    id := chi.URLParam(r, "id")
+    number, err := strconv.Atoi(chi.URLParam(r, "version"))
+    if err != nil || number <= 0 {
+        httpx.Error(w, http.StatusBadRequest, "version must be a positive integer")
+        return
+    }
+
+    version, err := h.repo.FindVersion(r.Context(), id, number)
+    if err != nil {
+        if IsNotFound(err) {
+            httpx.Error(w, http.StatusNotFound, "form version not found")
+            return
+        }
+        httpx.Error(w, http.StatusInternalServerError, err.Error())
+        return
+    }
+
+    httpx.JSON(w, http.StatusOK, map[string]any{"data": version.ToDTO()})
+}
+
+// checkSchema rejects schema documents whose field definitions are malformed.
+func checkSchema(w http.ResponseWriter, raw map[string]any) bool {
+    _, err := ParseSchema(raw)
+    if err == nil {
+        return true
+    }
+
+    var invalid *ValidationError
+    if errors.As(err, &invalid) {
+        httpx.ErrorDetails(w, http.StatusBadRequest, "invalid form schema", invalid.Fields)
+        return false
+    }
+    httpx.Error(w, http.StatusBadRequest, err.Error())
+    return false
+}
+
 func decodeJSON(r *http.Request, v any) error {
     defer r.Body.Close()
     decoder := json.NewDecoder(r.Body)
@@ -0,0 +1,17 @@
+package form
+
+import (
+    "gorm.io/gorm"
+
+    "github.com/pflow/components/outbox"
+)
+
+// Migrate creates the form schema and its event outbox. Versions recorded before they carried a
+// tenant move to the tenant of their form.
+func Migrate(db *gorm.DB) error {
+    if err := db.AutoMigrate(&Form{}, &FormVersion{}, &outbox.Message{}); err != nil {
+        return err
+    }
+    return db.Exec(`UPDATE form_versions SET tenant_id = (SELECT forms.tenant_id FROM forms WHERE forms.id = form_versions.form_id)
+        WHERE EXISTS (SELECT 1 FROM forms WHERE forms.id = form_versions.form_id AND forms.tenant_id <> form_versions.tenant_id)`).Error
+}
@@ -11,11 +11,26 @@ import (
 // Form represents a persisted form definition that can be attached to a workflow.
 type Form struct {
     ID          string            `json:"id" gorm:"type:uuid;primaryKey"`
+    TenantID    string            `json:"tenantId" gorm:"type:varchar(64);not null;default:default;index"`
     Name        string            `json:"name"`
     Description string            `json:"description"`
     Schema      datatypes.JSONMap `json:"schema" gorm:"type:jsonb"`
+    Version     int               `json:"version" gorm:"not null;default:0"`
     CreatedAt   time.Time         `json:"createdAt"`
     UpdatedAt   time.Time         `json:"updatedAt"`
+    DeletedAt   gorm.DeletedAt    `json:"deletedAt,omitempty" gorm:"index"`
+}
+
+// FormVersion is an immutable snapshot of a form schema. A new version is recorded
+// whenever the schema of a form changes, so tickets can keep rendering against the
+// schema they were submitted with.
+type FormVersion struct {
+    ID        string            `json:"id" gorm:"type:uuid;primaryKey"`
+    TenantID  string            `json:"tenantId" gorm:"type:varchar(64);not null;default:default;index"`
+    FormID    string            `json:"formId" gorm:"type:uuid;not null;uniqueIndex:idx_form_versions_form_version"`
+    Version   int               `json:"version" gorm:"not null;uniqueIndex:idx_form_versions_form_version"`
+    Schema    datatypes.JSONMap `json:"schema" gorm:"type:jsonb"`
+    CreatedAt time.Time         `json:"createdAt"`
 }
 
 // BeforeCreate ensures that a UUID is present for new records.
@@ -26,6 +41,25 @@ func (f *Form) BeforeCreate(tx *gorm.DB) error {
     return nil
 }
 
+// BeforeCreate ensures that a UUID is present for new records.
+func (v *FormVersion) BeforeCreate(tx *gorm.DB) error {
+    if v.ID == "" {
+        v.ID = uuid.NewString()
+    }
+    return nil
+}
+
+func (f Form) sortValue(column string) (any, string) {
+    switch column {
+    case "updated_at":
+        return f.UpdatedAt, f.ID
+    case "name":
+        return f.Name, f.ID
+    default:
+        return f.CreatedAt, f.ID
+    }
+}
+
 // ToDTO converts the model into a response-friendly structure.
 func (f Form) ToDTO() map[string]any {
     schema := map[string]any{}
@@ -33,12 +67,34 @@ func (f Form) ToDTO() map[string]any {
         schema = map[string]any(f.Schema)
     }
 
-    return map[string]any{
+    payload := map[string]any{
         "id":          f.ID,
+        "tenantId":    f.TenantID,
         "name":        f.Name,
         "description": f.Description,
         "schema":      schema,
+        "version":     f.Version,
         "createdAt":   f.CreatedAt,
         "updatedAt":   f.UpdatedAt,
     }
+    if f.DeletedAt.Valid {
+        payload["deletedAt"] = f.DeletedAt.Time
+    }
+    return payload
+}
+
+// ToDTO converts the version into a response-friendly structure.
+func (v FormVersion) ToDTO() map[string]any {
+    schema := map[string]any{}
+    if v.Schema != nil {
+        schema = map[string]any(v.Schema)
+    }
+
+    return map[string]any{
+        "id":        v.ID,
+        "formId":    v.FormID,
+        "version":   v.Version,
+        "schema":    schema,
+        "createdAt": v.CreatedAt,
+    }
 }
@@ -0,0 +1,78 @@
+package form
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "net/url"
+    "strings"
+    "time"
+
+    "github.com/pflow/shared/auth"
+    "github.com/pflow/shared/tenant"
+)
+
+// referencesPrincipal is the caller remote References present to the ticket
+// service.
+const referencesPrincipal = "system:form-references"
+
+// openTicketStatuses are the ticket statuses that keep a form in use.
+var openTicketStatuses = []string{"open", "in_progress"}
+
+// remoteReferences counts open tickets through the ticket service.
+type remoteReferences struct {
+    base   string
+    client *http.Client
+    secret []byte
+}
+
+// NewRemoteReferences constructs References that count the open tickets of a form
+// through the ticket service. Requests are made in the tenant of the request
+// context and, when secret is set, signed as a principal allowed to view tickets.
+func NewRemoteReferences(baseURL string, client *http.Client, secret []byte) References {
+    if client == nil {
+        client = &http.Client{Timeout: 5 * time.Second}
+    }
+    return &remoteReferences{
+        base:   strings.TrimRight(strings.TrimSpace(baseURL), "/"),
+        client: client,
+        secret: secret,
+    }
+}
+
+// OpenTickets returns how many open or in-progress tickets reference the form.
+func (r *remoteReferences) OpenTickets(ctx context.Context, formID string) (int64, error) {
+    params := url.Values{}
+    params.Set("formId", formID)
+    params.Set("status", strings.Join(openTicketStatuses, ","))
+    params.Set("limit", "1")
+
+    req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.base+"/tickets?"+params.Encode(), nil)
+    if err != nil {
+        return 0, err
+    }
+    req.Header.Set(tenant.Header, tenant.ID(ctx))
+    auth.Forward(req.Header, auth.Principal{
+        UserID:      referencesPrincipal,
+        Permissions: []string{auth.PermissionTicketView},
+        TenantID:    tenant.ID(ctx),
+    }, r.secret)
+    resp, err := r.client.Do(req)
+    if err != nil {
+        return 0, fmt.Errorf("count tickets of form %s: %w", formID, err)
+    }
+    defer resp.Body.Close()
+
+    if resp.StatusCode != http.StatusOK {
+        return 0, fmt.Errorf("count tickets of form %s: unexpected status %d", formID, resp.StatusCode)
+    }
+
+    var envelope struct {
+        Total int64 `json:"total"`
+    }
+    if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+        return 0, fmt.Errorf("decode tickets of form %s: %w", formID, err)
+    }
+    return envelope.Total, nil
+}
@@ -1,22 +1,35 @@
 package form
 
 import (
+    "bytes"
     "context"
+    "encoding/json"
     "errors"
+    "time"
 
+    "gorm.io/datatypes"
     "gorm.io/gorm"
+    "gorm.io/gorm/clause"
+
+    "github.com/pflow/shared/database"
+    "github.com/pflow/shared/httpx"
+    "github.com/pflow/shared/tenant"
 )
 
 // Repository defines the persistence contract for forms.
 type Repository interface {
-    List(ctx context.Context, search string) ([]Form, error)
+    List(ctx context.Context, query httpx.ListQuery) ([]Form, httpx.Page, error)
     Create(ctx context.Context, payload *Form) error
     Find(ctx context.Context, id string) (*Form, error)
     Update(ctx context.Context, id string, updates map[string]any) (*Form, error)
     Delete(ctx context.Context, id string) error
+    Restore(ctx context.Context, id string) (*Form, error)
+    Versions(ctx context.Context, id string) ([]FormVersion, error)
+    FindVersion(ctx context.Context, id string, version int) (*FormVersion, error)
 }
 
 // GormRepository provides a relational-backed implementation of Repository.
+// Every query is scoped to the tenant of the request context.
 type GormRepository struct {
     db *gorm.DB
 }
@@ -26,57 +39,109 @@ func NewGormRepository(db *gorm.DB) *GormRepository {
     return &GormRepository{db: db}
 }
 
-// List returns all forms, optionally filtered by a case-insensitive name search.
-func (r *GormRepository) List(ctx context.Context, search string) ([]Form, error) {
-    query := r.db.WithContext(ctx).Model(&Form{}).Order("created_at DESC")
-    if search != "" {
-        like := "%" + search + "%"
-        query = query.Where("LOWER(name) LIKE LOWER(?)", like)
-    }
+func (r *GormRepository) scoped(ctx context.Context) *gorm.DB {
+    return database.Conn(ctx, r.db).Scopes(database.TenantScope(ctx))
+}
 
-    var forms []Form
-    if err := query.Find(&forms).Error; err != nil {
-        return nil, err
-    }
-    return forms, nil
+// List returns a page of forms, optionally filtered by a case-insensitive name search.
+func (r *GormRepository) List(ctx context.Context, query httpx.ListQuery) ([]Form, httpx.Page, error) {
+    return database.Paginate(r.scoped(ctx).Model(&Form{}), query, Form.sortValue)
 }
 
-// Create persists a new form.
+// Create persists a new form together with its first schema version.
 func (r *GormRepository) Create(ctx context.Context, payload *Form) error {
-    return r.db.WithContext(ctx).Create(payload).Error
+    return database.Conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+        payload.TenantID = tenant.ID(ctx)
+        payload.Version = 1
+        if err := tx.Create(payload).Error; err != nil {
+            return err
+        }
+        return tx.Create(&FormVersion{TenantID: payload.TenantID, FormID: payload.ID, Version: 1, Schema: payload.Schema}).Error
+    })
 }
 
 // Find returns a form by ID.
 func (r *GormRepository) Find(ctx context.Context, id string) (*Form, error) {
     var entity Form
-    if err := r.db.WithContext(ctx).First(&entity, "id = ?", id).Error; err != nil {
+    if err := r.scoped(ctx).First(&entity, "id = ?", id).Error; err != nil {
         return nil, err
     }
     return &entity, nil
 }
 
-// Update applies partial updates to a form.
+// Update applies partial updates to a form. A changed schema is never overwritten in
+// place: it bumps the form version and is recorded as a new FormVersion.
 func (r *GormRepository) Update(ctx context.Context, id string, updates map[string]any) (*Form, error) {
     var entity Form
-    tx := r.db.WithContext(ctx)
-    if err := tx.First(&entity, "id = ?", id).Error; err != nil {
+    err := database.Conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+        if err := tx.Scopes(database.TenantScope(ctx)).Clauses(clause.Locking{Strength: "UPDATE"}).First(&entity, "id = ?", id).Error; err != nil {
+            return err
+        }
+
+        if schema, ok := updates["schema"].(datatypes.JSONMap); ok {
+            if sameSchema(entity.Schema, schema) {
+                delete(updates, "schema")
+            } else {
+                if entity.Version == 0 {
+                    // Forms created before versioning get their original schema preserved as version 1.
+                    entity.Version = 1
+                    if err := tx.Create(&FormVersion{TenantID: entity.TenantID, FormID: entity.ID, Version: 1, Schema: entity.Schema}).Error; err != nil {
+                        return err
+                    }
+                }
+                next := entity.Version + 1
+                if err := tx.Create(&FormVersion{TenantID: entity.TenantID, FormID: entity.ID, Version: next, Schema: schema}).Error; err != nil {
+                    return err
+                }
+                updates["version"] = next
+            }
+        }
+
+        if len(updates) > 0 {
+            if err := tx.Model(&entity).Updates(updates).Error; err != nil {
+                return err
+            }
+        }
+
+        return tx.First(&entity, "id = ?", id).Error
+    })
+    if err != nil {
         return nil, err
     }
 
-    if err := tx.Model(&entity).Updates(updates).Error; err != nil {
+    return &entity, nil
+}
+
+// Versions returns every recorded schema version of a form, oldest first. The
+// versions of deleted and purged forms stay readable, since tickets keep
+// rendering against them.
+func (r *GormRepository) Versions(ctx context.Context, id string) ([]FormVersion, error) {
+    var versions []FormVersion
+    if err := r.scoped(ctx).Where("form_id = ?", id).Order("version ASC").Find(&versions).Error; err != nil {
         return nil, err
     }
+    if len(versions) == 0 {
+        // Forms created before versioning have no versions yet.
+        if err := r.scoped(ctx).Unscoped().Select("id").First(&Form{}, "id = ?", id).Error; err != nil {
+            return nil, err
+        }
+    }
+    return versions, nil
+}
 
-    if err := tx.First(&entity, "id = ?", id).Error; err != nil {
+// FindVersion returns a specific schema version of a form, including the
+// versions of deleted and purged forms.
+func (r *GormRepository) FindVersion(ctx context.Context, id string, version int) (*FormVersion, error) {
+    var entity FormVersion
+    if err := r.scoped(ctx).First(&entity, "form_id = ? AND version = ?", id, version).Error; err != nil {
         return nil, err
     }
-
     return &entity, nil
 }
 
-// Delete removes a form by ID.
+// Delete soft-deletes a form by ID. The form can be restored until it is purged.
 func (r *GormRepository) Delete(ctx context.Context, id string) error {
-    result := r.db.WithContext(ctx).Delete(&Form{}, "id = ?", id)
+    result := r.scoped(ctx).Delete(&Form{}, "id = ?", id)
     if result.Error != nil {
         return result.Error
     }
@@ -86,6 +151,34 @@ func (r *GormRepository) Delete(ctx context.Context, id string) error {
     return nil
 }
 
+// Restore undeletes a soft-deleted form, failing with database.ErrNotDeleted
+// when the form was not deleted.
+func (r *GormRepository) Restore(ctx context.Context, id string) (*Form, error) {
+    if err := database.Restore(ctx, r.db, &Form{}, id); err != nil {
+        return nil, err
+    }
+    return r.Find(ctx, id)
+}
+
+// PurgeDeleted hard-deletes, in every tenant, up to limit forms deleted before
+// the cutoff. Their schema versions are kept for the tickets submitted with
+// them.
+func (r *GormRepository) PurgeDeleted(ctx context.Context, before time.Time, limit int) (int, error) {
+    return database.PurgeDeleted(ctx, r.db, &Form{}, before, limit, nil)
+}
+
+func sameSchema(current, next datatypes.JSONMap) bool {
+    left, err := json.Marshal(current)
+    if err != nil {
+        return false
+    }
+    right, err := json.Marshal(next)
+    if err != nil {
+        return false
+    }
+    return bytes.Equal(left, right)
+}
+
 // IsNotFound reports whether an error indicates a missing record.
 func IsNotFound(err error) bool {
     return errors.Is(err, gorm.ErrRecordNotFound)
@@ -0,0 +1,280 @@
+package form
+
+import (
+    "encoding/json"
+    "fmt"
+    "net/mail"
+    "regexp"
+    "strings"
+    "time"
+    "unicode/utf8"
+)
+
+// FieldType enumerates the input types a form field can declare.
+type FieldType string
+
+const (
+    FieldText        FieldType = "text"
+    FieldNumber      FieldType = "number"
+    FieldSelect      FieldType = "select"
+    FieldMultiSelect FieldType = "multi-select"
+    FieldDate        FieldType = "date"
+    FieldEmail       FieldType = "email"
+    FieldCheckbox    FieldType = "checkbox"
+)
+
+var knownFieldTypes = map[FieldType]struct{}{
+    FieldText:        {},
+    FieldNumber:      {},
+    FieldSelect:      {},
+    FieldMultiSelect: {},
+    FieldDate:        {},
+    FieldEmail:       {},
+    FieldCheckbox:    {},
+}
+
+// Field describes a single input of a form. Min and Max bound the value of number
+// fields, the length of text and email fields and the number of selections of
+// multi-select fields.
+type Field struct {
+    Name     string    `json:"name"`
+    Label    string    `json:"label,omitempty"`
+    Type     FieldType `json:"type"`
+    Required bool      `json:"required,omitempty"`
+    Min      *float64  `json:"min,omitempty"`
+    Max      *float64  `json:"max,omitempty"`
+    Pattern  string    `json:"pattern,omitempty"`
+    Options  []string  `json:"options,omitempty"`
+
+    pattern *regexp.Regexp
+}
+
+// Schema is the typed representation of Form.Schema.
+type Schema struct {
+    Fields []Field `json:"fields"`
+}
+
+// FieldError reports why a single field failed validation.
+type FieldError struct {
+    Field   string `json:"field"`
+    Message string `json:"message"`
+}
+
+// ValidationError aggregates the field errors produced while validating a schema or
+// data against a schema.
+type ValidationError struct {
+    Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+    parts := make([]string, 0, len(e.Fields))
+    for _, field := range e.Fields {
+        parts = append(parts, fmt.Sprintf("%s: %s", field.Field, field.Message))
+    }
+    return "validation failed: " + strings.Join(parts, "; ")
+}
+
+func (e *ValidationError) add(field, format string, args ...any) {
+    e.Fields = append(e.Fields, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+func (e *ValidationError) orNil() error {
+    if len(e.Fields) == 0 {
+        return nil
+    }
+    return e
+}
+
+// ParseSchema decodes a stored schema document and checks that its field
+// definitions are well formed. An empty document yields an empty schema.
+func ParseSchema(raw map[string]any) (Schema, error) {
+    var schema Schema
+    if len(raw) == 0 {
+        return schema, nil
+    }
+
+    encoded, err := json.Marshal(raw)
+    if err != nil {
+        return Schema{}, err
+    }
+    if err := json.Unmarshal(encoded, &schema); err != nil {
+        return Schema{}, fmt.Errorf("invalid schema: %w", err)
+    }
+    if err := schema.compile(); err != nil {
+        return Schema{}, err
+    }
+    return schema, nil
+}
+
+func (s *Schema) compile() error {
+    problems := &ValidationError{}
+    seen := make(map[string]struct{}, len(s.Fields))
+    for i := range s.Fields {
+        field := &s.Fields[i]
+        field.Name = strings.TrimSpace(field.Name)
+        field.Type = FieldType(strings.ToLower(strings.TrimSpace(string(field.Type))))
+
+        name := field.Name
+        if name == "" {
+            name = fmt.Sprintf("fields[%d]", i)
+            problems.add(name, "name is required")
+        } else if _, ok := seen[name]; ok {
+            problems.add(name, "duplicate field name")
+        }
+        seen[name] = struct{}{}
+
+        if _, ok := knownFieldTypes[field.Type]; !ok {
+            problems.add(name, "unsupported field type %q", field.Type)
+        }
+        if (field.Type == FieldSelect || field.Type == FieldMultiSelect) && len(field.Options) == 0 {
+            problems.add(name, "%s fields require options", field.Type)
+        }
+        if field.Min != nil && field.Max != nil && *field.Min > *field.Max {
+            problems.add(name, "min must not exceed max")
+        }
+        if field.Pattern != "" {
+            compiled, err := regexp.Compile(field.Pattern)
+            if err != nil {
+                problems.add(name, "invalid pattern: %v", err)
+            }
+            field.pattern = compiled
+        }
+    }
+    return problems.orNil()
+}
+
+// Validate checks data against the schema and returns a *ValidationError listing
+// every offending field. Keys that are not declared by the schema are ignored.
+func (s Schema) Validate(data map[string]any) error {
+    problems := &ValidationError{}
+    for _, field := range s.Fields {
+        value, present := data[field.Name]
+        if !present || isEmpty(value) {
+            if field.Required {
+                problems.add(field.Name, "is required")
+            }
+            continue
+        }
+        if message := field.check(value); message != "" {
+            problems.add(field.Name, "%s", message)
+        }
+    }
+    return problems.orNil()
+}
+
+func (f Field) check(value any) string {
+    switch f.Type {
+    case FieldText, FieldEmail:
+        text, ok := value.(string)
+        if !ok {
+            return "must be a string"
+        }
+        if f.Type == FieldEmail {
+            if address, err := mail.ParseAddress(text); err != nil || address.Address != text {
+                return "must be a valid email address"
+            }
+        }
+        if message := f.checkBounds(float64(utf8.RuneCountInString(text)), "length"); message != "" {
+            return message
+        }
+        if f.pattern != nil && !f.pattern.MatchString(text) {
+            return fmt.Sprintf("must match pattern %s", f.Pattern)
+        }
+    case FieldNumber:
+        number, ok := toFloat(value)
+        if !ok {
+            return "must be a number"
+        }
+        return f.checkBounds(number, "value")
+    case FieldSelect:
+        choice, ok := value.(string)
+        if !ok {
+            return "must be a string"
+        }
+        if !f.hasOption(choice) {
+            return fmt.Sprintf("must be one of %s", strings.Join(f.Options, ", "))
+        }
+    case FieldMultiSelect:
+        choices, ok := value.([]any)
+        if !ok {
+            return "must be a list"
+        }
+        for _, item := range choices {
+            choice, ok := item.(string)
+            if !ok || !f.hasOption(choice) {
+                return fmt.Sprintf("values must be among %s", strings.Join(f.Options, ", "))
+            }
+        }
+        return f.checkBounds(float64(len(choices)), "number of selections")
+    case FieldDate:
+        text, ok := value.(string)
+        if !ok || !isDate(text) {
+            return "must be a date (YYYY-MM-DD or RFC3339)"
+        }
+    case FieldCheckbox:
+        checked, ok := value.(bool)
+        if !ok {
+            return "must be a boolean"
+        }
+        if f.Required && !checked {
+            return "must be checked"
+        }
+    }
+    return ""
+}
+
+func (f Field) checkBounds(value float64, subject string) string {
+    if f.Min != nil && value < *f.Min {
+        return fmt.Sprintf("%s must be at least %g", subject, *f.Min)
+    }
+    if f.Max != nil && value > *f.Max {
+        return fmt.Sprintf("%s must be at most %g", subject, *f.Max)
+    }
+    return ""
+}
+
+func (f Field) hasOption(value string) bool {
+    for _, option := range f.Options {
+        if option == value {
+            return true
+        }
+    }
+    return false
+}
+
+func isEmpty(value any) bool {
+    switch v := value.(type) {
+    case nil:
+        return true
+    case string:
+        return strings.TrimSpace(v) == ""
+    case []any:
+        return len(v) == 0
+    }
+    return false
+}
+
+func isDate(value string) bool {
+    if _, err := time.Parse("2006-01-02", value); err == nil {
+        return true
+    }
+    _, err := time.Parse(time.RFC3339, value)
+    return err == nil
+}
+
+func toFloat(value any) (float64, bool) {
+    switch v := value.(type) {
+    case float64:
+        return v, true
+    case float32:
+        return float64(v), true
+    case int:
+        return float64(v), true
+    case int64:
+        return float64(v), true
+    case json.Number:
+        parsed, err := v.Float64()
+        return parsed, err == nil
+    }
+    return 0, false
+}
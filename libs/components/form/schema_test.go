@@ -0,0 +1,88 @@
+package form
+
+import (
+    "errors"
+    "testing"
+)
+
+func testSchema(t *testing.T) Schema {
+    t.Helper()
+    schema, err := ParseSchema(map[string]any{
+        "fields": []any{
+            map[string]any{"name": "summary", "type": "text", "required": true, "min": 3, "max": 20},
+            map[string]any{"name": "code", "type": "text", "pattern": "^[A-Z]{3}$"},
+            map[string]any{"name": "amount", "type": "number", "min": 0, "max": 100},
+            map[string]any{"name": "category", "type": "select", "options": []any{"hardware", "software"}},
+            map[string]any{"name": "tags", "type": "multi-select", "options": []any{"a", "b", "c"}, "max": 2},
+            map[string]any{"name": "due", "type": "date"},
+            map[string]any{"name": "contact", "type": "email"},
+            map[string]any{"name": "agree", "type": "checkbox", "required": true},
+        },
+    })
+    if err != nil {
+        t.Fatalf("parse schema: %v", err)
+    }
+    return schema
+}
+
+func TestSchemaAcceptsValidData(t *testing.T) {
+    err := testSchema(t).Validate(map[string]any{
+        "summary":  "Laptop broken",
+        "code":     "ABC",
+        "amount":   float64(42),
+        "category": "hardware",
+        "tags":     []any{"a", "c"},
+        "due":      "2024-05-01",
+        "contact":  "ops@example.com",
+        "agree":    true,
+        "extra":    "ignored",
+    })
+    if err != nil {
+        t.Fatalf("expected valid data, got %v", err)
+    }
+}
+
+func TestSchemaReportsEveryInvalidField(t *testing.T) {
+    err := testSchema(t).Validate(map[string]any{
+        "code":     "abc",
+        "amount":   float64(101),
+        "category": "furniture",
+        "tags":     []any{"a", "b", "c"},
+        "due":      "tomorrow",
+        "contact":  "not-an-email",
+        "agree":    false,
+    })
+
+    var invalid *ValidationError
+    if !errors.As(err, &invalid) {
+        t.Fatalf("expected ValidationError, got %v", err)
+    }
+    got := map[string]bool{}
+    for _, field := range invalid.Fields {
+        got[field.Field] = true
+    }
+    for _, name := range []string{"summary", "code", "amount", "category", "tags", "due", "contact", "agree"} {
+        if !got[name] {
+            t.Errorf("expected an error for %s, got %+v", name, invalid.Fields)
+        }
+    }
+}
+
+func TestParseSchemaRejectsMalformedDefinitions(t *testing.T) {
+    _, err := ParseSchema(map[string]any{
+        "fields": []any{
+            map[string]any{"name": "a", "type": "text", "pattern": "("},
+            map[string]any{"name": "a", "type": "select"},
+            map[string]any{"name": "b", "type": "slider"},
+            map[string]any{"type": "number", "min": 5, "max": 1},
+        },
+    })
+
+    var invalid *ValidationError
+    if !errors.As(err, &invalid) {
+        t.Fatalf("expected ValidationError, got %v", err)
+    }
+    if len(invalid.Fields) != 6 {
+        t.Fatalf("expected 6 problems, got %+v", invalid.Fields)
+    }
+}
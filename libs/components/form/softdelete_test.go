@@ -0,0 +1,165 @@
+package form
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "errors"
+    "net/http"
+    "net/http/httptest"
+    "testing"
+    "time"
+
+    "github.com/go-chi/chi/v5"
+    "gorm.io/gorm"
+
+    "github.com/pflow/shared/tenant"
+)
+
+// openTickets reports a fixed number of open tickets for every form.
+type openTickets int64
+
+func (n openTickets) OpenTickets(ctx context.Context, formID string) (int64, error) {
+    return int64(n), nil
+}
+
+// failingReferences cannot count the tickets of any form.
+type failingReferences struct{}
+
+func (failingReferences) OpenTickets(ctx context.Context, formID string) (int64, error) {
+    return 0, errors.New("ticket service unavailable")
+}
+
+func TestDeletedFormsCanBeRestoredUntilPurged(t *testing.T) {
+    db := newTestDB(t)
+    repo := NewGormRepository(db)
+    router := chi.NewRouter()
+    NewHandler(repo, WithReferences(openTickets(2), false)).Mount(router, "")
+
+    serve := func(method, path string, body any) (int, map[string]any) {
+        var payload bytes.Buffer
+        if body != nil {
+            json.NewEncoder(&payload).Encode(body)
+        }
+        rec := httptest.NewRecorder()
+        router.ServeHTTP(rec, httptest.NewRequest(method, path, &payload))
+        var decoded map[string]any
+        json.Unmarshal(rec.Body.Bytes(), &decoded)
+        return rec.Code, decoded
+    }
+
+    code, created := serve(http.MethodPost, "/forms", map[string]any{"name": "Laptop request", "schema": map[string]any{"fields": []any{}}})
+    if code != http.StatusCreated {
+        t.Fatalf("create: %d %v", code, created)
+    }
+    id := created["data"].(map[string]any)["id"].(string)
+
+    if code, body := serve(http.MethodDelete, "/forms/"+id, nil); code != http.StatusConflict {
+        t.Fatalf("expected a form with open tickets to be kept, got %d %v", code, body)
+    }
+    if code, _ := serve(http.MethodDelete, "/forms/"+id+"?force=true", nil); code != http.StatusNoContent {
+        t.Fatalf("forced delete: %d", code)
+    }
+    if code, _ := serve(http.MethodGet, "/forms/"+id, nil); code != http.StatusNotFound {
+        t.Fatalf("expected a deleted form to miss, got %d", code)
+    }
+    if _, listed := serve(http.MethodGet, "/forms", nil); listed["total"] != float64(0) {
+        t.Fatalf("expected deleted forms to be hidden, got %v", listed)
+    }
+    _, listed := serve(http.MethodGet, "/forms?includeDeleted=true", nil)
+    items, _ := listed["data"].([]any)
+    if len(items) != 1 || items[0].(map[string]any)["deletedAt"] == nil {
+        t.Fatalf("expected includeDeleted to list the deleted form, got %v", listed)
+    }
+
+    if code, restored := serve(http.MethodPost, "/forms/"+id+"/restore", nil); code != http.StatusOK || restored["data"].(map[string]any)["deletedAt"] != nil {
+        t.Fatalf("restore: %d %v", code, restored)
+    }
+    if code, _ := serve(http.MethodPost, "/forms/"+id+"/restore", nil); code != http.StatusConflict {
+        t.Fatalf("expected restoring a live form to conflict, got %d", code)
+    }
+    if code, _ := serve(http.MethodGet, "/forms/"+id+"/versions/1", nil); code != http.StatusOK {
+        t.Fatalf("expected the restored form to keep its versions, got %d", code)
+    }
+
+    if err := repo.Delete(context.Background(), id); err != nil {
+        t.Fatalf("delete: %v", err)
+    }
+    if code, _ := serve(http.MethodGet, "/forms/"+id+"/versions/1", nil); code != http.StatusOK {
+        t.Fatalf("expected tickets to still resolve the versions of a deleted form, got %d", code)
+    }
+    if purged, err := repo.PurgeDeleted(context.Background(), time.Now().Add(-time.Hour), 10); err != nil || purged != 0 {
+        t.Fatalf("expected a recently deleted form to be kept, purged %d (%v)", purged, err)
+    }
+    if purged, err := repo.PurgeDeleted(context.Background(), time.Now().Add(time.Minute), 10); err != nil || purged != 1 {
+        t.Fatalf("expected the form to be purged, purged %d (%v)", purged, err)
+    }
+    if code, _ := serve(http.MethodGet, "/forms/"+id+"/versions/1", nil); code != http.StatusOK {
+        t.Fatalf("expected purging to keep the versions tickets were submitted with, got %d", code)
+    }
+    if _, err := repo.FindVersion(tenant.WithID(context.Background(), "acme"), id, 1); !IsNotFound(err) {
+        t.Fatalf("expected the kept versions to stay in their tenant, got %v", err)
+    }
+    if code, _ := serve(http.MethodPost, "/forms/"+id+"/restore", nil); code != http.StatusNotFound {
+        t.Fatalf("expected a purged form to be gone, got %d", code)
+    }
+}
+
+func TestDeleteFollowsTheReferencesFailurePolicy(t *testing.T) {
+    for _, tc := range []struct {
+        allowOnError bool
+        want         int
+    }{
+        {allowOnError: false, want: http.StatusBadGateway},
+        {allowOnError: true, want: http.StatusNoContent},
+    } {
+        repo := NewGormRepository(newTestDB(t))
+        router := chi.NewRouter()
+        NewHandler(repo, WithReferences(failingReferences{}, tc.allowOnError)).Mount(router, "")
+
+        form := &Form{Name: "Laptop request", Schema: map[string]any{"fields": []any{}}}
+        if err := repo.Create(context.Background(), form); err != nil {
+            t.Fatalf("create: %v", err)
+        }
+        rec := httptest.NewRecorder()
+        router.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/forms/"+form.ID, nil))
+        if rec.Code != tc.want {
+            t.Fatalf("allowOnError=%v: expected %d, got %d %s", tc.allowOnError, tc.want, rec.Code, rec.Body.String())
+        }
+    }
+}
+
+func TestPurgeKeepsFormsRestoredWhilePurging(t *testing.T) {
+    db := newTestDB(t)
+    repo := NewGormRepository(db)
+    ctx := context.Background()
+    form := &Form{Name: "Laptop request", Schema: map[string]any{"fields": []any{}}}
+    if err := repo.Create(ctx, form); err != nil {
+        t.Fatalf("create: %v", err)
+    }
+    if err := repo.Delete(ctx, form.ID); err != nil {
+        t.Fatalf("delete: %v", err)
+    }
+
+    // Restore the form right after the purge has read its candidates.
+    restored := false
+    db.Callback().Query().After("gorm:query").Register("test:restore", func(tx *gorm.DB) {
+        if restored || tx.Statement.Table != "forms" {
+            return
+        }
+        restored = true
+        if err := tx.Session(&gorm.Session{NewDB: true}).Unscoped().Model(&Form{}).Where("id = ?", form.ID).Update("deleted_at", nil).Error; err != nil {
+            t.Errorf("restore: %v", err)
+        }
+    })
+
+    if purged, err := repo.PurgeDeleted(ctx, time.Now().Add(time.Minute), 10); err != nil || purged != 0 {
+        t.Fatalf("expected the restored form to be kept, purged %d (%v)", purged, err)
+    }
+    if !restored {
+        t.Fatal("expected the purge to read its candidates")
+    }
+    if _, err := repo.Find(ctx, form.ID); err != nil {
+        t.Fatalf("expected the restored form to survive the purge: %v", err)
+    }
+}
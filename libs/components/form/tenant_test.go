@@ -0,0 +1,81 @@
+package form
+
+import (
+    "context"
+    "testing"
+
+    "gorm.io/gorm"
+
+    "github.com/pflow/components/internal/dbtest"
+    "github.com/pflow/shared/httpx"
+    "github.com/pflow/shared/tenant"
+)
+
+func newTestDB(t *testing.T) *gorm.DB {
+    t.Helper()
+    return dbtest.Open(t, func(db *gorm.DB) error {
+        return Migrate(db)
+    })
+}
+
+func TestFormsAreInvisibleToOtherTenants(t *testing.T) {
+    repo := NewGormRepository(newTestDB(t))
+    acme := tenant.WithID(context.Background(), "acme")
+    globex := tenant.WithID(context.Background(), "globex")
+
+    form := &Form{Name: "Laptop request", Schema: map[string]any{"fields": []any{}}}
+    if err := repo.Create(acme, form); err != nil {
+        t.Fatalf("create: %v", err)
+    }
+
+    if _, err := repo.Find(globex, form.ID); !IsNotFound(err) {
+        t.Fatalf("expected find from another tenant to miss, got %v", err)
+    }
+    if _, err := repo.Update(globex, form.ID, map[string]any{"name": "hijacked"}); !IsNotFound(err) {
+        t.Fatalf("expected update from another tenant to miss, got %v", err)
+    }
+    if _, err := repo.Versions(globex, form.ID); !IsNotFound(err) {
+        t.Fatalf("expected versions from another tenant to miss, got %v", err)
+    }
+    if _, err := repo.FindVersion(globex, form.ID, 1); !IsNotFound(err) {
+        t.Fatalf("expected version from another tenant to miss, got %v", err)
+    }
+    if err := repo.Delete(globex, form.ID); !IsNotFound(err) {
+        t.Fatalf("expected delete from another tenant to miss, got %v", err)
+    }
+
+    items, page, err := repo.List(globex, httpx.ListQuery{Limit: 10, SortColumn: "created_at"})
+    if err != nil || len(items) != 0 || page.Total != 0 {
+        t.Fatalf("expected other tenant to list nothing, got %d items (total %d, err %v)", len(items), page.Total, err)
+    }
+
+    stored, err := repo.Find(acme, form.ID)
+    if err != nil {
+        t.Fatalf("find in owning tenant: %v", err)
+    }
+    if stored.Name != "Laptop request" || stored.TenantID != "acme" {
+        t.Fatalf("expected form to be untouched, got %+v", stored)
+    }
+}
+
+func TestMigrateMovesVersionsToTheTenantOfTheirForm(t *testing.T) {
+    db := newTestDB(t)
+    repo := NewGormRepository(db)
+    acme := tenant.WithID(context.Background(), "acme")
+
+    form := &Form{Name: "Laptop request", Schema: map[string]any{"fields": []any{}}}
+    if err := repo.Create(acme, form); err != nil {
+        t.Fatalf("create: %v", err)
+    }
+    // Versions recorded before they carried a tenant read as the default one.
+    if err := db.Model(&FormVersion{}).Where("form_id = ?", form.ID).Update("tenant_id", tenant.Default).Error; err != nil {
+        t.Fatalf("reset tenant: %v", err)
+    }
+
+    if err := Migrate(db); err != nil {
+        t.Fatalf("migrate: %v", err)
+    }
+    if _, err := repo.FindVersion(acme, form.ID, 1); err != nil {
+        t.Fatalf("expected the version to move to the tenant of its form: %v", err)
+    }
+}
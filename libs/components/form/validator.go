@@ -0,0 +1,133 @@
+package form
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "net/http"
+    "net/url"
+    "strconv"
+    "strings"
+    "time"
+
+    "gorm.io/datatypes"
+
+    "github.com/pflow/shared/auth"
+    "github.com/pflow/shared/tenant"
+)
+
+// validatorPrincipal is the caller a remote Validator presents to the form
+// service.
+const validatorPrincipal = "system:form-validator"
+
+var (
+    // ErrFormNotFound is returned by a Validator when the referenced form does not exist.
+    ErrFormNotFound = errors.New("form not found")
+    // ErrFormVersionNotFound is returned by a Validator when the requested schema version does not exist.
+    ErrFormVersionNotFound = errors.New("form version not found")
+)
+
+// schemaSnapshot is the schema a Validator checks data against.
+type schemaSnapshot struct {
+    Version int               `json:"version"`
+    Schema  datatypes.JSONMap `json:"schema"`
+}
+
+// Validator checks submitted data against the schema of a stored form.
+type Validator struct {
+    load func(ctx context.Context, id string, version int) (*schemaSnapshot, error)
+}
+
+// NewValidator constructs a Validator that reads forms from the repository.
+func NewValidator(repo Repository) *Validator {
+    return &Validator{load: func(ctx context.Context, id string, version int) (*schemaSnapshot, error) {
+        if version <= 0 {
+            entity, err := repo.Find(ctx, id)
+            if IsNotFound(err) {
+                return nil, ErrFormNotFound
+            }
+            if err != nil {
+                return nil, err
+            }
+            return &schemaSnapshot{Version: entity.Version, Schema: entity.Schema}, nil
+        }
+
+        entity, err := repo.FindVersion(ctx, id, version)
+        if IsNotFound(err) {
+            return nil, ErrFormVersionNotFound
+        }
+        if err != nil {
+            return nil, err
+        }
+        return &schemaSnapshot{Version: entity.Version, Schema: entity.Schema}, nil
+    }}
+}
+
+// NewRemoteValidator constructs a Validator that fetches forms from the form service,
+// for services that do not share the form database. Forms are looked up in the
+// tenant of the request context and, when secret is set, requests are signed as a
+// principal allowed to view forms.
+func NewRemoteValidator(baseURL string, client *http.Client, secret []byte) *Validator {
+    base := strings.TrimRight(strings.TrimSpace(baseURL), "/")
+    if client == nil {
+        client = &http.Client{Timeout: 5 * time.Second}
+    }
+
+    return &Validator{load: func(ctx context.Context, id string, version int) (*schemaSnapshot, error) {
+        endpoint := base + "/forms/" + url.PathEscape(id)
+        missing := ErrFormNotFound
+        if version > 0 {
+            endpoint += "/versions/" + strconv.Itoa(version)
+            missing = ErrFormVersionNotFound
+        }
+
+        req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+        if err != nil {
+            return nil, err
+        }
+        req.Header.Set(tenant.Header, tenant.ID(ctx))
+        auth.Forward(req.Header, auth.Principal{
+            UserID:      validatorPrincipal,
+            Permissions: []string{auth.PermissionFormView},
+            TenantID:    tenant.ID(ctx),
+        }, secret)
+        resp, err := client.Do(req)
+        if err != nil {
+            return nil, fmt.Errorf("fetch form %s: %w", id, err)
+        }
+        defer resp.Body.Close()
+
+        switch {
+        case resp.StatusCode == http.StatusNotFound:
+            return nil, missing
+        case resp.StatusCode != http.StatusOK:
+            return nil, fmt.Errorf("fetch form %s: unexpected status %d", id, resp.StatusCode)
+        }
+
+        var envelope struct {
+            Data schemaSnapshot `json:"data"`
+        }
+        if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+            return nil, fmt.Errorf("decode form %s: %w", id, err)
+        }
+        return &envelope.Data, nil
+    }}
+}
+
+// Validate loads the requested schema version of a form, or its current schema when
+// version is zero, and validates data against it. It returns the version the data was
+// checked against, ErrFormNotFound or ErrFormVersionNotFound for unknown references and
+// a *ValidationError when data does not match.
+func (v *Validator) Validate(ctx context.Context, formID string, version int, data map[string]any) (int, error) {
+    snapshot, err := v.load(ctx, formID, version)
+    if err != nil {
+        return 0, err
+    }
+
+    schema, err := ParseSchema(snapshot.Schema)
+    if err != nil {
+        return 0, fmt.Errorf("form %s has an invalid schema: %v", formID, err)
+    }
+    return snapshot.Version, schema.Validate(data)
+}
@@ -0,0 +1,42 @@
+package form
+
+import (
+    "context"
+    "errors"
+    "net/http/httptest"
+    "testing"
+
+    "github.com/go-chi/chi/v5"
+
+    "github.com/pflow/shared/auth"
+    "github.com/pflow/shared/tenant"
+)
+
+func TestRemoteValidatorSignsFormLookups(t *testing.T) {
+    repo := NewGormRepository(newTestDB(t))
+    acme := tenant.WithID(context.Background(), "acme")
+    form := &Form{Name: "Laptop request", Schema: map[string]any{"fields": []any{
+        map[string]any{"name": "model", "type": "text", "required": true},
+    }}}
+    if err := repo.Create(acme, form); err != nil {
+        t.Fatalf("create: %v", err)
+    }
+
+    secret := []byte("forward secret")
+    router := chi.NewRouter()
+    router.Use(auth.Middleware(nil, auth.AcceptForwarded(secret, 0)), tenant.Middleware)
+    NewHandler(repo, WithAuthorization()).Mount(router, "")
+    server := httptest.NewServer(router)
+    defer server.Close()
+
+    version, err := NewRemoteValidator(server.URL, nil, secret).Validate(acme, form.ID, 0, map[string]any{"model": "X1"})
+    if err != nil || version != 1 {
+        t.Fatalf("expected the signed lookup to validate against version 1, got %d (%v)", version, err)
+    }
+    if _, err := NewRemoteValidator(server.URL, nil, secret).Validate(acme, form.ID, 0, map[string]any{}); !errors.As(err, new(*ValidationError)) {
+        t.Fatalf("expected a validation error for missing data, got %v", err)
+    }
+    if _, err := NewRemoteValidator(server.URL, nil, []byte("wrong")).Validate(acme, form.ID, 0, map[string]any{"model": "X1"}); err == nil {
+        t.Fatal("expected a lookup signed with the wrong secret to be rejected")
+    }
+}
@@ -0,0 +1,66 @@
+package identity
+
+import (
+    "net/http"
+    "testing"
+
+    "github.com/go-chi/chi/v5"
+
+    "github.com/pflow/shared/audit"
+    "github.com/pflow/shared/tenant"
+)
+
+func TestUserChangesAreAudited(t *testing.T) {
+    repo := newTenantTestRepository(t)
+    if err := audit.Migrate(repo.db); err != nil {
+        t.Fatalf("migrate audit: %v", err)
+    }
+    auditLog := audit.NewGormRepository(repo.db)
+
+    router := chi.NewRouter()
+    router.Use(tenant.Middleware, audit.Middleware)
+    NewHandler(repo, WithRoles(repo), WithAudit(audit.NewRecorder(auditLog, "identity"))).Mount(router, "")
+    audit.NewHandler(auditLog).Mount(router, "")
+    client := authTestClient{t: t, router: router}
+    headers := map[string]string{"X-User-ID": "u-admin", "X-Forwarded-For": "203.0.113.9"}
+
+    code, created := client.post("/users", map[string]any{"name": "Alice", "email": "alice@example.com"}, headers)
+    if code != http.StatusCreated {
+        t.Fatalf("create user: %d %v", code, created)
+    }
+    id := created["id"].(string)
+    if code, _ := client.do(http.MethodPut, "/users/"+id, map[string]any{"name": "Alice Liddell"}, headers); code != http.StatusOK {
+        t.Fatalf("update user: %d", code)
+    }
+    if code, _ := client.do(http.MethodDelete, "/users/"+id, nil, headers); code != http.StatusNoContent {
+        t.Fatalf("delete user: %d", code)
+    }
+
+    code, listed := client.do(http.MethodGet, "/audit?resource=user:"+id, nil, nil)
+    entries, _ := listed["data"].([]any)
+    if code != http.StatusOK || len(entries) != 3 {
+        t.Fatalf("expected three entries for the user, got %d %v", code, listed)
+    }
+    deleted, updated := entries[0].(map[string]any), entries[1].(map[string]any)
+    if deleted["action"] != EventUserDeleted || deleted["actor"] != "u-admin" || deleted["ip"] != "203.0.113.9" || deleted["service"] != "identity" {
+        t.Fatalf("unexpected delete entry %v", deleted)
+    }
+    changes := updated["changes"].(map[string]any)
+    name, _ := changes["name"].(map[string]any)
+    if updated["action"] != EventUserUpdated || name["before"] != "Alice" || name["after"] != "Alice Liddell" || changes["email"] != nil {
+        t.Fatalf("unexpected update entry %v", updated)
+    }
+    if code, listed := client.do(http.MethodGet, "/audit?resource=role", nil, nil); code != http.StatusOK || len(listed["data"].([]any)) != 0 {
+        t.Fatalf("expected no role entries, got %d %v", code, listed)
+    }
+
+    if code, verification := client.do(http.MethodGet, "/audit/verify", nil, nil); code != http.StatusOK || verification["valid"] != true || verification["entries"] != float64(3) {
+        t.Fatalf("expected an intact chain, got %d %v", code, verification)
+    }
+    if err := repo.db.Exec("UPDATE audit_entries SET actor = ? WHERE seq = ?", "u-other", 2).Error; err != nil {
+        t.Fatalf("tamper: %v", err)
+    }
+    if _, verification := client.do(http.MethodGet, "/audit/verify", nil, nil); verification["valid"] != false || verification["brokenAt"] != float64(2) {
+        t.Fatalf("expected the edited entry to break the chain, got %v", verification)
+    }
+}
@@ -0,0 +1,528 @@
+package identity
+
+import (
+    "context"
+    "crypto/rand"
+    "crypto/sha256"
+    "encoding/base64"
+    "encoding/hex"
+    "errors"
+    "fmt"
+    "log"
+    "net/http"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+
+    "github.com/go-chi/chi/v5"
+    "golang.org/x/crypto/bcrypt"
+
+    "github.com/pflow/shared/audit"
+    "github.com/pflow/shared/auth"
+    "github.com/pflow/shared/httpx"
+)
+
+const (
+    minPasswordLength = 8
+    // bcrypt silently ignores input beyond 72 bytes, so longer passwords are rejected.
+    maxPasswordBytes = 72
+
+    defaultMaxFailedLogins = 5
+    defaultLockoutDuration = 15 * time.Minute
+    defaultRefreshTokenTTL = 30 * 24 * time.Hour
+    defaultResetTokenTTL   = time.Hour
+)
+
+// Audit actions of password changes, which are recorded without a diff.
+const (
+    auditPasswordChanged = "user.password_changed"
+    auditPasswordReset   = "user.password_reset"
+)
+
+// authPublicRoutes are the auth routes reachable without an access token.
+var authPublicRoutes = []string{"/login", "/refresh", "/logout", "/password-reset"}
+
+var (
+    dummyHashOnce sync.Once
+    dummyHash     []byte
+)
+
+// PasswordResetNotifier delivers a password reset token to the user, for
+// example by email.
+type PasswordResetNotifier interface {
+    NotifyPasswordReset(ctx context.Context, user User, token string, expiresAt time.Time) error
+}
+
+// PasswordResetNotifierFunc adapts a function to PasswordResetNotifier.
+type PasswordResetNotifierFunc func(ctx context.Context, user User, token string, expiresAt time.Time) error
+
+// NotifyPasswordReset calls f.
+func (f PasswordResetNotifierFunc) NotifyPasswordReset(ctx context.Context, user User, token string, expiresAt time.Time) error {
+    return f(ctx, user, token, expiresAt)
+}
+
+// WithAuthentication enables the endpoints mounted by MountAuth: access tokens
+// are issued by signer and credentials are kept in store.
+func WithAuthentication(store CredentialStore, signer *auth.Signer) HandlerOption {
+    return func(h *Handler) {
+        h.credentials = store
+        h.signer = signer
+    }
+}
+
+// WithRefreshTokenTTL overrides how long refresh tokens stay valid (30 days by default).
+func WithRefreshTokenTTL(ttl time.Duration) HandlerOption {
+    return func(h *Handler) {
+        if ttl > 0 {
+            h.refreshTTL = ttl
+        }
+    }
+}
+
+// WithLockout locks an account for duration after maxAttempts consecutive
+// failed logins (5 attempts and 15 minutes by default).
+func WithLockout(maxAttempts int, duration time.Duration) HandlerOption {
+    return func(h *Handler) {
+        if maxAttempts > 0 && duration > 0 {
+            h.maxFailedLogins = maxAttempts
+            h.lockout = duration
+        }
+    }
+}
+
+// WithPasswordResetNotifier enables password reset requests, delivering the
+// reset tokens through notifier.
+func WithPasswordResetNotifier(notifier PasswordResetNotifier) HandlerOption {
+    return func(h *Handler) {
+        h.resetNotifier = notifier
+    }
+}
+
+// AuthPublicPaths lists the auth routes under basePath that must stay
+// reachable without credentials, for auth.PublicPaths.
+func AuthPublicPaths(basePath string) []string {
+    base := strings.TrimSuffix(authBasePath(basePath), "/")
+    paths := make([]string, 0, len(authPublicRoutes))
+    for _, route := range authPublicRoutes {
+        paths = append(paths, base+route)
+    }
+    return paths
+}
+
+func authBasePath(basePath string) string {
+    if path := strings.TrimSpace(basePath); path != "" {
+        return path
+    }
+    return "/auth"
+}
+
+// MountAuth registers the login, token and password routes under basePath
+// ("/auth" by default). It is a no-op unless WithAuthentication was supplied.
+func (h *Handler) MountAuth(router chi.Router, basePath string) {
+    if h.credentials == nil || h.signer == nil {
+        return
+    }
+
+    router.Route(authBasePath(basePath), func(r chi.Router) {
+        r.Post("/login", h.login)
+        r.Post("/refresh", h.refresh)
+        r.Post("/logout", h.logout)
+        r.Post("/password", h.changePassword)
+        r.Post("/password-reset", h.requestPasswordReset)
+        r.Post("/password-reset/confirm", h.confirmPasswordReset)
+    })
+}
+
+// EnsureUser creates user with password unless an account with the same email
+// already exists, so a fresh deployment can bootstrap its first administrator.
+// It reports whether the user was created.
+func EnsureUser(ctx context.Context, repo Repository, store CredentialStore, user User, password string) (bool, error) {
+    user.Email = strings.ToLower(strings.TrimSpace(user.Email))
+    if _, err := store.FindByEmail(ctx, user.Email); err == nil {
+        return false, nil
+    } else if !IsNotFound(err) {
+        return false, err
+    }
+
+    hash, err := HashPassword(password)
+    if err != nil {
+        return false, err
+    }
+    now := time.Now()
+    user.PasswordHash = hash
+    user.PasswordChangedAt = &now
+    if err := repo.Create(ctx, &user); err != nil {
+        return false, err
+    }
+    return true, nil
+}
+
+type loginRequest struct {
+    Email    string `json:"email"`
+    Password string `json:"password"`
+}
+
+type refreshTokenRequest struct {
+    RefreshToken string `json:"refreshToken"`
+}
+
+type changePasswordRequest struct {
+    CurrentPassword string `json:"currentPassword"`
+    NewPassword     string `json:"newPassword"`
+}
+
+type passwordResetRequest struct {
+    Email string `json:"email"`
+}
+
+type confirmPasswordResetRequest struct {
+    Token       string `json:"token"`
+    NewPassword string `json:"newPassword"`
+}
+
+func (h *Handler) login(w http.ResponseWriter, r *http.Request) {
+    var payload loginRequest
+    if err := decodeJSON(r, &payload); err != nil {
+        httpx.Error(w, http.StatusBadRequest, err.Error())
+        return
+    }
+    email := strings.ToLower(strings.TrimSpace(payload.Email))
+    if email == "" || payload.Password == "" {
+        httpx.Error(w, http.StatusBadRequest, "email and password are required")
+        return
+    }
+
+    user, err := h.credentials.FindByEmail(r.Context(), email)
+    if err != nil && !IsNotFound(err) {
+        httpx.Error(w, http.StatusInternalServerError, err.Error())
+        return
+    }
+    if user == nil {
+        // CheckPassword burns the same time as a real comparison, so response
+        // latency does not reveal which emails have accounts.
+        CheckPassword("", payload.Password)
+        httpx.Error(w, http.StatusUnauthorized, "invalid email or password")
+        return
+    }
+    if user.Locked(time.Now()) {
+        renderLocked(w, *user.LockedUntil)
+        return
+    }
+
+    if !CheckPassword(user.PasswordHash, payload.Password) {
+        updated, err := h.credentials.RecordFailedLogin(r.Context(), user.ID, h.maxFailedLogins, h.lockout)
+        if err != nil {
+            httpx.Error(w, http.StatusInternalServerError, err.Error())
+            return
+        }
+        if updated.Locked(time.Now()) {
+            renderLocked(w, *updated.LockedUntil)
+            return
+        }
+        httpx.Error(w, http.StatusUnauthorized, "invalid email or password")
+        return
+    }
+
+    if user.FailedLogins > 0 || user.LockedUntil != nil {
+        if err := h.credentials.ClearFailedLogins(r.Context(), user.ID); err != nil {
+            httpx.Error(w, http.StatusInternalServerError, err.Error())
+            return
+        }
+    }
+
+    raw, token, err := newRefreshToken(h.refreshTTL)
+    if err != nil {
+        httpx.Error(w, http.StatusInternalServerError, err.Error())
+        return
+    }
+    token.UserID = user.ID
+    if err := h.credentials.CreateRefreshToken(r.Context(), token); err != nil {
+        httpx.Error(w, http.StatusInternalServerError, err.Error())
+        return
+    }
+    h.renderSession(w, user, raw, token.ExpiresAt)
+}
+
+func (h *Handler) refresh(w http.ResponseWriter, r *http.Request) {
+    var payload refreshTokenRequest
+    if err := decodeJSON(r, &payload); err != nil {
+        httpx.Error(w, http.StatusBadRequest, err.Error())
+        return
+    }
+    if strings.TrimSpace(payload.RefreshToken) == "" {
+        httpx.Error(w, http.StatusBadRequest, "refreshToken is required")
+        return
+    }
+
+    raw, next, err := newRefreshToken(h.refreshTTL)
+    if err != nil {
+        httpx.Error(w, http.StatusInternalServerError, err.Error())
+        return
+    }
+    current, err := h.credentials.RotateRefreshToken(r.Context(), hashToken(payload.RefreshToken), next)
+    if err != nil {
+        if errors.Is(err, ErrRefreshTokenInvalid) || errors.Is(err, ErrRefreshTokenReused) {
+            httpx.Error(w, http.StatusUnauthorized, err.Error())
+            return
+        }
+        httpx.Error(w, http.StatusInternalServerError, err.Error())
+        return
+    }
+
+    user, err := h.repo.Find(r.Context(), current.UserID)
+    if err != nil {
+        if IsNotFound(err) {
+            httpx.Error(w, http.StatusUnauthorized, ErrRefreshTokenInvalid.Error())
+            return
+        }
+        httpx.Error(w, http.StatusInternalServerError, err.Error())
+        return
+    }
+    h.renderSession(w, user, raw, next.ExpiresAt)
+}
+
+func (h *Handler) logout(w http.ResponseWriter, r *http.Request) {
+    var payload refreshTokenRequest
+    if err := decodeJSON(r, &payload); err != nil {
+        httpx.Error(w, http.StatusBadRequest, err.Error())
+        return
+    }
+    if strings.TrimSpace(payload.RefreshToken) == "" {
+        httpx.Error(w, http.StatusBadRequest, "refreshToken is required")
+        return
+    }
+
+    if err := h.credentials.RevokeRefreshToken(r.Context(), hashToken(payload.RefreshToken)); err != nil {
+        httpx.Error(w, http.StatusInternalServerError, err.Error())
+        return
+    }
+    w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) changePassword(w http.ResponseWriter, r *http.Request) {
+    userID := ""
+    if principal, ok := auth.FromContext(r.Context()); ok {
+        userID = principal.UserID
+    } else {
+        userID = strings.TrimSpace(r.Header.Get(auth.HeaderActor))
+    }
+    if userID == "" {
+        httpx.Error(w, http.StatusUnauthorized, "authentication required")
+        return
+    }
+
+    var payload changePasswordRequest
+    if err := decodeJSON(r, &payload); err != nil {
+        httpx.Error(w, http.StatusBadRequest, err.Error())
+        return
+    }
+    if err := ValidatePassword(payload.NewPassword); err != nil {
+        httpx.Error(w, http.StatusBadRequest, err.Error())
+        return
+    }
+
+    user, err := h.repo.Find(r.Context(), userID)
+    if err != nil {
+        if IsNotFound(err) {
+            httpx.Error(w, http.StatusUnauthorized, "authentication required")
+            return
+        }
+        httpx.Error(w, http.StatusInternalServerError, err.Error())
+        return
+    }
+    if user.Locked(time.Now()) {
+        renderLocked(w, *user.LockedUntil)
+        return
+    }
+    if !CheckPassword(user.PasswordHash, payload.CurrentPassword) {
+        // Wrong current passwords count towards the lockout like failed logins.
+        if _, err := h.credentials.RecordFailedLogin(r.Context(), user.ID, h.maxFailedLogins, h.lockout); err != nil {
+            httpx.Error(w, http.StatusInternalServerError, err.Error())
+            return
+        }
+        httpx.Error(w, http.StatusForbidden, "current password is incorrect")
+        return
+    }
+
+    h.storePassword(w, r, auditPasswordChanged, user.ID, payload.NewPassword)
+}
+
+func (h *Handler) requestPasswordReset(w http.ResponseWriter, r *http.Request) {
+    if h.resetNotifier == nil {
+        httpx.Error(w, http.StatusNotImplemented, "password reset delivery is not configured")
+        return
+    }
+
+    var payload passwordResetRequest
+    if err := decodeJSON(r, &payload); err != nil {
+        httpx.Error(w, http.StatusBadRequest, err.Error())
+        return
+    }
+    email := strings.ToLower(strings.TrimSpace(payload.Email))
+    if email == "" {
+        httpx.Error(w, http.StatusBadRequest, "email is required")
+        return
+    }
+
+    // The response is the same whether or not the account exists.
+    user, err := h.credentials.FindByEmail(r.Context(), email)
+    if err != nil {
+        if !IsNotFound(err) {
+            httpx.Error(w, http.StatusInternalServerError, err.Error())
+            return
+        }
+        w.WriteHeader(http.StatusAccepted)
+        return
+    }
+
+    raw, err := randomToken()
+    if err != nil {
+        httpx.Error(w, http.StatusInternalServerError, err.Error())
+        return
+    }
+    reset := &PasswordReset{UserID: user.ID, TokenHash: hashToken(raw), ExpiresAt: time.Now().Add(defaultResetTokenTTL)}
+    if err := h.credentials.CreatePasswordReset(r.Context(), reset); err != nil {
+        httpx.Error(w, http.StatusInternalServerError, err.Error())
+        return
+    }
+    if err := h.resetNotifier.NotifyPasswordReset(r.Context(), *user, raw, reset.ExpiresAt); err != nil {
+        log.Printf("identity: failed to deliver password reset for user %s: %v", user.ID, err)
+        httpx.Error(w, http.StatusInternalServerError, "failed to deliver password reset")
+        return
+    }
+    w.WriteHeader(http.StatusAccepted)
+}
+
+func (h *Handler) confirmPasswordReset(w http.ResponseWriter, r *http.Request) {
+    var payload confirmPasswordResetRequest
+    if err := decodeJSON(r, &payload); err != nil {
+        httpx.Error(w, http.StatusBadRequest, err.Error())
+        return
+    }
+    if strings.TrimSpace(payload.Token) == "" {
+        httpx.Error(w, http.StatusBadRequest, "token is required")
+        return
+    }
+    if err := ValidatePassword(payload.NewPassword); err != nil {
+        httpx.Error(w, http.StatusBadRequest, err.Error())
+        return
+    }
+
+    reset, err := h.credentials.ConsumePasswordReset(r.Context(), hashToken(payload.Token))
+    if err != nil {
+        if errors.Is(err, ErrResetTokenInvalid) {
+            httpx.Error(w, http.StatusBadRequest, err.Error())
+            return
+        }
+        httpx.Error(w, http.StatusInternalServerError, err.Error())
+        return
+    }
+
+    h.storePassword(w, r, auditPasswordReset, reset.UserID, payload.NewPassword)
+}
+
+// storePassword hashes and saves a validated password, signing the user out
+// of every session, and records action in the audit log.
+func (h *Handler) storePassword(w http.ResponseWriter, r *http.Request, action, userID, password string) {
+    hash, err := HashPassword(password)
+    if err != nil {
+        httpx.Error(w, http.StatusInternalServerError, err.Error())
+        return
+    }
+    if err := h.credentials.SetPassword(r.Context(), userID, hash); err != nil {
+        if IsNotFound(err) {
+            httpx.Error(w, http.StatusNotFound, "user not found")
+            return
+        }
+        httpx.Error(w, http.StatusInternalServerError, err.Error())
+        return
+    }
+    h.audit.Record(r.Context(), audit.Change{Action: action, ResourceID: userID})
+    w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) renderSession(w http.ResponseWriter, user *User, refreshToken string, refreshExpiresAt time.Time) {
+    accessToken, expiresAt, err := h.signer.Sign(user.Principal())
+    if err != nil {
+        httpx.Error(w, http.StatusInternalServerError, err.Error())
+        return
+    }
+
+    httpx.JSON(w, http.StatusOK, map[string]any{"data": map[string]any{
+        "tokenType":        "Bearer",
+        "accessToken":      accessToken,
+        "expiresAt":        expiresAt,
+        "refreshToken":     refreshToken,
+        "refreshExpiresAt": refreshExpiresAt,
+        "user":             user.ToDTO(),
+    }})
+}
+
+func renderLocked(w http.ResponseWriter, until time.Time) {
+    seconds := int(time.Until(until).Seconds()) + 1
+    w.Header().Set("Retry-After", strconv.Itoa(seconds))
+    httpx.Error(w, http.StatusLocked, "account is temporarily locked after repeated failed logins")
+}
+
+// ValidatePassword enforces the password policy.
+func ValidatePassword(password string) error {
+    if len([]rune(password)) < minPasswordLength {
+        return fmt.Errorf("password must be at least %d characters", minPasswordLength)
+    }
+    if len(password) > maxPasswordBytes {
+        return fmt.Errorf("password must be at most %d bytes", maxPasswordBytes)
+    }
+    return nil
+}
+
+// HashPassword validates password and returns its bcrypt hash.
+func HashPassword(password string) (string, error) {
+    if err := ValidatePassword(password); err != nil {
+        return "", err
+    }
+    hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+    if err != nil {
+        return "", err
+    }
+    return string(hash), nil
+}
+
+// CheckPassword reports whether password matches hash. An empty hash never
+// matches but still costs a full comparison.
+func CheckPassword(hash, password string) bool {
+    if hash == "" {
+        bcrypt.CompareHashAndPassword(timingHash(), []byte(password))
+        return false
+    }
+    return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+func timingHash() []byte {
+    dummyHashOnce.Do(func() {
+        dummyHash, _ = bcrypt.GenerateFromPassword([]byte("pflow-timing-equaliser"), bcrypt.DefaultCost)
+    })
+    return dummyHash
+}
+
+func newRefreshToken(ttl time.Duration) (string, *RefreshToken, error) {
+    raw, err := randomToken()
+    if err != nil {
+        return "", nil, err
+    }
+    return raw, &RefreshToken{TokenHash: hashToken(raw), ExpiresAt: time.Now().Add(ttl)}, nil
+}
+
+func randomToken() (string, error) {
+    buf := make([]byte, 32)
+    if _, err := rand.Read(buf); err != nil {
+        return "", err
+    }
+    return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// hashToken is how refresh and reset tokens are looked up without storing them.
+func hashToken(raw string) string {
+    sum := sha256.Sum256([]byte(strings.TrimSpace(raw)))
+    return hex.EncodeToString(sum[:])
+}
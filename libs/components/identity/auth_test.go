@@ -0,0 +1,526 @@
+package identity
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "net/http/httptest"
+    "sync"
+    "testing"
+    "time"
+
+    "github.com/go-chi/chi/v5"
+    "gorm.io/gorm"
+
+    "github.com/pflow/shared/auth"
+    "github.com/pflow/shared/httpx"
+)
+
+// memoryStore is an in-memory Repository, CredentialStore and RoleRepository
+// mirroring the semantics of GormRepository.
+type memoryStore struct {
+    mu      sync.Mutex
+    users   map[string]*User
+    tokens  map[string]*RefreshToken
+    resets  map[string]*PasswordReset
+    roles   map[string]*Role
+    counter int
+}
+
+func newMemoryStore() *memoryStore {
+    store := &memoryStore{users: map[string]*User{}, tokens: map[string]*RefreshToken{}, resets: map[string]*PasswordReset{}, roles: map[string]*Role{}}
+    store.roles["role-admin"] = &Role{ID: "role-admin", Name: AdminRole, System: true, Permissions: []Permission{{Key: auth.PermissionAll}}}
+    return store
+}
+
+func (s *memoryStore) nextID() string {
+    s.counter++
+    return fmt.Sprintf("id-%d", s.counter)
+}
+
+func (s *memoryStore) List(ctx context.Context, query httpx.ListQuery) ([]User, httpx.Page, error) {
+    return nil, httpx.Page{}, nil
+}
+
+func (s *memoryStore) Create(ctx context.Context, entity *User) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    entity.ID = s.nextID()
+    clone := *entity
+    s.users[entity.ID] = &clone
+    return nil
+}
+
+func (s *memoryStore) Find(ctx context.Context, id string) (*User, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    user, ok := s.users[id]
+    if !ok {
+        return nil, gorm.ErrRecordNotFound
+    }
+    return s.load(user), nil
+}
+
+// load copies a user with its roles as currently stored, the way the GORM
+// repository preloads them. Callers hold s.mu.
+func (s *memoryStore) load(user *User) *User {
+    clone := *user
+    clone.Roles = make([]Role, 0, len(user.Roles))
+    for _, role := range user.Roles {
+        if current, ok := s.roles[role.ID]; ok {
+            clone.Roles = append(clone.Roles, *current)
+        }
+    }
+    return &clone
+}
+
+func (s *memoryStore) Update(ctx context.Context, id string, updates map[string]any) (*User, error) {
+    return s.Find(ctx, id)
+}
+
+func (s *memoryStore) Delete(ctx context.Context, id string) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    delete(s.users, id)
+    return nil
+}
+
+func (s *memoryStore) Restore(ctx context.Context, id string) (*User, error) {
+    return nil, gorm.ErrRecordNotFound
+}
+
+func (s *memoryStore) SetRoles(ctx context.Context, id string, roles []Role) (*User, error) {
+    s.mu.Lock()
+    user, ok := s.users[id]
+    if ok {
+        user.Roles = roles
+    }
+    s.mu.Unlock()
+    if !ok {
+        return nil, gorm.ErrRecordNotFound
+    }
+    return s.Find(ctx, id)
+}
+
+func (s *memoryStore) ListRoles(ctx context.Context) ([]Role, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    roles := make([]Role, 0, len(s.roles))
+    for _, role := range s.roles {
+        roles = append(roles, *role)
+    }
+    return roles, nil
+}
+
+func (s *memoryStore) FindRole(ctx context.Context, id string) (*Role, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    role, ok := s.roles[id]
+    if !ok {
+        return nil, gorm.ErrRecordNotFound
+    }
+    clone := *role
+    return &clone, nil
+}
+
+func (s *memoryStore) FindRolesByName(ctx context.Context, names []string) ([]Role, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    roles := []Role{}
+    for _, name := range names {
+        found := false
+        for _, role := range s.roles {
+            if role.Name == name {
+                roles = append(roles, *role)
+                found = true
+            }
+        }
+        if !found {
+            return nil, fmt.Errorf("%w %q", ErrUnknownRole, name)
+        }
+    }
+    return roles, nil
+}
+
+func (s *memoryStore) CreateRole(ctx context.Context, role *Role, permissions []string) error {
+    resolved, err := catalogPermissions(permissions)
+    if err != nil {
+        return err
+    }
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    role.ID = s.nextID()
+    role.Permissions = resolved
+    clone := *role
+    s.roles[role.ID] = &clone
+    return nil
+}
+
+func (s *memoryStore) UpdateRole(ctx context.Context, id string, updates map[string]any, permissions []string) (*Role, error) {
+    s.mu.Lock()
+    role, ok := s.roles[id]
+    if !ok {
+        s.mu.Unlock()
+        return nil, gorm.ErrRecordNotFound
+    }
+    if role.System {
+        s.mu.Unlock()
+        return nil, ErrSystemRole
+    }
+    if name, ok := updates["name"].(string); ok {
+        role.Name = name
+    }
+    if permissions != nil {
+        resolved, err := catalogPermissions(permissions)
+        if err != nil {
+            s.mu.Unlock()
+            return nil, err
+        }
+        role.Permissions = resolved
+    }
+    s.mu.Unlock()
+    return s.FindRole(ctx, id)
+}
+
+func (s *memoryStore) DeleteRole(ctx context.Context, id string) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    role, ok := s.roles[id]
+    if !ok {
+        return gorm.ErrRecordNotFound
+    }
+    if role.System {
+        return ErrSystemRole
+    }
+    delete(s.roles, id)
+    return nil
+}
+
+func (s *memoryStore) ListPermissions(ctx context.Context) ([]Permission, error) {
+    return permissionCatalog(), nil
+}
+
+func catalogPermissions(keys []string) ([]Permission, error) {
+    catalog := map[string]Permission{}
+    for _, permission := range permissionCatalog() {
+        catalog[permission.Key] = permission
+    }
+    permissions := []Permission{}
+    for _, key := range keys {
+        permission, ok := catalog[key]
+        if !ok {
+            return nil, fmt.Errorf("%w %q", ErrUnknownPermission, key)
+        }
+        permissions = append(permissions, permission)
+    }
+    return permissions, nil
+}
+
+func (s *memoryStore) FindByEmail(ctx context.Context, email string) (*User, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    for _, user := range s.users {
+        if user.Email == email {
+            return s.load(user), nil
+        }
+    }
+    return nil, gorm.ErrRecordNotFound
+}
+
+func (s *memoryStore) RecordFailedLogin(ctx context.Context, id string, maxAttempts int, lockout time.Duration) (*User, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    user := s.users[id]
+    user.FailedLogins++
+    if user.FailedLogins >= maxAttempts {
+        until := time.Now().Add(lockout)
+        user.FailedLogins = 0
+        user.LockedUntil = &until
+    }
+    clone := *user
+    return &clone, nil
+}
+
+func (s *memoryStore) ClearFailedLogins(ctx context.Context, id string) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    s.users[id].FailedLogins = 0
+    s.users[id].LockedUntil = nil
+    return nil
+}
+
+func (s *memoryStore) SetPassword(ctx context.Context, id, hash string) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    user, ok := s.users[id]
+    if !ok {
+        return gorm.ErrRecordNotFound
+    }
+    now := time.Now()
+    user.PasswordHash = hash
+    user.PasswordChangedAt = &now
+    user.FailedLogins = 0
+    user.LockedUntil = nil
+    for _, token := range s.tokens {
+        if token.UserID == id && token.RevokedAt == nil {
+            token.RevokedAt = &now
+        }
+    }
+    return nil
+}
+
+func (s *memoryStore) CreateRefreshToken(ctx context.Context, token *RefreshToken) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    token.ID = s.nextID()
+    if token.FamilyID == "" {
+        token.FamilyID = token.ID
+    }
+    s.tokens[token.TokenHash] = token
+    return nil
+}
+
+func (s *memoryStore) RotateRefreshToken(ctx context.Context, tokenHash string, next *RefreshToken) (*RefreshToken, error) {
+    s.mu.Lock()
+    current, ok := s.tokens[tokenHash]
+    if !ok || time.Now().After(current.ExpiresAt) {
+        s.mu.Unlock()
+        return nil, ErrRefreshTokenInvalid
+    }
+    if current.RevokedAt != nil {
+        s.mu.Unlock()
+        s.revokeFamily(current.FamilyID)
+        return nil, ErrRefreshTokenReused
+    }
+    now := time.Now()
+    current.RevokedAt = &now
+    next.UserID = current.UserID
+    next.FamilyID = current.FamilyID
+    s.mu.Unlock()
+    return current, s.CreateRefreshToken(ctx, next)
+}
+
+func (s *memoryStore) RevokeRefreshToken(ctx context.Context, tokenHash string) error {
+    s.mu.Lock()
+    token, ok := s.tokens[tokenHash]
+    s.mu.Unlock()
+    if ok {
+        s.revokeFamily(token.FamilyID)
+    }
+    return nil
+}
+
+func (s *memoryStore) revokeFamily(familyID string) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    now := time.Now()
+    for _, token := range s.tokens {
+        if token.FamilyID == familyID && token.RevokedAt == nil {
+            token.RevokedAt = &now
+        }
+    }
+}
+
+func (s *memoryStore) CreatePasswordReset(ctx context.Context, reset *PasswordReset) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    s.resets[reset.TokenHash] = reset
+    return nil
+}
+
+func (s *memoryStore) ConsumePasswordReset(ctx context.Context, tokenHash string) (*PasswordReset, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    reset, ok := s.resets[tokenHash]
+    if !ok || reset.UsedAt != nil || time.Now().After(reset.ExpiresAt) {
+        return nil, ErrResetTokenInvalid
+    }
+    now := time.Now()
+    reset.UsedAt = &now
+    return reset, nil
+}
+
+type authTestClient struct {
+    t      *testing.T
+    router chi.Router
+}
+
+func (c authTestClient) post(path string, body any, headers map[string]string) (int, map[string]any) {
+    c.t.Helper()
+    return c.do(http.MethodPost, path, body, headers)
+}
+
+func (c authTestClient) do(method, path string, body any, headers map[string]string) (int, map[string]any) {
+    c.t.Helper()
+    payload, err := json.Marshal(body)
+    if err != nil {
+        c.t.Fatalf("encode: %v", err)
+    }
+    req := httptest.NewRequest(method, path, bytes.NewReader(payload))
+    for key, value := range headers {
+        req.Header.Set(key, value)
+    }
+    recorder := httptest.NewRecorder()
+    c.router.ServeHTTP(recorder, req)
+
+    var decoded map[string]any
+    if recorder.Body.Len() > 0 {
+        if err := json.Unmarshal(recorder.Body.Bytes(), &decoded); err != nil {
+            c.t.Fatalf("decode %s: %v", path, err)
+        }
+    }
+    if data, ok := decoded["data"].(map[string]any); ok {
+        return recorder.Code, data
+    }
+    return recorder.Code, decoded
+}
+
+func newAuthTestClient(t *testing.T, opts ...HandlerOption) (authTestClient, *auth.Verifier) {
+    t.Helper()
+    secret := []byte("identity-test-secret")
+    signer, err := auth.NewSigner(secret, auth.SignerOptions{TTL: time.Minute})
+    if err != nil {
+        t.Fatalf("signer: %v", err)
+    }
+    verifier, err := auth.NewVerifier(context.Background(), auth.Options{HMACSecret: secret})
+    if err != nil {
+        t.Fatalf("verifier: %v", err)
+    }
+
+    store := newMemoryStore()
+    handler := NewHandler(store, append([]HandlerOption{WithAuthentication(store, signer), WithRoles(store), WithLockout(3, time.Minute)}, opts...)...)
+    router := chi.NewRouter()
+    handler.Mount(router, "")
+    handler.MountAuth(router, "")
+    handler.MountRoles(router, "")
+    return authTestClient{t: t, router: router}, verifier
+}
+
+func TestLoginIssuesTokensAndRotatesRefreshTokens(t *testing.T) {
+    client, verifier := newAuthTestClient(t)
+
+    code, created := client.post("/users", map[string]any{"name": "Ada", "email": "ada@example.com", "roles": []string{AdminRole}, "password": "correct horse"}, nil)
+    if code != http.StatusCreated || created["hasPassword"] != true {
+        t.Fatalf("create user: %d %v", code, created)
+    }
+    if code, _ := client.post("/users", map[string]any{"name": "Bob", "email": "bob@example.com", "password": "short"}, nil); code != http.StatusBadRequest {
+        t.Fatalf("expected weak password to be rejected, got %d", code)
+    }
+
+    code, session := client.post("/auth/login", map[string]any{"email": "ADA@example.com", "password": "correct horse"}, nil)
+    if code != http.StatusOK {
+        t.Fatalf("login: %d %v", code, session)
+    }
+    principal, err := verifier.Verify(context.Background(), session["accessToken"].(string))
+    if err != nil || principal.UserID != created["id"] || !principal.HasRole(AdminRole) || !principal.Can(auth.PermissionUserManage) {
+        t.Fatalf("unexpected access token principal %+v: %v", principal, err)
+    }
+
+    first := session["refreshToken"].(string)
+    code, rotated := client.post("/auth/refresh", map[string]any{"refreshToken": first}, nil)
+    if code != http.StatusOK || rotated["refreshToken"] == first {
+        t.Fatalf("refresh: %d %v", code, rotated)
+    }
+    second := rotated["refreshToken"].(string)
+
+    // Replaying the rotated token revokes the whole session.
+    if code, _ := client.post("/auth/refresh", map[string]any{"refreshToken": first}, nil); code != http.StatusUnauthorized {
+        t.Fatalf("expected reused refresh token to be rejected, got %d", code)
+    }
+    if code, _ := client.post("/auth/refresh", map[string]any{"refreshToken": second}, nil); code != http.StatusUnauthorized {
+        t.Fatalf("expected token family to be revoked after reuse, got %d", code)
+    }
+
+    _, session = client.post("/auth/login", map[string]any{"email": "ada@example.com", "password": "correct horse"}, nil)
+    if code, _ := client.post("/auth/logout", map[string]any{"refreshToken": session["refreshToken"]}, nil); code != http.StatusNoContent {
+        t.Fatalf("logout: %d", code)
+    }
+    if code, _ := client.post("/auth/refresh", map[string]any{"refreshToken": session["refreshToken"]}, nil); code != http.StatusUnauthorized {
+        t.Fatalf("expected logged out refresh token to be rejected, got %d", code)
+    }
+}
+
+func TestLoginLocksAccountAfterRepeatedFailures(t *testing.T) {
+    client, _ := newAuthTestClient(t)
+    client.post("/users", map[string]any{"name": "Ada", "email": "ada@example.com", "roles": []string{AdminRole}, "password": "correct horse"}, nil)
+
+    for attempt := 1; attempt <= 2; attempt++ {
+        if code, _ := client.post("/auth/login", map[string]any{"email": "ada@example.com", "password": "wrong password"}, nil); code != http.StatusUnauthorized {
+            t.Fatalf("attempt %d: expected 401, got %d", attempt, code)
+        }
+    }
+    if code, _ := client.post("/auth/login", map[string]any{"email": "ada@example.com", "password": "wrong password"}, nil); code != http.StatusLocked {
+        t.Fatalf("expected third failure to lock the account, got %d", code)
+    }
+    if code, _ := client.post("/auth/login", map[string]any{"email": "ada@example.com", "password": "correct horse"}, nil); code != http.StatusLocked {
+        t.Fatalf("expected locked account to reject the right password, got %d", code)
+    }
+    if code, _ := client.post("/auth/login", map[string]any{"email": "nobody@example.com", "password": "correct horse"}, nil); code != http.StatusUnauthorized {
+        t.Fatalf("expected unknown email to be rejected, got %d", code)
+    }
+}
+
+func TestPasswordChangeAndReset(t *testing.T) {
+    var delivered string
+    notifier := PasswordResetNotifierFunc(func(ctx context.Context, user User, token string, expiresAt time.Time) error {
+        delivered = token
+        return nil
+    })
+    client, _ := newAuthTestClient(t, WithPasswordResetNotifier(notifier))
+    _, created := client.post("/users", map[string]any{"name": "Ada", "email": "ada@example.com", "roles": []string{AdminRole}, "password": "correct horse"}, nil)
+    actor := map[string]string{auth.HeaderActor: created["id"].(string)}
+
+    if code, _ := client.post("/auth/password", map[string]any{"currentPassword": "wrong password", "newPassword": "battery staple"}, actor); code != http.StatusForbidden {
+        t.Fatalf("expected wrong current password to be rejected, got %d", code)
+    }
+    if code, _ := client.post("/auth/password", map[string]any{"currentPassword": "correct horse", "newPassword": "battery staple"}, nil); code != http.StatusUnauthorized {
+        t.Fatalf("expected anonymous password change to be rejected, got %d", code)
+    }
+    if code, _ := client.post("/auth/password", map[string]any{"currentPassword": "correct horse", "newPassword": "battery staple"}, actor); code != http.StatusNoContent {
+        t.Fatalf("change password: %d", code)
+    }
+    if code, _ := client.post("/auth/login", map[string]any{"email": "ada@example.com", "password": "battery staple"}, nil); code != http.StatusOK {
+        t.Fatalf("expected new password to log in, got %d", code)
+    }
+
+    if code, _ := client.post("/auth/password-reset", map[string]any{"email": "nobody@example.com"}, nil); code != http.StatusAccepted || delivered != "" {
+        t.Fatalf("expected unknown email to be accepted silently, got %d", code)
+    }
+    if code, _ := client.post("/auth/password-reset", map[string]any{"email": "ada@example.com"}, nil); code != http.StatusAccepted || delivered == "" {
+        t.Fatalf("expected reset token to be delivered, got %d", code)
+    }
+    if code, _ := client.post("/auth/password-reset/confirm", map[string]any{"token": delivered, "newPassword": "reset password"}, nil); code != http.StatusNoContent {
+        t.Fatalf("confirm reset: %d", code)
+    }
+    if code, _ := client.post("/auth/password-reset/confirm", map[string]any{"token": delivered, "newPassword": "another password"}, nil); code != http.StatusBadRequest {
+        t.Fatalf("expected reset token to be single use, got %d", code)
+    }
+    if code, _ := client.post("/auth/login", map[string]any{"email": "ada@example.com", "password": "reset password"}, nil); code != http.StatusOK {
+        t.Fatalf("expected reset password to log in, got %d", code)
+    }
+}
+
+func TestEnsureUserCreatesBootstrapAccountOnce(t *testing.T) {
+    store := newMemoryStore()
+    roles, err := store.FindRolesByName(context.Background(), []string{AdminRole})
+    if err != nil {
+        t.Fatalf("admin role: %v", err)
+    }
+    admin := User{Name: "Administrator", Email: "Admin@Example.com", Roles: roles}
+
+    created, err := EnsureUser(context.Background(), store, store, admin, "bootstrap secret")
+    if err != nil || !created {
+        t.Fatalf("expected bootstrap user to be created, got %v %v", created, err)
+    }
+    created, err = EnsureUser(context.Background(), store, store, admin, "other secret")
+    if err != nil || created {
+        t.Fatalf("expected existing user to be kept, got %v %v", created, err)
+    }
+
+    user, err := store.FindByEmail(context.Background(), "admin@example.com")
+    if err != nil || !CheckPassword(user.PasswordHash, "bootstrap secret") {
+        t.Fatalf("expected bootstrap password to be stored, got %+v %v", user, err)
+    }
+}
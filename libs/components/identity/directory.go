@@ -0,0 +1,152 @@
+package identity
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "net/url"
+    "strings"
+    "time"
+
+    "github.com/pflow/shared/auth"
+    "github.com/pflow/shared/httpx"
+    "github.com/pflow/shared/tenant"
+)
+
+// directoryPageSize is how many users a Directory reads per page.
+const directoryPageSize = 200
+
+// directoryPrincipal is the caller a remote Directory presents to the identity
+// service.
+const directoryPrincipal = "system:directory"
+
+// Member is a user of a group as seen by components that route work to it.
+type Member struct {
+    ID     string   `json:"id"`
+    Name   string   `json:"name"`
+    Skills []string `json:"skills"`
+}
+
+// HasSkills reports whether the member has every skill in required.
+func (m Member) HasSkills(required []string) bool {
+    for _, skill := range required {
+        found := false
+        for _, own := range m.Skills {
+            if strings.EqualFold(own, skill) {
+                found = true
+                break
+            }
+        }
+        if !found {
+            return false
+        }
+    }
+    return true
+}
+
+// Directory lists the members of a group. Groups are roles: every user holding
+// the role is a member.
+type Directory struct {
+    page func(ctx context.Context, role, cursor string) ([]Member, string, error)
+}
+
+// NewDirectory constructs a Directory that reads users from the repository.
+func NewDirectory(repo Repository) *Directory {
+    return &Directory{page: func(ctx context.Context, role, cursor string) ([]Member, string, error) {
+        query := httpx.ListQuery{
+            Limit:      directoryPageSize,
+            SortColumn: "created_at",
+            Filters:    map[string][]any{"role": {role}},
+        }
+        if cursor != "" {
+            decoded, err := httpx.DecodeCursor(cursor)
+            if err != nil {
+                return nil, "", err
+            }
+            query.Cursor = decoded
+        }
+
+        users, page, err := repo.List(ctx, query)
+        if err != nil {
+            return nil, "", err
+        }
+        members := make([]Member, 0, len(users))
+        for _, entity := range users {
+            members = append(members, entity.Member())
+        }
+        return members, page.NextCursor, nil
+    }}
+}
+
+// NewRemoteDirectory constructs a Directory that lists users through the
+// identity service, for services that do not share the identity database.
+// Requests are made in the tenant of the request context and, when secret is
+// set, signed as a principal allowed to view users.
+func NewRemoteDirectory(baseURL string, client *http.Client, secret []byte) *Directory {
+    base := strings.TrimRight(strings.TrimSpace(baseURL), "/")
+    if client == nil {
+        client = &http.Client{Timeout: 5 * time.Second}
+    }
+
+    return &Directory{page: func(ctx context.Context, role, cursor string) ([]Member, string, error) {
+        params := url.Values{}
+        params.Set("role", role)
+        params.Set("limit", fmt.Sprint(directoryPageSize))
+        if cursor != "" {
+            params.Set("cursor", cursor)
+        }
+
+        req, err := http.NewRequestWithContext(ctx, http.MethodGet, base+"/users?"+params.Encode(), nil)
+        if err != nil {
+            return nil, "", err
+        }
+        req.Header.Set(tenant.Header, tenant.ID(ctx))
+        auth.Forward(req.Header, auth.Principal{
+            UserID:      directoryPrincipal,
+            Permissions: []string{auth.PermissionUserView},
+            TenantID:    tenant.ID(ctx),
+        }, secret)
+        resp, err := client.Do(req)
+        if err != nil {
+            return nil, "", fmt.Errorf("list members of %s: %w", role, err)
+        }
+        defer resp.Body.Close()
+
+        if resp.StatusCode != http.StatusOK {
+            return nil, "", fmt.Errorf("list members of %s: unexpected status %d", role, resp.StatusCode)
+        }
+
+        var envelope struct {
+            Data       []Member `json:"data"`
+            NextCursor *string  `json:"nextCursor"`
+        }
+        if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+            return nil, "", fmt.Errorf("decode members of %s: %w", role, err)
+        }
+        next := ""
+        if envelope.NextCursor != nil {
+            next = *envelope.NextCursor
+        }
+        return envelope.Data, next, nil
+    }}
+}
+
+// Members returns every member of the group named by role.
+func (d *Directory) Members(ctx context.Context, role string) ([]Member, error) {
+    var (
+        members []Member
+        cursor  string
+    )
+    for {
+        page, next, err := d.page(ctx, role, cursor)
+        if err != nil {
+            return nil, err
+        }
+        members = append(members, page...)
+        if next == "" {
+            return members, nil
+        }
+        cursor = next
+    }
+}
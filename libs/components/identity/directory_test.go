@@ -0,0 +1,67 @@
+package identity
+
+import (
+    "context"
+    "net/http/httptest"
+    "reflect"
+    "testing"
+
+    "github.com/go-chi/chi/v5"
+
+    "github.com/pflow/shared/auth"
+    "github.com/pflow/shared/tenant"
+)
+
+func TestDirectoryListsMembersOfRole(t *testing.T) {
+    repo := newTenantTestRepository(t)
+    acme := tenant.WithID(context.Background(), "acme")
+    globex := tenant.WithID(context.Background(), "globex")
+
+    support := &Role{Name: "support"}
+    if err := repo.CreateRole(acme, support, nil); err != nil {
+        t.Fatalf("create role: %v", err)
+    }
+    users := []struct {
+        ctx   context.Context
+        user  *User
+        roles []Role
+    }{
+        {acme, &User{Name: "Ada", Email: "ada@example.com", Skills: []string{" DE", "en", "de"}}, []Role{*support}},
+        {acme, &User{Name: "Bruno", Email: "bruno@example.com"}, nil},
+        {globex, &User{Name: "Chen", Email: "chen@example.com"}, []Role{*support}},
+    }
+    for _, entry := range users {
+        entry.user.Roles = entry.roles
+        if err := repo.Create(entry.ctx, entry.user); err != nil {
+            t.Fatalf("create %s: %v", entry.user.Name, err)
+        }
+    }
+    want := []Member{{ID: users[0].user.ID, Name: "Ada", Skills: []string{"de", "en"}}}
+
+    local, err := NewDirectory(repo).Members(acme, "support")
+    if err != nil {
+        t.Fatalf("local members: %v", err)
+    }
+    if !reflect.DeepEqual(local, want) {
+        t.Fatalf("expected %+v, got %+v", want, local)
+    }
+
+    secret := []byte("forward secret")
+    router := chi.NewRouter()
+    router.Use(auth.Middleware(nil, auth.AcceptForwarded(secret, 0)), tenant.Middleware)
+    NewHandler(repo, WithRoles(repo), WithAuthorization()).Mount(router, "")
+    server := httptest.NewServer(router)
+    defer server.Close()
+
+    remote, err := NewRemoteDirectory(server.URL, nil, secret).Members(acme, "support")
+    if err != nil {
+        t.Fatalf("remote members: %v", err)
+    }
+    if !reflect.DeepEqual(remote, want) {
+        t.Fatalf("expected %+v, got %+v", want, remote)
+    }
+
+    if _, err := NewRemoteDirectory(server.URL, nil, []byte("wrong")).Members(acme, "support"); err == nil {
+        t.Fatal("expected an unsigned directory request to be rejected")
+    }
+}
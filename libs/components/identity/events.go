@@ -0,0 +1,28 @@
+package identity
+
+import (
+    "gorm.io/gorm"
+
+    "github.com/pflow/components/outbox"
+)
+
+// eventSource is the source of the domain events the identity component emits.
+const eventSource = "/pflow/identity"
+
+// Domain events emitted after user and role changes, with the user or role ID
+// as subject.
+const (
+    EventUserCreated  = "user.created"
+    EventUserUpdated  = "user.updated"
+    EventUserDeleted  = "user.deleted"
+    EventUserRestored = "user.restored"
+    EventRoleCreated  = "role.created"
+    EventRoleUpdated  = "role.updated"
+    EventRoleDeleted  = "role.deleted"
+)
+
+// NewEventOutbox constructs the outbox the user and role events are recorded
+// in, in the transaction of the change they announce.
+func NewEventOutbox(db *gorm.DB) *outbox.EventStore {
+    return outbox.NewEventStore(db, eventSource)
+}
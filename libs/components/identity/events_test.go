@@ -0,0 +1,76 @@
+package identity
+
+import (
+    "context"
+    "encoding/json"
+    "net/http"
+    "testing"
+
+    "github.com/go-chi/chi/v5"
+
+    "github.com/pflow/components/outbox"
+    "github.com/pflow/shared/auth"
+    "github.com/pflow/shared/mq"
+)
+
+type recordingPublisher struct {
+    events []mq.Event
+}
+
+func (p *recordingPublisher) Publish(ctx context.Context, key string, value []byte, headers map[string]string) error {
+    var event mq.Event
+    if err := json.Unmarshal(value, &event); err != nil {
+        return err
+    }
+    p.events = append(p.events, event)
+    return nil
+}
+
+func TestHandlerRecordsEventsInTheOutbox(t *testing.T) {
+    repo := newTenantTestRepository(t)
+    router := chi.NewRouter()
+    handler := NewHandler(repo, WithRoles(repo), WithEvents(NewEventOutbox(repo.db)))
+    handler.Mount(router, "")
+    handler.MountRoles(router, "")
+    client := authTestClient{t: t, router: router}
+
+    code, role := client.post("/roles", map[string]any{"name": "auditor", "permissions": []string{auth.PermissionUserView}}, nil)
+    if code != http.StatusCreated {
+        t.Fatalf("create role: %d %v", code, role)
+    }
+    code, created := client.post("/users", map[string]any{"name": "Alice", "email": "alice@example.com"}, nil)
+    if code != http.StatusCreated {
+        t.Fatalf("create user: %d %v", code, created)
+    }
+    id := created["id"].(string)
+    if code, _ := client.post("/users", map[string]any{"name": "Alice again", "email": "alice@example.com"}, nil); code == http.StatusCreated {
+        t.Fatal("expected a duplicate email to be refused")
+    }
+    if code, _ := client.do(http.MethodPut, "/users/"+id, map[string]any{"name": "Alice Liddell"}, nil); code != http.StatusOK {
+        t.Fatalf("update user: %d", code)
+    }
+    if code, _ := client.do(http.MethodDelete, "/users/"+id, nil, nil); code != http.StatusNoContent {
+        t.Fatalf("delete user: %d", code)
+    }
+
+    publisher := &recordingPublisher{}
+    relay := outbox.NewRelay(outbox.NewRepository(repo.db), outbox.Routes{outbox.Events: publisher}, outbox.RelayConfig{})
+    if _, err := relay.RelayOnce(context.Background()); err != nil {
+        t.Fatalf("relay: %v", err)
+    }
+
+    want := []string{EventRoleCreated, EventUserCreated, EventUserUpdated, EventUserDeleted}
+    if len(publisher.events) != len(want) {
+        t.Fatalf("expected %d events, got %+v", len(want), publisher.events)
+    }
+    for i, event := range publisher.events {
+        if event.Type != want[i] || event.Source != eventSource {
+            t.Fatalf("unexpected event %d: %+v", i, event)
+        }
+    }
+    for _, event := range publisher.events[1:] {
+        if event.Subject != id {
+            t.Fatalf("expected the user events to be about %s, got %+v", id, event)
+        }
+    }
+}
@@ -1,26 +1,105 @@
 package identity
 
 import (
+    "context"
     "encoding/json"
     "errors"
     "io"
     "net/http"
     "net/mail"
     "strings"
+    "time"
 
     "github.com/go-chi/chi/v5"
+    "gorm.io/datatypes"
 
+    "github.com/pflow/components/outbox"
+    "github.com/pflow/shared/audit"
+    "github.com/pflow/shared/auth"
+    "github.com/pflow/shared/database"
     "github.com/pflow/shared/httpx"
 )
 
-// Handler exposes HTTP handlers for user management.
+var userListSpec = httpx.ListSpec{
+    SortFields: map[string]string{
+        "createdAt": "created_at",
+        "updatedAt": "updated_at",
+        "name":      "name",
+        "email":     "email",
+    },
+    DefaultSort: "createdAt",
+    DefaultDesc: true,
+    Filters: map[string]string{
+        "role": "role",
+    },
+    SearchColumns: []string{"name", "email"},
+    SoftDelete:    true,
+}
+
+// Handler exposes HTTP handlers for user management and, when configured with
+// WithAuthentication, for login and token issuance.
 type Handler struct {
-    repo Repository
+    repo   Repository
+    roles  RoleRepository
+    authz  auth.Enforcer
+    events *outbox.EventStore
+    audit  *audit.Recorder
+
+    credentials     CredentialStore
+    signer          *auth.Signer
+    refreshTTL      time.Duration
+    maxFailedLogins int
+    lockout         time.Duration
+    resetNotifier   PasswordResetNotifier
+}
+
+// HandlerOption customises the handler behaviour.
+type HandlerOption func(*Handler)
+
+// WithRoles enables role assignment on users and the endpoints mounted by
+// MountRoles.
+func WithRoles(roles RoleRepository) HandlerOption {
+    return func(h *Handler) {
+        h.roles = roles
+    }
+}
+
+// WithEvents records an event for every user and role change in the
+// transaction of the change.
+func WithEvents(events *outbox.EventStore) HandlerOption {
+    return func(h *Handler) {
+        h.events = events
+    }
+}
+
+// WithAudit records every successful user and role change in the audit log.
+func WithAudit(recorder *audit.Recorder) HandlerOption {
+    return func(h *Handler) {
+        h.audit = recorder
+    }
+}
+
+// WithAuthorization guards every route with the permission it requires.
+func WithAuthorization() HandlerOption {
+    return func(h *Handler) {
+        h.authz.Enabled = true
+    }
 }
 
 // NewHandler creates a new identity Handler.
-func NewHandler(repo Repository) *Handler {
-    return &Handler{repo: repo}
+func NewHandler(repo Repository, opts ...HandlerOption) *Handler {
+    handler := &Handler{
+        repo:            repo,
+        refreshTTL:      defaultRefreshTokenTTL,
+        maxFailedLogins: defaultMaxFailedLogins,
+        lockout:         defaultLockoutDuration,
+    }
+    for _, opt := range opts {
+        if opt != nil {
+            opt(handler)
+        }
+    }
+    return handler
 }
 
 // Mount registers user routes on the provided router under the supplied base path.
@@ -31,33 +110,46 @@ func (h *Handler) Mount(router chi.Router, basePath string) {
     }
 
     router.Route(path, func(r chi.Router) {
-        r.Get("/", h.listUsers)
-        r.Post("/", h.createUser)
+        view := r.With(h.authz.Require(auth.PermissionUserView))
+        manage := r.With(h.authz.Require(auth.PermissionUserManage))
+        view.Get("/", h.listUsers)
+        manage.Post("/", h.createUser)
         r.Route("/{id}", func(r chi.Router) {
-            r.Get("/", h.getUser)
-            r.Put("/", h.updateUser)
-            r.Delete("/", h.deleteUser)
+            r.With(h.authz.Require(auth.PermissionUserView)).Get("/", h.getUser)
+            r.With(h.authz.Require(auth.PermissionUserManage)).Put("/", h.updateUser)
+            r.With(h.authz.Require(auth.PermissionUserManage)).Delete("/", h.deleteUser)
+            r.With(h.authz.Require(auth.PermissionUserManage)).Post("/restore", h.restoreUser)
         })
     })
 }
 
 type createUserRequest struct {
-    Name  string `json:"name"`
-    Email string `json:"email"`
-    Role  string `json:"role"`
+    Name     string   `json:"name"`
+    Email    string   `json:"email"`
+    Roles    []string `json:"roles"`
+    Skills   []string `json:"skills"`
+    Password string   `json:"password"`
 }
 
 type updateUserRequest struct {
-    Name  *string `json:"name"`
-    Email *string `json:"email"`
-    Role  *string `json:"role"`
+    Name   *string   `json:"name"`
+    Email  *string   `json:"email"`
+    Roles  *[]string `json:"roles"`
+    Skills *[]string `json:"skills"`
 }
 
 func (h *Handler) listUsers(w http.ResponseWriter, r *http.Request) {
-    role := strings.TrimSpace(r.URL.Query().Get("role"))
-    search := strings.TrimSpace(r.URL.Query().Get("search"))
+    query, err := httpx.ParseListQuery(r, userListSpec)
+    if err != nil {
+        httpx.Error(w, http.StatusBadRequest, err.Error())
+        return
+    }
+    if query.IncludeDeleted && !h.authz.Allows(r, auth.PermissionUserManage) {
+        httpx.Error(w, http.StatusForbidden, "listing deleted users requires "+auth.PermissionUserManage)
+        return
+    }
 
-    users, err := h.repo.List(r.Context(), role, search)
+    users, page, err := h.repo.List(r.Context(), query)
     if err != nil {
         httpx.Error(w, http.StatusInternalServerError, err.Error())
         return
@@ -68,7 +160,7 @@ func (h *Handler) listUsers(w http.ResponseWriter, r *http.Request) {
         items = append(items, entity.ToDTO())
     }
 
-    httpx.JSON(w, http.StatusOK, map[string]any{"data": items})
+    httpx.List(w, items, page)
 }
 
 func (h *Handler) createUser(w http.ResponseWriter, r *http.Request) {
@@ -80,7 +172,6 @@ func (h *Handler) createUser(w http.ResponseWriter, r *http.Request) {
 
     name := strings.TrimSpace(payload.Name)
     email := strings.ToLower(strings.TrimSpace(payload.Email))
-    role := strings.TrimSpace(payload.Role)
 
     if name == "" {
         httpx.Error(w, http.StatusBadRequest, "name is required")
@@ -94,21 +185,44 @@ func (h *Handler) createUser(w http.ResponseWriter, r *http.Request) {
         httpx.Error(w, http.StatusBadRequest, "email is invalid")
         return
     }
-    if role == "" {
-        httpx.Error(w, http.StatusBadRequest, "role is required")
+    roles, status, err := h.resolveRoles(r, payload.Roles)
+    if err != nil {
+        httpx.Error(w, status, err.Error())
+        return
+    }
+    if len(roles) > 0 && !h.authz.Allows(r, auth.PermissionRoleManage) {
+        httpx.Error(w, http.StatusForbidden, errRoleAssignment.Error())
         return
     }
 
     entity := &User{
-        Name:  name,
-        Email: email,
-        Role:  role,
+        Name:   name,
+        Email:  email,
+        Roles:  roles,
+        Skills: normalizeSkills(payload.Skills),
+    }
+    if payload.Password != "" {
+        hash, err := HashPassword(payload.Password)
+        if err != nil {
+            httpx.Error(w, http.StatusBadRequest, err.Error())
+            return
+        }
+        now := time.Now()
+        entity.PasswordHash = hash
+        entity.PasswordChangedAt = &now
     }
 
-    if err := h.repo.Create(r.Context(), entity); err != nil {
+    err = h.events.Transaction(r.Context(), func(ctx context.Context) error {
+        if err := h.repo.Create(ctx, entity); err != nil {
+            return err
+        }
+        return h.events.Emit(ctx, EventUserCreated, entity.ID, entity.ToDTO())
+    })
+    if err != nil {
         httpx.Error(w, http.StatusInternalServerError, err.Error())
         return
     }
+    h.audit.Record(r.Context(), audit.Change{Action: EventUserCreated, ResourceID: entity.ID, After: entity.ToDTO()})
 
     httpx.JSON(w, http.StatusCreated, map[string]any{"data": entity.ToDTO()})
 }
@@ -157,21 +271,52 @@ func (h *Handler) updateUser(w http.ResponseWriter, r *http.Request) {
         }
         updates["email"] = email
     }
-    if payload.Role != nil {
-        role := strings.TrimSpace(*payload.Role)
-        if role == "" {
-            httpx.Error(w, http.StatusBadRequest, "role cannot be empty")
+    if payload.Skills != nil {
+        updates["skills"] = datatypes.NewJSONSlice(normalizeSkills(*payload.Skills))
+    }
+    var roles []Role
+    if payload.Roles != nil {
+        resolved, status, err := h.resolveRoles(r, *payload.Roles)
+        if err != nil {
+            httpx.Error(w, status, err.Error())
             return
         }
-        updates["role"] = role
+        roles = resolved
     }
 
-    if len(updates) == 0 {
+    if len(updates) == 0 && payload.Roles == nil {
         httpx.Error(w, http.StatusBadRequest, "no updates provided")
         return
     }
 
-    entity, err := h.repo.Update(r.Context(), id, updates)
+    before, err := h.repo.Find(r.Context(), id)
+    if err != nil {
+        if IsNotFound(err) {
+            httpx.Error(w, http.StatusNotFound, "user not found")
+            return
+        }
+        httpx.Error(w, http.StatusInternalServerError, err.Error())
+        return
+    }
+    if payload.Roles != nil && !sameRoles(before.Roles, roles) && !h.authz.Allows(r, auth.PermissionRoleManage) {
+        httpx.Error(w, http.StatusForbidden, errRoleAssignment.Error())
+        return
+    }
+    entity := before
+    err = h.events.Transaction(r.Context(), func(ctx context.Context) error {
+        var err error
+        if len(updates) > 0 {
+            if entity, err = h.repo.Update(ctx, id, updates); err != nil {
+                return err
+            }
+        }
+        if payload.Roles != nil {
+            if entity, err = h.repo.SetRoles(ctx, id, roles); err != nil {
+                return err
+            }
+        }
+        return h.events.Emit(ctx, EventUserUpdated, entity.ID, entity.ToDTO())
+    })
     if err != nil {
         if IsNotFound(err) {
             httpx.Error(w, http.StatusNotFound, "user not found")
@@ -180,13 +325,29 @@ func (h *Handler) updateUser(w http.ResponseWriter, r *http.Request) {
         httpx.Error(w, http.StatusInternalServerError, err.Error())
         return
     }
+    h.audit.Record(r.Context(), audit.Change{Action: EventUserUpdated, ResourceID: entity.ID, Before: before.ToDTO(), After: entity.ToDTO()})
 
     httpx.JSON(w, http.StatusOK, map[string]any{"data": entity.ToDTO()})
 }
 
 func (h *Handler) deleteUser(w http.ResponseWriter, r *http.Request) {
     id := chi.URLParam(r, "id")
-    if err := h.repo.Delete(r.Context(), id); err != nil {
+    before, err := h.repo.Find(r.Context(), id)
+    if err != nil {
+        if IsNotFound(err) {
+            httpx.Error(w, http.StatusNotFound, "user not found")
+            return
+        }
+        httpx.Error(w, http.StatusInternalServerError, err.Error())
+        return
+    }
+    err = h.events.Transaction(r.Context(), func(ctx context.Context) error {
+        if err := h.repo.Delete(ctx, id); err != nil {
+            return err
+        }
+        return h.events.Emit(ctx, EventUserDeleted, id, map[string]any{"id": id})
+    })
+    if err != nil {
         if IsNotFound(err) {
             httpx.Error(w, http.StatusNotFound, "user not found")
             return
@@ -194,10 +355,86 @@ func (h *Handler) deleteUser(w http.ResponseWriter, r *http.Request) {
         httpx.Error(w, http.StatusInternalServerError, err.Error())
         return
     }
+    h.audit.Record(r.Context(), audit.Change{Action: EventUserDeleted, ResourceID: id, Before: before.ToDTO()})
 
     w.WriteHeader(http.StatusNoContent)
 }
 
+// restoreUser undoes the deletion of a user that was not purged yet. The user
+// has to sign in again.
+func (h *Handler) restoreUser(w http.ResponseWriter, r *http.Request) {
+    var entity *User
+    err := h.events.Transaction(r.Context(), func(ctx context.Context) error {
+        var err error
+        if entity, err = h.repo.Restore(ctx, chi.URLParam(r, "id")); err != nil {
+            return err
+        }
+        return h.events.Emit(ctx, EventUserRestored, entity.ID, entity.ToDTO())
+    })
+    if err != nil {
+        switch {
+        case IsNotFound(err):
+            httpx.Error(w, http.StatusNotFound, "user not found")
+        case errors.Is(err, database.ErrNotDeleted):
+            httpx.Error(w, http.StatusConflict, "user is not deleted")
+        case errors.Is(err, ErrEmailInUse):
+            httpx.Error(w, http.StatusConflict, err.Error())
+        default:
+            httpx.Error(w, http.StatusInternalServerError, err.Error())
+        }
+        return
+    }
+    h.audit.Record(r.Context(), audit.Change{Action: EventUserRestored, ResourceID: entity.ID, After: entity.ToDTO()})
+
+    httpx.JSON(w, http.StatusOK, map[string]any{"data": entity.ToDTO()})
+}
+
+// resolveRoles loads the roles named in a user payload, returning the status
+// to respond with when that fails.
+func (h *Handler) resolveRoles(r *http.Request, names []string) ([]Role, int, error) {
+    cleaned := make([]string, 0, len(names))
+    for _, name := range names {
+        name = strings.TrimSpace(name)
+        if name == "" {
+            return nil, http.StatusBadRequest, errors.New("role names cannot be empty")
+        }
+        cleaned = append(cleaned, name)
+    }
+    if len(cleaned) == 0 {
+        return nil, http.StatusOK, nil
+    }
+    if h.roles == nil {
+        return nil, http.StatusBadRequest, errors.New("role management is not configured")
+    }
+    roles, err := h.roles.FindRolesByName(r.Context(), cleaned)
+    if errors.Is(err, ErrUnknownRole) {
+        return nil, http.StatusBadRequest, err
+    }
+    if err != nil {
+        return nil, http.StatusInternalServerError, err
+    }
+    return roles, http.StatusOK, nil
+}
+
+// errRoleAssignment is returned when a caller that may manage users but not
+// roles tries to change which roles a user holds. Without this check user:manage
+// would be enough to hand anyone the admin role.
+var errRoleAssignment = errors.New("changing roles requires " + auth.PermissionRoleManage)
+
+// sameRoles reports whether two role sets hold the same roles.
+func sameRoles(a, b []Role) bool {
+    left, right := User{Roles: a}.RoleNames(), User{Roles: b}.RoleNames()
+    if len(left) != len(right) {
+        return false
+    }
+    for i := range left {
+        if left[i] != right[i] {
+            return false
+        }
+    }
+    return true
+}
+
 func decodeJSON(r *http.Request, v any) error {
     defer r.Body.Close()
     decoder := json.NewDecoder(r.Body)
@@ -0,0 +1,109 @@
+package identity
+
+import (
+    "fmt"
+    "strings"
+
+    "gorm.io/gorm"
+    "gorm.io/gorm/clause"
+
+    "github.com/pflow/components/outbox"
+    "github.com/pflow/shared/auth"
+)
+
+// Migrate creates the identity schema and its event outbox, seeds the permission catalog and the
+// admin role, and converts the legacy free-text users.role column into role
+// assignments. Roles created from that column start without permissions.
+// Existing users and roles move to the default tenant and the earlier email
+// indexes are replaced by one per tenant that ignores soft-deleted users; role
+// names become unique per tenant.
+func Migrate(db *gorm.DB) error {
+    if err := db.AutoMigrate(&Permission{}, &Role{}, &User{}, &RefreshToken{}, &PasswordReset{}, &outbox.Message{}); err != nil {
+        return err
+    }
+    if db.Migrator().HasIndex(&Role{}, "idx_roles_name") {
+        if err := db.Migrator().DropIndex(&Role{}, "idx_roles_name"); err != nil {
+            return err
+        }
+    }
+    for _, index := range []string{"idx_users_email", "idx_users_tenant_email"} {
+        if db.Migrator().HasIndex(&User{}, index) {
+            if err := db.Migrator().DropIndex(&User{}, index); err != nil {
+                return err
+            }
+        }
+    }
+
+    return db.Transaction(func(tx *gorm.DB) error {
+        if err := seedPermissions(tx); err != nil {
+            return err
+        }
+        if err := seedAdminRole(tx); err != nil {
+            return err
+        }
+        return migrateLegacyRoles(tx)
+    })
+}
+
+// permissionCatalog returns the enforced permissions together with the
+// wildcards that can be granted in their place.
+func permissionCatalog() []Permission {
+    catalog := []Permission{{Key: auth.PermissionAll, Description: "All permissions"}}
+    resources := map[string]bool{}
+    for _, info := range auth.Permissions() {
+        resource, _, _ := strings.Cut(info.Key, ":")
+        if !resources[resource] {
+            resources[resource] = true
+            catalog = append(catalog, Permission{
+                Key:         resource + ":*",
+                Description: fmt.Sprintf("All %s permissions", resource),
+            })
+        }
+        catalog = append(catalog, Permission{Key: info.Key, Description: info.Description})
+    }
+    return catalog
+}
+
+func seedPermissions(tx *gorm.DB) error {
+    return tx.Clauses(clause.OnConflict{
+        Columns:   []clause.Column{{Name: "key"}},
+        DoUpdates: clause.AssignmentColumns([]string{"description"}),
+    }).Create(permissionCatalog()).Error
+}
+
+func seedAdminRole(tx *gorm.DB) error {
+    role := Role{Name: AdminRole}
+    err := tx.Where("name = ? AND system = ?", AdminRole, true).
+        Attrs(Role{Description: "Full access to every component", System: true}).
+        FirstOrCreate(&role).Error
+    if err != nil {
+        return err
+    }
+    return tx.Model(&role).Omit("Permissions.*").Association("Permissions").
+        Append(&Permission{Key: auth.PermissionAll})
+}
+
+func migrateLegacyRoles(tx *gorm.DB) error {
+    if !tx.Migrator().HasColumn(&User{}, "role") {
+        return nil
+    }
+
+    var names []string
+    if err := tx.Table("users").Distinct("role").Where("role <> ''").Pluck("role", &names).Error; err != nil {
+        return err
+    }
+    for _, name := range names {
+        role := Role{Name: name}
+        if err := tx.Where("name = ?", name).FirstOrCreate(&role).Error; err != nil {
+            return err
+        }
+    }
+
+    err := tx.Exec(`INSERT INTO user_roles (user_id, role_id)
+        SELECT users.id, roles.id FROM users JOIN roles ON roles.name = users.role
+        ON CONFLICT DO NOTHING`).Error
+    if err != nil {
+        return err
+    }
+    return tx.Migrator().DropColumn(&User{}, "role")
+}
@@ -1,20 +1,39 @@
 package identity
 
 import (
+    "sort"
+    "strings"
     "time"
 
     "github.com/google/uuid"
+    "gorm.io/datatypes"
     "gorm.io/gorm"
+
+    "github.com/pflow/shared/auth"
 )
 
-// User captures an account within the identity service.
+// User captures an account within the identity service. Users belong to one
+// tenant and their email is unique within it. Users without a password hash
+// cannot log in until one is set through a reset.
 type User struct {
     ID        string    `json:"id" gorm:"type:uuid;primaryKey"`
+    TenantID  string    `json:"tenantId" gorm:"type:varchar(64);not null;default:default;uniqueIndex:idx_users_tenant_email_active,priority:1,where:deleted_at IS NULL"`
     Name      string    `json:"name" gorm:"not null"`
-    Email     string    `json:"email" gorm:"uniqueIndex;not null"`
-    Role      string    `json:"role" gorm:"not null"`
+    Email     string    `json:"email" gorm:"not null;uniqueIndex:idx_users_tenant_email_active,priority:2"`
+    Roles     []Role    `json:"roles" gorm:"many2many:user_roles"`
+    // Skills are free-form tags, such as languages or products, used to
+    // route work to the user.
+    Skills    datatypes.JSONSlice[string] `json:"skills" gorm:"type:jsonb"`
     CreatedAt time.Time `json:"createdAt"`
     UpdatedAt time.Time `json:"updatedAt"`
+    // DeletedAt is set while the user is soft-deleted; the email is only
+    // unique among users that are not deleted.
+    DeletedAt gorm.DeletedAt `json:"deletedAt,omitempty" gorm:"index"`
+
+    PasswordHash      string     `json:"-"`
+    PasswordChangedAt *time.Time `json:"passwordChangedAt,omitempty"`
+    FailedLogins      int        `json:"-" gorm:"not null;default:0"`
+    LockedUntil       *time.Time `json:"lockedUntil,omitempty"`
 }
 
 // BeforeCreate ensures a UUID exists.
@@ -25,14 +44,203 @@ func (u *User) BeforeCreate(tx *gorm.DB) error {
     return nil
 }
 
-// ToDTO renders the response payload.
+func (u User) sortValue(column string) (any, string) {
+    switch column {
+    case "updated_at":
+        return u.UpdatedAt, u.ID
+    case "name":
+        return u.Name, u.ID
+    case "email":
+        return u.Email, u.ID
+    default:
+        return u.CreatedAt, u.ID
+    }
+}
+
+// RoleNames returns the names of the user's roles.
+func (u User) RoleNames() []string {
+    names := make([]string, 0, len(u.Roles))
+    for _, role := range u.Roles {
+        names = append(names, role.Name)
+    }
+    sort.Strings(names)
+    return names
+}
+
+// Member describes the user as a member of the groups it belongs to.
+func (u User) Member() Member {
+    return Member{ID: u.ID, Name: u.Name, Skills: normalizeSkills(u.Skills)}
+}
+
+// normalizeSkills trims, lowercases, deduplicates and sorts skill tags.
+func normalizeSkills(skills []string) []string {
+    seen := map[string]struct{}{}
+    normalized := []string{}
+    for _, skill := range skills {
+        skill = strings.ToLower(strings.TrimSpace(skill))
+        if skill == "" {
+            continue
+        }
+        if _, ok := seen[skill]; ok {
+            continue
+        }
+        seen[skill] = struct{}{}
+        normalized = append(normalized, skill)
+    }
+    sort.Strings(normalized)
+    return normalized
+}
+
+// Principal describes the user as carried in access tokens: the role names and
+// the union of their permissions. Roles must be loaded with their permissions.
+func (u User) Principal() auth.Principal {
+    seen := map[string]struct{}{}
+    permissions := []string{}
+    for _, role := range u.Roles {
+        for _, permission := range role.Permissions {
+            if _, ok := seen[permission.Key]; ok {
+                continue
+            }
+            seen[permission.Key] = struct{}{}
+            permissions = append(permissions, permission.Key)
+        }
+    }
+    sort.Strings(permissions)
+    return auth.Principal{UserID: u.ID, Roles: u.RoleNames(), Permissions: permissions, TenantID: u.TenantID}
+}
+
+// Locked reports whether the account is locked out at the given time.
+func (u User) Locked(now time.Time) bool {
+    return u.LockedUntil != nil && now.Before(*u.LockedUntil)
+}
+
+// ToDTO renders the response payload. Credentials are never included.
 func (u User) ToDTO() map[string]any {
+    payload := map[string]any{
+        "id":          u.ID,
+        "tenantId":    u.TenantID,
+        "name":        u.Name,
+        "email":       u.Email,
+        "roles":       u.RoleNames(),
+        "skills":      normalizeSkills(u.Skills),
+        "hasPassword": u.PasswordHash != "",
+        "createdAt":   u.CreatedAt,
+        "updatedAt":   u.UpdatedAt,
+    }
+    if u.PasswordChangedAt != nil {
+        payload["passwordChangedAt"] = u.PasswordChangedAt
+    }
+    if u.Locked(time.Now()) {
+        payload["lockedUntil"] = u.LockedUntil
+    }
+    if u.DeletedAt.Valid {
+        payload["deletedAt"] = u.DeletedAt.Time
+    }
+    return payload
+}
+
+// RefreshToken is a server-side record of an issued refresh token. Only the
+// SHA-256 hash of the token is stored. Every refresh rotates the token within
+// its family; presenting a token that was already rotated revokes the family.
+type RefreshToken struct {
+    ID         string     `gorm:"type:uuid;primaryKey"`
+    TenantID   string     `gorm:"type:varchar(64);not null;default:default;index"`
+    UserID     string     `gorm:"type:uuid;index;not null"`
+    FamilyID   string     `gorm:"type:uuid;index;not null"`
+    TokenHash  string     `gorm:"uniqueIndex;not null"`
+    ExpiresAt  time.Time  `gorm:"not null"`
+    RevokedAt  *time.Time
+    ReplacedBy string
+    CreatedAt  time.Time
+}
+
+// BeforeCreate ensures a UUID exists and starts a new family when none is set.
+func (t *RefreshToken) BeforeCreate(tx *gorm.DB) error {
+    if t.ID == "" {
+        t.ID = uuid.NewString()
+    }
+    if t.FamilyID == "" {
+        t.FamilyID = t.ID
+    }
+    return nil
+}
+
+// PasswordReset is a single-use password reset token, stored hashed.
+type PasswordReset struct {
+    ID        string     `gorm:"type:uuid;primaryKey"`
+    TenantID  string     `gorm:"type:varchar(64);not null;default:default;index"`
+    UserID    string     `gorm:"type:uuid;index;not null"`
+    TokenHash string     `gorm:"uniqueIndex;not null"`
+    ExpiresAt time.Time  `gorm:"not null"`
+    UsedAt    *time.Time
+    CreatedAt time.Time
+}
+
+// BeforeCreate ensures a UUID exists.
+func (p *PasswordReset) BeforeCreate(tx *gorm.DB) error {
+    if p.ID == "" {
+        p.ID = uuid.NewString()
+    }
+    return nil
+}
+
+// AdminRole is the seeded system role holding every permission.
+const AdminRole = "admin"
+
+// Permission is an entry of the permission catalog. The catalog is seeded from
+// auth.Permissions together with the "*" and "<resource>:*" wildcards.
+type Permission struct {
+    Key         string    `gorm:"primaryKey"`
+    Description string    `gorm:"not null"`
+    CreatedAt   time.Time
+}
+
+// ToDTO renders the response payload.
+func (p Permission) ToDTO() map[string]any {
+    return map[string]any{"key": p.Key, "description": p.Description}
+}
+
+// Role groups permissions and is assigned to users. Roles belong to a tenant,
+// except the seeded system roles: every tenant sees them and none may change
+// or delete them.
+type Role struct {
+    ID          string       `gorm:"type:uuid;primaryKey"`
+    TenantID    string       `gorm:"type:varchar(64);not null;default:default;uniqueIndex:idx_roles_tenant_name,priority:1"`
+    Name        string       `gorm:"uniqueIndex:idx_roles_tenant_name,priority:2;not null"`
+    Description string
+    System      bool         `gorm:"not null;default:false"`
+    Permissions []Permission `gorm:"many2many:role_permissions"`
+    CreatedAt   time.Time
+    UpdatedAt   time.Time
+}
+
+// BeforeCreate ensures a UUID exists.
+func (r *Role) BeforeCreate(tx *gorm.DB) error {
+    if r.ID == "" {
+        r.ID = uuid.NewString()
+    }
+    return nil
+}
+
+// PermissionKeys returns the sorted keys of the role's permissions.
+func (r Role) PermissionKeys() []string {
+    keys := make([]string, 0, len(r.Permissions))
+    for _, permission := range r.Permissions {
+        keys = append(keys, permission.Key)
+    }
+    sort.Strings(keys)
+    return keys
+}
+
+// ToDTO renders the response payload.
+func (r Role) ToDTO() map[string]any {
     return map[string]any{
-        "id":        u.ID,
-        "name":      u.Name,
-        "email":     u.Email,
-        "role":      u.Role,
-        "createdAt": u.CreatedAt,
-        "updatedAt": u.UpdatedAt,
+        "id":          r.ID,
+        "name":        r.Name,
+        "description": r.Description,
+        "system":      r.System,
+        "permissions": r.PermissionKeys(),
+        "createdAt":   r.CreatedAt,
+        "updatedAt":   r.UpdatedAt,
     }
 }
@@ -3,20 +3,97 @@ package identity
 import (
     "context"
     "errors"
+    "fmt"
+    "time"
 
     "gorm.io/gorm"
+    "gorm.io/gorm/clause"
+
+    "github.com/pflow/shared/database"
+    "github.com/pflow/shared/httpx"
+    "github.com/pflow/shared/tenant"
 )
 
 // Repository defines the persistence contract for identity users.
 type Repository interface {
-    List(ctx context.Context, role, search string) ([]User, error)
+    List(ctx context.Context, query httpx.ListQuery) ([]User, httpx.Page, error)
     Create(ctx context.Context, entity *User) error
     Find(ctx context.Context, id string) (*User, error)
     Update(ctx context.Context, id string, updates map[string]any) (*User, error)
     Delete(ctx context.Context, id string) error
+    // Restore undeletes a soft-deleted user, failing with ErrEmailInUse when
+    // another user has taken the email address in the meantime.
+    Restore(ctx context.Context, id string) (*User, error)
+    // SetRoles replaces the roles assigned to a user.
+    SetRoles(ctx context.Context, id string, roles []Role) (*User, error)
+}
+
+var (
+    // ErrUnknownRole is returned when a role name does not exist.
+    ErrUnknownRole = errors.New("unknown role")
+    // ErrUnknownPermission is returned when a permission is not in the catalog.
+    ErrUnknownPermission = errors.New("unknown permission")
+    // ErrSystemRole is returned when changing or deleting a seeded role.
+    ErrSystemRole = errors.New("system roles are shared by every tenant and cannot be changed or deleted")
+    // ErrRoleExists is returned when a role name is already taken in the tenant.
+    ErrRoleExists = errors.New("role already exists")
+    // ErrEmailInUse is returned when restoring a user whose email address
+    // another user of the tenant has.
+    ErrEmailInUse = errors.New("email is already in use")
+)
+
+// RoleRepository persists roles and exposes the permission catalog.
+type RoleRepository interface {
+    ListRoles(ctx context.Context) ([]Role, error)
+    FindRole(ctx context.Context, id string) (*Role, error)
+    // FindRolesByName resolves role names, failing with ErrUnknownRole when
+    // any of them does not exist.
+    FindRolesByName(ctx context.Context, names []string) ([]Role, error)
+    CreateRole(ctx context.Context, role *Role, permissions []string) error
+    // UpdateRole applies updates and, when permissions is non-nil, replaces
+    // the permission set of the role.
+    UpdateRole(ctx context.Context, id string, updates map[string]any, permissions []string) (*Role, error)
+    DeleteRole(ctx context.Context, id string) error
+    ListPermissions(ctx context.Context) ([]Permission, error)
+}
+
+var (
+    // ErrRefreshTokenInvalid is returned for unknown, expired or revoked refresh tokens.
+    ErrRefreshTokenInvalid = errors.New("refresh token is invalid or expired")
+    // ErrRefreshTokenReused is returned when an already rotated refresh token is
+    // presented again; the whole token family is revoked in response.
+    ErrRefreshTokenReused = errors.New("refresh token was already used")
+    // ErrResetTokenInvalid is returned for unknown, expired or used reset tokens.
+    ErrResetTokenInvalid = errors.New("password reset token is invalid or expired")
+)
+
+// CredentialStore persists passwords, lockout state and the server-side
+// refresh and password reset tokens used by the auth endpoints.
+type CredentialStore interface {
+    FindByEmail(ctx context.Context, email string) (*User, error)
+    // RecordFailedLogin counts a failed attempt and locks the account for
+    // lockout once maxAttempts consecutive failures are reached.
+    RecordFailedLogin(ctx context.Context, id string, maxAttempts int, lockout time.Duration) (*User, error)
+    ClearFailedLogins(ctx context.Context, id string) error
+    // SetPassword stores a new hash, lifts any lockout and revokes every
+    // refresh token of the user.
+    SetPassword(ctx context.Context, id, hash string) error
+    CreateRefreshToken(ctx context.Context, token *RefreshToken) error
+    // RotateRefreshToken revokes the token with tokenHash and stores next in
+    // the same family, returning the revoked token.
+    RotateRefreshToken(ctx context.Context, tokenHash string, next *RefreshToken) (*RefreshToken, error)
+    // RevokeRefreshToken ends the session the token belongs to. Unknown tokens
+    // are ignored.
+    RevokeRefreshToken(ctx context.Context, tokenHash string) error
+    CreatePasswordReset(ctx context.Context, reset *PasswordReset) error
+    // ConsumePasswordReset marks the reset as used, together with any other
+    // outstanding reset of the same user.
+    ConsumePasswordReset(ctx context.Context, tokenHash string) (*PasswordReset, error)
 }
 
-// GormRepository persists users to a relational database via GORM.
+// GormRepository persists users to a relational database via GORM. Users and
+// their credentials are scoped to the tenant of the request context; roles and
+// the permission catalog are shared by all tenants.
 type GormRepository struct {
     db *gorm.DB
 }
@@ -26,69 +103,481 @@ func NewGormRepository(db *gorm.DB) *GormRepository {
     return &GormRepository{db: db}
 }
 
-// List returns users optionally filtered by role or search query.
-func (r *GormRepository) List(ctx context.Context, role, search string) ([]User, error) {
-    query := r.db.WithContext(ctx).Model(&User{}).Order("created_at DESC")
-    if role != "" {
-        query = query.Where("role = ?", role)
+// List returns a page of users optionally filtered by role or search query.
+func (r *GormRepository) List(ctx context.Context, query httpx.ListQuery) ([]User, httpx.Page, error) {
+    tx := r.scoped(ctx).Model(&User{})
+    if roles, ok := query.Filters["role"]; ok {
+        filters := make(map[string][]any, len(query.Filters))
+        for column, values := range query.Filters {
+            if column != "role" {
+                filters[column] = values
+            }
+        }
+        query.Filters = filters
+        tx = tx.Where("id IN (?)", r.db.Table("user_roles").
+            Select("user_roles.user_id").
+            Joins("JOIN roles ON roles.id = user_roles.role_id").
+            Where("roles.name IN ?", roles))
     }
-    if search != "" {
-        like := "%" + search + "%"
-        query = query.Where("LOWER(name) LIKE LOWER(?) OR LOWER(email) LIKE LOWER(?)", like, like)
+    users, page, err := database.Paginate(tx, query, User.sortValue)
+    if err != nil {
+        return nil, page, err
     }
+    if err := r.loadRoles(ctx, users); err != nil {
+        return nil, page, err
+    }
+    return users, page, nil
+}
 
-    var users []User
-    if err := query.Find(&users).Error; err != nil {
-        return nil, err
+// loadRoles attaches roles to a page of users without disturbing its order.
+func (r *GormRepository) loadRoles(ctx context.Context, users []User) error {
+    if len(users) == 0 {
+        return nil
+    }
+    ids := make([]string, 0, len(users))
+    for _, entity := range users {
+        ids = append(ids, entity.ID)
+    }
+    var loaded []User
+    if err := r.users(ctx).Unscoped().Select("id").Find(&loaded, "id IN ?", ids).Error; err != nil {
+        return err
     }
-    return users, nil
+    roles := make(map[string][]Role, len(loaded))
+    for _, entity := range loaded {
+        roles[entity.ID] = entity.Roles
+    }
+    for i := range users {
+        users[i].Roles = roles[users[i].ID]
+    }
+    return nil
 }
 
-// Create persists a new user.
+// Create persists a new user together with its role assignments.
 func (r *GormRepository) Create(ctx context.Context, entity *User) error {
-    return r.db.WithContext(ctx).Create(entity).Error
+    entity.TenantID = tenant.ID(ctx)
+    return database.Conn(ctx, r.db).Omit("Roles.*").Create(entity).Error
 }
 
 // Find returns a user by ID.
 func (r *GormRepository) Find(ctx context.Context, id string) (*User, error) {
     var entity User
-    if err := r.db.WithContext(ctx).First(&entity, "id = ?", id).Error; err != nil {
+    if err := r.users(ctx).First(&entity, "id = ?", id).Error; err != nil {
         return nil, err
     }
     return &entity, nil
 }
 
+func (r *GormRepository) users(ctx context.Context) *gorm.DB {
+    return r.scoped(ctx).Preload("Roles.Permissions")
+}
+
+func (r *GormRepository) scoped(ctx context.Context) *gorm.DB {
+    return database.Conn(ctx, r.db).Scopes(database.TenantScope(ctx))
+}
+
 // Update applies changes to an existing user.
 func (r *GormRepository) Update(ctx context.Context, id string, updates map[string]any) (*User, error) {
     var entity User
-    tx := r.db.WithContext(ctx)
-    if err := tx.First(&entity, "id = ?", id).Error; err != nil {
+    if err := r.scoped(ctx).First(&entity, "id = ?", id).Error; err != nil {
         return nil, err
     }
 
-    if err := tx.Model(&entity).Updates(updates).Error; err != nil {
+    if err := database.Conn(ctx, r.db).Model(&entity).Updates(updates).Error; err != nil {
         return nil, err
     }
 
-    if err := tx.First(&entity, "id = ?", id).Error; err != nil {
+    return r.Find(ctx, id)
+}
+
+// Delete soft-deletes a user and signs it out everywhere. The user keeps its
+// role assignments so it can be restored until it is purged.
+func (r *GormRepository) Delete(ctx context.Context, id string) error {
+    return database.Conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+        result := tx.Scopes(database.TenantScope(ctx)).Delete(&User{}, "id = ?", id)
+        if result.Error != nil {
+            return result.Error
+        }
+        if result.RowsAffected == 0 {
+            return gorm.ErrRecordNotFound
+        }
+        return tx.Model(&RefreshToken{}).Where("user_id = ? AND revoked_at IS NULL", id).
+            Update("revoked_at", time.Now()).Error
+    })
+}
+
+// Restore undeletes a soft-deleted user together with its role assignments.
+// Sessions revoked by the deletion stay revoked.
+func (r *GormRepository) Restore(ctx context.Context, id string) (*User, error) {
+    var entity User
+    if err := r.scoped(ctx).Unscoped().Select("id", "email").First(&entity, "id = ?", id).Error; err != nil {
         return nil, err
     }
+    var taken int64
+    if err := r.scoped(ctx).Model(&User{}).Where("email = ? AND id <> ?", entity.Email, id).Count(&taken).Error; err != nil {
+        return nil, err
+    }
+    if taken > 0 {
+        return nil, ErrEmailInUse
+    }
+    if err := database.Restore(ctx, r.db, &User{}, id); err != nil {
+        return nil, err
+    }
+    return r.Find(ctx, id)
+}
+
+// PurgeDeleted hard-deletes, in every tenant, up to limit users deleted before
+// the cutoff together with their role assignments and credentials.
+func (r *GormRepository) PurgeDeleted(ctx context.Context, before time.Time, limit int) (int, error) {
+    return database.PurgeDeleted(ctx, r.db, &User{}, before, limit, func(tx *gorm.DB, ids []string) error {
+        if err := tx.Exec("DELETE FROM user_roles WHERE user_id IN ?", ids).Error; err != nil {
+            return err
+        }
+        for _, model := range []any{&RefreshToken{}, &PasswordReset{}} {
+            if err := tx.Where("user_id IN ?", ids).Delete(model).Error; err != nil {
+                return err
+            }
+        }
+        return nil
+    })
+}
 
+// SetRoles replaces the role assignments of a user.
+func (r *GormRepository) SetRoles(ctx context.Context, id string, roles []Role) (*User, error) {
+    err := database.Conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+        var entity User
+        if err := tx.Scopes(database.TenantScope(ctx)).First(&entity, "id = ?", id).Error; err != nil {
+            return err
+        }
+        return tx.Model(&entity).Omit("Roles.*").Association("Roles").Replace(roles)
+    })
+    if err != nil {
+        return nil, err
+    }
+    return r.Find(ctx, id)
+}
+
+// FindByEmail returns a user by (lower-cased) email address.
+func (r *GormRepository) FindByEmail(ctx context.Context, email string) (*User, error) {
+    var entity User
+    if err := r.users(ctx).First(&entity, "email = ?", email).Error; err != nil {
+        return nil, err
+    }
     return &entity, nil
 }
 
-// Delete removes a user.
-func (r *GormRepository) Delete(ctx context.Context, id string) error {
-    result := r.db.WithContext(ctx).Delete(&User{}, "id = ?", id)
-    if result.Error != nil {
-        return result.Error
+// RecordFailedLogin increments the failure counter under a row lock.
+func (r *GormRepository) RecordFailedLogin(ctx context.Context, id string, maxAttempts int, lockout time.Duration) (*User, error) {
+    var entity User
+    err := database.Conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+        if err := tx.Scopes(database.TenantScope(ctx)).Clauses(clause.Locking{Strength: "UPDATE"}).First(&entity, "id = ?", id).Error; err != nil {
+            return err
+        }
+
+        entity.FailedLogins++
+        if maxAttempts > 0 && entity.FailedLogins >= maxAttempts {
+            lockedUntil := time.Now().Add(lockout)
+            entity.FailedLogins = 0
+            entity.LockedUntil = &lockedUntil
+        }
+        return tx.Model(&User{}).Where("id = ?", id).Updates(map[string]any{
+            "failed_logins": entity.FailedLogins,
+            "locked_until":  entity.LockedUntil,
+        }).Error
+    })
+    if err != nil {
+        return nil, err
+    }
+    return &entity, nil
+}
+
+// ClearFailedLogins resets the failure counter after a successful login.
+func (r *GormRepository) ClearFailedLogins(ctx context.Context, id string) error {
+    return r.scoped(ctx).Model(&User{}).Where("id = ?", id).
+        Updates(map[string]any{"failed_logins": 0, "locked_until": nil}).Error
+}
+
+// SetPassword replaces the password hash and signs the user out everywhere.
+func (r *GormRepository) SetPassword(ctx context.Context, id, hash string) error {
+    now := time.Now()
+    return database.Conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+        result := tx.Scopes(database.TenantScope(ctx)).Model(&User{}).Where("id = ?", id).Updates(map[string]any{
+            "password_hash":       hash,
+            "password_changed_at": now,
+            "failed_logins":       0,
+            "locked_until":        nil,
+        })
+        if result.Error != nil {
+            return result.Error
+        }
+        if result.RowsAffected == 0 {
+            return gorm.ErrRecordNotFound
+        }
+        return tx.Model(&RefreshToken{}).Where("user_id = ? AND revoked_at IS NULL", id).
+            Update("revoked_at", now).Error
+    })
+}
+
+// CreateRefreshToken stores a newly issued refresh token.
+func (r *GormRepository) CreateRefreshToken(ctx context.Context, token *RefreshToken) error {
+    token.TenantID = tenant.ID(ctx)
+    return database.Conn(ctx, r.db).Create(token).Error
+}
+
+// RotateRefreshToken swaps a live refresh token for next. Reuse of a revoked
+// token revokes its whole family so a stolen token cannot outlive the session.
+func (r *GormRepository) RotateRefreshToken(ctx context.Context, tokenHash string, next *RefreshToken) (*RefreshToken, error) {
+    var current RefreshToken
+    now := time.Now()
+    err := database.Conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+        if err := tx.Scopes(database.TenantScope(ctx)).Clauses(clause.Locking{Strength: "UPDATE"}).First(&current, "token_hash = ?", tokenHash).Error; err != nil {
+            if IsNotFound(err) {
+                return ErrRefreshTokenInvalid
+            }
+            return err
+        }
+        if current.RevokedAt != nil {
+            return ErrRefreshTokenReused
+        }
+        if !now.Before(current.ExpiresAt) {
+            return ErrRefreshTokenInvalid
+        }
+
+        next.TenantID = current.TenantID
+        next.UserID = current.UserID
+        next.FamilyID = current.FamilyID
+        if err := tx.Create(next).Error; err != nil {
+            return err
+        }
+        return tx.Model(&current).Updates(map[string]any{"revoked_at": now, "replaced_by": next.ID}).Error
+    })
+    if errors.Is(err, ErrRefreshTokenReused) {
+        if revokeErr := r.revokeFamily(ctx, current.FamilyID, now); revokeErr != nil {
+            return nil, revokeErr
+        }
+    }
+    if err != nil {
+        return nil, err
+    }
+    return &current, nil
+}
+
+// RevokeRefreshToken revokes the family of the given token.
+func (r *GormRepository) RevokeRefreshToken(ctx context.Context, tokenHash string) error {
+    var token RefreshToken
+    if err := r.scoped(ctx).First(&token, "token_hash = ?", tokenHash).Error; err != nil {
+        if IsNotFound(err) {
+            return nil
+        }
+        return err
+    }
+    return r.revokeFamily(ctx, token.FamilyID, time.Now())
+}
+
+func (r *GormRepository) revokeFamily(ctx context.Context, familyID string, at time.Time) error {
+    return database.Conn(ctx, r.db).Model(&RefreshToken{}).
+        Where("family_id = ? AND revoked_at IS NULL", familyID).
+        Update("revoked_at", at).Error
+}
+
+// CreatePasswordReset stores a newly issued reset token.
+func (r *GormRepository) CreatePasswordReset(ctx context.Context, reset *PasswordReset) error {
+    reset.TenantID = tenant.ID(ctx)
+    return database.Conn(ctx, r.db).Create(reset).Error
+}
+
+// ConsumePasswordReset redeems a reset token exactly once.
+func (r *GormRepository) ConsumePasswordReset(ctx context.Context, tokenHash string) (*PasswordReset, error) {
+    var reset PasswordReset
+    now := time.Now()
+    err := database.Conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+        if err := tx.Scopes(database.TenantScope(ctx)).Clauses(clause.Locking{Strength: "UPDATE"}).First(&reset, "token_hash = ?", tokenHash).Error; err != nil {
+            if IsNotFound(err) {
+                return ErrResetTokenInvalid
+            }
+            return err
+        }
+        if reset.UsedAt != nil || !now.Before(reset.ExpiresAt) {
+            return ErrResetTokenInvalid
+        }
+        return tx.Model(&PasswordReset{}).Where("user_id = ? AND used_at IS NULL", reset.UserID).
+            Update("used_at", now).Error
+    })
+    if err != nil {
+        return nil, err
+    }
+    return &reset, nil
+}
+
+// roleScope restricts a role query to the roles of the tenant of ctx and the
+// system roles every tenant shares.
+func roleScope(ctx context.Context) func(*gorm.DB) *gorm.DB {
+    id := tenant.ID(ctx)
+    return func(tx *gorm.DB) *gorm.DB {
+        return tx.Where("(roles.tenant_id = ? OR roles.system = ?)", id, true)
+    }
+}
+
+// ListRoles returns the roles of the current tenant and the system roles
+// ordered by name.
+func (r *GormRepository) ListRoles(ctx context.Context) ([]Role, error) {
+    var roles []Role
+    if err := database.Conn(ctx, r.db).Scopes(roleScope(ctx)).Preload("Permissions").Order("name").Find(&roles).Error; err != nil {
+        return nil, err
     }
-    if result.RowsAffected == 0 {
-        return gorm.ErrRecordNotFound
+    return roles, nil
+}
+
+// FindRole returns a role of the current tenant, or a system role, by ID.
+func (r *GormRepository) FindRole(ctx context.Context, id string) (*Role, error) {
+    var role Role
+    if err := database.Conn(ctx, r.db).Scopes(roleScope(ctx)).Preload("Permissions").First(&role, "id = ?", id).Error; err != nil {
+        return nil, err
+    }
+    return &role, nil
+}
+
+// FindRolesByName returns the roles with the given names among the roles of
+// the current tenant and the system roles.
+func (r *GormRepository) FindRolesByName(ctx context.Context, names []string) ([]Role, error) {
+    roles := []Role{}
+    if len(names) == 0 {
+        return roles, nil
+    }
+    if err := database.Conn(ctx, r.db).Scopes(roleScope(ctx)).Preload("Permissions").Where("name IN ?", names).Find(&roles).Error; err != nil {
+        return nil, err
+    }
+    found := make(map[string]struct{}, len(roles))
+    for _, role := range roles {
+        found[role.Name] = struct{}{}
+    }
+    for _, name := range names {
+        if _, ok := found[name]; !ok {
+            return nil, fmt.Errorf("%w %q", ErrUnknownRole, name)
+        }
+    }
+    return roles, nil
+}
+
+// CreateRole persists a new role of the current tenant with the given
+// permission keys.
+func (r *GormRepository) CreateRole(ctx context.Context, role *Role, permissions []string) error {
+    role.TenantID = tenant.ID(ctx)
+    role.System = false
+    return database.Conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+        if err := ensureRoleNameFree(ctx, tx, role.Name, ""); err != nil {
+            return err
+        }
+        resolved, err := findPermissions(tx, permissions)
+        if err != nil {
+            return err
+        }
+        role.Permissions = resolved
+        return tx.Omit("Permissions.*").Create(role).Error
+    })
+}
+
+// UpdateRole applies changes to a role of the current tenant. System roles are
+// shared by every tenant and cannot be changed.
+func (r *GormRepository) UpdateRole(ctx context.Context, id string, updates map[string]any, permissions []string) (*Role, error) {
+    err := database.Conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+        var role Role
+        if err := tx.Scopes(roleScope(ctx)).First(&role, "id = ?", id).Error; err != nil {
+            return err
+        }
+        if role.System {
+            return ErrSystemRole
+        }
+        if name, ok := updates["name"].(string); ok && name != role.Name {
+            if err := ensureRoleNameFree(ctx, tx, name, role.ID); err != nil {
+                return err
+            }
+        }
+        if len(updates) > 0 {
+            if err := tx.Model(&role).Updates(updates).Error; err != nil {
+                return err
+            }
+        }
+        if permissions == nil {
+            return nil
+        }
+        resolved, err := findPermissions(tx, permissions)
+        if err != nil {
+            return err
+        }
+        return tx.Model(&role).Omit("Permissions.*").Association("Permissions").Replace(resolved)
+    })
+    if err != nil {
+        return nil, err
+    }
+    return r.FindRole(ctx, id)
+}
+
+// DeleteRole removes a role of the current tenant, its permission set and its
+// user assignments.
+func (r *GormRepository) DeleteRole(ctx context.Context, id string) error {
+    return database.Conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+        var role Role
+        if err := tx.Scopes(roleScope(ctx)).First(&role, "id = ?", id).Error; err != nil {
+            return err
+        }
+        if role.System {
+            return ErrSystemRole
+        }
+        if err := tx.Exec("DELETE FROM user_roles WHERE role_id = ?", id).Error; err != nil {
+            return err
+        }
+        if err := tx.Exec("DELETE FROM role_permissions WHERE role_id = ?", id).Error; err != nil {
+            return err
+        }
+        return tx.Delete(&role).Error
+    })
+}
+
+// ensureRoleNameFree fails with ErrRoleExists when the current tenant, or the
+// system roles, already have a role other than exceptID named name.
+func ensureRoleNameFree(ctx context.Context, tx *gorm.DB, name, exceptID string) error {
+    var count int64
+    err := tx.Model(&Role{}).Scopes(roleScope(ctx)).
+        Where("name = ? AND id <> ?", name, exceptID).
+        Count(&count).Error
+    if err != nil {
+        return err
+    }
+    if count > 0 {
+        return fmt.Errorf("%w %q", ErrRoleExists, name)
     }
     return nil
 }
 
+// ListPermissions returns the permission catalog ordered by key.
+func (r *GormRepository) ListPermissions(ctx context.Context) ([]Permission, error) {
+    var permissions []Permission
+    if err := database.Conn(ctx, r.db).Order("key").Find(&permissions).Error; err != nil {
+        return nil, err
+    }
+    return permissions, nil
+}
+
+func findPermissions(tx *gorm.DB, keys []string) ([]Permission, error) {
+    permissions := []Permission{}
+    if len(keys) == 0 {
+        return permissions, nil
+    }
+    if err := tx.Where("key IN ?", keys).Find(&permissions).Error; err != nil {
+        return nil, err
+    }
+    found := make(map[string]struct{}, len(permissions))
+    for _, permission := range permissions {
+        found[permission.Key] = struct{}{}
+    }
+    for _, key := range keys {
+        if _, ok := found[key]; !ok {
+            return nil, fmt.Errorf("%w %q", ErrUnknownPermission, key)
+        }
+    }
+    return permissions, nil
+}
+
 // IsNotFound indicates a missing record error.
 func IsNotFound(err error) bool {
     return errors.Is(err, gorm.ErrRecordNotFound)
@@ -0,0 +1,213 @@
+package identity
+
+import (
+    "context"
+    "errors"
+    "net/http"
+    "strings"
+
+    "github.com/go-chi/chi/v5"
+
+    "github.com/pflow/shared/audit"
+    "github.com/pflow/shared/auth"
+    "github.com/pflow/shared/httpx"
+)
+
+// MountRoles registers the role management routes and the permission catalog
+// under basePath (default "/roles"). It requires WithRoles.
+func (h *Handler) MountRoles(router chi.Router, basePath string) {
+    if h.roles == nil {
+        return
+    }
+    path := strings.TrimSpace(basePath)
+    if path == "" {
+        path = "/roles"
+    }
+
+    router.Route(path, func(r chi.Router) {
+        view := r.With(h.authz.Require(auth.PermissionRoleView))
+        manage := r.With(h.authz.Require(auth.PermissionRoleManage))
+        view.Get("/", h.listRoles)
+        manage.Post("/", h.createRole)
+        view.Get("/permissions", h.listPermissions)
+        r.Route("/{id}", func(r chi.Router) {
+            r.With(h.authz.Require(auth.PermissionRoleView)).Get("/", h.getRole)
+            r.With(h.authz.Require(auth.PermissionRoleManage)).Put("/", h.updateRole)
+            r.With(h.authz.Require(auth.PermissionRoleManage)).Delete("/", h.deleteRole)
+        })
+    })
+}
+
+type createRoleRequest struct {
+    Name        string   `json:"name"`
+    Description string   `json:"description"`
+    Permissions []string `json:"permissions"`
+}
+
+type updateRoleRequest struct {
+    Name        *string   `json:"name"`
+    Description *string   `json:"description"`
+    Permissions *[]string `json:"permissions"`
+}
+
+func (h *Handler) listRoles(w http.ResponseWriter, r *http.Request) {
+    roles, err := h.roles.ListRoles(r.Context())
+    if err != nil {
+        httpx.Error(w, http.StatusInternalServerError, err.Error())
+        return
+    }
+
+    items := make([]map[string]any, 0, len(roles))
+    for _, role := range roles {
+        items = append(items, role.ToDTO())
+    }
+
+    httpx.JSON(w, http.StatusOK, map[string]any{"data": items})
+}
+
+func (h *Handler) listPermissions(w http.ResponseWriter, r *http.Request) {
+    permissions, err := h.roles.ListPermissions(r.Context())
+    if err != nil {
+        httpx.Error(w, http.StatusInternalServerError, err.Error())
+        return
+    }
+
+    items := make([]map[string]any, 0, len(permissions))
+    for _, permission := range permissions {
+        items = append(items, permission.ToDTO())
+    }
+
+    httpx.JSON(w, http.StatusOK, map[string]any{"data": items})
+}
+
+func (h *Handler) createRole(w http.ResponseWriter, r *http.Request) {
+    var payload createRoleRequest
+    if err := decodeJSON(r, &payload); err != nil {
+        httpx.Error(w, http.StatusBadRequest, err.Error())
+        return
+    }
+
+    name := strings.TrimSpace(payload.Name)
+    if name == "" {
+        httpx.Error(w, http.StatusBadRequest, "name is required")
+        return
+    }
+
+    role := &Role{Name: name, Description: strings.TrimSpace(payload.Description)}
+    err := h.events.Transaction(r.Context(), func(ctx context.Context) error {
+        if err := h.roles.CreateRole(ctx, role, payload.Permissions); err != nil {
+            return err
+        }
+        return h.events.Emit(ctx, EventRoleCreated, role.ID, role.ToDTO())
+    })
+    if err != nil {
+        httpx.Error(w, roleErrorStatus(err), err.Error())
+        return
+    }
+    h.audit.Record(r.Context(), audit.Change{Action: EventRoleCreated, ResourceID: role.ID, After: role.ToDTO()})
+
+    httpx.JSON(w, http.StatusCreated, map[string]any{"data": role.ToDTO()})
+}
+
+func (h *Handler) getRole(w http.ResponseWriter, r *http.Request) {
+    role, err := h.roles.FindRole(r.Context(), chi.URLParam(r, "id"))
+    if err != nil {
+        httpx.Error(w, roleErrorStatus(err), roleErrorMessage(err))
+        return
+    }
+
+    httpx.JSON(w, http.StatusOK, map[string]any{"data": role.ToDTO()})
+}
+
+func (h *Handler) updateRole(w http.ResponseWriter, r *http.Request) {
+    var payload updateRoleRequest
+    if err := decodeJSON(r, &payload); err != nil {
+        httpx.Error(w, http.StatusBadRequest, err.Error())
+        return
+    }
+
+    updates := make(map[string]any)
+    if payload.Name != nil {
+        name := strings.TrimSpace(*payload.Name)
+        if name == "" {
+            httpx.Error(w, http.StatusBadRequest, "name cannot be empty")
+            return
+        }
+        updates["name"] = name
+    }
+    if payload.Description != nil {
+        updates["description"] = strings.TrimSpace(*payload.Description)
+    }
+
+    var permissions []string
+    if payload.Permissions != nil {
+        permissions = append([]string{}, *payload.Permissions...)
+    }
+    if len(updates) == 0 && permissions == nil {
+        httpx.Error(w, http.StatusBadRequest, "no updates provided")
+        return
+    }
+
+    before, err := h.roles.FindRole(r.Context(), chi.URLParam(r, "id"))
+    if err != nil {
+        httpx.Error(w, roleErrorStatus(err), roleErrorMessage(err))
+        return
+    }
+    var role *Role
+    err = h.events.Transaction(r.Context(), func(ctx context.Context) error {
+        var err error
+        if role, err = h.roles.UpdateRole(ctx, before.ID, updates, permissions); err != nil {
+            return err
+        }
+        return h.events.Emit(ctx, EventRoleUpdated, role.ID, role.ToDTO())
+    })
+    if err != nil {
+        httpx.Error(w, roleErrorStatus(err), roleErrorMessage(err))
+        return
+    }
+    h.audit.Record(r.Context(), audit.Change{Action: EventRoleUpdated, ResourceID: role.ID, Before: before.ToDTO(), After: role.ToDTO()})
+
+    httpx.JSON(w, http.StatusOK, map[string]any{"data": role.ToDTO()})
+}
+
+func (h *Handler) deleteRole(w http.ResponseWriter, r *http.Request) {
+    id := chi.URLParam(r, "id")
+    before, err := h.roles.FindRole(r.Context(), id)
+    if err != nil {
+        httpx.Error(w, roleErrorStatus(err), roleErrorMessage(err))
+        return
+    }
+    err = h.events.Transaction(r.Context(), func(ctx context.Context) error {
+        if err := h.roles.DeleteRole(ctx, id); err != nil {
+            return err
+        }
+        return h.events.Emit(ctx, EventRoleDeleted, id, map[string]any{"id": id})
+    })
+    if err != nil {
+        httpx.Error(w, roleErrorStatus(err), roleErrorMessage(err))
+        return
+    }
+    h.audit.Record(r.Context(), audit.Change{Action: EventRoleDeleted, ResourceID: id, Before: before.ToDTO()})
+
+    w.WriteHeader(http.StatusNoContent)
+}
+
+func roleErrorStatus(err error) int {
+    switch {
+    case IsNotFound(err):
+        return http.StatusNotFound
+    case errors.Is(err, ErrUnknownPermission):
+        return http.StatusBadRequest
+    case errors.Is(err, ErrSystemRole), errors.Is(err, ErrRoleExists):
+        return http.StatusConflict
+    default:
+        return http.StatusInternalServerError
+    }
+}
+
+func roleErrorMessage(err error) string {
+    if IsNotFound(err) {
+        return "role not found"
+    }
+    return err.Error()
+}
@@ -0,0 +1,137 @@
+package identity
+
+import (
+    "context"
+    "net/http"
+    "testing"
+    "time"
+
+    "github.com/go-chi/chi/v5"
+
+    "github.com/pflow/shared/auth"
+)
+
+// newAuthorizedTestClient serves the identity routes behind the JWT
+// middleware with authorization enabled, seeded with an administrator.
+func newAuthorizedTestClient(t *testing.T) (authTestClient, map[string]string) {
+    t.Helper()
+    secret := []byte("identity-test-secret")
+    signer, err := auth.NewSigner(secret, auth.SignerOptions{TTL: time.Minute})
+    if err != nil {
+        t.Fatalf("signer: %v", err)
+    }
+    verifier, err := auth.NewVerifier(context.Background(), auth.Options{HMACSecret: secret})
+    if err != nil {
+        t.Fatalf("verifier: %v", err)
+    }
+
+    store := newMemoryStore()
+    roles, err := store.FindRolesByName(context.Background(), []string{AdminRole})
+    if err != nil {
+        t.Fatalf("admin role: %v", err)
+    }
+    admin := User{Name: "Administrator", Email: "admin@example.com", Roles: roles}
+    if _, err := EnsureUser(context.Background(), store, store, admin, "bootstrap secret"); err != nil {
+        t.Fatalf("bootstrap: %v", err)
+    }
+
+    handler := NewHandler(store, WithAuthentication(store, signer), WithRoles(store), WithAuthorization())
+    router := chi.NewRouter()
+    router.Use(auth.Middleware(verifier, auth.PublicPaths(AuthPublicPaths("")...)))
+    handler.Mount(router, "")
+    handler.MountAuth(router, "")
+    handler.MountRoles(router, "")
+
+    client := authTestClient{t: t, router: router}
+    return client, client.login("admin@example.com", "bootstrap secret")
+}
+
+func (c authTestClient) login(email, password string) map[string]string {
+    c.t.Helper()
+    code, session := c.post("/auth/login", map[string]any{"email": email, "password": password}, nil)
+    if code != http.StatusOK {
+        c.t.Fatalf("login %s: %d %v", email, code, session)
+    }
+    return map[string]string{"Authorization": "Bearer " + session["accessToken"].(string)}
+}
+
+func TestRolesGrantPermissionsThroughAccessTokens(t *testing.T) {
+    client, admin := newAuthorizedTestClient(t)
+
+    if code, _ := client.post("/roles", map[string]any{"name": "auditor", "permissions": []string{"user:audit"}}, admin); code != http.StatusBadRequest {
+        t.Fatalf("expected unknown permission to be rejected, got %d", code)
+    }
+    code, role := client.post("/roles", map[string]any{"name": "auditor", "permissions": []string{auth.PermissionUserView}}, admin)
+    if code != http.StatusCreated {
+        t.Fatalf("create role: %d %v", code, role)
+    }
+
+    if code, _ := client.post("/users", map[string]any{"name": "Bob", "email": "bob@example.com", "roles": []string{"ghost"}, "password": "correct horse"}, admin); code != http.StatusBadRequest {
+        t.Fatalf("expected unknown role to be rejected, got %d", code)
+    }
+    code, created := client.post("/users", map[string]any{"name": "Bob", "email": "bob@example.com", "roles": []string{"auditor"}, "password": "correct horse"}, admin)
+    if code != http.StatusCreated {
+        t.Fatalf("create user: %d %v", code, created)
+    }
+
+    auditor := client.login("bob@example.com", "correct horse")
+    if code, _ := client.do(http.MethodGet, "/users/"+created["id"].(string), nil, auditor); code != http.StatusOK {
+        t.Fatalf("expected user:view to allow reading users, got %d", code)
+    }
+    if code, _ := client.post("/users", map[string]any{"name": "Eve", "email": "eve@example.com"}, auditor); code != http.StatusForbidden {
+        t.Fatalf("expected missing user:manage to be forbidden, got %d", code)
+    }
+    if code, _ := client.do(http.MethodGet, "/roles", nil, nil); code != http.StatusUnauthorized {
+        t.Fatalf("expected anonymous request to be rejected, got %d", code)
+    }
+
+    // Widening the role takes effect with the next token.
+    if code, _ := client.do(http.MethodPut, "/roles/"+role["id"].(string), map[string]any{"permissions": []string{"user:*"}}, admin); code != http.StatusOK {
+        t.Fatalf("update role: %d", code)
+    }
+    auditor = client.login("bob@example.com", "correct horse")
+    if code, _ := client.post("/users", map[string]any{"name": "Eve", "email": "eve@example.com"}, auditor); code != http.StatusCreated {
+        t.Fatalf("expected user:* to allow creating users, got %d", code)
+    }
+}
+
+func TestSystemRolesCannotBeRenamedOrDeleted(t *testing.T) {
+    client, admin := newAuthorizedTestClient(t)
+
+    if code, _ := client.do(http.MethodPut, "/roles/role-admin", map[string]any{"name": "root"}, admin); code != http.StatusConflict {
+        t.Fatalf("expected rename of system role to conflict, got %d", code)
+    }
+    if code, _ := client.do(http.MethodDelete, "/roles/role-admin", nil, admin); code != http.StatusConflict {
+        t.Fatalf("expected delete of system role to conflict, got %d", code)
+    }
+    if code, _ := client.do(http.MethodDelete, "/roles/missing", nil, admin); code != http.StatusNotFound {
+        t.Fatalf("expected unknown role to be reported, got %d", code)
+    }
+}
+
+func TestAssigningRolesRequiresRoleManage(t *testing.T) {
+    client, admin := newAuthorizedTestClient(t)
+
+    if code, role := client.post("/roles", map[string]any{"name": "user-admin", "permissions": []string{auth.PermissionUserView, auth.PermissionUserManage}}, admin); code != http.StatusCreated {
+        t.Fatalf("create role: %d %v", code, role)
+    }
+    code, created := client.post("/users", map[string]any{"name": "Mallory", "email": "mallory@example.com", "roles": []string{"user-admin"}, "password": "correct horse"}, admin)
+    if code != http.StatusCreated {
+        t.Fatalf("create user: %d %v", code, created)
+    }
+    self := "/users/" + created["id"].(string)
+    mallory := client.login("mallory@example.com", "correct horse")
+
+    if code, _ := client.do(http.MethodPut, self, map[string]any{"roles": []string{AdminRole}}, mallory); code != http.StatusForbidden {
+        t.Fatalf("expected granting the admin role without role:manage to be forbidden, got %d", code)
+    }
+    if code, _ := client.post("/users", map[string]any{"name": "Eve", "email": "eve@example.com", "roles": []string{AdminRole}}, mallory); code != http.StatusForbidden {
+        t.Fatalf("expected creating an admin without role:manage to be forbidden, got %d", code)
+    }
+    if code, _ := client.do(http.MethodPut, self, map[string]any{"name": "Mal", "roles": []string{"user-admin"}}, mallory); code != http.StatusOK {
+        t.Fatalf("expected an update keeping the same roles to pass, got %d", code)
+    }
+    if code, _ := client.post("/users", map[string]any{"name": "Eve", "email": "eve@example.com"}, mallory); code != http.StatusCreated {
+        t.Fatalf("expected creating a user without roles to pass, got %d", code)
+    }
+}
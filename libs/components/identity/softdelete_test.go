@@ -0,0 +1,55 @@
+package identity
+
+import (
+    "context"
+    "net/http"
+    "testing"
+    "time"
+
+    "github.com/go-chi/chi/v5"
+)
+
+func TestDeletedUsersFreeTheirEmailUntilRestored(t *testing.T) {
+    repo := newTenantTestRepository(t)
+    router := chi.NewRouter()
+    NewHandler(repo, WithRoles(repo)).Mount(router, "")
+    client := authTestClient{t: t, router: router}
+
+    code, first := client.post("/users", map[string]any{"name": "Alice", "email": "alice@example.com", "roles": []string{AdminRole}}, nil)
+    if code != http.StatusCreated {
+        t.Fatalf("create user: %d %v", code, first)
+    }
+    if code, _ := client.do(http.MethodDelete, "/users/"+first["id"].(string), nil, nil); code != http.StatusNoContent {
+        t.Fatalf("delete user: %d", code)
+    }
+    code, second := client.post("/users", map[string]any{"name": "Alice Again", "email": "alice@example.com"}, nil)
+    if code != http.StatusCreated {
+        t.Fatalf("expected the email of a deleted user to be reusable, got %d %v", code, second)
+    }
+
+    if code, _ := client.do(http.MethodPost, "/users/"+first["id"].(string)+"/restore", nil, nil); code != http.StatusConflict {
+        t.Fatalf("expected restoring onto a taken email to conflict, got %d", code)
+    }
+    if code, _ := client.do(http.MethodDelete, "/users/"+second["id"].(string), nil, nil); code != http.StatusNoContent {
+        t.Fatalf("delete second user: %d", code)
+    }
+    code, restored := client.do(http.MethodPost, "/users/"+first["id"].(string)+"/restore", nil, nil)
+    roles, _ := restored["roles"].([]any)
+    if code != http.StatusOK || len(roles) != 1 || roles[0] != AdminRole {
+        t.Fatalf("expected the restored user to keep its roles, got %d %v", code, restored)
+    }
+
+    if code, listed := client.do(http.MethodGet, "/users", nil, nil); code != http.StatusOK || listed["total"] != float64(1) {
+        t.Fatalf("expected deleted users to be hidden, got %d %v", code, listed)
+    }
+    if code, listed := client.do(http.MethodGet, "/users?includeDeleted=true", nil, nil); code != http.StatusOK || listed["total"] != float64(2) {
+        t.Fatalf("expected includeDeleted to list both users, got %d %v", code, listed)
+    }
+
+    if purged, err := repo.PurgeDeleted(context.Background(), time.Now().Add(time.Minute), 10); err != nil || purged != 1 {
+        t.Fatalf("expected the deleted user to be purged, purged %d (%v)", purged, err)
+    }
+    if code, _ := client.do(http.MethodPost, "/users/"+second["id"].(string)+"/restore", nil, nil); code != http.StatusNotFound {
+        t.Fatalf("expected a purged user to be gone, got %d", code)
+    }
+}
@@ -0,0 +1,116 @@
+package identity
+
+import (
+    "context"
+    "errors"
+    "testing"
+
+    "github.com/pflow/components/internal/dbtest"
+    "github.com/pflow/shared/auth"
+    "github.com/pflow/shared/httpx"
+    "github.com/pflow/shared/tenant"
+)
+
+func newTenantTestRepository(t *testing.T) *GormRepository {
+    t.Helper()
+    return NewGormRepository(dbtest.Open(t, Migrate))
+}
+
+func TestEmailsAreUniquePerTenant(t *testing.T) {
+    repo := newTenantTestRepository(t)
+    acme := tenant.WithID(context.Background(), "acme")
+    globex := tenant.WithID(context.Background(), "globex")
+
+    if err := repo.Create(acme, &User{Name: "Alice", Email: "alice@example.com"}); err != nil {
+        t.Fatalf("create acme user: %v", err)
+    }
+    if err := repo.Create(globex, &User{Name: "Alice", Email: "alice@example.com"}); err != nil {
+        t.Fatalf("expected the same email to be accepted in another tenant: %v", err)
+    }
+    if err := repo.Create(acme, &User{Name: "Alice again", Email: "alice@example.com"}); err == nil {
+        t.Fatal("expected a duplicate email within a tenant to be rejected")
+    }
+
+    user, err := repo.FindByEmail(globex, "alice@example.com")
+    if err != nil {
+        t.Fatalf("find by email: %v", err)
+    }
+    if user.TenantID != "globex" || user.Principal().TenantID != "globex" {
+        t.Fatalf("expected globex user, got tenant %q", user.TenantID)
+    }
+}
+
+func TestUsersAreInvisibleToOtherTenants(t *testing.T) {
+    repo := newTenantTestRepository(t)
+    acme := tenant.WithID(context.Background(), "acme")
+    globex := tenant.WithID(context.Background(), "globex")
+
+    user := &User{Name: "Alice", Email: "alice@example.com"}
+    if err := repo.Create(acme, user); err != nil {
+        t.Fatalf("create: %v", err)
+    }
+
+    if _, err := repo.Find(globex, user.ID); !IsNotFound(err) {
+        t.Fatalf("expected find from another tenant to miss, got %v", err)
+    }
+    if _, err := repo.FindByEmail(globex, user.Email); !IsNotFound(err) {
+        t.Fatalf("expected login lookup from another tenant to miss, got %v", err)
+    }
+    if _, err := repo.Update(globex, user.ID, map[string]any{"name": "hijacked"}); !IsNotFound(err) {
+        t.Fatalf("expected update from another tenant to miss, got %v", err)
+    }
+    if err := repo.SetPassword(globex, user.ID, "hash"); !IsNotFound(err) {
+        t.Fatalf("expected password change from another tenant to miss, got %v", err)
+    }
+    if err := repo.Delete(globex, user.ID); !IsNotFound(err) {
+        t.Fatalf("expected delete from another tenant to miss, got %v", err)
+    }
+
+    items, page, err := repo.List(globex, httpx.ListQuery{Limit: 10, SortColumn: "created_at", Filters: map[string][]any{}})
+    if err != nil || len(items) != 0 || page.Total != 0 {
+        t.Fatalf("expected other tenant to list nothing, got %d items (total %d, err %v)", len(items), page.Total, err)
+    }
+}
+
+func TestRolesAreScopedToTheirTenant(t *testing.T) {
+    repo := newTenantTestRepository(t)
+    acme := tenant.WithID(context.Background(), "acme")
+    globex := tenant.WithID(context.Background(), "globex")
+
+    role := &Role{Name: "agent"}
+    if err := repo.CreateRole(acme, role, []string{"ticket:view"}); err != nil {
+        t.Fatalf("create role: %v", err)
+    }
+    if err := repo.CreateRole(globex, &Role{Name: "agent"}, nil); err != nil {
+        t.Fatalf("expected the same role name to be accepted in another tenant: %v", err)
+    }
+    if err := repo.CreateRole(acme, &Role{Name: AdminRole}, nil); !errors.Is(err, ErrRoleExists) {
+        t.Fatalf("expected a tenant role named like a system role to be rejected, got %v", err)
+    }
+
+    if _, err := repo.FindRole(globex, role.ID); !IsNotFound(err) {
+        t.Fatalf("expected find from another tenant to miss, got %v", err)
+    }
+    if _, err := repo.UpdateRole(globex, role.ID, nil, []string{auth.PermissionAll}); !IsNotFound(err) {
+        t.Fatalf("expected update from another tenant to miss, got %v", err)
+    }
+    if err := repo.DeleteRole(globex, role.ID); !IsNotFound(err) {
+        t.Fatalf("expected delete from another tenant to miss, got %v", err)
+    }
+    roles, err := repo.FindRolesByName(globex, []string{"agent", AdminRole})
+    if err != nil || len(roles) != 2 || roles[0].ID == role.ID || roles[1].ID == role.ID {
+        t.Fatalf("expected globex to resolve its own role and the system role, got %v (%v)", roles, err)
+    }
+    listed, err := repo.ListRoles(globex)
+    if err != nil || len(listed) != 2 {
+        t.Fatalf("expected globex to list its role and the system role, got %v (%v)", listed, err)
+    }
+
+    admin, err := repo.FindRolesByName(acme, []string{AdminRole})
+    if err != nil {
+        t.Fatalf("admin role: %v", err)
+    }
+    if _, err := repo.UpdateRole(acme, admin[0].ID, nil, []string{"ticket:view"}); !errors.Is(err, ErrSystemRole) {
+        t.Fatalf("expected the shared admin role to be read-only, got %v", err)
+    }
+}
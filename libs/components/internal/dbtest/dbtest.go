@@ -0,0 +1,32 @@
+// Package dbtest opens the in-memory SQLite databases the component tests run
+// against.
+package dbtest
+
+import (
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Open returns a fresh in-memory database migrated with migrate. The pool is
+// limited to one connection because every connection to an in-memory database
+// opens a database of its own.
+func Open(t testing.TB, migrate func(db *gorm.DB) error) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("sqlite pool: %v", err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+	if err := migrate(db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return db
+}
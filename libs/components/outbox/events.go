@@ -0,0 +1,68 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+
+	"github.com/pflow/shared/database"
+	"github.com/pflow/shared/mq"
+)
+
+// EventStore records domain events as messages of the Events topic, in the
+// transaction of the change they announce, so an event is never lost or
+// published for a change that was rolled back. A relay hands them to the
+// event topic afterwards. A nil *EventStore discards events and runs
+// transactions as plain calls, so components can emit unconditionally.
+type EventStore struct {
+	db     *gorm.DB
+	source string
+}
+
+// NewEventStore constructs an event store writing the events of source, e.g.
+// "/pflow/form", to db.
+func NewEventStore(db *gorm.DB, source string) *EventStore {
+	return &EventStore{db: db, source: source}
+}
+
+// Transaction runs fn in a transaction that the repositories and the events
+// emitted with the context passed to fn join.
+func (s *EventStore) Transaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if s == nil {
+		return fn(ctx)
+	}
+	return database.Transaction(ctx, s.db, fn)
+}
+
+// Emit records an event about subject in the tenant of ctx.
+func (s *EventStore) Emit(ctx context.Context, eventType, subject string, data any) error {
+	if s == nil {
+		return nil
+	}
+	event, err := mq.NewEvent(ctx, s.source, eventType, subject, data)
+	if err != nil {
+		return fmt.Errorf("encode %s event: %w", eventType, err)
+	}
+	return mq.PublishEvent(ctx, s, event)
+}
+
+// Publish records an encoded event, so the store can stand in for the
+// publisher of components that encode their own events.
+func (s *EventStore) Publish(ctx context.Context, key string, value []byte, headers map[string]string) error {
+	if s == nil {
+		return nil
+	}
+	stored := make(datatypes.JSONMap, len(headers))
+	for name, header := range headers {
+		stored[name] = header
+	}
+	return database.Conn(ctx, s.db).Create(&Message{
+		AggregateID: key,
+		Topic:       Events,
+		Key:         key,
+		Payload:     value,
+		Headers:     stored,
+	}).Error
+}
@@ -0,0 +1,82 @@
+// Package outbox implements the transactional outbox the components announce
+// their changes through. Messages are written in the transaction of the change
+// they announce, so none is lost or sent for a change that was rolled back, and
+// a Relay publishes them afterwards, at least once.
+package outbox
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+const (
+	// Pending marks a message that still has to be published.
+	Pending = "pending"
+	// Sent marks a message that was delivered to its publisher.
+	Sent = "sent"
+)
+
+// Events is the topic of the messages carrying domain events.
+const Events = "events"
+
+// Message is a broker message persisted in the same transaction as the state
+// change it announces. Topic names the route the relay delivers it to.
+type Message struct {
+	ID            string            `json:"id" gorm:"type:uuid;primaryKey"`
+	AggregateID   string            `json:"aggregateId" gorm:"type:uuid;index"`
+	Topic         string            `json:"topic" gorm:"type:varchar(32);not null;index:idx_outbox_stream,priority:1"`
+	Key           string            `json:"key" gorm:"type:varchar(128);index:idx_outbox_stream,priority:2"`
+	Payload       []byte            `json:"payload" gorm:"type:bytea"`
+	Headers       datatypes.JSONMap `json:"headers" gorm:"type:jsonb"`
+	Status        string            `json:"status" gorm:"not null;index:idx_outbox_due,priority:1"`
+	Attempts      int               `json:"attempts"`
+	NextAttemptAt time.Time         `json:"nextAttemptAt" gorm:"index:idx_outbox_due,priority:2"`
+	LastError     string            `json:"lastError"`
+	CreatedAt     time.Time         `json:"createdAt"`
+	SentAt        *time.Time        `json:"sentAt" gorm:"index"`
+}
+
+// TableName keeps the table the ticket service created before the outbox was
+// shared.
+func (Message) TableName() string {
+	return "outbox_messages"
+}
+
+// BeforeCreate assigns defaults on outbox messages.
+func (m *Message) BeforeCreate(tx *gorm.DB) error {
+	if m.ID == "" {
+		m.ID = uuid.NewString()
+	}
+	if m.Status == "" {
+		m.Status = Pending
+	}
+	if m.NextAttemptAt.IsZero() {
+		m.NextAttemptAt = time.Now()
+	}
+	return nil
+}
+
+// stream identifies the messages that are published in the order they were
+// written: those of one topic and key.
+type stream struct {
+	topic string
+	key   string
+}
+
+func (m Message) stream() stream {
+	return stream{topic: m.Topic, key: m.Key}
+}
+
+// headerMap converts the stored headers into broker headers.
+func (m Message) headerMap() map[string]string {
+	headers := make(map[string]string, len(m.Headers))
+	for key, value := range m.Headers {
+		if s, ok := value.(string); ok {
+			headers[key] = s
+		}
+	}
+	return headers
+}
@@ -0,0 +1,239 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/pflow/components/internal/dbtest"
+)
+
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	return dbtest.Open(t, func(db *gorm.DB) error {
+		return db.AutoMigrate(&Message{})
+	})
+}
+
+type memoryStore struct {
+	messages []Message
+	sent     []string
+	failed   map[string]time.Time
+}
+
+func (m *memoryStore) Claim(ctx context.Context, topics []string, limit int, lease time.Duration) ([]Message, error) {
+	if len(m.messages) < limit {
+		limit = len(m.messages)
+	}
+	claimed := m.messages[:limit]
+	m.messages = m.messages[limit:]
+	return claimed, nil
+}
+
+func (m *memoryStore) MarkSent(ctx context.Context, id string) error {
+	m.sent = append(m.sent, id)
+	return nil
+}
+
+func (m *memoryStore) MarkFailed(ctx context.Context, id, reason string, nextAttempt time.Time) error {
+	m.failed[id] = nextAttempt
+	return nil
+}
+
+type flakyPublisher struct {
+	fail map[string]bool
+	keys []string
+}
+
+func (p *flakyPublisher) Publish(ctx context.Context, key string, value []byte, headers map[string]string) error {
+	if p.fail[key] {
+		return errors.New("broker unavailable")
+	}
+	p.keys = append(p.keys, key)
+	return nil
+}
+
+func TestRelayPublishesAndSchedulesRetries(t *testing.T) {
+	store := &memoryStore{
+		messages: []Message{
+			{ID: "m1", Topic: "submissions", Key: "s1", Payload: []byte(`{"submissionId":"s1"}`)},
+			{ID: "m2", Topic: "submissions", Key: "s2", Payload: []byte(`{"submissionId":"s2"}`), Attempts: 2},
+		},
+		failed: map[string]time.Time{},
+	}
+	publisher := &flakyPublisher{fail: map[string]bool{"s2": true}}
+	relay := NewRelay(store, Routes{"submissions": publisher}, RelayConfig{BaseBackoff: time.Second, MaxBackoff: time.Minute})
+
+	before := time.Now()
+	relayed, err := relay.RelayOnce(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if relayed != 2 {
+		t.Fatalf("expected 2 claimed messages, got %d", relayed)
+	}
+	if len(store.sent) != 1 || store.sent[0] != "m1" {
+		t.Fatalf("expected m1 to be marked sent, got %v", store.sent)
+	}
+
+	next, ok := store.failed["m2"]
+	if !ok {
+		t.Fatalf("expected m2 to be scheduled for retry")
+	}
+	if delay := next.Sub(before); delay < 4*time.Second || delay > 5*time.Second {
+		t.Fatalf("expected third attempt to back off ~4s, got %s", delay)
+	}
+}
+
+func TestRelayHoldsBackTheKeyOfAFailedMessage(t *testing.T) {
+	store := &memoryStore{
+		messages: []Message{
+			{ID: "m1", Topic: Events, Key: "t-1"},
+			{ID: "m2", Topic: Events, Key: "t-2"},
+			{ID: "m3", Topic: Events, Key: "t-1"},
+		},
+		failed: map[string]time.Time{},
+	}
+	publisher := &flakyPublisher{fail: map[string]bool{"t-1": true}}
+	if _, err := NewRelay(store, Routes{Events: publisher}, RelayConfig{}).RelayOnce(context.Background()); err != nil {
+		t.Fatalf("relay: %v", err)
+	}
+	if len(store.sent) != 1 || store.sent[0] != "m2" {
+		t.Fatalf("expected only m2 to be sent, got %v", store.sent)
+	}
+	if _, ok := store.failed["m3"]; ok || len(store.failed) != 1 {
+		t.Fatalf("expected m3 to wait for the retry of m1 untouched, got %v", store.failed)
+	}
+}
+
+func TestClaimKeepsTheOrderOfEachKey(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewRepository(db)
+	ctx := context.Background()
+
+	written := time.Now().Add(-time.Minute)
+	messages := []*Message{
+		{ID: "00000000-0000-0000-0000-000000000001", Topic: Events, Key: "t-1", CreatedAt: written},
+		{ID: "00000000-0000-0000-0000-000000000002", Topic: Events, Key: "t-2", CreatedAt: written.Add(time.Second)},
+		{ID: "00000000-0000-0000-0000-000000000003", Topic: Events, Key: "t-1", CreatedAt: written.Add(2 * time.Second)},
+		{ID: "00000000-0000-0000-0000-000000000004", Topic: Events, Key: "t-1", CreatedAt: written.Add(3 * time.Second)},
+	}
+	for _, message := range messages {
+		if err := db.Create(message).Error; err != nil {
+			t.Fatalf("create: %v", err)
+		}
+	}
+	claimed := func(limit int) []string {
+		t.Helper()
+		batch, err := repo.Claim(ctx, []string{Events}, limit, time.Minute)
+		if err != nil {
+			t.Fatalf("claim: %v", err)
+		}
+		ids := make([]string, 0, len(batch))
+		for _, message := range batch {
+			ids = append(ids, message.ID[len(message.ID)-1:])
+		}
+		return ids
+	}
+
+	// The first message of t-1 failed and waits for its retry.
+	if err := repo.MarkFailed(ctx, messages[0].ID, "broker unavailable", time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("mark failed: %v", err)
+	}
+	if got := claimed(10); len(got) != 1 || got[0] != "2" {
+		t.Fatalf("expected only the message of t-2 to be claimed, got %v", got)
+	}
+
+	if err := db.Model(&Message{}).Where("id = ?", messages[0].ID).Update("next_attempt_at", time.Now()).Error; err != nil {
+		t.Fatalf("make due: %v", err)
+	}
+	if got := claimed(2); len(got) != 2 || got[0] != "1" || got[1] != "3" {
+		t.Fatalf("expected t-1 to be claimed from its first message, got %v", got)
+	}
+	// The fourth message waits while the claimed ones are being published.
+	if got := claimed(10); len(got) != 0 {
+		t.Fatalf("expected t-1 to be held back by its claimed messages, got %v", got)
+	}
+	for _, message := range messages[:3] {
+		if err := repo.MarkSent(ctx, message.ID); err != nil {
+			t.Fatalf("mark sent: %v", err)
+		}
+	}
+	if got := claimed(10); len(got) != 1 || got[0] != "4" {
+		t.Fatalf("expected the last message of t-1 once the earlier ones were sent, got %v", got)
+	}
+}
+
+func TestRelayBackoffIsCapped(t *testing.T) {
+	cfg := RelayConfig{BaseBackoff: time.Second, MaxBackoff: 10 * time.Second}.normalize()
+	if got := cfg.backoff(1); got != time.Second {
+		t.Fatalf("first retry backoff = %s", got)
+	}
+	if got := cfg.backoff(20); got != 10*time.Second {
+		t.Fatalf("expected backoff to be capped, got %s", got)
+	}
+}
+
+func TestPurgeSentKeepsPendingAndRecentMessages(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewRepository(db)
+	ctx := context.Background()
+
+	old := time.Now().Add(-48 * time.Hour)
+	recent := time.Now()
+	messages := []*Message{
+		{Topic: Events, Key: "old-sent", Status: Sent, SentAt: &old},
+		{Topic: Events, Key: "recent-sent", Status: Sent, SentAt: &recent},
+		{Topic: Events, Key: "old-pending", CreatedAt: old},
+	}
+	for _, message := range messages {
+		if err := db.Create(message).Error; err != nil {
+			t.Fatalf("create: %v", err)
+		}
+	}
+
+	purged, err := repo.PurgeSent(ctx, time.Now().Add(-24*time.Hour), 10)
+	if err != nil || purged != 1 {
+		t.Fatalf("expected one purged message, got %d (%v)", purged, err)
+	}
+	var kept []string
+	db.Model(&Message{}).Order("key").Pluck("key", &kept)
+	if len(kept) != 2 || kept[0] != "old-pending" || kept[1] != "recent-sent" {
+		t.Fatalf("expected pending and recent messages to be kept, got %v", kept)
+	}
+}
+
+func TestEventStoreRecordsEventsWithTheirTransaction(t *testing.T) {
+	db := newTestDB(t)
+	events := NewEventStore(db, "/pflow/form")
+	ctx := context.Background()
+
+	err := events.Transaction(ctx, func(ctx context.Context) error {
+		if err := events.Emit(ctx, "form.created", "f-1", map[string]any{"id": "f-1"}); err != nil {
+			return err
+		}
+		return errors.New("change failed")
+	})
+	if err == nil {
+		t.Fatal("expected the transaction to fail")
+	}
+	if err := events.Emit(ctx, "form.updated", "f-1", map[string]any{"id": "f-1"}); err != nil {
+		t.Fatalf("emit: %v", err)
+	}
+
+	publisher := &flakyPublisher{}
+	if _, err := NewRelay(NewRepository(db), Routes{Events: publisher}, RelayConfig{}).RelayOnce(ctx); err != nil {
+		t.Fatalf("relay: %v", err)
+	}
+	if len(publisher.keys) != 1 || publisher.keys[0] != "f-1" {
+		t.Fatalf("expected only the committed event to be relayed, got %v", publisher.keys)
+	}
+
+	var disabled *EventStore
+	if err := disabled.Emit(ctx, "form.created", "f-2", nil); err != nil {
+		t.Fatalf("emit on a nil store: %v", err)
+	}
+}
@@ -0,0 +1,145 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/pflow/shared/mq"
+)
+
+// RelayConfig tunes how the outbox relay polls and retries.
+type RelayConfig struct {
+	BatchSize    int
+	PollInterval time.Duration
+	Lease        time.Duration
+	BaseBackoff  time.Duration
+	MaxBackoff   time.Duration
+}
+
+func (cfg RelayConfig) normalize() RelayConfig {
+	normalized := cfg
+	if normalized.BatchSize <= 0 {
+		normalized.BatchSize = 100
+	}
+	if normalized.PollInterval <= 0 {
+		normalized.PollInterval = time.Second
+	}
+	if normalized.Lease <= 0 {
+		normalized.Lease = 30 * time.Second
+	}
+	if normalized.BaseBackoff <= 0 {
+		normalized.BaseBackoff = time.Second
+	}
+	if normalized.MaxBackoff <= 0 {
+		normalized.MaxBackoff = 5 * time.Minute
+	}
+	return normalized
+}
+
+// backoff returns the delay before the given retry attempt.
+func (cfg RelayConfig) backoff(attempts int) time.Duration {
+	delay := cfg.BaseBackoff
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= cfg.MaxBackoff {
+			return cfg.MaxBackoff
+		}
+	}
+	return delay
+}
+
+// Routes maps outbox topics to the publisher their messages go to.
+type Routes map[string]mq.Publisher
+
+// topics returns the routed topics in a stable order.
+func (routes Routes) topics() []string {
+	topics := make([]string, 0, len(routes))
+	for topic, publisher := range routes {
+		if publisher != nil {
+			topics = append(topics, topic)
+		}
+	}
+	sort.Strings(topics)
+	return topics
+}
+
+// Relay publishes pending outbox messages and records their delivery.
+// It only claims messages of the topics it has a route for.
+type Relay struct {
+	store  Store
+	routes Routes
+	cfg    RelayConfig
+}
+
+// NewRelay constructs a relay that drains store into the publishers of routes.
+func NewRelay(store Store, routes Routes, cfg RelayConfig) *Relay {
+	return &Relay{store: store, routes: routes, cfg: cfg.normalize()}
+}
+
+// Run relays messages until the context is cancelled.
+func (r *Relay) Run(ctx context.Context) error {
+	if r == nil || r.store == nil || len(r.routes.topics()) == 0 {
+		return fmt.Errorf("outbox relay not initialised")
+	}
+
+	ticker := time.NewTicker(r.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			relayed, err := r.RelayOnce(ctx)
+			if err != nil {
+				log.Printf("outbox relay: %v", err)
+				break
+			}
+			if relayed < r.cfg.BatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// RelayOnce claims one batch of due messages and publishes them, returning how many were claimed.
+func (r *Relay) RelayOnce(ctx context.Context) (int, error) {
+	messages, err := r.store.Claim(ctx, r.routes.topics(), r.cfg.BatchSize, r.cfg.Lease)
+	if err != nil {
+		return 0, err
+	}
+
+	// A failed message holds back the later messages of its key until its
+	// retry; they are claimed again once their lease runs out.
+	failed := make(map[stream]bool)
+	for _, message := range messages {
+		if ctx.Err() != nil {
+			return len(messages), ctx.Err()
+		}
+		if message.Key != "" && failed[message.stream()] {
+			continue
+		}
+
+		if err := r.routes[message.Topic].Publish(ctx, message.Key, message.Payload, message.headerMap()); err != nil {
+			failed[message.stream()] = true
+			attempts := message.Attempts + 1
+			next := time.Now().Add(r.cfg.backoff(attempts))
+			log.Printf("outbox relay: publish %s failed (attempt %d, retry at %s): %v", message.ID, attempts, next.Format(time.RFC3339), err)
+			if markErr := r.store.MarkFailed(ctx, message.ID, err.Error(), next); markErr != nil {
+				log.Printf("outbox relay: failed to record failure for %s: %v", message.ID, markErr)
+			}
+			continue
+		}
+
+		if err := r.store.MarkSent(ctx, message.ID); err != nil {
+			log.Printf("outbox relay: failed to mark %s as sent: %v", message.ID, err)
+		}
+	}
+	return len(messages), nil
+}
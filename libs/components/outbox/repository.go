@@ -0,0 +1,158 @@
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/pflow/shared/database"
+)
+
+// Store handles persistence of outbox messages for the relay.
+type Store interface {
+	Claim(ctx context.Context, topics []string, limit int, lease time.Duration) ([]Message, error)
+	MarkSent(ctx context.Context, id string) error
+	MarkFailed(ctx context.Context, id, reason string, nextAttempt time.Time) error
+}
+
+// GormRepository persists outbox messages via GORM.
+type GormRepository struct {
+	db *gorm.DB
+}
+
+// NewRepository constructs an outbox repository backed by the provided DB connection.
+func NewRepository(db *gorm.DB) *GormRepository {
+	return &GormRepository{db: db}
+}
+
+// Claim locks up to limit due messages of topics and pushes their next attempt past the lease so
+// concurrent relays skip them while they are being published. Messages of one topic and key are
+// claimed in the order they were written: a message is only claimed together with every earlier
+// pending message of its key, so a key waiting for a retry or for another relay holds back its
+// later messages.
+func (r *GormRepository) Claim(ctx context.Context, topics []string, limit int, lease time.Duration) ([]Message, error) {
+	var messages []Message
+	err := database.Conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		var due []Message
+		if err := tx.
+			Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ? AND topic IN ?", Pending, now, topics).
+			Order("created_at ASC, id ASC").
+			Limit(limit).
+			Find(&due).Error; err != nil {
+			return err
+		}
+		if len(due) == 0 {
+			return nil
+		}
+
+		var err error
+		if messages, err = inKeyOrder(tx, due); err != nil || len(messages) == 0 {
+			return err
+		}
+		ids := make([]string, 0, len(messages))
+		for _, message := range messages {
+			ids = append(ids, message.ID)
+		}
+		return tx.Model(&Message{}).
+			Where("id IN ?", ids).
+			Update("next_attempt_at", now.Add(lease)).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// inKeyOrder drops the due messages that wait for an earlier pending message of their topic and
+// key that is not among them. Messages without a key are not ordered.
+func inKeyOrder(tx *gorm.DB, due []Message) ([]Message, error) {
+	claimed := make(map[string]bool, len(due))
+	keys := make(map[string]bool)
+	topics := make(map[string]bool)
+	for _, message := range due {
+		claimed[message.ID] = true
+		if message.Key != "" {
+			keys[message.Key] = true
+			topics[message.Topic] = true
+		}
+	}
+	if len(keys) == 0 {
+		return due, nil
+	}
+
+	var pending []Message
+	if err := tx.Model(&Message{}).
+		Select("id", "topic", "key").
+		Where("status = ? AND topic IN ? AND key IN ?", Pending, setKeys(topics), setKeys(keys)).
+		Order("created_at ASC, id ASC").
+		Find(&pending).Error; err != nil {
+		return nil, err
+	}
+	blocked := make(map[stream]bool)
+	held := make(map[string]bool)
+	for _, message := range pending {
+		switch {
+		case !claimed[message.ID]:
+			blocked[message.stream()] = true
+		case blocked[message.stream()]:
+			held[message.ID] = true
+		}
+	}
+
+	ordered := make([]Message, 0, len(due))
+	for _, message := range due {
+		if !held[message.ID] {
+			ordered = append(ordered, message)
+		}
+	}
+	return ordered, nil
+}
+
+func setKeys(set map[string]bool) []string {
+	values := make([]string, 0, len(set))
+	for value := range set {
+		values = append(values, value)
+	}
+	return values
+}
+
+// MarkSent records a successful publication.
+func (r *GormRepository) MarkSent(ctx context.Context, id string) error {
+	now := time.Now()
+	return database.Conn(ctx, r.db).Model(&Message{}).Where("id = ?", id).Updates(map[string]any{
+		"status":     Sent,
+		"sent_at":    &now,
+		"last_error": "",
+	}).Error
+}
+
+// MarkFailed records a failed publication and schedules the next attempt.
+func (r *GormRepository) MarkFailed(ctx context.Context, id, reason string, nextAttempt time.Time) error {
+	return database.Conn(ctx, r.db).Model(&Message{}).Where("id = ?", id).Updates(map[string]any{
+		"attempts":        gorm.Expr("attempts + 1"),
+		"last_error":      reason,
+		"next_attempt_at": nextAttempt,
+	}).Error
+}
+
+// PurgeSent deletes up to limit messages sent before the cutoff, for a
+// database.Purger. Pending messages are kept however old they are.
+func (r *GormRepository) PurgeSent(ctx context.Context, before time.Time, limit int) (int, error) {
+	var ids []string
+	if err := database.Conn(ctx, r.db).Model(&Message{}).
+		Where("status = ? AND sent_at < ?", Sent, before).
+		Order("sent_at ASC").
+		Limit(limit).
+		Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	result := database.Conn(ctx, r.db).Where("id IN ?", ids).Delete(&Message{})
+	return int(result.RowsAffected), result.Error
+}
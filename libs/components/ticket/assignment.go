@@ -0,0 +1,502 @@
+package ticket
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+
+	"github.com/pflow/components/identity"
+	"github.com/pflow/shared/audit"
+	"github.com/pflow/shared/httpx"
+)
+
+const (
+	// StrategyRoundRobin hands tickets to the members of the group in turn.
+	StrategyRoundRobin = "round_robin"
+	// StrategyLeastOpen picks the member with the fewest open tickets.
+	StrategyLeastOpen = "least_open"
+	// StrategySkill picks, among the members holding every skill the ticket
+	// requires, the one with the fewest open tickets.
+	StrategySkill = "skill"
+)
+
+var allowedStrategies = map[string]struct{}{
+	StrategyRoundRobin: {},
+	StrategyLeastOpen:  {},
+	StrategySkill:      {},
+}
+
+// ErrNoAssignee is returned when no assignment rule yields an assignee.
+var ErrNoAssignee = errors.New("no assignment rule matched the ticket")
+
+// AssignmentRule routes new tickets to a group of users. A rule matches a
+// ticket when every condition it sets holds: the form, the priority and each
+// metadata value. Rules are tried by ascending position; the first one whose
+// group has a suitable member assigns the ticket. Groups are identity roles.
+type AssignmentRule struct {
+	ID            string            `json:"id" gorm:"type:uuid;primaryKey"`
+	TenantID      string            `json:"tenantId" gorm:"type:varchar(64);not null;default:default;index"`
+	Name          string            `json:"name" gorm:"not null"`
+	Position      int               `json:"position" gorm:"not null;default:0;index"`
+	Enabled       bool              `json:"enabled" gorm:"not null"`
+	FormID        *string           `json:"formId" gorm:"type:uuid"`
+	Priority      string            `json:"priority" gorm:"type:varchar(32)"`
+	MetadataMatch datatypes.JSONMap `json:"metadataMatch" gorm:"type:jsonb"`
+	Group         string            `json:"group" gorm:"not null"`
+	Strategy      string            `json:"strategy" gorm:"type:varchar(32);not null"`
+	// Skills are required of the assignee by StrategySkill, together with the
+	// skills listed in the SkillsField metadata value of the ticket.
+	Skills      datatypes.JSONSlice[string] `json:"skills" gorm:"type:jsonb"`
+	SkillsField string                      `json:"skillsField"`
+	// LastAssigneeID is the round-robin cursor of the rule.
+	LastAssigneeID string    `json:"lastAssigneeId" gorm:"type:uuid"`
+	CreatedAt      time.Time `json:"createdAt"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+}
+
+// BeforeCreate assigns a UUID when missing.
+func (a *AssignmentRule) BeforeCreate(tx *gorm.DB) error {
+	if a.ID == "" {
+		a.ID = uuid.NewString()
+	}
+	return nil
+}
+
+// ToDTO exposes the rule for clients.
+func (a AssignmentRule) ToDTO() map[string]any {
+	dto := map[string]any{
+		"id":        a.ID,
+		"tenantId":  a.TenantID,
+		"name":      a.Name,
+		"position":  a.Position,
+		"enabled":   a.Enabled,
+		"group":     a.Group,
+		"strategy":  a.Strategy,
+		"skills":    []string(a.Skills),
+		"createdAt": a.CreatedAt,
+		"updatedAt": a.UpdatedAt,
+	}
+	if a.Skills == nil {
+		dto["skills"] = []string{}
+	}
+	if a.FormID != nil {
+		dto["formId"] = *a.FormID
+	}
+	if a.Priority != "" {
+		dto["priority"] = a.Priority
+	}
+	if len(a.MetadataMatch) > 0 {
+		dto["metadataMatch"] = a.MetadataMatch
+	}
+	if a.SkillsField != "" {
+		dto["skillsField"] = a.SkillsField
+	}
+	return dto
+}
+
+// Matches reports whether the rule applies to ticket.
+func (a AssignmentRule) Matches(ticket *Ticket) bool {
+	if !a.Enabled {
+		return false
+	}
+	if a.FormID != nil && *a.FormID != ticket.FormID {
+		return false
+	}
+	if a.Priority != "" && a.Priority != ticket.Priority {
+		return false
+	}
+	for key, expected := range a.MetadataMatch {
+		actual, ok := ticket.Metadata[key]
+		if !ok || fmt.Sprint(actual) != fmt.Sprint(expected) {
+			return false
+		}
+	}
+	return true
+}
+
+// requiredSkills returns the skills StrategySkill asks of the assignee of ticket.
+func (a AssignmentRule) requiredSkills(ticket *Ticket) []string {
+	required := append([]string{}, a.Skills...)
+	if a.SkillsField == "" {
+		return required
+	}
+	switch value := ticket.Metadata[a.SkillsField].(type) {
+	case string:
+		required = append(required, value)
+	case []any:
+		for _, item := range value {
+			required = append(required, fmt.Sprint(item))
+		}
+	}
+	return required
+}
+
+// AssignmentRuleRepository persists the assignment rules of the current tenant.
+type AssignmentRuleRepository interface {
+	ListRules(ctx context.Context) ([]AssignmentRule, error)
+	FindRule(ctx context.Context, id string) (*AssignmentRule, error)
+	SaveRule(ctx context.Context, rule *AssignmentRule) error
+	DeleteRule(ctx context.Context, id string) error
+}
+
+// RoutingStore is the persistence the Router needs besides the rules.
+type RoutingStore interface {
+	AssignmentRuleRepository
+	// OpenTicketCounts returns how many open or in progress tickets each of
+	// the users is assigned.
+	OpenTicketCounts(ctx context.Context, assigneeIDs []string) (map[string]int64, error)
+	// NextInRotation picks the candidate after the last one the rule
+	// assigned and stores it as the new cursor.
+	NextInRotation(ctx context.Context, ruleID string, candidates []string) (string, error)
+}
+
+// GroupDirectory lists the members of the groups rules route to.
+// *identity.Directory satisfies it.
+type GroupDirectory interface {
+	Members(ctx context.Context, group string) ([]identity.Member, error)
+}
+
+// Router assigns tickets according to the assignment rules of their tenant.
+type Router struct {
+	store     RoutingStore
+	directory GroupDirectory
+}
+
+// NewRouter constructs a Router reading rules from store and groups from directory.
+func NewRouter(store RoutingStore, directory GroupDirectory) *Router {
+	return &Router{store: store, directory: directory}
+}
+
+// Route returns the assignee the first suitable rule picks for ticket and the
+// rule that picked it, or ErrNoAssignee.
+func (r *Router) Route(ctx context.Context, ticket *Ticket) (string, *AssignmentRule, error) {
+	rules, err := r.store.ListRules(ctx)
+	if err != nil {
+		return "", nil, err
+	}
+	for i := range rules {
+		rule := &rules[i]
+		if !rule.Matches(ticket) {
+			continue
+		}
+		assignee, err := r.pick(ctx, rule, ticket)
+		if err != nil {
+			return "", nil, fmt.Errorf("assignment rule %s: %w", rule.Name, err)
+		}
+		if assignee != "" {
+			return assignee, rule, nil
+		}
+	}
+	return "", nil, ErrNoAssignee
+}
+
+// pick chooses a member of the rule's group, or nobody when none qualifies.
+func (r *Router) pick(ctx context.Context, rule *AssignmentRule, ticket *Ticket) (string, error) {
+	members, err := r.directory.Members(ctx, rule.Group)
+	if err != nil {
+		return "", err
+	}
+
+	var candidates []string
+	required := rule.requiredSkills(ticket)
+	for _, member := range members {
+		if rule.Strategy == StrategySkill && !member.HasSkills(required) {
+			continue
+		}
+		candidates = append(candidates, member.ID)
+	}
+	if len(candidates) == 0 {
+		return "", nil
+	}
+	sort.Strings(candidates)
+
+	if rule.Strategy == StrategyRoundRobin {
+		return r.store.NextInRotation(ctx, rule.ID, candidates)
+	}
+
+	counts, err := r.store.OpenTicketCounts(ctx, candidates)
+	if err != nil {
+		return "", err
+	}
+	chosen := candidates[0]
+	for _, candidate := range candidates[1:] {
+		if counts[candidate] < counts[chosen] {
+			chosen = candidate
+		}
+	}
+	return chosen, nil
+}
+
+// autoAssign routes a newly created ticket and records the assignment. It only
+// logs failures: a ticket that cannot be routed stays unassigned.
+func autoAssign(ctx context.Context, router *Router, repo Repository, entity *Ticket) *Ticket {
+	if router == nil || entity.AssigneeID != "" {
+		return entity
+	}
+	assignee, rule, err := router.Route(ctx, entity)
+	if err != nil {
+		if !errors.Is(err, ErrNoAssignee) {
+			log.Printf("ticket routing: failed to route ticket %s: %v", entity.ID, err)
+		}
+		return entity
+	}
+	assigned, err := repo.Assign(ctx, entity.ID, AssignmentRequest{AssigneeID: assignee, Actor: ruleActor(rule)})
+	if err != nil {
+		log.Printf("ticket routing: failed to assign ticket %s to %s: %v", entity.ID, assignee, err)
+		return entity
+	}
+	return assigned
+}
+
+// ruleActor names a rule as the actor of the assignments it makes.
+func ruleActor(rule *AssignmentRule) string {
+	return "rule:" + rule.ID
+}
+
+type assignmentRuleRequest struct {
+	Name          *string         `json:"name"`
+	Position      *int            `json:"position"`
+	Enabled       *bool           `json:"enabled"`
+	FormID        *string         `json:"formId"`
+	Priority      *string         `json:"priority"`
+	MetadataMatch *map[string]any `json:"metadataMatch"`
+	Group         *string         `json:"group"`
+	Strategy      *string         `json:"strategy"`
+	Skills        *[]string       `json:"skills"`
+	SkillsField   *string         `json:"skillsField"`
+}
+
+// apply copies the provided fields onto rule and validates the result.
+func (req assignmentRuleRequest) apply(rule *AssignmentRule) error {
+	if req.Name != nil {
+		rule.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.Position != nil {
+		rule.Position = *req.Position
+	}
+	if req.Enabled != nil {
+		rule.Enabled = *req.Enabled
+	}
+	if req.FormID != nil {
+		rule.FormID = nil
+		if formID := strings.TrimSpace(*req.FormID); formID != "" {
+			if _, err := uuid.Parse(formID); err != nil {
+				return errors.New("formId must be a UUID")
+			}
+			rule.FormID = &formID
+		}
+	}
+	if req.Priority != nil {
+		rule.Priority = strings.ToLower(strings.TrimSpace(*req.Priority))
+	}
+	if req.MetadataMatch != nil {
+		rule.MetadataMatch = datatypes.JSONMap(*req.MetadataMatch)
+	}
+	if req.Group != nil {
+		rule.Group = strings.TrimSpace(*req.Group)
+	}
+	if req.Strategy != nil {
+		rule.Strategy = strings.ToLower(strings.TrimSpace(*req.Strategy))
+	}
+	if req.Skills != nil {
+		skills := make([]string, 0, len(*req.Skills))
+		for _, skill := range *req.Skills {
+			if skill = strings.ToLower(strings.TrimSpace(skill)); skill != "" {
+				skills = append(skills, skill)
+			}
+		}
+		rule.Skills = datatypes.NewJSONSlice(skills)
+	}
+	if req.SkillsField != nil {
+		rule.SkillsField = strings.TrimSpace(*req.SkillsField)
+	}
+
+	if rule.Name == "" {
+		return errors.New("name is required")
+	}
+	if rule.Group == "" {
+		return errors.New("group is required")
+	}
+	if rule.Strategy == "" {
+		rule.Strategy = StrategyRoundRobin
+	}
+	if _, ok := allowedStrategies[rule.Strategy]; !ok {
+		return errors.New("strategy must be round_robin, least_open or skill")
+	}
+	if rule.Strategy == StrategySkill && len(rule.Skills) == 0 && rule.SkillsField == "" {
+		return errors.New("skill strategy requires skills or skillsField")
+	}
+	return nil
+}
+
+func (h *Handler) listAssignmentRules(w http.ResponseWriter, r *http.Request) {
+	rules, err := h.routingRules.ListRules(r.Context())
+	if err != nil {
+		httpx.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	items := make([]map[string]any, 0, len(rules))
+	for _, rule := range rules {
+		items = append(items, rule.ToDTO())
+	}
+
+	httpx.JSON(w, http.StatusOK, map[string]any{"data": items})
+}
+
+func (h *Handler) createAssignmentRule(w http.ResponseWriter, r *http.Request) {
+	var payload assignmentRuleRequest
+	if err := decodeJSON(r, &payload); err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	rule := &AssignmentRule{Enabled: true}
+	if err := payload.apply(rule); err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := h.routingRules.SaveRule(r.Context(), rule); err != nil {
+		renderAssignmentRuleError(w, err)
+		return
+	}
+	h.audit.Record(r.Context(), audit.Change{Action: auditAssignmentRuleCreated, ResourceID: rule.ID, After: rule.ToDTO()})
+
+	httpx.JSON(w, http.StatusCreated, map[string]any{"data": rule.ToDTO()})
+}
+
+func (h *Handler) getAssignmentRule(w http.ResponseWriter, r *http.Request) {
+	rule, err := h.routingRules.FindRule(r.Context(), chi.URLParam(r, "ruleId"))
+	if err != nil {
+		renderAssignmentRuleError(w, err)
+		return
+	}
+
+	httpx.JSON(w, http.StatusOK, map[string]any{"data": rule.ToDTO()})
+}
+
+func (h *Handler) updateAssignmentRule(w http.ResponseWriter, r *http.Request) {
+	var payload assignmentRuleRequest
+	if err := decodeJSON(r, &payload); err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	rule, err := h.routingRules.FindRule(r.Context(), chi.URLParam(r, "ruleId"))
+	if err != nil {
+		renderAssignmentRuleError(w, err)
+		return
+	}
+	before := rule.ToDTO()
+	if err := payload.apply(rule); err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := h.routingRules.SaveRule(r.Context(), rule); err != nil {
+		renderAssignmentRuleError(w, err)
+		return
+	}
+	h.audit.Record(r.Context(), audit.Change{Action: auditAssignmentRuleUpdated, ResourceID: rule.ID, Before: before, After: rule.ToDTO()})
+
+	httpx.JSON(w, http.StatusOK, map[string]any{"data": rule.ToDTO()})
+}
+
+func (h *Handler) deleteAssignmentRule(w http.ResponseWriter, r *http.Request) {
+	rule, err := h.routingRules.FindRule(r.Context(), chi.URLParam(r, "ruleId"))
+	if err != nil {
+		renderAssignmentRuleError(w, err)
+		return
+	}
+	if err := h.routingRules.DeleteRule(r.Context(), rule.ID); err != nil {
+		renderAssignmentRuleError(w, err)
+		return
+	}
+	h.audit.Record(r.Context(), audit.Change{Action: auditAssignmentRuleDeleted, ResourceID: rule.ID, Before: rule.ToDTO()})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func renderAssignmentRuleError(w http.ResponseWriter, err error) {
+	if IsNotFound(err) {
+		httpx.Error(w, http.StatusNotFound, "assignment rule not found")
+		return
+	}
+	httpx.Error(w, http.StatusInternalServerError, err.Error())
+}
+
+type assignTicketRequest struct {
+	AssigneeID *string `json:"assigneeId"`
+}
+
+// assignTicket reassigns a ticket to the given user, or unassigns it for an
+// empty assigneeId. Without an assigneeId the assignment rules pick one.
+func (h *Handler) assignTicket(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	var payload assignTicketRequest
+	if err := decodeJSON(r, &payload); err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	before, err := h.repo.Find(r.Context(), id)
+	if err != nil {
+		h.renderTicketError(w, err)
+		return
+	}
+
+	actor := requestActor(r)
+	var assignee string
+	if payload.AssigneeID != nil {
+		assignee = strings.TrimSpace(*payload.AssigneeID)
+		if assignee != "" {
+			if _, err := uuid.Parse(assignee); err != nil {
+				httpx.Error(w, http.StatusBadRequest, "assigneeId must be a UUID")
+				return
+			}
+		}
+	} else {
+		if h.router == nil {
+			httpx.Error(w, http.StatusBadRequest, "assigneeId is required")
+			return
+		}
+		routed, rule, err := h.router.Route(r.Context(), before)
+		switch {
+		case errors.Is(err, ErrNoAssignee):
+			httpx.Error(w, http.StatusUnprocessableEntity, err.Error())
+			return
+		case err != nil:
+			httpx.Error(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		assignee = routed
+		if actor == "" {
+			actor = ruleActor(rule)
+		}
+	}
+
+	var entity *Ticket
+	err = h.events.Transaction(r.Context(), func(ctx context.Context) error {
+		var err error
+		if entity, err = h.repo.Assign(ctx, id, AssignmentRequest{AssigneeID: assignee, Actor: actor}); err != nil {
+			return err
+		}
+		return h.events.Emit(ctx, EventTicketAssigned, entity.ID, entity.ToDTO())
+	})
+	if err != nil {
+		h.renderTicketError(w, err)
+		return
+	}
+	h.audit.Record(r.Context(), audit.Change{Action: EventTicketAssigned, ResourceID: entity.ID, Before: before.ToDTO(), After: entity.ToDTO()})
+
+	httpx.JSON(w, http.StatusOK, map[string]any{"data": entity.ToDTO()})
+}
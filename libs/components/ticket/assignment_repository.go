@@ -0,0 +1,115 @@
+package ticket
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/pflow/shared/database"
+	"github.com/pflow/shared/tenant"
+)
+
+// GormRoutingRepository persists assignment rules via GORM and answers the
+// load and rotation queries of the Router.
+type GormRoutingRepository struct {
+	db *gorm.DB
+}
+
+// NewRoutingRepository constructs a routing repository backed by the provided DB connection.
+func NewRoutingRepository(db *gorm.DB) *GormRoutingRepository {
+	return &GormRoutingRepository{db: db}
+}
+
+func (r *GormRoutingRepository) scoped(ctx context.Context) *gorm.DB {
+	return database.Conn(ctx, r.db).Scopes(database.TenantScope(ctx))
+}
+
+// ListRules returns the rules of the current tenant in the order they are tried.
+func (r *GormRoutingRepository) ListRules(ctx context.Context) ([]AssignmentRule, error) {
+	var rules []AssignmentRule
+	if err := r.scoped(ctx).Order("position ASC").Order("created_at ASC").Find(&rules).Error; err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// FindRule retrieves a rule by ID.
+func (r *GormRoutingRepository) FindRule(ctx context.Context, id string) (*AssignmentRule, error) {
+	var rule AssignmentRule
+	if err := r.scoped(ctx).First(&rule, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// SaveRule creates or updates a rule. The round-robin cursor is left alone so
+// editing a rule does not restart its rotation.
+func (r *GormRoutingRepository) SaveRule(ctx context.Context, rule *AssignmentRule) error {
+	if rule.ID == "" {
+		rule.TenantID = tenant.ID(ctx)
+		return database.Conn(ctx, r.db).Create(rule).Error
+	}
+	return database.Conn(ctx, r.db).Select("*").Omit("last_assignee_id", "created_at").Updates(rule).Error
+}
+
+// DeleteRule removes a rule.
+func (r *GormRoutingRepository) DeleteRule(ctx context.Context, id string) error {
+	result := r.scoped(ctx).Delete(&AssignmentRule{}, "id = ?", id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// OpenTicketCounts counts the open and in progress tickets of each assignee in
+// the current tenant. Users without any are absent from the result.
+func (r *GormRoutingRepository) OpenTicketCounts(ctx context.Context, assigneeIDs []string) (map[string]int64, error) {
+	var rows []struct {
+		AssigneeID  string
+		OpenTickets int64
+	}
+	err := r.scoped(ctx).Model(&Ticket{}).
+		Select("assignee_id, COUNT(*) AS open_tickets").
+		Where("assignee_id IN ? AND status IN ?", assigneeIDs, []string{StatusOpen, StatusInProgress}).
+		Group("assignee_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		counts[row.AssigneeID] = row.OpenTickets
+	}
+	return counts, nil
+}
+
+// NextInRotation picks the first candidate after the rule's cursor, wrapping
+// around, and advances the cursor. The rule row is locked so concurrent
+// creations take turns. candidates must be sorted.
+func (r *GormRoutingRepository) NextInRotation(ctx context.Context, ruleID string, candidates []string) (string, error) {
+	var next string
+	err := database.Conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		var rule AssignmentRule
+		if err := tx.Scopes(database.TenantScope(ctx)).Clauses(clause.Locking{Strength: "UPDATE"}).First(&rule, "id = ?", ruleID).Error; err != nil {
+			return err
+		}
+
+		next = candidates[0]
+		for _, candidate := range candidates {
+			if candidate > rule.LastAssigneeID {
+				next = candidate
+				break
+			}
+		}
+		return tx.Model(&rule).UpdateColumn("last_assignee_id", next).Error
+	})
+	if err != nil {
+		return "", err
+	}
+	return next, nil
+}
@@ -0,0 +1,183 @@
+package ticket
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/pflow/components/identity"
+	"github.com/pflow/shared/tenant"
+)
+
+const (
+	agentAda   = "0a5e7c1d-1111-4c6e-9a5f-1e2d3c4b5a60"
+	agentBruno = "0a5e7c1d-2222-4c6e-9a5f-1e2d3c4b5a60"
+	agentChen  = "0a5e7c1d-3333-4c6e-9a5f-1e2d3c4b5a60"
+	routedForm = "3f1c2a4e-8b7d-4c6e-9a5f-1e2d3c4b5a69"
+)
+
+type staticDirectory map[string][]identity.Member
+
+func (d staticDirectory) Members(ctx context.Context, group string) ([]identity.Member, error) {
+	return d[group], nil
+}
+
+type routingTestServer struct {
+	t      *testing.T
+	repo   *GormRepository
+	router chi.Router
+	// actor is sent as X-User-ID when set.
+	actor string
+}
+
+func newRoutingTestServer(t *testing.T) routingTestServer {
+	db := newTestDB(t)
+	repo := NewGormRepository(db)
+	rules := NewRoutingRepository(db)
+	directory := staticDirectory{
+		"oncall": {{ID: agentAda}, {ID: agentBruno}},
+		"support": {
+			{ID: agentAda, Skills: []string{"en"}},
+			{ID: agentBruno, Skills: []string{"en", "de"}},
+			{ID: agentChen, Skills: []string{"en"}},
+		},
+	}
+	router := chi.NewRouter()
+	NewHandler(repo, WithRouting(NewRouter(rules, directory))).Mount(router, "")
+	return routingTestServer{t: t, repo: repo, router: router}
+}
+
+func (s routingTestServer) do(method, target string, body any) (int, map[string]any) {
+	s.t.Helper()
+	var payload bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&payload).Encode(body)
+	}
+	req := httptest.NewRequest(method, target, &payload)
+	if s.actor != "" {
+		req.Header.Set("X-User-ID", s.actor)
+	}
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+	var envelope struct {
+		Data map[string]any `json:"data"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &envelope)
+	return rec.Code, envelope.Data
+}
+
+func (s routingTestServer) rule(body map[string]any) string {
+	s.t.Helper()
+	code, rule := s.do(http.MethodPost, "/tickets/assignment-rules", body)
+	if code != http.StatusCreated {
+		s.t.Fatalf("create rule %v: %d", body["name"], code)
+	}
+	return rule["id"].(string)
+}
+
+func (s routingTestServer) create(priority string, metadata map[string]any) string {
+	s.t.Helper()
+	code, ticket := s.do(http.MethodPost, "/tickets", map[string]any{
+		"title":    "Routed ticket",
+		"formId":   routedForm,
+		"priority": priority,
+		"metadata": metadata,
+	})
+	if code != http.StatusCreated {
+		s.t.Fatalf("create ticket: %d", code)
+	}
+	assignee, _ := ticket["assigneeId"].(string)
+	return assignee
+}
+
+func TestRoutingStrategies(t *testing.T) {
+	s := newRoutingTestServer(t)
+	s.rule(map[string]any{"name": "Urgent on call", "position": 1, "priority": "urgent", "group": "oncall", "strategy": StrategyRoundRobin})
+	s.rule(map[string]any{"name": "By language", "position": 2, "metadataMatch": map[string]any{"channel": "email"}, "group": "support", "strategy": StrategySkill, "skillsField": "language"})
+	s.rule(map[string]any{"name": "Everything else", "position": 3, "group": "support", "strategy": StrategyLeastOpen})
+
+	var rotation []string
+	for i := 0; i < 3; i++ {
+		rotation = append(rotation, s.create("urgent", nil))
+	}
+	if rotation[0] != agentAda || rotation[1] != agentBruno || rotation[2] != agentAda {
+		t.Fatalf("expected round robin over the on-call group, got %v", rotation)
+	}
+
+	if got := s.create("low", map[string]any{"channel": "email", "language": "de"}); got != agentBruno {
+		t.Fatalf("expected the German speaker, got %q", got)
+	}
+
+	// Ada and Bruno hold two open tickets each, so Chen is least loaded.
+	if got := s.create("low", nil); got != agentChen {
+		t.Fatalf("expected the least loaded member, got %q", got)
+	}
+
+	// Nobody speaks French, so the skill rule passes the ticket on.
+	if got := s.create("low", map[string]any{"channel": "email", "language": "fr"}); got != agentChen {
+		t.Fatalf("expected the fallback rule to apply, got %q", got)
+	}
+}
+
+func TestAssignEndpoint(t *testing.T) {
+	s := newRoutingTestServer(t)
+	id := func() string {
+		ctx := tenant.WithID(context.Background(), tenant.Default)
+		entity := &Ticket{Title: "Unrouted", Status: StatusOpen, Priority: "urgent", FormID: routedForm}
+		if err := s.repo.Create(ctx, entity); err != nil {
+			t.Fatalf("create: %v", err)
+		}
+		return entity.ID
+	}()
+
+	if code, _ := s.do(http.MethodPost, "/tickets/"+id+"/assign", map[string]any{}); code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected routing without rules to fail, got %d", code)
+	}
+	if code, _ := s.do(http.MethodPost, "/tickets/"+id+"/assign", map[string]any{"assigneeId": "bruno"}); code != http.StatusBadRequest {
+		t.Fatalf("expected a malformed assignee to be rejected, got %d", code)
+	}
+
+	lead := s
+	lead.actor = "lead"
+	if code, _ := lead.do(http.MethodPost, "/tickets/"+id+"/assign", map[string]any{"assigneeId": agentChen, "actor": "someone-else"}); code != http.StatusBadRequest {
+		t.Fatalf("expected a request body naming the actor to be rejected, got %d", code)
+	}
+	code, ticket := lead.do(http.MethodPost, "/tickets/"+id+"/assign", map[string]any{"assigneeId": agentChen})
+	if code != http.StatusOK || ticket["assigneeId"] != agentChen {
+		t.Fatalf("manual assignment: %d %v", code, ticket)
+	}
+
+	ruleID := s.rule(map[string]any{"name": "Urgent on call", "priority": "urgent", "group": "oncall"})
+	code, ticket = s.do(http.MethodPost, "/tickets/"+id+"/assign", map[string]any{})
+	if code != http.StatusOK || ticket["assigneeId"] != agentAda {
+		t.Fatalf("routed reassignment: %d %v", code, ticket)
+	}
+
+	code, ticket = s.do(http.MethodPost, "/tickets/"+id+"/assign", map[string]any{"assigneeId": ""})
+	if code != http.StatusOK || ticket["assigneeId"] != "" {
+		t.Fatalf("unassignment: %d %v", code, ticket)
+	}
+
+	assignments, err := s.repo.Assignments(tenant.WithID(context.Background(), tenant.Default), id)
+	if err != nil {
+		t.Fatalf("assignments: %v", err)
+	}
+	if len(assignments) != 3 || assignments[0].Actor != "lead" || assignments[1].Actor != "rule:"+ruleID || assignments[2].ToAssignee != "" {
+		t.Fatalf("unexpected assignment history: %+v", assignments)
+	}
+
+	if code, _ := s.do(http.MethodPatch, "/tickets/assignment-rules/"+ruleID, map[string]any{"strategy": "random"}); code != http.StatusBadRequest {
+		t.Fatalf("expected unknown strategy to be rejected, got %d", code)
+	}
+	if code, rule := s.do(http.MethodPatch, "/tickets/assignment-rules/"+ruleID, map[string]any{"enabled": false}); code != http.StatusOK || rule["enabled"] != false || rule["strategy"] != StrategyRoundRobin {
+		t.Fatalf("disable rule: %d %v", code, rule)
+	}
+	if code, _ := s.do(http.MethodPost, "/tickets/"+id+"/assign", map[string]any{}); code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected disabled rule to be skipped, got %d", code)
+	}
+}
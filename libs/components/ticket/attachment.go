@@ -0,0 +1,488 @@
+package ticket
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/pflow/shared/audit"
+	"github.com/pflow/shared/database"
+	"github.com/pflow/shared/httpx"
+	"github.com/pflow/shared/tenant"
+)
+
+// DefaultAttachmentTypes lists the media types accepted when no allow-list is
+// configured: common screenshots, PDFs, plain text and office documents.
+var DefaultAttachmentTypes = []string{
+	"image/png",
+	"image/jpeg",
+	"image/gif",
+	"image/webp",
+	"application/pdf",
+	"text/plain",
+	"text/csv",
+	"application/zip",
+	"application/msword",
+	"application/vnd.ms-excel",
+	"application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	"application/vnd.openxmlformats-officedocument.presentationml.presentation",
+}
+
+// DefaultAttachmentMaxBytes caps uploads when no limit is configured.
+const DefaultAttachmentMaxBytes int64 = 10 << 20
+
+// AttachmentLimits restricts what may be uploaded.
+type AttachmentLimits struct {
+	MaxBytes     int64
+	AllowedTypes []string
+}
+
+func (l AttachmentLimits) withDefaults() AttachmentLimits {
+	if l.MaxBytes <= 0 {
+		l.MaxBytes = DefaultAttachmentMaxBytes
+	}
+	if len(l.AllowedTypes) == 0 {
+		l.AllowedTypes = DefaultAttachmentTypes
+	}
+	return l
+}
+
+func (l AttachmentLimits) allows(contentType string) bool {
+	for _, allowed := range l.AllowedTypes {
+		if strings.EqualFold(strings.TrimSpace(allowed), contentType) {
+			return true
+		}
+	}
+	return false
+}
+
+// TicketAttachment describes a file attached to a ticket. The content lives in
+// a BlobStore under BlobKey, which is derived from the tenant and the SHA-256
+// of the content, so identical uploads within a tenant share one blob.
+type TicketAttachment struct {
+	ID          string    `json:"id" gorm:"type:uuid;primaryKey"`
+	TenantID    string    `json:"tenantId" gorm:"type:varchar(64);not null;default:default;index"`
+	TicketID    string    `json:"ticketId" gorm:"type:uuid;not null;index"`
+	FileName    string    `json:"fileName" gorm:"not null"`
+	ContentType string    `json:"contentType" gorm:"not null"`
+	Size        int64     `json:"size" gorm:"not null"`
+	SHA256      string    `json:"sha256" gorm:"type:char(64);not null;index"`
+	BlobKey     string    `json:"-" gorm:"not null;index"`
+	UploadedBy  string    `json:"uploadedBy"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+// BeforeCreate assigns a UUID when missing.
+func (a *TicketAttachment) BeforeCreate(tx *gorm.DB) error {
+	if a.ID == "" {
+		a.ID = uuid.NewString()
+	}
+	return nil
+}
+
+// ToDTO exposes the attachment metadata for clients.
+func (a TicketAttachment) ToDTO() map[string]any {
+	dto := map[string]any{
+		"id":          a.ID,
+		"ticketId":    a.TicketID,
+		"fileName":    a.FileName,
+		"contentType": a.ContentType,
+		"size":        a.Size,
+		"sha256":      a.SHA256,
+		"createdAt":   a.CreatedAt,
+	}
+	if a.UploadedBy != "" {
+		dto["uploadedBy"] = a.UploadedBy
+	}
+	return dto
+}
+
+// AttachmentRepository persists attachment metadata. Lookups fail with
+// gorm.ErrRecordNotFound when the ticket is missing from the current tenant.
+type AttachmentRepository interface {
+	ListAttachments(ctx context.Context, ticketID string) ([]TicketAttachment, error)
+	FindAttachment(ctx context.Context, ticketID, attachmentID string) (*TicketAttachment, error)
+	FindAttachmentByHash(ctx context.Context, ticketID, sum string) (*TicketAttachment, error)
+	CreateAttachment(ctx context.Context, attachment *TicketAttachment) error
+	DeleteAttachment(ctx context.Context, ticketID, attachmentID string) (*TicketAttachment, error)
+	BlobInUse(ctx context.Context, key string) (bool, error)
+	// LockBlob serialises storing and releasing the blob under key until the
+	// transaction of ctx ends.
+	LockBlob(ctx context.Context, key string) error
+	Transaction(ctx context.Context, fn func(ctx context.Context) error) error
+
+	// Attachments of submissions are staged until the worker creates their
+	// ticket; lookups fail with gorm.ErrRecordNotFound when the submission is
+	// missing from the current tenant.
+	LockSubmission(ctx context.Context, submissionID string) (*TicketSubmission, error)
+	ListSubmissionAttachments(ctx context.Context, submissionID string) ([]SubmissionAttachment, error)
+	FindSubmissionAttachmentByHash(ctx context.Context, submissionID, sum string) (*SubmissionAttachment, error)
+	StageAttachment(ctx context.Context, attachment *SubmissionAttachment) error
+	AttachSubmission(ctx context.Context, submissionID, ticketID string) ([]TicketAttachment, error)
+}
+
+// attachmentBlobKey addresses content by tenant and digest.
+func attachmentBlobKey(tenantID, sum string) string {
+	return fmt.Sprintf("%s/%s/%s", tenantID, sum[:2], sum)
+}
+
+// resolveContentType prefers the sniffed type of the content and falls back to
+// the declared type, then the file extension, where sniffing cannot tell
+// formats apart (office documents sniff as zip, CSV as plain text).
+func resolveContentType(fileName, declared string, head []byte) string {
+	sniffed := mediaType(http.DetectContentType(head))
+	switch sniffed {
+	case "application/octet-stream", "text/plain", "application/zip":
+		if claimed := mediaType(declared); claimed != "" && claimed != "application/octet-stream" {
+			return claimed
+		}
+		if byExtension := mediaType(mime.TypeByExtension(filepath.Ext(fileName))); byExtension != "" {
+			return byExtension
+		}
+	}
+	return sniffed
+}
+
+func mediaType(value string) string {
+	parsed, _, err := mime.ParseMediaType(value)
+	if err != nil {
+		return ""
+	}
+	return parsed
+}
+
+func (h *Handler) listAttachments(w http.ResponseWriter, r *http.Request) {
+	attachments, err := h.attachments.ListAttachments(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		h.renderTicketError(w, err)
+		return
+	}
+
+	items := make([]map[string]any, 0, len(attachments))
+	for _, attachment := range attachments {
+		items = append(items, attachment.ToDTO())
+	}
+
+	httpx.JSON(w, http.StatusOK, map[string]any{"data": items})
+}
+
+// uploadAttachment accepts a multipart upload with the content in the "file"
+// part. Uploading content the ticket already holds returns that attachment.
+func (h *Handler) uploadAttachment(w http.ResponseWriter, r *http.Request) {
+	ticketID := chi.URLParam(r, "id")
+	if _, err := h.repo.Find(r.Context(), ticketID); err != nil {
+		h.renderTicketError(w, err)
+		return
+	}
+
+	upload := h.receiveUpload(w, r)
+	if upload == nil {
+		return
+	}
+	defer upload.Close()
+	h.storeAttachment(w, r, ticketID, upload)
+}
+
+// upload is an uploaded file spooled to disk and checked against the limits.
+type upload struct {
+	spool       *os.File
+	fileName    string
+	contentType string
+	size        int64
+	sum         string
+}
+
+// Close removes the spooled content.
+func (u *upload) Close() {
+	u.spool.Close()
+	os.Remove(u.spool.Name())
+}
+
+// receiveUpload spools the "file" part of a multipart request. It renders the
+// response and returns nil when the upload is rejected.
+func (h *Handler) receiveUpload(w http.ResponseWriter, r *http.Request) *upload {
+	// Leave room for the multipart framing around the file itself.
+	r.Body = http.MaxBytesReader(w, r.Body, h.attachmentLimits.MaxBytes+1<<20)
+	reader, err := r.MultipartReader()
+	if err != nil {
+		httpx.Error(w, http.StatusBadRequest, "expected a multipart/form-data upload")
+		return nil
+	}
+
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			httpx.Error(w, http.StatusBadRequest, `multipart field "file" is required`)
+			return nil
+		}
+		if err != nil {
+			renderUploadError(w, err)
+			return nil
+		}
+		if part.FormName() != "file" {
+			part.Close()
+			continue
+		}
+		upload := h.spoolUpload(w, part.FileName(), part.Header.Get("Content-Type"), part)
+		part.Close()
+		return upload
+	}
+}
+
+func (h *Handler) spoolUpload(w http.ResponseWriter, fileName, declared string, content io.Reader) *upload {
+	fileName = filepath.Base(strings.TrimSpace(fileName))
+	if fileName == "" || fileName == "." || fileName == string(filepath.Separator) {
+		httpx.Error(w, http.StatusBadRequest, "file name is required")
+		return nil
+	}
+
+	spool, err := os.CreateTemp("", "pflow-attachment-*")
+	if err != nil {
+		httpx.Error(w, http.StatusInternalServerError, err.Error())
+		return nil
+	}
+	upload := &upload{spool: spool, fileName: fileName}
+	kept := false
+	defer func() {
+		if !kept {
+			upload.Close()
+		}
+	}()
+
+	digest := sha256.New()
+	size, err := io.Copy(io.MultiWriter(spool, digest), io.LimitReader(content, h.attachmentLimits.MaxBytes+1))
+	if err != nil {
+		renderUploadError(w, err)
+		return nil
+	}
+	if size > h.attachmentLimits.MaxBytes {
+		httpx.Error(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("attachments must not exceed %d bytes", h.attachmentLimits.MaxBytes))
+		return nil
+	}
+	if size == 0 {
+		httpx.Error(w, http.StatusBadRequest, "file is empty")
+		return nil
+	}
+
+	head := make([]byte, 512)
+	n, err := spool.ReadAt(head, 0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		httpx.Error(w, http.StatusInternalServerError, err.Error())
+		return nil
+	}
+	upload.contentType = resolveContentType(fileName, declared, head[:n])
+	if !h.attachmentLimits.allows(upload.contentType) {
+		httpx.Error(w, http.StatusUnsupportedMediaType, fmt.Sprintf("attachments of type %s are not allowed", upload.contentType))
+		return nil
+	}
+
+	upload.size = size
+	upload.sum = hex.EncodeToString(digest.Sum(nil))
+	kept = true
+	return upload
+}
+
+// errStoreBlob marks failures of the blob store, which are reported as 502.
+var errStoreBlob = errors.New("store attachment")
+
+// putBlob stores the upload under key. Content is addressed by digest, so
+// storing it again is harmless, and the caller holds the lock of the key so a
+// concurrent release cannot delete it before the referencing row commits.
+func (h *Handler) putBlob(ctx context.Context, key string, upload *upload) error {
+	if _, err := upload.spool.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if err := h.blobs.Put(ctx, key, upload.spool, upload.size, upload.contentType); err != nil {
+		return fmt.Errorf("%w: %v", errStoreBlob, err)
+	}
+	return nil
+}
+
+func (h *Handler) storeAttachment(w http.ResponseWriter, r *http.Request, ticketID string, upload *upload) {
+	ctx := r.Context()
+	key := attachmentBlobKey(tenant.ID(ctx), upload.sum)
+	attachment := &TicketAttachment{
+		TicketID:    ticketID,
+		FileName:    upload.fileName,
+		ContentType: upload.contentType,
+		Size:        upload.size,
+		SHA256:      upload.sum,
+		BlobKey:     key,
+		UploadedBy:  requestActor(r),
+	}
+
+	var existing *TicketAttachment
+	stored := false
+	err := h.attachments.Transaction(ctx, func(ctx context.Context) error {
+		if err := h.attachments.LockBlob(ctx, key); err != nil {
+			return err
+		}
+		found, err := h.attachments.FindAttachmentByHash(ctx, ticketID, upload.sum)
+		switch {
+		case err == nil:
+			existing = found
+			return nil
+		case !IsNotFound(err):
+			return err
+		}
+
+		if err := h.putBlob(ctx, key, upload); err != nil {
+			return err
+		}
+		stored = true
+		if err := h.attachments.CreateAttachment(ctx, attachment); err != nil {
+			return err
+		}
+		return h.events.Emit(ctx, EventTicketAttachmentAdded, ticketID, attachment.ToDTO())
+	})
+	switch {
+	case err != nil:
+		if stored {
+			releaseBlobs(ctx, h.attachments, h.blobs, key)
+		}
+		renderStoreError(w, err, h.renderTicketError)
+		return
+	case existing != nil:
+		httpx.JSON(w, http.StatusOK, map[string]any{"data": existing.ToDTO()})
+		return
+	}
+	h.audit.Record(ctx, audit.Change{Action: EventTicketAttachmentAdded, ResourceID: ticketID, After: attachment.ToDTO()})
+
+	httpx.JSON(w, http.StatusCreated, map[string]any{"data": attachment.ToDTO()})
+}
+
+// downloadAttachment streams the content with the type recorded at upload.
+func (h *Handler) downloadAttachment(w http.ResponseWriter, r *http.Request) {
+	attachment, err := h.attachments.FindAttachment(r.Context(), chi.URLParam(r, "id"), chi.URLParam(r, "attachmentId"))
+	if err != nil {
+		renderAttachmentError(w, err)
+		return
+	}
+
+	etag := `"` + attachment.SHA256 + `"`
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	content, err := h.blobs.Open(r.Context(), attachment.BlobKey)
+	if err != nil {
+		if errors.Is(err, ErrBlobNotFound) {
+			httpx.Error(w, http.StatusNotFound, "attachment content is missing")
+			return
+		}
+		httpx.Error(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	defer content.Close()
+
+	header := w.Header()
+	header.Set("Content-Type", attachment.ContentType)
+	header.Set("Content-Length", strconv.FormatInt(attachment.Size, 10))
+	header.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": attachment.FileName}))
+	header.Set("X-Content-Type-Options", "nosniff")
+	header.Set("ETag", etag)
+	w.WriteHeader(http.StatusOK)
+	io.Copy(w, content)
+}
+
+func (h *Handler) deleteAttachment(w http.ResponseWriter, r *http.Request) {
+	var attachment *TicketAttachment
+	err := h.events.Transaction(r.Context(), func(ctx context.Context) error {
+		var err error
+		if attachment, err = h.attachments.DeleteAttachment(ctx, chi.URLParam(r, "id"), chi.URLParam(r, "attachmentId")); err != nil {
+			return err
+		}
+		return h.events.Emit(ctx, EventTicketAttachmentDeleted, attachment.TicketID, attachment.ToDTO())
+	})
+	if err != nil {
+		renderAttachmentError(w, err)
+		return
+	}
+	releaseBlobs(r.Context(), h.attachments, h.blobs, attachment.BlobKey)
+	h.audit.Record(r.Context(), audit.Change{Action: EventTicketAttachmentDeleted, ResourceID: attachment.TicketID, Before: attachment.ToDTO()})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// releaseBlobs deletes blobs no attachment references anymore. The check and
+// the delete hold the lock of the key, so an upload storing the same content
+// either commits its reference first or stores the blob again afterwards.
+// Failures only leave orphaned content behind, so they are logged rather than
+// reported.
+func releaseBlobs(ctx context.Context, attachments AttachmentRepository, blobs BlobStore, keys ...string) {
+	for _, key := range keys {
+		err := attachments.Transaction(ctx, func(ctx context.Context) error {
+			if err := attachments.LockBlob(ctx, key); err != nil {
+				return err
+			}
+			inUse, err := attachments.BlobInUse(ctx, key)
+			if err != nil || inUse {
+				return err
+			}
+			return blobs.Delete(ctx, key)
+		})
+		if err != nil {
+			log.Printf("ticket attachments: failed to release blob %s: %v", key, err)
+		}
+	}
+}
+
+// DeletedTicketPurge returns the purge function for a database.Purger that
+// hard-deletes tickets past the retention period along with their attachment
+// content. Soft-deleted tickets keep their attachments so they can be restored.
+func DeletedTicketPurge(repo *GormRepository, blobs BlobStore) database.PurgeFunc {
+	return func(ctx context.Context, before time.Time, limit int) (int, error) {
+		purged, keys, err := repo.PurgeDeleted(ctx, before, limit)
+		if err != nil {
+			return 0, err
+		}
+		if blobs != nil {
+			releaseBlobs(ctx, repo, blobs, keys...)
+		}
+		return purged, nil
+	}
+}
+
+func renderAttachmentError(w http.ResponseWriter, err error) {
+	if IsNotFound(err) {
+		httpx.Error(w, http.StatusNotFound, "attachment not found")
+		return
+	}
+	httpx.Error(w, http.StatusInternalServerError, err.Error())
+}
+
+// renderStoreError reports blob store failures as 502 and hands other errors
+// to render.
+func renderStoreError(w http.ResponseWriter, err error, render func(http.ResponseWriter, error)) {
+	if errors.Is(err, errStoreBlob) {
+		httpx.Error(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	render(w, err)
+}
+
+func renderUploadError(w http.ResponseWriter, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		httpx.Error(w, http.StatusRequestEntityTooLarge, "upload is too large")
+		return
+	}
+	httpx.Error(w, http.StatusBadRequest, err.Error())
+}
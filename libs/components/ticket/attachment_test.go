@@ -0,0 +1,315 @@
+package ticket
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/pflow/shared/database"
+	"github.com/pflow/shared/mq"
+)
+
+// pngHeader is enough of a PNG for content sniffing.
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+type attachmentTestServer struct {
+	t      *testing.T
+	router http.Handler
+	root   string
+	purge  database.PurgeFunc
+}
+
+func newAttachmentTestServer(t *testing.T, limits AttachmentLimits) attachmentTestServer {
+	t.Helper()
+	repo := NewGormRepository(newTestDB(t))
+	root := t.TempDir()
+	blobs, err := NewFileBlobStore(root)
+	if err != nil {
+		t.Fatalf("blob store: %v", err)
+	}
+
+	router := chi.NewRouter()
+	NewHandler(repo, WithAttachments(repo, blobs, limits)).Mount(router, "")
+	return attachmentTestServer{t: t, router: router, root: root, purge: DeletedTicketPurge(repo, blobs)}
+}
+
+func (s attachmentTestServer) createTicket(title string) string {
+	s.t.Helper()
+	rec := s.serve(http.MethodPost, "/tickets", "application/json",
+		bytes.NewBufferString(`{"title":"`+title+`","formId":"3f1c2a4e-8b7d-4c6e-9a5f-1e2d3c4b5a69"}`))
+	if rec.Code != http.StatusCreated {
+		s.t.Fatalf("create ticket: %d %s", rec.Code, rec.Body)
+	}
+	return s.decode(rec)["id"].(string)
+}
+
+func (s attachmentTestServer) upload(ticketID, fileName, contentType string, content []byte) *httptest.ResponseRecorder {
+	s.t.Helper()
+	return s.uploadTo("/tickets/"+ticketID+"/attachments", fileName, contentType, content)
+}
+
+func (s attachmentTestServer) uploadTo(target, fileName, contentType string, content []byte) *httptest.ResponseRecorder {
+	s.t.Helper()
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	header := make(map[string][]string)
+	header["Content-Disposition"] = []string{`form-data; name="file"; filename="` + fileName + `"`}
+	header["Content-Type"] = []string{contentType}
+	part, err := writer.CreatePart(header)
+	if err != nil {
+		s.t.Fatalf("multipart: %v", err)
+	}
+	part.Write(content)
+	writer.Close()
+	return s.serve(http.MethodPost, target, writer.FormDataContentType(), &body)
+}
+
+func (s attachmentTestServer) serve(method, path, contentType string, body *bytes.Buffer) *httptest.ResponseRecorder {
+	if body == nil {
+		body = &bytes.Buffer{}
+	}
+	req := httptest.NewRequest(method, path, body)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+	return rec
+}
+
+func (s attachmentTestServer) decode(rec *httptest.ResponseRecorder) map[string]any {
+	s.t.Helper()
+	var payload struct {
+		Data map[string]any `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &payload); err != nil {
+		s.t.Fatalf("decode: %v", err)
+	}
+	return payload.Data
+}
+
+func (s attachmentTestServer) blobCount() int {
+	count := 0
+	filepath.Walk(s.root, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			count++
+		}
+		return nil
+	})
+	return count
+}
+
+func TestAttachmentsUploadAndDownloadWithSniffedType(t *testing.T) {
+	server := newAttachmentTestServer(t, AttachmentLimits{})
+	ticketID := server.createTicket("Broken screen")
+
+	// The client claims octet-stream; the content is recognisably a PNG.
+	content := append(append([]byte{}, pngHeader...), []byte("pixels")...)
+	rec := server.upload(ticketID, "../../screenshot.png", "application/octet-stream", content)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("upload: %d %s", rec.Code, rec.Body)
+	}
+	attachment := server.decode(rec)
+	if attachment["contentType"] != "image/png" || attachment["fileName"] != "screenshot.png" || attachment["size"] != float64(len(content)) {
+		t.Fatalf("unexpected attachment %v", attachment)
+	}
+
+	download := server.serve(http.MethodGet, "/tickets/"+ticketID+"/attachments/"+attachment["id"].(string), "", nil)
+	if download.Code != http.StatusOK {
+		t.Fatalf("download: %d %s", download.Code, download.Body)
+	}
+	if got := download.Header().Get("Content-Type"); got != "image/png" {
+		t.Fatalf("expected image/png, got %q", got)
+	}
+	if got := download.Header().Get("Content-Disposition"); got != `attachment; filename=screenshot.png` {
+		t.Fatalf("unexpected disposition %q", got)
+	}
+	if !bytes.Equal(download.Body.Bytes(), content) {
+		t.Fatal("downloaded content differs from the upload")
+	}
+
+	other := server.createTicket("Another ticket")
+	if rec := server.serve(http.MethodGet, "/tickets/"+other+"/attachments/"+attachment["id"].(string), "", nil); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected attachment of another ticket to miss, got %d", rec.Code)
+	}
+}
+
+func TestAttachmentsEnforceLimits(t *testing.T) {
+	server := newAttachmentTestServer(t, AttachmentLimits{MaxBytes: 64, AllowedTypes: []string{"image/png", "text/plain"}})
+	ticketID := server.createTicket("Limits")
+
+	if rec := server.upload(ticketID, "big.txt", "text/plain", bytes.Repeat([]byte("a"), 65)); rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected oversized upload to be rejected, got %d", rec.Code)
+	}
+	if rec := server.upload(ticketID, "doc.pdf", "application/pdf", []byte("%PDF-1.7\n")); rec.Code != http.StatusUnsupportedMediaType {
+		t.Fatalf("expected disallowed type to be rejected, got %d", rec.Code)
+	}
+	// Claiming an allowed type does not smuggle in other content.
+	if rec := server.upload(ticketID, "fake.png", "image/png", []byte("%PDF-1.7\n")); rec.Code != http.StatusUnsupportedMediaType {
+		t.Fatalf("expected sniffed type to win over the claim, got %d", rec.Code)
+	}
+	if rec := server.upload("9d1f7f0e-5c1a-4f4e-8b51-2f0b7e3d6c21", "a.txt", "text/plain", []byte("hi")); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected upload to unknown ticket to miss, got %d", rec.Code)
+	}
+	if server.blobCount() != 0 {
+		t.Fatalf("expected rejected uploads to store nothing, found %d blobs", server.blobCount())
+	}
+}
+
+func TestAttachmentsDeduplicateAndCleanUp(t *testing.T) {
+	server := newAttachmentTestServer(t, AttachmentLimits{})
+	first := server.createTicket("First ticket")
+	second := server.createTicket("Second ticket")
+	content := []byte("same log excerpt")
+
+	created := server.upload(first, "log.txt", "text/plain", content)
+	if created.Code != http.StatusCreated {
+		t.Fatalf("upload: %d %s", created.Code, created.Body)
+	}
+	again := server.upload(first, "log-copy.txt", "text/plain", content)
+	if again.Code != http.StatusOK || server.decode(again)["id"] != server.decode(created)["id"] {
+		t.Fatalf("expected re-upload to return the existing attachment, got %d %s", again.Code, again.Body)
+	}
+	if rec := server.upload(second, "log.txt", "text/plain", content); rec.Code != http.StatusCreated {
+		t.Fatalf("upload to second ticket: %d", rec.Code)
+	}
+	if server.blobCount() != 1 {
+		t.Fatalf("expected identical content to share one blob, found %d", server.blobCount())
+	}
+
+	if rec := server.serve(http.MethodDelete, "/tickets/"+first, "", nil); rec.Code != http.StatusNoContent {
+		t.Fatalf("delete first ticket: %d", rec.Code)
+	}
+	if server.blobCount() != 1 {
+		t.Fatal("expected the blob to survive while the second ticket references it")
+	}
+	if rec := server.serve(http.MethodDelete, "/tickets/"+second, "", nil); rec.Code != http.StatusNoContent {
+		t.Fatalf("delete second ticket: %d", rec.Code)
+	}
+	if server.blobCount() != 1 {
+		t.Fatal("expected deleted tickets to keep their attachments until purged")
+	}
+	if rec := server.serve(http.MethodPost, "/tickets/"+second+"/restore", "", nil); rec.Code != http.StatusOK {
+		t.Fatalf("restore second ticket: %d %s", rec.Code, rec.Body)
+	}
+	if rec := server.serve(http.MethodGet, "/tickets/"+second+"/attachments", "", nil); rec.Code != http.StatusOK || !bytes.Contains(rec.Body.Bytes(), []byte("log.txt")) {
+		t.Fatalf("expected the restored ticket to keep its attachment, got %d %s", rec.Code, rec.Body)
+	}
+	if rec := server.serve(http.MethodDelete, "/tickets/"+second, "", nil); rec.Code != http.StatusNoContent {
+		t.Fatalf("delete second ticket again: %d", rec.Code)
+	}
+
+	if purged, err := server.purge(context.Background(), time.Now().Add(-time.Hour), 10); err != nil || purged != 0 {
+		t.Fatalf("expected recently deleted tickets to be kept, purged %d (%v)", purged, err)
+	}
+	if purged, err := server.purge(context.Background(), time.Now().Add(time.Minute), 10); err != nil || purged != 2 {
+		t.Fatalf("expected both tickets to be purged, purged %d (%v)", purged, err)
+	}
+	if server.blobCount() != 0 {
+		t.Fatalf("expected purging the last ticket to remove the blob, found %d", server.blobCount())
+	}
+	if rec := server.serve(http.MethodPost, "/tickets/"+second+"/restore", "", nil); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected a purged ticket to be gone, got %d", rec.Code)
+	}
+}
+
+func TestAttachmentsRestoreContentReleasedConcurrently(t *testing.T) {
+	server := newAttachmentTestServer(t, AttachmentLimits{})
+	first := server.createTicket("First ticket")
+	second := server.createTicket("Second ticket")
+	content := []byte("same log excerpt")
+
+	if rec := server.upload(first, "log.txt", "text/plain", content); rec.Code != http.StatusCreated {
+		t.Fatalf("upload: %d %s", rec.Code, rec.Body)
+	}
+	// A release that raced the upload removed the shared blob.
+	if err := os.RemoveAll(server.root); err != nil {
+		t.Fatalf("remove blobs: %v", err)
+	}
+
+	rec := server.upload(second, "log.txt", "text/plain", content)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("upload to second ticket: %d %s", rec.Code, rec.Body)
+	}
+	download := server.serve(http.MethodGet, "/tickets/"+second+"/attachments/"+server.decode(rec)["id"].(string), "", nil)
+	if download.Code != http.StatusOK || download.Body.String() != string(content) {
+		t.Fatalf("expected the upload to store the content again, got %d %s", download.Code, download.Body)
+	}
+}
+
+func TestSubmissionAttachmentsMoveToTheirTicket(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewGormRepository(db)
+	submissions := NewSubmissionRepository(db)
+	blobs, err := NewFileBlobStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("blob store: %v", err)
+	}
+	router := chi.NewRouter()
+	NewHandler(repo, WithSubmissionCoordinator(NewQueueCoordinator(submissions)), WithAttachments(repo, blobs, AttachmentLimits{})).Mount(router, "")
+	server := attachmentTestServer{t: t, router: router}
+
+	rec := server.serve(http.MethodPost, "/tickets/submissions", "application/json",
+		bytes.NewBufferString(`{"title":"Broken screen","formId":"3f1c2a4e-8b7d-4c6e-9a5f-1e2d3c4b5a69"}`))
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("submit: %d %s", rec.Code, rec.Body)
+	}
+	submissionID := server.decode(rec)["id"].(string)
+
+	content := append(append([]byte{}, pngHeader...), []byte("pixels")...)
+	if rec := server.uploadTo("/tickets/submissions/"+submissionID+"/attachments", "screen.png", "image/png", content); rec.Code != http.StatusCreated {
+		t.Fatalf("stage: %d %s", rec.Code, rec.Body)
+	}
+	if rec := server.uploadTo("/tickets/submissions/"+submissionID+"/attachments", "screen-again.png", "image/png", content); rec.Code != http.StatusOK {
+		t.Fatalf("expected staging the same content again to return the staged file, got %d %s", rec.Code, rec.Body)
+	}
+	if rec := server.uploadTo("/tickets/submissions/00000000-0000-0000-0000-000000000000/attachments", "screen.png", "image/png", content); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected staging for an unknown submission to miss, got %d", rec.Code)
+	}
+
+	worker := NewQueueWorker(submissions, repo, WithWorkerAttachments(repo), WithWorkerEvents(NewEventOutbox(db)))
+	message, _ := json.Marshal(submissionMessage{SubmissionID: submissionID, TenantID: "default"})
+	if err := worker.HandleMessage(context.Background(), mq.Message{Value: message}); err != nil {
+		t.Fatalf("handle: %v", err)
+	}
+	submission, err := submissions.FindByID(context.Background(), submissionID)
+	if err != nil || submission.TicketID == nil {
+		t.Fatalf("expected the submission to complete, got %+v (%v)", submission, err)
+	}
+	ticketID := *submission.TicketID
+
+	if rec := server.serve(http.MethodGet, "/tickets/submissions/"+submissionID+"/attachments", "", nil); rec.Code != http.StatusOK || bytes.Contains(rec.Body.Bytes(), []byte("screen.png")) {
+		t.Fatalf("expected no staged files once the ticket exists, got %d %s", rec.Code, rec.Body)
+	}
+	listed := server.serve(http.MethodGet, "/tickets/"+ticketID+"/attachments", "", nil)
+	if listed.Code != http.StatusOK || !bytes.Contains(listed.Body.Bytes(), []byte("screen.png")) {
+		t.Fatalf("expected the staged file on the ticket, got %d %s", listed.Code, listed.Body)
+	}
+
+	// Uploads after completion land on the ticket directly.
+	late := server.uploadTo("/tickets/submissions/"+submissionID+"/attachments", "notes.txt", "text/plain", []byte("late notes"))
+	if late.Code != http.StatusCreated || server.decode(late)["ticketId"] != ticketID {
+		t.Fatalf("expected a late upload to attach to the ticket, got %d %s", late.Code, late.Body)
+	}
+}
+
+func TestFileBlobStoreRejectsEscapingKeys(t *testing.T) {
+	store, err := NewFileBlobStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("blob store: %v", err)
+	}
+	for _, key := range []string{"", "../outside", "a/../../b", "/absolute"} {
+		if err := store.Put(context.Background(), key, bytes.NewReader(nil), 0, ""); err == nil {
+			t.Fatalf("expected key %q to be rejected", key)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package ticket
+
+// Audit actions of the changes that are not announced as domain events.
+const (
+	auditSubmissionSubmitted = "ticket_submission.submitted"
+	auditSubmissionRequeued  = "ticket_submission.requeued"
+	// Files uploaded for a submission are staged until its ticket exists.
+	auditSubmissionAttachmentStaged = "ticket_submission.attachment_staged"
+
+	auditSLAPolicyCreated = "sla_policy.created"
+	auditSLAPolicyUpdated = "sla_policy.updated"
+	auditSLAPolicyDeleted = "sla_policy.deleted"
+
+	auditAssignmentRuleCreated = "assignment_rule.created"
+	auditAssignmentRuleUpdated = "assignment_rule.updated"
+	auditAssignmentRuleDeleted = "assignment_rule.deleted"
+
+	auditWebhookCreated     = "webhook.created"
+	auditWebhookUpdated     = "webhook.updated"
+	auditWebhookDeleted     = "webhook.deleted"
+	auditWebhookRedelivered = "webhook.redelivered"
+)
@@ -0,0 +1,105 @@
+package ticket
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ErrBlobNotFound is returned by BlobStore implementations for unknown keys.
+var ErrBlobNotFound = errors.New("blob not found")
+
+// BlobStore keeps attachment content outside the database. Keys are slash
+// separated paths chosen by the caller; storing a key again replaces it.
+type BlobStore interface {
+	Put(ctx context.Context, key string, content io.Reader, size int64, contentType string) error
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// FileBlobStore stores blobs as files below a root directory.
+type FileBlobStore struct {
+	root string
+}
+
+var _ BlobStore = (*FileBlobStore)(nil)
+
+// NewFileBlobStore creates the root directory when missing.
+func NewFileBlobStore(root string) (*FileBlobStore, error) {
+	if strings.TrimSpace(root) == "" {
+		return nil, errors.New("blob store root must not be empty")
+	}
+	if err := os.MkdirAll(root, 0o750); err != nil {
+		return nil, fmt.Errorf("create blob store root: %w", err)
+	}
+	return &FileBlobStore{root: root}, nil
+}
+
+// Put writes the content to a temporary file first so readers never observe a
+// partially written blob.
+func (s *FileBlobStore) Put(ctx context.Context, key string, content io.Reader, size int64, contentType string) error {
+	target, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0o750); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(target), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	written, err := io.Copy(tmp, content)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if size >= 0 && written != size {
+		return fmt.Errorf("blob %s: wrote %d of %d bytes", key, written, size)
+	}
+	return os.Rename(tmp.Name(), target)
+}
+
+// Open returns the content of a blob.
+func (s *FileBlobStore) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	target, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(target)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrBlobNotFound
+	}
+	return file, err
+}
+
+// Delete removes a blob; deleting a missing blob is not an error.
+func (s *FileBlobStore) Delete(ctx context.Context, key string) error {
+	target, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(target); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (s *FileBlobStore) path(key string) (string, error) {
+	clean := path.Clean("/" + key)
+	if key == "" || clean == "/" || clean[1:] != key {
+		return "", fmt.Errorf("invalid blob key %q", key)
+	}
+	return filepath.Join(s.root, filepath.FromSlash(key)), nil
+}
@@ -0,0 +1,160 @@
+package ticket
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// calendarHorizon bounds how far ahead BusinessCalendar.Add searches for
+// business hours, so a calendar that is closed for good fails instead of
+// looping forever.
+const calendarHorizon = 5 * 366
+
+var weekdays = map[string]time.Weekday{
+	"sunday":    time.Sunday,
+	"monday":    time.Monday,
+	"tuesday":   time.Tuesday,
+	"wednesday": time.Wednesday,
+	"thursday":  time.Thursday,
+	"friday":    time.Friday,
+	"saturday":  time.Saturday,
+}
+
+// BusinessHours opens a calendar on a weekday between Start and End, given as
+// "HH:MM" in the calendar time zone. End may be "24:00".
+type BusinessHours struct {
+	Day   string `json:"day"`
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+// BusinessCalendar describes when SLA clocks run. A calendar without hours is
+// open around the clock; holidays are "YYYY-MM-DD" dates on which it stays
+// closed.
+type BusinessCalendar struct {
+	Timezone string          `json:"timezone,omitempty"`
+	Hours    []BusinessHours `json:"hours,omitempty"`
+	Holidays []string        `json:"holidays,omitempty"`
+}
+
+type businessWindow struct {
+	start, end int // minutes after midnight
+}
+
+type compiledCalendar struct {
+	location *time.Location
+	windows  map[time.Weekday][]businessWindow
+	holidays map[string]struct{}
+}
+
+// Validate reports the first problem with the calendar definition.
+func (c BusinessCalendar) Validate() error {
+	_, err := c.compile()
+	return err
+}
+
+// Add returns the moment d of business time after start has elapsed.
+func (c BusinessCalendar) Add(start time.Time, d time.Duration) (time.Time, error) {
+	calendar, err := c.compile()
+	if err != nil {
+		return time.Time{}, err
+	}
+	if d <= 0 || (len(calendar.windows) == 0 && len(calendar.holidays) == 0) {
+		return start.Add(d), nil
+	}
+	if len(calendar.windows) == 0 {
+		// Without hours the calendar is open around the clock, but still
+		// closed on its holidays.
+		for _, day := range weekdays {
+			calendar.windows[day] = []businessWindow{{start: 0, end: 24 * 60}}
+		}
+	}
+
+	remaining := d
+	cursor := start.In(calendar.location)
+	for day := 0; day < calendarHorizon; day++ {
+		year, month, date := cursor.Date()
+		if _, closed := calendar.holidays[cursor.Format("2006-01-02")]; !closed {
+			for _, window := range calendar.windows[cursor.Weekday()] {
+				// time.Date normalises the minutes, which keeps windows correct
+				// across daylight saving changes.
+				opens := time.Date(year, month, date, 0, window.start, 0, 0, calendar.location)
+				closes := time.Date(year, month, date, 0, window.end, 0, 0, calendar.location)
+				if !closes.After(cursor) {
+					continue
+				}
+				if opens.After(cursor) {
+					cursor = opens
+				}
+				available := closes.Sub(cursor)
+				if remaining <= available {
+					return cursor.Add(remaining), nil
+				}
+				remaining -= available
+				cursor = closes
+			}
+		}
+		cursor = time.Date(year, month, date+1, 0, 0, 0, 0, calendar.location)
+	}
+	return time.Time{}, errors.New("business calendar has no business hours ahead")
+}
+
+func (c BusinessCalendar) compile() (compiledCalendar, error) {
+	compiled := compiledCalendar{
+		location: time.UTC,
+		windows:  map[time.Weekday][]businessWindow{},
+		holidays: map[string]struct{}{},
+	}
+	if zone := strings.TrimSpace(c.Timezone); zone != "" {
+		location, err := time.LoadLocation(zone)
+		if err != nil {
+			return compiled, fmt.Errorf("unknown time zone %q", zone)
+		}
+		compiled.location = location
+	}
+
+	for _, hours := range c.Hours {
+		day, ok := weekdays[strings.ToLower(strings.TrimSpace(hours.Day))]
+		if !ok {
+			return compiled, fmt.Errorf("unknown weekday %q", hours.Day)
+		}
+		start, err := parseClock(hours.Start)
+		if err != nil {
+			return compiled, err
+		}
+		end, err := parseClock(hours.End)
+		if err != nil {
+			return compiled, err
+		}
+		if start >= end {
+			return compiled, fmt.Errorf("business hours on %s must start before they end", hours.Day)
+		}
+		compiled.windows[day] = append(compiled.windows[day], businessWindow{start: start, end: end})
+	}
+	for day := range compiled.windows {
+		windows := compiled.windows[day]
+		sort.Slice(windows, func(i, j int) bool { return windows[i].start < windows[j].start })
+	}
+
+	for _, holiday := range c.Holidays {
+		date, err := time.Parse("2006-01-02", strings.TrimSpace(holiday))
+		if err != nil {
+			return compiled, fmt.Errorf("holiday %q must be a YYYY-MM-DD date", holiday)
+		}
+		compiled.holidays[date.Format("2006-01-02")] = struct{}{}
+	}
+	return compiled, nil
+}
+
+// parseClock converts "HH:MM" into minutes after midnight.
+func parseClock(value string) (int, error) {
+	var hours, minutes int
+	if _, err := fmt.Sscanf(strings.TrimSpace(value), "%d:%d", &hours, &minutes); err != nil ||
+		hours < 0 || minutes < 0 || minutes > 59 || hours*60+minutes > 24*60 {
+		return 0, fmt.Errorf("invalid time of day %q", value)
+	}
+	return hours*60 + minutes, nil
+}
@@ -0,0 +1,313 @@
+package ticket
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/pflow/shared/audit"
+	"github.com/pflow/shared/auth"
+	"github.com/pflow/shared/httpx"
+)
+
+const (
+	// VisibilityPublic marks a comment every ticket viewer can read.
+	VisibilityPublic = "public"
+	// VisibilityInternal marks a comment only agents holding ticket:internal can read.
+	VisibilityInternal = "internal"
+)
+
+// maxCommentLength caps comment bodies, counted in characters.
+const maxCommentLength = 10000
+
+var (
+	// ErrCommentDeleted is returned when editing a comment that was deleted.
+	ErrCommentDeleted = errors.New("comment was deleted")
+	// ErrNotCommentAuthor is returned when someone other than the author edits a comment.
+	ErrNotCommentAuthor = errors.New("only the author can change this comment")
+)
+
+// TicketComment is a markdown note on a ticket. Deleted comments keep their
+// place in the conversation but lose their body.
+type TicketComment struct {
+	ID         string     `json:"id" gorm:"type:uuid;primaryKey"`
+	TicketID   string     `json:"ticketId" gorm:"type:uuid;not null;index"`
+	AuthorID   string     `json:"authorId"`
+	Body       string     `json:"body" gorm:"type:text"`
+	Visibility string     `json:"visibility" gorm:"type:varchar(16);not null;default:public"`
+	Edited     bool       `json:"edited" gorm:"not null;default:false"`
+	EditedAt   *time.Time `json:"editedAt"`
+	Deleted    bool       `json:"deleted" gorm:"not null;default:false"`
+	DeletedAt  *time.Time `json:"deletedAt"`
+	CreatedAt  time.Time  `json:"createdAt" gorm:"index"`
+	UpdatedAt  time.Time  `json:"updatedAt"`
+}
+
+// BeforeCreate assigns a UUID and the default visibility when missing.
+func (c *TicketComment) BeforeCreate(tx *gorm.DB) error {
+	if c.ID == "" {
+		c.ID = uuid.NewString()
+	}
+	if c.Visibility == "" {
+		c.Visibility = VisibilityPublic
+	}
+	return nil
+}
+
+// ToDTO exposes the comment for clients.
+func (c TicketComment) ToDTO() map[string]any {
+	dto := map[string]any{
+		"id":         c.ID,
+		"ticketId":   c.TicketID,
+		"authorId":   c.AuthorID,
+		"visibility": c.Visibility,
+		"edited":     c.Edited,
+		"deleted":    c.Deleted,
+		"createdAt":  c.CreatedAt,
+		"updatedAt":  c.UpdatedAt,
+	}
+	if !c.Deleted {
+		dto["body"] = c.Body
+	}
+	if c.EditedAt != nil {
+		dto["editedAt"] = c.EditedAt
+	}
+	if c.DeletedAt != nil {
+		dto["deletedAt"] = c.DeletedAt
+	}
+	return dto
+}
+
+// EventDTO is the comment as announced in domain events, which reach Kafka and
+// partner webhooks. Internal comments are announced without their body or
+// author, so agent-only notes stay inside the system.
+func (c TicketComment) EventDTO() map[string]any {
+	if c.Visibility != VisibilityInternal {
+		return c.ToDTO()
+	}
+	return map[string]any{
+		"id":         c.ID,
+		"ticketId":   c.TicketID,
+		"visibility": c.Visibility,
+	}
+}
+
+// CommentRepository persists ticket comments. Every method fails with
+// gorm.ErrRecordNotFound when the ticket is missing from the current tenant.
+type CommentRepository interface {
+	ListComments(ctx context.Context, ticketID string, includeInternal bool) ([]TicketComment, error)
+	FindComment(ctx context.Context, ticketID, commentID string) (*TicketComment, error)
+	CreateComment(ctx context.Context, comment *TicketComment) error
+	UpdateComment(ctx context.Context, ticketID, commentID string, updates map[string]any) (*TicketComment, error)
+	DeleteComment(ctx context.Context, ticketID, commentID string) error
+}
+
+type createCommentRequest struct {
+	Body       string `json:"body"`
+	Visibility string `json:"visibility"`
+}
+
+type updateCommentRequest struct {
+	Body       *string `json:"body"`
+	Visibility *string `json:"visibility"`
+}
+
+func (h *Handler) listComments(w http.ResponseWriter, r *http.Request) {
+	comments, err := h.comments.ListComments(r.Context(), chi.URLParam(r, "id"), h.authz.Allows(r, auth.PermissionTicketInternal))
+	if err != nil {
+		h.renderTicketError(w, err)
+		return
+	}
+
+	items := make([]map[string]any, 0, len(comments))
+	for _, comment := range comments {
+		items = append(items, comment.ToDTO())
+	}
+
+	httpx.JSON(w, http.StatusOK, map[string]any{"data": items})
+}
+
+func (h *Handler) createComment(w http.ResponseWriter, r *http.Request) {
+	var payload createCommentRequest
+	if err := decodeJSON(r, &payload); err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	body, err := normalizeCommentBody(payload.Body)
+	if err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	visibility, status, err := h.commentVisibility(r, payload.Visibility)
+	if err != nil {
+		httpx.Error(w, status, err.Error())
+		return
+	}
+
+	comment := &TicketComment{
+		TicketID:   chi.URLParam(r, "id"),
+		AuthorID:   requestActor(r),
+		Body:       body,
+		Visibility: visibility,
+	}
+	err = h.events.Transaction(r.Context(), func(ctx context.Context) error {
+		if err := h.comments.CreateComment(ctx, comment); err != nil {
+			return err
+		}
+		return h.events.Emit(ctx, EventTicketCommentAdded, comment.TicketID, comment.EventDTO())
+	})
+	if err != nil {
+		h.renderTicketError(w, err)
+		return
+	}
+	h.audit.Record(r.Context(), audit.Change{Action: EventTicketCommentAdded, ResourceID: comment.TicketID, After: comment.ToDTO()})
+
+	httpx.JSON(w, http.StatusCreated, map[string]any{"data": comment.ToDTO()})
+}
+
+func (h *Handler) updateComment(w http.ResponseWriter, r *http.Request) {
+	var payload updateCommentRequest
+	if err := decodeJSON(r, &payload); err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	updates := make(map[string]any)
+	if payload.Body != nil {
+		body, err := normalizeCommentBody(*payload.Body)
+		if err != nil {
+			httpx.Error(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		updates["body"] = body
+	}
+	if payload.Visibility != nil {
+		visibility, status, err := h.commentVisibility(r, *payload.Visibility)
+		if err != nil {
+			httpx.Error(w, status, err.Error())
+			return
+		}
+		updates["visibility"] = visibility
+	}
+	if len(updates) == 0 {
+		httpx.Error(w, http.StatusBadRequest, "no updates provided")
+		return
+	}
+
+	ticketID, commentID := chi.URLParam(r, "id"), chi.URLParam(r, "commentId")
+	before, err := h.ownComment(r, ticketID, commentID)
+	if err != nil {
+		h.renderCommentError(w, err)
+		return
+	}
+
+	var comment *TicketComment
+	err = h.events.Transaction(r.Context(), func(ctx context.Context) error {
+		var err error
+		if comment, err = h.comments.UpdateComment(ctx, ticketID, commentID, updates); err != nil {
+			return err
+		}
+		return h.events.Emit(ctx, EventTicketCommentUpdated, ticketID, comment.EventDTO())
+	})
+	if err != nil {
+		h.renderCommentError(w, err)
+		return
+	}
+	h.audit.Record(r.Context(), audit.Change{Action: EventTicketCommentUpdated, ResourceID: ticketID, Before: before.ToDTO(), After: comment.ToDTO()})
+
+	httpx.JSON(w, http.StatusOK, map[string]any{"data": comment.ToDTO()})
+}
+
+func (h *Handler) deleteComment(w http.ResponseWriter, r *http.Request) {
+	ticketID, commentID := chi.URLParam(r, "id"), chi.URLParam(r, "commentId")
+	before, err := h.ownComment(r, ticketID, commentID)
+	if err != nil {
+		h.renderCommentError(w, err)
+		return
+	}
+
+	err = h.events.Transaction(r.Context(), func(ctx context.Context) error {
+		if err := h.comments.DeleteComment(ctx, ticketID, commentID); err != nil {
+			return err
+		}
+		return h.events.Emit(ctx, EventTicketCommentDeleted, ticketID, map[string]any{"id": commentID, "ticketId": ticketID})
+	})
+	if err != nil {
+		h.renderCommentError(w, err)
+		return
+	}
+	h.audit.Record(r.Context(), audit.Change{Action: EventTicketCommentDeleted, ResourceID: ticketID, Before: before.ToDTO()})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ownComment loads a comment the caller may change: their own, or any comment
+// when they hold ticket:edit. Internal comments stay invisible to callers who
+// cannot read them.
+func (h *Handler) ownComment(r *http.Request, ticketID, commentID string) (*TicketComment, error) {
+	comment, err := h.comments.FindComment(r.Context(), ticketID, commentID)
+	if err != nil {
+		return nil, err
+	}
+	if comment.Visibility == VisibilityInternal && !h.authz.Allows(r, auth.PermissionTicketInternal) {
+		return nil, gorm.ErrRecordNotFound
+	}
+	if comment.Deleted {
+		return nil, ErrCommentDeleted
+	}
+	if comment.AuthorID != requestActor(r) && !h.authz.Allows(r, auth.PermissionTicketEdit) {
+		return nil, ErrNotCommentAuthor
+	}
+	return comment, nil
+}
+
+// commentVisibility validates a requested visibility, defaulting to public.
+// Writing internal comments requires ticket:internal.
+func (h *Handler) commentVisibility(r *http.Request, raw string) (string, int, error) {
+	visibility := strings.ToLower(strings.TrimSpace(raw))
+	switch visibility {
+	case "":
+		return VisibilityPublic, 0, nil
+	case VisibilityPublic:
+		return visibility, 0, nil
+	case VisibilityInternal:
+		if !h.authz.Allows(r, auth.PermissionTicketInternal) {
+			return "", http.StatusForbidden, errors.New("missing permission " + auth.PermissionTicketInternal)
+		}
+		return visibility, 0, nil
+	default:
+		return "", http.StatusBadRequest, errors.New("visibility must be public or internal")
+	}
+}
+
+func (h *Handler) renderCommentError(w http.ResponseWriter, err error) {
+	switch {
+	case IsNotFound(err):
+		httpx.Error(w, http.StatusNotFound, "comment not found")
+	case errors.Is(err, ErrCommentDeleted):
+		httpx.Error(w, http.StatusConflict, err.Error())
+	case errors.Is(err, ErrNotCommentAuthor):
+		httpx.Error(w, http.StatusForbidden, err.Error())
+	default:
+		httpx.Error(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+func normalizeCommentBody(raw string) (string, error) {
+	body := strings.TrimSpace(raw)
+	if body == "" {
+		return "", errors.New("body is required")
+	}
+	if utf8.RuneCountInString(body) > maxCommentLength {
+		return "", errors.New("body must be at most 10000 characters")
+	}
+	return body, nil
+}
@@ -0,0 +1,266 @@
+package ticket
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/pflow/components/outbox"
+	"github.com/pflow/shared/auth"
+)
+
+type commentTestClient struct {
+	t      *testing.T
+	router http.Handler
+}
+
+// newCommentTestClient serves the ticket routes with authorization enabled.
+// Requests name their caller with X-Test-User and X-Test-Permissions.
+func newCommentTestClient(t *testing.T) (commentTestClient, *Ticket) {
+	t.Helper()
+	repo := NewGormRepository(newTestDB(t))
+	ticket := &Ticket{Title: "VPN is down", Status: StatusOpen, Priority: "high"}
+	if err := repo.Create(context.Background(), ticket); err != nil {
+		t.Fatalf("create ticket: %v", err)
+	}
+
+	router := chi.NewRouter()
+	router.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal := auth.Principal{
+				UserID:      r.Header.Get("X-Test-User"),
+				Permissions: strings.Split(r.Header.Get("X-Test-Permissions"), ","),
+			}
+			r.Header.Set(auth.HeaderActor, principal.UserID)
+			next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), principal)))
+		})
+	})
+	NewHandler(repo, WithComments(repo), WithAuthorization()).Mount(router, "")
+	return commentTestClient{t: t, router: router}, ticket
+}
+
+func (c commentTestClient) do(method, path, user, permissions string, body any) (int, map[string]any) {
+	c.t.Helper()
+	var reader *bytes.Reader
+	if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			c.t.Fatalf("encode body: %v", err)
+		}
+		reader = bytes.NewReader(raw)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("X-Test-User", user)
+	req.Header.Set("X-Test-Permissions", permissions)
+	rec := httptest.NewRecorder()
+	c.router.ServeHTTP(rec, req)
+
+	var payload map[string]any
+	if rec.Body.Len() > 0 {
+		if err := json.Unmarshal(rec.Body.Bytes(), &payload); err != nil {
+			c.t.Fatalf("decode %s %s: %v", method, path, err)
+		}
+	}
+	return rec.Code, payload
+}
+
+func (c commentTestClient) items(path, user, permissions string) []map[string]any {
+	c.t.Helper()
+	code, payload := c.do(http.MethodGet, path, user, permissions, nil)
+	if code != http.StatusOK {
+		c.t.Fatalf("GET %s: %d %v", path, code, payload)
+	}
+	var items []map[string]any
+	for _, item := range payload["data"].([]any) {
+		items = append(items, item.(map[string]any))
+	}
+	return items
+}
+
+const (
+	agentPermissions    = "ticket:view,ticket:comment,ticket:internal,ticket:edit"
+	customerPermissions = "ticket:view,ticket:comment"
+)
+
+func TestInternalCommentsAreHiddenFromCustomers(t *testing.T) {
+	client, ticket := newCommentTestClient(t)
+	base := "/tickets/" + ticket.ID + "/comments"
+
+	if code, _ := client.do(http.MethodPost, base, "customer", customerPermissions, map[string]any{"body": "note", "visibility": VisibilityInternal}); code != http.StatusForbidden {
+		t.Fatalf("expected customer to be refused internal comments, got %d", code)
+	}
+	if code, _ := client.do(http.MethodPost, base, "agent", agentPermissions, map[string]any{"body": "Looks like **DNS**", "visibility": VisibilityInternal}); code != http.StatusCreated {
+		t.Fatalf("create internal comment: %d", code)
+	}
+	if code, _ := client.do(http.MethodPost, base, "agent", agentPermissions, map[string]any{"body": "We are on it"}); code != http.StatusCreated {
+		t.Fatalf("create public comment: %d", code)
+	}
+
+	if got := client.items(base, "agent", agentPermissions); len(got) != 2 {
+		t.Fatalf("expected agent to see both comments, got %d", len(got))
+	}
+	visible := client.items(base, "customer", customerPermissions)
+	if len(visible) != 1 || visible[0]["body"] != "We are on it" {
+		t.Fatalf("expected customer to see only the public comment, got %v", visible)
+	}
+	if timeline := client.items("/tickets/"+ticket.ID+"/timeline", "customer", customerPermissions); len(timeline) != 1 {
+		t.Fatalf("expected customer timeline to hide internal comments, got %v", timeline)
+	}
+}
+
+func TestCommentsCanOnlyBeChangedByTheirAuthor(t *testing.T) {
+	client, ticket := newCommentTestClient(t)
+	base := "/tickets/" + ticket.ID + "/comments"
+
+	code, created := client.do(http.MethodPost, base, "alice", customerPermissions, map[string]any{"body": "First draft"})
+	if code != http.StatusCreated {
+		t.Fatalf("create comment: %d %v", code, created)
+	}
+	path := base + "/" + created["data"].(map[string]any)["id"].(string)
+
+	if code, _ := client.do(http.MethodPatch, path, "bob", customerPermissions, map[string]any{"body": "Hijacked"}); code != http.StatusForbidden {
+		t.Fatalf("expected another customer to be refused, got %d", code)
+	}
+	code, edited := client.do(http.MethodPatch, path, "alice", customerPermissions, map[string]any{"body": "Second draft"})
+	if code != http.StatusOK {
+		t.Fatalf("edit comment: %d %v", code, edited)
+	}
+	if data := edited["data"].(map[string]any); data["body"] != "Second draft" || data["edited"] != true {
+		t.Fatalf("expected edited comment, got %v", data)
+	}
+
+	// Agents holding ticket:edit moderate any comment.
+	if code, _ := client.do(http.MethodDelete, path, "agent", agentPermissions, nil); code != http.StatusNoContent {
+		t.Fatalf("delete comment: %d", code)
+	}
+	if code, _ := client.do(http.MethodPatch, path, "alice", customerPermissions, map[string]any{"body": "Revived"}); code != http.StatusConflict {
+		t.Fatalf("expected deleted comment to reject edits, got %d", code)
+	}
+	comments := client.items(base, "alice", customerPermissions)
+	if len(comments) != 1 || comments[0]["deleted"] != true || comments[0]["body"] != nil {
+		t.Fatalf("expected a tombstone without body, got %v", comments)
+	}
+}
+
+func TestTimelineInterleavesActivity(t *testing.T) {
+	client, ticket := newCommentTestClient(t)
+	base := "/tickets/" + ticket.ID
+
+	steps := []struct {
+		method string
+		path   string
+		body   map[string]any
+	}{
+		{http.MethodPost, base + "/comments", map[string]any{"body": "Reported by phone"}},
+		{http.MethodPatch, base, map[string]any{"assigneeId": "7b0e1b6e-9d2c-4f6a-8d8e-0a4d1c2b3e4f"}},
+		{http.MethodPost, base + "/transitions", map[string]any{"to": StatusInProgress}},
+		{http.MethodPost, base + "/comments", map[string]any{"body": "Restarted the gateway"}},
+		// Re-assigning the same person is not an assignment change.
+		{http.MethodPatch, base, map[string]any{"assigneeId": "7b0e1b6e-9d2c-4f6a-8d8e-0a4d1c2b3e4f"}},
+	}
+	for _, step := range steps {
+		// Assigning through PATCH needs ticket:assign on top of ticket:edit.
+		if code, payload := client.do(step.method, step.path, "agent", agentPermissions+",ticket:assign", step.body); code >= 300 {
+			t.Fatalf("%s %s: %d %v", step.method, step.path, code, payload)
+		}
+	}
+
+	timeline := client.items(base+"/timeline", "agent", agentPermissions)
+	var types []string
+	for _, entry := range timeline {
+		types = append(types, entry["type"].(string))
+	}
+	want := []string{TimelineComment, TimelineAssignment, TimelineStatus, TimelineComment}
+	if strings.Join(types, ",") != strings.Join(want, ",") {
+		t.Fatalf("expected timeline %v, got %v", want, types)
+	}
+	if assignment := timeline[1]; assignment["actor"] != "agent" || assignment["data"].(map[string]any)["from"] != "" {
+		t.Fatalf("unexpected assignment entry %v", assignment)
+	}
+
+	if code, _ := client.do(http.MethodGet, "/tickets/missing/timeline", "agent", agentPermissions, nil); code != http.StatusNotFound {
+		t.Fatalf("expected unknown ticket to be reported, got %d", code)
+	}
+}
+
+func TestActorComesFromThePrincipal(t *testing.T) {
+	client, ticket := newCommentTestClient(t)
+	base := "/tickets/" + ticket.ID
+
+	if code, _ := client.do(http.MethodPost, base+"/transitions", "agent", agentPermissions, map[string]any{"to": StatusInProgress, "actor": "someone-else"}); code != http.StatusBadRequest {
+		t.Fatalf("expected a request body naming the actor to be rejected, got %d", code)
+	}
+	if code, payload := client.do(http.MethodPost, base+"/transitions", "agent", agentPermissions, map[string]any{"to": StatusInProgress}); code != http.StatusOK {
+		t.Fatalf("transition: %d %v", code, payload)
+	}
+	history := client.items(base+"/history", "agent", agentPermissions)
+	if len(history) != 1 || history[0]["actor"] != "agent" {
+		t.Fatalf("expected the transition to be recorded for the principal, got %v", history)
+	}
+}
+
+func TestInternalCommentBodiesStayOutOfWebhooks(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewGormRepository(db)
+	webhooks := NewWebhookRepository(db)
+	ticket := &Ticket{Title: "VPN is down", Status: StatusOpen, Priority: "high"}
+	if err := repo.Create(context.Background(), ticket); err != nil {
+		t.Fatalf("create ticket: %v", err)
+	}
+	router := chi.NewRouter()
+	NewHandler(repo, WithComments(repo), WithEvents(NewEventOutbox(db)), WithWebhooks(webhooks, WebhookTargets{AllowPrivate: true})).Mount(router, "")
+	do := func(method, target string, body any) *httptest.ResponseRecorder {
+		t.Helper()
+		raw, _ := json.Marshal(body)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(method, target, bytes.NewReader(raw)))
+		return rec
+	}
+
+	partner := newWebhookReceiver(t)
+	if rec := do(http.MethodPost, "/tickets/webhooks", map[string]any{"url": partner.URL, "eventTypes": []string{"ticket.*"}}); rec.Code != http.StatusCreated {
+		t.Fatalf("create webhook: %d %s", rec.Code, rec.Body)
+	}
+	base := "/tickets/" + ticket.ID + "/comments"
+	rec := do(http.MethodPost, base, map[string]any{"body": "root password is hunter2", "visibility": VisibilityInternal})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create internal comment: %d %s", rec.Code, rec.Body)
+	}
+	var created struct {
+		Data map[string]any `json:"data"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &created)
+	if rec := do(http.MethodPatch, base+"/"+created.Data["id"].(string), map[string]any{"body": "root password is hunter3"}); rec.Code != http.StatusOK {
+		t.Fatalf("update internal comment: %d %s", rec.Code, rec.Body)
+	}
+	if rec := do(http.MethodPost, base, map[string]any{"body": "We are on it"}); rec.Code != http.StatusCreated {
+		t.Fatalf("create public comment: %d %s", rec.Code, rec.Body)
+	}
+
+	dispatcher := NewWebhookDispatcher(webhooks, nil, WebhookDispatcherConfig{Targets: WebhookTargets{AllowPrivate: true}})
+	relay := outbox.NewRelay(outbox.NewRepository(db), outbox.Routes{OutboxEvents: dispatcher}, outbox.RelayConfig{})
+	if _, err := relay.RelayOnce(context.Background()); err != nil {
+		t.Fatalf("relay: %v", err)
+	}
+	if dispatched, err := dispatcher.DispatchOnce(context.Background()); err != nil || dispatched != 3 {
+		t.Fatalf("expected three deliveries, got %d (%v)", dispatched, err)
+	}
+	public := false
+	for _, body := range partner.bodies {
+		if bytes.Contains(body, []byte("hunter")) {
+			t.Fatalf("expected internal comment bodies to stay out of webhooks, got %s", body)
+		}
+		public = public || bytes.Contains(body, []byte("We are on it"))
+	}
+	if !public {
+		t.Fatal("expected public comments to be delivered with their body")
+	}
+}
@@ -0,0 +1,63 @@
+package ticket
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+
+	"github.com/pflow/components/outbox"
+)
+
+// eventSource is the source of the domain events the ticket component emits.
+const eventSource = "/pflow/ticket"
+
+// Domain events emitted after ticket changes, with the ticket ID as subject.
+// Comment and attachment events carry the comment or attachment.
+const (
+	EventTicketCreated           = "ticket.created"
+	EventTicketUpdated           = "ticket.updated"
+	EventTicketDeleted           = "ticket.deleted"
+	EventTicketRestored          = "ticket.restored"
+	EventTicketStatusChanged     = "ticket.status_changed"
+	EventTicketResolved          = "ticket.resolved"
+	EventTicketAssigned          = "ticket.assigned"
+	EventTicketCommentAdded      = "ticket.comment_added"
+	EventTicketCommentUpdated    = "ticket.comment_updated"
+	EventTicketCommentDeleted    = "ticket.comment_deleted"
+	EventTicketAttachmentAdded   = "ticket.attachment_added"
+	EventTicketAttachmentDeleted = "ticket.attachment_deleted"
+)
+
+// NewEventOutbox constructs the outbox the ticket events are recorded in, in
+// the transaction of the change they announce. The outbox relay of the worker
+// hands them to the webhook dispatcher and the event topic.
+func NewEventOutbox(db *gorm.DB) *outbox.EventStore {
+	return outbox.NewEventStore(db, eventSource)
+}
+
+// transition changes the status of a ticket and announces the change in one
+// transaction.
+func (h *Handler) transition(ctx context.Context, id string, req TransitionRequest) (*Ticket, error) {
+	var entity *Ticket
+	err := h.events.Transaction(ctx, func(ctx context.Context) error {
+		var err error
+		if entity, err = h.repo.Transition(ctx, id, req, h.transitions); err != nil {
+			return err
+		}
+		return h.emitTransition(ctx, entity)
+	})
+	return entity, err
+}
+
+// emitTransition announces a status change, as ticket.resolved when the ticket
+// was resolved.
+func (h *Handler) emitTransition(ctx context.Context, entity *Ticket) error {
+	return h.events.Emit(ctx, transitionEvent(entity), entity.ID, entity.ToDTO())
+}
+
+func transitionEvent(entity *Ticket) string {
+	if entity.Status == StatusResolved {
+		return EventTicketResolved
+	}
+	return EventTicketStatusChanged
+}
@@ -0,0 +1,112 @@
+package ticket
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/pflow/components/outbox"
+)
+
+func TestHandlerEmitsDomainEvents(t *testing.T) {
+	db := newTestDB(t)
+	router := chi.NewRouter()
+	NewHandler(NewGormRepository(db), WithEvents(NewEventOutbox(db))).Mount(router, "")
+
+	do := func(method, target string, body any) *httptest.ResponseRecorder {
+		t.Helper()
+		var payload bytes.Buffer
+		if body != nil {
+			json.NewEncoder(&payload).Encode(body)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(method, target, &payload))
+		return rec
+	}
+
+	rec := do(http.MethodPost, "/tickets", map[string]any{"title": "Printer on fire", "formId": routedForm})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create: %d %s", rec.Code, rec.Body)
+	}
+	var created struct {
+		Data struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &created)
+	id := created.Data.ID
+
+	if rec := do(http.MethodPost, "/tickets/"+id+"/transitions", map[string]any{"to": StatusInProgress}); rec.Code != http.StatusOK {
+		t.Fatalf("transition: %d %s", rec.Code, rec.Body)
+	}
+	if rec := do(http.MethodPost, "/tickets/"+id+"/resolve", map[string]any{}); rec.Code != http.StatusOK {
+		t.Fatalf("resolve: %d %s", rec.Code, rec.Body)
+	}
+	if rec := do(http.MethodPost, "/tickets/"+id+"/resolve", map[string]any{}); rec.Code == http.StatusOK {
+		t.Fatal("expected resolving twice to fail")
+	}
+	if rec := do(http.MethodDelete, "/tickets/"+id, nil); rec.Code != http.StatusNoContent {
+		t.Fatalf("delete: %d %s", rec.Code, rec.Body)
+	}
+	publisher := &recordingPublisher{}
+	submissions := &recordingPublisher{}
+	relay := outbox.NewRelay(outbox.NewRepository(db), outbox.Routes{OutboxEvents: publisher, OutboxSubmissions: submissions}, outbox.RelayConfig{})
+	if _, err := relay.RelayOnce(context.Background()); err != nil {
+		t.Fatalf("relay: %v", err)
+	}
+	if len(submissions.events) != 0 {
+		t.Fatalf("expected no events on the submission queue, got %+v", submissions.events)
+	}
+
+	want := []string{EventTicketCreated, EventTicketStatusChanged, EventTicketResolved, EventTicketDeleted}
+	if len(publisher.events) != len(want) {
+		t.Fatalf("expected %d events, got %+v", len(want), publisher.events)
+	}
+	for i, event := range publisher.events {
+		if event.Type != want[i] || event.Subject != id || event.Source != eventSource {
+			t.Fatalf("unexpected event %d: %+v", i, event.Event)
+		}
+	}
+	var resolved map[string]any
+	if err := json.Unmarshal(publisher.events[2].Data, &resolved); err != nil || resolved["status"] != StatusResolved {
+		t.Fatalf("expected the resolved ticket as data, got %s", publisher.events[2].Data)
+	}
+}
+
+func TestEventsRollBackWithTheirChange(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewGormRepository(db)
+	events := NewEventOutbox(db)
+	ctx := context.Background()
+
+	entity := &Ticket{Title: "Printer on fire", FormID: routedForm}
+	err := events.Transaction(ctx, func(ctx context.Context) error {
+		if err := repo.Create(ctx, entity); err != nil {
+			return err
+		}
+		if err := events.Emit(ctx, EventTicketCreated, entity.ID, entity.ToDTO()); err != nil {
+			return err
+		}
+		return errors.New("change failed")
+	})
+	if err == nil {
+		t.Fatal("expected the transaction to fail")
+	}
+
+	var pending int64
+	if err := db.Model(&outbox.Message{}).Where("topic = ?", OutboxEvents).Count(&pending).Error; err != nil {
+		t.Fatalf("count outbox: %v", err)
+	}
+	if pending != 0 {
+		t.Fatalf("expected the event to roll back with the ticket, found %d", pending)
+	}
+	if _, err := repo.Find(ctx, entity.ID); !IsNotFound(err) {
+		t.Fatalf("expected the ticket to roll back, got %v", err)
+	}
+}
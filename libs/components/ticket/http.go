@@ -7,14 +7,56 @@ import (
 	"io"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
 	"gorm.io/datatypes"
 
+	"github.com/pflow/components/form"
+	"github.com/pflow/components/outbox"
+	"github.com/pflow/shared/audit"
+	"github.com/pflow/shared/auth"
+	"github.com/pflow/shared/database"
 	"github.com/pflow/shared/httpx"
 )
 
+var ticketListSpec = httpx.ListSpec{
+	SortFields: map[string]string{
+		"createdAt": "created_at",
+		"updatedAt": "updated_at",
+		"priority":  "priority",
+		"title":     "title",
+	},
+	DefaultSort: "createdAt",
+	DefaultDesc: true,
+	Filters: map[string]string{
+		"status":     "status",
+		"priority":   "priority",
+		"assigneeId": "assignee_id",
+		"formId":     "form_id",
+	},
+	BoolFilters: map[string]string{
+		"breached": "breached",
+	},
+	BeforeFilters: map[string]string{
+		"dueBefore": "due_at",
+	},
+	SearchColumns: []string{"title"},
+	SoftDelete:    true,
+}
+
+var submissionListSpec = httpx.ListSpec{
+	SortFields: map[string]string{
+		"createdAt": "created_at",
+		"updatedAt": "updated_at",
+	},
+	DefaultSort: "updatedAt",
+	Filters: map[string]string{
+		"status": "status",
+	},
+}
+
 var allowedStatuses = map[string]struct{}{
 	StatusOpen:       {},
 	StatusInProgress: {},
@@ -27,12 +69,33 @@ type SubmissionCoordinator interface {
 	Submit(ctx context.Context, req SubmissionRequest) (*TicketSubmission, error)
 	Lookup(ctx context.Context, id string) (*TicketSubmission, error)
 	Metrics(ctx context.Context) (SubmissionMetrics, error)
+	List(ctx context.Context, query httpx.ListQuery) ([]TicketSubmission, httpx.Page, error)
+	Requeue(ctx context.Context, id string) (*TicketSubmission, error)
 }
 
 // Handler exposes HTTP handlers for the ticket component.
 type Handler struct {
 	repo        Repository
 	coordinator SubmissionCoordinator
+	transitions TransitionRules
+	forms       FormValidator
+	comments    CommentRepository
+	authz       auth.Enforcer
+
+	attachments      AttachmentRepository
+	blobs            BlobStore
+	attachmentLimits AttachmentLimits
+
+	slaPolicies SLAPolicyRepository
+
+	router       *Router
+	routingRules AssignmentRuleRepository
+
+	webhooks       WebhookRepository
+	webhookTargets WebhookTargets
+
+	events *outbox.EventStore
+	audit  *audit.Recorder
 }
 
 // HandlerOption customises the handler behaviour.
@@ -45,9 +108,94 @@ func WithSubmissionCoordinator(coordinator SubmissionCoordinator) HandlerOption
 	}
 }
 
+// WithTransitionRules overrides the default ticket status transition table.
+func WithTransitionRules(rules TransitionRules) HandlerOption {
+	return func(h *Handler) {
+		if len(rules) > 0 {
+			h.transitions = rules
+		}
+	}
+}
+
+// WithFormValidator checks ticket metadata against the schema of the referenced form.
+func WithFormValidator(forms FormValidator) HandlerOption {
+	return func(h *Handler) {
+		h.forms = forms
+	}
+}
+
+// WithComments enables the comment routes of tickets and adds comments to
+// their timeline.
+func WithComments(comments CommentRepository) HandlerOption {
+	return func(h *Handler) {
+		h.comments = comments
+	}
+}
+
+// WithAttachments enables ticket attachments, keeping their metadata in repo
+// and their content in blobs. Zero limits fall back to the defaults.
+func WithAttachments(repo AttachmentRepository, blobs BlobStore, limits AttachmentLimits) HandlerOption {
+	return func(h *Handler) {
+		h.attachments = repo
+		h.blobs = blobs
+		h.attachmentLimits = limits.withDefaults()
+	}
+}
+
+// WithSLAPolicies enables the routes managing SLA policies.
+func WithSLAPolicies(policies SLAPolicyRepository) HandlerOption {
+	return func(h *Handler) {
+		h.slaPolicies = policies
+	}
+}
+
+// WithRouting assigns new tickets through the router and enables the routes
+// managing its assignment rules. POST /tickets/{id}/assign also falls back to
+// the router when no assignee is given.
+func WithRouting(router *Router) HandlerOption {
+	return func(h *Handler) {
+		h.router = router
+		if router != nil {
+			h.routingRules = router.store
+		}
+	}
+}
+
+// WithWebhooks enables the routes managing webhooks and their delivery log.
+// Webhook URLs must point at one of targets; deliveries are queued and sent by
+// a WebhookDispatcher.
+func WithWebhooks(webhooks WebhookRepository, targets WebhookTargets) HandlerOption {
+	return func(h *Handler) {
+		h.webhooks = webhooks
+		h.webhookTargets = targets
+	}
+}
+
+// WithEvents announces every successful change through the event outbox, in
+// the transaction of the change.
+func WithEvents(events *outbox.EventStore) HandlerOption {
+	return func(h *Handler) {
+		h.events = events
+	}
+}
+
+// WithAudit records every successful change in the audit log.
+func WithAudit(recorder *audit.Recorder) HandlerOption {
+	return func(h *Handler) {
+		h.audit = recorder
+	}
+}
+
+// WithAuthorization guards every route with the permission it requires.
+func WithAuthorization() HandlerOption {
+	return func(h *Handler) {
+		h.authz.Enabled = true
+	}
+}
+
 // NewHandler builds a ticket HTTP handler backed by the given repository.
 func NewHandler(repo Repository, opts ...HandlerOption) *Handler {
-	handler := &Handler{repo: repo}
+	handler := &Handler{repo: repo, transitions: DefaultTransitionRules()}
 	for _, opt := range opts {
 		if opt != nil {
 			opt(handler)
@@ -63,33 +211,96 @@ func (h *Handler) Mount(router chi.Router, basePath string) {
 		path = "/tickets"
 	}
 
+	require := h.authz.Require
 	router.Route(path, func(r chi.Router) {
-		r.Get("/", h.listTickets)
-		r.Post("/", h.createTicket)
+		r.With(require(auth.PermissionTicketView)).Get("/", h.listTickets)
+		r.With(require(auth.PermissionTicketCreate)).Post("/", h.createTicket)
 		r.Route("/{id}", func(r chi.Router) {
-			r.Get("/", h.getTicket)
-			r.Patch("/", h.updateTicket)
-			r.Delete("/", h.deleteTicket)
-			r.Post("/resolve", h.resolveTicket)
+			r.With(require(auth.PermissionTicketView)).Get("/", h.getTicket)
+			r.With(require(auth.PermissionTicketEdit)).Patch("/", h.updateTicket)
+			r.With(require(auth.PermissionTicketDelete)).Delete("/", h.deleteTicket)
+			r.With(require(auth.PermissionTicketDelete)).Post("/restore", h.restoreTicket)
+			r.With(require(auth.PermissionTicketResolve)).Post("/resolve", h.resolveTicket)
+			r.With(require(auth.PermissionTicketEdit)).Post("/transitions", h.transitionTicket)
+			r.With(require(auth.PermissionTicketAssign)).Post("/assign", h.assignTicket)
+			r.With(require(auth.PermissionTicketView)).Get("/history", h.ticketHistory)
+			r.With(require(auth.PermissionTicketView)).Get("/timeline", h.ticketTimeline)
+			if h.comments != nil {
+				r.Route("/comments", func(r chi.Router) {
+					r.With(require(auth.PermissionTicketView)).Get("/", h.listComments)
+					r.With(require(auth.PermissionTicketComment)).Post("/", h.createComment)
+					r.With(require(auth.PermissionTicketComment)).Patch("/{commentId}", h.updateComment)
+					r.With(require(auth.PermissionTicketComment)).Delete("/{commentId}", h.deleteComment)
+				})
+			}
+			if h.attachments != nil {
+				r.Route("/attachments", func(r chi.Router) {
+					r.With(require(auth.PermissionTicketView)).Get("/", h.listAttachments)
+					r.With(require(auth.PermissionTicketAttach)).Post("/", h.uploadAttachment)
+					r.With(require(auth.PermissionTicketView)).Get("/{attachmentId}", h.downloadAttachment)
+					r.With(require(auth.PermissionTicketAttach)).Delete("/{attachmentId}", h.deleteAttachment)
+				})
+			}
 		})
 
 		if h.coordinator != nil {
 			r.Route("/submissions", func(r chi.Router) {
-				r.Post("/", h.submitTicket)
-				r.Get("/{id}", h.getSubmission)
+				r.With(require(auth.PermissionTicketView)).Get("/", h.listSubmissions)
+				r.With(require(auth.PermissionTicketCreate)).Post("/", h.submitTicket)
+				r.With(require(auth.PermissionTicketView)).Get("/{id}", h.getSubmission)
+				r.With(require(auth.PermissionTicketQueue)).Post("/{id}/requeue", h.requeueSubmission)
+				if h.attachments != nil {
+					r.With(require(auth.PermissionTicketView)).Get("/{id}/attachments", h.listSubmissionAttachments)
+					r.With(require(auth.PermissionTicketAttach)).Post("/{id}/attachments", h.uploadSubmissionAttachment)
+				}
+			})
+			r.With(require(auth.PermissionTicketQueue)).Get("/queue-metrics", h.queueMetrics)
+		}
+
+		if h.slaPolicies != nil {
+			r.Route("/sla-policies", func(r chi.Router) {
+				r.With(require(auth.PermissionTicketView)).Get("/", h.listSLAPolicies)
+				r.With(require(auth.PermissionTicketSLA)).Post("/", h.createSLAPolicy)
+				r.With(require(auth.PermissionTicketView)).Get("/{policyId}", h.getSLAPolicy)
+				r.With(require(auth.PermissionTicketSLA)).Patch("/{policyId}", h.updateSLAPolicy)
+				r.With(require(auth.PermissionTicketSLA)).Delete("/{policyId}", h.deleteSLAPolicy)
+			})
+		}
+
+		if h.routingRules != nil {
+			r.Route("/assignment-rules", func(r chi.Router) {
+				r.With(require(auth.PermissionTicketView)).Get("/", h.listAssignmentRules)
+				r.With(require(auth.PermissionTicketAssign)).Post("/", h.createAssignmentRule)
+				r.With(require(auth.PermissionTicketView)).Get("/{ruleId}", h.getAssignmentRule)
+				r.With(require(auth.PermissionTicketAssign)).Patch("/{ruleId}", h.updateAssignmentRule)
+				r.With(require(auth.PermissionTicketAssign)).Delete("/{ruleId}", h.deleteAssignmentRule)
+			})
+		}
+
+		if h.webhooks != nil {
+			r.Route("/webhooks", func(r chi.Router) {
+				manage := r.With(require(auth.PermissionTicketWebhook))
+				manage.Get("/", h.listWebhooks)
+				manage.Post("/", h.createWebhook)
+				manage.Get("/{webhookId}", h.getWebhook)
+				manage.Patch("/{webhookId}", h.updateWebhook)
+				manage.Delete("/{webhookId}", h.deleteWebhook)
+				manage.Get("/{webhookId}/deliveries", h.listWebhookDeliveries)
+				manage.Get("/{webhookId}/deliveries/{deliveryId}", h.getWebhookDelivery)
+				manage.Post("/{webhookId}/deliveries/{deliveryId}/redeliver", h.redeliverWebhook)
 			})
-			r.Get("/queue-metrics", h.queueMetrics)
 		}
 	})
 }
 
 type createTicketRequest struct {
-	Title      string         `json:"title"`
-	Status     string         `json:"status"`
-	FormID     string         `json:"formId"`
-	AssigneeID string         `json:"assigneeId"`
-	Priority   string         `json:"priority"`
-	Metadata   map[string]any `json:"metadata"`
+	Title       string         `json:"title"`
+	Status      string         `json:"status"`
+	FormID      string         `json:"formId"`
+	FormVersion int            `json:"formVersion"`
+	AssigneeID  string         `json:"assigneeId"`
+	Priority    string         `json:"priority"`
+	Metadata    map[string]any `json:"metadata"`
 }
 
 type createSubmissionRequest struct {
@@ -97,6 +308,11 @@ type createSubmissionRequest struct {
 	ClientReference string `json:"clientReference"`
 }
 
+type transitionTicketRequest struct {
+	To     string `json:"to"`
+	Reason string `json:"reason"`
+}
+
 type updateTicketRequest struct {
 	Title      *string        `json:"title"`
 	Status     *string        `json:"status"`
@@ -106,10 +322,17 @@ type updateTicketRequest struct {
 }
 
 func (h *Handler) listTickets(w http.ResponseWriter, r *http.Request) {
-	status := strings.TrimSpace(r.URL.Query().Get("status"))
-	assignee := strings.TrimSpace(r.URL.Query().Get("assigneeId"))
+	query, err := httpx.ParseListQuery(r, ticketListSpec)
+	if err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if query.IncludeDeleted && !h.authz.Allows(r, auth.PermissionTicketDelete) {
+		httpx.Error(w, http.StatusForbidden, "listing deleted tickets requires "+auth.PermissionTicketDelete)
+		return
+	}
 
-	tickets, err := h.repo.List(r.Context(), status, assignee)
+	tickets, page, err := h.repo.List(r.Context(), query)
 	if err != nil {
 		httpx.Error(w, http.StatusInternalServerError, err.Error())
 		return
@@ -120,7 +343,7 @@ func (h *Handler) listTickets(w http.ResponseWriter, r *http.Request) {
 		items = append(items, entity.ToDTO())
 	}
 
-	httpx.JSON(w, http.StatusOK, map[string]any{"data": items})
+	httpx.List(w, items, page)
 }
 
 func (h *Handler) createTicket(w http.ResponseWriter, r *http.Request) {
@@ -130,16 +353,24 @@ func (h *Handler) createTicket(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	entity, _, err := normalizeTicketPayload(payload)
+	entity, _, err := normalizeTicketPayload(r.Context(), payload, h.forms)
 	if err != nil {
-		httpx.Error(w, http.StatusBadRequest, err.Error())
+		renderPayloadError(w, err)
 		return
 	}
 
-	if err := h.repo.Create(r.Context(), entity); err != nil {
+	err = h.events.Transaction(r.Context(), func(ctx context.Context) error {
+		if err := h.repo.Create(ctx, entity); err != nil {
+			return err
+		}
+		entity = autoAssign(ctx, h.router, h.repo, entity)
+		return h.events.Emit(ctx, EventTicketCreated, entity.ID, entity.ToDTO())
+	})
+	if err != nil {
 		httpx.Error(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+	h.audit.Record(r.Context(), audit.Change{Action: EventTicketCreated, ResourceID: entity.ID, After: entity.ToDTO()})
 
 	httpx.JSON(w, http.StatusCreated, map[string]any{"data": entity.ToDTO()})
 }
@@ -176,16 +407,13 @@ func (h *Handler) updateTicket(w http.ResponseWriter, r *http.Request) {
 		}
 		updates["title"] = title
 	}
+	status := ""
 	if payload.Status != nil {
-		status := strings.ToLower(strings.TrimSpace(*payload.Status))
+		status = strings.ToLower(strings.TrimSpace(*payload.Status))
 		if !isValidStatus(status) {
 			httpx.Error(w, http.StatusBadRequest, "invalid status")
 			return
 		}
-		updates["status"] = status
-	}
-	if payload.AssigneeID != nil {
-		updates["assignee_id"] = strings.TrimSpace(*payload.AssigneeID)
 	}
 	if payload.Priority != nil {
 		updates["priority"] = strings.ToLower(strings.TrimSpace(*payload.Priority))
@@ -194,52 +422,219 @@ func (h *Handler) updateTicket(w http.ResponseWriter, r *http.Request) {
 		updates["metadata"] = datatypes.JSONMap(payload.Metadata)
 	}
 
-	if len(updates) == 0 {
+	if len(updates) == 0 && status == "" && payload.AssigneeID == nil {
 		httpx.Error(w, http.StatusBadRequest, "no updates provided")
 		return
 	}
+	if !h.allowsStatus(w, r, status) {
+		return
+	}
+	if payload.AssigneeID != nil && !h.authz.Allows(r, auth.PermissionTicketAssign) {
+		httpx.Error(w, http.StatusForbidden, "assigning a ticket requires "+auth.PermissionTicketAssign)
+		return
+	}
 
-	entity, err := h.repo.Update(r.Context(), id, updates)
+	before, err := h.repo.Find(r.Context(), id)
 	if err != nil {
-		if IsNotFound(err) {
-			httpx.Error(w, http.StatusNotFound, "ticket not found")
+		h.renderTicketError(w, err)
+		return
+	}
+	// The form of a ticket never changes, so the metadata is checked before the
+	// row is locked rather than while the form service is called.
+	if payload.Metadata != nil && h.forms != nil {
+		if _, err := validateMetadata(r.Context(), h.forms, before.FormID, before.FormVersion, payload.Metadata); err != nil {
+			renderPayloadError(w, err)
 			return
 		}
-		httpx.Error(w, http.StatusInternalServerError, err.Error())
+	}
+
+	// The changes apply to the locked ticket and commit together, so a
+	// concurrent update cannot interleave with them or see half of them.
+	var entity *Ticket
+	err = h.repo.Transaction(r.Context(), func(ctx context.Context) error {
+		if before, err = h.repo.FindForUpdate(ctx, id); err != nil {
+			return err
+		}
+		entity = before
+		if status != "" && entity.Status != status {
+			if entity, err = h.repo.Transition(ctx, id, TransitionRequest{
+				To:    status,
+				Actor: requestActor(r),
+			}, h.transitions); err != nil {
+				return err
+			}
+			if err := h.emitTransition(ctx, entity); err != nil {
+				return err
+			}
+		}
+		if payload.AssigneeID != nil {
+			if entity, err = h.repo.Assign(ctx, id, AssignmentRequest{
+				AssigneeID: strings.TrimSpace(*payload.AssigneeID),
+				Actor:      requestActor(r),
+			}); err != nil {
+				return err
+			}
+			if err := h.events.Emit(ctx, EventTicketAssigned, entity.ID, entity.ToDTO()); err != nil {
+				return err
+			}
+		}
+		if len(updates) > 0 {
+			if entity, err = h.repo.Update(ctx, id, updates); err != nil {
+				return err
+			}
+			return h.events.Emit(ctx, EventTicketUpdated, entity.ID, entity.ToDTO())
+		}
+		return nil
+	})
+	if err != nil {
+		h.renderTicketError(w, err)
 		return
 	}
+	h.audit.Record(r.Context(), audit.Change{Action: EventTicketUpdated, ResourceID: entity.ID, Before: before.ToDTO(), After: entity.ToDTO()})
 
 	httpx.JSON(w, http.StatusOK, map[string]any{"data": entity.ToDTO()})
 }
 
 func (h *Handler) deleteTicket(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
-	if err := h.repo.Delete(r.Context(), id); err != nil {
-		if IsNotFound(err) {
-			httpx.Error(w, http.StatusNotFound, "ticket not found")
-			return
+	before, err := h.repo.Find(r.Context(), id)
+	if err != nil {
+		h.renderTicketError(w, err)
+		return
+	}
+	err = h.events.Transaction(r.Context(), func(ctx context.Context) error {
+		if err := h.repo.Delete(ctx, id); err != nil {
+			return err
 		}
-		httpx.Error(w, http.StatusInternalServerError, err.Error())
+		return h.events.Emit(ctx, EventTicketDeleted, id, map[string]any{"id": id})
+	})
+	if err != nil {
+		h.renderTicketError(w, err)
 		return
 	}
+	h.audit.Record(r.Context(), audit.Change{Action: EventTicketDeleted, ResourceID: id, Before: before.ToDTO()})
 
 	w.WriteHeader(http.StatusNoContent)
 }
 
-func (h *Handler) resolveTicket(w http.ResponseWriter, r *http.Request) {
-	id := chi.URLParam(r, "id")
-	entity, err := h.repo.Resolve(r.Context(), id)
+// restoreTicket undoes the deletion of a ticket that was not purged yet, with
+// its comments, history and attachments.
+func (h *Handler) restoreTicket(w http.ResponseWriter, r *http.Request) {
+	var entity *Ticket
+	err := h.events.Transaction(r.Context(), func(ctx context.Context) error {
+		var err error
+		if entity, err = h.repo.Restore(ctx, chi.URLParam(r, "id")); err != nil {
+			return err
+		}
+		return h.events.Emit(ctx, EventTicketRestored, entity.ID, entity.ToDTO())
+	})
 	if err != nil {
-		if IsNotFound(err) {
+		switch {
+		case IsNotFound(err):
 			httpx.Error(w, http.StatusNotFound, "ticket not found")
-			return
+		case errors.Is(err, database.ErrNotDeleted):
+			httpx.Error(w, http.StatusConflict, "ticket is not deleted")
+		default:
+			httpx.Error(w, http.StatusInternalServerError, err.Error())
 		}
-		httpx.Error(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+	h.audit.Record(r.Context(), audit.Change{Action: EventTicketRestored, ResourceID: entity.ID, After: entity.ToDTO()})
+
+	httpx.JSON(w, http.StatusOK, map[string]any{"data": entity.ToDTO()})
+}
+
+func (h *Handler) resolveTicket(w http.ResponseWriter, r *http.Request) {
+	before, err := h.repo.Find(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		h.renderTicketError(w, err)
+		return
+	}
+	entity, err := h.transition(r.Context(), before.ID, TransitionRequest{
+		To:    StatusResolved,
+		Actor: requestActor(r),
+	})
+	if err != nil {
+		h.renderTicketError(w, err)
+		return
+	}
+	h.audit.Record(r.Context(), audit.Change{Action: transitionEvent(entity), ResourceID: entity.ID, Before: before.ToDTO(), After: entity.ToDTO()})
 	httpx.JSON(w, http.StatusOK, map[string]any{"data": entity.ToDTO()})
 }
 
+func (h *Handler) transitionTicket(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	var payload transitionTicketRequest
+	if err := decodeJSON(r, &payload); err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	to := strings.ToLower(strings.TrimSpace(payload.To))
+	if !isValidStatus(to) {
+		httpx.Error(w, http.StatusBadRequest, "invalid status")
+		return
+	}
+	if !h.allowsStatus(w, r, to) {
+		return
+	}
+
+	before, err := h.repo.Find(r.Context(), id)
+	if err != nil {
+		h.renderTicketError(w, err)
+		return
+	}
+	entity, err := h.transition(r.Context(), id, TransitionRequest{
+		To:     to,
+		Actor:  requestActor(r),
+		Reason: strings.TrimSpace(payload.Reason),
+	})
+	if err != nil {
+		h.renderTicketError(w, err)
+		return
+	}
+	h.audit.Record(r.Context(), audit.Change{Action: transitionEvent(entity), ResourceID: entity.ID, Before: before.ToDTO(), After: entity.ToDTO()})
+
+	httpx.JSON(w, http.StatusOK, map[string]any{"data": entity.ToDTO()})
+}
+
+// allowsStatus refuses moves to resolved without ticket:resolve, so PATCH and
+// /transitions cannot bypass the permission of /resolve.
+func (h *Handler) allowsStatus(w http.ResponseWriter, r *http.Request, status string) bool {
+	if status == StatusResolved && !h.authz.Allows(r, auth.PermissionTicketResolve) {
+		httpx.Error(w, http.StatusForbidden, "resolving a ticket requires "+auth.PermissionTicketResolve)
+		return false
+	}
+	return true
+}
+
+func (h *Handler) ticketHistory(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	transitions, err := h.repo.History(r.Context(), id)
+	if err != nil {
+		h.renderTicketError(w, err)
+		return
+	}
+
+	items := make([]map[string]any, 0, len(transitions))
+	for _, entry := range transitions {
+		items = append(items, entry.ToDTO())
+	}
+
+	httpx.JSON(w, http.StatusOK, map[string]any{"data": items})
+}
+
+func (h *Handler) renderTicketError(w http.ResponseWriter, err error) {
+	switch {
+	case IsNotFound(err):
+		httpx.Error(w, http.StatusNotFound, "ticket not found")
+	case IsInvalidTransition(err):
+		httpx.Error(w, http.StatusConflict, err.Error())
+	default:
+		httpx.Error(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
 func (h *Handler) submitTicket(w http.ResponseWriter, r *http.Request) {
 	if h.coordinator == nil {
 		httpx.Error(w, http.StatusNotImplemented, "ticket submissions are not configured")
@@ -252,9 +647,9 @@ func (h *Handler) submitTicket(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_, normalized, err := normalizeTicketPayload(payload.createTicketRequest)
+	_, normalized, err := normalizeTicketPayload(r.Context(), payload.createTicketRequest, h.forms)
 	if err != nil {
-		httpx.Error(w, http.StatusBadRequest, err.Error())
+		renderPayloadError(w, err)
 		return
 	}
 
@@ -266,6 +661,7 @@ func (h *Handler) submitTicket(w http.ResponseWriter, r *http.Request) {
 		httpx.Error(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+	h.audit.Record(r.Context(), audit.Change{Action: auditSubmissionSubmitted, ResourceID: submission.ID, After: submission.ToDTO()})
 
 	statusCode := http.StatusAccepted
 	if submission.Status == SubmissionCompleted {
@@ -294,6 +690,78 @@ func (h *Handler) getSubmission(w http.ResponseWriter, r *http.Request) {
 	httpx.JSON(w, http.StatusOK, map[string]any{"data": submission.ToDTO()})
 }
 
+func (h *Handler) listSubmissions(w http.ResponseWriter, r *http.Request) {
+	if h.coordinator == nil {
+		httpx.Error(w, http.StatusNotImplemented, "ticket submissions are not configured")
+		return
+	}
+
+	query, err := httpx.ParseListQuery(r, submissionListSpec)
+	if err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if raw := strings.TrimSpace(r.URL.Query().Get("olderThan")); raw != "" {
+		cutoff, err := parseOlderThan(raw)
+		if err != nil {
+			httpx.Error(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		query.UpdatedBefore = &cutoff
+	}
+
+	submissions, page, err := h.coordinator.List(r.Context(), query)
+	if err != nil {
+		httpx.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	items := make([]map[string]any, 0, len(submissions))
+	for _, submission := range submissions {
+		items = append(items, submission.ToDTO())
+	}
+
+	httpx.List(w, items, page)
+}
+
+func (h *Handler) requeueSubmission(w http.ResponseWriter, r *http.Request) {
+	if h.coordinator == nil {
+		httpx.Error(w, http.StatusNotImplemented, "ticket submissions are not configured")
+		return
+	}
+
+	submission, err := h.coordinator.Requeue(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		switch {
+		case IsNotFound(err):
+			httpx.Error(w, http.StatusNotFound, "submission not found")
+		case errors.Is(err, ErrSubmissionCompleted), errors.Is(err, ErrSubmissionChanged):
+			httpx.Error(w, http.StatusConflict, err.Error())
+		default:
+			httpx.Error(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	h.audit.Record(r.Context(), audit.Change{Action: auditSubmissionRequeued, ResourceID: submission.ID, After: submission.ToDTO()})
+
+	httpx.JSON(w, http.StatusAccepted, map[string]any{"data": submission.ToDTO()})
+}
+
+// parseOlderThan accepts either a duration such as 15m or an RFC3339 timestamp.
+func parseOlderThan(raw string) (time.Time, error) {
+	if age, err := time.ParseDuration(raw); err == nil {
+		if age < 0 {
+			return time.Time{}, errors.New("olderThan must not be negative")
+		}
+		return time.Now().Add(-age), nil
+	}
+	cutoff, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return time.Time{}, errors.New("olderThan must be a duration or an RFC3339 timestamp")
+	}
+	return cutoff, nil
+}
+
 func (h *Handler) queueMetrics(w http.ResponseWriter, r *http.Request) {
 	if h.coordinator == nil {
 		httpx.Error(w, http.StatusNotImplemented, "ticket submissions are not configured")
@@ -309,7 +777,23 @@ func (h *Handler) queueMetrics(w http.ResponseWriter, r *http.Request) {
 	httpx.JSON(w, http.StatusOK, map[string]any{"data": metrics})
 }
 
-func normalizeTicketPayload(payload createTicketRequest) (*Ticket, map[string]any, error) {
+func renderPayloadError(w http.ResponseWriter, err error) {
+	var invalid *form.ValidationError
+	switch {
+	case errors.As(err, &invalid):
+		httpx.ErrorDetails(w, http.StatusUnprocessableEntity, "metadata does not match the form schema", invalid.Fields)
+	case errors.Is(err, form.ErrFormNotFound):
+		httpx.Error(w, http.StatusUnprocessableEntity, "formId references an unknown form")
+	case errors.Is(err, form.ErrFormVersionNotFound):
+		httpx.Error(w, http.StatusUnprocessableEntity, "formVersion references an unknown form version")
+	case errors.Is(err, errFormLookup):
+		httpx.Error(w, http.StatusBadGateway, err.Error())
+	default:
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+	}
+}
+
+func normalizeTicketPayload(ctx context.Context, payload createTicketRequest, forms FormValidator) (*Ticket, map[string]any, error) {
 	title := strings.TrimSpace(payload.Title)
 	if len(title) < 3 {
 		return nil, nil, errors.New("title must be at least 3 characters")
@@ -320,12 +804,14 @@ func normalizeTicketPayload(payload createTicketRequest) (*Ticket, map[string]an
 		return nil, nil, errors.New("formId must be a valid UUID")
 	}
 
+	// Tickets start open; every later status is reached through a transition,
+	// which the transition rules check and the history records.
 	status := strings.ToLower(strings.TrimSpace(payload.Status))
 	if status == "" {
 		status = StatusOpen
 	}
-	if !isValidStatus(status) {
-		return nil, nil, errors.New("invalid status")
+	if status != StatusOpen {
+		return nil, nil, errors.New("tickets are created open; change the status with a transition")
 	}
 
 	entity := &Ticket{
@@ -352,6 +838,18 @@ func normalizeTicketPayload(payload createTicketRequest) (*Ticket, map[string]an
 		normalized["assigneeId"] = entity.AssigneeID
 	}
 
+	if payload.FormVersion < 0 {
+		return nil, nil, errors.New("formVersion must not be negative")
+	}
+	version, err := validateMetadata(ctx, forms, formID, payload.FormVersion, payload.Metadata)
+	if err != nil {
+		return nil, nil, err
+	}
+	if version > 0 {
+		entity.FormVersion = version
+		normalized["formVersion"] = version
+	}
+
 	if payload.Metadata != nil {
 		entity.Metadata = datatypes.JSONMap(payload.Metadata)
 		normalized["metadata"] = payload.Metadata
@@ -360,6 +858,15 @@ func normalizeTicketPayload(payload createTicketRequest) (*Ticket, map[string]an
 	return entity, normalized, nil
 }
 
+// requestActor resolves who performed a change: the authenticated principal,
+// else the X-User-ID header. Request bodies cannot name the actor.
+func requestActor(r *http.Request) string {
+	if principal, ok := auth.FromContext(r.Context()); ok && principal.UserID != "" {
+		return principal.UserID
+	}
+	return strings.TrimSpace(r.Header.Get("X-User-ID"))
+}
+
 func isValidStatus(status string) bool {
 	_, ok := allowedStatuses[status]
 	return ok
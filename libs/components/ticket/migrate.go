@@ -0,0 +1,20 @@
+package ticket
+
+import (
+	"gorm.io/gorm"
+
+	"github.com/pflow/components/outbox"
+)
+
+// Migrate creates the ticket schema. Rows that predate tenants belong to the
+// default tenant, and client references become unique per tenant instead of
+// globally.
+func Migrate(db *gorm.DB) error {
+	if err := db.AutoMigrate(&Ticket{}, &TicketSubmission{}, &TicketTransition{}, &TicketAssignment{}, &TicketComment{}, &TicketAttachment{}, &SubmissionAttachment{}, &SLAPolicy{}, &AssignmentRule{}, &Webhook{}, &WebhookDelivery{}, &outbox.Message{}); err != nil {
+		return err
+	}
+	if db.Migrator().HasIndex(&TicketSubmission{}, "idx_ticket_submissions_client_reference") {
+		return db.Migrator().DropIndex(&TicketSubmission{}, "idx_ticket_submissions_client_reference")
+	}
+	return nil
+}
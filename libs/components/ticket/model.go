@@ -34,26 +34,44 @@ const (
 
 // Ticket represents a workflow-driven work item.
 type Ticket struct {
-	ID         string            `json:"id" gorm:"type:uuid;primaryKey"`
-	Title      string            `json:"title" gorm:"not null"`
-	Status     string            `json:"status" gorm:"not null;index"`
-	FormID     string            `json:"formId" gorm:"type:uuid;not null;index"`
-	AssigneeID string            `json:"assigneeId" gorm:"type:uuid;index"`
-	Priority   string            `json:"priority" gorm:"default:'medium'"`
-	Metadata   datatypes.JSONMap `json:"metadata" gorm:"type:jsonb"`
-	CreatedAt  time.Time         `json:"createdAt"`
-	UpdatedAt  time.Time         `json:"updatedAt"`
-	ResolvedAt *time.Time        `json:"resolvedAt"`
+	ID          string            `json:"id" gorm:"type:uuid;primaryKey"`
+	TenantID    string            `json:"tenantId" gorm:"type:varchar(64);not null;default:default;index"`
+	Title       string            `json:"title" gorm:"not null"`
+	Status      string            `json:"status" gorm:"not null;index"`
+	FormID      string            `json:"formId" gorm:"type:uuid;not null;index"`
+	FormVersion int               `json:"formVersion" gorm:"not null;default:0"`
+	AssigneeID  string            `json:"assigneeId" gorm:"type:uuid;index"`
+	Priority    string            `json:"priority" gorm:"default:'medium'"`
+	Metadata    datatypes.JSONMap `json:"metadata" gorm:"type:jsonb"`
+	CreatedAt   time.Time         `json:"createdAt"`
+	UpdatedAt   time.Time         `json:"updatedAt"`
+	ResolvedAt  *time.Time        `json:"resolvedAt"`
+	DeletedAt   gorm.DeletedAt    `json:"deletedAt,omitempty" gorm:"index"`
+
+	// SLA deadlines come from the SLAPolicy matching the priority and form
+	// when the ticket is created or its priority changes. DueAt is the
+	// resolution deadline; Breached is set by the SLAEvaluator once either
+	// target is missed.
+	SLAPolicyID          *string    `json:"slaPolicyId" gorm:"type:uuid"`
+	FirstResponseDueAt   *time.Time `json:"firstResponseDueAt" gorm:"index"`
+	FirstRespondedAt     *time.Time `json:"firstRespondedAt"`
+	DueAt                *time.Time `json:"dueAt" gorm:"index"`
+	Breached             bool       `json:"breached" gorm:"not null;default:false;index"`
+	ResponseBreachedAt   *time.Time `json:"responseBreachedAt"`
+	ResolutionBreachedAt *time.Time `json:"resolutionBreachedAt"`
 }
 
-// TicketSubmission captures asynchronous ticket creation requests.
+// TicketSubmission captures asynchronous ticket creation requests. Client
+// references are idempotency keys unique within a tenant.
 type TicketSubmission struct {
 	ID              string            `json:"id" gorm:"type:uuid;primaryKey"`
-	ClientReference string            `json:"clientReference" gorm:"type:varchar(128);uniqueIndex"`
+	TenantID        string            `json:"tenantId" gorm:"type:varchar(64);not null;default:default;uniqueIndex:idx_ticket_submissions_tenant_reference,priority:1"`
+	ClientReference string            `json:"clientReference" gorm:"type:varchar(128);uniqueIndex:idx_ticket_submissions_tenant_reference,priority:2"`
 	Status          string            `json:"status" gorm:"not null;index"`
 	ErrorMessage    string            `json:"errorMessage"`
 	TicketID        *string           `json:"ticketId" gorm:"type:uuid;index"`
 	RequestPayload  datatypes.JSONMap `json:"requestPayload" gorm:"type:jsonb"`
+	RequeueCount    int               `json:"requeueCount" gorm:"not null;default:0"`
 	CreatedAt       time.Time         `json:"createdAt"`
 	UpdatedAt       time.Time         `json:"updatedAt"`
 	CompletedAt     *time.Time        `json:"completedAt"`
@@ -84,14 +102,16 @@ func (s *TicketSubmission) BeforeCreate(tx *gorm.DB) error {
 // ToDTO converts a ticket into a serialisable map.
 func (t Ticket) ToDTO() map[string]any {
 	payload := map[string]any{
-		"id":         t.ID,
-		"title":      t.Title,
-		"status":     t.Status,
-		"formId":     t.FormID,
-		"assigneeId": t.AssigneeID,
-		"priority":   t.Priority,
-		"createdAt":  t.CreatedAt,
-		"updatedAt":  t.UpdatedAt,
+		"id":          t.ID,
+		"tenantId":    t.TenantID,
+		"title":       t.Title,
+		"status":      t.Status,
+		"formId":      t.FormID,
+		"formVersion": t.FormVersion,
+		"assigneeId":  t.AssigneeID,
+		"priority":    t.Priority,
+		"createdAt":   t.CreatedAt,
+		"updatedAt":   t.UpdatedAt,
 	}
 	if t.Metadata != nil {
 		payload["metadata"] = map[string]any(t.Metadata)
@@ -101,15 +121,61 @@ func (t Ticket) ToDTO() map[string]any {
 	if t.ResolvedAt != nil {
 		payload["resolvedAt"] = t.ResolvedAt
 	}
+	payload["breached"] = t.Breached
+	optional := map[string]*time.Time{
+		"firstResponseDueAt":   t.FirstResponseDueAt,
+		"firstRespondedAt":     t.FirstRespondedAt,
+		"dueAt":                t.DueAt,
+		"responseBreachedAt":   t.ResponseBreachedAt,
+		"resolutionBreachedAt": t.ResolutionBreachedAt,
+	}
+	for key, value := range optional {
+		if value != nil {
+			payload[key] = value
+		}
+	}
+	if t.SLAPolicyID != nil {
+		payload["slaPolicyId"] = *t.SLAPolicyID
+	}
+	if t.DeletedAt.Valid {
+		payload["deletedAt"] = t.DeletedAt.Time
+	}
 	return payload
 }
 
+// slaUpdates lists the SLA columns of the ticket for an update.
+func (t Ticket) slaUpdates() map[string]any {
+	return map[string]any{
+		"sla_policy_id":          t.SLAPolicyID,
+		"first_response_due_at":  t.FirstResponseDueAt,
+		"due_at":                 t.DueAt,
+		"breached":               t.Breached,
+		"response_breached_at":   t.ResponseBreachedAt,
+		"resolution_breached_at": t.ResolutionBreachedAt,
+	}
+}
+
+func (t Ticket) sortValue(column string) (any, string) {
+	switch column {
+	case "updated_at":
+		return t.UpdatedAt, t.ID
+	case "priority":
+		return t.Priority, t.ID
+	case "title":
+		return t.Title, t.ID
+	default:
+		return t.CreatedAt, t.ID
+	}
+}
+
 // ToDTO exposes submission data for clients.
 func (s TicketSubmission) ToDTO() map[string]any {
 	dto := map[string]any{
 		"id":              s.ID,
+		"tenantId":        s.TenantID,
 		"clientReference": s.ClientReference,
 		"status":          s.Status,
+		"requeueCount":    s.RequeueCount,
 		"createdAt":       s.CreatedAt,
 		"updatedAt":       s.UpdatedAt,
 	}
@@ -125,6 +191,13 @@ func (s TicketSubmission) ToDTO() map[string]any {
 	return dto
 }
 
+func (s TicketSubmission) sortValue(column string) (any, string) {
+	if column == "updated_at" {
+		return s.UpdatedAt, s.ID
+	}
+	return s.CreatedAt, s.ID
+}
+
 // ToTicket reconstructs a Ticket entity from the stored payload.
 func (s TicketSubmission) ToTicket() (*Ticket, error) {
 	payload := map[string]any(s.RequestPayload)
@@ -150,6 +223,7 @@ func (s TicketSubmission) ToTicket() (*Ticket, error) {
 	}
 
 	ticket := &Ticket{
+		TenantID:   s.TenantID,
 		Title:      strings.TrimSpace(title),
 		Status:     strings.TrimSpace(statusValue),
 		FormID:     strings.TrimSpace(formID),
@@ -157,6 +231,12 @@ func (s TicketSubmission) ToTicket() (*Ticket, error) {
 		Priority:   strings.TrimSpace(priorityValue),
 	}
 
+	switch version := payload["formVersion"].(type) {
+	case float64:
+		ticket.FormVersion = int(version)
+	case int:
+		ticket.FormVersion = version
+	}
 	if metadataRaw, ok := payload["metadata"].(map[string]any); ok {
 		ticket.Metadata = datatypes.JSONMap(metadataRaw)
 	}
@@ -0,0 +1,42 @@
+package ticket
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"gorm.io/datatypes"
+
+	"github.com/pflow/components/outbox"
+)
+
+// Outbox topics name where the relay delivers a message.
+const (
+	// OutboxSubmissions messages announce submissions to the ticket workers.
+	OutboxSubmissions = "submissions"
+	// OutboxEvents messages carry domain events for webhooks and the event topic.
+	OutboxEvents = outbox.Events
+)
+
+// newSubmissionMessage builds the outbox message announcing a submission to the ticket workers.
+func newSubmissionMessage(submission *TicketSubmission) (*outbox.Message, error) {
+	payload, err := json.Marshal(submissionMessage{SubmissionID: submission.ID, TenantID: submission.TenantID})
+	if err != nil {
+		return nil, fmt.Errorf("marshal submission payload: %w", err)
+	}
+
+	submittedAt := submission.UpdatedAt
+	if submittedAt.IsZero() {
+		submittedAt = time.Now()
+	}
+
+	return &outbox.Message{
+		AggregateID: submission.ID,
+		Topic:       OutboxSubmissions,
+		Key:         submission.ID,
+		Payload:     payload,
+		Headers: datatypes.JSONMap{
+			"submitted_at": submittedAt.Format(time.RFC3339Nano),
+		},
+	}, nil
+}
@@ -2,33 +2,37 @@ package ticket
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
-	"fmt"
 	"strings"
-	"time"
 
 	"gorm.io/datatypes"
 	"gorm.io/gorm"
 
-	"github.com/pflow/shared/mq"
+	"github.com/pflow/shared/httpx"
 )
 
+// ErrSubmissionCompleted is returned when requeueing a submission that already produced a ticket.
+var ErrSubmissionCompleted = errors.New("ticket submission already completed")
+
+// ErrSubmissionChanged is returned when a submission moved on while it was being requeued or reaped.
+var ErrSubmissionChanged = errors.New("ticket submission changed concurrently")
+
 // SubmissionRequest captures the normalized payload for asynchronous creation.
 type SubmissionRequest struct {
 	ClientReference string
 	Payload         map[string]any
 }
 
-// QueueCoordinator orchestrates submission persistence and queue publication.
+// QueueCoordinator orchestrates submission persistence and queue publication. Queue
+// messages are written to the transactional outbox together with the submission and
+// delivered to Kafka by an outbox.Relay.
 type QueueCoordinator struct {
-	store    SubmissionStore
-	producer *mq.Producer
+	store SubmissionStore
 }
 
 // NewQueueCoordinator constructs a queue-backed submission coordinator.
-func NewQueueCoordinator(store SubmissionStore, producer *mq.Producer) *QueueCoordinator {
-	return &QueueCoordinator{store: store, producer: producer}
+func NewQueueCoordinator(store SubmissionStore) *QueueCoordinator {
+	return &QueueCoordinator{store: store}
 }
 
 // Submit persists a submission and enqueues it for asynchronous processing.
@@ -56,13 +60,7 @@ func (c *QueueCoordinator) Submit(ctx context.Context, req SubmissionRequest) (*
 				existing.TicketID = nil
 				existing.CompletedAt = nil
 				existing.RequestPayload = datatypes.JSONMap(sanitized)
-				if err := c.store.Save(ctx, existing); err != nil {
-					return nil, err
-				}
-				if err := c.publish(ctx, existing); err != nil {
-					existing.Status = SubmissionFailed
-					existing.ErrorMessage = err.Error()
-					_ = c.store.Save(ctx, existing)
+				if err := c.store.SaveWithOutbox(ctx, existing, newSubmissionMessage); err != nil {
 					return nil, err
 				}
 				return existing, nil
@@ -78,14 +76,7 @@ func (c *QueueCoordinator) Submit(ctx context.Context, req SubmissionRequest) (*
 		RequestPayload:  datatypes.JSONMap(sanitized),
 	}
 
-	if err := c.store.Create(ctx, submission); err != nil {
-		return nil, err
-	}
-
-	if err := c.publish(ctx, submission); err != nil {
-		submission.Status = SubmissionFailed
-		submission.ErrorMessage = err.Error()
-		_ = c.store.Save(ctx, submission)
+	if err := c.store.CreateWithOutbox(ctx, submission, newSubmissionMessage); err != nil {
 		return nil, err
 	}
 
@@ -100,6 +91,30 @@ func (c *QueueCoordinator) Lookup(ctx context.Context, id string) (*TicketSubmis
 	return c.store.FindByID(ctx, id)
 }
 
+// List returns a page of submissions for operators.
+func (c *QueueCoordinator) List(ctx context.Context, query httpx.ListQuery) ([]TicketSubmission, httpx.Page, error) {
+	if c == nil || c.store == nil {
+		return nil, httpx.Page{}, errors.New("ticket submissions are not configured")
+	}
+	return c.store.List(ctx, query)
+}
+
+// Requeue resets a pending, processing or failed submission and publishes it again.
+func (c *QueueCoordinator) Requeue(ctx context.Context, id string) (*TicketSubmission, error) {
+	if c == nil || c.store == nil {
+		return nil, errors.New("ticket submissions are not configured")
+	}
+
+	submission, err := c.store.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := requeueSubmission(ctx, c.store, submission); err != nil {
+		return nil, err
+	}
+	return submission, nil
+}
+
 // Metrics exposes queue statistics for observability.
 func (c *QueueCoordinator) Metrics(ctx context.Context) (SubmissionMetrics, error) {
 	if c == nil || c.store == nil {
@@ -108,19 +123,16 @@ func (c *QueueCoordinator) Metrics(ctx context.Context) (SubmissionMetrics, erro
 	return c.store.Metrics(ctx)
 }
 
-func (c *QueueCoordinator) publish(ctx context.Context, submission *TicketSubmission) error {
-	if c.producer == nil {
-		return errors.New("queue producer not configured")
-	}
-
-	payload, err := json.Marshal(struct {
-		SubmissionID string `json:"submissionId"`
-	}{SubmissionID: submission.ID})
-	if err != nil {
-		return fmt.Errorf("marshal submission payload: %w", err)
+func requeueSubmission(ctx context.Context, store SubmissionStore, submission *TicketSubmission) error {
+	if submission.Status == SubmissionCompleted {
+		return ErrSubmissionCompleted
 	}
 
-	return c.producer.Publish(ctx, submission.ID, payload, map[string]string{
-		"submitted_at": submission.CreatedAt.Format(time.RFC3339Nano),
-	})
+	status, updatedAt := submission.Status, submission.UpdatedAt
+	submission.Status = SubmissionPending
+	submission.ErrorMessage = ""
+	submission.TicketID = nil
+	submission.CompletedAt = nil
+	submission.RequeueCount++
+	return store.SaveIfUnchanged(ctx, submission, status, updatedAt, newSubmissionMessage)
 }
@@ -0,0 +1,122 @@
+package ticket
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// ReaperConfig controls when submissions count as stuck and what happens to them.
+type ReaperConfig struct {
+	// PendingAfter is how long a submission may stay pending once its message was delivered.
+	PendingAfter time.Duration
+	// ProcessingAfter is how long a worker may hold a submission in processing.
+	ProcessingAfter time.Duration
+	// MaxRequeues is how often a stuck submission is republished before it is failed.
+	MaxRequeues int
+	Interval    time.Duration
+	BatchSize   int
+}
+
+func (cfg ReaperConfig) normalize() ReaperConfig {
+	normalized := cfg
+	if normalized.PendingAfter <= 0 {
+		normalized.PendingAfter = 5 * time.Minute
+	}
+	if normalized.ProcessingAfter <= 0 {
+		normalized.ProcessingAfter = 10 * time.Minute
+	}
+	if normalized.MaxRequeues < 0 {
+		normalized.MaxRequeues = 0
+	}
+	if normalized.Interval <= 0 {
+		normalized.Interval = time.Minute
+	}
+	if normalized.BatchSize <= 0 {
+		normalized.BatchSize = 100
+	}
+	return normalized
+}
+
+// SubmissionReaper periodically republishes or fails submissions stranded in
+// SubmissionPending or SubmissionProcessing.
+type SubmissionReaper struct {
+	store SubmissionStore
+	cfg   ReaperConfig
+}
+
+// NewSubmissionReaper constructs a reaper over the provided submission store.
+func NewSubmissionReaper(store SubmissionStore, cfg ReaperConfig) *SubmissionReaper {
+	return &SubmissionReaper{store: store, cfg: cfg.normalize()}
+}
+
+// Run reaps stuck submissions every interval until the context is cancelled.
+func (r *SubmissionReaper) Run(ctx context.Context) error {
+	if r == nil || r.store == nil {
+		return fmt.Errorf("submission reaper not initialised")
+	}
+
+	ticker := time.NewTicker(r.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := r.ReapOnce(ctx); err != nil {
+			log.Printf("submission reaper: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// ReapOnce handles one batch of stuck submissions per status and returns how many were touched.
+func (r *SubmissionReaper) ReapOnce(ctx context.Context) (int, error) {
+	now := time.Now()
+	thresholds := []struct {
+		status string
+		after  time.Duration
+	}{
+		{SubmissionPending, r.cfg.PendingAfter},
+		{SubmissionProcessing, r.cfg.ProcessingAfter},
+	}
+
+	reaped := 0
+	for _, threshold := range thresholds {
+		stale, err := r.store.FindStale(ctx, threshold.status, now.Add(-threshold.after), r.cfg.BatchSize)
+		if err != nil {
+			return reaped, err
+		}
+
+		for i := range stale {
+			submission := &stale[i]
+			err := r.reap(ctx, submission, threshold.after)
+			if errors.Is(err, ErrSubmissionChanged) {
+				continue
+			}
+			if err != nil {
+				log.Printf("submission reaper: failed to reap %s: %v", submission.ID, err)
+				continue
+			}
+			reaped++
+		}
+	}
+	return reaped, nil
+}
+
+func (r *SubmissionReaper) reap(ctx context.Context, submission *TicketSubmission, after time.Duration) error {
+	if submission.RequeueCount < r.cfg.MaxRequeues {
+		log.Printf("submission reaper: requeueing %s stuck in %s for over %s", submission.ID, submission.Status, after)
+		return requeueSubmission(ctx, r.store, submission)
+	}
+
+	log.Printf("submission reaper: failing %s stuck in %s after %d requeues", submission.ID, submission.Status, submission.RequeueCount)
+	status, updatedAt := submission.Status, submission.UpdatedAt
+	submission.ErrorMessage = fmt.Sprintf("submission stuck in %s for over %s after %d requeues", submission.Status, after, submission.RequeueCount)
+	submission.Status = SubmissionFailed
+	return r.store.SaveIfUnchanged(ctx, submission, status, updatedAt, nil)
+}
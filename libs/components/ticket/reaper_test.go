@@ -0,0 +1,116 @@
+package ticket
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pflow/components/outbox"
+)
+
+type staleStore struct {
+	SubmissionStore
+	stale    map[string][]TicketSubmission
+	saved    []TicketSubmission
+	outboxed []*outbox.Message
+}
+
+func (s *staleStore) FindStale(ctx context.Context, status string, updatedBefore time.Time, limit int) ([]TicketSubmission, error) {
+	return s.stale[status], nil
+}
+
+func (s *staleStore) SaveIfUnchanged(ctx context.Context, submission *TicketSubmission, status string, updatedAt time.Time, build func(*TicketSubmission) (*outbox.Message, error)) error {
+	if build != nil {
+		message, err := build(submission)
+		if err != nil {
+			return err
+		}
+		s.outboxed = append(s.outboxed, message)
+	}
+	s.saved = append(s.saved, *submission)
+	return nil
+}
+
+func TestReaperRequeuesUntilLimitThenFails(t *testing.T) {
+	store := &staleStore{stale: map[string][]TicketSubmission{
+		SubmissionPending:    {{ID: "fresh", Status: SubmissionPending}},
+		SubmissionProcessing: {{ID: "exhausted", Status: SubmissionProcessing, RequeueCount: 2}},
+	}}
+	reaper := NewSubmissionReaper(store, ReaperConfig{MaxRequeues: 2})
+
+	reaped, err := reaper.ReapOnce(context.Background())
+	if err != nil {
+		t.Fatalf("reap: %v", err)
+	}
+	if reaped != 2 {
+		t.Fatalf("expected 2 reaped submissions, got %d", reaped)
+	}
+
+	if len(store.outboxed) != 1 || store.outboxed[0].AggregateID != "fresh" {
+		t.Fatalf("expected only the fresh submission to be republished, got %+v", store.outboxed)
+	}
+
+	byID := map[string]TicketSubmission{}
+	for _, saved := range store.saved {
+		byID[saved.ID] = saved
+	}
+	if got := byID["fresh"]; got.Status != SubmissionPending || got.RequeueCount != 1 {
+		t.Fatalf("unexpected requeued submission: %+v", got)
+	}
+	if got := byID["exhausted"]; got.Status != SubmissionFailed || got.ErrorMessage == "" {
+		t.Fatalf("expected exhausted submission to fail, got %+v", got)
+	}
+}
+
+func TestRequeueRejectsCompletedSubmission(t *testing.T) {
+	store := &staleStore{}
+	err := requeueSubmission(context.Background(), store, &TicketSubmission{ID: "done", Status: SubmissionCompleted})
+	if err != ErrSubmissionCompleted {
+		t.Fatalf("expected ErrSubmissionCompleted, got %v", err)
+	}
+}
+
+func TestReaperLeavesSubmissionsThatMovedOn(t *testing.T) {
+	store := NewSubmissionRepository(newTestDB(t))
+	ctx := context.Background()
+	submission := &TicketSubmission{Status: SubmissionProcessing}
+	if err := store.Create(ctx, submission); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	stale, err := store.FindStale(ctx, SubmissionProcessing, time.Now().Add(time.Minute), 10)
+	if err != nil || len(stale) != 1 {
+		t.Fatalf("expected the submission to be stale, got %d (%v)", len(stale), err)
+	}
+
+	// A worker completes the submission after the reaper read it.
+	completed, _ := store.FindByID(ctx, submission.ID)
+	completed.Status = SubmissionCompleted
+	completed.UpdatedAt = time.Now().Add(time.Second)
+	if err := store.Save(ctx, completed); err != nil {
+		t.Fatalf("complete: %v", err)
+	}
+
+	if err := requeueSubmission(ctx, store, &stale[0]); err != ErrSubmissionChanged {
+		t.Fatalf("expected the requeue to lose against the worker, got %v", err)
+	}
+	reaper := NewSubmissionReaper(store, ReaperConfig{})
+	if err := reaper.reap(ctx, &stale[0], time.Minute); err != ErrSubmissionChanged {
+		t.Fatalf("expected failing the submission to lose against the worker, got %v", err)
+	}
+	if got, _ := store.FindByID(ctx, submission.ID); got.Status != SubmissionCompleted || got.RequeueCount != 0 {
+		t.Fatalf("expected the completed submission to be kept, got %+v", got)
+	}
+
+	fresh, _ := store.FindByID(ctx, submission.ID)
+	fresh.Status = SubmissionProcessing
+	if err := store.Save(ctx, fresh); err != nil {
+		t.Fatalf("reset: %v", err)
+	}
+	fresh, _ = store.FindByID(ctx, submission.ID)
+	if err := requeueSubmission(ctx, store, fresh); err != nil {
+		t.Fatalf("requeue: %v", err)
+	}
+	if got, _ := store.FindByID(ctx, submission.ID); got.Status != SubmissionPending || got.RequeueCount != 1 {
+		t.Fatalf("expected an unchanged submission to be requeued, got %+v", got)
+	}
+}
@@ -0,0 +1,38 @@
+package ticket
+
+import (
+	"context"
+
+	"github.com/pflow/shared/httpx"
+)
+
+// openStatuses are the statuses of tickets still being worked on.
+var openStatuses = []string{StatusOpen, StatusInProgress}
+
+// FormReferences counts the open tickets submitted through a form, so the form
+// component can refuse to delete forms that are still in use when it shares the
+// ticket database; the form service uses form.NewRemoteReferences instead.
+type FormReferences struct {
+	count func(ctx context.Context, formID string) (int64, error)
+}
+
+// NewFormReferences constructs FormReferences that read tickets from the repository.
+func NewFormReferences(repo Repository) *FormReferences {
+	return &FormReferences{count: func(ctx context.Context, formID string) (int64, error) {
+		statuses := make([]any, 0, len(openStatuses))
+		for _, status := range openStatuses {
+			statuses = append(statuses, status)
+		}
+		_, page, err := repo.List(ctx, httpx.ListQuery{
+			Limit:      1,
+			SortColumn: "created_at",
+			Filters:    map[string][]any{"form_id": {formID}, "status": statuses},
+		})
+		return page.Total, err
+	}}
+}
+
+// OpenTickets returns how many open or in-progress tickets reference the form.
+func (f *FormReferences) OpenTickets(ctx context.Context, formID string) (int64, error) {
+	return f.count(ctx, formID)
+}
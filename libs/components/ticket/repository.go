@@ -3,22 +3,36 @@ package ticket
 import (
 	"context"
 	"errors"
+	"fmt"
+	"log"
 	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/pflow/shared/database"
+	"github.com/pflow/shared/httpx"
+	"github.com/pflow/shared/tenant"
 )
 
 // Repository defines the persistence contract for tickets.
 type Repository interface {
-	List(ctx context.Context, status, assignee string) ([]Ticket, error)
+	List(ctx context.Context, query httpx.ListQuery) ([]Ticket, httpx.Page, error)
 	Create(ctx context.Context, entity *Ticket) error
 	Find(ctx context.Context, id string) (*Ticket, error)
+	FindForUpdate(ctx context.Context, id string) (*Ticket, error)
+	Transaction(ctx context.Context, fn func(ctx context.Context) error) error
 	Update(ctx context.Context, id string, updates map[string]any) (*Ticket, error)
 	Delete(ctx context.Context, id string) error
-	Resolve(ctx context.Context, id string) (*Ticket, error)
+	Restore(ctx context.Context, id string) (*Ticket, error)
+	Transition(ctx context.Context, id string, req TransitionRequest, rules TransitionRules) (*Ticket, error)
+	History(ctx context.Context, id string) ([]TicketTransition, error)
+	Assign(ctx context.Context, id string, req AssignmentRequest) (*Ticket, error)
+	Assignments(ctx context.Context, id string) ([]TicketAssignment, error)
 }
 
-// GormRepository persists tickets using a relational database via GORM.
+// GormRepository persists tickets using a relational database via GORM. Every
+// query is scoped to the tenant of the request context.
 type GormRepository struct {
 	db *gorm.DB
 }
@@ -28,59 +42,80 @@ func NewGormRepository(db *gorm.DB) *GormRepository {
 	return &GormRepository{db: db}
 }
 
-// List returns tickets filtered by optional status or assignee.
-func (r *GormRepository) List(ctx context.Context, status, assignee string) ([]Ticket, error) {
-	query := r.db.WithContext(ctx).Model(&Ticket{}).Order("created_at DESC")
-	if status != "" {
-		query = query.Where("status = ?", status)
-	}
-	if assignee != "" {
-		query = query.Where("assignee_id = ?", assignee)
-	}
+func (r *GormRepository) scoped(ctx context.Context) *gorm.DB {
+	return database.Conn(ctx, r.db).Scopes(database.TenantScope(ctx))
+}
 
-	var tickets []Ticket
-	if err := query.Find(&tickets).Error; err != nil {
-		return nil, err
-	}
-	return tickets, nil
+// List returns a page of tickets matching the query filters.
+func (r *GormRepository) List(ctx context.Context, query httpx.ListQuery) ([]Ticket, httpx.Page, error) {
+	return database.Paginate(r.scoped(ctx).Model(&Ticket{}), query, Ticket.sortValue)
 }
 
-// Create persists a new ticket.
+// Create persists a new ticket and starts its SLA clock.
 func (r *GormRepository) Create(ctx context.Context, entity *Ticket) error {
-	return r.db.WithContext(ctx).Create(entity).Error
+	entity.TenantID = tenant.ID(ctx)
+	if entity.CreatedAt.IsZero() {
+		entity.CreatedAt = time.Now()
+	}
+	if err := r.applySLA(ctx, entity); err != nil {
+		return err
+	}
+	return database.Conn(ctx, r.db).Create(entity).Error
 }
 
 // Find retrieves a ticket by ID.
 func (r *GormRepository) Find(ctx context.Context, id string) (*Ticket, error) {
 	var entity Ticket
-	if err := r.db.WithContext(ctx).First(&entity, "id = ?", id).Error; err != nil {
+	if err := r.scoped(ctx).First(&entity, "id = ?", id).Error; err != nil {
 		return nil, err
 	}
 	return &entity, nil
 }
 
-// Update applies updates to a ticket.
-func (r *GormRepository) Update(ctx context.Context, id string, updates map[string]any) (*Ticket, error) {
+// FindForUpdate retrieves a ticket and locks its row until the transaction of
+// ctx ends, so concurrent changes of the ticket wait for it.
+func (r *GormRepository) FindForUpdate(ctx context.Context, id string) (*Ticket, error) {
 	var entity Ticket
-	tx := r.db.WithContext(ctx)
-	if err := tx.First(&entity, "id = ?", id).Error; err != nil {
+	if err := r.scoped(ctx).Clauses(clause.Locking{Strength: "UPDATE"}).First(&entity, "id = ?", id).Error; err != nil {
 		return nil, err
 	}
+	return &entity, nil
+}
+
+// Transaction runs fn in a transaction that the repository calls made with the
+// context passed to fn join.
+func (r *GormRepository) Transaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return database.Transaction(ctx, r.db, fn)
+}
 
-	if err := tx.Model(&entity).Updates(updates).Error; err != nil {
+// Update applies updates to a ticket. A new priority recomputes the SLA
+// deadlines from the creation time and clears recorded breaches.
+func (r *GormRepository) Update(ctx context.Context, id string, updates map[string]any) (*Ticket, error) {
+	var entity Ticket
+	if err := r.scoped(ctx).First(&entity, "id = ?", id).Error; err != nil {
 		return nil, err
 	}
 
-	if err := tx.First(&entity, "id = ?", id).Error; err != nil {
+	if priority, ok := updates["priority"].(string); ok && priority != entity.Priority {
+		entity.Priority = priority
+		if err := r.applySLA(ctx, &entity); err != nil {
+			return nil, err
+		}
+		for column, value := range entity.slaUpdates() {
+			updates[column] = value
+		}
+	}
+
+	if err := database.Conn(ctx, r.db).Model(&entity).Updates(updates).Error; err != nil {
 		return nil, err
 	}
 
-	return &entity, nil
+	return r.Find(ctx, id)
 }
 
-// Delete removes a ticket.
+// Delete soft-deletes a ticket. The ticket can be restored until it is purged.
 func (r *GormRepository) Delete(ctx context.Context, id string) error {
-	result := r.db.WithContext(ctx).Delete(&Ticket{}, "id = ?", id)
+	result := r.scoped(ctx).Delete(&Ticket{}, "id = ?", id)
 	if result.Error != nil {
 		return result.Error
 	}
@@ -90,17 +125,439 @@ func (r *GormRepository) Delete(ctx context.Context, id string) error {
 	return nil
 }
 
-// Resolve marks a ticket as resolved and sets the timestamp.
-func (r *GormRepository) Resolve(ctx context.Context, id string) (*Ticket, error) {
+// Restore undeletes a soft-deleted ticket, failing with database.ErrNotDeleted
+// when the ticket was not deleted.
+func (r *GormRepository) Restore(ctx context.Context, id string) (*Ticket, error) {
+	if err := database.Restore(ctx, r.db, &Ticket{}, id); err != nil {
+		return nil, err
+	}
+	return r.Find(ctx, id)
+}
+
+// PurgeDeleted hard-deletes, in every tenant, up to limit tickets deleted
+// before the cutoff together with their comments, history, assignments and
+// attachment records. It returns how many tickets it removed and the blob keys
+// that no attachment references anymore.
+func (r *GormRepository) PurgeDeleted(ctx context.Context, before time.Time, limit int) (int, []string, error) {
+	var keys []string
+	purged, err := database.PurgeDeleted(ctx, r.db, &Ticket{}, before, limit, func(tx *gorm.DB, ids []string) error {
+		if err := tx.Model(&TicketAttachment{}).Where("ticket_id IN ?", ids).Distinct().Pluck("blob_key", &keys).Error; err != nil {
+			return err
+		}
+		for _, model := range []any{&TicketAttachment{}, &TicketComment{}, &TicketTransition{}, &TicketAssignment{}} {
+			if err := tx.Where("ticket_id IN ?", ids).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		if len(keys) == 0 {
+			return nil
+		}
+
+		shared, err := blobKeysInUse(tx, keys)
+		if err != nil {
+			return err
+		}
+		keys = without(keys, shared)
+		return nil
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+	return purged, keys, nil
+}
+
+// Transition moves a ticket to a new status when the rules allow it and records the change.
+func (r *GormRepository) Transition(ctx context.Context, id string, req TransitionRequest, rules TransitionRules) (*Ticket, error) {
+	var entity Ticket
+	err := database.Conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Scopes(database.TenantScope(ctx)).Clauses(clause.Locking{Strength: "UPDATE"}).First(&entity, "id = ?", id).Error; err != nil {
+			return err
+		}
+
+		from := entity.Status
+		if !rules.Allows(from, req.To) {
+			return fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, from, req.To)
+		}
+
+		now := time.Now()
+		updates := map[string]any{"status": req.To}
+		if req.To == StatusResolved {
+			updates["resolved_at"] = &now
+		} else if from == StatusResolved {
+			updates["resolved_at"] = nil
+		}
+		if from == StatusOpen && entity.FirstRespondedAt == nil {
+			updates["first_responded_at"] = &now
+		}
+		if err := tx.Model(&entity).Updates(updates).Error; err != nil {
+			return err
+		}
+
+		record := &TicketTransition{
+			TicketID:   entity.ID,
+			FromStatus: from,
+			ToStatus:   req.To,
+			Actor:      req.Actor,
+			Reason:     req.Reason,
+		}
+		if err := tx.Create(record).Error; err != nil {
+			return err
+		}
+
+		return tx.First(&entity, "id = ?", id).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &entity, nil
+}
+
+// History returns the recorded status changes of a ticket, oldest first.
+func (r *GormRepository) History(ctx context.Context, id string) ([]TicketTransition, error) {
+	if err := r.ensureTicket(ctx, id); err != nil {
+		return nil, err
+	}
+
+	var transitions []TicketTransition
+	if err := database.Conn(ctx, r.db).Where("ticket_id = ?", id).Order("created_at ASC").Find(&transitions).Error; err != nil {
+		return nil, err
+	}
+	return transitions, nil
+}
+
+// Assign changes the assignee of a ticket and records the change. Assigning
+// the current assignee again records nothing.
+func (r *GormRepository) Assign(ctx context.Context, id string, req AssignmentRequest) (*Ticket, error) {
+	var entity Ticket
+	err := database.Conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Scopes(database.TenantScope(ctx)).Clauses(clause.Locking{Strength: "UPDATE"}).First(&entity, "id = ?", id).Error; err != nil {
+			return err
+		}
+
+		from := entity.AssigneeID
+		if from == req.AssigneeID {
+			return nil
+		}
+		if err := tx.Model(&entity).Update("assignee_id", req.AssigneeID).Error; err != nil {
+			return err
+		}
+
+		record := &TicketAssignment{
+			TicketID:     entity.ID,
+			FromAssignee: from,
+			ToAssignee:   req.AssigneeID,
+			Actor:        req.Actor,
+		}
+		return tx.Create(record).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &entity, nil
+}
+
+// Assignments returns the recorded assignee changes of a ticket, oldest first.
+func (r *GormRepository) Assignments(ctx context.Context, id string) ([]TicketAssignment, error) {
+	if err := r.ensureTicket(ctx, id); err != nil {
+		return nil, err
+	}
+
+	var assignments []TicketAssignment
+	if err := database.Conn(ctx, r.db).Where("ticket_id = ?", id).Order("created_at ASC").Find(&assignments).Error; err != nil {
+		return nil, err
+	}
+	return assignments, nil
+}
+
+// ListComments returns the comments of a ticket, oldest first, leaving out
+// internal ones unless includeInternal is set.
+func (r *GormRepository) ListComments(ctx context.Context, ticketID string, includeInternal bool) ([]TicketComment, error) {
+	if err := r.ensureTicket(ctx, ticketID); err != nil {
+		return nil, err
+	}
+
+	query := database.Conn(ctx, r.db).Where("ticket_id = ?", ticketID)
+	if !includeInternal {
+		query = query.Where("visibility = ?", VisibilityPublic)
+	}
+	var comments []TicketComment
+	if err := query.Order("created_at ASC").Find(&comments).Error; err != nil {
+		return nil, err
+	}
+	return comments, nil
+}
+
+// FindComment returns a single comment of a ticket.
+func (r *GormRepository) FindComment(ctx context.Context, ticketID, commentID string) (*TicketComment, error) {
+	if err := r.ensureTicket(ctx, ticketID); err != nil {
+		return nil, err
+	}
+
+	var comment TicketComment
+	if err := database.Conn(ctx, r.db).First(&comment, "id = ? AND ticket_id = ?", commentID, ticketID).Error; err != nil {
+		return nil, err
+	}
+	return &comment, nil
+}
+
+// CreateComment adds a comment to a ticket. The first public comment counts
+// as the first response to the ticket.
+func (r *GormRepository) CreateComment(ctx context.Context, comment *TicketComment) error {
+	if err := r.ensureTicket(ctx, comment.TicketID); err != nil {
+		return err
+	}
+	return database.Conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(comment).Error; err != nil {
+			return err
+		}
+		if comment.Visibility != VisibilityPublic {
+			return nil
+		}
+		return tx.Model(&Ticket{}).
+			Where("id = ? AND first_responded_at IS NULL", comment.TicketID).
+			UpdateColumn("first_responded_at", comment.CreatedAt).Error
+	})
+}
+
+// UpdateComment applies updates to a comment that was not deleted and marks
+// it as edited.
+func (r *GormRepository) UpdateComment(ctx context.Context, ticketID, commentID string, updates map[string]any) (*TicketComment, error) {
+	comment, err := r.FindComment(ctx, ticketID, commentID)
+	if err != nil {
+		return nil, err
+	}
+	if comment.Deleted {
+		return nil, ErrCommentDeleted
+	}
+
 	now := time.Now()
-	updates := map[string]any{
-		"status":      StatusResolved,
-		"resolved_at": &now,
+	updates["edited"] = true
+	updates["edited_at"] = &now
+	if err := database.Conn(ctx, r.db).Model(comment).Updates(updates).Error; err != nil {
+		return nil, err
+	}
+	return r.FindComment(ctx, ticketID, commentID)
+}
+
+// DeleteComment flags a comment as deleted and discards its body.
+func (r *GormRepository) DeleteComment(ctx context.Context, ticketID, commentID string) error {
+	comment, err := r.FindComment(ctx, ticketID, commentID)
+	if err != nil {
+		return err
+	}
+	if comment.Deleted {
+		return ErrCommentDeleted
+	}
+
+	now := time.Now()
+	return database.Conn(ctx, r.db).Model(comment).Updates(map[string]any{
+		"body":       "",
+		"deleted":    true,
+		"deleted_at": &now,
+	}).Error
+}
+
+// ListAttachments returns the attachments of a ticket, oldest first.
+func (r *GormRepository) ListAttachments(ctx context.Context, ticketID string) ([]TicketAttachment, error) {
+	if err := r.ensureTicket(ctx, ticketID); err != nil {
+		return nil, err
+	}
+
+	var attachments []TicketAttachment
+	if err := database.Conn(ctx, r.db).Where("ticket_id = ?", ticketID).Order("created_at ASC").Find(&attachments).Error; err != nil {
+		return nil, err
+	}
+	return attachments, nil
+}
+
+// FindAttachment returns a single attachment of a ticket.
+func (r *GormRepository) FindAttachment(ctx context.Context, ticketID, attachmentID string) (*TicketAttachment, error) {
+	if err := r.ensureTicket(ctx, ticketID); err != nil {
+		return nil, err
+	}
+
+	var attachment TicketAttachment
+	if err := database.Conn(ctx, r.db).First(&attachment, "id = ? AND ticket_id = ?", attachmentID, ticketID).Error; err != nil {
+		return nil, err
+	}
+	return &attachment, nil
+}
+
+// FindAttachmentByHash returns the attachment of a ticket with the given content digest.
+func (r *GormRepository) FindAttachmentByHash(ctx context.Context, ticketID, sum string) (*TicketAttachment, error) {
+	if err := r.ensureTicket(ctx, ticketID); err != nil {
+		return nil, err
+	}
+
+	var attachment TicketAttachment
+	if err := database.Conn(ctx, r.db).First(&attachment, "ticket_id = ? AND sha256 = ?", ticketID, sum).Error; err != nil {
+		return nil, err
+	}
+	return &attachment, nil
+}
+
+// CreateAttachment records an attachment of a ticket in the current tenant.
+func (r *GormRepository) CreateAttachment(ctx context.Context, attachment *TicketAttachment) error {
+	if err := r.ensureTicket(ctx, attachment.TicketID); err != nil {
+		return err
+	}
+	attachment.TenantID = tenant.ID(ctx)
+	return database.Conn(ctx, r.db).Create(attachment).Error
+}
+
+// DeleteAttachment removes an attachment record and returns it so the caller
+// can release its blob.
+func (r *GormRepository) DeleteAttachment(ctx context.Context, ticketID, attachmentID string) (*TicketAttachment, error) {
+	attachment, err := r.FindAttachment(ctx, ticketID, attachmentID)
+	if err != nil {
+		return nil, err
 	}
-	return r.Update(ctx, id, updates)
+	if err := database.Conn(ctx, r.db).Delete(attachment).Error; err != nil {
+		return nil, err
+	}
+	return attachment, nil
+}
+
+// BlobInUse reports whether any attachment, staged ones included, references
+// the blob. Keys embed the tenant, so the lookup spans all tenants.
+func (r *GormRepository) BlobInUse(ctx context.Context, key string) (bool, error) {
+	used, err := blobKeysInUse(database.Conn(ctx, r.db), []string{key})
+	return len(used) > 0, err
+}
+
+// LockBlob takes the lock of a blob key until the transaction of ctx ends.
+func (r *GormRepository) LockBlob(ctx context.Context, key string) error {
+	return database.LockKey(database.Conn(ctx, r.db), "ticket-blob:"+key)
+}
+
+// blobKeysInUse returns the keys that ticket or staged submission attachments
+// still reference.
+func blobKeysInUse(tx *gorm.DB, keys []string) ([]string, error) {
+	var used []string
+	for _, model := range []any{&TicketAttachment{}, &SubmissionAttachment{}} {
+		var found []string
+		if err := tx.Model(model).Where("blob_key IN ?", keys).Distinct().Pluck("blob_key", &found).Error; err != nil {
+			return nil, err
+		}
+		used = append(used, found...)
+	}
+	return used, nil
+}
+
+// LockSubmission returns a submission of the current tenant locked until the
+// transaction of ctx ends, so staging files and completing the submission are
+// serialised.
+func (r *GormRepository) LockSubmission(ctx context.Context, submissionID string) (*TicketSubmission, error) {
+	var submission TicketSubmission
+	if err := r.scoped(ctx).Clauses(clause.Locking{Strength: "UPDATE"}).First(&submission, "id = ?", submissionID).Error; err != nil {
+		return nil, err
+	}
+	return &submission, nil
+}
+
+// ListSubmissionAttachments returns the files staged for a submission of the
+// current tenant.
+func (r *GormRepository) ListSubmissionAttachments(ctx context.Context, submissionID string) ([]SubmissionAttachment, error) {
+	if err := r.scoped(ctx).Select("id").First(&TicketSubmission{}, "id = ?", submissionID).Error; err != nil {
+		return nil, err
+	}
+
+	var attachments []SubmissionAttachment
+	if err := r.scoped(ctx).Where("submission_id = ?", submissionID).Order("created_at ASC").Find(&attachments).Error; err != nil {
+		return nil, err
+	}
+	return attachments, nil
+}
+
+// FindSubmissionAttachmentByHash returns the file staged for a submission with
+// the given content digest.
+func (r *GormRepository) FindSubmissionAttachmentByHash(ctx context.Context, submissionID, sum string) (*SubmissionAttachment, error) {
+	var attachment SubmissionAttachment
+	if err := r.scoped(ctx).First(&attachment, "submission_id = ? AND sha256 = ?", submissionID, sum).Error; err != nil {
+		return nil, err
+	}
+	return &attachment, nil
+}
+
+// StageAttachment records a file for a submission of the current tenant.
+func (r *GormRepository) StageAttachment(ctx context.Context, attachment *SubmissionAttachment) error {
+	attachment.TenantID = tenant.ID(ctx)
+	return database.Conn(ctx, r.db).Create(attachment).Error
+}
+
+// AttachSubmission turns the files staged for a submission into attachments
+// of the ticket created from it and returns them. The attachments are created
+// before the staged rows are removed, so their blobs stay referenced.
+func (r *GormRepository) AttachSubmission(ctx context.Context, submissionID, ticketID string) ([]TicketAttachment, error) {
+	var attached []TicketAttachment
+	err := database.Transaction(ctx, r.db, func(ctx context.Context) error {
+		var staged []SubmissionAttachment
+		if err := r.scoped(ctx).Where("submission_id = ?", submissionID).Order("created_at ASC").Find(&staged).Error; err != nil {
+			return err
+		}
+		for _, file := range staged {
+			attachment := file.ticketAttachment(ticketID)
+			if err := r.CreateAttachment(ctx, attachment); err != nil {
+				return err
+			}
+			attached = append(attached, *attachment)
+		}
+		return r.scoped(ctx).Where("submission_id = ?", submissionID).Delete(&SubmissionAttachment{}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return attached, nil
+}
+
+func without(values, remove []string) []string {
+	skip := make(map[string]bool, len(remove))
+	for _, value := range remove {
+		skip[value] = true
+	}
+	kept := values[:0]
+	for _, value := range values {
+		if !skip[value] {
+			kept = append(kept, value)
+		}
+	}
+	return kept
+}
+
+// ensureTicket fails with gorm.ErrRecordNotFound unless the ticket exists in
+// the current tenant.
+func (r *GormRepository) ensureTicket(ctx context.Context, id string) error {
+	return r.scoped(ctx).Select("id").First(&Ticket{}, "id = ?", id).Error
+}
+
+// applySLA sets the SLA deadlines of a ticket from the policy matching its
+// priority and form. A policy whose calendar has no business hours left is
+// logged and skipped rather than blocking the ticket.
+func (r *GormRepository) applySLA(ctx context.Context, entity *Ticket) error {
+	entity.SLAPolicyID, entity.FirstResponseDueAt, entity.DueAt = nil, nil, nil
+	entity.Breached, entity.ResponseBreachedAt, entity.ResolutionBreachedAt = false, nil, nil
+
+	policy, err := policyFor(ctx, r.db, entity.Priority, entity.FormID)
+	if err != nil || policy == nil {
+		return err
+	}
+	firstResponse, resolution, err := policy.Deadlines(entity.CreatedAt)
+	if err != nil {
+		log.Printf("ticket sla: policy %s does not apply to ticket %s: %v", policy.ID, entity.ID, err)
+		return nil
+	}
+	entity.SLAPolicyID = &policy.ID
+	entity.FirstResponseDueAt = firstResponse
+	entity.DueAt = resolution
+	return nil
 }
 
 // IsNotFound returns true if the error represents a missing record.
 func IsNotFound(err error) bool {
 	return errors.Is(err, gorm.ErrRecordNotFound)
 }
+
+// IsInvalidTransition returns true if the error represents a rejected status change.
+func IsInvalidTransition(err error) bool {
+	return errors.Is(err, ErrInvalidTransition)
+}
@@ -0,0 +1,254 @@
+package ticket
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+
+	"github.com/pflow/shared/audit"
+	"github.com/pflow/shared/httpx"
+)
+
+const (
+	// SLATargetFirstResponse is the deadline for the first public comment or
+	// for moving the ticket out of StatusOpen.
+	SLATargetFirstResponse = "first_response"
+	// SLATargetResolution is the deadline for resolving the ticket.
+	SLATargetResolution = "resolution"
+)
+
+// ErrSLAPolicyConflict is returned when a tenant already has a policy for the
+// same priority and form.
+var ErrSLAPolicyConflict = errors.New("an SLA policy for this priority and form already exists")
+
+// SLAPolicy sets the response and resolution targets of tickets with a
+// priority, optionally limited to one form. A policy for a form wins over the
+// policy for any form. Targets are business minutes measured on the calendar;
+// zero means no target.
+type SLAPolicy struct {
+	ID                   string                               `json:"id" gorm:"type:uuid;primaryKey"`
+	TenantID             string                               `json:"tenantId" gorm:"type:varchar(64);not null;default:default;index"`
+	Name                 string                               `json:"name" gorm:"not null"`
+	Priority             string                               `json:"priority" gorm:"type:varchar(32);not null;index"`
+	FormID               *string                              `json:"formId" gorm:"type:uuid;index"`
+	FirstResponseMinutes int                                  `json:"firstResponseMinutes" gorm:"not null;default:0"`
+	ResolutionMinutes    int                                  `json:"resolutionMinutes" gorm:"not null;default:0"`
+	Calendar             datatypes.JSONType[BusinessCalendar] `json:"calendar" gorm:"type:jsonb"`
+	CreatedAt            time.Time                            `json:"createdAt"`
+	UpdatedAt            time.Time                            `json:"updatedAt"`
+}
+
+// BeforeCreate assigns a UUID when missing.
+func (p *SLAPolicy) BeforeCreate(tx *gorm.DB) error {
+	if p.ID == "" {
+		p.ID = uuid.NewString()
+	}
+	return nil
+}
+
+// ToDTO exposes the policy for clients.
+func (p SLAPolicy) ToDTO() map[string]any {
+	dto := map[string]any{
+		"id":                   p.ID,
+		"tenantId":             p.TenantID,
+		"name":                 p.Name,
+		"priority":             p.Priority,
+		"firstResponseMinutes": p.FirstResponseMinutes,
+		"resolutionMinutes":    p.ResolutionMinutes,
+		"calendar":             p.Calendar.Data(),
+		"createdAt":            p.CreatedAt,
+		"updatedAt":            p.UpdatedAt,
+	}
+	if p.FormID != nil {
+		dto["formId"] = *p.FormID
+	}
+	return dto
+}
+
+// Deadlines returns the first response and resolution due dates of a ticket
+// created at from; a nil deadline means the policy sets no such target.
+func (p SLAPolicy) Deadlines(from time.Time) (firstResponse, resolution *time.Time, err error) {
+	calendar := p.Calendar.Data()
+	if p.FirstResponseMinutes > 0 {
+		due, err := calendar.Add(from, time.Duration(p.FirstResponseMinutes)*time.Minute)
+		if err != nil {
+			return nil, nil, err
+		}
+		firstResponse = &due
+	}
+	if p.ResolutionMinutes > 0 {
+		due, err := calendar.Add(from, time.Duration(p.ResolutionMinutes)*time.Minute)
+		if err != nil {
+			return nil, nil, err
+		}
+		resolution = &due
+	}
+	return firstResponse, resolution, nil
+}
+
+// SLAPolicyRepository persists the SLA policies of the current tenant.
+type SLAPolicyRepository interface {
+	ListPolicies(ctx context.Context) ([]SLAPolicy, error)
+	FindPolicy(ctx context.Context, id string) (*SLAPolicy, error)
+	SavePolicy(ctx context.Context, policy *SLAPolicy) error
+	DeletePolicy(ctx context.Context, id string) error
+}
+
+type slaPolicyRequest struct {
+	Name                 *string           `json:"name"`
+	Priority             *string           `json:"priority"`
+	FormID               *string           `json:"formId"`
+	FirstResponseMinutes *int              `json:"firstResponseMinutes"`
+	ResolutionMinutes    *int              `json:"resolutionMinutes"`
+	Calendar             *BusinessCalendar `json:"calendar"`
+}
+
+// apply copies the provided fields onto policy and validates the result.
+func (req slaPolicyRequest) apply(policy *SLAPolicy) error {
+	if req.Name != nil {
+		policy.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.Priority != nil {
+		policy.Priority = strings.ToLower(strings.TrimSpace(*req.Priority))
+	}
+	if req.FormID != nil {
+		policy.FormID = nil
+		if formID := strings.TrimSpace(*req.FormID); formID != "" {
+			if _, err := uuid.Parse(formID); err != nil {
+				return errors.New("formId must be a UUID")
+			}
+			policy.FormID = &formID
+		}
+	}
+	if req.FirstResponseMinutes != nil {
+		policy.FirstResponseMinutes = *req.FirstResponseMinutes
+	}
+	if req.ResolutionMinutes != nil {
+		policy.ResolutionMinutes = *req.ResolutionMinutes
+	}
+	if req.Calendar != nil {
+		if err := req.Calendar.Validate(); err != nil {
+			return err
+		}
+		policy.Calendar = datatypes.NewJSONType(*req.Calendar)
+	}
+
+	if policy.Priority == "" {
+		return errors.New("priority is required")
+	}
+	if policy.Name == "" {
+		policy.Name = policy.Priority
+	}
+	if policy.FirstResponseMinutes < 0 || policy.ResolutionMinutes < 0 {
+		return errors.New("targets must not be negative")
+	}
+	if policy.FirstResponseMinutes == 0 && policy.ResolutionMinutes == 0 {
+		return errors.New("firstResponseMinutes or resolutionMinutes is required")
+	}
+	return nil
+}
+
+func (h *Handler) listSLAPolicies(w http.ResponseWriter, r *http.Request) {
+	policies, err := h.slaPolicies.ListPolicies(r.Context())
+	if err != nil {
+		httpx.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	items := make([]map[string]any, 0, len(policies))
+	for _, policy := range policies {
+		items = append(items, policy.ToDTO())
+	}
+
+	httpx.JSON(w, http.StatusOK, map[string]any{"data": items})
+}
+
+func (h *Handler) createSLAPolicy(w http.ResponseWriter, r *http.Request) {
+	var payload slaPolicyRequest
+	if err := decodeJSON(r, &payload); err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	policy := &SLAPolicy{}
+	if err := payload.apply(policy); err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := h.slaPolicies.SavePolicy(r.Context(), policy); err != nil {
+		renderSLAPolicyError(w, err)
+		return
+	}
+	h.audit.Record(r.Context(), audit.Change{Action: auditSLAPolicyCreated, ResourceID: policy.ID, After: policy.ToDTO()})
+
+	httpx.JSON(w, http.StatusCreated, map[string]any{"data": policy.ToDTO()})
+}
+
+func (h *Handler) getSLAPolicy(w http.ResponseWriter, r *http.Request) {
+	policy, err := h.slaPolicies.FindPolicy(r.Context(), chi.URLParam(r, "policyId"))
+	if err != nil {
+		renderSLAPolicyError(w, err)
+		return
+	}
+
+	httpx.JSON(w, http.StatusOK, map[string]any{"data": policy.ToDTO()})
+}
+
+func (h *Handler) updateSLAPolicy(w http.ResponseWriter, r *http.Request) {
+	var payload slaPolicyRequest
+	if err := decodeJSON(r, &payload); err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	policy, err := h.slaPolicies.FindPolicy(r.Context(), chi.URLParam(r, "policyId"))
+	if err != nil {
+		renderSLAPolicyError(w, err)
+		return
+	}
+	before := policy.ToDTO()
+	if err := payload.apply(policy); err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := h.slaPolicies.SavePolicy(r.Context(), policy); err != nil {
+		renderSLAPolicyError(w, err)
+		return
+	}
+	h.audit.Record(r.Context(), audit.Change{Action: auditSLAPolicyUpdated, ResourceID: policy.ID, Before: before, After: policy.ToDTO()})
+
+	httpx.JSON(w, http.StatusOK, map[string]any{"data": policy.ToDTO()})
+}
+
+func (h *Handler) deleteSLAPolicy(w http.ResponseWriter, r *http.Request) {
+	policy, err := h.slaPolicies.FindPolicy(r.Context(), chi.URLParam(r, "policyId"))
+	if err != nil {
+		renderSLAPolicyError(w, err)
+		return
+	}
+	if err := h.slaPolicies.DeletePolicy(r.Context(), policy.ID); err != nil {
+		renderSLAPolicyError(w, err)
+		return
+	}
+	h.audit.Record(r.Context(), audit.Change{Action: auditSLAPolicyDeleted, ResourceID: policy.ID, Before: policy.ToDTO()})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func renderSLAPolicyError(w http.ResponseWriter, err error) {
+	switch {
+	case IsNotFound(err):
+		httpx.Error(w, http.StatusNotFound, "SLA policy not found")
+	case errors.Is(err, ErrSLAPolicyConflict):
+		httpx.Error(w, http.StatusConflict, err.Error())
+	default:
+		httpx.Error(w, http.StatusInternalServerError, err.Error())
+	}
+}
@@ -0,0 +1,126 @@
+package ticket
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/pflow/shared/mq"
+	"github.com/pflow/shared/tenant"
+)
+
+// EventSLABreached is the event type published for every missed SLA target.
+const EventSLABreached = "ticket.sla_breached"
+
+// SLABreachEvent is the data of the event published when a ticket misses an
+// SLA target.
+type SLABreachEvent struct {
+	TicketID   string    `json:"ticketId"`
+	Target     string    `json:"target"`
+	DueAt      time.Time `json:"dueAt"`
+	BreachedAt time.Time `json:"breachedAt"`
+}
+
+// SLAEvaluatorConfig tunes how often the evaluator looks for breaches.
+type SLAEvaluatorConfig struct {
+	Interval  time.Duration
+	BatchSize int
+}
+
+func (cfg SLAEvaluatorConfig) normalize() SLAEvaluatorConfig {
+	normalized := cfg
+	if normalized.Interval <= 0 {
+		normalized.Interval = time.Minute
+	}
+	if normalized.BatchSize <= 0 {
+		normalized.BatchSize = 100
+	}
+	return normalized
+}
+
+// SLAEvaluator periodically records missed SLA targets on tickets and
+// announces each one through the publisher. A breach is recorded before it is
+// published and recorded again later if publishing fails, so every breach is
+// announced at least once.
+type SLAEvaluator struct {
+	store     SLAStore
+	publisher mq.Publisher
+	cfg       SLAEvaluatorConfig
+}
+
+// NewSLAEvaluator constructs an evaluator over store. publisher may be nil, in
+// which case breaches are only recorded.
+func NewSLAEvaluator(store SLAStore, publisher mq.Publisher, cfg SLAEvaluatorConfig) *SLAEvaluator {
+	return &SLAEvaluator{store: store, publisher: publisher, cfg: cfg.normalize()}
+}
+
+// Run evaluates SLAs every interval until the context is cancelled.
+func (e *SLAEvaluator) Run(ctx context.Context) error {
+	if e == nil || e.store == nil {
+		return fmt.Errorf("sla evaluator not initialised")
+	}
+
+	ticker := time.NewTicker(e.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := e.EvaluateOnce(ctx); err != nil {
+			log.Printf("sla evaluator: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// EvaluateOnce records one batch of breaches and returns how many it recorded.
+func (e *SLAEvaluator) EvaluateOnce(ctx context.Context) (int, error) {
+	now := time.Now()
+	breaches, err := e.store.FindBreaches(ctx, now, e.cfg.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	recorded := 0
+	for _, breach := range breaches {
+		breach.DetectedAt = now
+		claimed, err := e.store.MarkBreach(ctx, breach)
+		if err != nil {
+			log.Printf("sla evaluator: failed to record %s breach of %s: %v", breach.Target, breach.TicketID, err)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+
+		if err := e.publish(ctx, breach); err != nil {
+			log.Printf("sla evaluator: failed to publish %s breach of %s: %v", breach.Target, breach.TicketID, err)
+			if err := e.store.UnmarkBreach(ctx, breach); err != nil {
+				log.Printf("sla evaluator: failed to revert %s breach of %s: %v", breach.Target, breach.TicketID, err)
+			}
+			continue
+		}
+		recorded++
+	}
+	return recorded, nil
+}
+
+func (e *SLAEvaluator) publish(ctx context.Context, breach SLABreach) error {
+	if e.publisher == nil {
+		return nil
+	}
+	event, err := mq.NewEvent(tenant.WithID(ctx, breach.TenantID), eventSource, EventSLABreached, breach.TicketID, SLABreachEvent{
+		TicketID:   breach.TicketID,
+		Target:     breach.Target,
+		DueAt:      breach.DueAt,
+		BreachedAt: breach.DetectedAt,
+	})
+	if err != nil {
+		return err
+	}
+	return mq.PublishEvent(ctx, e.publisher, event)
+}
@@ -0,0 +1,197 @@
+package ticket
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/pflow/shared/database"
+	"github.com/pflow/shared/tenant"
+)
+
+// SLABreach is a missed SLA target of a ticket.
+type SLABreach struct {
+	TicketID   string
+	TenantID   string
+	Target     string
+	DueAt      time.Time
+	DetectedAt time.Time
+}
+
+// SLAStore finds and records SLA breaches across all tenants for the
+// SLAEvaluator.
+type SLAStore interface {
+	FindBreaches(ctx context.Context, now time.Time, limit int) ([]SLABreach, error)
+	MarkBreach(ctx context.Context, breach SLABreach) (bool, error)
+	UnmarkBreach(ctx context.Context, breach SLABreach) error
+}
+
+// slaColumns names the ticket columns tracking one SLA target.
+type slaColumns struct {
+	due, met, breachedAt string
+}
+
+var slaTargets = map[string]slaColumns{
+	SLATargetFirstResponse: {due: "first_response_due_at", met: "first_responded_at", breachedAt: "response_breached_at"},
+	SLATargetResolution:    {due: "due_at", met: "resolved_at", breachedAt: "resolution_breached_at"},
+}
+
+// GormSLARepository persists SLA policies and breaches via GORM.
+type GormSLARepository struct {
+	db *gorm.DB
+}
+
+// NewSLARepository constructs an SLA repository backed by the provided DB connection.
+func NewSLARepository(db *gorm.DB) *GormSLARepository {
+	return &GormSLARepository{db: db}
+}
+
+func (r *GormSLARepository) scoped(ctx context.Context) *gorm.DB {
+	return database.Conn(ctx, r.db).Scopes(database.TenantScope(ctx))
+}
+
+// ListPolicies returns the policies of the current tenant ordered by priority.
+func (r *GormSLARepository) ListPolicies(ctx context.Context) ([]SLAPolicy, error) {
+	var policies []SLAPolicy
+	if err := r.scoped(ctx).Order("priority ASC").Order("created_at ASC").Find(&policies).Error; err != nil {
+		return nil, err
+	}
+	return policies, nil
+}
+
+// FindPolicy retrieves a policy by ID.
+func (r *GormSLARepository) FindPolicy(ctx context.Context, id string) (*SLAPolicy, error) {
+	var policy SLAPolicy
+	if err := r.scoped(ctx).First(&policy, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// SavePolicy creates or updates a policy, rejecting a second policy for the
+// same priority and form with ErrSLAPolicyConflict. Tickets keep the deadlines
+// they were given; changed targets apply to new tickets and priority changes.
+func (r *GormSLARepository) SavePolicy(ctx context.Context, policy *SLAPolicy) error {
+	return database.Conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		query := tx.Scopes(database.TenantScope(ctx)).Model(&SLAPolicy{}).Where("priority = ?", policy.Priority)
+		if policy.FormID == nil {
+			query = query.Where("form_id IS NULL")
+		} else {
+			query = query.Where("form_id = ?", *policy.FormID)
+		}
+		if policy.ID != "" {
+			query = query.Where("id <> ?", policy.ID)
+		}
+		var conflicts int64
+		if err := query.Count(&conflicts).Error; err != nil {
+			return err
+		}
+		if conflicts > 0 {
+			return ErrSLAPolicyConflict
+		}
+
+		if policy.ID == "" {
+			policy.TenantID = tenant.ID(ctx)
+			return tx.Create(policy).Error
+		}
+		return tx.Save(policy).Error
+	})
+}
+
+// DeletePolicy removes a policy.
+func (r *GormSLARepository) DeletePolicy(ctx context.Context, id string) error {
+	result := r.scoped(ctx).Delete(&SLAPolicy{}, "id = ?", id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// FindBreaches returns up to limit targets per kind that were missed by now
+// and not yet recorded: still unmet past their deadline, or met late.
+// Cancelled tickets are skipped. This deliberately spans all tenants.
+func (r *GormSLARepository) FindBreaches(ctx context.Context, now time.Time, limit int) ([]SLABreach, error) {
+	var breaches []SLABreach
+	for _, target := range []string{SLATargetFirstResponse, SLATargetResolution} {
+		columns := slaTargets[target]
+		var tickets []Ticket
+		err := database.Conn(ctx, r.db).
+			Where(columns.due+" IS NOT NULL AND "+columns.breachedAt+" IS NULL AND status <> ?", StatusCancelled).
+			Where("("+columns.met+" IS NULL AND "+columns.due+" <= ?) OR "+columns.met+" > "+columns.due, now).
+			Order(columns.due + " ASC").
+			Limit(limit).
+			Find(&tickets).Error
+		if err != nil {
+			return nil, err
+		}
+
+		for _, ticket := range tickets {
+			due := ticket.DueAt
+			if target == SLATargetFirstResponse {
+				due = ticket.FirstResponseDueAt
+			}
+			breaches = append(breaches, SLABreach{
+				TicketID: ticket.ID,
+				TenantID: ticket.TenantID,
+				Target:   target,
+				DueAt:    *due,
+			})
+		}
+	}
+	return breaches, nil
+}
+
+// MarkBreach records a breach and flags the ticket. It reports false when the
+// breach was already recorded, e.g. by a concurrent evaluator.
+func (r *GormSLARepository) MarkBreach(ctx context.Context, breach SLABreach) (bool, error) {
+	columns := slaTargets[breach.Target]
+	result := database.Conn(ctx, r.db).Model(&Ticket{}).
+		Where("id = ? AND "+columns.breachedAt+" IS NULL", breach.TicketID).
+		UpdateColumns(map[string]any{
+			columns.breachedAt: breach.DetectedAt,
+			"breached":         true,
+		})
+	return result.RowsAffected == 1, result.Error
+}
+
+// UnmarkBreach reverts MarkBreach so the breach is detected again later.
+func (r *GormSLARepository) UnmarkBreach(ctx context.Context, breach SLABreach) error {
+	columns := slaTargets[breach.Target]
+	other := slaTargets[SLATargetResolution]
+	if breach.Target == SLATargetResolution {
+		other = slaTargets[SLATargetFirstResponse]
+	}
+	return database.Conn(ctx, r.db).Model(&Ticket{}).
+		Where("id = ?", breach.TicketID).
+		UpdateColumns(map[string]any{
+			columns.breachedAt: nil,
+			"breached":         gorm.Expr(other.breachedAt + " IS NOT NULL"),
+		}).Error
+}
+
+// policyFor returns the policy applying to a ticket of the current tenant, or
+// nil when there is none.
+func policyFor(ctx context.Context, db *gorm.DB, priority, formID string) (*SLAPolicy, error) {
+	query := database.Conn(ctx, db).Scopes(database.TenantScope(ctx)).Where("priority = ?", priority)
+	if formID != "" {
+		query = query.Where("(form_id IS NULL OR form_id = ?)", formID)
+	} else {
+		query = query.Where("form_id IS NULL")
+	}
+	var candidates []SLAPolicy
+	if err := query.Find(&candidates).Error; err != nil {
+		return nil, err
+	}
+
+	var policy *SLAPolicy
+	for i := range candidates {
+		if policy == nil || candidates[i].FormID != nil {
+			policy = &candidates[i]
+		}
+	}
+	return policy, nil
+}
@@ -0,0 +1,278 @@
+package ticket
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/pflow/shared/mq"
+	"github.com/pflow/shared/tenant"
+)
+
+func TestBusinessCalendarAdd(t *testing.T) {
+	weekdays := []BusinessHours{
+		{Day: "monday", Start: "09:00", End: "17:00"},
+		{Day: "tuesday", Start: "09:00", End: "17:00"},
+		{Day: "wednesday", Start: "09:00", End: "17:00"},
+		{Day: "thursday", Start: "09:00", End: "12:00"},
+		{Day: "thursday", Start: "13:00", End: "17:00"},
+		{Day: "friday", Start: "09:00", End: "17:00"},
+	}
+	office := BusinessCalendar{Hours: weekdays, Holidays: []string{"2024-05-20"}}
+	utc := func(day, hour, minute int) time.Time { return time.Date(2024, 5, day, hour, minute, 0, 0, time.UTC) }
+
+	cases := []struct {
+		name     string
+		calendar BusinessCalendar
+		start    time.Time
+		add      time.Duration
+		want     time.Time
+	}{
+		{"around the clock", BusinessCalendar{}, utc(18, 23, 0), 2 * time.Hour, utc(19, 1, 0)},
+		{"around the clock over a holiday", BusinessCalendar{Holidays: []string{"2024-05-20"}}, utc(19, 23, 0), 2 * time.Hour, utc(21, 1, 0)},
+		{"around the clock from a holiday", BusinessCalendar{Holidays: []string{"2024-05-20"}}, utc(20, 10, 0), time.Hour, utc(21, 1, 0)},
+		{"within the day", office, utc(14, 10, 0), 3 * time.Hour, utc(14, 13, 0)},
+		{"before opening", office, utc(14, 6, 0), time.Hour, utc(14, 10, 0)},
+		{"over the lunch break", office, utc(16, 11, 0), 2 * time.Hour, utc(16, 14, 0)},
+		{"over the weekend and a holiday", office, utc(17, 16, 0), 2 * time.Hour, utc(21, 10, 0)},
+		{"from the weekend", office, utc(18, 12, 0), 30 * time.Minute, utc(21, 9, 30)},
+		{"exactly at closing", office, utc(14, 9, 0), 8 * time.Hour, utc(14, 17, 0)},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := tc.calendar.Add(tc.start, tc.add)
+			if err != nil {
+				t.Fatalf("add: %v", err)
+			}
+			if !got.Equal(tc.want) {
+				t.Fatalf("expected %s, got %s", tc.want, got.UTC())
+			}
+		})
+	}
+
+	berlin := BusinessCalendar{Timezone: "Europe/Berlin", Hours: []BusinessHours{{Day: "monday", Start: "09:00", End: "17:00"}}}
+	got, err := berlin.Add(utc(13, 6, 0), time.Hour)
+	if err != nil {
+		t.Fatalf("add in time zone: %v", err)
+	}
+	if want := utc(13, 8, 0); !got.Equal(want) {
+		t.Fatalf("expected hours in Berlin time, got %s", got.UTC())
+	}
+}
+
+func TestBusinessCalendarValidate(t *testing.T) {
+	for _, calendar := range []BusinessCalendar{
+		{Timezone: "Mars/Olympus"},
+		{Hours: []BusinessHours{{Day: "someday", Start: "09:00", End: "17:00"}}},
+		{Hours: []BusinessHours{{Day: "monday", Start: "17:00", End: "09:00"}}},
+		{Hours: []BusinessHours{{Day: "monday", Start: "09:00", End: "24:30"}}},
+		{Holidays: []string{"next friday"}},
+	} {
+		if err := calendar.Validate(); err == nil {
+			t.Errorf("expected %+v to be rejected", calendar)
+		}
+	}
+}
+
+type recordedEvent struct {
+	mq.Event
+	Breach SLABreachEvent
+}
+
+type recordingPublisher struct {
+	events []recordedEvent
+	err    error
+}
+
+func (p *recordingPublisher) Publish(ctx context.Context, key string, value []byte, headers map[string]string) error {
+	if p.err != nil {
+		return p.err
+	}
+	var event recordedEvent
+	if err := json.Unmarshal(value, &event.Event); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(event.Data, &event.Breach); err != nil {
+		return err
+	}
+	p.events = append(p.events, event)
+	return nil
+}
+
+func TestSLADeadlinesAndBreaches(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewGormRepository(db)
+	slas := NewSLARepository(db)
+	ctx := tenant.WithID(context.Background(), "acme")
+	formID := "3f1c2a4e-8b7d-4c6e-9a5f-1e2d3c4b5a69"
+
+	for _, policy := range []*SLAPolicy{
+		{Name: "High", Priority: "high", FirstResponseMinutes: 60, ResolutionMinutes: 240},
+		{Name: "High on the incident form", Priority: "high", FormID: &formID, FirstResponseMinutes: 15, ResolutionMinutes: 120},
+	} {
+		if err := slas.SavePolicy(ctx, policy); err != nil {
+			t.Fatalf("save policy: %v", err)
+		}
+	}
+	if err := slas.SavePolicy(ctx, &SLAPolicy{Priority: "high", ResolutionMinutes: 10}); !errors.Is(err, ErrSLAPolicyConflict) {
+		t.Fatalf("expected a second policy for the same priority to conflict, got %v", err)
+	}
+	if err := slas.SavePolicy(tenant.WithID(context.Background(), "globex"), &SLAPolicy{Priority: "high", ResolutionMinutes: 10}); err != nil {
+		t.Fatalf("expected another tenant to define its own policy: %v", err)
+	}
+
+	created := time.Now().Add(-3 * time.Hour).Truncate(time.Second)
+	late := &Ticket{Title: "Outage", Status: StatusOpen, Priority: "high", FormID: formID, CreatedAt: created}
+	if err := repo.Create(ctx, late); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if late.FirstResponseDueAt == nil || !late.FirstResponseDueAt.Equal(created.Add(15*time.Minute)) {
+		t.Fatalf("expected the form policy's response target, got %v", late.FirstResponseDueAt)
+	}
+	if late.DueAt == nil || !late.DueAt.Equal(created.Add(2*time.Hour)) {
+		t.Fatalf("expected the form policy's resolution target, got %v", late.DueAt)
+	}
+
+	answered := &Ticket{Title: "Slow laptop", Status: StatusOpen, Priority: "high", FormID: "9d1f7f0e-5c1a-4f4e-8b51-2f0b7e3d6c21", CreatedAt: time.Now()}
+	if err := repo.Create(ctx, answered); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if answered.DueAt == nil || !answered.DueAt.Equal(answered.CreatedAt.Add(4*time.Hour)) {
+		t.Fatalf("expected the generic policy's resolution target, got %v", answered.DueAt)
+	}
+	if err := repo.CreateComment(ctx, &TicketComment{TicketID: answered.ID, Body: "Looking into it"}); err != nil {
+		t.Fatalf("comment: %v", err)
+	}
+
+	unmatched := &Ticket{Title: "Question", Status: StatusOpen, Priority: "low", FormID: formID}
+	if err := repo.Create(ctx, unmatched); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if unmatched.DueAt != nil || unmatched.SLAPolicyID != nil {
+		t.Fatal("expected a ticket without a matching policy to have no deadlines")
+	}
+
+	failing := &recordingPublisher{err: errors.New("broker down")}
+	if recorded, err := NewSLAEvaluator(slas, failing, SLAEvaluatorConfig{}).EvaluateOnce(context.Background()); err != nil || recorded != 0 {
+		t.Fatalf("expected nothing to be recorded while publishing fails, got %d (%v)", recorded, err)
+	}
+
+	publisher := &recordingPublisher{}
+	evaluator := NewSLAEvaluator(slas, publisher, SLAEvaluatorConfig{})
+	if recorded, err := evaluator.EvaluateOnce(context.Background()); err != nil || recorded != 2 {
+		t.Fatalf("expected both targets of the late ticket to breach, got %d (%v)", recorded, err)
+	}
+	if recorded, err := evaluator.EvaluateOnce(context.Background()); err != nil || recorded != 0 {
+		t.Fatalf("expected breaches to be recorded once, got %d (%v)", recorded, err)
+	}
+	targets := map[string]bool{}
+	for _, event := range publisher.events {
+		if event.Type != EventSLABreached || event.Subject != late.ID || event.TenantID != "acme" || event.Breach.TicketID != late.ID {
+			t.Fatalf("unexpected event %+v", event)
+		}
+		targets[event.Breach.Target] = true
+	}
+	if !targets[SLATargetFirstResponse] || !targets[SLATargetResolution] {
+		t.Fatalf("expected response and resolution breaches, got %+v", publisher.events)
+	}
+
+	stored, err := repo.Find(ctx, late.ID)
+	if err != nil {
+		t.Fatalf("find: %v", err)
+	}
+	if !stored.Breached || stored.ResponseBreachedAt == nil || stored.ResolutionBreachedAt == nil {
+		t.Fatalf("expected the late ticket to be flagged, got %+v", stored)
+	}
+	if stored, _ := repo.Find(ctx, answered.ID); stored.Breached || stored.FirstRespondedAt == nil {
+		t.Fatalf("expected the answered ticket to be on track, got %+v", stored)
+	}
+
+	// Lowering the priority re-baselines the ticket against the new policy.
+	updated, err := repo.Update(ctx, late.ID, map[string]any{"priority": "low"})
+	if err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if updated.Breached || updated.DueAt != nil || updated.SLAPolicyID != nil {
+		t.Fatalf("expected the priority change to clear the SLA, got %+v", updated)
+	}
+}
+
+func TestTicketListFiltersOnSLA(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewGormRepository(db)
+	slas := NewSLARepository(db)
+	router := chi.NewRouter()
+	NewHandler(repo, WithSLAPolicies(slas)).Mount(router, "")
+
+	serve := func(method, target string, body any) *httptest.ResponseRecorder {
+		var payload bytes.Buffer
+		if body != nil {
+			json.NewEncoder(&payload).Encode(body)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(method, target, &payload))
+		return rec
+	}
+
+	rec := serve(http.MethodPost, "/tickets/sla-policies", map[string]any{
+		"priority":          "urgent",
+		"resolutionMinutes": 60,
+		"calendar":          BusinessCalendar{Hours: []BusinessHours{{Day: "monday", Start: "9:00", End: "17:00"}}},
+	})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create policy: %d %s", rec.Code, rec.Body)
+	}
+	if rec := serve(http.MethodPost, "/tickets/sla-policies", map[string]any{"priority": "urgent", "resolutionMinutes": 30}); rec.Code != http.StatusConflict {
+		t.Fatalf("expected duplicate policy to conflict, got %d", rec.Code)
+	}
+	if rec := serve(http.MethodPost, "/tickets/sla-policies", map[string]any{"priority": "low"}); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected policy without targets to be rejected, got %d", rec.Code)
+	}
+
+	ctx := tenant.WithID(context.Background(), tenant.Default)
+	formID := "3f1c2a4e-8b7d-4c6e-9a5f-1e2d3c4b5a69"
+	soon := &Ticket{Title: "Due soon", Status: StatusOpen, Priority: "urgent", FormID: formID}
+	later := &Ticket{Title: "Due later", Status: StatusOpen, Priority: "medium", FormID: formID}
+	for _, entity := range []*Ticket{soon, later} {
+		if err := repo.Create(ctx, entity); err != nil {
+			t.Fatalf("create: %v", err)
+		}
+	}
+	due := time.Now().Add(-time.Hour)
+	breachedAt := time.Now()
+	if err := db.Model(&Ticket{}).Where("id = ?", soon.ID).Updates(map[string]any{"due_at": due, "breached": true, "resolution_breached_at": breachedAt}).Error; err != nil {
+		t.Fatalf("flag breach: %v", err)
+	}
+
+	list := func(target string) []map[string]any {
+		rec := serve(http.MethodGet, target, nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("list %s: %d %s", target, rec.Code, rec.Body)
+		}
+		var payload struct {
+			Data []map[string]any `json:"data"`
+		}
+		json.Unmarshal(rec.Body.Bytes(), &payload)
+		return payload.Data
+	}
+
+	if items := list("/tickets?breached=true"); len(items) != 1 || items[0]["id"] != soon.ID || items[0]["breached"] != true || items[0]["dueAt"] == nil {
+		t.Fatalf("expected only the breached ticket, got %v", items)
+	}
+	if items := list("/tickets?breached=false"); len(items) != 1 || items[0]["id"] != later.ID {
+		t.Fatalf("expected only the ticket on track, got %v", items)
+	}
+	if items := list("/tickets?dueBefore=" + time.Now().UTC().Format(time.RFC3339)); len(items) != 1 || items[0]["id"] != soon.ID {
+		t.Fatalf("expected tickets without a deadline to be left out, got %v", items)
+	}
+	if rec := serve(http.MethodGet, "/tickets?dueBefore=soon", nil); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected an invalid dueBefore to be rejected, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,192 @@
+package ticket
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/pflow/components/outbox"
+	"github.com/pflow/shared/audit"
+	"github.com/pflow/shared/httpx"
+	"github.com/pflow/shared/tenant"
+)
+
+// SubmissionAttachment is a file uploaded with a form submission before the
+// worker has created its ticket. The worker turns it into a TicketAttachment of
+// that ticket, sharing the blob, in the transaction that completes the
+// submission.
+type SubmissionAttachment struct {
+	ID           string    `json:"id" gorm:"type:uuid;primaryKey"`
+	TenantID     string    `json:"tenantId" gorm:"type:varchar(64);not null;default:default;index"`
+	SubmissionID string    `json:"submissionId" gorm:"type:uuid;not null;index"`
+	FileName     string    `json:"fileName" gorm:"not null"`
+	ContentType  string    `json:"contentType" gorm:"not null"`
+	Size         int64     `json:"size" gorm:"not null"`
+	SHA256       string    `json:"sha256" gorm:"type:char(64);not null"`
+	BlobKey      string    `json:"-" gorm:"not null;index"`
+	UploadedBy   string    `json:"uploadedBy"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// BeforeCreate assigns a UUID when missing.
+func (a *SubmissionAttachment) BeforeCreate(tx *gorm.DB) error {
+	if a.ID == "" {
+		a.ID = uuid.NewString()
+	}
+	return nil
+}
+
+// ToDTO exposes the staged attachment metadata for clients.
+func (a SubmissionAttachment) ToDTO() map[string]any {
+	dto := map[string]any{
+		"id":           a.ID,
+		"submissionId": a.SubmissionID,
+		"fileName":     a.FileName,
+		"contentType":  a.ContentType,
+		"size":         a.Size,
+		"sha256":       a.SHA256,
+		"createdAt":    a.CreatedAt,
+	}
+	if a.UploadedBy != "" {
+		dto["uploadedBy"] = a.UploadedBy
+	}
+	return dto
+}
+
+// ticketAttachment is the attachment the staged file becomes on the ticket.
+func (a SubmissionAttachment) ticketAttachment(ticketID string) *TicketAttachment {
+	return &TicketAttachment{
+		TicketID:    ticketID,
+		FileName:    a.FileName,
+		ContentType: a.ContentType,
+		Size:        a.Size,
+		SHA256:      a.SHA256,
+		BlobKey:     a.BlobKey,
+		UploadedBy:  a.UploadedBy,
+		CreatedAt:   a.CreatedAt,
+	}
+}
+
+// listSubmissionAttachments lists the files staged for a submission. Once the
+// ticket exists they are listed under the ticket instead.
+func (h *Handler) listSubmissionAttachments(w http.ResponseWriter, r *http.Request) {
+	attachments, err := h.attachments.ListSubmissionAttachments(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		renderSubmissionAttachmentError(w, err)
+		return
+	}
+
+	items := make([]map[string]any, 0, len(attachments))
+	for _, attachment := range attachments {
+		items = append(items, attachment.ToDTO())
+	}
+
+	httpx.JSON(w, http.StatusOK, map[string]any{"data": items})
+}
+
+// uploadSubmissionAttachment stages a file for a submission until the worker
+// creates its ticket, or attaches it to the ticket when the submission has
+// already completed. Staging locks the submission, so the worker either sees
+// the staged file or the upload sees the completed submission.
+func (h *Handler) uploadSubmissionAttachment(w http.ResponseWriter, r *http.Request) {
+	submissionID := chi.URLParam(r, "id")
+	upload := h.receiveUpload(w, r)
+	if upload == nil {
+		return
+	}
+	defer upload.Close()
+
+	ctx := r.Context()
+	key := attachmentBlobKey(tenant.ID(ctx), upload.sum)
+	attachment := &SubmissionAttachment{
+		SubmissionID: submissionID,
+		FileName:     upload.fileName,
+		ContentType:  upload.contentType,
+		Size:         upload.size,
+		SHA256:       upload.sum,
+		BlobKey:      key,
+		UploadedBy:   requestActor(r),
+	}
+
+	var existing *SubmissionAttachment
+	var ticketID string
+	stored := false
+	err := h.attachments.Transaction(ctx, func(ctx context.Context) error {
+		submission, err := h.attachments.LockSubmission(ctx, submissionID)
+		if err != nil {
+			return err
+		}
+		if submission.Status == SubmissionCompleted && submission.TicketID != nil {
+			ticketID = *submission.TicketID
+			return nil
+		}
+
+		if err := h.attachments.LockBlob(ctx, key); err != nil {
+			return err
+		}
+		found, err := h.attachments.FindSubmissionAttachmentByHash(ctx, submissionID, upload.sum)
+		switch {
+		case err == nil:
+			existing = found
+			return nil
+		case !IsNotFound(err):
+			return err
+		}
+
+		if err := h.putBlob(ctx, key, upload); err != nil {
+			return err
+		}
+		stored = true
+		return h.attachments.StageAttachment(ctx, attachment)
+	})
+	switch {
+	case err != nil:
+		if stored {
+			releaseBlobs(ctx, h.attachments, h.blobs, key)
+		}
+		renderStoreError(w, err, renderSubmissionAttachmentError)
+		return
+	case ticketID != "":
+		// Completed submissions are final, so the ticket no longer changes.
+		h.storeAttachment(w, r, ticketID, upload)
+		return
+	case existing != nil:
+		httpx.JSON(w, http.StatusOK, map[string]any{"data": existing.ToDTO()})
+		return
+	}
+	h.audit.Record(ctx, audit.Change{Action: auditSubmissionAttachmentStaged, ResourceID: submissionID, After: attachment.ToDTO()})
+
+	httpx.JSON(w, http.StatusCreated, map[string]any{"data": attachment.ToDTO()})
+}
+
+// attachSubmission moves the files staged for a submission to the ticket the
+// worker created from it and announces each one. It runs in the worker's
+// transaction after the submission is marked completed.
+func attachSubmission(ctx context.Context, attachments AttachmentRepository, events *outbox.EventStore, submissionID, ticketID string) error {
+	if attachments == nil {
+		return nil
+	}
+	attached, err := attachments.AttachSubmission(ctx, submissionID, ticketID)
+	if err != nil {
+		return err
+	}
+	for _, attachment := range attached {
+		if err := events.Emit(ctx, EventTicketAttachmentAdded, ticketID, attachment.ToDTO()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func renderSubmissionAttachmentError(w http.ResponseWriter, err error) {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		httpx.Error(w, http.StatusNotFound, "submission not found")
+		return
+	}
+	httpx.Error(w, http.StatusInternalServerError, err.Error())
+}
@@ -7,6 +7,11 @@ import (
 	"time"
 
 	"gorm.io/gorm"
+
+	"github.com/pflow/components/outbox"
+	"github.com/pflow/shared/database"
+	"github.com/pflow/shared/httpx"
+	"github.com/pflow/shared/tenant"
 )
 
 // SubmissionMetrics exposes aggregated queue insights.
@@ -22,12 +27,20 @@ type SubmissionMetrics struct {
 type SubmissionStore interface {
 	Create(ctx context.Context, submission *TicketSubmission) error
 	Save(ctx context.Context, submission *TicketSubmission) error
+	CreateWithOutbox(ctx context.Context, submission *TicketSubmission, build func(*TicketSubmission) (*outbox.Message, error)) error
+	SaveWithOutbox(ctx context.Context, submission *TicketSubmission, build func(*TicketSubmission) (*outbox.Message, error)) error
+	SaveIfUnchanged(ctx context.Context, submission *TicketSubmission, status string, updatedAt time.Time, build func(*TicketSubmission) (*outbox.Message, error)) error
+	SaveUnlessCompleted(ctx context.Context, submission *TicketSubmission) error
 	FindByID(ctx context.Context, id string) (*TicketSubmission, error)
 	FindByClientReference(ctx context.Context, ref string) (*TicketSubmission, error)
+	List(ctx context.Context, query httpx.ListQuery) ([]TicketSubmission, httpx.Page, error)
+	FindStale(ctx context.Context, status string, updatedBefore time.Time, limit int) ([]TicketSubmission, error)
 	Metrics(ctx context.Context) (SubmissionMetrics, error)
 }
 
-// GormSubmissionRepository persists submissions via GORM.
+// GormSubmissionRepository persists submissions via GORM. Lookups are scoped to
+// the tenant of the request context; FindStale serves the reaper and spans all
+// tenants.
 type GormSubmissionRepository struct {
 	db *gorm.DB
 }
@@ -37,20 +50,104 @@ func NewSubmissionRepository(db *gorm.DB) *GormSubmissionRepository {
 	return &GormSubmissionRepository{db: db}
 }
 
+func (r *GormSubmissionRepository) scoped(ctx context.Context) *gorm.DB {
+	return database.Conn(ctx, r.db).Scopes(database.TenantScope(ctx))
+}
+
 // Create inserts a new submission.
 func (r *GormSubmissionRepository) Create(ctx context.Context, submission *TicketSubmission) error {
-	return r.db.WithContext(ctx).Create(submission).Error
+	submission.TenantID = tenant.ID(ctx)
+	return database.Conn(ctx, r.db).Create(submission).Error
 }
 
 // Save persists changes to a submission.
 func (r *GormSubmissionRepository) Save(ctx context.Context, submission *TicketSubmission) error {
-	return r.db.WithContext(ctx).Save(submission).Error
+	return database.Conn(ctx, r.db).Save(submission).Error
+}
+
+// CreateWithOutbox inserts a submission and the outbox message built for it in one transaction.
+func (r *GormSubmissionRepository) CreateWithOutbox(ctx context.Context, submission *TicketSubmission, build func(*TicketSubmission) (*outbox.Message, error)) error {
+	submission.TenantID = tenant.ID(ctx)
+	return database.Conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(submission).Error; err != nil {
+			return err
+		}
+		return createOutboxMessage(tx, submission, build)
+	})
+}
+
+// SaveWithOutbox persists submission changes and the outbox message built for them in one transaction.
+func (r *GormSubmissionRepository) SaveWithOutbox(ctx context.Context, submission *TicketSubmission, build func(*TicketSubmission) (*outbox.Message, error)) error {
+	return database.Conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(submission).Error; err != nil {
+			return err
+		}
+		return createOutboxMessage(tx, submission, build)
+	})
+}
+
+// reapedColumns are the submission columns a requeue or a reaper failure writes.
+var reapedColumns = []string{"status", "error_message", "ticket_id", "completed_at", "requeue_count", "updated_at"}
+
+// SaveIfUnchanged writes the reaped columns of a submission only while its row
+// is still in status and was last updated at updatedAt, and adds the outbox
+// message built for it when build is set. A row a worker moved on in the
+// meantime is left alone and ErrSubmissionChanged is returned.
+func (r *GormSubmissionRepository) SaveIfUnchanged(ctx context.Context, submission *TicketSubmission, status string, updatedAt time.Time, build func(*TicketSubmission) (*outbox.Message, error)) error {
+	return database.Conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		submission.UpdatedAt = time.Now()
+		result := tx.Model(&TicketSubmission{}).
+			Select(reapedColumns).
+			Where("id = ? AND status = ? AND updated_at = ?", submission.ID, status, updatedAt).
+			Updates(submission)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected != 1 {
+			return ErrSubmissionChanged
+		}
+		if build == nil {
+			return nil
+		}
+		return createOutboxMessage(tx, submission, build)
+	})
+}
+
+// processedColumns are the submission columns the worker writes.
+var processedColumns = []string{"status", "error_message", "ticket_id", "completed_at", "updated_at"}
+
+// SaveUnlessCompleted writes the processed columns of a submission unless its
+// row is already completed, in which case it returns ErrSubmissionCompleted.
+// Workers handling the same submission twice, after a redelivery, a requeue or
+// a replay, thereby neither complete it twice nor overwrite the result of the
+// worker that completed it.
+func (r *GormSubmissionRepository) SaveUnlessCompleted(ctx context.Context, submission *TicketSubmission) error {
+	submission.UpdatedAt = time.Now()
+	result := database.Conn(ctx, r.db).Model(&TicketSubmission{}).
+		Select(processedColumns).
+		Where("id = ? AND status <> ?", submission.ID, SubmissionCompleted).
+		Updates(submission)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected != 1 {
+		return ErrSubmissionCompleted
+	}
+	return nil
+}
+
+func createOutboxMessage(tx *gorm.DB, submission *TicketSubmission, build func(*TicketSubmission) (*outbox.Message, error)) error {
+	message, err := build(submission)
+	if err != nil {
+		return err
+	}
+	return tx.Create(message).Error
 }
 
 // FindByID locates a submission by primary key.
 func (r *GormSubmissionRepository) FindByID(ctx context.Context, id string) (*TicketSubmission, error) {
 	var entity TicketSubmission
-	if err := r.db.WithContext(ctx).First(&entity, "id = ?", id).Error; err != nil {
+	if err := r.scoped(ctx).First(&entity, "id = ?", id).Error; err != nil {
 		return nil, err
 	}
 	return &entity, nil
@@ -64,13 +161,36 @@ func (r *GormSubmissionRepository) FindByClientReference(ctx context.Context, re
 	}
 
 	var entity TicketSubmission
-	if err := r.db.WithContext(ctx).First(&entity, "client_reference = ?", ref).Error; err != nil {
+	if err := r.scoped(ctx).First(&entity, "client_reference = ?", ref).Error; err != nil {
 		return nil, err
 	}
 	return &entity, nil
 }
 
-// Metrics aggregates queue counts and wait times.
+// List returns a page of submissions matching the query filters.
+func (r *GormSubmissionRepository) List(ctx context.Context, query httpx.ListQuery) ([]TicketSubmission, httpx.Page, error) {
+	return database.Paginate(r.scoped(ctx).Model(&TicketSubmission{}), query, TicketSubmission.sortValue)
+}
+
+// FindStale returns submissions stuck in status since before updatedBefore. Submissions
+// that still have an undelivered outbox message are skipped, as the relay owns them.
+func (r *GormSubmissionRepository) FindStale(ctx context.Context, status string, updatedBefore time.Time, limit int) ([]TicketSubmission, error) {
+	var submissions []TicketSubmission
+	err := database.Conn(ctx, r.db).
+		Where("status = ? AND updated_at < ?", status, updatedBefore).
+		Where("NOT EXISTS (?)", r.db.Model(&outbox.Message{}).
+			Select("1").
+			Where("outbox_messages.aggregate_id = ticket_submissions.id AND outbox_messages.topic = ? AND outbox_messages.status = ?", OutboxSubmissions, outbox.Pending)).
+		Order("updated_at ASC").
+		Limit(limit).
+		Find(&submissions).Error
+	if err != nil {
+		return nil, err
+	}
+	return submissions, nil
+}
+
+// Metrics aggregates queue counts and wait times of the current tenant.
 func (r *GormSubmissionRepository) Metrics(ctx context.Context) (SubmissionMetrics, error) {
 	metrics := SubmissionMetrics{}
 
@@ -80,7 +200,7 @@ func (r *GormSubmissionRepository) Metrics(ctx context.Context) (SubmissionMetri
 	}
 
 	var rows []result
-	if err := r.db.WithContext(ctx).
+	if err := r.scoped(ctx).
 		Model(&TicketSubmission{}).
 		Select("status, COUNT(*) as total").
 		Group("status").
@@ -102,7 +222,7 @@ func (r *GormSubmissionRepository) Metrics(ctx context.Context) (SubmissionMetri
 	}
 
 	var oldest TicketSubmission
-	err := r.db.WithContext(ctx).
+	err := r.scoped(ctx).
 		Model(&TicketSubmission{}).
 		Where("status IN ?", []string{SubmissionPending, SubmissionProcessing}).
 		Order("created_at ASC").
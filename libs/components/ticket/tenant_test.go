@@ -0,0 +1,98 @@
+package ticket
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"gorm.io/gorm"
+
+	"github.com/pflow/components/internal/dbtest"
+	"github.com/pflow/shared/httpx"
+	"github.com/pflow/shared/tenant"
+)
+
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	return dbtest.Open(t, Migrate)
+}
+
+func TestTicketsAreInvisibleToOtherTenants(t *testing.T) {
+	repo := NewGormRepository(newTestDB(t))
+	acme := tenant.WithID(context.Background(), "acme")
+	globex := tenant.WithID(context.Background(), "globex")
+
+	ticket := &Ticket{Title: "Printer jammed", Status: "open", Priority: "medium"}
+	if err := repo.Create(acme, ticket); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if ticket.TenantID != "acme" {
+		t.Fatalf("expected ticket to belong to acme, got %q", ticket.TenantID)
+	}
+
+	if _, err := repo.Find(globex, ticket.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("expected find from another tenant to miss, got %v", err)
+	}
+	if _, err := repo.Update(globex, ticket.ID, map[string]any{"title": "hijacked"}); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("expected update from another tenant to miss, got %v", err)
+	}
+	if _, err := repo.Transition(globex, ticket.ID, TransitionRequest{To: "in_progress"}, DefaultTransitionRules()); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("expected transition from another tenant to miss, got %v", err)
+	}
+	if _, err := repo.History(globex, ticket.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("expected history from another tenant to miss, got %v", err)
+	}
+	if err := repo.Delete(globex, ticket.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("expected delete from another tenant to miss, got %v", err)
+	}
+
+	query := httpx.ListQuery{Limit: 10, SortColumn: "created_at"}
+	items, page, err := repo.List(globex, query)
+	if err != nil || len(items) != 0 || page.Total != 0 {
+		t.Fatalf("expected other tenant to list nothing, got %d items (total %d, err %v)", len(items), page.Total, err)
+	}
+
+	stored, err := repo.Find(acme, ticket.ID)
+	if err != nil {
+		t.Fatalf("find in owning tenant: %v", err)
+	}
+	if stored.Title != "Printer jammed" {
+		t.Fatalf("expected ticket to be untouched, got title %q", stored.Title)
+	}
+}
+
+func TestClientReferencesAreUniquePerTenant(t *testing.T) {
+	store := NewSubmissionRepository(newTestDB(t))
+	acme := tenant.WithID(context.Background(), "acme")
+	globex := tenant.WithID(context.Background(), "globex")
+
+	if err := store.Create(acme, &TicketSubmission{ClientReference: "req-1"}); err != nil {
+		t.Fatalf("create acme submission: %v", err)
+	}
+	if err := store.Create(globex, &TicketSubmission{ClientReference: "req-1"}); err != nil {
+		t.Fatalf("expected the same reference to be accepted in another tenant: %v", err)
+	}
+	if err := store.Create(acme, &TicketSubmission{ClientReference: "req-1"}); err == nil {
+		t.Fatal("expected a duplicate reference within a tenant to be rejected")
+	}
+
+	found, err := store.FindByClientReference(globex, "req-1")
+	if err != nil {
+		t.Fatalf("find by reference: %v", err)
+	}
+	if found.TenantID != "globex" {
+		t.Fatalf("expected globex submission, got tenant %q", found.TenantID)
+	}
+	if _, err := store.FindByID(globex, mustFindReference(t, store, acme, "req-1").ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("expected submission of another tenant to miss, got %v", err)
+	}
+}
+
+func mustFindReference(t *testing.T, store *GormSubmissionRepository, ctx context.Context, ref string) *TicketSubmission {
+	t.Helper()
+	submission, err := store.FindByClientReference(ctx, ref)
+	if err != nil {
+		t.Fatalf("find %s: %v", ref, err)
+	}
+	return submission
+}
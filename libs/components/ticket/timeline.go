@@ -0,0 +1,132 @@
+package ticket
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/pflow/shared/auth"
+	"github.com/pflow/shared/httpx"
+)
+
+// Timeline entry types.
+const (
+	TimelineComment    = "comment"
+	TimelineStatus     = "status"
+	TimelineAssignment = "assignment"
+)
+
+// AssignmentRequest describes a requested change of assignee. An empty
+// AssigneeID unassigns the ticket.
+type AssignmentRequest struct {
+	AssigneeID string
+	Actor      string
+}
+
+// TicketAssignment records a single change of a ticket's assignee.
+type TicketAssignment struct {
+	ID           string    `json:"id" gorm:"type:uuid;primaryKey"`
+	TicketID     string    `json:"ticketId" gorm:"type:uuid;not null;index"`
+	FromAssignee string    `json:"from"`
+	ToAssignee   string    `json:"to"`
+	Actor        string    `json:"actor"`
+	CreatedAt    time.Time `json:"createdAt" gorm:"index"`
+}
+
+// BeforeCreate assigns a UUID when missing.
+func (a *TicketAssignment) BeforeCreate(tx *gorm.DB) error {
+	if a.ID == "" {
+		a.ID = uuid.NewString()
+	}
+	return nil
+}
+
+// ToDTO exposes the assignment change for clients.
+func (a TicketAssignment) ToDTO() map[string]any {
+	dto := map[string]any{
+		"id":        a.ID,
+		"ticketId":  a.TicketID,
+		"from":      a.FromAssignee,
+		"to":        a.ToAssignee,
+		"createdAt": a.CreatedAt,
+	}
+	if a.Actor != "" {
+		dto["actor"] = a.Actor
+	}
+	return dto
+}
+
+// TimelineEntry is one event in the activity of a ticket.
+type TimelineEntry struct {
+	Type  string
+	At    time.Time
+	Actor string
+	Data  map[string]any
+}
+
+// ToDTO exposes the entry for clients.
+func (e TimelineEntry) ToDTO() map[string]any {
+	dto := map[string]any{
+		"type": e.Type,
+		"at":   e.At,
+		"data": e.Data,
+	}
+	if e.Actor != "" {
+		dto["actor"] = e.Actor
+	}
+	return dto
+}
+
+// BuildTimeline interleaves comments, status changes and assignment changes
+// chronologically, oldest first. Events recorded at the same instant keep the
+// order status, assignment, comment.
+func BuildTimeline(comments []TicketComment, transitions []TicketTransition, assignments []TicketAssignment) []TimelineEntry {
+	entries := make([]TimelineEntry, 0, len(comments)+len(transitions)+len(assignments))
+	for _, transition := range transitions {
+		entries = append(entries, TimelineEntry{Type: TimelineStatus, At: transition.CreatedAt, Actor: transition.Actor, Data: transition.ToDTO()})
+	}
+	for _, assignment := range assignments {
+		entries = append(entries, TimelineEntry{Type: TimelineAssignment, At: assignment.CreatedAt, Actor: assignment.Actor, Data: assignment.ToDTO()})
+	}
+	for _, comment := range comments {
+		entries = append(entries, TimelineEntry{Type: TimelineComment, At: comment.CreatedAt, Actor: comment.AuthorID, Data: comment.ToDTO()})
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].At.Before(entries[j].At)
+	})
+	return entries
+}
+
+func (h *Handler) ticketTimeline(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	transitions, err := h.repo.History(r.Context(), id)
+	if err != nil {
+		h.renderTicketError(w, err)
+		return
+	}
+	assignments, err := h.repo.Assignments(r.Context(), id)
+	if err != nil {
+		h.renderTicketError(w, err)
+		return
+	}
+	var comments []TicketComment
+	if h.comments != nil {
+		comments, err = h.comments.ListComments(r.Context(), id, h.authz.Allows(r, auth.PermissionTicketInternal))
+		if err != nil {
+			h.renderTicketError(w, err)
+			return
+		}
+	}
+
+	entries := BuildTimeline(comments, transitions, assignments)
+	items := make([]map[string]any, 0, len(entries))
+	for _, entry := range entries {
+		items = append(items, entry.ToDTO())
+	}
+
+	httpx.JSON(w, http.StatusOK, map[string]any{"data": items})
+}
@@ -0,0 +1,130 @@
+package ticket
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ErrInvalidTransition is returned when a status change is not permitted by the transition rules.
+var ErrInvalidTransition = errors.New("ticket status transition not allowed")
+
+// TransitionRules declares, for each status, the statuses a ticket may move to next.
+type TransitionRules map[string][]string
+
+// DefaultTransitionRules returns the transition table used when a deployment does not override it.
+func DefaultTransitionRules() TransitionRules {
+	return TransitionRules{
+		StatusOpen:       {StatusInProgress, StatusResolved, StatusCancelled},
+		StatusInProgress: {StatusOpen, StatusResolved, StatusCancelled},
+		StatusResolved:   {StatusInProgress},
+		StatusCancelled:  {},
+	}
+}
+
+// Allows reports whether a ticket in status from may move to status to.
+func (rules TransitionRules) Allows(from, to string) bool {
+	for _, candidate := range rules[from] {
+		if candidate == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Targets lists the statuses reachable from the provided status.
+func (rules TransitionRules) Targets(from string) []string {
+	targets := append([]string(nil), rules[from]...)
+	sort.Strings(targets)
+	return targets
+}
+
+// ParseTransitionRules parses a specification such as
+// "open=in_progress|cancelled;in_progress=resolved;resolved=;cancelled=".
+// Every status referenced must be a known ticket status.
+func ParseTransitionRules(spec string) (TransitionRules, error) {
+	rules := TransitionRules{}
+	for _, clause := range strings.Split(spec, ";") {
+		clause = strings.TrimSpace(clause)
+		if clause == "" {
+			continue
+		}
+
+		parts := strings.SplitN(clause, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid transition clause %q", clause)
+		}
+
+		from := strings.ToLower(strings.TrimSpace(parts[0]))
+		if !isValidStatus(from) {
+			return nil, fmt.Errorf("unknown status %q in transition rules", from)
+		}
+
+		targets := make([]string, 0)
+		for _, raw := range strings.Split(parts[1], "|") {
+			to := strings.ToLower(strings.TrimSpace(raw))
+			if to == "" {
+				continue
+			}
+			if !isValidStatus(to) {
+				return nil, fmt.Errorf("unknown status %q in transition rules", to)
+			}
+			targets = append(targets, to)
+		}
+		rules[from] = targets
+	}
+
+	if len(rules) == 0 {
+		return nil, errors.New("transition rules are empty")
+	}
+	return rules, nil
+}
+
+// TransitionRequest describes a requested status change.
+type TransitionRequest struct {
+	To     string
+	Actor  string
+	Reason string
+}
+
+// TicketTransition records a single status change of a ticket.
+type TicketTransition struct {
+	ID         string    `json:"id" gorm:"type:uuid;primaryKey"`
+	TicketID   string    `json:"ticketId" gorm:"type:uuid;not null;index"`
+	FromStatus string    `json:"from" gorm:"not null"`
+	ToStatus   string    `json:"to" gorm:"not null"`
+	Actor      string    `json:"actor"`
+	Reason     string    `json:"reason"`
+	CreatedAt  time.Time `json:"createdAt" gorm:"index"`
+}
+
+// BeforeCreate assigns a UUID when missing.
+func (t *TicketTransition) BeforeCreate(tx *gorm.DB) error {
+	if t.ID == "" {
+		t.ID = uuid.NewString()
+	}
+	return nil
+}
+
+// ToDTO exposes the transition for clients.
+func (t TicketTransition) ToDTO() map[string]any {
+	dto := map[string]any{
+		"id":        t.ID,
+		"ticketId":  t.TicketID,
+		"from":      t.FromStatus,
+		"to":        t.ToStatus,
+		"createdAt": t.CreatedAt,
+	}
+	if t.Actor != "" {
+		dto["actor"] = t.Actor
+	}
+	if t.Reason != "" {
+		dto["reason"] = t.Reason
+	}
+	return dto
+}
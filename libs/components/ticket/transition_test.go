@@ -0,0 +1,142 @@
+package ticket
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestDefaultTransitionRules(t *testing.T) {
+	rules := DefaultTransitionRules()
+
+	cases := []struct {
+		from, to string
+		allowed  bool
+	}{
+		{StatusOpen, StatusInProgress, true},
+		{StatusInProgress, StatusResolved, true},
+		{StatusResolved, StatusInProgress, true},
+		{StatusResolved, StatusOpen, false},
+		{StatusCancelled, StatusInProgress, false},
+		{StatusOpen, StatusOpen, false},
+	}
+	for _, tc := range cases {
+		if got := rules.Allows(tc.from, tc.to); got != tc.allowed {
+			t.Errorf("Allows(%s, %s) = %v, want %v", tc.from, tc.to, got, tc.allowed)
+		}
+	}
+}
+
+func TestParseTransitionRules(t *testing.T) {
+	rules, err := ParseTransitionRules("open=in_progress|cancelled; in_progress=resolved; resolved=")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !rules.Allows(StatusOpen, StatusCancelled) {
+		t.Fatalf("expected open -> cancelled to be allowed")
+	}
+	if rules.Allows(StatusInProgress, StatusOpen) {
+		t.Fatalf("expected in_progress -> open to be rejected")
+	}
+	if targets := rules.Targets(StatusResolved); len(targets) != 0 {
+		t.Fatalf("expected resolved to be terminal, got %v", targets)
+	}
+
+	if _, err := ParseTransitionRules("open=archived"); err == nil {
+		t.Fatalf("expected unknown status to be rejected")
+	}
+	if _, err := ParseTransitionRules("open"); err == nil {
+		t.Fatalf("expected malformed clause to be rejected")
+	}
+}
+
+func TestTransitionsThroughTheHandler(t *testing.T) {
+	router := chi.NewRouter()
+	NewHandler(NewGormRepository(newTestDB(t))).Mount(router, "")
+	do := func(method, target string, body any, out any) int {
+		t.Helper()
+		var payload bytes.Buffer
+		if body != nil {
+			json.NewEncoder(&payload).Encode(body)
+		}
+		req := httptest.NewRequest(method, target, &payload)
+		req.Header.Set("X-User-ID", "agent")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if out != nil {
+			json.Unmarshal(rec.Body.Bytes(), out)
+		}
+		return rec.Code
+	}
+
+	if code := do(http.MethodPost, "/tickets", map[string]any{"title": "Printer on fire", "formId": routedForm, "status": StatusResolved}, nil); code != http.StatusBadRequest {
+		t.Fatalf("expected a ticket created as resolved to be rejected, got %d", code)
+	}
+	var created struct {
+		Data struct {
+			ID     string `json:"id"`
+			Status string `json:"status"`
+		} `json:"data"`
+	}
+	if code := do(http.MethodPost, "/tickets", map[string]any{"title": "Printer on fire", "formId": routedForm}, &created); code != http.StatusCreated || created.Data.Status != StatusOpen {
+		t.Fatalf("create: %d %+v", code, created.Data)
+	}
+	base := "/tickets/" + created.Data.ID
+
+	if code := do(http.MethodPost, base+"/transitions", map[string]any{"to": StatusResolved, "reason": "rebooted"}, nil); code != http.StatusOK {
+		t.Fatalf("transition: %d", code)
+	}
+	if code := do(http.MethodPost, base+"/transitions", map[string]any{"to": StatusOpen}, nil); code != http.StatusConflict {
+		t.Fatalf("expected resolved -> open to conflict, got %d", code)
+	}
+	if code := do(http.MethodPatch, base, map[string]any{"status": StatusOpen}, nil); code != http.StatusConflict {
+		t.Fatalf("expected an update to resolved -> open to conflict, got %d", code)
+	}
+
+	var history struct {
+		Data []map[string]any `json:"data"`
+	}
+	if code := do(http.MethodGet, base+"/history", nil, &history); code != http.StatusOK {
+		t.Fatalf("history: %d", code)
+	}
+	if len(history.Data) != 1 {
+		t.Fatalf("expected only the allowed transition in the history, got %v", history.Data)
+	}
+	entry := history.Data[0]
+	if entry["from"] != StatusOpen || entry["to"] != StatusResolved || entry["actor"] != "agent" || entry["reason"] != "rebooted" {
+		t.Fatalf("unexpected history entry %v", entry)
+	}
+	if code := do(http.MethodGet, "/tickets/3f1c2a4e-0000-4c6e-9a5f-1e2d3c4b5a69/history", nil, nil); code != http.StatusNotFound {
+		t.Fatalf("expected the history of an unknown ticket to be 404, got %d", code)
+	}
+}
+
+func TestEditCannotResolveOrAssign(t *testing.T) {
+	client, ticket := newCommentTestClient(t)
+	base := "/tickets/" + ticket.ID
+	const edit = "ticket:view,ticket:edit"
+
+	refused := []struct {
+		method, path string
+		body         map[string]any
+	}{
+		{http.MethodPost, base + "/transitions", map[string]any{"to": StatusResolved}},
+		{http.MethodPatch, base, map[string]any{"status": StatusResolved}},
+		{http.MethodPatch, base, map[string]any{"assigneeId": "agent-2"}},
+	}
+	for _, tc := range refused {
+		if code, body := client.do(tc.method, tc.path, "editor", edit, tc.body); code != http.StatusForbidden {
+			t.Fatalf("%s %s %v: expected 403 with ticket:edit only, got %d %v", tc.method, tc.path, tc.body, code, body)
+		}
+	}
+	if code, body := client.do(http.MethodPost, base+"/transitions", "editor", edit, map[string]any{"to": StatusInProgress}); code != http.StatusOK {
+		t.Fatalf("expected ticket:edit to allow other transitions, got %d %v", code, body)
+	}
+	if code, body := client.do(http.MethodPatch, base, "resolver", edit+",ticket:resolve,ticket:assign", map[string]any{"status": StatusResolved, "assigneeId": "agent-2"}); code != http.StatusOK {
+		t.Fatalf("expected ticket:resolve and ticket:assign to allow the update, got %d %v", code, body)
+	}
+}
@@ -0,0 +1,48 @@
+package ticket
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/pflow/components/form"
+)
+
+// FormValidator checks ticket metadata against a schema version of a form, or its
+// current schema when version is zero, and returns the version it validated against.
+// Implementations return form.ErrFormNotFound or form.ErrFormVersionNotFound for unknown
+// references and a *form.ValidationError listing the offending fields; *form.Validator
+// satisfies this interface.
+type FormValidator interface {
+	Validate(ctx context.Context, formID string, version int, data map[string]any) (int, error)
+}
+
+// errFormLookup marks failures to load a form, as opposed to invalid ticket data.
+var errFormLookup = errors.New("form lookup failed")
+
+// validateMetadata runs the validator, if any, and returns the form version the ticket
+// should be pinned to. Without a validator the requested version is kept as is.
+// Infrastructure failures are wrapped in errFormLookup so callers can tell them apart
+// from rejected metadata.
+func validateMetadata(ctx context.Context, forms FormValidator, formID string, version int, metadata map[string]any) (int, error) {
+	if forms == nil {
+		return version, nil
+	}
+	if metadata == nil {
+		metadata = map[string]any{}
+	}
+
+	pinned, err := forms.Validate(ctx, formID, version, metadata)
+	if err == nil || isRejectedMetadata(err) {
+		return pinned, err
+	}
+	return 0, fmt.Errorf("%w: %v", errFormLookup, err)
+}
+
+// isRejectedMetadata reports whether err means the ticket data itself is unacceptable.
+func isRejectedMetadata(err error) bool {
+	var invalid *form.ValidationError
+	return errors.As(err, &invalid) ||
+		errors.Is(err, form.ErrFormNotFound) ||
+		errors.Is(err, form.ErrFormVersionNotFound)
+}
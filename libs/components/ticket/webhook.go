@@ -0,0 +1,484 @@
+package ticket
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+
+	"github.com/pflow/shared/audit"
+	"github.com/pflow/shared/httpx"
+)
+
+const (
+	// WebhookDeliveryPending marks a delivery that is waiting for its next attempt.
+	WebhookDeliveryPending = "pending"
+	// WebhookDeliverySucceeded marks a delivery the receiver accepted with a 2xx response.
+	WebhookDeliverySucceeded = "succeeded"
+	// WebhookDeliveryFailed marks a delivery that ran out of attempts.
+	WebhookDeliveryFailed = "failed"
+)
+
+// Headers sent with every webhook request.
+const (
+	WebhookEventHeader     = "X-Webhook-Event"
+	WebhookDeliveryHeader  = "X-Webhook-Delivery"
+	WebhookTimestampHeader = "X-Webhook-Timestamp"
+	// WebhookSignatureHeader carries "sha256=" and the hex HMAC-SHA256 of the
+	// timestamp header, a dot and the body, keyed with the webhook secret.
+	WebhookSignatureHeader = "X-Webhook-Signature"
+)
+
+// webhookEventTypes are the event types a webhook may subscribe to besides
+// the "*" and "ticket.*" wildcards.
+var webhookEventTypes = map[string]struct{}{
+	EventTicketCreated:           {},
+	EventTicketUpdated:           {},
+	EventTicketDeleted:           {},
+	EventTicketRestored:          {},
+	EventTicketStatusChanged:     {},
+	EventTicketResolved:          {},
+	EventTicketAssigned:          {},
+	EventTicketCommentAdded:      {},
+	EventTicketCommentUpdated:    {},
+	EventTicketCommentDeleted:    {},
+	EventTicketAttachmentAdded:   {},
+	EventTicketAttachmentDeleted: {},
+	EventSLABreached:             {},
+}
+
+// ErrWebhookDisabled is returned when redelivering to a disabled webhook.
+var ErrWebhookDisabled = errors.New("webhook is disabled")
+
+// Webhook subscribes a partner URL to ticket events. Every matching event is
+// POSTed to the URL as a CloudEvents JSON document signed with the secret.
+// The dispatcher disables a webhook whose deliveries keep failing; enabling
+// it again resumes the pending deliveries.
+type Webhook struct {
+	ID          string                      `json:"id" gorm:"type:uuid;primaryKey"`
+	TenantID    string                      `json:"tenantId" gorm:"type:varchar(64);not null;default:default;index"`
+	URL         string                      `json:"url" gorm:"not null"`
+	Description string                      `json:"description"`
+	EventTypes  datatypes.JSONSlice[string] `json:"eventTypes" gorm:"type:jsonb"`
+	Secret      string                      `json:"-" gorm:"not null"`
+	Enabled     bool                        `json:"enabled" gorm:"not null"`
+	// ConsecutiveFailures counts the failed attempts since the last success,
+	// which is also when FailingSince was set.
+	ConsecutiveFailures int        `json:"consecutiveFailures" gorm:"not null;default:0"`
+	FailingSince        *time.Time `json:"failingSince"`
+	DisabledAt          *time.Time `json:"disabledAt"`
+	DisabledReason      string     `json:"disabledReason"`
+	CreatedAt           time.Time  `json:"createdAt"`
+	UpdatedAt           time.Time  `json:"updatedAt"`
+}
+
+// BeforeCreate assigns a UUID when missing.
+func (w *Webhook) BeforeCreate(tx *gorm.DB) error {
+	if w.ID == "" {
+		w.ID = uuid.NewString()
+	}
+	return nil
+}
+
+// ToDTO exposes the webhook for clients. The secret is only returned when the
+// webhook is created or its secret rotated.
+func (w Webhook) ToDTO() map[string]any {
+	dto := map[string]any{
+		"id":                  w.ID,
+		"tenantId":            w.TenantID,
+		"url":                 w.URL,
+		"description":         w.Description,
+		"eventTypes":          []string(w.EventTypes),
+		"enabled":             w.Enabled,
+		"consecutiveFailures": w.ConsecutiveFailures,
+		"disabledReason":      w.DisabledReason,
+		"createdAt":           w.CreatedAt,
+		"updatedAt":           w.UpdatedAt,
+	}
+	if w.FailingSince != nil {
+		dto["failingSince"] = *w.FailingSince
+	}
+	if w.DisabledAt != nil {
+		dto["disabledAt"] = *w.DisabledAt
+	}
+	return dto
+}
+
+// Matches reports whether the webhook subscribes to events of eventType.
+func (w Webhook) Matches(eventType string) bool {
+	for _, subscribed := range w.EventTypes {
+		switch {
+		case subscribed == "*", subscribed == eventType:
+			return true
+		case strings.HasSuffix(subscribed, ".*") && strings.HasPrefix(eventType, strings.TrimSuffix(subscribed, "*")):
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDelivery is one event sent, or to be sent, to a webhook, together
+// with the outcome of its latest attempt.
+type WebhookDelivery struct {
+	ID            string     `json:"id" gorm:"type:uuid;primaryKey"`
+	TenantID      string     `json:"tenantId" gorm:"type:varchar(64);not null;default:default;index"`
+	WebhookID     string     `json:"webhookId" gorm:"type:uuid;not null;index"`
+	EventID       string     `json:"eventId" gorm:"type:varchar(64);not null"`
+	EventType     string     `json:"eventType" gorm:"type:varchar(64);not null"`
+	Payload       []byte     `json:"-" gorm:"type:bytea"`
+	Status        string     `json:"status" gorm:"type:varchar(16);not null;index:idx_webhook_deliveries_due,priority:1"`
+	Attempts      int        `json:"attempts" gorm:"not null;default:0"`
+	NextAttemptAt time.Time  `json:"nextAttemptAt" gorm:"index:idx_webhook_deliveries_due,priority:2"`
+	LastAttemptAt *time.Time `json:"lastAttemptAt"`
+	ResponseCode  int        `json:"responseCode"`
+	LatencyMillis int64      `json:"latencyMs"`
+	LastError     string     `json:"lastError"`
+	// RedeliveryOf is the delivery this one repeats on request.
+	RedeliveryOf *string   `json:"redeliveryOf" gorm:"type:uuid"`
+	CreatedAt    time.Time `json:"createdAt"`
+	UpdatedAt    time.Time `json:"updatedAt"`
+}
+
+// BeforeCreate assigns defaults on deliveries.
+func (d *WebhookDelivery) BeforeCreate(tx *gorm.DB) error {
+	if d.ID == "" {
+		d.ID = uuid.NewString()
+	}
+	if d.Status == "" {
+		d.Status = WebhookDeliveryPending
+	}
+	if d.NextAttemptAt.IsZero() {
+		d.NextAttemptAt = time.Now()
+	}
+	return nil
+}
+
+// ToDTO exposes the delivery for clients.
+func (d WebhookDelivery) ToDTO() map[string]any {
+	dto := map[string]any{
+		"id":           d.ID,
+		"webhookId":    d.WebhookID,
+		"eventId":      d.EventID,
+		"eventType":    d.EventType,
+		"payload":      json.RawMessage(d.Payload),
+		"status":       d.Status,
+		"attempts":     d.Attempts,
+		"responseCode": d.ResponseCode,
+		"latencyMs":    d.LatencyMillis,
+		"lastError":    d.LastError,
+		"createdAt":    d.CreatedAt,
+		"updatedAt":    d.UpdatedAt,
+	}
+	if d.Status == WebhookDeliveryPending {
+		dto["nextAttemptAt"] = d.NextAttemptAt
+	}
+	if d.LastAttemptAt != nil {
+		dto["lastAttemptAt"] = *d.LastAttemptAt
+	}
+	if d.RedeliveryOf != nil {
+		dto["redeliveryOf"] = *d.RedeliveryOf
+	}
+	return dto
+}
+
+func (d WebhookDelivery) sortValue(column string) (any, string) {
+	return d.CreatedAt, d.ID
+}
+
+// SignWebhook returns the signature header value of a webhook request sent at
+// timestamp with body.
+func SignWebhook(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhook checks the timestamp and signature headers of a received
+// webhook request against its body. Receivers should also reject timestamps
+// too far in the past to prevent replays.
+func VerifyWebhook(secret, timestamp, signature string, body []byte) bool {
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	return hmac.Equal([]byte(SignWebhook(secret, ts, body)), []byte(signature))
+}
+
+// newWebhookSecret generates the signing secret of a webhook created without one.
+func newWebhookSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(buf), nil
+}
+
+// WebhookRepository persists the webhooks of the current tenant and their
+// delivery log.
+type WebhookRepository interface {
+	ListWebhooks(ctx context.Context) ([]Webhook, error)
+	FindWebhook(ctx context.Context, id string) (*Webhook, error)
+	CreateWebhook(ctx context.Context, webhook *Webhook) error
+	UpdateWebhook(ctx context.Context, webhook *Webhook, toggled bool) error
+	DeleteWebhook(ctx context.Context, id string) error
+	ListDeliveries(ctx context.Context, webhookID string, query httpx.ListQuery) ([]WebhookDelivery, httpx.Page, error)
+	FindDelivery(ctx context.Context, webhookID, id string) (*WebhookDelivery, error)
+	Redeliver(ctx context.Context, delivery *WebhookDelivery) (*WebhookDelivery, error)
+}
+
+var webhookDeliveryListSpec = httpx.ListSpec{
+	SortFields: map[string]string{
+		"createdAt": "created_at",
+	},
+	DefaultSort: "createdAt",
+	DefaultDesc: true,
+	Filters: map[string]string{
+		"status":    "status",
+		"eventType": "event_type",
+	},
+}
+
+const minWebhookSecretLength = 16
+
+type webhookRequest struct {
+	URL         *string   `json:"url"`
+	Description *string   `json:"description"`
+	EventTypes  *[]string `json:"eventTypes"`
+	Secret      *string   `json:"secret"`
+	Enabled     *bool     `json:"enabled"`
+}
+
+// apply copies the provided fields onto webhook and validates the result
+// against targets. It reports whether the secret changed.
+func (req webhookRequest) apply(webhook *Webhook, targets WebhookTargets) (bool, error) {
+	if req.URL != nil {
+		webhook.URL = strings.TrimSpace(*req.URL)
+	}
+	if req.Description != nil {
+		webhook.Description = strings.TrimSpace(*req.Description)
+	}
+	if req.EventTypes != nil {
+		types := make([]string, 0, len(*req.EventTypes))
+		seen := make(map[string]struct{}, len(*req.EventTypes))
+		for _, eventType := range *req.EventTypes {
+			eventType = strings.TrimSpace(eventType)
+			if _, ok := seen[eventType]; ok {
+				continue
+			}
+			if _, ok := webhookEventTypes[eventType]; !ok && eventType != "*" && eventType != "ticket.*" {
+				return false, errors.New("unknown event type " + strconv.Quote(eventType))
+			}
+			seen[eventType] = struct{}{}
+			types = append(types, eventType)
+		}
+		webhook.EventTypes = datatypes.NewJSONSlice(types)
+	}
+	rotated := false
+	if req.Secret != nil {
+		secret := strings.TrimSpace(*req.Secret)
+		if len(secret) < minWebhookSecretLength {
+			return false, errors.New("secret must be at least 16 characters")
+		}
+		webhook.Secret = secret
+		rotated = true
+	}
+	if req.Enabled != nil && *req.Enabled != webhook.Enabled {
+		webhook.Enabled = *req.Enabled
+		webhook.ConsecutiveFailures = 0
+		webhook.FailingSince = nil
+		webhook.DisabledAt = nil
+		webhook.DisabledReason = ""
+		if !webhook.Enabled {
+			now := time.Now()
+			webhook.DisabledAt = &now
+			webhook.DisabledReason = "disabled manually"
+		}
+	}
+
+	if webhook.URL == "" {
+		return false, errors.New("url is required")
+	}
+	if err := targets.validate(webhook.URL); err != nil {
+		return false, err
+	}
+	if len(webhook.EventTypes) == 0 {
+		return false, errors.New("eventTypes is required")
+	}
+	return rotated, nil
+}
+
+func (h *Handler) listWebhooks(w http.ResponseWriter, r *http.Request) {
+	webhooks, err := h.webhooks.ListWebhooks(r.Context())
+	if err != nil {
+		httpx.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	items := make([]map[string]any, 0, len(webhooks))
+	for _, webhook := range webhooks {
+		items = append(items, webhook.ToDTO())
+	}
+
+	httpx.JSON(w, http.StatusOK, map[string]any{"data": items})
+}
+
+func (h *Handler) createWebhook(w http.ResponseWriter, r *http.Request) {
+	var payload webhookRequest
+	if err := decodeJSON(r, &payload); err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	webhook := &Webhook{Enabled: true}
+	if payload.Secret == nil {
+		secret, err := newWebhookSecret()
+		if err != nil {
+			httpx.Error(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		webhook.Secret = secret
+	}
+	if _, err := payload.apply(webhook, h.webhookTargets); err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := h.webhooks.CreateWebhook(r.Context(), webhook); err != nil {
+		renderWebhookError(w, err)
+		return
+	}
+	h.audit.Record(r.Context(), audit.Change{Action: auditWebhookCreated, ResourceID: webhook.ID, After: webhook.ToDTO()})
+
+	dto := webhook.ToDTO()
+	dto["secret"] = webhook.Secret
+	httpx.JSON(w, http.StatusCreated, map[string]any{"data": dto})
+}
+
+func (h *Handler) getWebhook(w http.ResponseWriter, r *http.Request) {
+	webhook, err := h.webhooks.FindWebhook(r.Context(), chi.URLParam(r, "webhookId"))
+	if err != nil {
+		renderWebhookError(w, err)
+		return
+	}
+
+	httpx.JSON(w, http.StatusOK, map[string]any{"data": webhook.ToDTO()})
+}
+
+func (h *Handler) updateWebhook(w http.ResponseWriter, r *http.Request) {
+	var payload webhookRequest
+	if err := decodeJSON(r, &payload); err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	webhook, err := h.webhooks.FindWebhook(r.Context(), chi.URLParam(r, "webhookId"))
+	if err != nil {
+		renderWebhookError(w, err)
+		return
+	}
+	before, wasEnabled := webhook.ToDTO(), webhook.Enabled
+	rotated, err := payload.apply(webhook, h.webhookTargets)
+	if err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := h.webhooks.UpdateWebhook(r.Context(), webhook, webhook.Enabled != wasEnabled); err != nil {
+		renderWebhookError(w, err)
+		return
+	}
+	h.audit.Record(r.Context(), audit.Change{Action: auditWebhookUpdated, ResourceID: webhook.ID, Before: before, After: webhook.ToDTO()})
+
+	dto := webhook.ToDTO()
+	if rotated {
+		dto["secret"] = webhook.Secret
+	}
+	httpx.JSON(w, http.StatusOK, map[string]any{"data": dto})
+}
+
+func (h *Handler) deleteWebhook(w http.ResponseWriter, r *http.Request) {
+	webhook, err := h.webhooks.FindWebhook(r.Context(), chi.URLParam(r, "webhookId"))
+	if err != nil {
+		renderWebhookError(w, err)
+		return
+	}
+	if err := h.webhooks.DeleteWebhook(r.Context(), webhook.ID); err != nil {
+		renderWebhookError(w, err)
+		return
+	}
+	h.audit.Record(r.Context(), audit.Change{Action: auditWebhookDeleted, ResourceID: webhook.ID, Before: webhook.ToDTO()})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) listWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	query, err := httpx.ParseListQuery(r, webhookDeliveryListSpec)
+	if err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	deliveries, page, err := h.webhooks.ListDeliveries(r.Context(), chi.URLParam(r, "webhookId"), query)
+	if err != nil {
+		renderWebhookError(w, err)
+		return
+	}
+
+	items := make([]map[string]any, 0, len(deliveries))
+	for _, delivery := range deliveries {
+		items = append(items, delivery.ToDTO())
+	}
+
+	httpx.List(w, items, page)
+}
+
+func (h *Handler) getWebhookDelivery(w http.ResponseWriter, r *http.Request) {
+	delivery, err := h.webhooks.FindDelivery(r.Context(), chi.URLParam(r, "webhookId"), chi.URLParam(r, "deliveryId"))
+	if err != nil {
+		renderWebhookError(w, err)
+		return
+	}
+
+	httpx.JSON(w, http.StatusOK, map[string]any{"data": delivery.ToDTO()})
+}
+
+// redeliverWebhook queues the event of a delivery again as a new delivery,
+// which the dispatcher sends on its next run.
+func (h *Handler) redeliverWebhook(w http.ResponseWriter, r *http.Request) {
+	delivery, err := h.webhooks.FindDelivery(r.Context(), chi.URLParam(r, "webhookId"), chi.URLParam(r, "deliveryId"))
+	if err != nil {
+		renderWebhookError(w, err)
+		return
+	}
+	redelivery, err := h.webhooks.Redeliver(r.Context(), delivery)
+	if err != nil {
+		renderWebhookError(w, err)
+		return
+	}
+	h.audit.Record(r.Context(), audit.Change{Action: auditWebhookRedelivered, ResourceID: delivery.WebhookID, After: redelivery.ToDTO()})
+
+	httpx.JSON(w, http.StatusAccepted, map[string]any{"data": redelivery.ToDTO()})
+}
+
+func renderWebhookError(w http.ResponseWriter, err error) {
+	switch {
+	case IsNotFound(err):
+		httpx.Error(w, http.StatusNotFound, "webhook or delivery not found")
+	case errors.Is(err, ErrWebhookDisabled):
+		httpx.Error(w, http.StatusConflict, err.Error())
+	default:
+		httpx.Error(w, http.StatusInternalServerError, err.Error())
+	}
+}
@@ -0,0 +1,256 @@
+package ticket
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/pflow/shared/mq"
+	"github.com/pflow/shared/tenant"
+)
+
+// WebhookDispatcherConfig tunes how the dispatcher polls, retries and gives up
+// on webhooks.
+type WebhookDispatcherConfig struct {
+	BatchSize    int
+	PollInterval time.Duration
+	// Timeout bounds each request to a receiver. Lease is how long a claimed
+	// batch is hidden from other dispatchers and defaults to enough time for
+	// every request of the batch to time out.
+	Timeout time.Duration
+	Lease   time.Duration
+	// MaxAttempts is how often a delivery is tried before it is marked
+	// failed; retries back off exponentially from BaseBackoff to MaxBackoff.
+	MaxAttempts int
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	// A webhook is disabled once it has failed DisableAfterFailures attempts
+	// in a row over at least DisableAfter.
+	DisableAfterFailures int
+	DisableAfter         time.Duration
+	// Targets restricts the receivers the default client connects to.
+	Targets WebhookTargets
+}
+
+func (cfg WebhookDispatcherConfig) normalize() WebhookDispatcherConfig {
+	normalized := cfg
+	if normalized.BatchSize <= 0 {
+		normalized.BatchSize = 50
+	}
+	if normalized.PollInterval <= 0 {
+		normalized.PollInterval = 5 * time.Second
+	}
+	if normalized.Timeout <= 0 {
+		normalized.Timeout = 10 * time.Second
+	}
+	if normalized.Lease <= 0 {
+		normalized.Lease = time.Duration(normalized.BatchSize) * normalized.Timeout
+	}
+	if normalized.MaxAttempts <= 0 {
+		normalized.MaxAttempts = 8
+	}
+	if normalized.BaseBackoff <= 0 {
+		normalized.BaseBackoff = 30 * time.Second
+	}
+	if normalized.MaxBackoff <= 0 {
+		normalized.MaxBackoff = time.Hour
+	}
+	if normalized.DisableAfterFailures <= 0 {
+		normalized.DisableAfterFailures = 20
+	}
+	if normalized.DisableAfter <= 0 {
+		normalized.DisableAfter = 24 * time.Hour
+	}
+	return normalized
+}
+
+// backoff returns the delay before the given retry attempt.
+func (cfg WebhookDispatcherConfig) backoff(attempts int) time.Duration {
+	delay := cfg.BaseBackoff
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= cfg.MaxBackoff {
+			return cfg.MaxBackoff
+		}
+	}
+	return delay
+}
+
+// WebhookDispatcher queues ticket events for the webhooks subscribed to them
+// and POSTs the queued deliveries to their receivers. Queuing goes through
+// Publish, so the outbox relay can route events to the dispatcher; sending
+// happens in Run, which retries failed deliveries with exponential backoff and
+// disables webhooks that keep failing.
+type WebhookDispatcher struct {
+	store  WebhookStore
+	client *http.Client
+	cfg    WebhookDispatcherConfig
+}
+
+// NewWebhookDispatcher constructs a dispatcher over store. A nil client uses
+// the client of cfg.Targets.
+func NewWebhookDispatcher(store WebhookStore, client *http.Client, cfg WebhookDispatcherConfig) *WebhookDispatcher {
+	if client == nil {
+		client = cfg.Targets.Client()
+	}
+	return &WebhookDispatcher{store: store, client: client, cfg: cfg.normalize()}
+}
+
+// Publish queues a delivery of the encoded event to every webhook of its
+// tenant subscribed to its type. value must be an mq.Event.
+func (d *WebhookDispatcher) Publish(ctx context.Context, key string, value []byte, headers map[string]string) error {
+	var event mq.Event
+	if err := json.Unmarshal(value, &event); err != nil {
+		return fmt.Errorf("decode event: %w", err)
+	}
+
+	ctx = tenant.WithID(ctx, event.TenantID)
+	webhooks, err := d.store.MatchingWebhooks(ctx, event.Type)
+	if err != nil {
+		return err
+	}
+	deliveries := make([]WebhookDelivery, 0, len(webhooks))
+	for _, webhook := range webhooks {
+		deliveries = append(deliveries, WebhookDelivery{
+			TenantID:  webhook.TenantID,
+			WebhookID: webhook.ID,
+			EventID:   event.ID,
+			EventType: event.Type,
+			Payload:   value,
+		})
+	}
+	return d.store.CreateDeliveries(ctx, deliveries)
+}
+
+// Run dispatches deliveries until the context is cancelled.
+func (d *WebhookDispatcher) Run(ctx context.Context) error {
+	if d == nil || d.store == nil {
+		return fmt.Errorf("webhook dispatcher not initialised")
+	}
+
+	ticker := time.NewTicker(d.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			dispatched, err := d.DispatchOnce(ctx)
+			if err != nil {
+				log.Printf("webhook dispatcher: %v", err)
+				break
+			}
+			if dispatched < d.cfg.BatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// DispatchOnce claims one batch of due deliveries and sends them, returning how many were claimed.
+func (d *WebhookDispatcher) DispatchOnce(ctx context.Context) (int, error) {
+	deliveries, webhooks, err := d.store.ClaimDeliveries(ctx, d.cfg.BatchSize, d.cfg.Lease)
+	if err != nil {
+		return 0, err
+	}
+
+	disabled := make(map[string]bool)
+	for i := range deliveries {
+		if ctx.Err() != nil {
+			return len(deliveries), ctx.Err()
+		}
+		delivery := &deliveries[i]
+		webhook, ok := webhooks[delivery.WebhookID]
+		if !ok || disabled[webhook.ID] {
+			continue
+		}
+
+		d.attempt(ctx, webhook, delivery)
+		updated, err := d.store.RecordAttempt(ctx, delivery)
+		if err != nil {
+			log.Printf("webhook dispatcher: failed to record attempt of %s: %v", delivery.ID, err)
+			continue
+		}
+		if d.shouldDisable(updated) {
+			reason := fmt.Sprintf("disabled after %d consecutive failed deliveries: %s", updated.ConsecutiveFailures, delivery.LastError)
+			log.Printf("webhook dispatcher: %s %s", webhook.ID, reason)
+			if err := d.store.DisableWebhook(ctx, webhook.ID, reason); err != nil {
+				log.Printf("webhook dispatcher: failed to disable %s: %v", webhook.ID, err)
+			}
+			disabled[webhook.ID] = true
+		}
+	}
+	return len(deliveries), nil
+}
+
+// attempt sends delivery to the webhook and records the outcome on it.
+func (d *WebhookDispatcher) attempt(ctx context.Context, webhook Webhook, delivery *WebhookDelivery) {
+	started := time.Now()
+	code, err := d.send(ctx, webhook, delivery)
+
+	delivery.Attempts++
+	delivery.LastAttemptAt = &started
+	delivery.ResponseCode = code
+	delivery.LatencyMillis = time.Since(started).Milliseconds()
+	delivery.LastError = ""
+	switch {
+	case err == nil:
+		delivery.Status = WebhookDeliverySucceeded
+	case delivery.Attempts >= d.cfg.MaxAttempts:
+		delivery.Status = WebhookDeliveryFailed
+		delivery.LastError = err.Error()
+	default:
+		delivery.LastError = err.Error()
+		delivery.NextAttemptAt = time.Now().Add(d.cfg.backoff(delivery.Attempts))
+	}
+}
+
+// send POSTs the delivery and returns the response status; any status other
+// than 2xx is an error.
+func (d *WebhookDispatcher) send(ctx context.Context, webhook Webhook, delivery *WebhookDelivery) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, d.cfg.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", mq.EventContentType)
+	req.Header.Set("User-Agent", "pflow-webhooks/1.0")
+	req.Header.Set(WebhookEventHeader, delivery.EventType)
+	req.Header.Set(WebhookDeliveryHeader, delivery.ID)
+	req.Header.Set(WebhookTimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(WebhookSignatureHeader, SignWebhook(webhook.Secret, timestamp, delivery.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("receiver responded %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// shouldDisable reports whether the failures of webhook are sustained enough
+// to stop delivering to it.
+func (d *WebhookDispatcher) shouldDisable(webhook *Webhook) bool {
+	if !webhook.Enabled || webhook.FailingSince == nil {
+		return false
+	}
+	return webhook.ConsecutiveFailures >= d.cfg.DisableAfterFailures && time.Since(*webhook.FailingSince) >= d.cfg.DisableAfter
+}
@@ -0,0 +1,284 @@
+package ticket
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/pflow/shared/database"
+	"github.com/pflow/shared/httpx"
+	"github.com/pflow/shared/tenant"
+)
+
+// WebhookStore queues and records webhook deliveries for the dispatcher.
+// Claiming spans all tenants; the other methods act on the tenant of ctx.
+type WebhookStore interface {
+	MatchingWebhooks(ctx context.Context, eventType string) ([]Webhook, error)
+	CreateDeliveries(ctx context.Context, deliveries []WebhookDelivery) error
+	ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]WebhookDelivery, map[string]Webhook, error)
+	RecordAttempt(ctx context.Context, delivery *WebhookDelivery) (*Webhook, error)
+	DisableWebhook(ctx context.Context, id, reason string) error
+}
+
+// GormWebhookRepository persists webhooks and their deliveries via GORM.
+type GormWebhookRepository struct {
+	db *gorm.DB
+}
+
+// NewWebhookRepository constructs a webhook repository backed by the provided DB connection.
+func NewWebhookRepository(db *gorm.DB) *GormWebhookRepository {
+	return &GormWebhookRepository{db: db}
+}
+
+func (r *GormWebhookRepository) scoped(ctx context.Context) *gorm.DB {
+	return database.Conn(ctx, r.db).Scopes(database.TenantScope(ctx))
+}
+
+// ListWebhooks returns the webhooks of the current tenant, oldest first.
+func (r *GormWebhookRepository) ListWebhooks(ctx context.Context) ([]Webhook, error) {
+	var webhooks []Webhook
+	if err := r.scoped(ctx).Order("created_at ASC").Find(&webhooks).Error; err != nil {
+		return nil, err
+	}
+	return webhooks, nil
+}
+
+// FindWebhook retrieves a webhook by ID.
+func (r *GormWebhookRepository) FindWebhook(ctx context.Context, id string) (*Webhook, error) {
+	var webhook Webhook
+	if err := r.scoped(ctx).First(&webhook, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+// webhookSettingsColumns are the columns an update of a webhook writes. The
+// enabled state and the failure columns the dispatcher keeps are only written
+// when the update enables or disables the webhook.
+var (
+	webhookSettingsColumns = []string{"url", "description", "event_types", "secret", "updated_at"}
+	webhookStateColumns    = []string{"enabled", "consecutive_failures", "failing_since", "disabled_at", "disabled_reason"}
+)
+
+// CreateWebhook stores a new webhook in the current tenant.
+func (r *GormWebhookRepository) CreateWebhook(ctx context.Context, webhook *Webhook) error {
+	webhook.TenantID = tenant.ID(ctx)
+	return database.Conn(ctx, r.db).Create(webhook).Error
+}
+
+// UpdateWebhook writes the settings of a webhook, and its enabled state and
+// failure counters when toggled, so an update does not undo the failures or
+// the disabling the dispatcher recorded since the webhook was read.
+func (r *GormWebhookRepository) UpdateWebhook(ctx context.Context, webhook *Webhook, toggled bool) error {
+	columns := webhookSettingsColumns
+	if toggled {
+		columns = append(append([]string(nil), columns...), webhookStateColumns...)
+	}
+	result := r.scoped(ctx).Model(webhook).Select(columns).Updates(webhook)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// DeleteWebhook removes a webhook together with its delivery log.
+func (r *GormWebhookRepository) DeleteWebhook(ctx context.Context, id string) error {
+	return database.Conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		result := tx.Scopes(database.TenantScope(ctx)).Delete(&Webhook{}, "id = ?", id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return tx.Delete(&WebhookDelivery{}, "webhook_id = ?", id).Error
+	})
+}
+
+// ListDeliveries pages through the delivery log of a webhook, newest first by default.
+func (r *GormWebhookRepository) ListDeliveries(ctx context.Context, webhookID string, query httpx.ListQuery) ([]WebhookDelivery, httpx.Page, error) {
+	if _, err := r.FindWebhook(ctx, webhookID); err != nil {
+		return nil, httpx.Page{}, err
+	}
+	return database.Paginate(r.scoped(ctx).Model(&WebhookDelivery{}).Where("webhook_id = ?", webhookID), query, WebhookDelivery.sortValue)
+}
+
+// FindDelivery returns a single delivery of a webhook.
+func (r *GormWebhookRepository) FindDelivery(ctx context.Context, webhookID, id string) (*WebhookDelivery, error) {
+	var delivery WebhookDelivery
+	if err := r.scoped(ctx).First(&delivery, "id = ? AND webhook_id = ?", id, webhookID).Error; err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+// Redeliver queues the event of delivery again as a new delivery.
+func (r *GormWebhookRepository) Redeliver(ctx context.Context, delivery *WebhookDelivery) (*WebhookDelivery, error) {
+	webhook, err := r.FindWebhook(ctx, delivery.WebhookID)
+	if err != nil {
+		return nil, err
+	}
+	if !webhook.Enabled {
+		return nil, ErrWebhookDisabled
+	}
+
+	redelivery := &WebhookDelivery{
+		TenantID:     delivery.TenantID,
+		WebhookID:    delivery.WebhookID,
+		EventID:      delivery.EventID,
+		EventType:    delivery.EventType,
+		Payload:      delivery.Payload,
+		RedeliveryOf: &delivery.ID,
+	}
+	if err := database.Conn(ctx, r.db).Create(redelivery).Error; err != nil {
+		return nil, err
+	}
+	return redelivery, nil
+}
+
+// MatchingWebhooks returns the enabled webhooks of the current tenant that
+// subscribe to eventType.
+func (r *GormWebhookRepository) MatchingWebhooks(ctx context.Context, eventType string) ([]Webhook, error) {
+	var webhooks []Webhook
+	if err := r.scoped(ctx).Where("enabled = ?", true).Find(&webhooks).Error; err != nil {
+		return nil, err
+	}
+	matching := webhooks[:0]
+	for _, webhook := range webhooks {
+		if webhook.Matches(eventType) {
+			matching = append(matching, webhook)
+		}
+	}
+	return matching, nil
+}
+
+// CreateDeliveries queues deliveries, skipping those whose webhook already has
+// a delivery of the same event: the outbox relay hands an event over again
+// when it fails to record that it was published.
+func (r *GormWebhookRepository) CreateDeliveries(ctx context.Context, deliveries []WebhookDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+	return database.Conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		events := make([]string, 0, len(deliveries))
+		for _, delivery := range deliveries {
+			events = append(events, delivery.EventID)
+		}
+		var queued []WebhookDelivery
+		if err := tx.Select("webhook_id", "event_id").
+			Where("event_id IN ? AND redelivery_of IS NULL", events).
+			Find(&queued).Error; err != nil {
+			return err
+		}
+		seen := make(map[[2]string]bool, len(queued))
+		for _, delivery := range queued {
+			seen[[2]string{delivery.WebhookID, delivery.EventID}] = true
+		}
+
+		fresh := make([]WebhookDelivery, 0, len(deliveries))
+		for _, delivery := range deliveries {
+			if !seen[[2]string{delivery.WebhookID, delivery.EventID}] {
+				fresh = append(fresh, delivery)
+			}
+		}
+		if len(fresh) == 0 {
+			return nil
+		}
+		return tx.Create(&fresh).Error
+	})
+}
+
+// ClaimDeliveries locks up to limit due deliveries of enabled webhooks and
+// pushes their next attempt past the lease so concurrent dispatchers skip
+// them. It also returns the webhooks of the claimed deliveries by ID. This
+// deliberately spans all tenants.
+func (r *GormWebhookRepository) ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]WebhookDelivery, map[string]Webhook, error) {
+	var (
+		deliveries []WebhookDelivery
+		webhooks   []Webhook
+	)
+	err := database.Conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		enabled := tx.Model(&Webhook{}).Select("id").Where("enabled = ?", true)
+		if err := tx.
+			Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ? AND webhook_id IN (?)", WebhookDeliveryPending, now, enabled).
+			Order("next_attempt_at ASC").
+			Limit(limit).
+			Find(&deliveries).Error; err != nil {
+			return err
+		}
+		if len(deliveries) == 0 {
+			return nil
+		}
+
+		ids := make([]string, 0, len(deliveries))
+		webhookIDs := make([]string, 0, len(deliveries))
+		for _, delivery := range deliveries {
+			ids = append(ids, delivery.ID)
+			webhookIDs = append(webhookIDs, delivery.WebhookID)
+		}
+		if err := tx.Where("id IN ?", webhookIDs).Find(&webhooks).Error; err != nil {
+			return err
+		}
+		return tx.Model(&WebhookDelivery{}).
+			Where("id IN ?", ids).
+			Update("next_attempt_at", now.Add(lease)).Error
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	byID := make(map[string]Webhook, len(webhooks))
+	for _, webhook := range webhooks {
+		byID[webhook.ID] = webhook
+	}
+	return deliveries, byID, nil
+}
+
+// RecordAttempt stores the outcome of the latest attempt of delivery and
+// updates the failure counters of its webhook, which it returns.
+func (r *GormWebhookRepository) RecordAttempt(ctx context.Context, delivery *WebhookDelivery) (*Webhook, error) {
+	var webhook Webhook
+	err := database.Conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(delivery).Select("status", "attempts", "next_attempt_at", "last_attempt_at", "response_code", "latency_millis", "last_error").Updates(delivery).Error; err != nil {
+			return err
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&webhook, "id = ?", delivery.WebhookID).Error; err != nil {
+			return err
+		}
+
+		if delivery.Status == WebhookDeliverySucceeded {
+			webhook.ConsecutiveFailures = 0
+			webhook.FailingSince = nil
+		} else {
+			webhook.ConsecutiveFailures++
+			if webhook.FailingSince == nil {
+				webhook.FailingSince = delivery.LastAttemptAt
+			}
+		}
+		return tx.Model(&webhook).UpdateColumns(map[string]any{
+			"consecutive_failures": webhook.ConsecutiveFailures,
+			"failing_since":        webhook.FailingSince,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+// DisableWebhook stops deliveries to a webhook until it is enabled again.
+func (r *GormWebhookRepository) DisableWebhook(ctx context.Context, id, reason string) error {
+	now := time.Now()
+	return database.Conn(ctx, r.db).Model(&Webhook{}).Where("id = ?", id).UpdateColumns(map[string]any{
+		"enabled":         false,
+		"disabled_at":     &now,
+		"disabled_reason": reason,
+	}).Error
+}
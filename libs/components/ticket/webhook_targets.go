@@ -0,0 +1,149 @@
+package ticket
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// WebhookTargets decides which receivers webhooks may deliver to. By default
+// any public host is allowed, while loopback, private, link-local (including
+// the 169.254.169.254 metadata endpoint) and other non-public addresses are
+// refused both when a webhook is registered and when a delivery connects, so
+// a host that resolves to an internal address later is still caught.
+type WebhookTargets struct {
+	// AllowedHosts, when not empty, is the only hosts webhooks may deliver to.
+	// An entry of the form "*.example.com" allows every subdomain.
+	AllowedHosts []string
+	// AllowPrivate permits non-public receivers, for development and tests.
+	AllowPrivate bool
+}
+
+var errWebhookHostNotAllowed = errors.New("webhook host is not allowed")
+
+// nonPublicPrefixes are the ranges netip does not classify as private,
+// loopback or link-local but that still never reach a partner.
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+}
+
+// publicAddress reports whether addr is a routable, public unicast address.
+func publicAddress(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+	for _, prefix := range nonPublicPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// allowsHost reports whether host is on the allowlist, or whether there is no
+// allowlist at all.
+func (t WebhookTargets) allowsHost(host string) bool {
+	if len(t.AllowedHosts) == 0 {
+		return true
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, allowed := range t.AllowedHosts {
+		allowed = strings.ToLower(strings.TrimSpace(allowed))
+		if suffix, ok := strings.CutPrefix(allowed, "*"); ok && strings.HasPrefix(suffix, ".") {
+			if strings.HasSuffix(host, suffix) {
+				return true
+			}
+			continue
+		}
+		if host == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// validate checks a webhook URL when it is registered. Hosts given by name
+// are only resolved when the delivery connects.
+func (t WebhookTargets) validate(raw string) error {
+	target, err := url.Parse(raw)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return errors.New("url must be an absolute http or https URL")
+	}
+	host := target.Hostname()
+	if !t.allowsHost(host) {
+		return fmt.Errorf("url host %q is not allowed", host)
+	}
+	if t.AllowPrivate {
+		return nil
+	}
+	if addr, err := netip.ParseAddr(host); err == nil && !publicAddress(addr) {
+		return fmt.Errorf("url host %q is not a public address", host)
+	}
+	if host = strings.ToLower(strings.TrimSuffix(host, ".")); host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return fmt.Errorf("url host %q is not a public address", host)
+	}
+	return nil
+}
+
+// Client returns the HTTP client deliveries are sent with. It never follows
+// redirects, so a receiver cannot bounce a delivery to another host; the
+// redirect response counts as a failed attempt.
+func (t WebhookTargets) Client() *http.Client {
+	dialer := &net.Dialer{Timeout: 10 * time.Second, KeepAlive: 30 * time.Second}
+	if !t.AllowPrivate {
+		dialer.Control = refuseNonPublic
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// A proxy would connect on our behalf, past the dialer's check.
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Transport: webhookTransport{targets: t, next: transport},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// refuseNonPublic is a net.Dialer Control func that runs after the host was
+// resolved, right before the connection is opened.
+func refuseNonPublic(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	if !publicAddress(addr) {
+		return fmt.Errorf("webhook receiver %s is not a public address", addr)
+	}
+	return nil
+}
+
+// webhookTransport refuses requests to hosts outside the allowlist.
+type webhookTransport struct {
+	targets WebhookTargets
+	next    http.RoundTripper
+}
+
+func (t webhookTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !t.targets.allowsHost(req.URL.Hostname()) {
+		return nil, fmt.Errorf("%w: %s", errWebhookHostNotAllowed, req.URL.Hostname())
+	}
+	return t.next.RoundTrip(req)
+}
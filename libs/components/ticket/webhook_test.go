@@ -0,0 +1,262 @@
+package ticket
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/pflow/shared/mq"
+	"github.com/pflow/shared/tenant"
+)
+
+type webhookReceiver struct {
+	*httptest.Server
+	failing  atomic.Bool
+	mu       sync.Mutex
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func newWebhookReceiver(t *testing.T) *webhookReceiver {
+	receiver := &webhookReceiver{}
+	receiver.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		receiver.mu.Lock()
+		receiver.requests = append(receiver.requests, r)
+		receiver.bodies = append(receiver.bodies, body)
+		receiver.mu.Unlock()
+		if receiver.failing.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(receiver.Close)
+	return receiver
+}
+
+func TestWebhookDeliveries(t *testing.T) {
+	db := newTestDB(t)
+	webhooks := NewWebhookRepository(db)
+	router := chi.NewRouter()
+	NewHandler(NewGormRepository(db), WithWebhooks(webhooks, WebhookTargets{AllowPrivate: true})).Mount(router, "")
+	do := func(method, target string, body any) (int, map[string]any) {
+		t.Helper()
+		var payload bytes.Buffer
+		if body != nil {
+			json.NewEncoder(&payload).Encode(body)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(method, target, &payload))
+		var envelope struct {
+			Data map[string]any `json:"data"`
+		}
+		json.Unmarshal(rec.Body.Bytes(), &envelope)
+		return rec.Code, envelope.Data
+	}
+
+	partner := newWebhookReceiver(t)
+	broken := newWebhookReceiver(t)
+	broken.failing.Store(true)
+
+	if code, _ := do(http.MethodPost, "/tickets/webhooks", map[string]any{"url": partner.URL, "eventTypes": []string{"ticket.exploded"}}); code != http.StatusBadRequest {
+		t.Fatalf("expected an unknown event type to be rejected, got %d", code)
+	}
+	code, created := do(http.MethodPost, "/tickets/webhooks", map[string]any{"url": partner.URL, "eventTypes": []string{"ticket.*"}})
+	if code != http.StatusCreated || created["secret"] == "" {
+		t.Fatalf("create webhook: %d %v", code, created)
+	}
+	partnerID, secret := created["id"].(string), created["secret"].(string)
+	code, created = do(http.MethodPost, "/tickets/webhooks", map[string]any{"url": broken.URL, "eventTypes": []string{EventTicketResolved}, "secret": "a shared secret of ours"})
+	if code != http.StatusCreated {
+		t.Fatalf("create webhook: %d %v", code, created)
+	}
+	brokenID := created["id"].(string)
+	if _, fetched := do(http.MethodGet, "/tickets/webhooks/"+partnerID, nil); fetched["secret"] != nil {
+		t.Fatal("expected the secret to be hidden after creation")
+	}
+
+	dispatcher := NewWebhookDispatcher(webhooks, nil, WebhookDispatcherConfig{
+		BaseBackoff:          time.Millisecond,
+		MaxBackoff:           time.Millisecond,
+		MaxAttempts:          2,
+		DisableAfterFailures: 2,
+		DisableAfter:         time.Nanosecond,
+		Targets:              WebhookTargets{AllowPrivate: true},
+	})
+	ctx := tenant.WithID(context.Background(), tenant.Default)
+	for _, eventType := range []string{EventTicketCreated, EventTicketResolved} {
+		event, err := mq.NewEvent(ctx, eventSource, eventType, "t-1", map[string]any{"id": "t-1"})
+		if err != nil {
+			t.Fatalf("event: %v", err)
+		}
+		// The outbox relay may hand an event over twice.
+		for i := 0; i < 2; i++ {
+			if err := mq.PublishEvent(ctx, dispatcher, event); err != nil {
+				t.Fatalf("queue %s: %v", eventType, err)
+			}
+		}
+	}
+
+	if dispatched, err := dispatcher.DispatchOnce(context.Background()); err != nil || dispatched != 3 {
+		t.Fatalf("expected three deliveries, got %d (%v)", dispatched, err)
+	}
+	if len(partner.requests) != 2 {
+		t.Fatalf("expected the partner to receive both events, got %d", len(partner.requests))
+	}
+	for i, req := range partner.requests {
+		if !VerifyWebhook(secret, req.Header.Get(WebhookTimestampHeader), req.Header.Get(WebhookSignatureHeader), partner.bodies[i]) {
+			t.Fatalf("invalid signature on %s", req.Header.Get(WebhookEventHeader))
+		}
+		if VerifyWebhook("not the secret", req.Header.Get(WebhookTimestampHeader), req.Header.Get(WebhookSignatureHeader), partner.bodies[i]) {
+			t.Fatal("expected a signature made with another secret to fail")
+		}
+		var event mq.Event
+		if err := json.Unmarshal(partner.bodies[i], &event); err != nil || event.Type != req.Header.Get(WebhookEventHeader) || event.Subject != "t-1" {
+			t.Fatalf("unexpected body %s (%v)", partner.bodies[i], err)
+		}
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	if dispatched, err := dispatcher.DispatchOnce(context.Background()); err != nil || dispatched != 1 {
+		t.Fatalf("expected the failed delivery to be retried, got %d (%v)", dispatched, err)
+	}
+	if len(broken.requests) != 2 {
+		t.Fatalf("expected two attempts at the broken receiver, got %d", len(broken.requests))
+	}
+	if _, webhook := do(http.MethodGet, "/tickets/webhooks/"+brokenID, nil); webhook["enabled"] != false || webhook["disabledReason"] == "" {
+		t.Fatalf("expected sustained failures to disable the webhook, got %v", webhook)
+	}
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/tickets/webhooks/"+brokenID+"/deliveries", nil))
+	var log struct {
+		Data []map[string]any `json:"data"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &log)
+	if len(log.Data) != 1 {
+		t.Fatalf("expected one delivery in the log, got %s", rec.Body)
+	}
+	delivery := log.Data[0]
+	if delivery["status"] != WebhookDeliveryFailed || delivery["attempts"] != float64(2) || delivery["responseCode"] != float64(http.StatusInternalServerError) || delivery["lastAttemptAt"] == nil {
+		t.Fatalf("unexpected delivery log entry %v", delivery)
+	}
+	redeliver := "/tickets/webhooks/" + brokenID + "/deliveries/" + delivery["id"].(string) + "/redeliver"
+
+	if code, _ := do(http.MethodPost, redeliver, nil); code != http.StatusConflict {
+		t.Fatalf("expected redelivery to a disabled webhook to fail, got %d", code)
+	}
+	if code, webhook := do(http.MethodPatch, "/tickets/webhooks/"+brokenID, map[string]any{"description": "flaky partner"}); code != http.StatusOK ||
+		webhook["enabled"] != false || webhook["consecutiveFailures"] != float64(2) || webhook["disabledReason"] == "" {
+		t.Fatalf("expected editing a disabled webhook to keep its failures, got %d %v", code, webhook)
+	}
+	broken.failing.Store(false)
+	if code, webhook := do(http.MethodPatch, "/tickets/webhooks/"+brokenID, map[string]any{"enabled": true}); code != http.StatusOK || webhook["consecutiveFailures"] != float64(0) {
+		t.Fatalf("re-enable webhook: %d %v", code, webhook)
+	}
+	code, redelivery := do(http.MethodPost, redeliver, nil)
+	if code != http.StatusAccepted || redelivery["redeliveryOf"] != delivery["id"] {
+		t.Fatalf("redeliver: %d %v", code, redelivery)
+	}
+	if dispatched, err := dispatcher.DispatchOnce(context.Background()); err != nil || dispatched != 1 {
+		t.Fatalf("expected the redelivery to be sent, got %d (%v)", dispatched, err)
+	}
+	if _, sent := do(http.MethodGet, "/tickets/webhooks/"+brokenID+"/deliveries/"+redelivery["id"].(string), nil); sent["status"] != WebhookDeliverySucceeded || sent["responseCode"] != float64(http.StatusNoContent) {
+		t.Fatalf("unexpected redelivery %v", sent)
+	}
+}
+
+func TestWebhookUpdatesKeepWhatTheDispatcherRecorded(t *testing.T) {
+	repo := NewWebhookRepository(newTestDB(t))
+	ctx := context.Background()
+
+	webhook := &Webhook{URL: "https://partner.example.com/hook", EventTypes: []string{EventTicketCreated}, Secret: "whsec_0123456789abcdef", Enabled: true}
+	if err := repo.CreateWebhook(ctx, webhook); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	edited, err := repo.FindWebhook(ctx, webhook.ID)
+	if err != nil {
+		t.Fatalf("find: %v", err)
+	}
+
+	// The dispatcher disables the webhook while it is being edited.
+	if err := repo.DisableWebhook(ctx, webhook.ID, "receiver kept failing"); err != nil {
+		t.Fatalf("disable: %v", err)
+	}
+	edited.Description = "partner"
+	if err := repo.UpdateWebhook(ctx, edited, false); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	stored, err := repo.FindWebhook(ctx, webhook.ID)
+	if err != nil {
+		t.Fatalf("find: %v", err)
+	}
+	if stored.Description != "partner" || stored.Enabled || stored.DisabledReason != "receiver kept failing" {
+		t.Fatalf("expected the edit to keep the webhook disabled, got %+v", stored)
+	}
+	if err := repo.UpdateWebhook(tenant.WithID(ctx, "globex"), edited, false); !IsNotFound(err) {
+		t.Fatalf("expected updates from another tenant to miss, got %v", err)
+	}
+}
+
+func TestWebhookTargetsRefuseInternalReceivers(t *testing.T) {
+	db := newTestDB(t)
+	router := chi.NewRouter()
+	NewHandler(NewGormRepository(db), WithWebhooks(NewWebhookRepository(db), WebhookTargets{})).Mount(router, "")
+	for _, target := range []string{"http://169.254.169.254/latest/meta-data", "http://127.0.0.1:8080/", "http://10.0.0.7/hook", "http://[::1]/", "http://localhost/hook"} {
+		var payload bytes.Buffer
+		json.NewEncoder(&payload).Encode(map[string]any{"url": target, "eventTypes": []string{"*"}})
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/tickets/webhooks", &payload))
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("expected %s to be refused, got %d", target, rec.Code)
+		}
+	}
+
+	allowlist := WebhookTargets{AllowedHosts: []string{"hooks.example.com", "*.partner.example"}}
+	for target, allowed := range map[string]bool{
+		"https://hooks.example.com/pflow":  true,
+		"https://eu.partner.example/pflow": true,
+		"https://partner.example/pflow":    false,
+		"https://evil.example.com/pflow":   false,
+	} {
+		if err := allowlist.validate(target); (err == nil) != allowed {
+			t.Fatalf("expected %s allowed=%v, got %v", target, allowed, err)
+		}
+	}
+
+	receiver := newWebhookReceiver(t)
+	for _, target := range []string{receiver.URL, "http://169.254.169.254/latest/meta-data"} {
+		if _, err := (WebhookTargets{}).Client().Post(target, "application/json", nil); err == nil || !strings.Contains(err.Error(), "not a public address") {
+			t.Fatalf("expected the dialer to refuse %s, got %v", target, err)
+		}
+	}
+	if _, err := (WebhookTargets{AllowPrivate: true, AllowedHosts: []string{"hooks.example.com"}}).Client().Post(receiver.URL, "application/json", nil); !errors.Is(err, errWebhookHostNotAllowed) {
+		t.Fatalf("expected a host outside the allowlist to be refused, got %v", err)
+	}
+	if len(receiver.requests) != 0 {
+		t.Fatalf("expected no request to reach the receiver, got %d", len(receiver.requests))
+	}
+
+	bounce := httptest.NewServer(http.RedirectHandler(receiver.URL, http.StatusFound))
+	defer bounce.Close()
+	resp, err := (WebhookTargets{AllowPrivate: true}).Client().Post(bounce.URL, "application/json", nil)
+	if err != nil {
+		t.Fatalf("post: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound || len(receiver.requests) != 0 {
+		t.Fatalf("expected the redirect not to be followed, got %d and %d requests", resp.StatusCode, len(receiver.requests))
+	}
+}
@@ -11,18 +11,69 @@ import (
 
 	"gorm.io/gorm"
 
+	"github.com/pflow/components/outbox"
 	"github.com/pflow/shared/mq"
+	"github.com/pflow/shared/tenant"
 )
 
+// submissionMessage is the queue payload announcing a submission.
+type submissionMessage struct {
+	SubmissionID string `json:"submissionId"`
+	TenantID     string `json:"tenantId,omitempty"`
+}
+
 // QueueWorker processes submission messages and materialises tickets.
 type QueueWorker struct {
-	store SubmissionStore
-	repo  Repository
+	store       SubmissionStore
+	repo        Repository
+	forms       FormValidator
+	router      *Router
+	events      *outbox.EventStore
+	attachments AttachmentRepository
+}
+
+// WorkerOption customises the queue worker behaviour.
+type WorkerOption func(*QueueWorker)
+
+// WithWorkerFormValidator rejects submissions whose metadata does not match their form.
+func WithWorkerFormValidator(forms FormValidator) WorkerOption {
+	return func(w *QueueWorker) {
+		w.forms = forms
+	}
+}
+
+// WithWorkerRouting assigns the tickets the worker creates through the router.
+func WithWorkerRouting(router *Router) WorkerOption {
+	return func(w *QueueWorker) {
+		w.router = router
+	}
+}
+
+// WithWorkerEvents announces the tickets the worker creates through the event
+// outbox, in the transaction that creates them and completes the submission.
+func WithWorkerEvents(events *outbox.EventStore) WorkerOption {
+	return func(w *QueueWorker) {
+		w.events = events
+	}
+}
+
+// WithWorkerAttachments moves the files staged for a submission to the ticket
+// the worker creates from it.
+func WithWorkerAttachments(attachments AttachmentRepository) WorkerOption {
+	return func(w *QueueWorker) {
+		w.attachments = attachments
+	}
 }
 
 // NewQueueWorker constructs a queue worker.
-func NewQueueWorker(store SubmissionStore, repo Repository) *QueueWorker {
-	return &QueueWorker{store: store, repo: repo}
+func NewQueueWorker(store SubmissionStore, repo Repository, opts ...WorkerOption) *QueueWorker {
+	worker := &QueueWorker{store: store, repo: repo}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(worker)
+		}
+	}
+	return worker
 }
 
 // HandleMessage consumes a submission message from Kafka.
@@ -31,15 +82,18 @@ func (w *QueueWorker) HandleMessage(ctx context.Context, msg mq.Message) error {
 		return fmt.Errorf("ticket worker not initialised")
 	}
 
-	var payload struct {
-		SubmissionID string `json:"submissionId"`
-	}
+	var payload submissionMessage
 	if err := json.Unmarshal(msg.Value, &payload); err != nil {
 		return fmt.Errorf("decode submission message: %w", err)
 	}
 	if strings.TrimSpace(payload.SubmissionID) == "" {
 		return fmt.Errorf("submission id missing from message")
 	}
+	// Messages published before tenants were introduced carry none and belong
+	// to the default tenant.
+	if payload.TenantID != "" {
+		ctx = tenant.WithID(ctx, payload.TenantID)
+	}
 
 	submission, err := w.store.FindByID(ctx, payload.SubmissionID)
 	if err != nil {
@@ -56,34 +110,77 @@ func (w *QueueWorker) HandleMessage(ctx context.Context, msg mq.Message) error {
 
 	submission.Status = SubmissionProcessing
 	submission.ErrorMessage = ""
-	if err := w.store.Save(ctx, submission); err != nil {
-		return err
+	if err := w.store.SaveUnlessCompleted(ctx, submission); err != nil {
+		return w.skipCompleted(submission, err)
 	}
 
 	ticket, err := submission.ToTicket()
 	if err != nil {
 		submission.Status = SubmissionFailed
 		submission.ErrorMessage = err.Error()
-		if saveErr := w.store.Save(ctx, submission); saveErr != nil {
+		if saveErr := w.store.SaveUnlessCompleted(ctx, submission); saveErr != nil {
+			if errors.Is(saveErr, ErrSubmissionCompleted) {
+				return w.skipCompleted(submission, saveErr)
+			}
 			log.Printf("ticket worker: failed to persist submission failure: %v", saveErr)
 		}
 		return err
 	}
 
-	if err := w.repo.Create(ctx, ticket); err != nil {
+	version, err := validateMetadata(ctx, w.forms, ticket.FormID, ticket.FormVersion, map[string]any(ticket.Metadata))
+	if err != nil {
+		if !isRejectedMetadata(err) {
+			return err
+		}
+		// Invalid metadata will never succeed on retry, so fail the submission and
+		// acknowledge the message instead of dead-lettering it.
 		submission.Status = SubmissionFailed
 		submission.ErrorMessage = err.Error()
-		if saveErr := w.store.Save(ctx, submission); saveErr != nil {
-			log.Printf("ticket worker: failed to persist submission failure: %v", saveErr)
+		if saveErr := w.store.SaveUnlessCompleted(ctx, submission); saveErr != nil {
+			return w.skipCompleted(submission, saveErr)
 		}
-		return err
+		log.Printf("ticket worker: rejected submission %s: %v", submission.ID, err)
+		return nil
 	}
+	ticket.FormVersion = version
 
-	submission.Status = SubmissionCompleted
-	submission.TicketID = &ticket.ID
-	now := time.Now()
-	submission.CompletedAt = &now
-	if err := w.store.Save(ctx, submission); err != nil {
+	// The ticket, its creation event and the completed submission commit
+	// together. Completion only updates a submission that is not completed yet,
+	// so when two deliveries race, the one that loses rolls back its ticket.
+	err = w.repo.Transaction(ctx, func(ctx context.Context) error {
+		if err := w.repo.Create(ctx, ticket); err != nil {
+			return err
+		}
+		ticket = autoAssign(ctx, w.router, w.repo, ticket)
+		if err := w.events.Emit(ctx, EventTicketCreated, ticket.ID, ticket.ToDTO()); err != nil {
+			return err
+		}
+
+		submission.Status = SubmissionCompleted
+		submission.TicketID = &ticket.ID
+		now := time.Now()
+		submission.CompletedAt = &now
+		if err := w.store.SaveUnlessCompleted(ctx, submission); err != nil {
+			return err
+		}
+		// Staged files move once the submission row is locked by its update,
+		// so uploads racing the worker wait and then attach to the ticket.
+		return attachSubmission(ctx, w.attachments, w.events, submission.ID, ticket.ID)
+	})
+	if errors.Is(err, ErrSubmissionCompleted) {
+		return w.skipCompleted(submission, err)
+	}
+	if err != nil {
+		submission.Status = SubmissionFailed
+		submission.ErrorMessage = err.Error()
+		submission.TicketID = nil
+		submission.CompletedAt = nil
+		if saveErr := w.store.SaveUnlessCompleted(ctx, submission); saveErr != nil {
+			if errors.Is(saveErr, ErrSubmissionCompleted) {
+				return w.skipCompleted(submission, saveErr)
+			}
+			log.Printf("ticket worker: failed to persist submission failure: %v", saveErr)
+		}
 		return err
 	}
 
@@ -91,6 +188,16 @@ func (w *QueueWorker) HandleMessage(ctx context.Context, msg mq.Message) error {
 	return nil
 }
 
+// skipCompleted acknowledges a message whose submission another delivery has
+// completed meanwhile, and passes any other error on.
+func (w *QueueWorker) skipCompleted(submission *TicketSubmission, err error) error {
+	if !errors.Is(err, ErrSubmissionCompleted) {
+		return err
+	}
+	log.Printf("ticket worker: submission %s already completed, skipping", submission.ID)
+	return nil
+}
+
 // RunConsumer starts the provided consumer using the worker handler.
 func (w *QueueWorker) RunConsumer(ctx context.Context, consumer *mq.Consumer) error {
 	if consumer == nil {
@@ -0,0 +1,74 @@
+package ticket
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/pflow/components/outbox"
+	"github.com/pflow/shared/mq"
+)
+
+// racingStore replays a delivery that read the submission before another one
+// completed it: lookups return the submission as first read and the processing
+// mark is not written.
+type racingStore struct {
+	SubmissionStore
+	first *TicketSubmission
+}
+
+func (s *racingStore) FindByID(ctx context.Context, id string) (*TicketSubmission, error) {
+	if s.first == nil {
+		submission, err := s.SubmissionStore.FindByID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		s.first = submission
+	}
+	copied := *s.first
+	return &copied, nil
+}
+
+func (s *racingStore) SaveUnlessCompleted(ctx context.Context, submission *TicketSubmission) error {
+	if submission.Status == SubmissionProcessing {
+		return nil
+	}
+	return s.SubmissionStore.SaveUnlessCompleted(ctx, submission)
+}
+
+func TestRacingDeliveriesCreateOneTicket(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	submissions := NewSubmissionRepository(db)
+	submission := &TicketSubmission{
+		Status:         SubmissionPending,
+		RequestPayload: map[string]any{"title": "Printer jam", "formId": "3f1c2a4e-8b7d-4c6e-9a5f-1e2d3c4b5a69"},
+	}
+	if err := submissions.Create(ctx, submission); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	store := &racingStore{SubmissionStore: submissions}
+	worker := NewQueueWorker(store, NewGormRepository(db), WithWorkerEvents(NewEventOutbox(db)))
+	message, _ := json.Marshal(submissionMessage{SubmissionID: submission.ID, TenantID: "default"})
+	for i := 0; i < 2; i++ {
+		if err := worker.HandleMessage(ctx, mq.Message{Value: message}); err != nil {
+			t.Fatalf("delivery %d: %v", i+1, err)
+		}
+	}
+
+	var tickets, events int64
+	db.Model(&Ticket{}).Count(&tickets)
+	db.Model(&outbox.Message{}).Where("topic = ?", OutboxEvents).Count(&events)
+	if tickets != 1 || events != 1 {
+		t.Fatalf("expected one ticket and one event, got %d and %d", tickets, events)
+	}
+	completed, err := submissions.FindByID(ctx, submission.ID)
+	if err != nil || completed.Status != SubmissionCompleted || completed.TicketID == nil {
+		t.Fatalf("expected the first delivery's completion to stand, got %+v (%v)", completed, err)
+	}
+	var ticket Ticket
+	if err := db.First(&ticket).Error; err != nil || ticket.ID != *completed.TicketID {
+		t.Fatalf("expected the submission to point at the only ticket, got %s (%v)", ticket.ID, err)
+	}
+}
@@ -0,0 +1,480 @@
+package workflow
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "strings"
+)
+
+// StepType enumerates the node kinds the execution engine understands.
+type StepType string
+
+const (
+    StepUserTask         StepType = "userTask"
+    StepServiceTask      StepType = "serviceTask"
+    StepExclusiveGateway StepType = "exclusiveGateway"
+    StepParallelGateway  StepType = "parallelGateway"
+    StepEnd              StepType = "end"
+)
+
+// stepTypeAliases maps the step types emitted by the WorkflowDesigner onto engine node kinds.
+var stepTypeAliases = map[string]StepType{
+    "usertask":          StepUserTask,
+    "user_task":         StepUserTask,
+    "form":              StepUserTask,
+    "approval":          StepUserTask,
+    "servicetask":       StepServiceTask,
+    "service_task":      StepServiceTask,
+    "automation":        StepServiceTask,
+    "notification":      StepServiceTask,
+    "exclusivegateway":  StepExclusiveGateway,
+    "exclusive_gateway": StepExclusiveGateway,
+    "gateway":           StepExclusiveGateway,
+    "parallelgateway":   StepParallelGateway,
+    "parallel_gateway":  StepParallelGateway,
+    "parallel":          StepParallelGateway,
+    "end":               StepEnd,
+}
+
+// ErrInvalidBlueprint is returned when a blueprint cannot be executed.
+var ErrInvalidBlueprint = errors.New("invalid workflow blueprint")
+
+// Condition routes an exclusive gateway to Next when the variable comparison holds.
+// Supported operators are eq, ne, gt, gte, lt, lte and exists.
+type Condition struct {
+    Variable string `json:"variable"`
+    Operator string `json:"operator"`
+    Value    any    `json:"value,omitempty"`
+    Next     string `json:"next"`
+}
+
+// Step is a single node of a blueprint. Steps without an explicit Next continue with
+// the following step in the list; the last step implicitly ends its branch. Exclusive
+// gateways route through Conditions and Default, parallel gateways fork into every
+// entry of Branches and join by waiting for all of their incoming edges.
+type Step struct {
+    ID         string      `json:"id"`
+    Name       string      `json:"name,omitempty"`
+    Type       StepType    `json:"type"`
+    Next       string      `json:"next,omitempty"`
+    Assignee   string      `json:"assignee,omitempty"`
+    Handler    string      `json:"handler,omitempty"`
+    Conditions []Condition `json:"conditions,omitempty"`
+    Default    string      `json:"default,omitempty"`
+    Branches   []string    `json:"branches,omitempty"`
+}
+
+// Blueprint is the typed representation of Definition.Blueprint. Start names the
+// first step and defaults to the first entry of Steps.
+type Blueprint struct {
+    Start string `json:"start,omitempty"`
+    Steps []Step `json:"steps"`
+
+    index map[string]int
+}
+
+// Blueprint issue codes reported by ValidateBlueprint.
+const (
+    IssueMalformed          = "malformed"
+    IssueNoSteps            = "no_steps"
+    IssueMissingID          = "missing_id"
+    IssueDuplicateID        = "duplicate_id"
+    IssueUnknownType        = "unknown_type"
+    IssueInvalidStart       = "invalid_start"
+    IssueDanglingEdge       = "dangling_edge"
+    IssueInvalidEdge        = "invalid_edge"
+    IssueGatewayWithoutExit = "gateway_without_exit"
+    IssueInvalidCondition   = "invalid_condition"
+    IssueUnreachable        = "unreachable"
+    IssueNoPathToEnd        = "no_path_to_end"
+)
+
+// BlueprintIssue is a single structural problem of a blueprint.
+type BlueprintIssue struct {
+    NodeID  string `json:"nodeId,omitempty"`
+    Code    string `json:"code"`
+    Message string `json:"message"`
+}
+
+// BlueprintError lists every issue found in a blueprint. It matches ErrInvalidBlueprint
+// with errors.Is.
+type BlueprintError struct {
+    Issues []BlueprintIssue
+}
+
+func (e *BlueprintError) Error() string {
+    parts := make([]string, 0, len(e.Issues))
+    for _, issue := range e.Issues {
+        if issue.NodeID != "" {
+            parts = append(parts, fmt.Sprintf("%s: %s", issue.NodeID, issue.Message))
+        } else {
+            parts = append(parts, issue.Message)
+        }
+    }
+    return fmt.Sprintf("%v: %s", ErrInvalidBlueprint, strings.Join(parts, "; "))
+}
+
+// Unwrap lets errors.Is match ErrInvalidBlueprint.
+func (e *BlueprintError) Unwrap() error {
+    return ErrInvalidBlueprint
+}
+
+var conditionOperators = map[string]bool{
+    "":       false,
+    "eq":     false,
+    "ne":     false,
+    "exists": false,
+    "gt":     true,
+    "gte":    true,
+    "lt":     true,
+    "lte":    true,
+}
+
+// ParseBlueprint decodes a stored blueprint document, normalises step types and
+// returns a *BlueprintError when the blueprint is not structurally sound.
+func ParseBlueprint(raw map[string]any) (Blueprint, error) {
+    blueprint, issues := decodeBlueprint(raw)
+    if len(issues) == 0 {
+        issues = blueprint.validate()
+    }
+    if len(issues) > 0 {
+        return Blueprint{}, &BlueprintError{Issues: issues}
+    }
+    return blueprint, nil
+}
+
+// ValidateBlueprint reports every structural issue of a blueprint document: missing
+// or duplicate step IDs, unknown step types, a missing start step, dangling edges,
+// gateways without exits, malformed conditions, unreachable steps and steps that
+// cannot reach an end. An empty result means the blueprint can be executed.
+func ValidateBlueprint(raw map[string]any) []BlueprintIssue {
+    blueprint, issues := decodeBlueprint(raw)
+    if len(issues) > 0 {
+        return issues
+    }
+    return blueprint.validate()
+}
+
+func decodeBlueprint(raw map[string]any) (Blueprint, []BlueprintIssue) {
+    var blueprint Blueprint
+    encoded, err := json.Marshal(raw)
+    if err == nil {
+        err = json.Unmarshal(encoded, &blueprint)
+    }
+    if err != nil {
+        return Blueprint{}, []BlueprintIssue{{Code: IssueMalformed, Message: err.Error()}}
+    }
+    if len(blueprint.Steps) == 0 {
+        return Blueprint{}, []BlueprintIssue{{Code: IssueNoSteps, Message: "blueprint has no steps"}}
+    }
+
+    var issues []BlueprintIssue
+    blueprint.Start = strings.TrimSpace(blueprint.Start)
+    blueprint.index = make(map[string]int, len(blueprint.Steps))
+    for i := range blueprint.Steps {
+        step := &blueprint.Steps[i]
+        step.ID = strings.TrimSpace(step.ID)
+        if step.ID == "" {
+            issues = append(issues, BlueprintIssue{Code: IssueMissingID, Message: fmt.Sprintf("step %d has no id", i+1)})
+            continue
+        }
+        if _, exists := blueprint.index[step.ID]; exists {
+            issues = append(issues, BlueprintIssue{NodeID: step.ID, Code: IssueDuplicateID, Message: "duplicate step id"})
+            continue
+        }
+        blueprint.index[step.ID] = i
+
+        kind, ok := stepTypeAliases[strings.ToLower(strings.TrimSpace(string(step.Type)))]
+        if !ok {
+            issues = append(issues, BlueprintIssue{NodeID: step.ID, Code: IssueUnknownType, Message: fmt.Sprintf("unsupported step type %q", step.Type)})
+            continue
+        }
+        step.Type = kind
+    }
+    return blueprint, issues
+}
+
+// validate checks edges, conditions and reachability of a decoded blueprint.
+func (b Blueprint) validate() []BlueprintIssue {
+    var issues []BlueprintIssue
+    report := func(nodeID, code, format string, args ...any) {
+        issues = append(issues, BlueprintIssue{NodeID: nodeID, Code: code, Message: fmt.Sprintf(format, args...)})
+    }
+
+    if b.Start != "" {
+        if _, ok := b.index[b.Start]; !ok {
+            report(b.Start, IssueInvalidStart, "start step does not exist")
+            return issues
+        }
+    }
+
+    for _, step := range b.Steps {
+        switch step.Type {
+        case StepEnd:
+            if step.Next != "" {
+                report(step.ID, IssueInvalidEdge, "end steps cannot have a next step")
+            }
+        case StepExclusiveGateway:
+            if step.Next != "" {
+                report(step.ID, IssueInvalidEdge, "gateways route through conditions and default, not next")
+            }
+            if len(step.Conditions) == 0 && step.Default == "" {
+                report(step.ID, IssueGatewayWithoutExit, "gateway needs at least one condition or a default")
+            }
+            for i, condition := range step.Conditions {
+                if strings.TrimSpace(condition.Variable) == "" {
+                    report(step.ID, IssueInvalidCondition, "condition %d has no variable", i+1)
+                }
+                numeric, known := conditionOperators[strings.ToLower(strings.TrimSpace(condition.Operator))]
+                if !known {
+                    report(step.ID, IssueInvalidCondition, "condition %d uses unsupported operator %q", i+1, condition.Operator)
+                } else if numeric {
+                    if _, ok := toNumber(condition.Value); !ok {
+                        report(step.ID, IssueInvalidCondition, "condition %d compares %q with a non-numeric value", i+1, condition.Operator)
+                    }
+                }
+                if condition.Next == "" {
+                    report(step.ID, IssueDanglingEdge, "condition %d has no target step", i+1)
+                } else if _, ok := b.index[condition.Next]; !ok {
+                    report(step.ID, IssueDanglingEdge, "condition %d points to unknown step %q", i+1, condition.Next)
+                }
+            }
+            if step.Default != "" {
+                if _, ok := b.index[step.Default]; !ok {
+                    report(step.ID, IssueDanglingEdge, "default points to unknown step %q", step.Default)
+                }
+            }
+        case StepParallelGateway:
+            if len(step.Conditions) > 0 || step.Default != "" {
+                report(step.ID, IssueInvalidEdge, "parallel gateways take every branch and cannot have conditions")
+            }
+            if len(step.Branches) > 0 && step.Next != "" {
+                report(step.ID, IssueInvalidEdge, "parallel gateways use either branches or next")
+            }
+            for _, branch := range step.Branches {
+                if _, ok := b.index[branch]; !ok {
+                    report(step.ID, IssueDanglingEdge, "branch points to unknown step %q", branch)
+                }
+            }
+            if step.Next != "" {
+                if _, ok := b.index[step.Next]; !ok {
+                    report(step.ID, IssueDanglingEdge, "next points to unknown step %q", step.Next)
+                }
+            }
+        default:
+            if len(step.Branches) > 0 {
+                report(step.ID, IssueInvalidEdge, "only parallel gateways can have branches")
+            }
+            if step.Next != "" {
+                if _, ok := b.index[step.Next]; !ok {
+                    report(step.ID, IssueDanglingEdge, "next points to unknown step %q", step.Next)
+                }
+            }
+        }
+    }
+    if len(issues) > 0 {
+        return issues
+    }
+
+    reachable := map[string]bool{}
+    pending := []string{b.first().ID}
+    for len(pending) > 0 {
+        id := pending[len(pending)-1]
+        pending = pending[:len(pending)-1]
+        if reachable[id] {
+            continue
+        }
+        reachable[id] = true
+        step, _ := b.Step(id)
+        pending = append(pending, b.targets(step)...)
+    }
+
+    // Walk backwards from the steps that end the process to find those that can finish.
+    incoming := map[string][]string{}
+    var finishing []string
+    for _, step := range b.Steps {
+        targets := b.targets(step)
+        if step.Type == StepEnd || (step.Type != StepExclusiveGateway && len(targets) == 0) {
+            finishing = append(finishing, step.ID)
+        }
+        for _, target := range targets {
+            incoming[target] = append(incoming[target], step.ID)
+        }
+    }
+    canFinish := map[string]bool{}
+    for len(finishing) > 0 {
+        id := finishing[len(finishing)-1]
+        finishing = finishing[:len(finishing)-1]
+        if canFinish[id] {
+            continue
+        }
+        canFinish[id] = true
+        finishing = append(finishing, incoming[id]...)
+    }
+
+    for _, step := range b.Steps {
+        switch {
+        case !reachable[step.ID]:
+            report(step.ID, IssueUnreachable, "step cannot be reached from the start step")
+        case !canFinish[step.ID]:
+            report(step.ID, IssueNoPathToEnd, "step has no path to an end of the process")
+        }
+    }
+    return issues
+}
+
+// targets lists the steps a step can continue with.
+func (b Blueprint) targets(step Step) []string {
+    switch step.Type {
+    case StepEnd:
+        return nil
+    case StepExclusiveGateway:
+        targets := make([]string, 0, len(step.Conditions)+1)
+        for _, condition := range step.Conditions {
+            targets = append(targets, condition.Next)
+        }
+        if step.Default != "" {
+            targets = append(targets, step.Default)
+        }
+        return targets
+    case StepParallelGateway:
+        if len(step.Branches) > 0 {
+            return step.Branches
+        }
+    }
+    if step.Next != "" {
+        return []string{step.Next}
+    }
+    if i := b.index[step.ID]; i+1 < len(b.Steps) {
+        return []string{b.Steps[i+1].ID}
+    }
+    return nil
+}
+
+// incoming counts the edges that lead into a step.
+func (b Blueprint) incoming(id string) int {
+    count := 0
+    for _, step := range b.Steps {
+        for _, target := range b.targets(step) {
+            if target == id {
+                count++
+            }
+        }
+    }
+    return count
+}
+
+// first returns the step execution starts with.
+func (b Blueprint) first() Step {
+    if b.Start != "" {
+        if step, ok := b.Step(b.Start); ok {
+            return step
+        }
+    }
+    return b.Steps[0]
+}
+
+// Step looks up a step by ID.
+func (b Blueprint) Step(id string) (Step, bool) {
+    i, ok := b.index[id]
+    if !ok {
+        return Step{}, false
+    }
+    return b.Steps[i], true
+}
+
+// following returns the steps a non-routing step continues with. An empty result means
+// the branch ends after step.
+func (b Blueprint) following(step Step) []Step {
+    targets := b.targets(step)
+    steps := make([]Step, 0, len(targets))
+    for _, id := range targets {
+        if next, ok := b.Step(id); ok {
+            steps = append(steps, next)
+        }
+    }
+    return steps
+}
+
+// route picks the outgoing step of an exclusive gateway for the given variables.
+func (b Blueprint) route(step Step, variables map[string]any) (Step, error) {
+    target := step.Default
+    for _, condition := range step.Conditions {
+        matched, err := condition.matches(variables)
+        if err != nil {
+            return Step{}, fmt.Errorf("gateway %q: %w", step.ID, err)
+        }
+        if matched {
+            target = condition.Next
+            break
+        }
+    }
+    if target == "" {
+        return Step{}, fmt.Errorf("gateway %q: no condition matched and no default is set", step.ID)
+    }
+    next, ok := b.Step(target)
+    if !ok {
+        return Step{}, fmt.Errorf("%w: gateway %q points to unknown step %q", ErrInvalidBlueprint, step.ID, target)
+    }
+    return next, nil
+}
+
+func (c Condition) matches(variables map[string]any) (bool, error) {
+    actual, present := variables[c.Variable]
+    operator := strings.ToLower(strings.TrimSpace(c.Operator))
+    switch operator {
+    case "exists":
+        return present && actual != nil, nil
+    case "", "eq":
+        return present && equalValues(actual, c.Value), nil
+    case "ne":
+        return !present || !equalValues(actual, c.Value), nil
+    case "gt", "gte", "lt", "lte":
+        if !present {
+            return false, nil
+        }
+        left, ok := toNumber(actual)
+        right, ok2 := toNumber(c.Value)
+        if !ok || !ok2 {
+            return false, fmt.Errorf("operator %s requires numeric values for %q", operator, c.Variable)
+        }
+        switch operator {
+        case "gt":
+            return left > right, nil
+        case "gte":
+            return left >= right, nil
+        case "lt":
+            return left < right, nil
+        default:
+            return left <= right, nil
+        }
+    }
+    return false, fmt.Errorf("unsupported operator %q", c.Operator)
+}
+
+func equalValues(left, right any) bool {
+    if l, ok := toNumber(left); ok {
+        if r, ok := toNumber(right); ok {
+            return l == r
+        }
+    }
+    return fmt.Sprint(left) == fmt.Sprint(right)
+}
+
+func toNumber(value any) (float64, bool) {
+    switch v := value.(type) {
+    case float64:
+        return v, true
+    case float32:
+        return float64(v), true
+    case int:
+        return float64(v), true
+    case int64:
+        return float64(v), true
+    case json.Number:
+        parsed, err := v.Float64()
+        return parsed, err == nil
+    }
+    return 0, false
+}
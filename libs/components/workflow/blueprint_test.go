@@ -0,0 +1,111 @@
+package workflow
+
+import (
+    "errors"
+    "testing"
+)
+
+func issueCodes(issues []BlueprintIssue) map[string]string {
+    codes := make(map[string]string, len(issues))
+    for _, issue := range issues {
+        codes[issue.NodeID+"/"+issue.Code] = issue.Message
+    }
+    return codes
+}
+
+func TestValidateBlueprintAcceptsDesignerSteps(t *testing.T) {
+    issues := ValidateBlueprint(map[string]any{
+        "steps": []any{
+            map[string]any{"id": "1", "type": "form", "name": "表单填写"},
+            map[string]any{"id": "2", "type": "approval"},
+            map[string]any{"id": "3", "type": "notification"},
+        },
+    })
+    if len(issues) != 0 {
+        t.Fatalf("expected no issues, got %+v", issues)
+    }
+}
+
+func TestValidateBlueprintReportsStructuralIssues(t *testing.T) {
+    cases := []struct {
+        name      string
+        blueprint map[string]any
+        want      []string
+    }{
+        {
+            name:      "no steps",
+            blueprint: map[string]any{},
+            want:      []string{"/" + IssueNoSteps},
+        },
+        {
+            name: "ids and types",
+            blueprint: map[string]any{"steps": []any{
+                map[string]any{"id": "a", "type": "userTask"},
+                map[string]any{"id": "a", "type": "userTask"},
+                map[string]any{"type": "end"},
+                map[string]any{"id": "b", "type": "timer"},
+            }},
+            want: []string{"a/" + IssueDuplicateID, "/" + IssueMissingID, "b/" + IssueUnknownType},
+        },
+        {
+            name: "missing start",
+            blueprint: map[string]any{"start": "ghost", "steps": []any{
+                map[string]any{"id": "a", "type": "userTask"},
+            }},
+            want: []string{"ghost/" + IssueInvalidStart},
+        },
+        {
+            name: "edges and conditions",
+            blueprint: map[string]any{"steps": []any{
+                map[string]any{"id": "a", "type": "userTask", "next": "missing"},
+                map[string]any{"id": "g", "type": "exclusiveGateway", "conditions": []any{
+                    map[string]any{"variable": "amount", "operator": "gt", "value": "lots", "next": "e"},
+                    map[string]any{"operator": "between", "next": "e"},
+                }},
+                map[string]any{"id": "h", "type": "gateway"},
+                map[string]any{"id": "e", "type": "end", "next": "a"},
+            }},
+            want: []string{
+                "a/" + IssueDanglingEdge,
+                "g/" + IssueInvalidCondition,
+                "h/" + IssueGatewayWithoutExit,
+                "e/" + IssueInvalidEdge,
+            },
+        },
+        {
+            name: "reachability",
+            blueprint: map[string]any{"steps": []any{
+                map[string]any{"id": "a", "type": "userTask", "next": "loop"},
+                map[string]any{"id": "orphan", "type": "serviceTask", "next": "end"},
+                map[string]any{"id": "loop", "type": "serviceTask", "next": "a"},
+                map[string]any{"id": "end", "type": "end"},
+            }},
+            want: []string{"orphan/" + IssueUnreachable, "a/" + IssueNoPathToEnd, "loop/" + IssueNoPathToEnd},
+        },
+    }
+
+    for _, tc := range cases {
+        t.Run(tc.name, func(t *testing.T) {
+            codes := issueCodes(ValidateBlueprint(tc.blueprint))
+            for _, want := range tc.want {
+                if _, ok := codes[want]; !ok {
+                    t.Errorf("expected issue %s, got %v", want, codes)
+                }
+            }
+        })
+    }
+}
+
+func TestParseBlueprintWrapsIssues(t *testing.T) {
+    _, err := ParseBlueprint(map[string]any{"steps": []any{
+        map[string]any{"id": "a", "type": "userTask", "next": "missing"},
+    }})
+
+    var invalid *BlueprintError
+    if !errors.As(err, &invalid) || !errors.Is(err, ErrInvalidBlueprint) {
+        t.Fatalf("expected BlueprintError wrapping ErrInvalidBlueprint, got %v", err)
+    }
+    if len(invalid.Issues) != 1 || invalid.Issues[0].Code != IssueDanglingEdge {
+        t.Fatalf("unexpected issues %+v", invalid.Issues)
+    }
+}
@@ -0,0 +1,712 @@
+package workflow
+
+import (
+    "bytes"
+    "encoding/json"
+    "encoding/xml"
+    "errors"
+    "fmt"
+    "regexp"
+    "strconv"
+    "strings"
+)
+
+// Namespaces written by ExportBPMN. Service task handlers and user task assignees are
+// stored as Zeebe extension elements so diagrams open in Camunda Modeler unchanged.
+const (
+    bpmnModelNamespace = "http://www.omg.org/spec/BPMN/20100524/MODEL"
+    bpmnDINamespace    = "http://www.omg.org/spec/BPMN/20100524/DI"
+    dcNamespace        = "http://www.omg.org/spec/DD/20100524/DC"
+    diNamespace        = "http://www.omg.org/spec/DD/20100524/DI"
+    zeebeNamespace     = "http://camunda.org/schema/zeebe/1.0"
+    xsiNamespace       = "http://www.w3.org/2001/XMLSchema-instance"
+)
+
+// ErrInvalidBPMN is returned when a BPMN document cannot be mapped onto a blueprint.
+var ErrInvalidBPMN = errors.New("invalid BPMN document")
+
+// BPMNProcess is the result of importing a BPMN document.
+type BPMNProcess struct {
+    Name          string
+    Documentation string
+    Blueprint     Blueprint
+}
+
+// ExportBPMN renders a definition as a BPMN 2.0 XML document with a start event,
+// user and service tasks, exclusive and parallel gateways, end events and sequence
+// flows carrying FEEL conditions. Steps that implicitly end their branch get a
+// generated end event.
+func ExportBPMN(definition Definition) ([]byte, error) {
+    blueprint, err := ParseBlueprint(definition.Blueprint)
+    if err != nil {
+        return nil, err
+    }
+
+    ids := newBPMNIDs(BPMNProcessID(definition.ID), blueprint)
+    process := xmlOutProcess{
+        ID:           BPMNProcessID(definition.ID),
+        Name:         definition.Name,
+        IsExecutable: true,
+    }
+    if definition.Description != "" {
+        process.Documentation = &xmlOutText{Text: definition.Description}
+    }
+
+    flows := &flowWriter{process: &process}
+    startID := ids.unique("StartEvent_1")
+    process.StartEvents = append(process.StartEvents, xmlOutNode{ID: startID})
+    flows.add(startID, ids.step(blueprint.first().ID), nil)
+
+    for _, step := range blueprint.Steps {
+        id := ids.step(step.ID)
+        node := xmlOutNode{ID: id, Name: step.Name}
+        switch step.Type {
+        case StepUserTask:
+            if step.Assignee != "" {
+                node.Extensions = &xmlOutExtensions{Assignment: &xmlOutAssignment{Assignee: step.Assignee}}
+            }
+            process.UserTasks = append(process.UserTasks, node)
+        case StepServiceTask:
+            if step.Handler != "" {
+                node.Extensions = &xmlOutExtensions{TaskDefinition: &xmlOutTaskDefinition{Type: step.Handler}}
+            }
+            process.ServiceTasks = append(process.ServiceTasks, node)
+        case StepEnd:
+            process.EndEvents = append(process.EndEvents, node)
+            continue
+        case StepExclusiveGateway:
+            for _, condition := range step.Conditions {
+                expression, err := conditionToFEEL(condition)
+                if err != nil {
+                    return nil, fmt.Errorf("gateway %q: %w", step.ID, err)
+                }
+                flows.add(id, ids.step(condition.Next), &xmlOutCondition{Type: "bpmn:tFormalExpression", Text: expression})
+            }
+            if step.Default != "" {
+                node.Default = flows.add(id, ids.step(step.Default), nil)
+            }
+            process.ExclusiveGateways = append(process.ExclusiveGateways, node)
+            continue
+        case StepParallelGateway:
+            process.ParallelGateways = append(process.ParallelGateways, node)
+        }
+
+        targets := blueprint.targets(step)
+        if len(targets) == 0 {
+            endID := ids.unique(id + "_end")
+            process.EndEvents = append(process.EndEvents, xmlOutNode{ID: endID})
+            flows.add(id, endID, nil)
+            continue
+        }
+        for _, target := range targets {
+            flows.add(id, ids.step(target), nil)
+        }
+    }
+
+    process.link(flows.flows)
+    document := xmlOutDefinitions{
+        XMLNSBPMN:       bpmnModelNamespace,
+        XMLNSBPMNDI:     bpmnDINamespace,
+        XMLNSDC:         dcNamespace,
+        XMLNSDI:         diNamespace,
+        XMLNSZeebe:      zeebeNamespace,
+        XMLNSXSI:        xsiNamespace,
+        ID:              "Definitions_" + process.ID,
+        TargetNamespace: "http://bpmn.io/schema/bpmn",
+        Exporter:        "pflow",
+        Process:         process,
+        Diagram:         layoutDiagram(process, flows.flows),
+    }
+
+    var buf bytes.Buffer
+    buf.WriteString(xml.Header)
+    encoder := xml.NewEncoder(&buf)
+    encoder.Indent("", "  ")
+    if err := encoder.Encode(document); err != nil {
+        return nil, err
+    }
+    buf.WriteByte('\n')
+    return buf.Bytes(), nil
+}
+
+// BPMNProcessID returns the BPMN process ID a definition is exported under. It is
+// stable across versions so process engines treat every deployment as a new version
+// of the same process.
+func BPMNProcessID(definitionID string) string {
+    return "Process_" + strings.ReplaceAll(definitionID, "-", "")
+}
+
+// ImportBPMN maps the first executable process of a BPMN 2.0 document onto a
+// blueprint. Element IDs become step IDs; diagram interchange data is ignored.
+func ImportBPMN(data []byte) (*BPMNProcess, error) {
+    var document xmlInDefinitions
+    if err := xml.Unmarshal(data, &document); err != nil {
+        return nil, fmt.Errorf("%w: %v", ErrInvalidBPMN, err)
+    }
+    if len(document.Processes) == 0 {
+        return nil, fmt.Errorf("%w: no process element", ErrInvalidBPMN)
+    }
+    process := document.Processes[0]
+    for _, candidate := range document.Processes {
+        if candidate.IsExecutable {
+            process = candidate
+            break
+        }
+    }
+    if names := process.unsupported(); len(names) > 0 {
+        return nil, fmt.Errorf("%w: unsupported elements %s", ErrInvalidBPMN, strings.Join(names, ", "))
+    }
+    if len(process.StartEvents) != 1 {
+        return nil, fmt.Errorf("%w: expected exactly one start event, found %d", ErrInvalidBPMN, len(process.StartEvents))
+    }
+
+    outgoing := map[string][]xmlInFlow{}
+    for _, flow := range process.Flows {
+        outgoing[flow.Source] = append(outgoing[flow.Source], flow)
+    }
+
+    start := process.StartEvents[0]
+    if len(outgoing[start.ID]) != 1 {
+        return nil, fmt.Errorf("%w: start event %q must have exactly one outgoing flow", ErrInvalidBPMN, start.ID)
+    }
+
+    steps := make([]Step, 0, len(process.elements()))
+    ended := map[string]bool{}
+    for _, element := range process.elements() {
+        step := Step{ID: element.node.ID, Name: element.node.Name, Type: element.kind}
+        flows := outgoing[step.ID]
+        switch element.kind {
+        case StepUserTask:
+            if element.node.Extensions.Assignment.Assignee != "" {
+                step.Assignee = element.node.Extensions.Assignment.Assignee
+            } else {
+                step.Assignee = element.node.Assignee
+            }
+        case StepServiceTask:
+            step.Handler = element.node.Extensions.TaskDefinition.Type
+        case StepEnd:
+            if len(flows) > 0 {
+                return nil, fmt.Errorf("%w: end event %q has outgoing flows", ErrInvalidBPMN, step.ID)
+            }
+            steps = append(steps, step)
+            continue
+        case StepExclusiveGateway:
+            for _, flow := range flows {
+                switch {
+                case flow.ID == element.node.Default:
+                    step.Default = flow.Target
+                case strings.TrimSpace(flow.Condition.Text) != "":
+                    condition, err := conditionFromFEEL(flow.Condition.Text)
+                    if err != nil {
+                        return nil, fmt.Errorf("%w: flow %q: %v", ErrInvalidBPMN, flow.ID, err)
+                    }
+                    condition.Next = flow.Target
+                    step.Conditions = append(step.Conditions, condition)
+                case len(flows) == 1:
+                    step.Default = flow.Target
+                default:
+                    return nil, fmt.Errorf("%w: flow %q leaves gateway %q without a condition", ErrInvalidBPMN, flow.ID, step.ID)
+                }
+            }
+            steps = append(steps, step)
+            continue
+        case StepParallelGateway:
+            for _, flow := range flows {
+                step.Branches = append(step.Branches, flow.Target)
+            }
+            steps = append(steps, step)
+            continue
+        }
+
+        switch len(flows) {
+        case 0:
+            ended[step.ID] = true
+        case 1:
+            step.Next = flows[0].Target
+        default:
+            return nil, fmt.Errorf("%w: %q has several outgoing flows; use a gateway to split", ErrInvalidBPMN, step.ID)
+        }
+        steps = append(steps, step)
+    }
+
+    // Tasks without outgoing flows end their branch; make that explicit so the
+    // implicit "continue with the next step" rule of blueprints does not apply.
+    for i := range steps {
+        if ended[steps[i].ID] {
+            endID := steps[i].ID + "_end"
+            steps[i].Next = endID
+            steps = append(steps, Step{ID: endID, Type: StepEnd})
+        }
+    }
+
+    blueprint := Blueprint{Start: outgoing[start.ID][0].Target, Steps: steps}
+    raw, err := blueprint.toMap()
+    if err != nil {
+        return nil, err
+    }
+    parsed, err := ParseBlueprint(raw)
+    if err != nil {
+        return nil, err
+    }
+
+    return &BPMNProcess{
+        Name:          strings.TrimSpace(process.Name),
+        Documentation: strings.TrimSpace(process.Documentation),
+        Blueprint:     parsed,
+    }, nil
+}
+
+// toMap converts the blueprint into the document stored in Definition.Blueprint.
+func (b Blueprint) toMap() (map[string]any, error) {
+    encoded, err := json.Marshal(b)
+    if err != nil {
+        return nil, err
+    }
+    var raw map[string]any
+    if err := json.Unmarshal(encoded, &raw); err != nil {
+        return nil, err
+    }
+    return raw, nil
+}
+
+var (
+    ncNamePattern       = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+    nonNCNameChars      = regexp.MustCompile(`[^A-Za-z0-9_.-]`)
+    comparisonPattern   = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_.]*)\s*(!=|>=|<=|=|>|<)\s*(.+)$`)
+    existsPattern       = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_.]*)\s*!=\s*null$`)
+    isDefinedPattern    = regexp.MustCompile(`^is defined\(\s*([A-Za-z_][A-Za-z0-9_.]*)\s*\)$`)
+    feelOperators       = map[string]string{"eq": "=", "": "=", "ne": "!=", "gt": ">", "gte": ">=", "lt": "<", "lte": "<="}
+    feelOperatorsByText = map[string]string{"=": "eq", "!=": "ne", ">": "gt", ">=": "gte", "<": "lt", "<=": "lte"}
+)
+
+// conditionToFEEL renders a condition as a FEEL expression such as "=amount > 1000".
+func conditionToFEEL(condition Condition) (string, error) {
+    operator := strings.ToLower(strings.TrimSpace(condition.Operator))
+    if operator == "exists" {
+        return fmt.Sprintf("=%s != null", condition.Variable), nil
+    }
+    symbol, ok := feelOperators[operator]
+    if !ok {
+        return "", fmt.Errorf("unsupported operator %q", condition.Operator)
+    }
+
+    var literal string
+    switch value := condition.Value.(type) {
+    case string:
+        literal = strconv.Quote(value)
+    case bool:
+        literal = strconv.FormatBool(value)
+    case nil:
+        literal = "null"
+    default:
+        number, ok := toNumber(value)
+        if !ok {
+            return "", fmt.Errorf("cannot express value %v in FEEL", value)
+        }
+        literal = strconv.FormatFloat(number, 'f', -1, 64)
+    }
+    return fmt.Sprintf("=%s %s %s", condition.Variable, symbol, literal), nil
+}
+
+// conditionFromFEEL parses the subset of FEEL written by conditionToFEEL.
+func conditionFromFEEL(expression string) (Condition, error) {
+    text := strings.TrimSpace(expression)
+    text = strings.TrimSpace(strings.TrimPrefix(text, "="))
+    text = strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(text, "${"), "}"))
+
+    if match := existsPattern.FindStringSubmatch(text); match != nil {
+        return Condition{Variable: match[1], Operator: "exists"}, nil
+    }
+    if match := isDefinedPattern.FindStringSubmatch(text); match != nil {
+        return Condition{Variable: match[1], Operator: "exists"}, nil
+    }
+    match := comparisonPattern.FindStringSubmatch(text)
+    if match == nil {
+        return Condition{}, fmt.Errorf("unsupported condition expression %q", expression)
+    }
+    if match[2] == "=" && strings.HasPrefix(match[3], "=") {
+        return Condition{}, fmt.Errorf("unsupported condition expression %q", expression)
+    }
+
+    condition := Condition{Variable: match[1], Operator: feelOperatorsByText[match[2]]}
+    literal := strings.TrimSpace(match[3])
+    switch {
+    case strings.HasPrefix(literal, `"`):
+        value, err := strconv.Unquote(literal)
+        if err != nil {
+            return Condition{}, fmt.Errorf("invalid string literal %s", literal)
+        }
+        condition.Value = value
+    case literal == "true" || literal == "false":
+        condition.Value = literal == "true"
+    case literal == "null":
+        condition.Value = nil
+    default:
+        number, err := strconv.ParseFloat(literal, 64)
+        if err != nil {
+            return Condition{}, fmt.Errorf("unsupported literal %s", literal)
+        }
+        condition.Value = number
+    }
+    return condition, nil
+}
+
+// bpmnIDs maps step IDs onto valid, unique XML IDs.
+type bpmnIDs struct {
+    steps map[string]string
+    used  map[string]bool
+}
+
+func newBPMNIDs(processID string, blueprint Blueprint) *bpmnIDs {
+    ids := &bpmnIDs{steps: map[string]string{}, used: map[string]bool{processID: true}}
+    for _, step := range blueprint.Steps {
+        id := step.ID
+        if !ncNamePattern.MatchString(id) {
+            id = "Step_" + nonNCNameChars.ReplaceAllString(id, "_")
+        }
+        ids.steps[step.ID] = ids.unique(id)
+    }
+    return ids
+}
+
+func (ids *bpmnIDs) step(id string) string {
+    return ids.steps[id]
+}
+
+func (ids *bpmnIDs) unique(id string) string {
+    candidate := id
+    for i := 2; ids.used[candidate]; i++ {
+        candidate = fmt.Sprintf("%s_%d", id, i)
+    }
+    ids.used[candidate] = true
+    return candidate
+}
+
+type flowWriter struct {
+    process *xmlOutProcess
+    flows   []xmlOutFlow
+}
+
+func (w *flowWriter) add(source, target string, condition *xmlOutCondition) string {
+    id := fmt.Sprintf("Flow_%d", len(w.flows)+1)
+    w.flows = append(w.flows, xmlOutFlow{ID: id, Source: source, Target: target, Condition: condition})
+    return id
+}
+
+// link records incoming and outgoing flow references on every node and appends the flows.
+func (p *xmlOutProcess) link(flows []xmlOutFlow) {
+    incoming := map[string][]string{}
+    outgoing := map[string][]string{}
+    for _, flow := range flows {
+        outgoing[flow.Source] = append(outgoing[flow.Source], flow.ID)
+        incoming[flow.Target] = append(incoming[flow.Target], flow.ID)
+    }
+    for _, group := range p.groups() {
+        for i := range *group {
+            node := &(*group)[i]
+            node.Incoming = incoming[node.ID]
+            node.Outgoing = outgoing[node.ID]
+        }
+    }
+    p.Flows = flows
+}
+
+func (p *xmlOutProcess) groups() []*[]xmlOutNode {
+    return []*[]xmlOutNode{&p.StartEvents, &p.UserTasks, &p.ServiceTasks, &p.ExclusiveGateways, &p.ParallelGateways, &p.EndEvents}
+}
+
+// layoutDiagram places nodes on a grid in breadth-first order from the start event so
+// the exported diagram renders in modelling tools.
+func layoutDiagram(process xmlOutProcess, flows []xmlOutFlow) *xmlOutDiagram {
+    type box struct{ x, y, width, height float64 }
+    kinds := map[string]string{}
+    for _, node := range process.StartEvents {
+        kinds[node.ID] = "event"
+    }
+    for _, node := range process.EndEvents {
+        kinds[node.ID] = "event"
+    }
+    for _, node := range process.ExclusiveGateways {
+        kinds[node.ID] = "gateway"
+    }
+    for _, node := range process.ParallelGateways {
+        kinds[node.ID] = "gateway"
+    }
+
+    next := map[string][]string{}
+    for _, flow := range flows {
+        next[flow.Source] = append(next[flow.Source], flow.Target)
+    }
+
+    column := map[string]int{}
+    order := []string{}
+    if len(process.StartEvents) > 0 {
+        queue := []string{process.StartEvents[0].ID}
+        column[queue[0]] = 0
+        for len(queue) > 0 {
+            id := queue[0]
+            queue = queue[1:]
+            order = append(order, id)
+            for _, target := range next[id] {
+                if _, seen := column[target]; !seen {
+                    column[target] = column[id] + 1
+                    queue = append(queue, target)
+                }
+            }
+        }
+    }
+    for _, group := range process.groups() {
+        for _, node := range *group {
+            if _, seen := column[node.ID]; !seen {
+                column[node.ID] = 0
+                order = append(order, node.ID)
+            }
+        }
+    }
+
+    rows := map[int]int{}
+    boxes := map[string]box{}
+    diagram := &xmlOutDiagram{ID: "BPMNDiagram_1", Plane: xmlOutPlane{ID: "BPMNPlane_1", Element: process.ID}}
+    for _, id := range order {
+        col := column[id]
+        row := rows[col]
+        rows[col]++
+
+        b := box{width: 100, height: 80}
+        switch kinds[id] {
+        case "event":
+            b.width, b.height = 36, 36
+        case "gateway":
+            b.width, b.height = 50, 50
+        }
+        b.x = 160 + float64(col)*160 + (100-b.width)/2
+        b.y = 80 + float64(row)*130 + (80-b.height)/2
+        boxes[id] = b
+        diagram.Plane.Shapes = append(diagram.Plane.Shapes, xmlOutShape{
+            ID:      id + "_di",
+            Element: id,
+            Bounds:  xmlOutBounds{X: b.x, Y: b.y, Width: b.width, Height: b.height},
+        })
+    }
+    for _, flow := range flows {
+        source, target := boxes[flow.Source], boxes[flow.Target]
+        diagram.Plane.Edges = append(diagram.Plane.Edges, xmlOutEdge{
+            ID:      flow.ID + "_di",
+            Element: flow.ID,
+            Waypoints: []xmlOutWaypoint{
+                {X: source.x + source.width, Y: source.y + source.height/2},
+                {X: target.x, Y: target.y + target.height/2},
+            },
+        })
+    }
+    return diagram
+}
+
+// XML shapes for export. Element names carry explicit prefixes so the output uses the
+// conventional bpmn:, bpmndi:, dc:, di: and zeebe: prefixes.
+type xmlOutDefinitions struct {
+    XMLName         xml.Name       `xml:"bpmn:definitions"`
+    XMLNSBPMN       string         `xml:"xmlns:bpmn,attr"`
+    XMLNSBPMNDI     string         `xml:"xmlns:bpmndi,attr"`
+    XMLNSDC         string         `xml:"xmlns:dc,attr"`
+    XMLNSDI         string         `xml:"xmlns:di,attr"`
+    XMLNSZeebe      string         `xml:"xmlns:zeebe,attr"`
+    XMLNSXSI        string         `xml:"xmlns:xsi,attr"`
+    ID              string         `xml:"id,attr"`
+    TargetNamespace string         `xml:"targetNamespace,attr"`
+    Exporter        string         `xml:"exporter,attr"`
+    Process         xmlOutProcess  `xml:"bpmn:process"`
+    Diagram         *xmlOutDiagram `xml:"bpmndi:BPMNDiagram"`
+}
+
+type xmlOutProcess struct {
+    ID                string       `xml:"id,attr"`
+    Name              string       `xml:"name,attr,omitempty"`
+    IsExecutable      bool         `xml:"isExecutable,attr"`
+    Documentation     *xmlOutText  `xml:"bpmn:documentation"`
+    StartEvents       []xmlOutNode `xml:"bpmn:startEvent"`
+    UserTasks         []xmlOutNode `xml:"bpmn:userTask"`
+    ServiceTasks      []xmlOutNode `xml:"bpmn:serviceTask"`
+    ExclusiveGateways []xmlOutNode `xml:"bpmn:exclusiveGateway"`
+    ParallelGateways  []xmlOutNode `xml:"bpmn:parallelGateway"`
+    EndEvents         []xmlOutNode `xml:"bpmn:endEvent"`
+    Flows             []xmlOutFlow `xml:"bpmn:sequenceFlow"`
+}
+
+type xmlOutText struct {
+    Text string `xml:",chardata"`
+}
+
+type xmlOutNode struct {
+    ID         string            `xml:"id,attr"`
+    Name       string            `xml:"name,attr,omitempty"`
+    Default    string            `xml:"default,attr,omitempty"`
+    Extensions *xmlOutExtensions `xml:"bpmn:extensionElements"`
+    Incoming   []string          `xml:"bpmn:incoming"`
+    Outgoing   []string          `xml:"bpmn:outgoing"`
+}
+
+type xmlOutExtensions struct {
+    TaskDefinition *xmlOutTaskDefinition `xml:"zeebe:taskDefinition"`
+    Assignment     *xmlOutAssignment     `xml:"zeebe:assignmentDefinition"`
+}
+
+type xmlOutTaskDefinition struct {
+    Type string `xml:"type,attr"`
+}
+
+type xmlOutAssignment struct {
+    Assignee string `xml:"assignee,attr"`
+}
+
+type xmlOutFlow struct {
+    ID        string           `xml:"id,attr"`
+    Source    string           `xml:"sourceRef,attr"`
+    Target    string           `xml:"targetRef,attr"`
+    Condition *xmlOutCondition `xml:"bpmn:conditionExpression"`
+}
+
+type xmlOutCondition struct {
+    Type string `xml:"xsi:type,attr"`
+    Text string `xml:",chardata"`
+}
+
+type xmlOutDiagram struct {
+    ID    string      `xml:"id,attr"`
+    Plane xmlOutPlane `xml:"bpmndi:BPMNPlane"`
+}
+
+type xmlOutPlane struct {
+    ID      string        `xml:"id,attr"`
+    Element string        `xml:"bpmnElement,attr"`
+    Shapes  []xmlOutShape `xml:"bpmndi:BPMNShape"`
+    Edges   []xmlOutEdge  `xml:"bpmndi:BPMNEdge"`
+}
+
+type xmlOutShape struct {
+    ID      string       `xml:"id,attr"`
+    Element string       `xml:"bpmnElement,attr"`
+    Bounds  xmlOutBounds `xml:"dc:Bounds"`
+}
+
+type xmlOutBounds struct {
+    X      float64 `xml:"x,attr"`
+    Y      float64 `xml:"y,attr"`
+    Width  float64 `xml:"width,attr"`
+    Height float64 `xml:"height,attr"`
+}
+
+type xmlOutEdge struct {
+    ID        string           `xml:"id,attr"`
+    Element   string           `xml:"bpmnElement,attr"`
+    Waypoints []xmlOutWaypoint `xml:"di:waypoint"`
+}
+
+type xmlOutWaypoint struct {
+    X float64 `xml:"x,attr"`
+    Y float64 `xml:"y,attr"`
+}
+
+// XML shapes for import. Tags carry no namespace so elements match by local name
+// whatever prefix the modelling tool used.
+type xmlInDefinitions struct {
+    Processes []xmlInProcess `xml:"process"`
+}
+
+type xmlInProcess struct {
+    ID                string       `xml:"id,attr"`
+    Name              string       `xml:"name,attr"`
+    IsExecutable      bool         `xml:"isExecutable,attr"`
+    Documentation     string       `xml:"documentation"`
+    StartEvents       []xmlInNode  `xml:"startEvent"`
+    EndEvents         []xmlInNode  `xml:"endEvent"`
+    UserTasks         []xmlInNode  `xml:"userTask"`
+    ManualTasks       []xmlInNode  `xml:"manualTask"`
+    Tasks             []xmlInNode  `xml:"task"`
+    ServiceTasks      []xmlInNode  `xml:"serviceTask"`
+    ScriptTasks       []xmlInNode  `xml:"scriptTask"`
+    SendTasks         []xmlInNode  `xml:"sendTask"`
+    ExclusiveGateways []xmlInNode  `xml:"exclusiveGateway"`
+    ParallelGateways  []xmlInNode  `xml:"parallelGateway"`
+    Flows             []xmlInFlow  `xml:"sequenceFlow"`
+    Unsupported       []xmlInOther `xml:",any"`
+}
+
+type xmlInNode struct {
+    XMLName    xml.Name
+    ID         string `xml:"id,attr"`
+    Name       string `xml:"name,attr"`
+    Default    string `xml:"default,attr"`
+    Assignee   string `xml:"assignee,attr"`
+    Extensions struct {
+        TaskDefinition struct {
+            Type string `xml:"type,attr"`
+        } `xml:"taskDefinition"`
+        Assignment struct {
+            Assignee string `xml:"assignee,attr"`
+        } `xml:"assignmentDefinition"`
+    } `xml:"extensionElements"`
+}
+
+type xmlInFlow struct {
+    ID        string `xml:"id,attr"`
+    Source    string `xml:"sourceRef,attr"`
+    Target    string `xml:"targetRef,attr"`
+    Condition struct {
+        Text string `xml:",chardata"`
+    } `xml:"conditionExpression"`
+}
+
+type xmlInOther struct {
+    XMLName xml.Name
+}
+
+// bpmnIgnored lists process children that carry no execution semantics for blueprints.
+var bpmnIgnored = map[string]bool{
+    "extensionElements":   true,
+    "laneSet":             true,
+    "textAnnotation":      true,
+    "association":         true,
+    "group":               true,
+    "dataObject":          true,
+    "dataObjectReference": true,
+    "dataStoreReference":  true,
+    "property":            true,
+}
+
+// unsupported names the process children that cannot be mapped onto a blueprint.
+func (p xmlInProcess) unsupported() []string {
+    var names []string
+    for _, element := range p.Unsupported {
+        if !bpmnIgnored[element.XMLName.Local] {
+            names = append(names, element.XMLName.Local)
+        }
+    }
+    return names
+}
+
+type xmlInElement struct {
+    node xmlInNode
+    kind StepType
+}
+
+// elements lists the flow nodes of a process in document order per kind, together
+// with the step type they map to.
+func (p xmlInProcess) elements() []xmlInElement {
+    var elements []xmlInElement
+    add := func(nodes []xmlInNode, kind StepType) {
+        for _, node := range nodes {
+            elements = append(elements, xmlInElement{node: node, kind: kind})
+        }
+    }
+    add(p.UserTasks, StepUserTask)
+    add(p.ManualTasks, StepUserTask)
+    add(p.Tasks, StepUserTask)
+    add(p.ServiceTasks, StepServiceTask)
+    add(p.ScriptTasks, StepServiceTask)
+    add(p.SendTasks, StepServiceTask)
+    add(p.ExclusiveGateways, StepExclusiveGateway)
+    add(p.ParallelGateways, StepParallelGateway)
+    add(p.EndEvents, StepEnd)
+    return elements
+}
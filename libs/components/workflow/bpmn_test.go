@@ -0,0 +1,234 @@
+package workflow
+
+import (
+    "bytes"
+    "context"
+    "errors"
+    "os"
+    "path/filepath"
+    "reflect"
+    "strings"
+    "testing"
+
+    "gorm.io/datatypes"
+)
+
+func importSample(t *testing.T, name string) *BPMNProcess {
+    t.Helper()
+    data, err := os.ReadFile(filepath.Join("testdata", name))
+    if err != nil {
+        t.Fatalf("read %s: %v", name, err)
+    }
+    process, err := ImportBPMN(data)
+    if err != nil {
+        t.Fatalf("import %s: %v", name, err)
+    }
+    return process
+}
+
+func blueprintMap(t *testing.T, blueprint Blueprint) map[string]any {
+    t.Helper()
+    raw, err := blueprint.toMap()
+    if err != nil {
+        t.Fatalf("encode blueprint: %v", err)
+    }
+    return raw
+}
+
+func TestImportBPMNMapsSampleDiagrams(t *testing.T) {
+    expense := importSample(t, "expense-approval.bpmn")
+    if expense.Name != "Expense approval" || !strings.HasPrefix(expense.Documentation, "Route expense claims") {
+        t.Fatalf("unexpected process metadata %q %q", expense.Name, expense.Documentation)
+    }
+    blueprint := expense.Blueprint
+    if blueprint.Start != "manager_review" {
+        t.Fatalf("expected start manager_review, got %q", blueprint.Start)
+    }
+    if step, _ := blueprint.Step("manager_review"); step.Type != StepUserTask || step.Assignee != "manager" || step.Next != "amount_check" {
+        t.Fatalf("unexpected user task %+v", step)
+    }
+    if step, _ := blueprint.Step("pay_out"); step.Type != StepServiceTask || step.Handler != "payments" || step.Next != "done" {
+        t.Fatalf("unexpected service task %+v", step)
+    }
+    gateway, _ := blueprint.Step("amount_check")
+    wantConditions := []Condition{
+        {Variable: "amount", Operator: "gt", Value: float64(1000), Next: "finance_review"},
+        {Variable: "decision", Operator: "eq", Value: "rejected", Next: "notify_rejected"},
+    }
+    if !reflect.DeepEqual(gateway.Conditions, wantConditions) || gateway.Default != "pay_out" {
+        t.Fatalf("unexpected gateway %+v", gateway)
+    }
+    if step, _ := blueprint.Step("notify_rejected"); step.Next != "notify_rejected_end" {
+        t.Fatalf("expected dangling task to end explicitly, got %+v", step)
+    }
+
+    onboarding := importSample(t, "onboarding-parallel.bpmn")
+    fork, _ := onboarding.Blueprint.Step("fork")
+    if fork.Type != StepParallelGateway || !reflect.DeepEqual(fork.Branches, []string{"order_laptop", "assign_buddy"}) {
+        t.Fatalf("unexpected fork %+v", fork)
+    }
+    check, _ := onboarding.Blueprint.Step("remote_check")
+    if len(check.Conditions) != 2 || check.Conditions[0].Value != true || check.Conditions[1].Operator != "exists" {
+        t.Fatalf("unexpected conditions %+v", check.Conditions)
+    }
+}
+
+func TestBPMNRoundTripPreservesSampleDiagrams(t *testing.T) {
+    for _, name := range []string{"expense-approval.bpmn", "onboarding-parallel.bpmn"} {
+        t.Run(name, func(t *testing.T) {
+            imported := importSample(t, name)
+            definition := Definition{
+                ID:          "11111111-2222-3333-4444-555555555555",
+                Name:        imported.Name,
+                Description: imported.Documentation,
+                Blueprint:   datatypes.JSONMap(blueprintMap(t, imported.Blueprint)),
+            }
+
+            exported, err := ExportBPMN(definition)
+            if err != nil {
+                t.Fatalf("export: %v", err)
+            }
+            again, err := ImportBPMN(exported)
+            if err != nil {
+                t.Fatalf("re-import: %v\n%s", err, exported)
+            }
+
+            if again.Name != imported.Name || again.Documentation != imported.Documentation {
+                t.Fatalf("metadata changed: %q/%q -> %q/%q", imported.Name, imported.Documentation, again.Name, again.Documentation)
+            }
+            if want, got := blueprintMap(t, imported.Blueprint), blueprintMap(t, again.Blueprint); !reflect.DeepEqual(want, got) {
+                t.Fatalf("blueprint changed on round trip\nwant %+v\ngot  %+v", want, got)
+            }
+        })
+    }
+}
+
+func TestExportBPMNRoundTripsDesignerBlueprints(t *testing.T) {
+    definition := *approvalDefinition()
+    definition.Name = "Approval"
+    definition.Blueprint["steps"] = append(definition.Blueprint["steps"].([]any),
+        map[string]any{"id": "step 9", "type": "notification", "handler": "notify"},
+    )
+    definition.Blueprint["steps"].([]any)[3].(map[string]any)["next"] = "step 9"
+
+    first, err := ExportBPMN(definition)
+    if err != nil {
+        t.Fatalf("export: %v", err)
+    }
+    for _, want := range []string{
+        `<bpmn:userTask id="request"`,
+        `<zeebe:taskDefinition type="score"></zeebe:taskDefinition>`,
+        `<bpmn:exclusiveGateway id="route" default="Flow_`,
+        `=amount &gt; 1000</bpmn:conditionExpression>`,
+        `<bpmn:serviceTask id="Step_step_9"`,
+        `<bpmn:endEvent id="Step_step_9_end">`,
+        `<bpmndi:BPMNShape id="route_di" bpmnElement="route">`,
+    } {
+        if !bytes.Contains(first, []byte(want)) {
+            t.Fatalf("expected export to contain %s\n%s", want, first)
+        }
+    }
+
+    imported, err := ImportBPMN(first)
+    if err != nil {
+        t.Fatalf("import: %v", err)
+    }
+    if step, _ := imported.Blueprint.Step("review"); step.Next != "Step_step_9" {
+        t.Fatalf("expected sanitized reference to the renamed step, got %+v", step)
+    }
+    definition.Blueprint = datatypes.JSONMap(blueprintMap(t, imported.Blueprint))
+    second, err := ExportBPMN(definition)
+    if err != nil {
+        t.Fatalf("second export: %v", err)
+    }
+    again, err := ImportBPMN(second)
+    if err != nil {
+        t.Fatalf("second import: %v", err)
+    }
+    if want, got := blueprintMap(t, imported.Blueprint), blueprintMap(t, again.Blueprint); !reflect.DeepEqual(want, got) {
+        t.Fatalf("blueprint changed on round trip\nwant %+v\ngot  %+v", want, got)
+    }
+}
+
+func TestImportBPMNRejectsUnsupportedDocuments(t *testing.T) {
+    wrap := func(body string) []byte {
+        return []byte(`<definitions xmlns="http://www.omg.org/spec/BPMN/20100524/MODEL"><process id="p" isExecutable="true">` + body + `</process></definitions>`)
+    }
+    cases := map[string][]byte{
+        "not xml":    []byte("{}"),
+        "no start":   wrap(`<userTask id="a"/>`),
+        "timer":      wrap(`<startEvent id="s"/><intermediateCatchEvent id="t"/><sequenceFlow id="f" sourceRef="s" targetRef="t"/>`),
+        "task split": wrap(`<startEvent id="s"/><userTask id="a"/><endEvent id="e1"/><endEvent id="e2"/><sequenceFlow id="f1" sourceRef="s" targetRef="a"/><sequenceFlow id="f2" sourceRef="a" targetRef="e1"/><sequenceFlow id="f3" sourceRef="a" targetRef="e2"/>`),
+        "bad feel":   wrap(`<startEvent id="s"/><exclusiveGateway id="g"/><endEvent id="e1"/><endEvent id="e2"/><sequenceFlow id="f1" sourceRef="s" targetRef="g"/><sequenceFlow id="f2" sourceRef="g" targetRef="e1"><conditionExpression>=sum(items) &gt; 3</conditionExpression></sequenceFlow><sequenceFlow id="f3" sourceRef="g" targetRef="e2"/>`),
+    }
+    for name, document := range cases {
+        if _, err := ImportBPMN(document); !errors.Is(err, ErrInvalidBPMN) {
+            t.Fatalf("%s: expected ErrInvalidBPMN, got %v", name, err)
+        }
+    }
+
+    dangling := wrap(`<startEvent id="s"/><userTask id="a"/><sequenceFlow id="f1" sourceRef="s" targetRef="a"/><sequenceFlow id="f2" sourceRef="a" targetRef="missing"/>`)
+    var invalid *BlueprintError
+    if _, err := ImportBPMN(dangling); !errors.As(err, &invalid) {
+        t.Fatalf("expected BlueprintError for dangling flow, got %v", err)
+    }
+}
+
+func TestEngineJoinsParallelBranchesFromBPMN(t *testing.T) {
+    imported := importSample(t, "onboarding-parallel.bpmn")
+    definition := &Definition{
+        ID:        "onboarding",
+        Version:   1,
+        Published: true,
+        Blueprint: datatypes.JSONMap(blueprintMap(t, imported.Blueprint)),
+    }
+    noop := func(ctx context.Context, instance *ProcessInstance, step Step) (map[string]any, error) {
+        return nil, nil
+    }
+    engine := NewEngine(&memoryDefinitions{definitions: map[string]*Definition{"onboarding": definition}}, newMemoryInstances(),
+        WithServiceTaskHandler("identity.provision", noop),
+        WithServiceTaskHandler("logistics.ship", noop),
+    )
+    ctx := context.Background()
+
+    instance, tokens, err := engine.Start(ctx, "onboarding", map[string]any{"remote": true}, "hr")
+    if err != nil {
+        t.Fatalf("start: %v", err)
+    }
+    active := map[string]ProcessToken{}
+    for _, token := range tokens {
+        if token.Status == TokenActive {
+            active[token.StepID] = token
+        }
+    }
+    if len(active) != 2 || active["order_laptop"].ID == "" || active["assign_buddy"].ID == "" {
+        t.Fatalf("expected both branches to be active, got %+v", tokens)
+    }
+
+    instance, _, err = engine.CompleteTask(ctx, instance.ID, active["order_laptop"].ID, nil, "it")
+    if err != nil {
+        t.Fatalf("complete laptop: %v", err)
+    }
+    if instance.Status != InstanceRunning {
+        t.Fatalf("expected instance to wait at the join, got %s", instance.Status)
+    }
+    instance, tokens, err = engine.CompleteTask(ctx, instance.ID, active["assign_buddy"].ID, nil, "hr")
+    if err != nil {
+        t.Fatalf("complete buddy: %v", err)
+    }
+    if instance.Status != InstanceCompleted {
+        t.Fatalf("expected completed instance, got %s (%s) %+v", instance.Status, instance.ErrorMessage, tokens)
+    }
+    joins := 0
+    for _, token := range tokens {
+        if token.StepID == "join" {
+            joins++
+        }
+        if token.StepID == "welcome" {
+            t.Fatalf("expected the remote branch only, got %+v", tokens)
+        }
+    }
+    if joins == 0 {
+        t.Fatalf("expected join tokens, got %+v", tokens)
+    }
+}
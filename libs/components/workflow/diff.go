@@ -0,0 +1,122 @@
+package workflow
+
+import (
+    "encoding/json"
+    "reflect"
+)
+
+// FieldChange records the previous and the new value of an attribute.
+type FieldChange struct {
+    From any `json:"from"`
+    To   any `json:"to"`
+}
+
+// StepChange describes a step present in both blueprints whose attributes differ.
+// Fields are keyed by the blueprint attribute name, e.g. "next" or "conditions".
+type StepChange struct {
+    ID     string                 `json:"id"`
+    Fields map[string]FieldChange `json:"fields"`
+}
+
+// BlueprintDiff lists the step level differences between two blueprints.
+type BlueprintDiff struct {
+    Start   *FieldChange `json:"start,omitempty"`
+    Added   []Step       `json:"added"`
+    Removed []Step       `json:"removed"`
+    Changed []StepChange `json:"changed"`
+}
+
+// DefinitionDiff compares two snapshots of a definition.
+type DefinitionDiff struct {
+    Fields map[string]FieldChange `json:"fields"`
+    Steps  BlueprintDiff          `json:"steps"`
+}
+
+// Empty reports whether the snapshots are identical.
+func (d DefinitionDiff) Empty() bool {
+    return len(d.Fields) == 0 && d.Steps.Start == nil && len(d.Steps.Added) == 0 && len(d.Steps.Removed) == 0 && len(d.Steps.Changed) == 0
+}
+
+// DiffDefinitions compares the name, description and blueprint of two definition snapshots.
+func DiffDefinitions(from, to Definition) DefinitionDiff {
+    diff := DefinitionDiff{Fields: map[string]FieldChange{}, Steps: DiffBlueprints(from.Blueprint, to.Blueprint)}
+    if from.Name != to.Name {
+        diff.Fields["name"] = FieldChange{From: from.Name, To: to.Name}
+    }
+    if from.Description != to.Description {
+        diff.Fields["description"] = FieldChange{From: from.Description, To: to.Description}
+    }
+    return diff
+}
+
+// DiffBlueprints matches steps by ID and reports added, removed and changed steps in
+// the order they appear in the blueprints. Malformed blueprints are compared as far
+// as their steps could be decoded.
+func DiffBlueprints(from, to map[string]any) BlueprintDiff {
+    before, _ := decodeBlueprint(from)
+    after, _ := decodeBlueprint(to)
+    diff := BlueprintDiff{Added: []Step{}, Removed: []Step{}, Changed: []StepChange{}}
+
+    if start, next := startOf(before), startOf(after); start != next {
+        diff.Start = &FieldChange{From: start, To: next}
+    }
+
+    previous := make(map[string]Step, len(before.Steps))
+    for _, step := range before.Steps {
+        previous[step.ID] = step
+    }
+    current := make(map[string]bool, len(after.Steps))
+    for _, step := range after.Steps {
+        current[step.ID] = true
+        old, ok := previous[step.ID]
+        if !ok {
+            diff.Added = append(diff.Added, step)
+            continue
+        }
+        if fields := diffSteps(old, step); len(fields) > 0 {
+            diff.Changed = append(diff.Changed, StepChange{ID: step.ID, Fields: fields})
+        }
+    }
+    for _, step := range before.Steps {
+        if !current[step.ID] {
+            diff.Removed = append(diff.Removed, step)
+        }
+    }
+    return diff
+}
+
+// diffSteps compares two steps attribute by attribute using their JSON form, so
+// attributes added to Step later are picked up without changes here.
+func diffSteps(before, after Step) map[string]FieldChange {
+    left, right := stepAttributes(before), stepAttributes(after)
+    fields := map[string]FieldChange{}
+    for name, value := range left {
+        if !reflect.DeepEqual(value, right[name]) {
+            fields[name] = FieldChange{From: value, To: right[name]}
+        }
+    }
+    for name, value := range right {
+        if _, ok := left[name]; !ok {
+            fields[name] = FieldChange{To: value}
+        }
+    }
+    return fields
+}
+
+func stepAttributes(step Step) map[string]any {
+    attributes := map[string]any{}
+    encoded, err := json.Marshal(step)
+    if err != nil {
+        return attributes
+    }
+    _ = json.Unmarshal(encoded, &attributes)
+    return attributes
+}
+
+// startOf returns the ID of the step a blueprint starts with, or "" when it has none.
+func startOf(b Blueprint) string {
+    if len(b.Steps) == 0 {
+        return ""
+    }
+    return b.first().ID
+}
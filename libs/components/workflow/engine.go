@@ -0,0 +1,284 @@
+package workflow
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "log"
+    "strings"
+    "time"
+
+    "github.com/google/uuid"
+    "gorm.io/datatypes"
+)
+
+// maxSteps bounds how many steps a single advance may execute, so a blueprint that
+// loops through service tasks and gateways cannot spin forever.
+const maxSteps = 1000
+
+var (
+    // ErrDefinitionNotPublished is returned when starting an unpublished definition.
+    ErrDefinitionNotPublished = errors.New("workflow definition is not published")
+    // ErrInstanceNotRunning is returned when completing a task of a finished instance.
+    ErrInstanceNotRunning = errors.New("process instance is not running")
+    // ErrTaskNotFound is returned when the task does not exist or is no longer active.
+    ErrTaskNotFound = errors.New("task not found")
+)
+
+// ServiceTaskHandler executes a service task. Returned variables are merged into the
+// instance variables; an error fails the instance.
+type ServiceTaskHandler func(ctx context.Context, instance *ProcessInstance, step Step) (map[string]any, error)
+
+// Engine executes published workflow blueprints as process instances.
+type Engine struct {
+    definitions Repository
+    instances   InstanceStore
+    handlers    map[string]ServiceTaskHandler
+}
+
+// EngineOption customises the engine behaviour.
+type EngineOption func(*Engine)
+
+// WithServiceTaskHandler registers the handler invoked by service tasks that name it.
+// Service tasks without a registered handler complete immediately.
+func WithServiceTaskHandler(name string, handler ServiceTaskHandler) EngineOption {
+    return func(e *Engine) {
+        e.handlers[name] = handler
+    }
+}
+
+// NewEngine constructs an engine reading definitions from definitions and persisting
+// instances in instances.
+func NewEngine(definitions Repository, instances InstanceStore, opts ...EngineOption) *Engine {
+    engine := &Engine{
+        definitions: definitions,
+        instances:   instances,
+        handlers:    make(map[string]ServiceTaskHandler),
+    }
+    for _, opt := range opts {
+        if opt != nil {
+            opt(engine)
+        }
+    }
+    return engine
+}
+
+// Start creates a process instance for a published definition and runs it until it
+// waits on a user task or finishes.
+func (e *Engine) Start(ctx context.Context, definitionID string, variables map[string]any, actor string) (*ProcessInstance, []ProcessToken, error) {
+    definition, err := e.definitions.Find(ctx, definitionID)
+    if err != nil {
+        return nil, nil, err
+    }
+    if !definition.Published {
+        return nil, nil, ErrDefinitionNotPublished
+    }
+
+    // Instances run the published snapshot, not the draft. Definitions published
+    // before versioning have no snapshot and run from the definition itself.
+    source := definition.Blueprint
+    snapshot, err := e.definitions.FindVersion(ctx, definition.ID, definition.Version)
+    switch {
+    case err == nil:
+        source = snapshot.Blueprint
+    case !IsNotFound(err):
+        return nil, nil, err
+    }
+
+    blueprint, err := ParseBlueprint(source)
+    if err != nil {
+        return nil, nil, err
+    }
+
+    if variables == nil {
+        variables = map[string]any{}
+    }
+    // The instance has its ID before it runs, so service tasks executed on the
+    // way to the first wait state can refer to it.
+    instance := &ProcessInstance{
+        ID:                uuid.NewString(),
+        DefinitionID:      definition.ID,
+        DefinitionVersion: definition.Version,
+        Status:            InstanceRunning,
+        Blueprint:         source,
+        Variables:         datatypes.JSONMap(variables),
+        StartedBy:         strings.TrimSpace(actor),
+    }
+
+    tokens := e.advance(ctx, blueprint, instance, nil, []Step{blueprint.first()})
+    if err := e.instances.Create(ctx, instance, tokens); err != nil {
+        return nil, nil, err
+    }
+    return instance, tokens, nil
+}
+
+// CompleteTask finishes an active user task, merges the supplied variables and runs
+// the instance until it waits again or finishes.
+func (e *Engine) CompleteTask(ctx context.Context, instanceID, taskID string, variables map[string]any, actor string) (*ProcessInstance, []ProcessToken, error) {
+    return e.instances.Update(ctx, instanceID, func(instance *ProcessInstance, tokens []ProcessToken) ([]ProcessToken, error) {
+        if instance.Status != InstanceRunning {
+            return nil, ErrInstanceNotRunning
+        }
+
+        index := -1
+        for i, token := range tokens {
+            if token.ID == taskID && token.Status == TokenActive && token.StepType == string(StepUserTask) {
+                index = i
+                break
+            }
+        }
+        if index < 0 {
+            return nil, ErrTaskNotFound
+        }
+
+        blueprint, err := ParseBlueprint(instance.Blueprint)
+        if err != nil {
+            return nil, err
+        }
+        step, ok := blueprint.Step(tokens[index].StepID)
+        if !ok {
+            return nil, fmt.Errorf("%w: instance references unknown step %q", ErrInvalidBlueprint, tokens[index].StepID)
+        }
+
+        mergeVariables(instance, variables)
+        completeToken(&tokens[index], actor)
+        return e.advance(ctx, blueprint, instance, tokens, blueprint.following(step)), nil
+    })
+}
+
+// Instance returns a process instance with its tokens.
+func (e *Engine) Instance(ctx context.Context, id string) (*ProcessInstance, []ProcessToken, error) {
+    return e.instances.Find(ctx, id)
+}
+
+// advance moves the instance through the pending steps, appending a token per visited
+// step, until every branch waits on a user task or a parallel join, or has ended. The
+// instance completes once no branch is left open.
+func (e *Engine) advance(ctx context.Context, blueprint Blueprint, instance *ProcessInstance, tokens []ProcessToken, pending []Step) []ProcessToken {
+    for visited := 0; len(pending) > 0; visited++ {
+        if visited >= maxSteps {
+            fail(instance, fmt.Errorf("exceeded %d steps without reaching a user task or end", maxSteps))
+            return tokens
+        }
+        step := pending[0]
+        pending = pending[1:]
+
+        tokens = append(tokens, ProcessToken{
+            Sequence: len(tokens) + 1,
+            StepID:   step.ID,
+            StepType: string(step.Type),
+            Status:   TokenActive,
+            Assignee: step.Assignee,
+        })
+        token := &tokens[len(tokens)-1]
+
+        switch step.Type {
+        case StepUserTask:
+            continue
+        case StepEnd:
+            completeToken(token, "")
+        case StepServiceTask:
+            if err := e.runServiceTask(ctx, instance, step); err != nil {
+                fail(instance, err)
+                return tokens
+            }
+            completeToken(token, "")
+            pending = append(pending, blueprint.following(step)...)
+        case StepExclusiveGateway:
+            completeToken(token, "")
+            next, err := blueprint.route(step, instance.Variables)
+            if err != nil {
+                fail(instance, err)
+                return tokens
+            }
+            pending = append(pending, next)
+        case StepParallelGateway:
+            if !join(blueprint, step, tokens) {
+                continue
+            }
+            pending = append(pending, blueprint.following(step)...)
+        }
+    }
+
+    if instance.Status == InstanceRunning && !hasOpenTokens(tokens) {
+        finish(instance)
+    }
+    return tokens
+}
+
+// join parks the newest token of a parallel gateway until a token has arrived on every
+// incoming edge, then completes the arrived tokens and reports that the gateway fires.
+func join(blueprint Blueprint, step Step, tokens []ProcessToken) bool {
+    expected := blueprint.incoming(step.ID)
+    tokens[len(tokens)-1].Status = TokenWaiting
+
+    arrived := make([]int, 0, expected)
+    for i := range tokens {
+        if tokens[i].StepID == step.ID && tokens[i].Status == TokenWaiting {
+            arrived = append(arrived, i)
+        }
+    }
+    if len(arrived) < expected {
+        return false
+    }
+    for _, i := range arrived[:max(expected, 1)] {
+        completeToken(&tokens[i], "")
+    }
+    return true
+}
+
+func hasOpenTokens(tokens []ProcessToken) bool {
+    for _, token := range tokens {
+        if token.Status == TokenActive || token.Status == TokenWaiting {
+            return true
+        }
+    }
+    return false
+}
+
+func (e *Engine) runServiceTask(ctx context.Context, instance *ProcessInstance, step Step) error {
+    handler, ok := e.handlers[step.Handler]
+    if !ok {
+        if step.Handler != "" {
+            log.Printf("workflow engine: no handler registered for %q, skipping step %s", step.Handler, step.ID)
+        }
+        return nil
+    }
+
+    output, err := handler(ctx, instance, step)
+    if err != nil {
+        return fmt.Errorf("service task %q: %w", step.ID, err)
+    }
+    mergeVariables(instance, output)
+    return nil
+}
+
+func mergeVariables(instance *ProcessInstance, variables map[string]any) {
+    if len(variables) == 0 {
+        return
+    }
+    if instance.Variables == nil {
+        instance.Variables = datatypes.JSONMap{}
+    }
+    for key, value := range variables {
+        instance.Variables[key] = value
+    }
+}
+
+func completeToken(token *ProcessToken, actor string) {
+    now := time.Now()
+    token.Status = TokenCompleted
+    token.CompletedAt = &now
+    token.CompletedBy = strings.TrimSpace(actor)
+}
+
+func finish(instance *ProcessInstance) {
+    now := time.Now()
+    instance.Status = InstanceCompleted
+    instance.CompletedAt = &now
+}
+
+func fail(instance *ProcessInstance, err error) {
+    instance.Status = InstanceFailed
+    instance.ErrorMessage = err.Error()
+}
@@ -0,0 +1,331 @@
+package workflow
+
+import (
+    "context"
+    "errors"
+    "testing"
+
+    "github.com/google/uuid"
+    "gorm.io/datatypes"
+    "gorm.io/gorm"
+)
+
+type memoryDefinitions struct {
+    Repository
+    definitions map[string]*Definition
+    versions    map[string][]DefinitionVersion
+}
+
+func (m *memoryDefinitions) Find(ctx context.Context, id string) (*Definition, error) {
+    definition, ok := m.definitions[id]
+    if !ok {
+        return nil, gorm.ErrRecordNotFound
+    }
+    return definition, nil
+}
+
+func (m *memoryDefinitions) Update(ctx context.Context, id string, updates map[string]any) (*Definition, error) {
+    definition, err := m.Find(ctx, id)
+    if err != nil {
+        return nil, err
+    }
+    for column, value := range updates {
+        switch column {
+        case "name":
+            definition.Name = value.(string)
+        case "description":
+            definition.Description = value.(string)
+        case "blueprint":
+            definition.Blueprint = value.(datatypes.JSONMap)
+        case "published":
+            definition.Published = value.(bool)
+        }
+    }
+    return definition, nil
+}
+
+func (m *memoryDefinitions) Publish(ctx context.Context, id string, opts PublishOptions) (*Definition, error) {
+    definition, err := m.Find(ctx, id)
+    if err != nil {
+        return nil, err
+    }
+    entity := *definition
+    if opts.FromVersion > 0 {
+        source, err := m.FindVersion(ctx, id, opts.FromVersion)
+        if err != nil {
+            return nil, err
+        }
+        entity.Name, entity.Description, entity.Blueprint = source.Name, source.Description, source.Blueprint
+    }
+
+    versions := m.versions[id]
+    var latest DefinitionVersion
+    highest := 0
+    for _, version := range versions {
+        latest = version
+        if version.Name == entity.Name {
+            highest = max(highest, version.Version)
+        }
+    }
+    snapshot := freeze(entity, latest, highest, opts)
+    entity.Version = snapshot.Version
+    fresh := snapshot.ID == ""
+    if fresh {
+        snapshot.ID = uuid.NewString()
+    }
+    entity.Published = true
+    if opts.Announce != nil {
+        if err := opts.Announce(ctx, entity); err != nil {
+            return nil, err
+        }
+    }
+
+    var deployErr error
+    if opts.Deploy != nil {
+        deployment, err := opts.Deploy(ctx, entity)
+        if err != nil {
+            deployErr = err
+            entity.DeployError = err.Error()
+        } else {
+            if snapshot.ProcessKey == "" {
+                snapshot.ProcessKey, snapshot.EngineVersion = deployment.ProcessKey, deployment.Version
+            }
+            entity.ProcessKey, entity.EngineVersion, entity.DeployedAt = deployment.ProcessKey, deployment.Version, &deployment.DeployedAt
+            entity.DeployError = ""
+        }
+    }
+    if fresh {
+        if m.versions == nil {
+            m.versions = map[string][]DefinitionVersion{}
+        }
+        m.versions[id] = append(versions, snapshot)
+    } else {
+        versions[len(versions)-1] = snapshot
+    }
+    *definition = entity
+    return definition, deployErr
+}
+
+func (m *memoryDefinitions) Versions(ctx context.Context, id string) ([]DefinitionVersion, error) {
+    if _, err := m.Find(ctx, id); err != nil {
+        return nil, err
+    }
+    return m.versions[id], nil
+}
+
+func (m *memoryDefinitions) FindVersion(ctx context.Context, id string, version int) (*DefinitionVersion, error) {
+    for _, candidate := range m.versions[id] {
+        if candidate.Version == version {
+            return &candidate, nil
+        }
+    }
+    return nil, gorm.ErrRecordNotFound
+}
+
+type memoryInstances struct {
+    instances map[string]*ProcessInstance
+    tokens    map[string][]ProcessToken
+}
+
+func newMemoryInstances() *memoryInstances {
+    return &memoryInstances{instances: map[string]*ProcessInstance{}, tokens: map[string][]ProcessToken{}}
+}
+
+func (m *memoryInstances) Create(ctx context.Context, instance *ProcessInstance, tokens []ProcessToken) error {
+    if instance.ID == "" {
+        instance.ID = uuid.NewString()
+    }
+    for i := range tokens {
+        tokens[i].ID = uuid.NewString()
+        tokens[i].InstanceID = instance.ID
+    }
+    m.instances[instance.ID] = instance
+    m.tokens[instance.ID] = tokens
+    return nil
+}
+
+func (m *memoryInstances) Find(ctx context.Context, id string) (*ProcessInstance, []ProcessToken, error) {
+    return m.instances[id], m.tokens[id], nil
+}
+
+func (m *memoryInstances) Update(ctx context.Context, id string, mutate func(*ProcessInstance, []ProcessToken) ([]ProcessToken, error)) (*ProcessInstance, []ProcessToken, error) {
+    instance := *m.instances[id]
+    tokens, err := mutate(&instance, append([]ProcessToken(nil), m.tokens[id]...))
+    if err != nil {
+        return nil, nil, err
+    }
+    for i := range tokens {
+        if tokens[i].ID == "" {
+            tokens[i].ID = uuid.NewString()
+        }
+    }
+    m.instances[id] = &instance
+    m.tokens[id] = tokens
+    return &instance, tokens, nil
+}
+
+func approvalDefinition() *Definition {
+    return &Definition{
+        ID:        "approval",
+        Version:   1,
+        Published: true,
+        Blueprint: datatypes.JSONMap{
+            "steps": []any{
+                map[string]any{"id": "request", "type": "form"},
+                map[string]any{"id": "score", "type": "serviceTask", "handler": "score"},
+                map[string]any{
+                    "id":   "route",
+                    "type": "exclusiveGateway",
+                    "conditions": []any{
+                        map[string]any{"variable": "amount", "operator": "gt", "value": 1000, "next": "review"},
+                    },
+                    "default": "done",
+                },
+                map[string]any{"id": "review", "type": "approval", "next": "done"},
+                map[string]any{"id": "done", "type": "end"},
+            },
+        },
+    }
+}
+
+func activeTask(t *testing.T, tokens []ProcessToken) ProcessToken {
+    t.Helper()
+    for _, token := range tokens {
+        if token.Status == TokenActive {
+            return token
+        }
+    }
+    t.Fatalf("no active task in %+v", tokens)
+    return ProcessToken{}
+}
+
+func TestEngineRunsBlueprintThroughGateway(t *testing.T) {
+    definitions := &memoryDefinitions{definitions: map[string]*Definition{"approval": approvalDefinition()}}
+    scored := 0
+    engine := NewEngine(definitions, newMemoryInstances(), WithServiceTaskHandler("score", func(ctx context.Context, instance *ProcessInstance, step Step) (map[string]any, error) {
+        scored++
+        return map[string]any{"scored": true}, nil
+    }))
+    ctx := context.Background()
+
+    for _, tc := range []struct {
+        amount float64
+        steps  []string
+    }{
+        {amount: 50, steps: []string{"request", "score", "route", "done"}},
+        {amount: 5000, steps: []string{"request", "score", "route", "review", "done"}},
+    } {
+        instance, tokens, err := engine.Start(ctx, "approval", nil, "alice")
+        if err != nil {
+            t.Fatalf("start: %v", err)
+        }
+        if instance.Status != InstanceRunning || activeTask(t, tokens).StepID != "request" {
+            t.Fatalf("expected instance waiting on request, got %s %+v", instance.Status, tokens)
+        }
+
+        instance, tokens, err = engine.CompleteTask(ctx, instance.ID, activeTask(t, tokens).ID, map[string]any{"amount": tc.amount}, "alice")
+        if err != nil {
+            t.Fatalf("complete request: %v", err)
+        }
+        if instance.Status == InstanceRunning {
+            instance, tokens, err = engine.CompleteTask(ctx, instance.ID, activeTask(t, tokens).ID, nil, "bob")
+            if err != nil {
+                t.Fatalf("complete review: %v", err)
+            }
+        }
+
+        if instance.Status != InstanceCompleted {
+            t.Fatalf("amount %v: expected completed instance, got %s (%s)", tc.amount, instance.Status, instance.ErrorMessage)
+        }
+        if instance.Variables["scored"] != true {
+            t.Fatalf("expected service task output to be merged, got %+v", instance.Variables)
+        }
+        visited := make([]string, 0, len(tokens))
+        for _, token := range tokens {
+            visited = append(visited, token.StepID)
+        }
+        if len(visited) != len(tc.steps) {
+            t.Fatalf("amount %v: expected steps %v, got %v", tc.amount, tc.steps, visited)
+        }
+        for i := range visited {
+            if visited[i] != tc.steps[i] {
+                t.Fatalf("amount %v: expected steps %v, got %v", tc.amount, tc.steps, visited)
+            }
+        }
+    }
+    if scored != 2 {
+        t.Fatalf("expected score handler to run twice, ran %d times", scored)
+    }
+}
+
+func TestEngineRejectsUnpublishedDefinitionsAndStaleTasks(t *testing.T) {
+    draft := approvalDefinition()
+    draft.Published = false
+    definitions := &memoryDefinitions{definitions: map[string]*Definition{"approval": draft}}
+    engine := NewEngine(definitions, newMemoryInstances())
+    ctx := context.Background()
+
+    if _, _, err := engine.Start(ctx, "approval", nil, ""); !errors.Is(err, ErrDefinitionNotPublished) {
+        t.Fatalf("expected ErrDefinitionNotPublished, got %v", err)
+    }
+
+    draft.Published = true
+    instance, tokens, err := engine.Start(ctx, "approval", nil, "")
+    if err != nil {
+        t.Fatalf("start: %v", err)
+    }
+    task := activeTask(t, tokens)
+    if _, _, err := engine.CompleteTask(ctx, instance.ID, task.ID, map[string]any{"amount": 1}, ""); err != nil {
+        t.Fatalf("complete: %v", err)
+    }
+    if _, _, err := engine.CompleteTask(ctx, instance.ID, task.ID, nil, ""); !errors.Is(err, ErrInstanceNotRunning) {
+        t.Fatalf("expected ErrInstanceNotRunning, got %v", err)
+    }
+}
+
+func TestEngineServiceTasksSeeTheStartingInstanceID(t *testing.T) {
+    definitions := &memoryDefinitions{definitions: map[string]*Definition{"scoring": {
+        ID:        "scoring",
+        Version:   1,
+        Published: true,
+        Blueprint: datatypes.JSONMap{
+            "steps": []any{
+                map[string]any{"id": "score", "type": "serviceTask", "handler": "score"},
+                map[string]any{"id": "done", "type": "end"},
+            },
+        },
+    }}}
+    var seen string
+    engine := NewEngine(definitions, newMemoryInstances(), WithServiceTaskHandler("score", func(ctx context.Context, instance *ProcessInstance, step Step) (map[string]any, error) {
+        seen = instance.ID
+        return nil, nil
+    }))
+
+    instance, _, err := engine.Start(context.Background(), "scoring", nil, "")
+    if err != nil {
+        t.Fatalf("start: %v", err)
+    }
+    if seen == "" || seen != instance.ID {
+        t.Fatalf("expected the handler to see instance %q, got %q", instance.ID, seen)
+    }
+}
+
+func TestEngineFailsInstanceWhenServiceTaskErrors(t *testing.T) {
+    definitions := &memoryDefinitions{definitions: map[string]*Definition{"approval": approvalDefinition()}}
+    engine := NewEngine(definitions, newMemoryInstances(), WithServiceTaskHandler("score", func(ctx context.Context, instance *ProcessInstance, step Step) (map[string]any, error) {
+        return nil, errors.New("scoring service unavailable")
+    }))
+    ctx := context.Background()
+
+    instance, tokens, err := engine.Start(ctx, "approval", nil, "")
+    if err != nil {
+        t.Fatalf("start: %v", err)
+    }
+    instance, _, err = engine.CompleteTask(ctx, instance.ID, activeTask(t, tokens).ID, nil, "")
+    if err != nil {
+        t.Fatalf("complete: %v", err)
+    }
+    if instance.Status != InstanceFailed || instance.ErrorMessage == "" {
+        t.Fatalf("expected failed instance, got %s %q", instance.Status, instance.ErrorMessage)
+    }
+}
@@ -0,0 +1,48 @@
+package workflow
+
+import (
+    "context"
+
+    "gorm.io/gorm"
+
+    "github.com/pflow/components/outbox"
+)
+
+// eventSource is the source of the domain events the workflow component emits.
+const eventSource = "/pflow/workflow"
+
+// Domain events emitted after workflow changes. Definition events have the
+// definition ID as subject, instance events the instance ID.
+const (
+    EventWorkflowCreated         = "workflow.created"
+    EventWorkflowUpdated         = "workflow.updated"
+    EventWorkflowDeleted         = "workflow.deleted"
+    EventWorkflowRestored        = "workflow.restored"
+    EventWorkflowPublished       = "workflow.published"
+    EventWorkflowInstanceStarted = "workflow.instance_started"
+    EventWorkflowTaskCompleted   = "workflow.task_completed"
+    EventWorkflowInstanceEnded   = "workflow.instance_ended"
+)
+
+// instanceResource is the audit resource type of process instances, whose
+// audit entries would otherwise be filed under their definitions.
+const instanceResource = "workflow_instance"
+
+// NewEventOutbox constructs the outbox the workflow events are recorded in, in
+// the transaction of the change they announce.
+func NewEventOutbox(db *gorm.DB) *outbox.EventStore {
+    return outbox.NewEventStore(db, eventSource)
+}
+
+// emitInstance announces a change of an instance and, when the change finished
+// it, that the instance ended.
+func (h *Handler) emitInstance(ctx context.Context, eventType string, instance *ProcessInstance, tokens []ProcessToken) error {
+    dto := instance.ToDTO(tokens)
+    if err := h.events.Emit(ctx, eventType, instance.ID, dto); err != nil {
+        return err
+    }
+    if instance.Status != InstanceRunning {
+        return h.events.Emit(ctx, EventWorkflowInstanceEnded, instance.ID, dto)
+    }
+    return nil
+}
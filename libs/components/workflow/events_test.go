@@ -0,0 +1,89 @@
+package workflow
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "net/http"
+    "net/http/httptest"
+    "testing"
+
+    "github.com/go-chi/chi/v5"
+
+    "github.com/pflow/components/internal/dbtest"
+    "github.com/pflow/components/outbox"
+    "github.com/pflow/shared/mq"
+)
+
+type recordingPublisher struct {
+    events []mq.Event
+}
+
+func (p *recordingPublisher) Publish(ctx context.Context, key string, value []byte, headers map[string]string) error {
+    var event mq.Event
+    if err := json.Unmarshal(value, &event); err != nil {
+        return err
+    }
+    p.events = append(p.events, event)
+    return nil
+}
+
+func TestHandlerRecordsEventsInTheOutbox(t *testing.T) {
+    db := dbtest.Open(t, Migrate)
+    repo := NewGormRepository(db)
+    router := chi.NewRouter()
+    NewHandler(repo, WithEngine(NewEngine(repo, NewInstanceRepository(db))), WithEvents(NewEventOutbox(db))).Mount(router, "")
+
+    serve := func(method, path string, body any) (int, map[string]any) {
+        var payload bytes.Buffer
+        if body != nil {
+            json.NewEncoder(&payload).Encode(body)
+        }
+        rec := httptest.NewRecorder()
+        router.ServeHTTP(rec, httptest.NewRequest(method, path, &payload))
+        var decoded map[string]any
+        json.Unmarshal(rec.Body.Bytes(), &decoded)
+        return rec.Code, decoded
+    }
+
+    blueprint := map[string]any{"steps": []any{map[string]any{"id": "done", "type": "end"}}}
+    code, created := serve(http.MethodPost, "/workflows", map[string]any{"name": "onboarding", "blueprint": blueprint})
+    if code != http.StatusCreated {
+        t.Fatalf("create: %d %v", code, created)
+    }
+    id := created["data"].(map[string]any)["id"].(string)
+
+    if code, body := serve(http.MethodPost, "/workflows/"+id+"/publish", nil); code != http.StatusOK {
+        t.Fatalf("publish: %d %v", code, body)
+    }
+    code, started := serve(http.MethodPost, "/workflows/"+id+"/instances", map[string]any{})
+    if code != http.StatusCreated {
+        t.Fatalf("start: %d %v", code, started)
+    }
+    instanceID := started["data"].(map[string]any)["id"].(string)
+
+    publisher := &recordingPublisher{}
+    relay := outbox.NewRelay(outbox.NewRepository(db), outbox.Routes{outbox.Events: publisher}, outbox.RelayConfig{})
+    if _, err := relay.RelayOnce(context.Background()); err != nil {
+        t.Fatalf("relay: %v", err)
+    }
+
+    want := []struct{ eventType, subject string }{
+        {EventWorkflowCreated, id},
+        {EventWorkflowPublished, id},
+        {EventWorkflowInstanceStarted, instanceID},
+        {EventWorkflowInstanceEnded, instanceID},
+    }
+    if len(publisher.events) != len(want) {
+        t.Fatalf("expected %d events, got %+v", len(want), publisher.events)
+    }
+    for i, event := range publisher.events {
+        if event.Type != want[i].eventType || event.Subject != want[i].subject || event.Source != eventSource {
+            t.Fatalf("unexpected event %d: %+v", i, event)
+        }
+    }
+    var published map[string]any
+    if err := json.Unmarshal(publisher.events[1].Data, &published); err != nil || published["published"] != true || published["version"] != float64(1) {
+        t.Fatalf("expected the published definition as data, got %s", publisher.events[1].Data)
+    }
+}
@@ -1,8 +1,11 @@
 package workflow
 
 import (
+    "bytes"
+    "context"
     "encoding/json"
     "errors"
+    "fmt"
     "io"
     "net/http"
     "strconv"
@@ -11,65 +14,157 @@ import (
     "github.com/go-chi/chi/v5"
     "gorm.io/datatypes"
 
+    "github.com/pflow/components/outbox"
+    "github.com/pflow/shared/audit"
+    "github.com/pflow/shared/auth"
+    "github.com/pflow/shared/database"
     "github.com/pflow/shared/httpx"
 )
 
+var errEmptyBody = errors.New("request body is empty")
+
+// maxBPMNSize bounds the size of an imported BPMN document.
+const maxBPMNSize = 5 << 20
+
+var definitionListSpec = httpx.ListSpec{
+    SortFields: map[string]string{
+        "createdAt": "created_at",
+        "updatedAt": "updated_at",
+        "name":      "name",
+    },
+    DefaultSort: "updatedAt",
+    DefaultDesc: true,
+    BoolFilters: map[string]string{
+        "published": "published",
+    },
+    SearchColumns: []string{"name"},
+    SoftDelete:    true,
+}
+
 // Handler exposes workflow HTTP endpoints.
 type Handler struct {
-    repo Repository
+    repo          Repository
+    engine        *Engine
+    processEngine ProcessEngine
+    authz         auth.Enforcer
+    events        *outbox.EventStore
+    audit         *audit.Recorder
+}
+
+// HandlerOption customises the handler behaviour.
+type HandlerOption func(*Handler)
+
+// WithEngine enables the process instance endpoints backed by the given engine.
+func WithEngine(engine *Engine) HandlerOption {
+    return func(h *Handler) {
+        h.engine = engine
+    }
+}
+
+// WithProcessEngine deploys definitions to the given process engine when they are published.
+func WithProcessEngine(engine ProcessEngine) HandlerOption {
+    return func(h *Handler) {
+        h.processEngine = engine
+    }
+}
+
+// WithEvents records an event for every change in the transaction of the
+// change.
+func WithEvents(events *outbox.EventStore) HandlerOption {
+    return func(h *Handler) {
+        h.events = events
+    }
+}
+
+// WithAudit records every successful change in the audit log.
+func WithAudit(recorder *audit.Recorder) HandlerOption {
+    return func(h *Handler) {
+        h.audit = recorder
+    }
+}
+
+// WithAuthorization guards every route with the permission it requires.
+func WithAuthorization() HandlerOption {
+    return func(h *Handler) {
+        h.authz.Enabled = true
+    }
 }
 
 // NewHandler builds a workflow Handler backed by the given repository.
-func NewHandler(repo Repository) *Handler {
-    return &Handler{repo: repo}
+func NewHandler(repo Repository, opts ...HandlerOption) *Handler {
+    handler := &Handler{repo: repo}
+    for _, opt := range opts {
+        if opt != nil {
+            opt(handler)
+        }
+    }
+    return handler
 }
 
-// Mount registers the workflow routes under the provided base path.
+// Mount registers the workflow routes under the provided base path. When an engine is
+// configured, process instances are served under /instances.
 func (h *Handler) Mount(router chi.Router, basePath string) {
     path := strings.TrimSpace(basePath)
     if path == "" {
         path = "/workflows"
     }
 
+    require := h.authz.Require
     router.Route(path, func(r chi.Router) {
-        r.Get("/", h.listDefinitions)
-        r.Post("/", h.createDefinition)
+        r.With(require(auth.PermissionWorkflowView)).Get("/", h.listDefinitions)
+        r.With(require(auth.PermissionWorkflowEdit)).Post("/", h.createDefinition)
+        r.With(require(auth.PermissionWorkflowEdit)).Post("/validate", h.validateBlueprint)
+        r.With(require(auth.PermissionWorkflowEdit)).Post("/import", h.importBPMN)
         r.Route("/{id}", func(r chi.Router) {
-            r.Get("/", h.getDefinition)
-            r.Put("/", h.updateDefinition)
-            r.Delete("/", h.deleteDefinition)
-            r.Post("/publish", h.publishDefinition)
+            r.With(require(auth.PermissionWorkflowView)).Get("/", h.getDefinition)
+            r.With(require(auth.PermissionWorkflowEdit)).Put("/", h.updateDefinition)
+            r.With(require(auth.PermissionWorkflowDelete)).Delete("/", h.deleteDefinition)
+            r.With(require(auth.PermissionWorkflowDelete)).Post("/restore", h.restoreDefinition)
+            r.With(require(auth.PermissionWorkflowPublish)).Post("/publish", h.publishDefinition)
+            r.With(require(auth.PermissionWorkflowView)).Get("/versions", h.listVersions)
+            r.With(require(auth.PermissionWorkflowView)).Get("/versions/{version}", h.getVersion)
+            r.With(require(auth.PermissionWorkflowPublish)).Post("/versions/{version}/rollback", h.rollbackVersion)
+            r.With(require(auth.PermissionWorkflowView)).Get("/diff", h.diffVersions)
+            r.With(require(auth.PermissionWorkflowView)).Get("/bpmn", h.exportBPMN)
+            if h.engine != nil {
+                r.With(require(auth.PermissionWorkflowRun)).Post("/instances", h.startInstance)
+            }
         })
     })
+
+    if h.engine != nil {
+        router.Route("/instances/{id}", func(r chi.Router) {
+            r.With(require(auth.PermissionWorkflowView)).Get("/", h.getInstance)
+            r.With(require(auth.PermissionWorkflowRun)).Post("/tasks/{taskId}/complete", h.completeTask)
+        })
+    }
 }
 
 type createDefinitionRequest struct {
     Name        string         `json:"name"`
-    Version     int            `json:"version"`
     Description string         `json:"description"`
     Blueprint   map[string]any `json:"blueprint"`
 }
 
 type updateDefinitionRequest struct {
     Name        *string        `json:"name"`
-    Version     *int           `json:"version"`
     Description *string        `json:"description"`
     Blueprint   map[string]any `json:"blueprint"`
     Published   *bool          `json:"published"`
 }
 
 func (h *Handler) listDefinitions(w http.ResponseWriter, r *http.Request) {
-    var publishedFilter *bool
-    if value := strings.TrimSpace(r.URL.Query().Get("published")); value != "" {
-        parsed, err := strconv.ParseBool(value)
-        if err != nil {
-            httpx.Error(w, http.StatusBadRequest, "invalid published filter")
-            return
-        }
-        publishedFilter = &parsed
+    query, err := httpx.ParseListQuery(r, definitionListSpec)
+    if err != nil {
+        httpx.Error(w, http.StatusBadRequest, err.Error())
+        return
+    }
+    if query.IncludeDeleted && !h.authz.Allows(r, auth.PermissionWorkflowDelete) {
+        httpx.Error(w, http.StatusForbidden, "listing deleted workflows requires "+auth.PermissionWorkflowDelete)
+        return
     }
 
-    definitions, err := h.repo.List(r.Context(), publishedFilter)
+    definitions, page, err := h.repo.List(r.Context(), query)
     if err != nil {
         httpx.Error(w, http.StatusInternalServerError, err.Error())
         return
@@ -80,7 +175,7 @@ func (h *Handler) listDefinitions(w http.ResponseWriter, r *http.Request) {
         items = append(items, entity.ToDTO())
     }
 
-    httpx.JSON(w, http.StatusOK, map[string]any{"data": items})
+    httpx.List(w, items, page)
 }
 
 func (h *Handler) createDefinition(w http.ResponseWriter, r *http.Request) {
@@ -96,24 +191,28 @@ func (h *Handler) createDefinition(w http.ResponseWriter, r *http.Request) {
         return
     }
 
-    version := payload.Version
-    if version <= 0 {
-        version = 1
-    }
-
     entity := &Definition{
         Name:        name,
-        Version:     version,
         Description: strings.TrimSpace(payload.Description),
     }
     if payload.Blueprint != nil {
+        if !checkBlueprint(w, payload.Blueprint) {
+            return
+        }
         entity.Blueprint = datatypes.JSONMap(payload.Blueprint)
     }
 
-    if err := h.repo.Create(r.Context(), entity); err != nil {
+    err := h.events.Transaction(r.Context(), func(ctx context.Context) error {
+        if err := h.repo.Create(ctx, entity); err != nil {
+            return err
+        }
+        return h.events.Emit(ctx, EventWorkflowCreated, entity.ID, entity.ToDTO())
+    })
+    if err != nil {
         httpx.Error(w, http.StatusInternalServerError, err.Error())
         return
     }
+    h.audit.Record(r.Context(), audit.Change{Action: EventWorkflowCreated, ResourceID: entity.ID, After: entity.ToDTO()})
 
     httpx.JSON(w, http.StatusCreated, map[string]any{"data": entity.ToDTO()})
 }
@@ -150,35 +249,63 @@ func (h *Handler) updateDefinition(w http.ResponseWriter, r *http.Request) {
         }
         updates["name"] = name
     }
-    if payload.Version != nil {
-        if *payload.Version <= 0 {
-            httpx.Error(w, http.StatusBadRequest, "version must be positive")
-            return
-        }
-        updates["version"] = *payload.Version
-    }
     if payload.Description != nil {
         updates["description"] = strings.TrimSpace(*payload.Description)
     }
     if payload.Blueprint != nil {
+        if !checkBlueprint(w, payload.Blueprint) {
+            return
+        }
         updates["blueprint"] = datatypes.JSONMap(payload.Blueprint)
     }
+    publish := false
     if payload.Published != nil {
-        updates["published"] = *payload.Published
+        // Publishing freezes and deploys a version, so it needs the same
+        // permission here as through POST /publish.
+        if *payload.Published && !h.authz.Allows(r, auth.PermissionWorkflowPublish) {
+            httpx.Error(w, http.StatusForbidden, "publishing a workflow requires "+auth.PermissionWorkflowPublish)
+            return
+        }
+        if *payload.Published && payload.Blueprint == nil {
+            if _, ok := h.checkStoredBlueprint(w, r, id); !ok {
+                return
+            }
+        }
+        if *payload.Published {
+            publish = true
+        } else {
+            updates["published"] = false
+        }
     }
 
-    if len(updates) == 0 {
+    if len(updates) == 0 && !publish {
         httpx.Error(w, http.StatusBadRequest, "no updates provided")
         return
     }
 
-    entity, err := h.repo.Update(r.Context(), id, updates)
+    before, err := h.repo.Find(r.Context(), id)
     if err != nil {
-        if IsNotFound(err) {
-            httpx.Error(w, http.StatusNotFound, "workflow not found")
-            return
+        renderEngineError(w, err, "workflow not found")
+        return
+    }
+    entity := before
+    if len(updates) > 0 {
+        err = h.events.Transaction(r.Context(), func(ctx context.Context) error {
+            var err error
+            if entity, err = h.repo.Update(ctx, id, updates); err != nil {
+                return err
+            }
+            return h.events.Emit(ctx, EventWorkflowUpdated, entity.ID, entity.ToDTO())
+        })
+        if err == nil {
+            h.audit.Record(r.Context(), audit.Change{Action: EventWorkflowUpdated, ResourceID: entity.ID, Before: before.ToDTO(), After: entity.ToDTO()})
         }
-        httpx.Error(w, http.StatusInternalServerError, err.Error())
+    }
+    if err == nil && publish {
+        entity, err = h.publish(r.Context(), entity, PublishOptions{Actor: requestActor(r, "")})
+    }
+    if err != nil {
+        renderEngineError(w, err, "workflow not found")
         return
     }
 
@@ -187,7 +314,22 @@ func (h *Handler) updateDefinition(w http.ResponseWriter, r *http.Request) {
 
 func (h *Handler) deleteDefinition(w http.ResponseWriter, r *http.Request) {
     id := chi.URLParam(r, "id")
-    if err := h.repo.Delete(r.Context(), id); err != nil {
+    before, err := h.repo.Find(r.Context(), id)
+    if err != nil {
+        if IsNotFound(err) {
+            httpx.Error(w, http.StatusNotFound, "workflow not found")
+            return
+        }
+        httpx.Error(w, http.StatusInternalServerError, err.Error())
+        return
+    }
+    err = h.events.Transaction(r.Context(), func(ctx context.Context) error {
+        if err := h.repo.Delete(ctx, id); err != nil {
+            return err
+        }
+        return h.events.Emit(ctx, EventWorkflowDeleted, id, map[string]any{"id": id})
+    })
+    if err != nil {
         if IsNotFound(err) {
             httpx.Error(w, http.StatusNotFound, "workflow not found")
             return
@@ -195,13 +337,256 @@ func (h *Handler) deleteDefinition(w http.ResponseWriter, r *http.Request) {
         httpx.Error(w, http.StatusInternalServerError, err.Error())
         return
     }
+    h.audit.Record(r.Context(), audit.Change{Action: EventWorkflowDeleted, ResourceID: id, Before: before.ToDTO()})
 
     w.WriteHeader(http.StatusNoContent)
 }
 
+// restoreDefinition undoes the deletion of a definition that was not purged yet.
+func (h *Handler) restoreDefinition(w http.ResponseWriter, r *http.Request) {
+    var entity *Definition
+    err := h.events.Transaction(r.Context(), func(ctx context.Context) error {
+        var err error
+        if entity, err = h.repo.Restore(ctx, chi.URLParam(r, "id")); err != nil {
+            return err
+        }
+        return h.events.Emit(ctx, EventWorkflowRestored, entity.ID, entity.ToDTO())
+    })
+    if err != nil {
+        switch {
+        case IsNotFound(err):
+            httpx.Error(w, http.StatusNotFound, "workflow not found")
+        case errors.Is(err, database.ErrNotDeleted):
+            httpx.Error(w, http.StatusConflict, "workflow is not deleted")
+        default:
+            httpx.Error(w, http.StatusInternalServerError, err.Error())
+        }
+        return
+    }
+    h.audit.Record(r.Context(), audit.Change{Action: EventWorkflowRestored, ResourceID: entity.ID, After: entity.ToDTO()})
+
+    httpx.JSON(w, http.StatusOK, map[string]any{"data": entity.ToDTO()})
+}
+
 func (h *Handler) publishDefinition(w http.ResponseWriter, r *http.Request) {
-    id := chi.URLParam(r, "id")
-    entity, err := h.repo.Publish(r.Context(), id)
+    before, ok := h.checkStoredBlueprint(w, r, chi.URLParam(r, "id"))
+    if !ok {
+        return
+    }
+
+    entity, err := h.publish(r.Context(), before, PublishOptions{Actor: requestActor(r, "")})
+    if err != nil {
+        renderEngineError(w, err, "workflow not found")
+        return
+    }
+
+    httpx.JSON(w, http.StatusOK, map[string]any{"data": entity.ToDTO()})
+}
+
+func (h *Handler) listVersions(w http.ResponseWriter, r *http.Request) {
+    versions, err := h.repo.Versions(r.Context(), chi.URLParam(r, "id"))
+    if err != nil {
+        renderEngineError(w, err, "workflow not found")
+        return
+    }
+
+    items := make([]map[string]any, 0, len(versions))
+    for _, version := range versions {
+        items = append(items, version.ToDTO())
+    }
+    httpx.JSON(w, http.StatusOK, map[string]any{"data": items})
+}
+
+func (h *Handler) getVersion(w http.ResponseWriter, r *http.Request) {
+    version, ok := h.findVersion(w, r, chi.URLParam(r, "id"), chi.URLParam(r, "version"))
+    if !ok {
+        return
+    }
+    httpx.JSON(w, http.StatusOK, map[string]any{"data": version.ToDTO()})
+}
+
+// rollbackVersion republishes an earlier version as a new version; the draft is
+// replaced by the republished content.
+func (h *Handler) rollbackVersion(w http.ResponseWriter, r *http.Request) {
+    version, ok := h.findVersion(w, r, chi.URLParam(r, "id"), chi.URLParam(r, "version"))
+    if !ok {
+        return
+    }
+    if !checkBlueprint(w, version.Blueprint) {
+        return
+    }
+    before, err := h.repo.Find(r.Context(), version.DefinitionID)
+    if err != nil {
+        renderEngineError(w, err, "workflow not found")
+        return
+    }
+
+    entity, err := h.publish(r.Context(), before, PublishOptions{
+        Actor:       requestActor(r, ""),
+        FromVersion: version.Version,
+    })
+    if err != nil {
+        renderEngineError(w, err, "workflow not found")
+        return
+    }
+
+    httpx.JSON(w, http.StatusOK, map[string]any{"data": entity.ToDTO()})
+}
+
+// diffVersions compares two versions of a definition. from and to take a version
+// number or "draft"; from defaults to the latest published version and to to the draft.
+func (h *Handler) diffVersions(w http.ResponseWriter, r *http.Request) {
+    entity, err := h.repo.Find(r.Context(), chi.URLParam(r, "id"))
+    if err != nil {
+        renderEngineError(w, err, "workflow not found")
+        return
+    }
+
+    query := r.URL.Query()
+    from, ok := h.snapshot(w, r, entity, query.Get("from"), strconv.Itoa(entity.Version))
+    if !ok {
+        return
+    }
+    to, ok := h.snapshot(w, r, entity, query.Get("to"), draftVersion)
+    if !ok {
+        return
+    }
+
+    diff := DiffDefinitions(from.definition, to.definition)
+    httpx.JSON(w, http.StatusOK, map[string]any{"data": map[string]any{
+        "from":      from.label,
+        "to":        to.label,
+        "identical": diff.Empty(),
+        "fields":    diff.Fields,
+        "steps":     diff.Steps,
+    }})
+}
+
+// draftVersion names the editable draft wherever a version number is accepted.
+const draftVersion = "draft"
+
+type versionSnapshot struct {
+    label      any
+    definition Definition
+}
+
+// snapshot resolves a version reference to the content it names. Version 0 stands for
+// the empty definition that exists before the first publish.
+func (h *Handler) snapshot(w http.ResponseWriter, r *http.Request, entity *Definition, raw, fallback string) (versionSnapshot, bool) {
+    raw = strings.TrimSpace(raw)
+    if raw == "" {
+        raw = fallback
+    }
+    if raw == draftVersion {
+        return versionSnapshot{label: draftVersion, definition: *entity}, true
+    }
+    if raw == "0" {
+        return versionSnapshot{label: 0, definition: Definition{ID: entity.ID}}, true
+    }
+
+    version, ok := h.findVersion(w, r, entity.ID, raw)
+    if !ok {
+        return versionSnapshot{}, false
+    }
+    return versionSnapshot{label: version.Version, definition: Definition{
+        ID:          entity.ID,
+        Name:        version.Name,
+        Version:     version.Version,
+        Description: version.Description,
+        Blueprint:   version.Blueprint,
+    }}, true
+}
+
+func (h *Handler) findVersion(w http.ResponseWriter, r *http.Request, id, raw string) (*DefinitionVersion, bool) {
+    number, err := strconv.Atoi(raw)
+    if err != nil || number <= 0 {
+        httpx.Error(w, http.StatusBadRequest, fmt.Sprintf("invalid version %q", raw))
+        return nil, false
+    }
+
+    version, err := h.repo.FindVersion(r.Context(), id, number)
+    if err != nil {
+        renderEngineError(w, err, "workflow version not found")
+        return nil, false
+    }
+    return version, true
+}
+
+// publish freezes a definition as a new version and announces it in the same
+// transaction. With a process engine configured the committed version is then
+// deployed and the assigned process key and version are recorded; a failed
+// deployment leaves the version published and announced.
+func (h *Handler) publish(ctx context.Context, before *Definition, opts PublishOptions) (*Definition, error) {
+    opts.Announce = func(ctx context.Context, published Definition) error {
+        return h.events.Emit(ctx, EventWorkflowPublished, published.ID, published.ToDTO())
+    }
+    if h.processEngine != nil {
+        opts.Deploy = func(ctx context.Context, snapshot Definition) (Deployment, error) {
+            deployment, err := h.processEngine.Deploy(ctx, snapshot)
+            if err != nil && !errors.Is(err, ErrInvalidBlueprint) {
+                return Deployment{}, fmt.Errorf("%w: %v", ErrDeploymentFailed, err)
+            }
+            return deployment, err
+        }
+    }
+    entity, err := h.repo.Publish(ctx, before.ID, opts)
+    if entity == nil {
+        return nil, err
+    }
+    h.audit.Record(ctx, audit.Change{Action: EventWorkflowPublished, ResourceID: entity.ID, Before: before.ToDTO(), After: entity.ToDTO()})
+    return entity, err
+}
+
+type validateBlueprintRequest struct {
+    Blueprint map[string]any `json:"blueprint"`
+}
+
+// validateBlueprint is a dry run of the checks applied on save and publish.
+func (h *Handler) validateBlueprint(w http.ResponseWriter, r *http.Request) {
+    var payload validateBlueprintRequest
+    if err := decodeJSON(r, &payload); err != nil {
+        httpx.Error(w, http.StatusBadRequest, err.Error())
+        return
+    }
+
+    issues := ValidateBlueprint(payload.Blueprint)
+    if issues == nil {
+        issues = []BlueprintIssue{}
+    }
+    httpx.JSON(w, http.StatusOK, map[string]any{"data": map[string]any{
+        "valid":  len(issues) == 0,
+        "errors": issues,
+    }})
+}
+
+// checkBlueprint rejects a blueprint with structural issues.
+func checkBlueprint(w http.ResponseWriter, blueprint map[string]any) bool {
+    issues := ValidateBlueprint(blueprint)
+    if len(issues) == 0 {
+        return true
+    }
+    httpx.ErrorDetails(w, http.StatusUnprocessableEntity, ErrInvalidBlueprint.Error(), issues)
+    return false
+}
+
+// checkStoredBlueprint validates the persisted blueprint of a definition before
+// it is published and returns the definition.
+func (h *Handler) checkStoredBlueprint(w http.ResponseWriter, r *http.Request, id string) (*Definition, bool) {
+    entity, err := h.repo.Find(r.Context(), id)
+    if err != nil {
+        if IsNotFound(err) {
+            httpx.Error(w, http.StatusNotFound, "workflow not found")
+            return nil, false
+        }
+        httpx.Error(w, http.StatusInternalServerError, err.Error())
+        return nil, false
+    }
+    return entity, checkBlueprint(w, entity.Blueprint)
+}
+
+// exportBPMN renders a definition as a BPMN 2.0 XML document.
+func (h *Handler) exportBPMN(w http.ResponseWriter, r *http.Request) {
+    entity, err := h.repo.Find(r.Context(), chi.URLParam(r, "id"))
     if err != nil {
         if IsNotFound(err) {
             httpx.Error(w, http.StatusNotFound, "workflow not found")
@@ -211,7 +596,199 @@ func (h *Handler) publishDefinition(w http.ResponseWriter, r *http.Request) {
         return
     }
 
-    httpx.JSON(w, http.StatusOK, map[string]any{"data": entity.ToDTO()})
+    document, err := ExportBPMN(*entity)
+    if err != nil {
+        renderEngineError(w, err, "workflow not found")
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+    w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", entity.ID+".bpmn"))
+    w.WriteHeader(http.StatusOK)
+    _, _ = w.Write(document)
+}
+
+// importBPMN creates an unpublished definition from a BPMN 2.0 XML body. The name is
+// taken from the name query parameter or the process name.
+func (h *Handler) importBPMN(w http.ResponseWriter, r *http.Request) {
+    defer r.Body.Close()
+    data, err := io.ReadAll(io.LimitReader(r.Body, maxBPMNSize+1))
+    if err != nil {
+        httpx.Error(w, http.StatusBadRequest, err.Error())
+        return
+    }
+    if len(data) > maxBPMNSize {
+        httpx.Error(w, http.StatusRequestEntityTooLarge, "BPMN document is too large")
+        return
+    }
+    if len(bytes.TrimSpace(data)) == 0 {
+        httpx.Error(w, http.StatusBadRequest, errEmptyBody.Error())
+        return
+    }
+
+    process, err := ImportBPMN(data)
+    if err != nil {
+        var invalid *BlueprintError
+        if errors.As(err, &invalid) {
+            httpx.ErrorDetails(w, http.StatusUnprocessableEntity, ErrInvalidBlueprint.Error(), invalid.Issues)
+            return
+        }
+        httpx.Error(w, http.StatusUnprocessableEntity, err.Error())
+        return
+    }
+
+    name := strings.TrimSpace(r.URL.Query().Get("name"))
+    if name == "" {
+        name = process.Name
+    }
+    if len(name) < 2 {
+        httpx.Error(w, http.StatusBadRequest, "name must be at least 2 characters; set the process name or the name query parameter")
+        return
+    }
+
+    blueprint, err := process.Blueprint.toMap()
+    if err != nil {
+        httpx.Error(w, http.StatusInternalServerError, err.Error())
+        return
+    }
+    entity := &Definition{
+        Name:        name,
+        Description: process.Documentation,
+        Blueprint:   datatypes.JSONMap(blueprint),
+    }
+    err = h.events.Transaction(r.Context(), func(ctx context.Context) error {
+        if err := h.repo.Create(ctx, entity); err != nil {
+            return err
+        }
+        return h.events.Emit(ctx, EventWorkflowCreated, entity.ID, entity.ToDTO())
+    })
+    if err != nil {
+        httpx.Error(w, http.StatusInternalServerError, err.Error())
+        return
+    }
+    h.audit.Record(r.Context(), audit.Change{Action: EventWorkflowCreated, ResourceID: entity.ID, After: entity.ToDTO()})
+
+    httpx.JSON(w, http.StatusCreated, map[string]any{"data": entity.ToDTO()})
+}
+
+type startInstanceRequest struct {
+    Variables map[string]any `json:"variables"`
+    StartedBy string         `json:"startedBy"`
+}
+
+type completeTaskRequest struct {
+    Variables   map[string]any `json:"variables"`
+    CompletedBy string         `json:"completedBy"`
+}
+
+func (h *Handler) startInstance(w http.ResponseWriter, r *http.Request) {
+    var payload startInstanceRequest
+    if err := decodeOptionalJSON(r, &payload); err != nil {
+        httpx.Error(w, http.StatusBadRequest, err.Error())
+        return
+    }
+
+    var (
+        instance *ProcessInstance
+        tokens   []ProcessToken
+    )
+    err := h.events.Transaction(r.Context(), func(ctx context.Context) error {
+        var err error
+        if instance, tokens, err = h.engine.Start(ctx, chi.URLParam(r, "id"), payload.Variables, requestActor(r, payload.StartedBy)); err != nil {
+            return err
+        }
+        return h.emitInstance(ctx, EventWorkflowInstanceStarted, instance, tokens)
+    })
+    if err != nil {
+        renderEngineError(w, err, "workflow not found")
+        return
+    }
+    h.audit.Record(r.Context(), audit.Change{Action: EventWorkflowInstanceStarted, ResourceType: instanceResource, ResourceID: instance.ID, After: instance.ToDTO(tokens)})
+
+    httpx.JSON(w, http.StatusCreated, map[string]any{"data": instance.ToDTO(tokens)})
+}
+
+func (h *Handler) getInstance(w http.ResponseWriter, r *http.Request) {
+    instance, tokens, err := h.engine.Instance(r.Context(), chi.URLParam(r, "id"))
+    if err != nil {
+        renderEngineError(w, err, "instance not found")
+        return
+    }
+
+    httpx.JSON(w, http.StatusOK, map[string]any{"data": instance.ToDTO(tokens)})
+}
+
+func (h *Handler) completeTask(w http.ResponseWriter, r *http.Request) {
+    var payload completeTaskRequest
+    if err := decodeOptionalJSON(r, &payload); err != nil {
+        httpx.Error(w, http.StatusBadRequest, err.Error())
+        return
+    }
+
+    before, beforeTokens, err := h.engine.Instance(r.Context(), chi.URLParam(r, "id"))
+    if err != nil {
+        renderEngineError(w, err, "instance not found")
+        return
+    }
+    var (
+        instance *ProcessInstance
+        tokens   []ProcessToken
+    )
+    err = h.events.Transaction(r.Context(), func(ctx context.Context) error {
+        var err error
+        if instance, tokens, err = h.engine.CompleteTask(ctx, before.ID, chi.URLParam(r, "taskId"), payload.Variables, requestActor(r, payload.CompletedBy)); err != nil {
+            return err
+        }
+        return h.emitInstance(ctx, EventWorkflowTaskCompleted, instance, tokens)
+    })
+    if err != nil {
+        renderEngineError(w, err, "instance not found")
+        return
+    }
+    h.audit.Record(r.Context(), audit.Change{Action: EventWorkflowTaskCompleted, ResourceType: instanceResource, ResourceID: instance.ID, Before: before.ToDTO(beforeTokens), After: instance.ToDTO(tokens)})
+
+    httpx.JSON(w, http.StatusOK, map[string]any{"data": instance.ToDTO(tokens)})
+}
+
+func renderEngineError(w http.ResponseWriter, err error, notFound string) {
+    var invalid *BlueprintError
+    switch {
+    case IsNotFound(err):
+        httpx.Error(w, http.StatusNotFound, notFound)
+    case errors.Is(err, ErrTaskNotFound):
+        httpx.Error(w, http.StatusNotFound, err.Error())
+    case errors.Is(err, ErrDefinitionNotPublished), errors.Is(err, ErrInstanceNotRunning):
+        httpx.Error(w, http.StatusConflict, err.Error())
+    case errors.As(err, &invalid):
+        httpx.ErrorDetails(w, http.StatusUnprocessableEntity, ErrInvalidBlueprint.Error(), invalid.Issues)
+    case errors.Is(err, ErrInvalidBlueprint):
+        httpx.Error(w, http.StatusUnprocessableEntity, err.Error())
+    case errors.Is(err, ErrDeploymentFailed):
+        httpx.Error(w, http.StatusBadGateway, err.Error())
+    default:
+        httpx.Error(w, http.StatusInternalServerError, err.Error())
+    }
+}
+
+// requestActor resolves who performed an action: the authenticated principal,
+// which a request body cannot override, else an explicit value and then the
+// X-User-ID header.
+func requestActor(r *http.Request, explicit string) string {
+    if principal, ok := auth.FromContext(r.Context()); ok && principal.UserID != "" {
+        return principal.UserID
+    }
+    if actor := strings.TrimSpace(explicit); actor != "" {
+        return actor
+    }
+    return strings.TrimSpace(r.Header.Get("X-User-ID"))
+}
+
+// decodeOptionalJSON behaves like decodeJSON but accepts an empty body.
+func decodeOptionalJSON(r *http.Request, v any) error {
+    if err := decodeJSON(r, v); err != nil && !errors.Is(err, errEmptyBody) {
+        return err
+    }
+    return nil
 }
 
 func decodeJSON(r *http.Request, v any) error {
@@ -220,7 +797,7 @@ func decodeJSON(r *http.Request, v any) error {
     decoder.DisallowUnknownFields()
     if err := decoder.Decode(v); err != nil {
         if errors.Is(err, io.EOF) {
-            return errors.New("request body is empty")
+            return errEmptyBody
         }
         return err
     }
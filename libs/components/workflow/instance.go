@@ -0,0 +1,132 @@
+package workflow
+
+import (
+    "time"
+
+    "github.com/google/uuid"
+    "gorm.io/datatypes"
+    "gorm.io/gorm"
+)
+
+const (
+    // InstanceRunning marks an instance that still has active tokens.
+    InstanceRunning = "running"
+    // InstanceCompleted marks an instance that reached an end step.
+    InstanceCompleted = "completed"
+    // InstanceFailed marks an instance stopped by a service task or routing error.
+    InstanceFailed = "failed"
+)
+
+const (
+    // TokenActive marks a token that is waiting on a user task.
+    TokenActive = "active"
+    // TokenWaiting marks a token parked at a parallel join until every branch arrived.
+    TokenWaiting = "waiting"
+    // TokenCompleted marks a token whose step has finished.
+    TokenCompleted = "completed"
+)
+
+// ProcessInstance is a single execution of a published workflow definition. The
+// blueprint is copied at start so later edits of the definition do not affect it.
+type ProcessInstance struct {
+    ID                string            `json:"id" gorm:"type:uuid;primaryKey"`
+    TenantID          string            `json:"tenantId" gorm:"type:varchar(64);not null;default:default;index"`
+    DefinitionID      string            `json:"definitionId" gorm:"type:uuid;not null;index"`
+    DefinitionVersion int               `json:"definitionVersion" gorm:"not null"`
+    Status            string            `json:"status" gorm:"not null;index"`
+    Blueprint         datatypes.JSONMap `json:"-" gorm:"type:jsonb"`
+    Variables         datatypes.JSONMap `json:"variables" gorm:"type:jsonb"`
+    StartedBy         string            `json:"startedBy"`
+    ErrorMessage      string            `json:"errorMessage"`
+    CreatedAt         time.Time         `json:"createdAt"`
+    UpdatedAt         time.Time         `json:"updatedAt"`
+    CompletedAt       *time.Time        `json:"completedAt"`
+}
+
+// ProcessToken records the position of an instance within its blueprint. Tokens
+// waiting on a user task are exposed as tasks; their ID is the task ID.
+type ProcessToken struct {
+    ID          string     `json:"id" gorm:"type:uuid;primaryKey"`
+    InstanceID  string     `json:"instanceId" gorm:"type:uuid;not null;index"`
+    Sequence    int        `json:"sequence" gorm:"not null"`
+    StepID      string     `json:"stepId" gorm:"not null"`
+    StepType    string     `json:"stepType" gorm:"not null"`
+    Status      string     `json:"status" gorm:"not null;index"`
+    Assignee    string     `json:"assignee"`
+    CompletedBy string     `json:"completedBy"`
+    CreatedAt   time.Time  `json:"createdAt"`
+    UpdatedAt   time.Time  `json:"updatedAt"`
+    CompletedAt *time.Time `json:"completedAt"`
+}
+
+// BeforeCreate ensures a UUID exists.
+func (i *ProcessInstance) BeforeCreate(tx *gorm.DB) error {
+    if i.ID == "" {
+        i.ID = uuid.NewString()
+    }
+    return nil
+}
+
+// BeforeCreate ensures a UUID exists.
+func (t *ProcessToken) BeforeCreate(tx *gorm.DB) error {
+    if t.ID == "" {
+        t.ID = uuid.NewString()
+    }
+    return nil
+}
+
+// ToDTO converts an instance and its tokens into a response payload. Active user
+// task tokens are listed under "tasks".
+func (i ProcessInstance) ToDTO(tokens []ProcessToken) map[string]any {
+    variables := map[string]any{}
+    if i.Variables != nil {
+        variables = map[string]any(i.Variables)
+    }
+
+    tasks := make([]map[string]any, 0)
+    history := make([]map[string]any, 0, len(tokens))
+    for _, token := range tokens {
+        history = append(history, token.ToDTO())
+        if token.Status == TokenActive && token.StepType == string(StepUserTask) {
+            tasks = append(tasks, token.ToDTO())
+        }
+    }
+
+    payload := map[string]any{
+        "id":                i.ID,
+        "definitionId":      i.DefinitionID,
+        "definitionVersion": i.DefinitionVersion,
+        "status":            i.Status,
+        "variables":         variables,
+        "startedBy":         i.StartedBy,
+        "tasks":             tasks,
+        "tokens":            history,
+        "createdAt":         i.CreatedAt,
+        "updatedAt":         i.UpdatedAt,
+    }
+    if i.ErrorMessage != "" {
+        payload["errorMessage"] = i.ErrorMessage
+    }
+    if i.CompletedAt != nil {
+        payload["completedAt"] = i.CompletedAt
+    }
+    return payload
+}
+
+// ToDTO converts a token into a response payload.
+func (t ProcessToken) ToDTO() map[string]any {
+    payload := map[string]any{
+        "id":        t.ID,
+        "sequence":  t.Sequence,
+        "stepId":    t.StepID,
+        "stepType":  t.StepType,
+        "status":    t.Status,
+        "assignee":  t.Assignee,
+        "createdAt": t.CreatedAt,
+    }
+    if t.CompletedAt != nil {
+        payload["completedAt"] = t.CompletedAt
+        payload["completedBy"] = t.CompletedBy
+    }
+    return payload
+}
@@ -0,0 +1,104 @@
+package workflow
+
+import (
+    "context"
+
+    "gorm.io/gorm"
+    "gorm.io/gorm/clause"
+
+    "github.com/pflow/shared/database"
+    "github.com/pflow/shared/tenant"
+)
+
+// InstanceStore persists process instances and their tokens.
+type InstanceStore interface {
+    Create(ctx context.Context, instance *ProcessInstance, tokens []ProcessToken) error
+    Find(ctx context.Context, id string) (*ProcessInstance, []ProcessToken, error)
+    // Update locks the instance, hands it to mutate together with its tokens and
+    // persists the instance and the returned tokens atomically.
+    Update(ctx context.Context, id string, mutate func(instance *ProcessInstance, tokens []ProcessToken) ([]ProcessToken, error)) (*ProcessInstance, []ProcessToken, error)
+}
+
+// GormInstanceRepository implements InstanceStore using GORM. Instances are
+// scoped to the tenant of the request context.
+type GormInstanceRepository struct {
+    db *gorm.DB
+}
+
+// NewInstanceRepository constructs an instance repository.
+func NewInstanceRepository(db *gorm.DB) *GormInstanceRepository {
+    return &GormInstanceRepository{db: db}
+}
+
+// Create persists a new instance with its initial tokens.
+func (r *GormInstanceRepository) Create(ctx context.Context, instance *ProcessInstance, tokens []ProcessToken) error {
+    instance.TenantID = tenant.ID(ctx)
+    return database.Conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+        if err := tx.Create(instance).Error; err != nil {
+            return err
+        }
+        for i := range tokens {
+            tokens[i].InstanceID = instance.ID
+        }
+        if len(tokens) == 0 {
+            return nil
+        }
+        return tx.Create(&tokens).Error
+    })
+}
+
+// Find returns an instance and its tokens in the order they were created.
+func (r *GormInstanceRepository) Find(ctx context.Context, id string) (*ProcessInstance, []ProcessToken, error) {
+    return loadInstance(database.Conn(ctx, r.db).Scopes(database.TenantScope(ctx)), id)
+}
+
+// Update applies mutate to an instance under a row lock.
+func (r *GormInstanceRepository) Update(ctx context.Context, id string, mutate func(instance *ProcessInstance, tokens []ProcessToken) ([]ProcessToken, error)) (*ProcessInstance, []ProcessToken, error) {
+    var (
+        instance *ProcessInstance
+        tokens   []ProcessToken
+    )
+    err := database.Conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+        var err error
+        instance, tokens, err = loadInstance(tx.Scopes(database.TenantScope(ctx)).Clauses(clause.Locking{Strength: "UPDATE"}), id)
+        if err != nil {
+            return err
+        }
+
+        tokens, err = mutate(instance, tokens)
+        if err != nil {
+            return err
+        }
+
+        if err := tx.Save(instance).Error; err != nil {
+            return err
+        }
+        for i := range tokens {
+            tokens[i].InstanceID = instance.ID
+            if err := tx.Save(&tokens[i]).Error; err != nil {
+                return err
+            }
+        }
+        return nil
+    })
+    if err != nil {
+        return nil, nil, err
+    }
+    return instance, tokens, nil
+}
+
+func loadInstance(tx *gorm.DB, id string) (*ProcessInstance, []ProcessToken, error) {
+    var instance ProcessInstance
+    if err := tx.First(&instance, "id = ?", id).Error; err != nil {
+        return nil, nil, err
+    }
+
+    var tokens []ProcessToken
+    if err := tx.Session(&gorm.Session{NewDB: true}).
+        Where("instance_id = ?", id).
+        Order("sequence ASC").
+        Find(&tokens).Error; err != nil {
+        return nil, nil, err
+    }
+    return &instance, tokens, nil
+}
@@ -0,0 +1,20 @@
+package workflow
+
+import (
+    "gorm.io/gorm"
+
+    "github.com/pflow/components/outbox"
+)
+
+// Migrate creates the workflow schema and its event outbox. Definitions, versions and instances that
+// predate tenants belong to the default tenant, and version numbers become
+// unique per tenant instead of globally.
+func Migrate(db *gorm.DB) error {
+    if err := db.AutoMigrate(&Definition{}, &DefinitionVersion{}, &ProcessInstance{}, &ProcessToken{}, &outbox.Message{}); err != nil {
+        return err
+    }
+    if db.Migrator().HasIndex(&DefinitionVersion{}, "idx_definition_versions_name_version") {
+        return db.Migrator().DropIndex(&DefinitionVersion{}, "idx_definition_versions_name_version")
+    }
+    return nil
+}
@@ -8,16 +8,46 @@ import (
     "gorm.io/gorm"
 )
 
-// Definition captures a workflow blueprint stored in the workflow service.
+// Definition captures a workflow blueprint stored in the workflow service. The
+// definition itself is the editable draft; publishing freezes it as a
+// DefinitionVersion and Version points at the latest published version (0 while the
+// definition has never been published).
 type Definition struct {
     ID          string            `json:"id" gorm:"type:uuid;primaryKey"`
+    TenantID    string            `json:"tenantId" gorm:"type:varchar(64);not null;default:default;index"`
     Name        string            `json:"name" gorm:"not null"`
-    Version     int               `json:"version" gorm:"not null;default:1"`
+    Version     int               `json:"version" gorm:"not null;default:0"`
     Description string            `json:"description"`
     Blueprint   datatypes.JSONMap `json:"blueprint" gorm:"type:jsonb"`
     Published   bool              `json:"published" gorm:"index"`
     CreatedAt   time.Time         `json:"createdAt"`
     UpdatedAt   time.Time         `json:"updatedAt"`
+    DeletedAt   gorm.DeletedAt    `json:"deletedAt,omitempty" gorm:"index"`
+
+    // ProcessKey and EngineVersion identify the latest deployment to the external
+    // process engine; they stay empty when no engine is configured. DeployError
+    // holds why deploying the current version failed, until a deployment succeeds.
+    ProcessKey    string     `json:"processKey"`
+    EngineVersion int        `json:"engineVersion"`
+    DeployedAt    *time.Time `json:"deployedAt"`
+    DeployError   string     `json:"deployError"`
+}
+
+// DefinitionVersion is an immutable snapshot of a definition taken when it was
+// published. Version numbers increase per definition name within a tenant.
+type DefinitionVersion struct {
+    ID            string            `json:"id" gorm:"type:uuid;primaryKey"`
+    TenantID      string            `json:"tenantId" gorm:"type:varchar(64);not null;default:default;uniqueIndex:idx_definition_versions_tenant_name_version,priority:1"`
+    DefinitionID  string            `json:"definitionId" gorm:"type:uuid;not null;index"`
+    Name          string            `json:"name" gorm:"not null;uniqueIndex:idx_definition_versions_tenant_name_version,priority:2"`
+    Version       int               `json:"version" gorm:"not null;uniqueIndex:idx_definition_versions_tenant_name_version,priority:3"`
+    Description   string            `json:"description"`
+    Blueprint     datatypes.JSONMap `json:"blueprint" gorm:"type:jsonb"`
+    SourceVersion int               `json:"sourceVersion"`
+    PublishedBy   string            `json:"publishedBy"`
+    ProcessKey    string            `json:"processKey"`
+    EngineVersion int               `json:"engineVersion"`
+    CreatedAt     time.Time         `json:"createdAt"`
 }
 
 // BeforeCreate ensures a UUID exists.
@@ -28,6 +58,25 @@ func (d *Definition) BeforeCreate(tx *gorm.DB) error {
     return nil
 }
 
+// BeforeCreate ensures a UUID exists.
+func (v *DefinitionVersion) BeforeCreate(tx *gorm.DB) error {
+    if v.ID == "" {
+        v.ID = uuid.NewString()
+    }
+    return nil
+}
+
+func (d Definition) sortValue(column string) (any, string) {
+    switch column {
+    case "created_at":
+        return d.CreatedAt, d.ID
+    case "name":
+        return d.Name, d.ID
+    default:
+        return d.UpdatedAt, d.ID
+    }
+}
+
 // ToDTO converts a definition into a response payload.
 func (d Definition) ToDTO() map[string]any {
     payload := map[string]any{
@@ -39,10 +88,48 @@ func (d Definition) ToDTO() map[string]any {
         "createdAt":   d.CreatedAt,
         "updatedAt":   d.UpdatedAt,
     }
+    if d.ProcessKey != "" {
+        payload["processKey"] = d.ProcessKey
+        payload["engineVersion"] = d.EngineVersion
+        payload["deployedAt"] = d.DeployedAt
+    }
+    if d.DeployError != "" {
+        payload["deployError"] = d.DeployError
+    }
     if d.Blueprint != nil {
         payload["blueprint"] = map[string]any(d.Blueprint)
     } else {
         payload["blueprint"] = map[string]any{}
     }
+    if d.DeletedAt.Valid {
+        payload["deletedAt"] = d.DeletedAt.Time
+    }
+    return payload
+}
+
+// ToDTO converts a version into a response payload. SourceVersion is set when the
+// version was created by rolling back to an earlier one.
+func (v DefinitionVersion) ToDTO() map[string]any {
+    payload := map[string]any{
+        "id":           v.ID,
+        "definitionId": v.DefinitionID,
+        "name":         v.Name,
+        "version":      v.Version,
+        "description":  v.Description,
+        "publishedBy":  v.PublishedBy,
+        "createdAt":    v.CreatedAt,
+    }
+    if v.SourceVersion > 0 {
+        payload["sourceVersion"] = v.SourceVersion
+    }
+    if v.ProcessKey != "" {
+        payload["processKey"] = v.ProcessKey
+        payload["engineVersion"] = v.EngineVersion
+    }
+    if v.Blueprint != nil {
+        payload["blueprint"] = map[string]any(v.Blueprint)
+    } else {
+        payload["blueprint"] = map[string]any{}
+    }
     return payload
 }
@@ -0,0 +1,199 @@
+package workflow
+
+import (
+    "bytes"
+    "context"
+    "errors"
+    "fmt"
+    "strconv"
+    "sync"
+    "time"
+)
+
+var (
+    // ErrDeploymentFailed wraps errors returned by a ProcessEngine while publishing.
+    ErrDeploymentFailed = errors.New("process engine deployment failed")
+    // ErrProcessNotFound is returned when a process or process instance key is unknown.
+    ErrProcessNotFound = errors.New("process not found")
+    // ErrJobNotFound is returned when completing a job that does not exist or is done.
+    ErrJobNotFound = errors.New("job not found")
+)
+
+// Deployment describes a definition deployed to an external process engine.
+type Deployment struct {
+    ProcessID  string
+    ProcessKey string
+    Version    int
+    DeployedAt time.Time
+}
+
+// ProcessEngine deploys definitions to and drives instances on an external BPMN
+// engine such as Camunda 8. Keys are the engine's identifiers rendered as strings.
+type ProcessEngine interface {
+    Deploy(ctx context.Context, definition Definition) (Deployment, error)
+    StartInstance(ctx context.Context, processKey string, variables map[string]any) (string, error)
+    CancelInstance(ctx context.Context, instanceKey string) error
+    CompleteJob(ctx context.Context, jobKey string, variables map[string]any) error
+}
+
+// MemoryProcessEngine is an in-process ProcessEngine for tests and local development.
+// Like Zeebe it assigns a new version only when the deployed BPMN changed.
+type MemoryProcessEngine struct {
+    mu        sync.Mutex
+    nextKey   int64
+    processes map[string][]memoryProcess
+    instances map[string]*MemoryInstance
+    jobs      map[string]string
+}
+
+type memoryProcess struct {
+    deployment Deployment
+    resource   []byte
+}
+
+// MemoryInstance is a process instance held by a MemoryProcessEngine.
+type MemoryInstance struct {
+    Key        string
+    ProcessKey string
+    Variables  map[string]any
+    Canceled   bool
+}
+
+// NewMemoryProcessEngine constructs an empty in-memory engine.
+func NewMemoryProcessEngine() *MemoryProcessEngine {
+    return &MemoryProcessEngine{
+        nextKey:   2251799813685248,
+        processes: map[string][]memoryProcess{},
+        instances: map[string]*MemoryInstance{},
+        jobs:      map[string]string{},
+    }
+}
+
+// Deploy exports the definition as BPMN and registers it as the next process version.
+func (m *MemoryProcessEngine) Deploy(ctx context.Context, definition Definition) (Deployment, error) {
+    resource, err := ExportBPMN(definition)
+    if err != nil {
+        return Deployment{}, err
+    }
+
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    processID := BPMNProcessID(definition.ID)
+    versions := m.processes[processID]
+    if n := len(versions); n > 0 && bytes.Equal(versions[n-1].resource, resource) {
+        return versions[n-1].deployment, nil
+    }
+
+    deployment := Deployment{
+        ProcessID:  processID,
+        ProcessKey: m.key(),
+        Version:    len(versions) + 1,
+        DeployedAt: time.Now().UTC(),
+    }
+    m.processes[processID] = append(versions, memoryProcess{deployment: deployment, resource: resource})
+    return deployment, nil
+}
+
+// StartInstance creates an instance of a deployed process.
+func (m *MemoryProcessEngine) StartInstance(ctx context.Context, processKey string, variables map[string]any) (string, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    if !m.deployed(processKey) {
+        return "", fmt.Errorf("%w: process key %s", ErrProcessNotFound, processKey)
+    }
+
+    instance := &MemoryInstance{Key: m.key(), ProcessKey: processKey, Variables: map[string]any{}}
+    for name, value := range variables {
+        instance.Variables[name] = value
+    }
+    m.instances[instance.Key] = instance
+    return instance.Key, nil
+}
+
+// CancelInstance cancels a running instance and drops its open jobs.
+func (m *MemoryProcessEngine) CancelInstance(ctx context.Context, instanceKey string) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    instance, ok := m.instances[instanceKey]
+    if !ok || instance.Canceled {
+        return fmt.Errorf("%w: instance key %s", ErrProcessNotFound, instanceKey)
+    }
+    instance.Canceled = true
+    for job, owner := range m.jobs {
+        if owner == instanceKey {
+            delete(m.jobs, job)
+        }
+    }
+    return nil
+}
+
+// CompleteJob completes an open job and merges variables into its instance.
+func (m *MemoryProcessEngine) CompleteJob(ctx context.Context, jobKey string, variables map[string]any) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    instanceKey, ok := m.jobs[jobKey]
+    if !ok {
+        return fmt.Errorf("%w: job key %s", ErrJobNotFound, jobKey)
+    }
+    delete(m.jobs, jobKey)
+    for name, value := range variables {
+        m.instances[instanceKey].Variables[name] = value
+    }
+    return nil
+}
+
+// CreateJob opens a job on a running instance, standing in for the engine reaching a task.
+func (m *MemoryProcessEngine) CreateJob(instanceKey string) (string, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    instance, ok := m.instances[instanceKey]
+    if !ok || instance.Canceled {
+        return "", fmt.Errorf("%w: instance key %s", ErrProcessNotFound, instanceKey)
+    }
+    key := m.key()
+    m.jobs[key] = instanceKey
+    return key, nil
+}
+
+// Deployments returns every version deployed for a BPMN process ID, oldest first.
+func (m *MemoryProcessEngine) Deployments(processID string) []Deployment {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    deployments := make([]Deployment, 0, len(m.processes[processID]))
+    for _, process := range m.processes[processID] {
+        deployments = append(deployments, process.deployment)
+    }
+    return deployments
+}
+
+// Instance returns a copy of an instance.
+func (m *MemoryProcessEngine) Instance(instanceKey string) (MemoryInstance, bool) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    instance, ok := m.instances[instanceKey]
+    if !ok {
+        return MemoryInstance{}, false
+    }
+    copied := *instance
+    copied.Variables = make(map[string]any, len(instance.Variables))
+    for name, value := range instance.Variables {
+        copied.Variables[name] = value
+    }
+    return copied, true
+}
+
+func (m *MemoryProcessEngine) deployed(processKey string) bool {
+    for _, versions := range m.processes {
+        for _, process := range versions {
+            if process.deployment.ProcessKey == processKey {
+                return true
+            }
+        }
+    }
+    return false
+}
+
+func (m *MemoryProcessEngine) key() string {
+    m.nextKey++
+    return strconv.FormatInt(m.nextKey, 10)
+}
@@ -0,0 +1,91 @@
+package workflow
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "net/http"
+    "net/http/httptest"
+    "testing"
+
+    "github.com/go-chi/chi/v5"
+)
+
+func TestMemoryProcessEngineVersionsChangedDeployments(t *testing.T) {
+    engine := NewMemoryProcessEngine()
+    ctx := context.Background()
+    definition := *approvalDefinition()
+
+    first, err := engine.Deploy(ctx, definition)
+    if err != nil {
+        t.Fatalf("deploy: %v", err)
+    }
+    again, err := engine.Deploy(ctx, definition)
+    if err != nil || again != first {
+        t.Fatalf("expected unchanged definition to keep its deployment, got %+v (%v)", again, err)
+    }
+
+    definition.Name = "Approval v2"
+    second, err := engine.Deploy(ctx, definition)
+    if err != nil {
+        t.Fatalf("redeploy: %v", err)
+    }
+    if second.Version != 2 || second.ProcessKey == first.ProcessKey || len(engine.Deployments(first.ProcessID)) != 2 {
+        t.Fatalf("expected a second version, got %+v", second)
+    }
+
+    instanceKey, err := engine.StartInstance(ctx, first.ProcessKey, map[string]any{"amount": 10})
+    if err != nil {
+        t.Fatalf("start: %v", err)
+    }
+    jobKey, err := engine.CreateJob(instanceKey)
+    if err != nil {
+        t.Fatalf("create job: %v", err)
+    }
+    if err := engine.CompleteJob(ctx, jobKey, map[string]any{"approved": true}); err != nil {
+        t.Fatalf("complete job: %v", err)
+    }
+    if err := engine.CompleteJob(ctx, jobKey, nil); !errors.Is(err, ErrJobNotFound) {
+        t.Fatalf("expected ErrJobNotFound, got %v", err)
+    }
+    if err := engine.CancelInstance(ctx, instanceKey); err != nil {
+        t.Fatalf("cancel: %v", err)
+    }
+    instance, _ := engine.Instance(instanceKey)
+    if !instance.Canceled || instance.Variables["approved"] != true {
+        t.Fatalf("unexpected instance %+v", instance)
+    }
+    if _, err := engine.StartInstance(ctx, "missing", nil); !errors.Is(err, ErrProcessNotFound) {
+        t.Fatalf("expected ErrProcessNotFound, got %v", err)
+    }
+}
+
+func TestPublishDeploysToProcessEngine(t *testing.T) {
+    definition := approvalDefinition()
+    definition.Published = false
+    repo := &memoryDefinitions{definitions: map[string]*Definition{definition.ID: definition}}
+    engine := NewMemoryProcessEngine()
+    router := chi.NewRouter()
+    NewHandler(repo, WithProcessEngine(engine)).Mount(router, "")
+
+    recorder := httptest.NewRecorder()
+    router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/workflows/"+definition.ID+"/publish", nil))
+    if recorder.Code != http.StatusOK {
+        t.Fatalf("expected 200, got %d: %s", recorder.Code, recorder.Body)
+    }
+
+    var body struct {
+        Data struct {
+            Published     bool   `json:"published"`
+            ProcessKey    string `json:"processKey"`
+            EngineVersion int    `json:"engineVersion"`
+        } `json:"data"`
+    }
+    if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+        t.Fatalf("decode: %v", err)
+    }
+    deployments := engine.Deployments(BPMNProcessID(definition.ID))
+    if !body.Data.Published || len(deployments) != 1 || body.Data.ProcessKey != deployments[0].ProcessKey || body.Data.EngineVersion != 1 {
+        t.Fatalf("expected recorded deployment %+v, got %+v", deployments, body.Data)
+    }
+}
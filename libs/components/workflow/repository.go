@@ -1,23 +1,51 @@
 package workflow
 
 import (
+    "bytes"
     "context"
+    "encoding/json"
     "errors"
+    "time"
 
     "gorm.io/gorm"
+    "gorm.io/gorm/clause"
+
+    "github.com/pflow/shared/database"
+    "github.com/pflow/shared/httpx"
+    "github.com/pflow/shared/tenant"
 )
 
 // Repository defines persistence operations for workflow definitions.
 type Repository interface {
-    List(ctx context.Context, published *bool) ([]Definition, error)
+    List(ctx context.Context, query httpx.ListQuery) ([]Definition, httpx.Page, error)
     Create(ctx context.Context, entity *Definition) error
     Find(ctx context.Context, id string) (*Definition, error)
     Update(ctx context.Context, id string, updates map[string]any) (*Definition, error)
     Delete(ctx context.Context, id string) error
-    Publish(ctx context.Context, id string) (*Definition, error)
+    Restore(ctx context.Context, id string) (*Definition, error)
+    Publish(ctx context.Context, id string, opts PublishOptions) (*Definition, error)
+    Versions(ctx context.Context, id string) ([]DefinitionVersion, error)
+    FindVersion(ctx context.Context, id string, version int) (*DefinitionVersion, error)
+}
+
+// PublishOptions customise how a definition is published.
+type PublishOptions struct {
+    // Actor is recorded as the publisher of a new version.
+    Actor string
+    // FromVersion republishes an earlier version's content instead of the draft,
+    // overwriting the draft with it. Zero publishes the draft.
+    FromVersion int
+    // Deploy, when set, deploys the snapshot to a process engine once the version
+    // is committed. A failed deployment leaves the version published, records the
+    // failure on the definition and is retried by publishing again.
+    Deploy func(ctx context.Context, snapshot Definition) (Deployment, error)
+    // Announce, when set, runs in the publish transaction with the published
+    // definition, before any deployment. An error rolls the publish back.
+    Announce func(ctx context.Context, published Definition) error
 }
 
-// GormRepository implements Repository using GORM.
+// GormRepository implements Repository using GORM. Every query is scoped to the
+// tenant of the request context.
 type GormRepository struct {
     db *gorm.DB
 }
@@ -27,29 +55,25 @@ func NewGormRepository(db *gorm.DB) *GormRepository {
     return &GormRepository{db: db}
 }
 
-// List returns definitions optionally filtered by published flag.
-func (r *GormRepository) List(ctx context.Context, published *bool) ([]Definition, error) {
-    query := r.db.WithContext(ctx).Model(&Definition{}).Order("updated_at DESC")
-    if published != nil {
-        query = query.Where("published = ?", *published)
-    }
+func (r *GormRepository) scoped(ctx context.Context) *gorm.DB {
+    return database.Conn(ctx, r.db).Scopes(database.TenantScope(ctx))
+}
 
-    var definitions []Definition
-    if err := query.Find(&definitions).Error; err != nil {
-        return nil, err
-    }
-    return definitions, nil
+// List returns a page of definitions optionally filtered by published flag.
+func (r *GormRepository) List(ctx context.Context, query httpx.ListQuery) ([]Definition, httpx.Page, error) {
+    return database.Paginate(r.scoped(ctx).Model(&Definition{}), query, Definition.sortValue)
 }
 
 // Create persists a definition.
 func (r *GormRepository) Create(ctx context.Context, entity *Definition) error {
-    return r.db.WithContext(ctx).Create(entity).Error
+    entity.TenantID = tenant.ID(ctx)
+    return database.Conn(ctx, r.db).Create(entity).Error
 }
 
 // Find returns a definition by ID.
 func (r *GormRepository) Find(ctx context.Context, id string) (*Definition, error) {
     var entity Definition
-    if err := r.db.WithContext(ctx).First(&entity, "id = ?", id).Error; err != nil {
+    if err := r.scoped(ctx).First(&entity, "id = ?", id).Error; err != nil {
         return nil, err
     }
     return &entity, nil
@@ -58,25 +82,21 @@ func (r *GormRepository) Find(ctx context.Context, id string) (*Definition, erro
 // Update applies updates to a definition.
 func (r *GormRepository) Update(ctx context.Context, id string, updates map[string]any) (*Definition, error) {
     var entity Definition
-    tx := r.db.WithContext(ctx)
-    if err := tx.First(&entity, "id = ?", id).Error; err != nil {
-        return nil, err
-    }
-
-    if err := tx.Model(&entity).Updates(updates).Error; err != nil {
+    if err := r.scoped(ctx).First(&entity, "id = ?", id).Error; err != nil {
         return nil, err
     }
 
-    if err := tx.First(&entity, "id = ?", id).Error; err != nil {
+    if err := database.Conn(ctx, r.db).Model(&entity).Updates(updates).Error; err != nil {
         return nil, err
     }
 
-    return &entity, nil
+    return r.Find(ctx, id)
 }
 
-// Delete removes a definition.
+// Delete soft-deletes a definition. The definition can be restored until it is
+// purged; running instances are not affected.
 func (r *GormRepository) Delete(ctx context.Context, id string) error {
-    result := r.db.WithContext(ctx).Delete(&Definition{}, "id = ?", id)
+    result := r.scoped(ctx).Delete(&Definition{}, "id = ?", id)
     if result.Error != nil {
         return result.Error
     }
@@ -86,12 +106,183 @@ func (r *GormRepository) Delete(ctx context.Context, id string) error {
     return nil
 }
 
-// Publish marks a workflow as published.
-func (r *GormRepository) Publish(ctx context.Context, id string) (*Definition, error) {
-    updates := map[string]any{
-        "published": true,
+// Restore undeletes a soft-deleted definition, failing with
+// database.ErrNotDeleted when the definition was not deleted.
+func (r *GormRepository) Restore(ctx context.Context, id string) (*Definition, error) {
+    if err := database.Restore(ctx, r.db, &Definition{}, id); err != nil {
+        return nil, err
+    }
+    return r.Find(ctx, id)
+}
+
+// PurgeDeleted hard-deletes, in every tenant, up to limit definitions deleted
+// before the cutoff together with their published versions. Instances keep the
+// blueprint they were started with, so they outlive their definition.
+func (r *GormRepository) PurgeDeleted(ctx context.Context, before time.Time, limit int) (int, error) {
+    return database.PurgeDeleted(ctx, r.db, &Definition{}, before, limit, func(tx *gorm.DB, ids []string) error {
+        return tx.Where("definition_id IN ?", ids).Delete(&DefinitionVersion{}).Error
+    })
+}
+
+// Publish freezes the draft (or the version named by opts.FromVersion) as a new
+// immutable version and marks the definition as published. Publishing content that
+// matches the latest version reuses that version instead of creating another one.
+// When opts.Deploy fails the published definition is returned with the error.
+func (r *GormRepository) Publish(ctx context.Context, id string, opts PublishOptions) (*Definition, error) {
+    var (
+        entity   Definition
+        snapshot DefinitionVersion
+    )
+    err := database.Conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+        if err := tx.Scopes(database.TenantScope(ctx)).Clauses(clause.Locking{Strength: "UPDATE"}).First(&entity, "id = ?", id).Error; err != nil {
+            return err
+        }
+        if opts.FromVersion > 0 {
+            var source DefinitionVersion
+            if err := tx.First(&source, "definition_id = ? AND version = ?", id, opts.FromVersion).Error; err != nil {
+                return err
+            }
+            entity.Name = source.Name
+            entity.Description = source.Description
+            entity.Blueprint = source.Blueprint
+        }
+
+        // Version numbers are shared by the definitions of a name, so publishes
+        // of namesakes are serialised until this one has taken its number.
+        if err := database.LockKey(tx, "workflow-version:"+entity.TenantID+"/"+entity.Name); err != nil {
+            return err
+        }
+        var latest DefinitionVersion
+        if err := tx.Where("definition_id = ?", id).Order("version DESC").Limit(1).Find(&latest).Error; err != nil {
+            return err
+        }
+        var highest int
+        if err := tx.Model(&DefinitionVersion{}).Scopes(database.TenantScope(ctx)).Where("name = ?", entity.Name).Select("COALESCE(MAX(version), 0)").Scan(&highest).Error; err != nil {
+            return err
+        }
+
+        snapshot = freeze(entity, latest, highest, opts)
+        if snapshot.ID == "" {
+            if err := tx.Create(&snapshot).Error; err != nil {
+                return err
+            }
+        }
+        if err := tx.Model(&entity).Updates(map[string]any{
+            "name":        entity.Name,
+            "description": entity.Description,
+            "blueprint":   entity.Blueprint,
+            "version":     snapshot.Version,
+            "published":   true,
+        }).Error; err != nil {
+            return err
+        }
+        if opts.Announce == nil {
+            return nil
+        }
+        entity.Version, entity.Published = snapshot.Version, true
+        return opts.Announce(database.WithTx(ctx, tx), entity)
+    })
+    if err != nil {
+        return nil, err
+    }
+
+    if opts.Deploy != nil {
+        err = r.deploy(ctx, entity, snapshot, opts.Deploy)
+    }
+    published, findErr := r.Find(ctx, id)
+    if findErr != nil {
+        return nil, errors.Join(err, findErr)
+    }
+    return published, err
+}
+
+// deploy deploys a committed version and records the outcome on the definition.
+// A version keeps the deployment it was first deployed as, and the definition only
+// takes a deployment while it still points at the deployed version.
+func (r *GormRepository) deploy(ctx context.Context, entity Definition, snapshot DefinitionVersion, deploy func(context.Context, Definition) (Deployment, error)) error {
+    frozen := entity
+    frozen.Version = snapshot.Version
+    deployment, err := deploy(ctx, frozen)
+    if err != nil {
+        recordErr := database.Conn(ctx, r.db).Model(&Definition{}).
+            Where("id = ? AND version = ?", entity.ID, snapshot.Version).
+            Update("deploy_error", err.Error()).Error
+        return errors.Join(err, recordErr)
+    }
+
+    deployedAt := deployment.DeployedAt
+    return database.Conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+        if err := tx.Model(&DefinitionVersion{}).
+            Where("id = ? AND process_key = ?", snapshot.ID, "").
+            Updates(map[string]any{"process_key": deployment.ProcessKey, "engine_version": deployment.Version}).Error; err != nil {
+            return err
+        }
+        return tx.Model(&Definition{}).
+            Where("id = ? AND version = ?", entity.ID, snapshot.Version).
+            Updates(map[string]any{
+                "process_key":    deployment.ProcessKey,
+                "engine_version": deployment.Version,
+                "deployed_at":    &deployedAt,
+                "deploy_error":   "",
+            }).Error
+    })
+}
+
+// Versions lists the published versions of a definition, oldest first.
+func (r *GormRepository) Versions(ctx context.Context, id string) ([]DefinitionVersion, error) {
+    if err := r.scoped(ctx).Select("id").First(&Definition{}, "id = ?", id).Error; err != nil {
+        return nil, err
+    }
+
+    var versions []DefinitionVersion
+    if err := database.Conn(ctx, r.db).Where("definition_id = ?", id).Order("version ASC").Find(&versions).Error; err != nil {
+        return nil, err
+    }
+    return versions, nil
+}
+
+// FindVersion returns a single published version of a definition.
+func (r *GormRepository) FindVersion(ctx context.Context, id string, version int) (*DefinitionVersion, error) {
+    var entity DefinitionVersion
+    if err := r.scoped(ctx).First(&entity, "definition_id = ? AND version = ?", id, version).Error; err != nil {
+        return nil, err
+    }
+    return &entity, nil
+}
+
+// freeze returns the version a publish records: latest when the content did not
+// change since it was published, otherwise a new version numbered after highest, the
+// highest version already used by the definition's name.
+func freeze(entity Definition, latest DefinitionVersion, highest int, opts PublishOptions) DefinitionVersion {
+    if latest.ID != "" && sameSnapshot(latest, entity) {
+        return latest
+    }
+    return DefinitionVersion{
+        TenantID:      entity.TenantID,
+        DefinitionID:  entity.ID,
+        Name:          entity.Name,
+        Version:       max(highest, entity.Version) + 1,
+        Description:   entity.Description,
+        Blueprint:     entity.Blueprint,
+        SourceVersion: opts.FromVersion,
+        PublishedBy:   opts.Actor,
+    }
+}
+
+// sameSnapshot reports whether a definition's content matches a published version.
+func sameSnapshot(version DefinitionVersion, definition Definition) bool {
+    if version.Name != definition.Name || version.Description != definition.Description {
+        return false
+    }
+    left, err := json.Marshal(version.Blueprint)
+    if err != nil {
+        return false
+    }
+    right, err := json.Marshal(definition.Blueprint)
+    if err != nil {
+        return false
     }
-    return r.Update(ctx, id, updates)
+    return bytes.Equal(left, right)
 }
 
 // IsNotFound indicates whether the error is gorm.ErrRecordNotFound.
@@ -0,0 +1,59 @@
+package workflow
+
+import (
+    "context"
+    "testing"
+
+    "github.com/pflow/components/internal/dbtest"
+    "github.com/pflow/shared/httpx"
+    "github.com/pflow/shared/tenant"
+)
+
+func TestDefinitionsAreIsolatedPerTenant(t *testing.T) {
+    repo := NewGormRepository(dbtest.Open(t, Migrate))
+    acme := tenant.WithID(context.Background(), "acme")
+    globex := tenant.WithID(context.Background(), "globex")
+
+    blueprint := map[string]any{"steps": []any{map[string]any{"id": "done", "type": "end"}}}
+    definition := &Definition{Name: "onboarding", Blueprint: blueprint}
+    if err := repo.Create(acme, definition); err != nil {
+        t.Fatalf("create: %v", err)
+    }
+
+    if _, err := repo.Find(globex, definition.ID); !IsNotFound(err) {
+        t.Fatalf("expected find from another tenant to miss, got %v", err)
+    }
+    if _, err := repo.Update(globex, definition.ID, map[string]any{"name": "hijacked"}); !IsNotFound(err) {
+        t.Fatalf("expected update from another tenant to miss, got %v", err)
+    }
+    if _, err := repo.Publish(globex, definition.ID, PublishOptions{}); !IsNotFound(err) {
+        t.Fatalf("expected publish from another tenant to miss, got %v", err)
+    }
+    if err := repo.Delete(globex, definition.ID); !IsNotFound(err) {
+        t.Fatalf("expected delete from another tenant to miss, got %v", err)
+    }
+    items, page, err := repo.List(globex, httpx.ListQuery{Limit: 10, SortColumn: "created_at"})
+    if err != nil || len(items) != 0 || page.Total != 0 {
+        t.Fatalf("expected other tenant to list nothing, got %d items (total %d, err %v)", len(items), page.Total, err)
+    }
+
+    // Version numbers are counted per name within a tenant, so a namesake in
+    // another tenant starts at version 1 as well.
+    if _, err := repo.Publish(acme, definition.ID, PublishOptions{}); err != nil {
+        t.Fatalf("publish: %v", err)
+    }
+    namesake := &Definition{Name: "onboarding", Blueprint: blueprint}
+    if err := repo.Create(globex, namesake); err != nil {
+        t.Fatalf("create namesake: %v", err)
+    }
+    published, err := repo.Publish(globex, namesake.ID, PublishOptions{})
+    if err != nil {
+        t.Fatalf("publish namesake: %v", err)
+    }
+    if published.Version != 1 {
+        t.Fatalf("expected namesake to start at version 1, got %d", published.Version)
+    }
+    if _, err := repo.FindVersion(globex, definition.ID, 1); !IsNotFound(err) {
+        t.Fatalf("expected version of another tenant to miss, got %v", err)
+    }
+}
@@ -0,0 +1,242 @@
+package workflow
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "errors"
+    "net/http"
+    "net/http/httptest"
+    "strconv"
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/go-chi/chi/v5"
+
+    "github.com/pflow/components/internal/dbtest"
+    "github.com/pflow/shared/auth"
+)
+
+func TestDiffBlueprintsReportsStepChanges(t *testing.T) {
+    from := map[string]any{"steps": []any{
+        map[string]any{"id": "request", "type": "form"},
+        map[string]any{"id": "review", "type": "approval", "assignee": "manager"},
+        map[string]any{"id": "archive", "type": "serviceTask", "handler": "archive"},
+    }}
+    to := map[string]any{"start": "review", "steps": []any{
+        map[string]any{"id": "request", "type": "form"},
+        map[string]any{"id": "review", "type": "approval", "assignee": "director", "next": "done"},
+        map[string]any{"id": "done", "type": "end"},
+    }}
+
+    diff := DiffBlueprints(from, to)
+    if diff.Start == nil || diff.Start.From != "request" || diff.Start.To != "review" {
+        t.Fatalf("expected start change, got %+v", diff.Start)
+    }
+    if len(diff.Added) != 1 || diff.Added[0].ID != "done" || len(diff.Removed) != 1 || diff.Removed[0].ID != "archive" {
+        t.Fatalf("unexpected added/removed %+v %+v", diff.Added, diff.Removed)
+    }
+    if len(diff.Changed) != 1 || diff.Changed[0].ID != "review" {
+        t.Fatalf("unexpected changes %+v", diff.Changed)
+    }
+    fields := diff.Changed[0].Fields
+    if len(fields) != 2 || fields["assignee"].From != "manager" || fields["assignee"].To != "director" || fields["next"].From != nil || fields["next"].To != "done" {
+        t.Fatalf("unexpected field changes %+v", fields)
+    }
+
+    if same := DiffDefinitions(Definition{Name: "a", Blueprint: from}, Definition{Name: "a", Blueprint: from}); !same.Empty() {
+        t.Fatalf("expected identical definitions to have an empty diff, got %+v", same)
+    }
+}
+
+type versionTestClient struct {
+    t      *testing.T
+    router chi.Router
+}
+
+func (c versionTestClient) do(method, path string, body any, out any) int {
+    c.t.Helper()
+    var payload bytes.Buffer
+    if body != nil {
+        if err := json.NewEncoder(&payload).Encode(body); err != nil {
+            c.t.Fatalf("encode: %v", err)
+        }
+    }
+    recorder := httptest.NewRecorder()
+    c.router.ServeHTTP(recorder, httptest.NewRequest(method, path, &payload))
+    if out != nil && recorder.Code < 300 {
+        if err := json.Unmarshal(recorder.Body.Bytes(), out); err != nil {
+            c.t.Fatalf("decode %s %s: %v", method, path, err)
+        }
+    }
+    return recorder.Code
+}
+
+type definitionResponse struct {
+    Data struct {
+        Version   int  `json:"version"`
+        Published bool `json:"published"`
+    } `json:"data"`
+}
+
+func TestPublishFreezesVersionsAndRollsBack(t *testing.T) {
+    definition := approvalDefinition()
+    definition.Published = false
+    definition.Version = 0
+    repo := &memoryDefinitions{definitions: map[string]*Definition{definition.ID: definition}}
+    engine := NewEngine(repo, newMemoryInstances())
+    router := chi.NewRouter()
+    NewHandler(repo, WithEngine(engine)).Mount(router, "")
+    client := versionTestClient{t: t, router: router}
+    base := "/workflows/" + definition.ID
+
+    var published definitionResponse
+    if code := client.do(http.MethodPost, base+"/publish", nil, &published); code != http.StatusOK || published.Data.Version != 1 {
+        t.Fatalf("expected version 1, got %d %+v", code, published.Data)
+    }
+
+    // Editing the draft must not change what new instances run.
+    draft := map[string]any{"steps": []any{
+        map[string]any{"id": "request", "type": "form"},
+        map[string]any{"id": "done", "type": "end"},
+    }}
+    if code := client.do(http.MethodPut, base, map[string]any{"blueprint": draft}, nil); code != http.StatusOK {
+        t.Fatalf("update draft: %d", code)
+    }
+    instance, _, err := engine.Start(context.Background(), definition.ID, nil, "")
+    if err != nil {
+        t.Fatalf("start: %v", err)
+    }
+    if instance.DefinitionVersion != 1 || len(instance.Blueprint["steps"].([]any)) != 5 {
+        t.Fatalf("expected instance to run published version 1, got v%d %+v", instance.DefinitionVersion, instance.Blueprint)
+    }
+
+    if code := client.do(http.MethodPost, base+"/publish", nil, &published); code != http.StatusOK || published.Data.Version != 2 {
+        t.Fatalf("expected version 2, got %d %+v", code, published.Data)
+    }
+    if code := client.do(http.MethodPost, base+"/publish", nil, &published); code != http.StatusOK || published.Data.Version != 2 {
+        t.Fatalf("expected unchanged republish to keep version 2, got %d %+v", code, published.Data)
+    }
+
+    var diff struct {
+        Data struct {
+            From      any           `json:"from"`
+            To        any           `json:"to"`
+            Identical bool          `json:"identical"`
+            Steps     BlueprintDiff `json:"steps"`
+        } `json:"data"`
+    }
+    if code := client.do(http.MethodGet, base+"/diff?from=1&to=2", nil, &diff); code != http.StatusOK {
+        t.Fatalf("diff: %d", code)
+    }
+    if diff.Data.From != 1.0 || diff.Data.To != 2.0 || diff.Data.Identical || len(diff.Data.Steps.Removed) != 3 {
+        t.Fatalf("unexpected diff %+v", diff.Data)
+    }
+    if code := client.do(http.MethodGet, base+"/diff?from=9", nil, nil); code != http.StatusNotFound {
+        t.Fatalf("expected 404 for unknown version, got %d", code)
+    }
+
+    if code := client.do(http.MethodPost, base+"/versions/1/rollback", nil, &published); code != http.StatusOK || published.Data.Version != 3 {
+        t.Fatalf("expected rollback to publish version 3, got %d %+v", code, published.Data)
+    }
+
+    var versions struct {
+        Data []struct {
+            Version       int `json:"version"`
+            SourceVersion int `json:"sourceVersion"`
+        } `json:"data"`
+    }
+    if code := client.do(http.MethodGet, base+"/versions", nil, &versions); code != http.StatusOK || len(versions.Data) != 3 {
+        t.Fatalf("expected three versions, got %d %+v", code, versions.Data)
+    }
+    if versions.Data[2].SourceVersion != 1 {
+        t.Fatalf("expected version 3 to record its source, got %+v", versions.Data[2])
+    }
+    diff.Data.Identical = false
+    if code := client.do(http.MethodGet, base+"/diff?from=1&to=draft", nil, &diff); code != http.StatusOK || !diff.Data.Identical || diff.Data.To != draftVersion {
+        t.Fatalf("expected rolled back draft to match version 1, got %d %+v", code, diff.Data)
+    }
+    if code := client.do(http.MethodPut, base, map[string]any{"version": 7}, nil); code != http.StatusBadRequest {
+        t.Fatalf("expected client supplied versions to be rejected, got %d", code)
+    }
+}
+
+func TestPublishDeploysCommittedVersions(t *testing.T) {
+    repo := NewGormRepository(dbtest.Open(t, Migrate))
+    ctx := context.Background()
+    blueprint := map[string]any{"steps": []any{map[string]any{"id": "done", "type": "end"}}}
+    first := &Definition{Name: "onboarding", Blueprint: blueprint}
+    second := &Definition{Name: "onboarding", Blueprint: blueprint}
+    for _, definition := range []*Definition{first, second} {
+        if err := repo.Create(ctx, definition); err != nil {
+            t.Fatalf("create: %v", err)
+        }
+    }
+
+    // Namesakes share the version numbers of their name.
+    if published, err := repo.Publish(ctx, first.ID, PublishOptions{}); err != nil || published.Version != 1 {
+        t.Fatalf("publish first: %+v (%v)", published, err)
+    }
+    unreachable := errors.New("engine unreachable")
+    published, err := repo.Publish(ctx, second.ID, PublishOptions{Deploy: func(ctx context.Context, snapshot Definition) (Deployment, error) {
+        return Deployment{}, unreachable
+    }})
+    if !errors.Is(err, unreachable) || published == nil || !published.Published || published.Version != 2 || published.DeployError == "" {
+        t.Fatalf("expected the version to stay published with the deployment failure recorded, got %+v (%v)", published, err)
+    }
+
+    deployed := 0
+    deploy := func(ctx context.Context, snapshot Definition) (Deployment, error) {
+        deployed++
+        return Deployment{ProcessKey: "key-" + strconv.Itoa(deployed), Version: deployed, DeployedAt: time.Now()}, nil
+    }
+    published, err = repo.Publish(ctx, second.ID, PublishOptions{Actor: "someone-else", Deploy: deploy})
+    if err != nil || published.Version != 2 || published.ProcessKey != "key-1" || published.DeployError != "" {
+        t.Fatalf("expected publishing again to deploy version 2, got %+v (%v)", published, err)
+    }
+    published, err = repo.Publish(ctx, second.ID, PublishOptions{Actor: "someone-else", Deploy: deploy})
+    if err != nil || published.ProcessKey != "key-2" {
+        t.Fatalf("expected the definition to record the latest deployment, got %+v (%v)", published, err)
+    }
+
+    version, err := repo.FindVersion(ctx, second.ID, 2)
+    if err != nil {
+        t.Fatalf("find version: %v", err)
+    }
+    if version.ProcessKey != "key-1" || version.EngineVersion != 1 || version.PublishedBy != "" {
+        t.Fatalf("expected the published version to stay as first deployed, got %+v", version)
+    }
+}
+
+func TestUpdateNeedsPublishPermissionToPublish(t *testing.T) {
+    definition := approvalDefinition()
+    definition.Published = false
+    definition.Version = 0
+    repo := &memoryDefinitions{definitions: map[string]*Definition{definition.ID: definition}}
+    router := chi.NewRouter()
+    router.Use(func(next http.Handler) http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            principal := auth.Principal{UserID: "editor", Permissions: strings.Split(r.Header.Get("X-Test-Permissions"), ",")}
+            next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), principal)))
+        })
+    })
+    NewHandler(repo, WithEngine(NewEngine(repo, newMemoryInstances())), WithAuthorization()).Mount(router, "")
+    put := func(permissions string) int {
+        rec := httptest.NewRecorder()
+        req := httptest.NewRequest(http.MethodPut, "/workflows/"+definition.ID, bytes.NewBufferString(`{"published":true}`))
+        req.Header.Set("X-Test-Permissions", permissions)
+        router.ServeHTTP(rec, req)
+        return rec.Code
+    }
+
+    if code := put(auth.PermissionWorkflowEdit); code != http.StatusForbidden {
+        t.Fatalf("expected publishing through PUT with workflow:edit only to be refused, got %d", code)
+    }
+    if definition.Published || definition.Version != 0 {
+        t.Fatalf("expected the refused request to leave the definition unpublished, got v%d", definition.Version)
+    }
+    if code := put(auth.PermissionWorkflowEdit + "," + auth.PermissionWorkflowPublish); code != http.StatusOK {
+        t.Fatalf("expected publishing with workflow:publish to succeed, got %d", code)
+    }
+}
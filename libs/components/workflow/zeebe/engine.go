@@ -0,0 +1,177 @@
+// Package zeebe implements workflow.ProcessEngine on top of the Camunda 8 (Zeebe)
+// gRPC gateway.
+package zeebe
+
+import (
+    "context"
+    "fmt"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/camunda/zeebe/clients/go/v8/pkg/commands"
+    "github.com/camunda/zeebe/clients/go/v8/pkg/zbc"
+    "google.golang.org/grpc/codes"
+    "google.golang.org/grpc/status"
+
+    "github.com/pflow/components/workflow"
+)
+
+const defaultTimeout = 10 * time.Second
+
+// Config describes how to reach the Zeebe gateway. Camunda SaaS credentials are read
+// by the client from the standard ZEEBE_CLIENT_ID, ZEEBE_CLIENT_SECRET and
+// ZEEBE_AUTHORIZATION_SERVER_URL environment variables.
+type Config struct {
+    GatewayAddress string
+    Plaintext      bool
+    Timeout        time.Duration
+    ClientConfig   *zbc.ClientConfig
+}
+
+// Engine deploys definitions to Zeebe and drives their process instances.
+type Engine struct {
+    client  zbc.Client
+    timeout time.Duration
+}
+
+var _ workflow.ProcessEngine = (*Engine)(nil)
+
+// New connects to the Zeebe gateway. The connection is established lazily, so an
+// unreachable gateway surfaces on the first command rather than here.
+func New(cfg Config) (*Engine, error) {
+    clientCfg := cfg.ClientConfig
+    if clientCfg == nil {
+        clientCfg = &zbc.ClientConfig{}
+    }
+    if address := strings.TrimSpace(cfg.GatewayAddress); address != "" {
+        clientCfg.GatewayAddress = address
+    }
+    if cfg.Plaintext {
+        clientCfg.UsePlaintextConnection = true
+    }
+
+    client, err := zbc.NewClient(clientCfg)
+    if err != nil {
+        return nil, fmt.Errorf("zeebe: connect to %s: %w", clientCfg.GatewayAddress, err)
+    }
+
+    timeout := cfg.Timeout
+    if timeout <= 0 {
+        timeout = defaultTimeout
+    }
+    return &Engine{client: client, timeout: timeout}, nil
+}
+
+// Deploy exports the definition as BPMN and deploys it. Zeebe assigns a new version
+// only when the resource differs from the latest deployment of the same process ID.
+func (e *Engine) Deploy(ctx context.Context, definition workflow.Definition) (workflow.Deployment, error) {
+    resource, err := workflow.ExportBPMN(definition)
+    if err != nil {
+        return workflow.Deployment{}, err
+    }
+
+    ctx, cancel := context.WithTimeout(ctx, e.timeout)
+    defer cancel()
+    response, err := e.client.NewDeployResourceCommand().AddResource(resource, definition.ID+".bpmn").Send(ctx)
+    if err != nil {
+        return workflow.Deployment{}, fmt.Errorf("zeebe: deploy %s: %w", definition.ID, err)
+    }
+
+    processID := workflow.BPMNProcessID(definition.ID)
+    for _, deployment := range response.GetDeployments() {
+        process := deployment.GetProcess()
+        if process == nil || process.GetBpmnProcessId() != processID {
+            continue
+        }
+        return workflow.Deployment{
+            ProcessID:  processID,
+            ProcessKey: strconv.FormatInt(process.GetProcessDefinitionKey(), 10),
+            Version:    int(process.GetVersion()),
+            DeployedAt: time.Now().UTC(),
+        }, nil
+    }
+    return workflow.Deployment{}, fmt.Errorf("zeebe: deployment %d did not contain process %s", response.GetKey(), processID)
+}
+
+// StartInstance creates an instance of the process definition with the given key.
+func (e *Engine) StartInstance(ctx context.Context, processKey string, variables map[string]any) (string, error) {
+    key, err := parseKey(processKey)
+    if err != nil {
+        return "", err
+    }
+
+    command := e.client.NewCreateInstanceCommand().ProcessDefinitionKey(key)
+    if len(variables) > 0 {
+        if command, err = command.VariablesFromMap(variables); err != nil {
+            return "", fmt.Errorf("zeebe: encode variables: %w", err)
+        }
+    }
+
+    ctx, cancel := context.WithTimeout(ctx, e.timeout)
+    defer cancel()
+    response, err := command.Send(ctx)
+    if err != nil {
+        return "", translate(err, workflow.ErrProcessNotFound, fmt.Sprintf("start process %s", processKey))
+    }
+    return strconv.FormatInt(response.GetProcessInstanceKey(), 10), nil
+}
+
+// CancelInstance cancels a running process instance.
+func (e *Engine) CancelInstance(ctx context.Context, instanceKey string) error {
+    key, err := parseKey(instanceKey)
+    if err != nil {
+        return err
+    }
+
+    ctx, cancel := context.WithTimeout(ctx, e.timeout)
+    defer cancel()
+    if _, err := e.client.NewCancelInstanceCommand().ProcessInstanceKey(key).Send(ctx); err != nil {
+        return translate(err, workflow.ErrProcessNotFound, fmt.Sprintf("cancel instance %s", instanceKey))
+    }
+    return nil
+}
+
+// CompleteJob completes an activated job, merging variables into its process instance.
+func (e *Engine) CompleteJob(ctx context.Context, jobKey string, variables map[string]any) error {
+    key, err := parseKey(jobKey)
+    if err != nil {
+        return err
+    }
+
+    step := e.client.NewCompleteJobCommand().JobKey(key)
+    var command commands.DispatchCompleteJobCommand = step
+    if len(variables) > 0 {
+        if command, err = step.VariablesFromMap(variables); err != nil {
+            return fmt.Errorf("zeebe: encode variables: %w", err)
+        }
+    }
+
+    ctx, cancel := context.WithTimeout(ctx, e.timeout)
+    defer cancel()
+    if _, err := command.Send(ctx); err != nil {
+        return translate(err, workflow.ErrJobNotFound, fmt.Sprintf("complete job %s", jobKey))
+    }
+    return nil
+}
+
+// Close releases the gateway connection.
+func (e *Engine) Close() error {
+    return e.client.Close()
+}
+
+func parseKey(raw string) (int64, error) {
+    key, err := strconv.ParseInt(strings.TrimSpace(raw), 10, 64)
+    if err != nil {
+        return 0, fmt.Errorf("%w: invalid key %q", workflow.ErrProcessNotFound, raw)
+    }
+    return key, nil
+}
+
+// translate maps NOT_FOUND responses from the gateway onto a workflow sentinel error.
+func translate(err, notFound error, action string) error {
+    if status.Code(err) == codes.NotFound {
+        return fmt.Errorf("zeebe: %s: %w", action, notFound)
+    }
+    return fmt.Errorf("zeebe: %s: %w", action, err)
+}
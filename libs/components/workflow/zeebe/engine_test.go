@@ -0,0 +1,174 @@
+package zeebe
+
+import (
+    "context"
+    "encoding/json"
+    "encoding/xml"
+    "errors"
+    "net"
+    "strings"
+    "sync"
+    "testing"
+
+    "github.com/camunda/zeebe/clients/go/v8/pkg/pb"
+    "github.com/camunda/zeebe/clients/go/v8/pkg/zbc"
+    "google.golang.org/grpc"
+    "google.golang.org/grpc/codes"
+    "google.golang.org/grpc/status"
+    "google.golang.org/grpc/test/bufconn"
+    "gorm.io/datatypes"
+
+    "github.com/pflow/components/workflow"
+)
+
+// fakeGateway records the commands sent by the client and answers like a broker.
+type fakeGateway struct {
+    pb.UnimplementedGatewayServer
+
+    mu        sync.Mutex
+    resources []*pb.Resource
+    started   []*pb.CreateProcessInstanceRequest
+    canceled  []int64
+    completed []*pb.CompleteJobRequest
+}
+
+func (g *fakeGateway) DeployResource(ctx context.Context, req *pb.DeployResourceRequest) (*pb.DeployResourceResponse, error) {
+    g.mu.Lock()
+    defer g.mu.Unlock()
+    g.resources = append(g.resources, req.GetResources()...)
+
+    var document struct {
+        Process struct {
+            ID string `xml:"id,attr"`
+        } `xml:"process"`
+    }
+    if err := xml.Unmarshal(req.GetResources()[0].GetContent(), &document); err != nil {
+        return nil, status.Error(codes.InvalidArgument, err.Error())
+    }
+
+    return &pb.DeployResourceResponse{
+        Key: 1,
+        Deployments: []*pb.Deployment{{
+            Metadata: &pb.Deployment_Process{Process: &pb.ProcessMetadata{
+                BpmnProcessId:        document.Process.ID,
+                Version:              int32(len(g.resources)),
+                ProcessDefinitionKey: 2251799813685249,
+                ResourceName:         req.GetResources()[0].GetName(),
+            }},
+        }},
+    }, nil
+}
+
+func (g *fakeGateway) CreateProcessInstance(ctx context.Context, req *pb.CreateProcessInstanceRequest) (*pb.CreateProcessInstanceResponse, error) {
+    g.mu.Lock()
+    defer g.mu.Unlock()
+    if req.GetProcessDefinitionKey() != 2251799813685249 {
+        return nil, status.Error(codes.NotFound, "no process definition found")
+    }
+    g.started = append(g.started, req)
+    return &pb.CreateProcessInstanceResponse{ProcessDefinitionKey: req.GetProcessDefinitionKey(), ProcessInstanceKey: 2251799813685300}, nil
+}
+
+func (g *fakeGateway) CancelProcessInstance(ctx context.Context, req *pb.CancelProcessInstanceRequest) (*pb.CancelProcessInstanceResponse, error) {
+    g.mu.Lock()
+    defer g.mu.Unlock()
+    g.canceled = append(g.canceled, req.GetProcessInstanceKey())
+    return &pb.CancelProcessInstanceResponse{}, nil
+}
+
+func (g *fakeGateway) CompleteJob(ctx context.Context, req *pb.CompleteJobRequest) (*pb.CompleteJobResponse, error) {
+    g.mu.Lock()
+    defer g.mu.Unlock()
+    if req.GetJobKey() == 404 {
+        return nil, status.Error(codes.NotFound, "job not found")
+    }
+    g.completed = append(g.completed, req)
+    return &pb.CompleteJobResponse{}, nil
+}
+
+func newTestEngine(t *testing.T) (*Engine, *fakeGateway) {
+    t.Helper()
+    listener := bufconn.Listen(1 << 20)
+    gateway := &fakeGateway{}
+    server := grpc.NewServer()
+    pb.RegisterGatewayServer(server, gateway)
+    go server.Serve(listener)
+    t.Cleanup(server.Stop)
+
+    engine, err := New(Config{
+        Plaintext: true,
+        ClientConfig: &zbc.ClientConfig{
+            GatewayAddress: "bufnet",
+            DialOpts: []grpc.DialOption{grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+                return listener.DialContext(ctx)
+            })},
+        },
+    })
+    if err != nil {
+        t.Fatalf("new engine: %v", err)
+    }
+    t.Cleanup(func() { engine.Close() })
+    return engine, gateway
+}
+
+func TestEngineDeploysAndDrivesInstances(t *testing.T) {
+    engine, gateway := newTestEngine(t)
+    ctx := context.Background()
+    definition := workflow.Definition{
+        ID:   "6f1c2d3e-0000-4000-8000-000000000001",
+        Name: "Approval",
+        Blueprint: datatypes.JSONMap{"steps": []any{
+            map[string]any{"id": "review", "type": "approval", "assignee": "manager"},
+            map[string]any{"id": "done", "type": "end"},
+        }},
+    }
+
+    deployment, err := engine.Deploy(ctx, definition)
+    if err != nil {
+        t.Fatalf("deploy: %v", err)
+    }
+    if deployment.ProcessID != workflow.BPMNProcessID(definition.ID) || deployment.ProcessKey != "2251799813685249" || deployment.Version != 1 {
+        t.Fatalf("unexpected deployment %+v", deployment)
+    }
+    if name := gateway.resources[0].GetName(); name != definition.ID+".bpmn" {
+        t.Fatalf("unexpected resource name %q", name)
+    }
+    if !strings.Contains(string(gateway.resources[0].GetContent()), `<zeebe:assignmentDefinition assignee="manager">`) {
+        t.Fatalf("expected exported BPMN, got %s", gateway.resources[0].GetContent())
+    }
+
+    instanceKey, err := engine.StartInstance(ctx, deployment.ProcessKey, map[string]any{"amount": 42})
+    if err != nil {
+        t.Fatalf("start: %v", err)
+    }
+    if instanceKey != "2251799813685300" {
+        t.Fatalf("unexpected instance key %q", instanceKey)
+    }
+    var variables map[string]any
+    if err := json.Unmarshal([]byte(gateway.started[0].GetVariables()), &variables); err != nil || variables["amount"] != float64(42) {
+        t.Fatalf("unexpected variables %q", gateway.started[0].GetVariables())
+    }
+    if _, err := engine.StartInstance(ctx, "1", nil); !errors.Is(err, workflow.ErrProcessNotFound) {
+        t.Fatalf("expected ErrProcessNotFound, got %v", err)
+    }
+
+    if err := engine.CompleteJob(ctx, "77", map[string]any{"approved": true}); err != nil {
+        t.Fatalf("complete job: %v", err)
+    }
+    if gateway.completed[0].GetJobKey() != 77 || !strings.Contains(gateway.completed[0].GetVariables(), `"approved":true`) {
+        t.Fatalf("unexpected completion %+v", gateway.completed[0])
+    }
+    if err := engine.CompleteJob(ctx, "404", nil); !errors.Is(err, workflow.ErrJobNotFound) {
+        t.Fatalf("expected ErrJobNotFound, got %v", err)
+    }
+
+    if err := engine.CancelInstance(ctx, instanceKey); err != nil {
+        t.Fatalf("cancel: %v", err)
+    }
+    if len(gateway.canceled) != 1 || gateway.canceled[0] != 2251799813685300 {
+        t.Fatalf("unexpected cancellations %v", gateway.canceled)
+    }
+    if err := engine.CancelInstance(ctx, "not-a-key"); !errors.Is(err, workflow.ErrProcessNotFound) {
+        t.Fatalf("expected ErrProcessNotFound for malformed key, got %v", err)
+    }
+}
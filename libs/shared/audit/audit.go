@@ -0,0 +1,317 @@
+// Package audit keeps an append-only, hash-chained log of the changes made
+// through the component APIs: who changed which resource, how, when and from
+// where. Each entry's hash covers the hash of the entry before it in the
+// tenant's chain, so editing, removing or reordering entries is detectable.
+package audit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/pflow/shared/auth"
+	"github.com/pflow/shared/tenant"
+)
+
+// Entry records one change. Action is "<resource type>.<verb>", e.g.
+// "form.deleted"; Changes maps every field that differs between the
+// resource before and after the change to {"before": ..., "after": ...},
+// leaving out the side where the field is absent.
+type Entry struct {
+	ID           string          `json:"id" gorm:"type:uuid;primaryKey"`
+	TenantID     string          `json:"tenantId" gorm:"type:varchar(64);not null;default:default;uniqueIndex:idx_audit_entries_chain,priority:1;index:idx_audit_entries_resource,priority:1"`
+	Seq          int64           `json:"seq" gorm:"not null;uniqueIndex:idx_audit_entries_chain,priority:2"`
+	Service      string          `json:"service" gorm:"type:varchar(32);not null"`
+	Actor        string          `json:"actor" gorm:"index"`
+	Action       string          `json:"action" gorm:"type:varchar(64);not null"`
+	ResourceType string          `json:"resourceType" gorm:"type:varchar(32);not null;index:idx_audit_entries_resource,priority:2"`
+	ResourceID   string          `json:"resourceId" gorm:"index:idx_audit_entries_resource,priority:3"`
+	Changes      json.RawMessage `json:"changes" gorm:"type:jsonb"`
+	RequestID    string          `json:"requestId"`
+	IP           string          `json:"ip" gorm:"type:varchar(64)"`
+	PrevHash     string          `json:"prevHash" gorm:"type:char(64);not null"`
+	Hash         string          `json:"hash" gorm:"type:char(64);not null"`
+	CreatedAt    time.Time       `json:"createdAt" gorm:"index"`
+}
+
+// TableName pins the table name so every service appends to the same log.
+func (Entry) TableName() string {
+	return "audit_entries"
+}
+
+// BeforeCreate assigns a UUID when missing.
+func (e *Entry) BeforeCreate(tx *gorm.DB) error {
+	if e.ID == "" {
+		e.ID = uuid.NewString()
+	}
+	return nil
+}
+
+// ComputeHash returns the hex SHA-256 over the entry's fields and PrevHash.
+// Fields are length-prefixed so no two entries hash the same input, and the
+// changes are hashed in canonical form because the database may reformat them.
+func (e Entry) ComputeHash() string {
+	digest := sha256.New()
+	fields := []string{
+		e.PrevHash,
+		e.TenantID,
+		strconv.FormatInt(e.Seq, 10),
+		e.CreatedAt.UTC().Format(time.RFC3339Nano),
+		e.Service,
+		e.Actor,
+		e.Action,
+		e.ResourceType,
+		e.ResourceID,
+		string(canonicalJSON(e.Changes)),
+		e.RequestID,
+		e.IP,
+	}
+	var size [8]byte
+	for _, field := range fields {
+		binary.BigEndian.PutUint64(size[:], uint64(len(field)))
+		digest.Write(size[:])
+		digest.Write([]byte(field))
+	}
+	return hex.EncodeToString(digest.Sum(nil))
+}
+
+// Verification is the result of checking a tenant's chain. BrokenAt is the
+// sequence number of the first entry that does not fit the chain, zero when
+// the chain is intact; Head is the hash of the last intact entry.
+type Verification struct {
+	Entries  int64
+	Head     string
+	BrokenAt int64
+	Reason   string
+}
+
+// Valid reports whether no broken link was found.
+func (v Verification) Valid() bool {
+	return v.BrokenAt == 0
+}
+
+// extend checks that entries, ordered by sequence number, continue the chain
+// verified so far. It stops at the first broken link and reports whether the
+// chain is still intact.
+func (v *Verification) extend(entries []Entry) bool {
+	for _, entry := range entries {
+		switch {
+		case entry.Seq != v.Entries+1:
+			v.fail(v.Entries+1, fmt.Sprintf("entry %d is missing", v.Entries+1))
+		case entry.PrevHash != v.Head:
+			v.fail(entry.Seq, "previous hash does not match the preceding entry")
+		case entry.Hash != entry.ComputeHash():
+			v.fail(entry.Seq, "hash does not match the entry's contents")
+		default:
+			v.Entries = entry.Seq
+			v.Head = entry.Hash
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+func (v *Verification) fail(seq int64, reason string) {
+	v.BrokenAt = seq
+	v.Reason = reason
+}
+
+// canonicalJSON re-encodes raw with sorted keys and no insignificant
+// whitespace. Invalid JSON is returned unchanged.
+func canonicalJSON(raw json.RawMessage) []byte {
+	if len(raw) == 0 {
+		return nil
+	}
+	var value any
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return raw
+	}
+	canonical, err := json.Marshal(value)
+	if err != nil {
+		return raw
+	}
+	return canonical
+}
+
+// Change describes a change for Recorder.Record. ResourceType defaults to the
+// part of Action before the first dot. Before is nil for created resources and
+// After is nil for deleted ones; both are typically the DTO the API returns
+// for the resource.
+type Change struct {
+	Action       string
+	ResourceType string
+	ResourceID   string
+	Before       any
+	After        any
+}
+
+// Diff returns the fields of the JSON objects before and after that differ.
+// Values that do not encode to an object are compared as a whole under the
+// "value" key.
+func Diff(before, after any) (json.RawMessage, error) {
+	old, err := fields(before)
+	if err != nil {
+		return nil, err
+	}
+	updated, err := fields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := map[string]map[string]any{}
+	for key, value := range old {
+		next, ok := updated[key]
+		if ok && reflect.DeepEqual(value, next) {
+			continue
+		}
+		change := map[string]any{"before": value}
+		if ok {
+			change["after"] = next
+		}
+		changes[key] = change
+	}
+	for key, value := range updated {
+		if _, ok := old[key]; !ok {
+			changes[key] = map[string]any{"after": value}
+		}
+	}
+	return json.Marshal(changes)
+}
+
+func fields(value any) (map[string]any, error) {
+	if value == nil {
+		return nil, nil
+	}
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var decoded any
+	if err := json.Unmarshal(raw, &decoded); err != nil {
+		return nil, err
+	}
+	switch decoded := decoded.(type) {
+	case nil:
+		return nil, nil
+	case map[string]any:
+		return decoded, nil
+	default:
+		return map[string]any{"value": decoded}, nil
+	}
+}
+
+// Recorder appends the changes made by a service's handlers to the audit log.
+// A nil Recorder records nothing, so handlers can call it unconditionally.
+type Recorder struct {
+	repo    Repository
+	service string
+}
+
+// NewRecorder constructs a recorder that attributes its entries to service.
+func NewRecorder(repo Repository, service string) *Recorder {
+	return &Recorder{repo: repo, service: service}
+}
+
+// Record appends change to the log of the tenant of ctx, attributed to the
+// principal of ctx and the request stored by Middleware. The change has
+// already been made when Record is called, so failures are logged rather than
+// returned.
+func (r *Recorder) Record(ctx context.Context, change Change) {
+	if r == nil || r.repo == nil {
+		return
+	}
+
+	changes, err := Diff(change.Before, change.After)
+	if err != nil {
+		log.Printf("audit: failed to diff %s %s: %v", change.Action, change.ResourceID, err)
+		return
+	}
+	resourceType := change.ResourceType
+	if resourceType == "" {
+		resourceType, _, _ = strings.Cut(change.Action, ".")
+	}
+	request := requestFromContext(ctx)
+	entry := &Entry{
+		TenantID:     tenant.ID(ctx),
+		Service:      r.service,
+		Actor:        actor(ctx, request),
+		Action:       change.Action,
+		ResourceType: resourceType,
+		ResourceID:   change.ResourceID,
+		Changes:      changes,
+		RequestID:    request.ID,
+		IP:           request.IP,
+	}
+	if err := r.repo.Append(ctx, entry); err != nil {
+		log.Printf("audit: failed to record %s %s: %v", change.Action, change.ResourceID, err)
+	}
+}
+
+// actor prefers the authenticated principal over the X-User-ID header.
+func actor(ctx context.Context, request requestInfo) string {
+	if principal, ok := auth.FromContext(ctx); ok && principal.UserID != "" {
+		return principal.UserID
+	}
+	return request.UserID
+}
+
+type requestInfo struct {
+	ID     string
+	IP     string
+	UserID string
+}
+
+type requestKey struct{}
+
+func requestFromContext(ctx context.Context) requestInfo {
+	request, _ := ctx.Value(requestKey{}).(requestInfo)
+	return request
+}
+
+// Middleware stores the request ID, client IP and X-User-ID header of each
+// request for Recorder. The request ID is the one assigned by chi's RequestID
+// middleware, which keeps the X-Request-ID set by the gateway. Services sit
+// behind the gateway, so the client IP is the last X-Forwarded-For hop, which
+// the gateway appended, and the peer address otherwise.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request := requestInfo{
+			ID:     middleware.GetReqID(r.Context()),
+			IP:     clientIP(r),
+			UserID: strings.TrimSpace(r.Header.Get("X-User-ID")),
+		}
+		if request.ID == "" {
+			request.ID = strings.TrimSpace(r.Header.Get(middleware.RequestIDHeader))
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestKey{}, request)))
+	})
+}
+
+func clientIP(r *http.Request) string {
+	if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+		hops := strings.Split(forwarded[len(forwarded)-1], ",")
+		if hop := strings.TrimSpace(hops[len(hops)-1]); hop != "" {
+			return hop
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
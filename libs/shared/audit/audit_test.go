@@ -0,0 +1,144 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+
+	"github.com/pflow/shared/auth"
+	"github.com/pflow/shared/httpx"
+	"github.com/pflow/shared/tenant"
+)
+
+// memoryRepository links entries the way GormRepository does, without a database.
+type memoryRepository struct {
+	entries []Entry
+}
+
+func (m *memoryRepository) Append(ctx context.Context, entry *Entry) error {
+	entry.Seq = int64(len(m.entries)) + 1
+	if len(m.entries) > 0 {
+		entry.PrevHash = m.entries[len(m.entries)-1].Hash
+	}
+	entry.CreatedAt = time.Now().UTC().Truncate(time.Microsecond)
+	entry.Hash = entry.ComputeHash()
+	m.entries = append(m.entries, *entry)
+	return nil
+}
+
+func (m *memoryRepository) List(ctx context.Context, query httpx.ListQuery) ([]Entry, httpx.Page, error) {
+	return m.entries, httpx.Page{Total: int64(len(m.entries))}, nil
+}
+
+func (m *memoryRepository) Verify(ctx context.Context) (Verification, error) {
+	var verification Verification
+	verification.extend(m.entries)
+	return verification, nil
+}
+
+func TestDiffKeepsChangedFields(t *testing.T) {
+	before := map[string]any{"name": "Intake", "version": 1, "tags": []string{"hr"}, "legacy": true}
+	after := map[string]any{"name": "Intake", "version": 2, "tags": []string{"hr"}, "owner": "u-1"}
+
+	raw, err := Diff(before, after)
+	if err != nil {
+		t.Fatalf("diff: %v", err)
+	}
+	var changes map[string]map[string]any
+	if err := json.Unmarshal(raw, &changes); err != nil {
+		t.Fatalf("decode diff: %v", err)
+	}
+	if len(changes) != 3 {
+		t.Fatalf("expected version, legacy and owner to change, got %s", raw)
+	}
+	if changes["version"]["before"] != float64(1) || changes["version"]["after"] != float64(2) {
+		t.Fatalf("unexpected version change %v", changes["version"])
+	}
+	if _, ok := changes["legacy"]["after"]; ok || changes["legacy"]["before"] != true {
+		t.Fatalf("unexpected removed field %v", changes["legacy"])
+	}
+	if _, ok := changes["owner"]["before"]; ok || changes["owner"]["after"] != "u-1" {
+		t.Fatalf("unexpected added field %v", changes["owner"])
+	}
+
+	if raw, err := Diff(nil, map[string]any{"id": "f-1"}); err != nil || string(raw) != `{"id":{"after":"f-1"}}` {
+		t.Fatalf("unexpected diff of a created resource: %s (%v)", raw, err)
+	}
+}
+
+func TestRecorderCapturesRequest(t *testing.T) {
+	repo := &memoryRepository{}
+	recorder := NewRecorder(repo, "form")
+	handler := middleware.RequestID(Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		recorder.Record(r.Context(), Change{Action: "form.deleted", ResourceID: "f-1", Before: map[string]any{"id": "f-1"}})
+	})))
+
+	req := httptest.NewRequest(http.MethodDelete, "/forms/f-1", nil)
+	req.Header.Set(middleware.RequestIDHeader, "req-42")
+	req.Header.Set("X-Forwarded-For", "198.51.100.7, 203.0.113.9")
+	req.Header.Set("X-User-ID", "spoofed")
+	ctx := auth.WithPrincipal(req.Context(), auth.Principal{UserID: "u-1"})
+	ctx = tenant.WithID(ctx, "acme")
+	handler.ServeHTTP(httptest.NewRecorder(), req.WithContext(ctx))
+
+	if len(repo.entries) != 1 {
+		t.Fatalf("expected one entry, got %d", len(repo.entries))
+	}
+	entry := repo.entries[0]
+	if entry.TenantID != "acme" || entry.Service != "form" || entry.Actor != "u-1" || entry.ResourceType != "form" || entry.ResourceID != "f-1" {
+		t.Fatalf("unexpected entry %+v", entry)
+	}
+	if entry.RequestID != "req-42" || entry.IP != "203.0.113.9" {
+		t.Fatalf("expected the request ID and the last forwarded hop, got %q and %q", entry.RequestID, entry.IP)
+	}
+
+	var nilRecorder *Recorder
+	nilRecorder.Record(context.Background(), Change{Action: "form.created"})
+}
+
+func TestVerificationDetectsTampering(t *testing.T) {
+	chain := func() []Entry {
+		repo := &memoryRepository{}
+		for _, action := range []string{"user.created", "user.updated", "user.deleted"} {
+			changes, _ := Diff(nil, map[string]any{"action": action})
+			repo.Append(context.Background(), &Entry{TenantID: "acme", Service: "identity", Actor: "u-1", Action: action, ResourceType: "user", ResourceID: "u-2", Changes: changes})
+		}
+		return repo.entries
+	}
+
+	var intact Verification
+	entries := chain()
+	entries[1].Changes = json.RawMessage("{ \"action\" : { \"after\" : \"user.updated\" } }")
+	if !intact.extend(entries) || !intact.Valid() || intact.Entries != 3 || intact.Head != entries[2].Hash {
+		t.Fatalf("expected a reformatted but equal entry to verify, got %+v", intact)
+	}
+
+	cases := map[string]struct {
+		tamper   func([]Entry) []Entry
+		brokenAt int64
+	}{
+		"edited": {func(entries []Entry) []Entry {
+			entries[1].Actor = "u-9"
+			return entries
+		}, 2},
+		"removed": {func(entries []Entry) []Entry {
+			return append(entries[:1], entries[2])
+		}, 2},
+		"rehashed": {func(entries []Entry) []Entry {
+			entries[1].Actor = "u-9"
+			entries[1].Hash = entries[1].ComputeHash()
+			return entries
+		}, 3},
+	}
+	for name, tc := range cases {
+		var verification Verification
+		if verification.extend(tc.tamper(chain())) || verification.Valid() || verification.BrokenAt != tc.brokenAt {
+			t.Fatalf("%s: expected the chain to break at %d, got %+v", name, tc.brokenAt, verification)
+		}
+	}
+}
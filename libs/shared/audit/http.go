@@ -0,0 +1,114 @@
+package audit
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/pflow/shared/auth"
+	"github.com/pflow/shared/httpx"
+)
+
+// Handler exposes the audit log of the current tenant over HTTP.
+type Handler struct {
+	repo  Repository
+	authz auth.Enforcer
+}
+
+// HandlerOption customises the audit handler.
+type HandlerOption func(*Handler)
+
+// WithAuthorization guards the routes with auth.PermissionAuditView.
+func WithAuthorization() HandlerOption {
+	return func(h *Handler) {
+		h.authz.Enabled = true
+	}
+}
+
+// NewHandler constructs the audit HTTP handler.
+func NewHandler(repo Repository, opts ...HandlerOption) *Handler {
+	h := &Handler{repo: repo}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// Mount registers GET /audit, which lists entries newest first, and GET
+// /audit/verify, which checks the hash chain.
+func (h *Handler) Mount(r chi.Router, prefix string) {
+	r.Route(prefix+"/audit", func(r chi.Router) {
+		r.Use(h.authz.Require(auth.PermissionAuditView))
+		r.Get("/", h.list)
+		r.Get("/verify", h.verify)
+	})
+}
+
+var listSpec = httpx.ListSpec{
+	SortFields: map[string]string{
+		"createdAt": "created_at",
+		"action":    "action",
+	},
+	DefaultSort: "createdAt",
+	DefaultDesc: true,
+	Filters: map[string]string{
+		"actor":   "actor",
+		"action":  "action",
+		"service": "service",
+	},
+}
+
+// list accepts resource=<type> or resource=<type>:<id> besides the filters of
+// listSpec, and from/to as RFC3339 bounds of when the change was made.
+func (h *Handler) list(w http.ResponseWriter, r *http.Request) {
+	query, err := httpx.ParseListQuery(r, listSpec)
+	if err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	values := r.URL.Query()
+	if resource := strings.TrimSpace(values.Get("resource")); resource != "" {
+		resourceType, resourceID, scoped := strings.Cut(resource, ":")
+		query.Filters["resource_type"] = []any{resourceType}
+		if scoped {
+			query.Filters["resource_id"] = []any{resourceID}
+		}
+	}
+	for name, bound := range map[string]**time.Time{"from": &query.CreatedAfter, "to": &query.CreatedBefore} {
+		raw := strings.TrimSpace(values.Get(name))
+		if raw == "" {
+			continue
+		}
+		at, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			httpx.Error(w, http.StatusBadRequest, name+" must be an RFC3339 timestamp")
+			return
+		}
+		*bound = &at
+	}
+
+	entries, page, err := h.repo.List(r.Context(), query)
+	if err != nil {
+		httpx.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	httpx.List(w, entries, page)
+}
+
+func (h *Handler) verify(w http.ResponseWriter, r *http.Request) {
+	verification, err := h.repo.Verify(r.Context())
+	if err != nil {
+		httpx.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	httpx.JSON(w, http.StatusOK, map[string]any{"data": map[string]any{
+		"valid":    verification.Valid(),
+		"entries":  verification.Entries,
+		"head":     verification.Head,
+		"brokenAt": verification.BrokenAt,
+		"reason":   verification.Reason,
+	}})
+}
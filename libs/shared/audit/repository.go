@@ -0,0 +1,157 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/pflow/shared/config"
+	"github.com/pflow/shared/database"
+	"github.com/pflow/shared/httpx"
+	"github.com/pflow/shared/tenant"
+)
+
+// Repository appends to and reads the audit log of the tenant of ctx. It
+// deliberately offers no way to change or remove entries.
+type Repository interface {
+	Append(ctx context.Context, entry *Entry) error
+	List(ctx context.Context, query httpx.ListQuery) ([]Entry, httpx.Page, error)
+	Verify(ctx context.Context) (Verification, error)
+}
+
+// chainHead is the latest entry of a tenant's chain. Appends lock it so
+// concurrent writers, from any service, extend the chain one at a time.
+type chainHead struct {
+	TenantID  string `gorm:"type:varchar(64);primaryKey"`
+	Seq       int64  `gorm:"not null;default:0"`
+	Hash      string `gorm:"type:char(64);not null;default:''"`
+	UpdatedAt time.Time
+}
+
+func (chainHead) TableName() string {
+	return "audit_chain_heads"
+}
+
+// Migrate creates the audit schema. On PostgreSQL a trigger additionally
+// rejects every UPDATE and DELETE of audit entries.
+func Migrate(db *gorm.DB) error {
+	if err := db.AutoMigrate(&Entry{}, &chainHead{}); err != nil {
+		return err
+	}
+	if db.Dialector.Name() != "postgres" {
+		return nil
+	}
+	return db.Exec(`CREATE OR REPLACE FUNCTION audit_entries_append_only() RETURNS trigger AS $$
+		BEGIN
+			RAISE EXCEPTION 'audit entries are append-only';
+		END;
+		$$ LANGUAGE plpgsql;
+		DROP TRIGGER IF EXISTS audit_entries_append_only ON audit_entries;
+		CREATE TRIGGER audit_entries_append_only BEFORE UPDATE OR DELETE ON audit_entries
+			FOR EACH ROW EXECUTE FUNCTION audit_entries_append_only();`).Error
+}
+
+// RepositoryFromConfig connects to the audit database, AUDIT_DATABASE_DSN or
+// the shared POSTGRES_DSN, and migrates it. Every service appends to the same
+// log there, so the chain covers changes made through any of them.
+func RepositoryFromConfig(cfg *config.AppConfig) (*GormRepository, error) {
+	db := database.ConnectWithDSN("audit", cfg.DatabaseDSN("audit"))
+	if err := Migrate(db); err != nil {
+		return nil, err
+	}
+	return NewGormRepository(db), nil
+}
+
+// GormRepository persists the audit log via GORM.
+type GormRepository struct {
+	db *gorm.DB
+}
+
+// NewGormRepository constructs an audit repository backed by the provided DB connection.
+func NewGormRepository(db *gorm.DB) *GormRepository {
+	return &GormRepository{db: db}
+}
+
+// Append links entry to the end of its tenant's chain and stores it. It sets
+// the sequence number, timestamp and hashes of the entry.
+func (r *GormRepository) Append(ctx context.Context, entry *Entry) error {
+	if entry.TenantID == "" {
+		entry.TenantID = tenant.ID(ctx)
+	}
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		head := chainHead{TenantID: entry.TenantID}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&head).Error; err != nil {
+			return err
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&head, "tenant_id = ?", entry.TenantID).Error; err != nil {
+			return err
+		}
+
+		entry.Seq = head.Seq + 1
+		entry.PrevHash = head.Hash
+		// PostgreSQL keeps microseconds, so the hash covers no more than that.
+		entry.CreatedAt = time.Now().UTC().Truncate(time.Microsecond)
+		entry.Changes = canonicalJSON(entry.Changes)
+		entry.Hash = entry.ComputeHash()
+		if err := tx.Create(entry).Error; err != nil {
+			return err
+		}
+		return tx.Model(&head).UpdateColumns(map[string]any{
+			"seq":        entry.Seq,
+			"hash":       entry.Hash,
+			"updated_at": entry.CreatedAt,
+		}).Error
+	})
+}
+
+// List pages through the audit log of the current tenant.
+func (r *GormRepository) List(ctx context.Context, query httpx.ListQuery) ([]Entry, httpx.Page, error) {
+	base := r.db.WithContext(ctx).Model(&Entry{}).Scopes(database.TenantScope(ctx))
+	return database.Paginate(base, query, Entry.sortValue)
+}
+
+// verifyBatchSize is how many entries Verify loads at a time.
+const verifyBatchSize = 500
+
+// Verify walks the chain of the current tenant from its first entry and
+// checks every link against the recorded chain head.
+func (r *GormRepository) Verify(ctx context.Context) (Verification, error) {
+	db := r.db.WithContext(ctx)
+	id := tenant.ID(ctx)
+
+	var head chainHead
+	if err := db.Where("tenant_id = ?", id).Limit(1).Find(&head).Error; err != nil {
+		return Verification{}, err
+	}
+
+	var verification Verification
+	for {
+		var batch []Entry
+		err := db.Where("tenant_id = ? AND seq > ?", id, verification.Entries).
+			Order("seq ASC").
+			Limit(verifyBatchSize).
+			Find(&batch).Error
+		if err != nil {
+			return Verification{}, err
+		}
+		if !verification.extend(batch) || len(batch) < verifyBatchSize {
+			break
+		}
+	}
+	if verification.Valid() && (verification.Entries != head.Seq || verification.Head != head.Hash) {
+		verification.fail(verification.Entries+1, fmt.Sprintf("chain ends at entry %d but %d entries were recorded", verification.Entries, head.Seq))
+	}
+	return verification, nil
+}
+
+func (e Entry) sortValue(column string) (any, string) {
+	switch column {
+	case "action":
+		return e.Action, e.ID
+	default:
+		return e.CreatedAt, e.ID
+	}
+}
@@ -0,0 +1,305 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var testSecret = []byte("test-secret")
+
+func signHS256(t *testing.T, claims Claims) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(testSecret)
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	return token
+}
+
+func validClaims(subject string) Claims {
+	return Claims{
+		Roles:       []string{"admin"},
+		Permissions: []string{"ticket:*"},
+		TenantID:    "acme",
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   subject,
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	}
+}
+
+func writeJWKS(t *testing.T, kid string, key *rsa.PublicKey) []byte {
+	t.Helper()
+	document := map[string]any{"keys": []map[string]string{{
+		"kty": "RSA",
+		"kid": kid,
+		"use": "sig",
+		"alg": "RS256",
+		"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}}}
+	data, err := json.Marshal(document)
+	if err != nil {
+		t.Fatalf("marshal jwks: %v", err)
+	}
+	return data
+}
+
+func TestVerifierAcceptsHS256Tokens(t *testing.T) {
+	verifier, err := NewVerifier(context.Background(), Options{HMACSecret: testSecret, Issuer: "pflow"})
+	if err != nil {
+		t.Fatalf("new verifier: %v", err)
+	}
+
+	claims := validClaims("user-1")
+	claims.Issuer = "pflow"
+	principal, err := verifier.Verify(context.Background(), signHS256(t, claims))
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	expected := Principal{UserID: "user-1", Roles: []string{"admin"}, Permissions: []string{"ticket:*"}, TenantID: "acme"}
+	if !reflect.DeepEqual(principal, expected) {
+		t.Fatalf("unexpected principal %+v", principal)
+	}
+
+	expired := validClaims("user-1")
+	expired.Issuer = "pflow"
+	expired.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Hour))
+	wrongIssuer := validClaims("user-1")
+	wrongIssuer.Issuer = "someone-else"
+	noExpiry := validClaims("user-1")
+	noExpiry.Issuer = "pflow"
+	noExpiry.ExpiresAt = nil
+
+	for name, token := range map[string]string{
+		"expired":      signHS256(t, expired),
+		"wrong issuer": signHS256(t, wrongIssuer),
+		"no expiry":    signHS256(t, noExpiry),
+		"garbage":      "not.a.token",
+	} {
+		if _, err := verifier.Verify(context.Background(), token); !errors.Is(err, ErrInvalidToken) {
+			t.Fatalf("%s: expected ErrInvalidToken, got %v", name, err)
+		}
+	}
+}
+
+func TestVerifierAcceptsRS256TokensFromJWKS(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, writeJWKS(t, "key-1", &key.PublicKey), 0o600); err != nil {
+		t.Fatalf("write jwks: %v", err)
+	}
+
+	verifier, err := NewVerifier(context.Background(), Options{JWKS: path})
+	if err != nil {
+		t.Fatalf("new verifier: %v", err)
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, validClaims("user-2"))
+	token.Header["kid"] = "key-1"
+	raw, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	principal, err := verifier.Verify(context.Background(), raw)
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if principal.UserID != "user-2" {
+		t.Fatalf("unexpected principal %+v", principal)
+	}
+
+	// Only RS256 is enabled, so HS256 tokens are rejected outright.
+	if _, err := verifier.Verify(context.Background(), signHS256(t, validClaims("user-2"))); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expected HS256 token to be rejected, got %v", err)
+	}
+}
+
+func TestVerifierFetchesJWKSOverHTTP(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	document := writeJWKS(t, "key-1", &key.PublicKey)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(document)
+	}))
+	defer server.Close()
+
+	verifier, err := NewVerifier(context.Background(), Options{JWKS: server.URL})
+	if err != nil {
+		t.Fatalf("new verifier: %v", err)
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, validClaims("user-3"))
+	token.Header["kid"] = "unknown"
+	raw, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	if _, err := verifier.Verify(context.Background(), raw); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expected unknown key ID to be rejected, got %v", err)
+	}
+
+	if _, err := NewVerifier(context.Background(), Options{}); !errors.Is(err, ErrNoKeys) {
+		t.Fatalf("expected ErrNoKeys, got %v", err)
+	}
+}
+
+func TestMiddlewareInjectsAndForwardsPrincipal(t *testing.T) {
+	verifier, err := NewVerifier(context.Background(), Options{HMACSecret: testSecret})
+	if err != nil {
+		t.Fatalf("new verifier: %v", err)
+	}
+	forwardSecret := []byte("forward-secret")
+
+	var upstream http.Header
+	var seen Principal
+	handler := Middleware(verifier, ForwardSigned(forwardSecret), PublicPaths("/api/health"))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstream = r.Header.Clone()
+		seen, _ = FromContext(r.Context())
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	serve := func(req *http.Request) int {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+		return recorder.Code
+	}
+
+	if code := serve(httptest.NewRequest(http.MethodDelete, "/api/users/1", nil)); code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without token, got %d", code)
+	}
+	if code := serve(httptest.NewRequest(http.MethodGet, "/api/health", nil)); code != http.StatusNoContent {
+		t.Fatalf("expected public path to pass, got %d", code)
+	}
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/users/1", nil)
+	req.Header.Set("Authorization", "Bearer "+signHS256(t, validClaims("user-1")))
+	req.Header.Set(HeaderActor, "spoofed")
+	req.Header.Set(HeaderPermissions, "*")
+	if code := serve(req); code != http.StatusNoContent {
+		t.Fatalf("expected authenticated request to pass, got %d", code)
+	}
+	if seen.UserID != "user-1" || upstream.Get(HeaderActor) != "user-1" || upstream.Get(HeaderPermissions) != "ticket:*" {
+		t.Fatalf("expected principal headers to be rewritten, got %+v %v", seen, upstream)
+	}
+
+	// A service behind the gateway trusts the signed headers, not the caller.
+	var service Principal
+	serviceHandler := Middleware(nil, AcceptForwarded(forwardSecret, time.Minute))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		service, _ = FromContext(r.Context())
+	}))
+	forwarded := httptest.NewRequest(http.MethodGet, "/tickets", nil)
+	forwarded.Header = upstream
+	recorder := httptest.NewRecorder()
+	serviceHandler.ServeHTTP(recorder, forwarded)
+	if recorder.Code != http.StatusOK || !reflect.DeepEqual(service, seen) {
+		t.Fatalf("expected forwarded principal to be accepted, got %d %+v", recorder.Code, service)
+	}
+
+	tampered := httptest.NewRequest(http.MethodGet, "/tickets", nil)
+	tampered.Header = upstream.Clone()
+	tampered.Header.Set(HeaderTenantID, "other-tenant")
+	recorder = httptest.NewRecorder()
+	serviceHandler.ServeHTTP(recorder, tampered)
+	if recorder.Code != http.StatusUnauthorized {
+		t.Fatalf("expected tampered headers to be rejected, got %d", recorder.Code)
+	}
+}
+
+func TestVerifyHeadersRejectsStaleSignatures(t *testing.T) {
+	header := http.Header{}
+	Forward(header, Principal{UserID: "user-1"}, testSecret)
+	if _, err := VerifyHeaders(header, testSecret, time.Minute); err != nil {
+		t.Fatalf("expected fresh signature to verify: %v", err)
+	}
+
+	header.Set(HeaderTimestamp, "1")
+	if _, err := VerifyHeaders(header, testSecret, time.Minute); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("expected stale signature to be rejected, got %v", err)
+	}
+}
+
+func TestSignerIssuesTokensTheVerifierAccepts(t *testing.T) {
+	signer, err := NewSigner(testSecret, SignerOptions{Issuer: "pflow", Audience: "console", TTL: time.Minute})
+	if err != nil {
+		t.Fatalf("new signer: %v", err)
+	}
+	verifier, err := NewVerifier(context.Background(), Options{HMACSecret: testSecret, Issuer: "pflow", Audience: "console"})
+	if err != nil {
+		t.Fatalf("new verifier: %v", err)
+	}
+
+	issued := Principal{UserID: "user-1", Roles: []string{"agent"}, Permissions: []string{PermissionTicketView}}
+	token, expiresAt, err := signer.Sign(issued)
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	if time.Until(expiresAt) > time.Minute {
+		t.Fatalf("expected expiry within the TTL, got %v", expiresAt)
+	}
+	principal, err := verifier.Verify(context.Background(), token)
+	if err != nil || !reflect.DeepEqual(principal, issued) {
+		t.Fatalf("unexpected principal %+v: %v", principal, err)
+	}
+}
+
+func TestRequireChecksPermissions(t *testing.T) {
+	handler := Require(PermissionTicketResolve)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	serve := func(principal *Principal) int {
+		req := httptest.NewRequest(http.MethodPost, "/tickets/1/resolve", nil)
+		if principal != nil {
+			req = req.WithContext(WithPrincipal(req.Context(), *principal))
+		}
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+		return recorder.Code
+	}
+
+	cases := []struct {
+		name      string
+		principal *Principal
+		want      int
+	}{
+		{"anonymous", nil, http.StatusUnauthorized},
+		{"missing permission", &Principal{UserID: "u", Permissions: []string{PermissionTicketView}}, http.StatusForbidden},
+		{"exact permission", &Principal{UserID: "u", Permissions: []string{PermissionTicketResolve}}, http.StatusNoContent},
+		{"resource wildcard", &Principal{UserID: "u", Permissions: []string{"ticket:*"}}, http.StatusNoContent},
+		{"other resource wildcard", &Principal{UserID: "u", Permissions: []string{"form:*"}}, http.StatusForbidden},
+		{"superuser", &Principal{UserID: "u", Permissions: []string{PermissionAll}}, http.StatusNoContent},
+	}
+	for _, tc := range cases {
+		if got := serve(tc.principal); got != tc.want {
+			t.Fatalf("%s: expected %d, got %d", tc.name, tc.want, got)
+		}
+	}
+
+	reached := false
+	open := Enforcer{}.Require(PermissionTicketResolve)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+	}))
+	open.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/tickets/1/resolve", nil))
+	if !reached {
+		t.Fatalf("expected a disabled enforcer to let anonymous requests through")
+	}
+}
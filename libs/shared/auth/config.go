@@ -0,0 +1,60 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/pflow/shared/config"
+)
+
+// servicePublicPaths stay reachable on services without credentials.
+var servicePublicPaths = []string{"/health", "/healthz", "/metrics"}
+
+// FromConfig builds a verifier from AUTH_JWT_SECRET and AUTH_JWKS. It returns a
+// nil verifier, and no error, when neither is configured.
+func FromConfig(ctx context.Context, cfg *config.AppConfig) (*Verifier, error) {
+	if cfg == nil || (cfg.AuthJWTSecret == "" && cfg.AuthJWKS == "") {
+		return nil, nil
+	}
+	return NewVerifier(ctx, Options{
+		HMACSecret: []byte(cfg.AuthJWTSecret),
+		JWKS:       cfg.AuthJWKS,
+		Issuer:     cfg.AuthIssuer,
+		Audience:   cfg.AuthAudience,
+	})
+}
+
+// SignerFromConfig builds the access token signer from AUTH_JWT_SECRET. It
+// returns a nil signer, and no error, when no secret is configured.
+func SignerFromConfig(cfg *config.AppConfig) (*Signer, error) {
+	if cfg == nil || cfg.AuthJWTSecret == "" {
+		return nil, nil
+	}
+	return NewSigner([]byte(cfg.AuthJWTSecret), SignerOptions{
+		Issuer:   cfg.AuthIssuer,
+		Audience: cfg.AuthAudience,
+		TTL:      cfg.AuthAccessTokenTTL,
+	})
+}
+
+// ServiceMiddleware returns the middleware a service mounts through
+// httpx.WithMiddleware. It accepts principal headers signed by the gateway with
+// AUTH_FORWARD_SECRET and, when keys are configured, bearer tokens from direct
+// callers; /health, /metrics and any extra publicPaths, such as login routes,
+// need no credentials. It returns nil when neither is configured, leaving the
+// service open to whatever can reach it.
+func ServiceMiddleware(ctx context.Context, cfg *config.AppConfig, publicPaths ...string) (func(http.Handler) http.Handler, error) {
+	verifier, err := FromConfig(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if verifier == nil && (cfg == nil || cfg.AuthForwardSecret == "") {
+		return nil, nil
+	}
+
+	opts := []Option{PublicPaths(servicePublicPaths...), PublicPaths(publicPaths...)}
+	if cfg.AuthForwardSecret != "" {
+		opts = append(opts, AcceptForwarded([]byte(cfg.AuthForwardSecret), 0))
+	}
+	return Middleware(verifier, opts...), nil
+}
@@ -0,0 +1,150 @@
+package auth
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// jwksRefreshInterval throttles refetching the key set when a token names an
+// unknown key ID, so rotated keys are picked up without hammering the source.
+const jwksRefreshInterval = time.Minute
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// keySet holds the RSA signing keys published by a JWKS file or URL.
+type keySet struct {
+	source string
+	client *http.Client
+
+	mu      sync.RWMutex
+	keys    map[string]*rsa.PublicKey
+	fetched time.Time
+}
+
+func newKeySet(source string, client *http.Client) *keySet {
+	if client == nil {
+		client = &http.Client{Timeout: 5 * time.Second}
+	}
+	return &keySet{source: source, client: client}
+}
+
+func (s *keySet) lookup(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	if key, ok := s.find(kid); ok {
+		return key, nil
+	}
+
+	s.mu.RLock()
+	stale := time.Since(s.fetched) >= jwksRefreshInterval
+	s.mu.RUnlock()
+	if stale {
+		if err := s.load(ctx); err != nil {
+			return nil, err
+		}
+		if key, ok := s.find(kid); ok {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// find resolves kid; tokens without a kid are accepted only when the set holds
+// a single key.
+func (s *keySet) find(kid string) (*rsa.PublicKey, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if kid == "" && len(s.keys) == 1 {
+		for _, key := range s.keys {
+			return key, true
+		}
+	}
+	key, ok := s.keys[kid]
+	return key, ok
+}
+
+func (s *keySet) load(ctx context.Context) error {
+	data, err := s.read(ctx)
+	if err != nil {
+		return fmt.Errorf("auth: load JWKS from %s: %w", s.source, err)
+	}
+
+	var document struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &document); err != nil {
+		return fmt.Errorf("auth: decode JWKS from %s: %w", s.source, err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(document.Keys))
+	for _, entry := range document.Keys {
+		if entry.Kty != "RSA" || (entry.Use != "" && entry.Use != "sig") {
+			continue
+		}
+		key, err := entry.rsaKey()
+		if err != nil {
+			return fmt.Errorf("auth: JWKS key %q: %w", entry.Kid, err)
+		}
+		keys[entry.Kid] = key
+	}
+	if len(keys) == 0 {
+		return fmt.Errorf("auth: JWKS from %s has no RSA signing keys", s.source)
+	}
+
+	s.mu.Lock()
+	s.keys = keys
+	s.fetched = time.Now()
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *keySet) read(ctx context.Context) ([]byte, error) {
+	if !strings.HasPrefix(s.source, "http://") && !strings.HasPrefix(s.source, "https://") {
+		return os.ReadFile(strings.TrimPrefix(s.source, "file://"))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.source, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
+
+func (k jwk) rsaKey() (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil {
+		return nil, fmt.Errorf("invalid modulus: %w", err)
+	}
+	e, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil {
+		return nil, fmt.Errorf("invalid exponent: %w", err)
+	}
+	exponent := new(big.Int).SetBytes(e)
+	if len(n) == 0 || !exponent.IsInt64() || exponent.Int64() < 3 {
+		return nil, fmt.Errorf("invalid RSA parameters")
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, nil
+}
@@ -0,0 +1,133 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pflow/shared/httpx"
+)
+
+const defaultForwardMaxAge = 5 * time.Minute
+
+// Messages returned to rejected callers; the underlying reason is not exposed.
+var (
+	errMissingCredentials = errors.New("authentication required")
+	errBearerNotAccepted  = errors.New("bearer tokens are not accepted")
+	errTokenRejected      = errors.New("invalid or expired token")
+	errForwardRejected    = errors.New("invalid forwarded principal")
+)
+
+type middleware struct {
+	verifier      *Verifier
+	publicPaths   []string
+	acceptSecret  []byte
+	acceptMaxAge  time.Duration
+	forwardSecret []byte
+}
+
+// Option customises Middleware.
+type Option func(*middleware)
+
+// PublicPaths lets requests to the given paths, or anything below them, through
+// without credentials.
+func PublicPaths(paths ...string) Option {
+	return func(m *middleware) {
+		m.publicPaths = append(m.publicPaths, paths...)
+	}
+}
+
+// AcceptForwarded trusts principal headers signed with secret by an upstream
+// gateway, as long as the signature is younger than maxAge (5 minutes if zero).
+func AcceptForwarded(secret []byte, maxAge time.Duration) Option {
+	return func(m *middleware) {
+		if maxAge <= 0 {
+			maxAge = defaultForwardMaxAge
+		}
+		m.acceptSecret = secret
+		m.acceptMaxAge = maxAge
+	}
+}
+
+// ForwardSigned signs the principal headers written onto authenticated requests
+// with secret, for handlers that proxy the request upstream.
+func ForwardSigned(secret []byte) Option {
+	return func(m *middleware) {
+		m.forwardSecret = secret
+	}
+}
+
+// Middleware authenticates every request with a bearer token checked by
+// verifier or, with AcceptForwarded, with signed principal headers. Rejected
+// requests get a 401. Accepted requests carry the principal in their context and
+// have their principal headers rewritten, so X-User-ID always names the caller.
+// verifier may be nil when only forwarded principals are accepted.
+func Middleware(verifier *Verifier, opts ...Option) func(http.Handler) http.Handler {
+	m := &middleware{verifier: verifier}
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if m.public(r.URL.Path) {
+				StripHeaders(r.Header)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			principal, err := m.authenticate(r)
+			if err != nil {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="pflow"`)
+				httpx.Error(w, http.StatusUnauthorized, err.Error())
+				return
+			}
+
+			Forward(r.Header, principal, m.forwardSecret)
+			next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), principal)))
+		})
+	}
+}
+
+func (m *middleware) authenticate(r *http.Request) (Principal, error) {
+	if len(m.acceptSecret) > 0 && r.Header.Get(HeaderSignature) != "" {
+		principal, err := VerifyHeaders(r.Header, m.acceptSecret, m.acceptMaxAge)
+		if err != nil {
+			return Principal{}, errForwardRejected
+		}
+		return principal, nil
+	}
+
+	token, ok := bearerToken(r)
+	if !ok {
+		return Principal{}, errMissingCredentials
+	}
+	if m.verifier == nil {
+		return Principal{}, errBearerNotAccepted
+	}
+	principal, err := m.verifier.Verify(r.Context(), token)
+	if err != nil {
+		return Principal{}, errTokenRejected
+	}
+	return principal, nil
+}
+
+func (m *middleware) public(path string) bool {
+	for _, prefix := range m.publicPaths {
+		if path == prefix || strings.HasPrefix(path, strings.TrimSuffix(prefix, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	header := r.Header.Get("Authorization")
+	scheme, token, found := strings.Cut(header, " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
@@ -0,0 +1,137 @@
+package auth
+
+import (
+	"net/http"
+
+	"github.com/pflow/shared/httpx"
+)
+
+// Permissions checked by the component handlers. They are named
+// "<resource>:<action>"; granting "<resource>:*" covers every action on a
+// resource and PermissionAll covers everything.
+const (
+	PermissionAll = "*"
+
+	PermissionFormView   = "form:view"
+	PermissionFormEdit   = "form:edit"
+	PermissionFormDelete = "form:delete"
+
+	PermissionTicketView    = "ticket:view"
+	PermissionTicketCreate  = "ticket:create"
+	PermissionTicketEdit    = "ticket:edit"
+	PermissionTicketResolve = "ticket:resolve"
+	PermissionTicketDelete  = "ticket:delete"
+	PermissionTicketQueue   = "ticket:queue"
+	PermissionTicketComment = "ticket:comment"
+	PermissionTicketAttach  = "ticket:attach"
+	PermissionTicketSLA     = "ticket:sla"
+	PermissionTicketAssign  = "ticket:assign"
+	PermissionTicketWebhook = "ticket:webhook"
+	// PermissionTicketInternal reveals internal comments, which are hidden
+	// from principals that only hold the public ticket permissions.
+	PermissionTicketInternal = "ticket:internal"
+
+	PermissionWorkflowView    = "workflow:view"
+	PermissionWorkflowEdit    = "workflow:edit"
+	PermissionWorkflowPublish = "workflow:publish"
+	PermissionWorkflowDelete  = "workflow:delete"
+	PermissionWorkflowRun     = "workflow:run"
+
+	PermissionUserView   = "user:view"
+	PermissionUserManage = "user:manage"
+	PermissionRoleView   = "role:view"
+	PermissionRoleManage = "role:manage"
+
+	PermissionAuditView = "audit:view"
+
+	// PermissionTenantSelect lets a principal without a tenant of its own,
+	// such as a platform administrator, act on any tenant through the
+	// X-Tenant-ID header.
+	PermissionTenantSelect = "tenant:select"
+)
+
+// PermissionInfo describes a permission for the identity catalog.
+type PermissionInfo struct {
+	Key         string
+	Description string
+}
+
+// Permissions lists every permission the components enforce.
+func Permissions() []PermissionInfo {
+	return []PermissionInfo{
+		{PermissionFormView, "View forms and their schema versions"},
+		{PermissionFormEdit, "Create and edit forms"},
+		{PermissionFormDelete, "Delete forms"},
+		{PermissionTicketView, "View tickets, their history and submissions"},
+		{PermissionTicketCreate, "Create and submit tickets"},
+		{PermissionTicketEdit, "Edit tickets and move them between statuses"},
+		{PermissionTicketResolve, "Resolve tickets"},
+		{PermissionTicketDelete, "Delete tickets"},
+		{PermissionTicketQueue, "Inspect queue metrics and requeue submissions"},
+		{PermissionTicketComment, "Comment on tickets and edit own comments"},
+		{PermissionTicketInternal, "Read and write internal ticket comments"},
+		{PermissionTicketAttach, "Upload and delete ticket attachments"},
+		{PermissionTicketSLA, "Manage SLA policies"},
+		{PermissionTicketAssign, "Reassign tickets and manage assignment rules"},
+		{PermissionTicketWebhook, "Manage webhooks and redeliver webhook events"},
+		{PermissionWorkflowView, "View workflow definitions, versions and instances"},
+		{PermissionWorkflowEdit, "Create, edit and import workflow definitions"},
+		{PermissionWorkflowPublish, "Publish and roll back workflow versions"},
+		{PermissionWorkflowDelete, "Delete workflow definitions"},
+		{PermissionWorkflowRun, "Start process instances and complete tasks"},
+		{PermissionUserView, "View users"},
+		{PermissionUserManage, "Create, edit and delete users and assign roles"},
+		{PermissionRoleView, "View roles and permissions"},
+		{PermissionRoleManage, "Create, edit and delete roles"},
+		{PermissionAuditView, "View the audit log and verify its integrity"},
+		{PermissionTenantSelect, "Act on any tenant (platform administrators without a tenant of their own)"},
+	}
+}
+
+// Require rejects requests whose principal lacks any of the permissions: 401
+// when the request carries no principal at all, 403 otherwise.
+func Require(permissions ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal, ok := FromContext(r.Context())
+			if !ok {
+				httpx.Error(w, http.StatusUnauthorized, errMissingCredentials.Error())
+				return
+			}
+			for _, permission := range permissions {
+				if !principal.Can(permission) {
+					httpx.Error(w, http.StatusForbidden, "missing permission "+permission)
+					return
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Enforcer lets component handlers declare the permission of each route while
+// leaving enforcement to the deployment: the zero value lets every request
+// through, so components keep working where no authentication is configured.
+type Enforcer struct {
+	Enabled bool
+}
+
+// Require returns the Require middleware when enforcement is enabled and a
+// pass-through otherwise.
+func (e Enforcer) Require(permissions ...string) func(http.Handler) http.Handler {
+	if !e.Enabled {
+		return func(next http.Handler) http.Handler { return next }
+	}
+	return Require(permissions...)
+}
+
+// Allows reports whether the principal of r holds permission. It is always
+// true when enforcement is disabled, and lets handlers vary a response by
+// permission where a route guard is too coarse.
+func (e Enforcer) Allows(r *http.Request, permission string) bool {
+	if !e.Enabled {
+		return true
+	}
+	principal, ok := FromContext(r.Context())
+	return ok && principal.Can(permission)
+}
@@ -0,0 +1,53 @@
+// Package auth verifies the JWTs presented to the gateway and services and
+// carries the authenticated principal through request contexts and, between
+// the gateway and upstream services, through signed headers.
+package auth
+
+import (
+	"context"
+	"strings"
+)
+
+// Principal is the authenticated caller behind a request. Permissions are
+// resolved from the caller's roles when the token is issued.
+type Principal struct {
+	UserID      string
+	Roles       []string
+	Permissions []string
+	TenantID    string
+}
+
+// HasRole reports whether the principal holds the named role.
+func (p Principal) HasRole(role string) bool {
+	for _, candidate := range p.Roles {
+		if candidate == role {
+			return true
+		}
+	}
+	return false
+}
+
+// Can reports whether the principal holds permission, either directly, through
+// a resource wildcard such as "ticket:*", or through the "*" superuser grant.
+func (p Principal) Can(permission string) bool {
+	resource, _, _ := strings.Cut(permission, ":")
+	for _, granted := range p.Permissions {
+		if granted == permission || granted == PermissionAll || granted == resource+":*" {
+			return true
+		}
+	}
+	return false
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying the principal.
+func WithPrincipal(ctx context.Context, principal Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// FromContext returns the principal stored by the middleware, if any.
+func FromContext(ctx context.Context) (Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(Principal)
+	return principal, ok
+}
@@ -0,0 +1,65 @@
+package auth
+
+import (
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const defaultAccessTokenTTL = 15 * time.Minute
+
+// SignerOptions configures a Signer.
+type SignerOptions struct {
+	Issuer   string
+	Audience string
+	// TTL bounds how long issued access tokens stay valid. Defaults to 15 minutes.
+	TTL time.Duration
+}
+
+// Signer issues short-lived HS256 access tokens that a Verifier sharing the
+// same secret accepts.
+type Signer struct {
+	secret []byte
+	opts   SignerOptions
+	now    func() time.Time
+}
+
+// NewSigner returns a signer for secret.
+func NewSigner(secret []byte, opts SignerOptions) (*Signer, error) {
+	if len(secret) == 0 {
+		return nil, errors.New("auth: signer requires an HS256 secret")
+	}
+	if opts.TTL <= 0 {
+		opts.TTL = defaultAccessTokenTTL
+	}
+	return &Signer{secret: secret, opts: opts, now: time.Now}, nil
+}
+
+// Sign issues an access token for principal and reports when it expires.
+func (s *Signer) Sign(principal Principal) (string, time.Time, error) {
+	issuedAt := s.now()
+	expiresAt := issuedAt.Add(s.opts.TTL)
+	claims := Claims{
+		Roles:       principal.Roles,
+		Permissions: principal.Permissions,
+		TenantID:    principal.TenantID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			Subject:   principal.UserID,
+			Issuer:    s.opts.Issuer,
+			IssuedAt:  jwt.NewNumericDate(issuedAt),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	}
+	if s.opts.Audience != "" {
+		claims.Audience = jwt.ClaimStrings{s.opts.Audience}
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(s.secret)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return token, expiresAt, nil
+}
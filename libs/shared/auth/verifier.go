@@ -0,0 +1,123 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	// ErrNoKeys is returned when a verifier is built without any key material.
+	ErrNoKeys = errors.New("auth: no HS256 secret or JWKS source configured")
+	// ErrInvalidToken wraps every reason a bearer token is rejected.
+	ErrInvalidToken = errors.New("auth: invalid token")
+)
+
+const defaultLeeway = 30 * time.Second
+
+// Claims are the JWT claims read on top of the registered ones. A single
+// "role" claim, as issued by some external identity providers, is treated as
+// one more entry in Roles.
+type Claims struct {
+	Roles       []string `json:"roles,omitempty"`
+	Role        string   `json:"role,omitempty"`
+	Permissions []string `json:"permissions,omitempty"`
+	TenantID    string   `json:"tenant_id,omitempty"`
+	jwt.RegisteredClaims
+}
+
+func (c Claims) principal() Principal {
+	roles := append([]string(nil), c.Roles...)
+	if c.Role != "" {
+		roles = append(roles, c.Role)
+	}
+	return Principal{UserID: c.Subject, Roles: roles, Permissions: c.Permissions, TenantID: c.TenantID}
+}
+
+// Options configures a Verifier. At least one of HMACSecret or JWKS is required.
+type Options struct {
+	// HMACSecret enables HS256 tokens signed with a shared secret.
+	HMACSecret []byte
+	// JWKS is a local file path or an http(s) URL serving the RS256 public keys.
+	JWKS string
+	// Issuer and Audience, when set, must match the token's iss and aud claims.
+	Issuer   string
+	Audience string
+	// Leeway tolerates clock skew on exp, nbf and iat. Defaults to 30 seconds.
+	Leeway     time.Duration
+	HTTPClient *http.Client
+}
+
+// Verifier checks signed JWTs and extracts the principal they carry.
+type Verifier struct {
+	secret []byte
+	keys   *keySet
+	parser *jwt.Parser
+}
+
+// NewVerifier builds a verifier for the configured algorithms. A JWKS source is
+// loaded eagerly so misconfiguration surfaces at startup.
+func NewVerifier(ctx context.Context, opts Options) (*Verifier, error) {
+	if len(opts.HMACSecret) == 0 && opts.JWKS == "" {
+		return nil, ErrNoKeys
+	}
+
+	verifier := &Verifier{secret: opts.HMACSecret}
+	var methods []string
+	if len(opts.HMACSecret) > 0 {
+		methods = append(methods, jwt.SigningMethodHS256.Alg())
+	}
+	if opts.JWKS != "" {
+		verifier.keys = newKeySet(opts.JWKS, opts.HTTPClient)
+		if err := verifier.keys.load(ctx); err != nil {
+			return nil, err
+		}
+		methods = append(methods, jwt.SigningMethodRS256.Alg())
+	}
+
+	leeway := opts.Leeway
+	if leeway <= 0 {
+		leeway = defaultLeeway
+	}
+	parserOpts := []jwt.ParserOption{
+		jwt.WithValidMethods(methods),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(leeway),
+	}
+	if opts.Issuer != "" {
+		parserOpts = append(parserOpts, jwt.WithIssuer(opts.Issuer))
+	}
+	if opts.Audience != "" {
+		parserOpts = append(parserOpts, jwt.WithAudience(opts.Audience))
+	}
+	verifier.parser = jwt.NewParser(parserOpts...)
+	return verifier, nil
+}
+
+// Verify validates the raw token and returns its principal. Tokens must carry
+// an expiry and a subject, which becomes the principal's user ID.
+func (v *Verifier) Verify(ctx context.Context, raw string) (Principal, error) {
+	claims := &Claims{}
+	_, err := v.parser.ParseWithClaims(raw, claims, func(token *jwt.Token) (any, error) {
+		switch token.Method.Alg() {
+		case jwt.SigningMethodHS256.Alg():
+			return v.secret, nil
+		case jwt.SigningMethodRS256.Alg():
+			kid, _ := token.Header["kid"].(string)
+			return v.keys.lookup(ctx, kid)
+		default:
+			return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+		}
+	})
+	if err != nil {
+		return Principal{}, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	if claims.Subject == "" {
+		return Principal{}, fmt.Errorf("%w: missing subject", ErrInvalidToken)
+	}
+	return claims.principal(), nil
+}
@@ -4,8 +4,10 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -25,11 +27,85 @@ type AppConfig struct {
 	TicketServiceURL   string
 	WorkflowServiceURL string
 
+	TicketStatusTransitions string
+
+	// Submissions stuck in pending or processing longer than these are
+	// requeued up to TicketSubmissionMaxRequeues times, then failed.
+	TicketSubmissionPendingAfter    time.Duration
+	TicketSubmissionProcessingAfter time.Duration
+	TicketSubmissionMaxRequeues     int64
+
+	// Outbox messages are deleted this long after they were sent: by the
+	// ticket worker after TicketOutboxRetention and by the form, identity and
+	// workflow services after OutboxRetention.
+	TicketOutboxRetention time.Duration
+	OutboxRetention       time.Duration
+
+	// Ticket attachments are stored in TicketAttachmentDir, or in an
+	// S3-compatible bucket when TicketAttachmentStore is "s3". Uploads larger
+	// than TicketAttachmentMaxBytes or of a type outside TicketAttachmentTypes
+	// (comma separated; empty keeps the built-in list) are rejected.
+	TicketAttachmentStore    string
+	TicketAttachmentDir      string
+	TicketAttachmentMaxBytes int64
+	TicketAttachmentTypes    string
+	S3Endpoint               string
+	S3Region                 string
+	S3Bucket                 string
+	S3AccessKey              string
+	S3SecretKey              string
+
+	// Webhooks only deliver to public addresses unless
+	// TicketWebhookAllowPrivate is set; TicketWebhookAllowedHosts (comma
+	// separated, "*.example.com" for subdomains) further limits their hosts.
+	TicketWebhookAllowedHosts string
+	TicketWebhookAllowPrivate bool
+
+	// ProcessEngine selects where published workflows are deployed: "embedded"
+	// (default, no external engine) or "zeebe".
+	ProcessEngine    string
+	CamundaPlaintext bool
+
+	// Authentication: bearer tokens are verified with the HS256 secret and/or
+	// the RS256 keys from AuthJWKS (a file path or URL). The gateway signs the
+	// principal headers it forwards with AuthForwardSecret. AuthDisabled lets
+	// the gateway start without any key material.
+	AuthJWTSecret     string
+	AuthJWKS          string
+	AuthIssuer        string
+	AuthAudience      string
+	AuthForwardSecret string
+	AuthDisabled      bool
+
+	// Lifetimes of the access and refresh tokens the identity service issues.
+	AuthAccessTokenTTL  time.Duration
+	AuthRefreshTokenTTL time.Duration
+
+	// Seeds an administrator with this email and password when the identity
+	// service starts and no such user exists yet. The administrator belongs to
+	// IdentityBootstrapTenant, or to the default tenant when it is empty.
+	IdentityBootstrapEmail    string
+	IdentityBootstrapPassword string
+	IdentityBootstrapTenant   string
+
+	// Soft-deleted forms, workflows, users and tickets can be restored for
+	// this many days before they are purged for good.
+	SoftDeleteRetentionDays int64
+
+	// The form service counts the open tickets of a form through
+	// TICKET_SERVICE_URL before deleting it, when that is set. A failed count
+	// refuses the delete unless FormReferencesAllowOnError is set.
+	FormReferencesAllowOnError bool
+
 	ServiceDatabaseDSN  map[string]string
 	ServiceHTTPPorts    map[string]string
 	ServiceKafkaBrokers map[string]string
 	ServiceQueueTopics  map[string]string
 	ServiceQueueGroups  map[string]string
+
+	ServiceQueueMaxAttempts map[string]string
+	ServiceQueueConcurrency map[string]string
+	ServiceDeadLetterTopics map[string]string
 }
 
 var (
@@ -53,6 +129,49 @@ func Load() *AppConfig {
 			IdentityServiceURL: getEnv("IDENTITY_SERVICE_URL", "http://localhost:8082"),
 			TicketServiceURL:   getEnv("TICKET_SERVICE_URL", "http://localhost:8083"),
 			WorkflowServiceURL: getEnv("WORKFLOW_SERVICE_URL", "http://localhost:8084"),
+
+			TicketStatusTransitions: getEnv("TICKET_STATUS_TRANSITIONS", ""),
+
+			TicketSubmissionPendingAfter:    getDuration("TICKET_SUBMISSION_PENDING_AFTER", 5*time.Minute),
+			TicketSubmissionProcessingAfter: getDuration("TICKET_SUBMISSION_PROCESSING_AFTER", 10*time.Minute),
+			TicketSubmissionMaxRequeues:     getInt64("TICKET_SUBMISSION_MAX_REQUEUES", 3),
+
+			TicketOutboxRetention: getDuration("TICKET_OUTBOX_RETENTION", 7*24*time.Hour),
+			OutboxRetention:       getDuration("OUTBOX_RETENTION", 7*24*time.Hour),
+
+			TicketAttachmentStore:    strings.ToLower(strings.TrimSpace(getEnv("TICKET_ATTACHMENT_STORE", "file"))),
+			TicketAttachmentDir:      getEnv("TICKET_ATTACHMENT_DIR", "data/attachments"),
+			TicketAttachmentMaxBytes: getInt64("TICKET_ATTACHMENT_MAX_BYTES", 10<<20),
+			TicketAttachmentTypes:    getEnv("TICKET_ATTACHMENT_TYPES", ""),
+			S3Endpoint:               getEnv("S3_ENDPOINT", "http://localhost:9000"),
+			S3Region:                 getEnv("S3_REGION", "us-east-1"),
+			S3Bucket:                 getEnv("S3_BUCKET", "pflow-attachments"),
+			S3AccessKey:              getEnv("S3_ACCESS_KEY", ""),
+			S3SecretKey:              getEnv("S3_SECRET_KEY", ""),
+
+			TicketWebhookAllowedHosts: getEnv("TICKET_WEBHOOK_ALLOWED_HOSTS", ""),
+			TicketWebhookAllowPrivate: getEnv("TICKET_WEBHOOK_ALLOW_PRIVATE", "false") == "true",
+
+			ProcessEngine:    strings.ToLower(strings.TrimSpace(getEnv("WORKFLOW_PROCESS_ENGINE", "embedded"))),
+			CamundaPlaintext: getEnv("CAMUNDA_PLAINTEXT", "true") == "true",
+
+			AuthJWTSecret:     getEnv("AUTH_JWT_SECRET", ""),
+			AuthJWKS:          getEnv("AUTH_JWKS", ""),
+			AuthIssuer:        getEnv("AUTH_JWT_ISSUER", ""),
+			AuthAudience:      getEnv("AUTH_JWT_AUDIENCE", ""),
+			AuthForwardSecret: getEnv("AUTH_FORWARD_SECRET", ""),
+			AuthDisabled:      getEnv("AUTH_DISABLED", "false") == "true",
+
+			AuthAccessTokenTTL:  getDuration("AUTH_ACCESS_TOKEN_TTL", 15*time.Minute),
+			AuthRefreshTokenTTL: getDuration("AUTH_REFRESH_TOKEN_TTL", 30*24*time.Hour),
+
+			IdentityBootstrapEmail:    getEnv("IDENTITY_BOOTSTRAP_EMAIL", ""),
+			IdentityBootstrapPassword: getEnv("IDENTITY_BOOTSTRAP_PASSWORD", ""),
+			IdentityBootstrapTenant:   getEnv("IDENTITY_BOOTSTRAP_TENANT", ""),
+
+			SoftDeleteRetentionDays: getInt64("SOFT_DELETE_RETENTION_DAYS", 30),
+
+			FormReferencesAllowOnError: getEnv("FORM_REFERENCES_ALLOW_ON_ERROR", "false") == "true",
 		}
 
 		cfg.ServiceDatabaseDSN = collectServiceValues("DATABASE_DSN")
@@ -60,6 +179,9 @@ func Load() *AppConfig {
 		cfg.ServiceKafkaBrokers = collectServiceValues("KAFKA_BROKERS")
 		cfg.ServiceQueueTopics = collectServiceValues("QUEUE_TOPIC")
 		cfg.ServiceQueueGroups = collectServiceValues("QUEUE_GROUP")
+		cfg.ServiceQueueMaxAttempts = collectServiceValues("QUEUE_MAX_ATTEMPTS")
+		cfg.ServiceQueueConcurrency = collectServiceValues("QUEUE_CONCURRENCY")
+		cfg.ServiceDeadLetterTopics = collectServiceValues("QUEUE_DLQ_TOPIC")
 	})
 
 	return cfg
@@ -73,6 +195,22 @@ func getEnv(key, fallback string) string {
 	return value
 }
 
+func getInt64(key string, fallback int64) int64 {
+	value, err := strconv.ParseInt(strings.TrimSpace(os.Getenv(key)), 10, 64)
+	if err != nil || value <= 0 {
+		return fallback
+	}
+	return value
+}
+
+func getDuration(key string, fallback time.Duration) time.Duration {
+	value, err := time.ParseDuration(strings.TrimSpace(os.Getenv(key)))
+	if err != nil || value <= 0 {
+		return fallback
+	}
+	return value
+}
+
 func defaultServiceName() string {
 	if exe, err := os.Executable(); err == nil {
 		return filepath.Base(exe)
@@ -280,6 +418,48 @@ func (cfg *AppConfig) ResolveServiceQueueGroup(service, fallback string) string
 	return strings.TrimSpace(fallback)
 }
 
+// ResolveServiceQueueMaxAttempts returns how often a service queue message is attempted before dead-lettering.
+func (cfg *AppConfig) ResolveServiceQueueMaxAttempts(service string, fallback int) int {
+	if cfg == nil {
+		return fallback
+	}
+	return resolvePositiveInt(cfg.ServiceQueueMaxAttempts, service, fallback)
+}
+
+// ResolveServiceQueueConcurrency returns how many queue messages a service handles in parallel.
+func (cfg *AppConfig) ResolveServiceQueueConcurrency(service string, fallback int) int {
+	if cfg == nil {
+		return fallback
+	}
+	return resolvePositiveInt(cfg.ServiceQueueConcurrency, service, fallback)
+}
+
+func resolvePositiveInt(values map[string]string, service string, fallback int) int {
+	if raw, ok := values[normalizeServiceKey(service)]; ok {
+		if parsed, err := strconv.Atoi(strings.TrimSpace(raw)); err == nil && parsed > 0 {
+			return parsed
+		}
+	}
+	return fallback
+}
+
+// ResolveServiceDeadLetterTopic returns the dead-letter topic for the service queue.
+func (cfg *AppConfig) ResolveServiceDeadLetterTopic(service, fallback string) string {
+	if cfg == nil {
+		return strings.TrimSpace(fallback)
+	}
+
+	serviceKey := normalizeServiceKey(service)
+	if topic, ok := cfg.ServiceDeadLetterTopics[serviceKey]; ok {
+		topic = strings.TrimSpace(topic)
+		if topic != "" {
+			return topic
+		}
+	}
+
+	return strings.TrimSpace(fallback)
+}
+
 // DatabaseDSN resolves the database DSN for a service, defaulting to PostgresDSN.
 func (cfg *AppConfig) DatabaseDSN(service string) string {
 	if cfg == nil {
@@ -297,6 +477,28 @@ func (cfg *AppConfig) DatabaseDSN(service string) string {
 	return cfg.PostgresDSN
 }
 
+// SoftDeleteRetention is how long soft-deleted records are kept before they are purged.
+func (cfg *AppConfig) SoftDeleteRetention() time.Duration {
+	if cfg == nil {
+		return 0
+	}
+	return time.Duration(cfg.SoftDeleteRetentionDays) * 24 * time.Hour
+}
+
+// TicketWebhookHosts returns the entries of TicketWebhookAllowedHosts.
+func (cfg *AppConfig) TicketWebhookHosts() []string {
+	if cfg == nil {
+		return nil
+	}
+	var hosts []string
+	for _, host := range strings.Split(cfg.TicketWebhookAllowedHosts, ",") {
+		if host = strings.TrimSpace(host); host != "" {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
+
 // MustGet returns the loaded configuration or exits the process.
 func MustGet() *AppConfig {
 	if cfg == nil {
@@ -0,0 +1,92 @@
+package database
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+
+	"github.com/pflow/shared/httpx"
+)
+
+// Paginate applies the filters, sort order and cursor from q to query and returns a
+// single page of results together with the total number of matching rows. sortValue
+// must return the value of the sort column and the primary key of an entity so the
+// next cursor can be built from the last row of the page.
+func Paginate[T any](query *gorm.DB, q httpx.ListQuery, sortValue func(T, string) (any, string)) ([]T, httpx.Page, error) {
+	page := httpx.Page{}
+	filtered := query.Scopes(ListFilters(q))
+
+	if err := filtered.Session(&gorm.Session{}).Count(&page.Total).Error; err != nil {
+		return nil, page, err
+	}
+
+	direction := "ASC"
+	comparator := ">"
+	if q.SortDesc {
+		direction = "DESC"
+		comparator = "<"
+	}
+
+	paged := filtered.Session(&gorm.Session{})
+	if q.Cursor != nil {
+		paged = paged.Where(
+			fmt.Sprintf("(%s, id) %s (?, ?)", q.SortColumn, comparator),
+			q.Cursor.Value, q.Cursor.ID,
+		)
+	}
+
+	var items []T
+	err := paged.
+		Order(fmt.Sprintf("%s %s", q.SortColumn, direction)).
+		Order(fmt.Sprintf("id %s", direction)).
+		Limit(q.Limit + 1).
+		Find(&items).Error
+	if err != nil {
+		return nil, page, err
+	}
+
+	if len(items) > q.Limit {
+		items = items[:q.Limit]
+		value, id := sortValue(items[len(items)-1], q.SortColumn)
+		page.NextCursor = q.NextCursor(value, id)
+	}
+	return items, page, nil
+}
+
+// ListFilters scopes a query to the filters, search term and time windows of q.
+func ListFilters(q httpx.ListQuery) func(*gorm.DB) *gorm.DB {
+	return func(tx *gorm.DB) *gorm.DB {
+		if q.IncludeDeleted {
+			tx = tx.Unscoped()
+		}
+		for column, values := range q.Filters {
+			tx = tx.Where(fmt.Sprintf("%s IN ?", column), values)
+		}
+		for column, at := range q.Before {
+			tx = tx.Where(fmt.Sprintf("%s < ?", column), at)
+		}
+		if q.Search != "" && len(q.SearchColumns) > 0 {
+			like := "%" + q.Search + "%"
+			clause := ""
+			args := make([]any, 0, len(q.SearchColumns))
+			for i, column := range q.SearchColumns {
+				if i > 0 {
+					clause += " OR "
+				}
+				clause += fmt.Sprintf("LOWER(%s) LIKE LOWER(?)", column)
+				args = append(args, like)
+			}
+			tx = tx.Where(clause, args...)
+		}
+		if q.CreatedAfter != nil {
+			tx = tx.Where("created_at >= ?", *q.CreatedAfter)
+		}
+		if q.CreatedBefore != nil {
+			tx = tx.Where("created_at < ?", *q.CreatedBefore)
+		}
+		if q.UpdatedBefore != nil {
+			tx = tx.Where("updated_at < ?", *q.UpdatedBefore)
+		}
+		return tx
+	}
+}
@@ -0,0 +1,151 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrNotDeleted is returned by Restore when the row is not soft-deleted.
+var ErrNotDeleted = errors.New("record is not deleted")
+
+// Restore undoes the soft delete of the row of model with id in the tenant of
+// ctx. It fails with gorm.ErrRecordNotFound when the tenant has no such row
+// and with ErrNotDeleted when the row was not deleted.
+func Restore(ctx context.Context, db *gorm.DB, model any, id string) error {
+	row := Conn(ctx, db).Unscoped().Model(model).Scopes(TenantScope(ctx)).Where("id = ?", id)
+	result := row.Session(&gorm.Session{}).Where("deleted_at IS NOT NULL").Update("deleted_at", nil)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		return nil
+	}
+
+	var count int64
+	if err := row.Session(&gorm.Session{}).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return ErrNotDeleted
+}
+
+// PurgeDeleted hard-deletes up to limit rows of model, in every tenant, that
+// were soft-deleted before the cutoff and returns how many it removed. The
+// rows are selected and locked inside the transaction, so a concurrent Restore
+// either waits for the purge or keeps its row out of it. cleanup, when set,
+// first runs in the same transaction with the IDs of those rows to remove the
+// rows that depend on them.
+func PurgeDeleted(ctx context.Context, db *gorm.DB, model any, before time.Time, limit int, cleanup func(tx *gorm.DB, ids []string) error) (int, error) {
+	purged := 0
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		expired := tx.Unscoped().Model(model).Where("deleted_at IS NOT NULL AND deleted_at < ?", before)
+
+		var ids []string
+		err := expired.Session(&gorm.Session{}).
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Order("deleted_at ASC").
+			Limit(limit).
+			Pluck("id", &ids).Error
+		if err != nil || len(ids) == 0 {
+			return err
+		}
+
+		if cleanup != nil {
+			if err := cleanup(tx, ids); err != nil {
+				return err
+			}
+		}
+		result := expired.Session(&gorm.Session{}).Delete(model, "id IN ?", ids)
+		purged = int(result.RowsAffected)
+		return result.Error
+	})
+	if err != nil {
+		return 0, err
+	}
+	return purged, nil
+}
+
+// PurgeFunc hard-deletes up to limit rows soft-deleted before the cutoff and
+// returns how many it removed.
+type PurgeFunc func(ctx context.Context, before time.Time, limit int) (int, error)
+
+// PurgerConfig controls how long soft-deleted rows stay restorable.
+type PurgerConfig struct {
+	// Retention is how long after its deletion a row is purged.
+	Retention time.Duration
+	Interval  time.Duration
+	BatchSize int
+}
+
+func (cfg PurgerConfig) normalize() PurgerConfig {
+	normalized := cfg
+	if normalized.Retention <= 0 {
+		normalized.Retention = 30 * 24 * time.Hour
+	}
+	if normalized.Interval <= 0 {
+		normalized.Interval = time.Hour
+	}
+	if normalized.BatchSize <= 0 {
+		normalized.BatchSize = 100
+	}
+	return normalized
+}
+
+// Purger periodically hard-deletes the rows that were soft-deleted longer
+// than the retention period ago.
+type Purger struct {
+	name  string
+	purge PurgeFunc
+	cfg   PurgerConfig
+}
+
+// NewPurger constructs a purger; name identifies it in log messages.
+func NewPurger(name string, purge PurgeFunc, cfg PurgerConfig) *Purger {
+	return &Purger{name: name, purge: purge, cfg: cfg.normalize()}
+}
+
+// Run purges every interval until the context is cancelled.
+func (p *Purger) Run(ctx context.Context) error {
+	if p == nil || p.purge == nil {
+		return fmt.Errorf("purger not initialised")
+	}
+
+	ticker := time.NewTicker(p.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		if purged, err := p.PurgeOnce(ctx); err != nil {
+			log.Printf("%s purger: %v", p.name, err)
+		} else if purged > 0 {
+			log.Printf("%s purger: purged %d rows deleted over %s ago", p.name, purged, p.cfg.Retention)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// PurgeOnce purges, batch by batch, every row past the retention period and
+// returns how many were removed.
+func (p *Purger) PurgeOnce(ctx context.Context) (int, error) {
+	before := time.Now().Add(-p.cfg.Retention)
+	purged := 0
+	for {
+		n, err := p.purge(ctx, before, p.cfg.BatchSize)
+		purged += n
+		if err != nil || n < p.cfg.BatchSize {
+			return purged, err
+		}
+	}
+}
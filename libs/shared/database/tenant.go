@@ -0,0 +1,23 @@
+package database
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/pflow/shared/tenant"
+)
+
+// TenantScope restricts a query on a tenant-owned table to the tenant of ctx,
+// or tenant.Default when ctx names none. Repositories apply it to every query
+// so one tenant can never read or change another tenant's rows.
+func TenantScope(ctx context.Context) func(*gorm.DB) *gorm.DB {
+	id := tenant.ID(ctx)
+	return func(tx *gorm.DB) *gorm.DB {
+		return tx.Where(clause.Eq{
+			Column: clause.Column{Table: clause.CurrentTable, Name: "tenant_id"},
+			Value:  id,
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package database
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+type txKey struct{}
+
+// WithTx returns a copy of ctx carrying tx, so repositories handed ctx run
+// their queries in that transaction.
+func WithTx(ctx context.Context, tx *gorm.DB) context.Context {
+	return context.WithValue(ctx, txKey{}, tx)
+}
+
+// Conn returns the transaction carried by ctx, or db bound to ctx when there
+// is none. Repositories use it instead of db.WithContext so their writes join
+// a transaction a caller opened with Transaction.
+func Conn(ctx context.Context, db *gorm.DB) *gorm.DB {
+	if tx, ok := ctx.Value(txKey{}).(*gorm.DB); ok && tx != nil {
+		return tx.WithContext(ctx)
+	}
+	return db.WithContext(ctx)
+}
+
+// Transaction runs fn in a transaction on db, or in the transaction ctx
+// already carries, as a nested savepoint. fn must pass the context it is
+// given to the repositories it calls.
+func Transaction(ctx context.Context, db *gorm.DB, fn func(ctx context.Context) error) error {
+	return Conn(ctx, db).Transaction(func(tx *gorm.DB) error {
+		return fn(WithTx(ctx, tx))
+	})
+}
+
+// LockKey takes a lock on key that is held until the transaction tx ends, for
+// writes that must be serialised but have no row to lock, such as numbering
+// rows per name. On PostgreSQL it is an advisory lock; SQLite admits a single
+// writer at a time and needs none.
+func LockKey(tx *gorm.DB, key string) error {
+	if tx.Dialector.Name() != "postgres" {
+		return nil
+	}
+	return tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", key).Error
+}
@@ -0,0 +1,260 @@
+package httpx
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultListLimit = 50
+	maxListLimit     = 200
+)
+
+// ListSpec declares the sort fields and filters a list endpoint accepts. Keys are
+// the public query parameter names, values are the underlying column names.
+// SoftDelete lists soft-deleted rows too when the request asks for
+// includeDeleted=true.
+type ListSpec struct {
+	SortFields    map[string]string
+	DefaultSort   string
+	DefaultDesc   bool
+	Filters       map[string]string
+	BoolFilters   map[string]string
+	BeforeFilters map[string]string
+	SearchColumns []string
+	DefaultLimit  int
+	MaxLimit      int
+	SoftDelete    bool
+}
+
+// ListQuery is the parsed pagination, sorting and filtering contract shared by list endpoints.
+type ListQuery struct {
+	Limit         int
+	Cursor        *Cursor
+	SortColumn    string
+	SortDesc      bool
+	Filters       map[string][]any
+	Before        map[string]time.Time
+	Search        string
+	SearchColumns []string
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	UpdatedBefore *time.Time
+	// IncludeDeleted lists soft-deleted rows alongside the others.
+	IncludeDeleted bool
+}
+
+// Cursor identifies the last row of a page for keyset pagination.
+type Cursor struct {
+	Sort  string `json:"s"`
+	Desc  bool   `json:"d"`
+	Value string `json:"v"`
+	ID    string `json:"id"`
+}
+
+// Page describes the position of a list response within the full result set.
+type Page struct {
+	NextCursor string
+	Total      int64
+}
+
+// ParseListQuery reads limit, cursor, sort, order, search, createdAfter/createdBefore,
+// updatedBefore, includeDeleted and the filters declared in spec from the request
+// query string.
+// Filters accept comma separated values, e.g. status=open,in_progress; before
+// filters take an RFC3339 timestamp the column must precede.
+func ParseListQuery(r *http.Request, spec ListSpec) (ListQuery, error) {
+	values := r.URL.Query()
+	query := ListQuery{
+		Limit:         spec.DefaultLimit,
+		Filters:       map[string][]any{},
+		Before:        map[string]time.Time{},
+		SearchColumns: spec.SearchColumns,
+	}
+	if query.Limit <= 0 {
+		query.Limit = defaultListLimit
+	}
+	maxLimit := spec.MaxLimit
+	if maxLimit <= 0 {
+		maxLimit = maxListLimit
+	}
+
+	if raw := strings.TrimSpace(values.Get("limit")); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit <= 0 {
+			return ListQuery{}, fmt.Errorf("limit must be a positive integer")
+		}
+		if limit > maxLimit {
+			limit = maxLimit
+		}
+		query.Limit = limit
+	}
+
+	sortName := strings.TrimSpace(values.Get("sort"))
+	desc := spec.DefaultDesc
+	if strings.HasPrefix(sortName, "-") {
+		sortName = strings.TrimPrefix(sortName, "-")
+		desc = true
+	}
+	if sortName == "" {
+		sortName = spec.DefaultSort
+	}
+	column, ok := spec.SortFields[sortName]
+	if !ok {
+		return ListQuery{}, fmt.Errorf("unsupported sort field %q", sortName)
+	}
+	query.SortColumn = column
+
+	switch strings.ToLower(strings.TrimSpace(values.Get("order"))) {
+	case "":
+	case "asc":
+		desc = false
+	case "desc":
+		desc = true
+	default:
+		return ListQuery{}, fmt.Errorf("order must be asc or desc")
+	}
+	query.SortDesc = desc
+
+	for name, column := range spec.Filters {
+		if items := splitList(values[name]); len(items) > 0 {
+			filter := make([]any, 0, len(items))
+			for _, item := range items {
+				filter = append(filter, item)
+			}
+			query.Filters[column] = filter
+		}
+	}
+	for name, column := range spec.BoolFilters {
+		items := splitList(values[name])
+		if len(items) == 0 {
+			continue
+		}
+		filter := make([]any, 0, len(items))
+		for _, item := range items {
+			parsed, err := strconv.ParseBool(item)
+			if err != nil {
+				return ListQuery{}, fmt.Errorf("invalid %s filter", name)
+			}
+			filter = append(filter, parsed)
+		}
+		query.Filters[column] = filter
+	}
+
+	for name, column := range spec.BeforeFilters {
+		at, err := parseTimeParam(values.Get(name))
+		if err != nil {
+			return ListQuery{}, fmt.Errorf("%s must be an RFC3339 timestamp", name)
+		}
+		if at != nil {
+			query.Before[column] = *at
+		}
+	}
+
+	if len(spec.SearchColumns) > 0 {
+		query.Search = strings.TrimSpace(values.Get("search"))
+	}
+
+	var err error
+	if query.CreatedAfter, err = parseTimeParam(values.Get("createdAfter")); err != nil {
+		return ListQuery{}, fmt.Errorf("createdAfter must be an RFC3339 timestamp")
+	}
+	if query.CreatedBefore, err = parseTimeParam(values.Get("createdBefore")); err != nil {
+		return ListQuery{}, fmt.Errorf("createdBefore must be an RFC3339 timestamp")
+	}
+	if query.UpdatedBefore, err = parseTimeParam(values.Get("updatedBefore")); err != nil {
+		return ListQuery{}, fmt.Errorf("updatedBefore must be an RFC3339 timestamp")
+	}
+
+	if raw := strings.TrimSpace(values.Get("includeDeleted")); raw != "" && spec.SoftDelete {
+		if query.IncludeDeleted, err = strconv.ParseBool(raw); err != nil {
+			return ListQuery{}, fmt.Errorf("includeDeleted must be a boolean")
+		}
+	}
+
+	if raw := strings.TrimSpace(values.Get("cursor")); raw != "" {
+		cursor, err := DecodeCursor(raw)
+		if err != nil {
+			return ListQuery{}, err
+		}
+		if cursor.Sort != query.SortColumn || cursor.Desc != query.SortDesc {
+			return ListQuery{}, fmt.Errorf("cursor does not match the requested sort")
+		}
+		query.Cursor = cursor
+	}
+
+	return query, nil
+}
+
+// NextCursor builds the cursor pointing after a row with the given sort value and ID.
+func (q ListQuery) NextCursor(value any, id string) string {
+	cursor := Cursor{Sort: q.SortColumn, Desc: q.SortDesc, ID: id}
+	switch v := value.(type) {
+	case time.Time:
+		cursor.Value = v.UTC().Format(time.RFC3339Nano)
+	case string:
+		cursor.Value = v
+	default:
+		cursor.Value = fmt.Sprint(v)
+	}
+
+	raw, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// DecodeCursor parses an opaque cursor token.
+func DecodeCursor(token string) (*Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	var cursor Cursor
+	if err := json.Unmarshal(raw, &cursor); err != nil || cursor.ID == "" {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	return &cursor, nil
+}
+
+// List writes a paginated collection using the standard list envelope.
+func List(w http.ResponseWriter, items any, page Page) {
+	payload := map[string]any{
+		"data":  items,
+		"total": page.Total,
+	}
+	if page.NextCursor != "" {
+		payload["nextCursor"] = page.NextCursor
+	} else {
+		payload["nextCursor"] = nil
+	}
+	JSON(w, http.StatusOK, payload)
+}
+
+func splitList(raw []string) []string {
+	out := make([]string, 0, len(raw))
+	for _, value := range raw {
+		for _, part := range strings.Split(value, ",") {
+			part = strings.TrimSpace(part)
+			if part != "" {
+				out = append(out, part)
+			}
+		}
+	}
+	return out
+}
+
+func parseTimeParam(raw string) (*time.Time, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, nil
+	}
+	parsed, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return nil, err
+	}
+	return &parsed, nil
+}
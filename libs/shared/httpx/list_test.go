@@ -0,0 +1,127 @@
+package httpx
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var testSpec = ListSpec{
+	SortFields:  map[string]string{"createdAt": "created_at", "title": "title"},
+	DefaultSort: "createdAt",
+	DefaultDesc: true,
+	Filters:     map[string]string{"status": "status"},
+	BoolFilters: map[string]string{"published": "published"},
+}
+
+func TestParseListQueryDefaults(t *testing.T) {
+	req := httptest.NewRequest("GET", "/tickets", nil)
+	query, err := ParseListQuery(req, testSpec)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if query.Limit != defaultListLimit || query.SortColumn != "created_at" || !query.SortDesc {
+		t.Fatalf("unexpected defaults: %+v", query)
+	}
+	if len(query.Filters) != 0 || query.Cursor != nil {
+		t.Fatalf("expected no filters or cursor: %+v", query)
+	}
+}
+
+func TestParseListQueryFiltersAndSort(t *testing.T) {
+	req := httptest.NewRequest("GET", "/tickets?status=open,in_progress&published=true&sort=title&order=asc&limit=500&createdAfter=2024-01-02T03:04:05Z", nil)
+	query, err := ParseListQuery(req, testSpec)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if query.Limit != maxListLimit {
+		t.Fatalf("expected limit to be capped, got %d", query.Limit)
+	}
+	if query.SortColumn != "title" || query.SortDesc {
+		t.Fatalf("unexpected sort: %s desc=%v", query.SortColumn, query.SortDesc)
+	}
+	if got := query.Filters["status"]; len(got) != 2 || got[0] != "open" || got[1] != "in_progress" {
+		t.Fatalf("unexpected status filter: %v", got)
+	}
+	if got := query.Filters["published"]; len(got) != 1 || got[0] != true {
+		t.Fatalf("unexpected published filter: %v", got)
+	}
+	if query.CreatedAfter == nil || !query.CreatedAfter.Equal(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)) {
+		t.Fatalf("unexpected createdAfter: %v", query.CreatedAfter)
+	}
+}
+
+func TestParseListQueryRejectsInvalidInput(t *testing.T) {
+	for _, target := range []string{
+		"/tickets?sort=unknown",
+		"/tickets?limit=-1",
+		"/tickets?order=sideways",
+		"/tickets?published=maybe",
+		"/tickets?cursor=not-a-cursor",
+	} {
+		req := httptest.NewRequest("GET", target, nil)
+		if _, err := ParseListQuery(req, testSpec); err == nil {
+			t.Errorf("expected %s to be rejected", target)
+		}
+	}
+}
+
+func TestParseListQueryBeforeFilters(t *testing.T) {
+	spec := testSpec
+	spec.BeforeFilters = map[string]string{"dueBefore": "due_at"}
+
+	req := httptest.NewRequest("GET", "/tickets?dueBefore=2024-02-01T00:00:00Z", nil)
+	query, err := ParseListQuery(req, spec)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, ok := query.Before["due_at"]; !ok || !got.Equal(time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected dueBefore: %v", query.Before)
+	}
+	req = httptest.NewRequest("GET", "/tickets?dueBefore=tomorrow", nil)
+	if _, err := ParseListQuery(req, spec); err == nil {
+		t.Fatal("expected an invalid dueBefore to be rejected")
+	}
+}
+
+func TestParseListQueryIncludeDeleted(t *testing.T) {
+	req := httptest.NewRequest("GET", "/tickets?includeDeleted=true", nil)
+	if query, err := ParseListQuery(req, testSpec); err != nil || query.IncludeDeleted {
+		t.Fatalf("expected includeDeleted to be ignored without soft delete, got %+v (%v)", query, err)
+	}
+
+	spec := testSpec
+	spec.SoftDelete = true
+	if query, err := ParseListQuery(req, spec); err != nil || !query.IncludeDeleted {
+		t.Fatalf("expected includeDeleted to be parsed, got %+v (%v)", query, err)
+	}
+	req = httptest.NewRequest("GET", "/tickets?includeDeleted=sometimes", nil)
+	if _, err := ParseListQuery(req, spec); err == nil {
+		t.Fatal("expected an invalid includeDeleted to be rejected")
+	}
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	req := httptest.NewRequest("GET", "/tickets", nil)
+	query, err := ParseListQuery(req, testSpec)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	created := time.Date(2024, 5, 6, 7, 8, 9, 123456000, time.UTC)
+	token := query.NextCursor(created, "abc")
+
+	req = httptest.NewRequest("GET", "/tickets?cursor="+token, nil)
+	next, err := ParseListQuery(req, testSpec)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if next.Cursor == nil || next.Cursor.ID != "abc" || next.Cursor.Value != created.Format(time.RFC3339Nano) {
+		t.Fatalf("unexpected cursor: %+v", next.Cursor)
+	}
+
+	req = httptest.NewRequest("GET", "/tickets?sort=title&cursor="+token, nil)
+	if _, err := ParseListQuery(req, testSpec); err == nil {
+		t.Fatalf("expected cursor from a different sort to be rejected")
+	}
+}
@@ -19,3 +19,9 @@ func JSON(w http.ResponseWriter, status int, payload any) {
 func Error(w http.ResponseWriter, status int, message string) {
 	JSON(w, status, map[string]any{"error": message})
 }
+
+// ErrorDetails writes an error response that also carries structured details, such
+// as per-field validation failures.
+func ErrorDetails(w http.ResponseWriter, status int, message string, details any) {
+	JSON(w, status, map[string]any{"error": message, "details": details})
+}
@@ -15,11 +15,30 @@ type Server struct {
 	httpServer *http.Server
 }
 
+// Option customises the server built by New.
+type Option func(chi.Router)
+
+// WithMiddleware mounts additional middleware after the defaults. Nil entries
+// are skipped so optional middleware can be passed straight through.
+func WithMiddleware(middlewares ...func(http.Handler) http.Handler) Option {
+	return func(router chi.Router) {
+		for _, mw := range middlewares {
+			if mw != nil {
+				router.Use(mw)
+			}
+		}
+	}
+}
+
 // New creates a new HTTP server with sane defaults.
-func New() *Server {
+func New(opts ...Option) *Server {
 	router := chi.NewRouter()
+	router.Use(middleware.RequestID)
 	router.Use(middleware.Recoverer)
 	router.Use(middleware.Logger)
+	for _, opt := range opts {
+		opt(router)
+	}
 
 	return &Server{Router: router}
 }
@@ -35,6 +35,26 @@ type ConsumerConfig struct {
 	ClientID string
 	MinBytes int
 	MaxBytes int
+
+	// MaxAttempts bounds how often a message is handed to the handler before it is
+	// dead-lettered. Values <= 0 default to 1 (no retries).
+	MaxAttempts int
+	// InitialBackoff and MaxBackoff control the exponential delay between attempts.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// DeadLetterTopic receives messages that exhausted their attempts. When empty,
+	// failed messages are logged and committed.
+	DeadLetterTopic string
+
+	// Concurrency is the number of handlers running in parallel. Messages sharing a
+	// key are always handled by the same handler, in order. Values <= 0 default to 1.
+	Concurrency int
+	// MaxInFlight bounds the number of fetched but not yet committed messages.
+	// Values <= 0 default to 4x Concurrency.
+	MaxInFlight int
+	// DrainTimeout is how long in-flight handlers may keep running after shutdown
+	// was requested before their context is cancelled.
+	DrainTimeout time.Duration
 }
 
 // Validate ensures the consumer configuration is usable.
@@ -48,9 +68,24 @@ func (cfg ConsumerConfig) Validate() error {
 	if strings.TrimSpace(cfg.GroupID) == "" {
 		return errors.New("mq: group id must be provided")
 	}
+	if dlq := strings.TrimSpace(cfg.DeadLetterTopic); dlq != "" && dlq == strings.TrimSpace(cfg.Topic) {
+		return errors.New("mq: dead-letter topic must differ from the consumed topic")
+	}
 	return nil
 }
 
+// backoff returns the delay to wait after the given failed attempt.
+func (cfg ConsumerConfig) backoff(attempt int) time.Duration {
+	delay := cfg.InitialBackoff
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if delay >= cfg.MaxBackoff {
+			return cfg.MaxBackoff
+		}
+	}
+	return delay
+}
+
 func (cfg ProducerConfig) effectiveTimeout() time.Duration {
 	if cfg.Timeout <= 0 {
 		return 5 * time.Second
@@ -73,6 +108,31 @@ func (cfg ConsumerConfig) normalize() ConsumerConfig {
 	if normalized.MaxBytes <= 0 {
 		normalized.MaxBytes = 10e6
 	}
+	if normalized.MaxAttempts <= 0 {
+		normalized.MaxAttempts = 1
+	}
+	if normalized.InitialBackoff <= 0 {
+		normalized.InitialBackoff = 200 * time.Millisecond
+	}
+	if normalized.MaxBackoff <= 0 {
+		normalized.MaxBackoff = 10 * time.Second
+	}
+	if normalized.MaxBackoff < normalized.InitialBackoff {
+		normalized.MaxBackoff = normalized.InitialBackoff
+	}
+	normalized.DeadLetterTopic = strings.TrimSpace(normalized.DeadLetterTopic)
+	if normalized.Concurrency <= 0 {
+		normalized.Concurrency = 1
+	}
+	if normalized.MaxInFlight <= 0 {
+		normalized.MaxInFlight = 4 * normalized.Concurrency
+	}
+	if normalized.MaxInFlight < normalized.Concurrency {
+		normalized.MaxInFlight = normalized.Concurrency
+	}
+	if normalized.DrainTimeout <= 0 {
+		normalized.DrainTimeout = 30 * time.Second
+	}
 	normalized.Topic = strings.TrimSpace(normalized.Topic)
 	normalized.GroupID = strings.TrimSpace(normalized.GroupID)
 	normalized.ClientID = strings.TrimSpace(normalized.ClientID)
@@ -120,5 +180,5 @@ func (cfg ProducerConfig) String() string {
 // String implements fmt.Stringer for ConsumerConfig.
 func (cfg ConsumerConfig) String() string {
 	normalized := cfg.normalize()
-	return fmt.Sprintf("ConsumerConfig{brokers=%s, topic=%s, group=%s, client=%s}", joinBrokers(normalized.Brokers), normalized.Topic, normalized.GroupID, normalized.ClientID)
+	return fmt.Sprintf("ConsumerConfig{brokers=%s, topic=%s, group=%s, client=%s, attempts=%d, dlq=%s, concurrency=%d}", joinBrokers(normalized.Brokers), normalized.Topic, normalized.GroupID, normalized.ClientID, normalized.MaxAttempts, normalized.DeadLetterTopic, normalized.Concurrency)
 }
@@ -2,28 +2,58 @@ package mq
 
 import (
 	"context"
-	"errors"
+	"fmt"
+	"hash/fnv"
 	"log"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/segmentio/kafka-go"
 )
 
+// Headers added to messages routed to a dead-letter topic.
+const (
+	HeaderDeadLetterError     = "x-dlq-error"
+	HeaderDeadLetterAttempts  = "x-dlq-attempts"
+	HeaderDeadLetterTopic     = "x-dlq-original-topic"
+	HeaderDeadLetterPartition = "x-dlq-original-partition"
+	HeaderDeadLetterOffset    = "x-dlq-original-offset"
+	HeaderDeadLetterFailedAt  = "x-dlq-failed-at"
+)
+
 // Message represents a Kafka message delivered to consumers.
 type Message struct {
-	Key     []byte
-	Value   []byte
-	Headers map[string]string
-	Time    time.Time
+	Key       []byte
+	Value     []byte
+	Headers   map[string]string
+	Time      time.Time
+	Topic     string
+	Partition int
+	Offset    int64
 }
 
 // Handler processes messages from a consumer.
 type Handler func(context.Context, Message) error
 
-// Consumer wraps a Kafka reader and invokes a handler for each message.
+type messageReader interface {
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+	CommitMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+type messageWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+// Consumer wraps a Kafka reader and invokes a handler for each message. Offsets are
+// committed only after the handler succeeded or the message was dead-lettered.
 type Consumer struct {
-	reader  *kafka.Reader
-	handler Handler
+	reader     messageReader
+	deadLetter messageWriter
+	handler    Handler
+	cfg        ConsumerConfig
 }
 
 // NewConsumer constructs a Kafka consumer and prepares it for message processing.
@@ -44,50 +74,322 @@ func NewConsumer(cfg ConsumerConfig, handler Handler) (*Consumer, error) {
 		readerCfg.Dialer = &kafka.Dialer{ClientID: normalized.ClientID}
 	}
 
-	log.Printf("mq: initialized consumer %s", normalized.String())
-	return &Consumer{
+	consumer := &Consumer{
 		reader:  kafka.NewReader(readerCfg),
 		handler: handler,
-	}, nil
+		cfg:     normalized,
+	}
+
+	if normalized.DeadLetterTopic != "" {
+		writer := &kafka.Writer{
+			Addr:                   kafka.TCP(normalized.Brokers...),
+			Topic:                  normalized.DeadLetterTopic,
+			AllowAutoTopicCreation: true,
+			RequiredAcks:           kafka.RequireAll,
+			BatchSize:              1,
+		}
+		if normalized.ClientID != "" {
+			writer.Transport = &kafka.Transport{ClientID: normalized.ClientID}
+		}
+		consumer.deadLetter = writer
+	}
+
+	log.Printf("mq: initialized consumer %s", normalized.String())
+	return consumer, nil
 }
 
 // Run starts consuming messages until the context is cancelled or an unrecoverable error occurs.
+// Messages are dispatched to cfg.Concurrency handlers by key so that messages sharing a key
+// are processed in order. Offsets are committed per partition only once every earlier
+// message of that partition has completed. When ctx is cancelled, fetching stops and
+// in-flight messages are drained for up to cfg.DrainTimeout before returning.
 func (c *Consumer) Run(ctx context.Context) error {
 	if c == nil || c.reader == nil {
 		return nil
 	}
 
-	for {
-		msg, err := c.reader.ReadMessage(ctx)
-		if err != nil {
-			if errors.Is(err, context.Canceled) {
-				return ctx.Err()
+	fetchCtx, stopFetching := context.WithCancel(ctx)
+	defer stopFetching()
+	handlerCtx, cancelHandlers := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelHandlers()
+
+	slots := make(chan struct{}, c.cfg.MaxInFlight)
+	results := make(chan processed, c.cfg.MaxInFlight)
+	lanes := make([]chan kafka.Message, c.cfg.Concurrency)
+	var workers sync.WaitGroup
+	for i := range lanes {
+		lanes[i] = make(chan kafka.Message, c.cfg.MaxInFlight)
+		workers.Add(1)
+		go func(lane <-chan kafka.Message) {
+			defer workers.Done()
+			for msg := range lane {
+				results <- processed{msg: msg, err: c.process(handlerCtx, msg)}
 			}
-			return err
+		}(lanes[i])
+	}
+	defer func() {
+		for _, lane := range lanes {
+			close(lane)
 		}
+		workers.Wait()
+	}()
 
-		payload := Message{
-			Key:     msg.Key,
-			Value:   msg.Value,
-			Headers: make(map[string]string, len(msg.Headers)),
-			Time:    msg.Time,
-		}
-		for _, header := range msg.Headers {
-			payload.Headers[header.Key] = string(header.Value)
+	fetched := make(chan kafka.Message)
+	fetchErr := make(chan error, 1)
+	go func() {
+		defer close(fetched)
+		for {
+			select {
+			case slots <- struct{}{}:
+			case <-fetchCtx.Done():
+				return
+			}
+			msg, err := c.reader.FetchMessage(fetchCtx)
+			if err != nil {
+				if fetchCtx.Err() == nil {
+					fetchErr <- err
+				}
+				return
+			}
+			select {
+			case fetched <- msg:
+			case <-fetchCtx.Done():
+				return
+			}
 		}
+	}()
 
-		if c.handler != nil {
-			if err := c.handler(ctx, payload); err != nil {
-				log.Printf("mq: handler error for topic %s: %v", msg.Topic, err)
+	tracker := newOffsetTracker()
+	done := ctx.Done()
+	inFlight := 0
+	var (
+		runErr     error
+		drainTimer *time.Timer
+	)
+	for fetched != nil || inFlight > 0 {
+		select {
+		case msg, ok := <-fetched:
+			if !ok {
+				fetched = nil
+				continue
+			}
+			tracker.track(msg)
+			inFlight++
+			lanes[c.laneFor(msg)] <- msg
+		case res := <-results:
+			inFlight--
+			<-slots
+			if res.err != nil {
+				if runErr == nil {
+					runErr = res.err
+				}
+				stopFetching()
+				continue
 			}
+			if commit, ok := tracker.complete(res.msg); ok {
+				if err := c.commit(ctx, commit); err != nil && runErr == nil {
+					runErr = err
+					stopFetching()
+				}
+			}
+		case <-done:
+			done = nil
+			stopFetching()
+			drainTimer = time.AfterFunc(c.cfg.DrainTimeout, cancelHandlers)
 		}
 	}
+	if drainTimer != nil {
+		drainTimer.Stop()
+	}
+
+	if runErr != nil {
+		return runErr
+	}
+	select {
+	case err := <-fetchErr:
+		return err
+	default:
+	}
+	return ctx.Err()
+}
+
+type processed struct {
+	msg kafka.Message
+	err error
+}
+
+// laneFor maps a message to a handler lane, keeping messages with the same key together.
+func (c *Consumer) laneFor(msg kafka.Message) int {
+	if c.cfg.Concurrency <= 1 {
+		return 0
+	}
+	hash := fnv.New32a()
+	if len(msg.Key) > 0 {
+		hash.Write(msg.Key)
+	} else {
+		hash.Write([]byte(strconv.Itoa(msg.Partition)))
+	}
+	return int(hash.Sum32() % uint32(c.cfg.Concurrency))
+}
+
+func (c *Consumer) commit(ctx context.Context, msg kafka.Message) error {
+	commitCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+	defer cancel()
+	if err := c.reader.CommitMessages(commitCtx, msg); err != nil {
+		return fmt.Errorf("mq: commit offset %d on %s/%d: %w", msg.Offset, msg.Topic, msg.Partition, err)
+	}
+	return nil
+}
+
+// offsetTracker releases offsets for commit in partition order, so a message is never
+// committed while an earlier message of the same partition is still being handled.
+type offsetTracker struct {
+	partitions map[string]*partitionOffsets
+}
+
+type partitionOffsets struct {
+	pending []int64
+	done    map[int64]kafka.Message
+}
+
+func newOffsetTracker() *offsetTracker {
+	return &offsetTracker{partitions: make(map[string]*partitionOffsets)}
 }
 
-// Close shuts down the reader.
+func (t *offsetTracker) partition(msg kafka.Message) *partitionOffsets {
+	key := msg.Topic + "/" + strconv.Itoa(msg.Partition)
+	p, ok := t.partitions[key]
+	if !ok {
+		p = &partitionOffsets{done: make(map[int64]kafka.Message)}
+		t.partitions[key] = p
+	}
+	return p
+}
+
+func (t *offsetTracker) track(msg kafka.Message) {
+	p := t.partition(msg)
+	p.pending = append(p.pending, msg.Offset)
+}
+
+// complete marks msg as handled and returns the highest message of its partition that
+// can now be committed, if any.
+func (t *offsetTracker) complete(msg kafka.Message) (kafka.Message, bool) {
+	p := t.partition(msg)
+	p.done[msg.Offset] = msg
+
+	var last kafka.Message
+	ready := false
+	for len(p.pending) > 0 {
+		next, ok := p.done[p.pending[0]]
+		if !ok {
+			break
+		}
+		delete(p.done, p.pending[0])
+		p.pending = p.pending[1:]
+		last = next
+		ready = true
+	}
+	return last, ready
+}
+
+// process hands a message to the handler, retrying with backoff and dead-lettering it
+// once the attempts are exhausted. A nil result means the offset may be committed.
+func (c *Consumer) process(ctx context.Context, msg kafka.Message) error {
+	payload := toMessage(msg)
+
+	var lastErr error
+	for attempt := 1; attempt <= c.cfg.MaxAttempts; attempt++ {
+		if c.handler == nil {
+			return nil
+		}
+		lastErr = c.handler(ctx, payload)
+		if lastErr == nil {
+			return nil
+		}
+
+		log.Printf("mq: handler error for topic %s offset %d (attempt %d/%d): %v", msg.Topic, msg.Offset, attempt, c.cfg.MaxAttempts, lastErr)
+		if attempt == c.cfg.MaxAttempts {
+			break
+		}
+
+		timer := time.NewTimer(c.cfg.backoff(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+
+	return c.deadLetterMessage(ctx, msg, lastErr)
+}
+
+func (c *Consumer) deadLetterMessage(ctx context.Context, msg kafka.Message, cause error) error {
+	if c.deadLetter == nil {
+		log.Printf("mq: dropping message from topic %s offset %d after %d attempts: %v", msg.Topic, msg.Offset, c.cfg.MaxAttempts, cause)
+		return nil
+	}
+
+	dead := kafka.Message{
+		Key:   msg.Key,
+		Value: msg.Value,
+	}
+	for _, header := range msg.Headers {
+		if isDeadLetterHeader(header.Key) {
+			continue
+		}
+		dead.Headers = append(dead.Headers, header)
+	}
+	dead.Headers = append(dead.Headers,
+		kafka.Header{Key: HeaderDeadLetterError, Value: []byte(cause.Error())},
+		kafka.Header{Key: HeaderDeadLetterAttempts, Value: []byte(strconv.Itoa(c.cfg.MaxAttempts))},
+		kafka.Header{Key: HeaderDeadLetterTopic, Value: []byte(msg.Topic)},
+		kafka.Header{Key: HeaderDeadLetterPartition, Value: []byte(strconv.Itoa(msg.Partition))},
+		kafka.Header{Key: HeaderDeadLetterOffset, Value: []byte(strconv.FormatInt(msg.Offset, 10))},
+		kafka.Header{Key: HeaderDeadLetterFailedAt, Value: []byte(time.Now().UTC().Format(time.RFC3339Nano))},
+	)
+
+	if err := c.deadLetter.WriteMessages(ctx, dead); err != nil {
+		return fmt.Errorf("mq: dead-letter offset %d on %s/%d: %w", msg.Offset, msg.Topic, msg.Partition, err)
+	}
+	log.Printf("mq: dead-lettered message from topic %s offset %d to %s", msg.Topic, msg.Offset, c.cfg.DeadLetterTopic)
+	return nil
+}
+
+// Close shuts down the reader and the dead-letter writer.
 func (c *Consumer) Close() error {
 	if c == nil || c.reader == nil {
 		return nil
 	}
+	if c.deadLetter != nil {
+		if err := c.deadLetter.Close(); err != nil {
+			log.Printf("mq: failed to close dead-letter writer: %v", err)
+		}
+	}
 	return c.reader.Close()
 }
+
+func toMessage(msg kafka.Message) Message {
+	payload := Message{
+		Key:       msg.Key,
+		Value:     msg.Value,
+		Headers:   make(map[string]string, len(msg.Headers)),
+		Time:      msg.Time,
+		Topic:     msg.Topic,
+		Partition: msg.Partition,
+		Offset:    msg.Offset,
+	}
+	for _, header := range msg.Headers {
+		payload.Headers[header.Key] = string(header.Value)
+	}
+	return payload
+}
+
+func isDeadLetterHeader(key string) bool {
+	switch key {
+	case HeaderDeadLetterError, HeaderDeadLetterAttempts, HeaderDeadLetterTopic,
+		HeaderDeadLetterPartition, HeaderDeadLetterOffset, HeaderDeadLetterFailedAt:
+		return true
+	}
+	return false
+}
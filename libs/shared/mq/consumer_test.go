@@ -0,0 +1,232 @@
+package mq
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+type fakeReader struct {
+	messages  []kafka.Message
+	committed []int64
+}
+
+func (r *fakeReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	if len(r.messages) == 0 {
+		<-ctx.Done()
+		return kafka.Message{}, ctx.Err()
+	}
+	msg := r.messages[0]
+	r.messages = r.messages[1:]
+	return msg, nil
+}
+
+func (r *fakeReader) CommitMessages(ctx context.Context, msgs ...kafka.Message) error {
+	for _, msg := range msgs {
+		r.committed = append(r.committed, msg.Offset)
+	}
+	return nil
+}
+
+func (r *fakeReader) Close() error { return nil }
+
+type fakeWriter struct {
+	written []kafka.Message
+	err     error
+}
+
+func (w *fakeWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	if w.err != nil {
+		return w.err
+	}
+	w.written = append(w.written, msgs...)
+	return nil
+}
+
+func (w *fakeWriter) Close() error { return nil }
+
+func headerValue(msg kafka.Message, key string) string {
+	for _, header := range msg.Headers {
+		if header.Key == key {
+			return string(header.Value)
+		}
+	}
+	return ""
+}
+
+func TestConsumerRetriesThenDeadLetters(t *testing.T) {
+	reader := &fakeReader{messages: []kafka.Message{
+		{Topic: "tickets", Offset: 1, Key: []byte("ok"), Value: []byte("1")},
+		{Topic: "tickets", Offset: 2, Key: []byte("bad"), Value: []byte("2"), Headers: []kafka.Header{{Key: "trace", Value: []byte("abc")}}},
+	}}
+	writer := &fakeWriter{}
+	attempts := map[string]int{}
+
+	consumer := &Consumer{
+		reader:     reader,
+		deadLetter: writer,
+		cfg: ConsumerConfig{
+			Topic:           "tickets",
+			DeadLetterTopic: "tickets.dlq",
+			MaxAttempts:     3,
+			InitialBackoff:  time.Millisecond,
+			MaxBackoff:      time.Millisecond,
+		}.normalize(),
+		handler: func(ctx context.Context, msg Message) error {
+			attempts[string(msg.Key)]++
+			if string(msg.Key) == "bad" {
+				return errors.New("boom")
+			}
+			return nil
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	if err := consumer.Run(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected run to stop on context deadline, got %v", err)
+	}
+
+	if attempts["ok"] != 1 || attempts["bad"] != 3 {
+		t.Fatalf("unexpected attempts: %v", attempts)
+	}
+	if len(reader.committed) != 2 {
+		t.Fatalf("expected both offsets to be committed, got %v", reader.committed)
+	}
+	if len(writer.written) != 1 {
+		t.Fatalf("expected one dead-lettered message, got %d", len(writer.written))
+	}
+
+	dead := writer.written[0]
+	if string(dead.Value) != "2" || headerValue(dead, "trace") != "abc" {
+		t.Fatalf("dead letter lost original content: %+v", dead)
+	}
+	if headerValue(dead, HeaderDeadLetterError) != "boom" || headerValue(dead, HeaderDeadLetterAttempts) != "3" || headerValue(dead, HeaderDeadLetterTopic) != "tickets" {
+		t.Fatalf("unexpected dead-letter headers: %+v", dead.Headers)
+	}
+}
+
+func TestConsumerDoesNotCommitWhenDeadLetterFails(t *testing.T) {
+	reader := &fakeReader{messages: []kafka.Message{{Topic: "tickets", Offset: 7}}}
+	consumer := &Consumer{
+		reader:     reader,
+		deadLetter: &fakeWriter{err: errors.New("broker down")},
+		cfg:        ConsumerConfig{Topic: "tickets", MaxAttempts: 1}.normalize(),
+		handler: func(ctx context.Context, msg Message) error {
+			return errors.New("boom")
+		},
+	}
+
+	if err := consumer.Run(context.Background()); err == nil {
+		t.Fatalf("expected run to fail when the dead-letter write fails")
+	}
+	if len(reader.committed) != 0 {
+		t.Fatalf("expected no commit, got %v", reader.committed)
+	}
+}
+
+func TestReplayRestoresOriginalTopic(t *testing.T) {
+	reader := &fakeReader{messages: []kafka.Message{{
+		Offset: 3,
+		Value:  []byte("payload"),
+		Headers: []kafka.Header{
+			{Key: "trace", Value: []byte("abc")},
+			{Key: HeaderDeadLetterTopic, Value: []byte("tickets")},
+			{Key: HeaderDeadLetterError, Value: []byte("boom")},
+		},
+	}}}
+	writer := &fakeWriter{}
+
+	replayed, err := replay(context.Background(), reader, writer, ReplayConfig{IdleTimeout: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if replayed != 1 || len(reader.committed) != 1 {
+		t.Fatalf("expected one replayed and committed message, got %d/%v", replayed, reader.committed)
+	}
+
+	out := writer.written[0]
+	if out.Topic != "tickets" || headerValue(out, "trace") != "abc" || headerValue(out, HeaderDeadLetterError) != "" {
+		t.Fatalf("unexpected replayed message: %+v", out)
+	}
+}
+
+func TestConsumerPreservesKeyOrderAndCommitsInOffsetOrder(t *testing.T) {
+	var messages []kafka.Message
+	for i := 0; i < 12; i++ {
+		key := []string{"a", "b", "c"}[i%3]
+		messages = append(messages, kafka.Message{Topic: "tickets", Offset: int64(i), Key: []byte(key), Value: []byte{byte(i)}})
+	}
+	reader := &fakeReader{messages: messages}
+
+	var mu sync.Mutex
+	seen := map[string][]int64{}
+	consumer := &Consumer{
+		reader: reader,
+		cfg:    ConsumerConfig{Topic: "tickets", Concurrency: 3, MaxInFlight: 6}.normalize(),
+		handler: func(ctx context.Context, msg Message) error {
+			if string(msg.Key) == "a" {
+				time.Sleep(5 * time.Millisecond)
+			}
+			mu.Lock()
+			seen[string(msg.Key)] = append(seen[string(msg.Key)], msg.Offset)
+			mu.Unlock()
+			return nil
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	_ = consumer.Run(ctx)
+
+	for key, offsets := range seen {
+		for i := 1; i < len(offsets); i++ {
+			if offsets[i] < offsets[i-1] {
+				t.Fatalf("key %s handled out of order: %v", key, offsets)
+			}
+		}
+	}
+	for i := 1; i < len(reader.committed); i++ {
+		if reader.committed[i] <= reader.committed[i-1] {
+			t.Fatalf("offsets committed out of order: %v", reader.committed)
+		}
+	}
+	if last := reader.committed[len(reader.committed)-1]; last != 11 {
+		t.Fatalf("expected final commit at offset 11, got %d", last)
+	}
+}
+
+func TestConsumerDrainsInFlightMessagesOnShutdown(t *testing.T) {
+	reader := &fakeReader{messages: []kafka.Message{{Topic: "tickets", Offset: 4, Key: []byte("k")}}}
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	consumer := &Consumer{
+		reader: reader,
+		cfg:    ConsumerConfig{Topic: "tickets", Concurrency: 2, DrainTimeout: time.Second}.normalize(),
+		handler: func(ctx context.Context, msg Message) error {
+			close(started)
+			<-release
+			return ctx.Err()
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+	go func() { errs <- consumer.Run(ctx) }()
+
+	<-started
+	cancel()
+	close(release)
+
+	if err := <-errs; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if len(reader.committed) != 1 || reader.committed[0] != 4 {
+		t.Fatalf("expected drained message to be committed, got %v", reader.committed)
+	}
+}
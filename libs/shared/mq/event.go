@@ -0,0 +1,103 @@
+package mq
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/pflow/shared/tenant"
+)
+
+const (
+	// EventSpecVersion is the version of the event envelope. It follows the
+	// CloudEvents specification of the same version.
+	EventSpecVersion = "1.0"
+	// EventContentType is the Kafka content-type header of published events,
+	// marking them as structured CloudEvents.
+	EventContentType = "application/cloudevents+json"
+)
+
+// Event is the envelope of every domain event published to the event topic.
+// Type names the change, e.g. "ticket.resolved"; Subject is the ID of the
+// changed entity and the key events are partitioned by, so the events of one
+// entity stay in order. TenantID is an extension attribute.
+type Event struct {
+	SpecVersion     string          `json:"specversion"`
+	ID              string          `json:"id"`
+	Type            string          `json:"type"`
+	Source          string          `json:"source"`
+	Subject         string          `json:"subject,omitempty"`
+	Time            time.Time       `json:"time"`
+	DataContentType string          `json:"datacontenttype"`
+	TenantID        string          `json:"tenantid,omitempty"`
+	Data            json.RawMessage `json:"data"`
+}
+
+// Publisher sends raw messages to a topic. *Producer satisfies it.
+type Publisher interface {
+	Publish(ctx context.Context, key string, value []byte, headers map[string]string) error
+}
+
+type fanOut []Publisher
+
+// FanOut returns a Publisher sending every message through each of publishers
+// in turn, skipping nil ones. It fails when any of them fails.
+func FanOut(publishers ...Publisher) Publisher {
+	var targets fanOut
+	for _, publisher := range publishers {
+		if publisher != nil {
+			targets = append(targets, publisher)
+		}
+	}
+	return targets
+}
+
+func (f fanOut) Publish(ctx context.Context, key string, value []byte, headers map[string]string) error {
+	var errs []error
+	for _, publisher := range f {
+		if err := publisher.Publish(ctx, key, value, headers); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// NewEvent builds an event about subject in the tenant of ctx with data
+// encoded as JSON.
+func NewEvent(ctx context.Context, source, eventType, subject string, data any) (Event, error) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return Event{}, err
+	}
+	return Event{
+		SpecVersion:     EventSpecVersion,
+		ID:              uuid.NewString(),
+		Type:            eventType,
+		Source:          source,
+		Subject:         subject,
+		Time:            time.Now().UTC(),
+		DataContentType: "application/json",
+		TenantID:        tenant.ID(ctx),
+		Data:            payload,
+	}, nil
+}
+
+// PublishEvent sends event through publisher, keyed by its subject.
+func PublishEvent(ctx context.Context, publisher Publisher, event Event) error {
+	value, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	key := event.Subject
+	if key == "" {
+		key = event.ID
+	}
+	return publisher.Publish(ctx, key, value, map[string]string{
+		"content-type": EventContentType,
+		"event_type":   event.Type,
+		"tenant_id":    event.TenantID,
+	})
+}
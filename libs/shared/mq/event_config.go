@@ -0,0 +1,29 @@
+package mq
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pflow/shared/config"
+)
+
+// EventProducerFromConfig builds the producer service publishes its domain
+// events with, writing to KAFKA_TOPIC on the brokers of the service. Without a
+// topic or brokers it returns nil and the service does not publish events.
+func EventProducerFromConfig(cfg *config.AppConfig, service string) (*Producer, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+	topic := strings.TrimSpace(cfg.KafkaTopic)
+	brokers := cfg.KafkaBrokerList(service)
+	if topic == "" || len(brokers) == 0 {
+		return nil, nil
+	}
+	return NewProducer(ProducerConfig{
+		Brokers:  brokers,
+		Topic:    topic,
+		ClientID: fmt.Sprintf("%s-%s-events", cfg.ServiceName, service),
+		Timeout:  2 * time.Second,
+	})
+}
@@ -0,0 +1,74 @@
+package mq
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"github.com/pflow/shared/tenant"
+)
+
+type recordedMessage struct {
+	key     string
+	value   []byte
+	headers map[string]string
+}
+
+type recordingPublisher struct {
+	mu       sync.Mutex
+	messages []recordedMessage
+}
+
+func (p *recordingPublisher) Publish(ctx context.Context, key string, value []byte, headers map[string]string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.messages = append(p.messages, recordedMessage{key: key, value: value, headers: headers})
+	return nil
+}
+
+func TestPublishEventSendsEnvelopesKeyedBySubject(t *testing.T) {
+	publisher := &recordingPublisher{}
+	ctx := tenant.WithID(context.Background(), "acme")
+
+	for _, change := range []struct {
+		eventType string
+		data      any
+	}{
+		{"ticket.created", map[string]any{"title": "Printer on fire"}},
+		{"ticket.resolved", map[string]any{"status": "resolved"}},
+	} {
+		event, err := NewEvent(ctx, "/pflow/ticket", change.eventType, "t-1", change.data)
+		if err != nil {
+			t.Fatalf("new event: %v", err)
+		}
+		if err := PublishEvent(ctx, publisher, event); err != nil {
+			t.Fatalf("publish: %v", err)
+		}
+	}
+
+	if len(publisher.messages) != 2 {
+		t.Fatalf("expected 2 published events, got %d", len(publisher.messages))
+	}
+	for i, wantType := range []string{"ticket.created", "ticket.resolved"} {
+		msg := publisher.messages[i]
+		var event Event
+		if err := json.Unmarshal(msg.value, &event); err != nil {
+			t.Fatalf("decode event: %v", err)
+		}
+		if event.Type != wantType || event.SpecVersion != EventSpecVersion || event.Source != "/pflow/ticket" ||
+			event.Subject != "t-1" || event.TenantID != "acme" || event.ID == "" || event.Time.IsZero() {
+			t.Fatalf("unexpected envelope: %+v", event)
+		}
+		if msg.key != "t-1" || msg.headers["content-type"] != EventContentType || msg.headers["event_type"] != wantType || msg.headers["tenant_id"] != "acme" {
+			t.Fatalf("unexpected message metadata: key=%s headers=%v", msg.key, msg.headers)
+		}
+	}
+
+	var data map[string]any
+	var first Event
+	json.Unmarshal(publisher.messages[0].value, &first)
+	if err := json.Unmarshal(first.Data, &data); err != nil || data["title"] != "Printer on fire" {
+		t.Fatalf("unexpected data: %s", first.Data)
+	}
+}
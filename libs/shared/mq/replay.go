@@ -0,0 +1,138 @@
+package mq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// ReplayConfig describes how dead-lettered messages are pushed back for reprocessing.
+type ReplayConfig struct {
+	Brokers         []string
+	DeadLetterTopic string
+	// Topic overrides the destination; by default each message returns to the topic
+	// recorded in its x-dlq-original-topic header.
+	Topic    string
+	GroupID  string
+	ClientID string
+	// Limit caps the number of replayed messages; values <= 0 replay everything.
+	Limit int
+	// IdleTimeout ends the replay once no message arrived for this long.
+	IdleTimeout time.Duration
+}
+
+func (cfg ReplayConfig) normalize() ReplayConfig {
+	normalized := cfg
+	normalized.DeadLetterTopic = strings.TrimSpace(normalized.DeadLetterTopic)
+	normalized.Topic = strings.TrimSpace(normalized.Topic)
+	normalized.GroupID = strings.TrimSpace(normalized.GroupID)
+	normalized.ClientID = strings.TrimSpace(normalized.ClientID)
+	if normalized.GroupID == "" {
+		normalized.GroupID = normalized.DeadLetterTopic + "-replay"
+	}
+	if normalized.IdleTimeout <= 0 {
+		normalized.IdleTimeout = 5 * time.Second
+	}
+	brokers := make([]string, 0, len(normalized.Brokers))
+	for _, broker := range normalized.Brokers {
+		broker = strings.TrimSpace(broker)
+		if broker != "" {
+			brokers = append(brokers, broker)
+		}
+	}
+	normalized.Brokers = brokers
+	return normalized
+}
+
+// Validate ensures the replay configuration is usable.
+func (cfg ReplayConfig) Validate() error {
+	if len(cfg.Brokers) == 0 {
+		return errors.New("mq: at least one broker must be configured")
+	}
+	if strings.TrimSpace(cfg.DeadLetterTopic) == "" {
+		return errors.New("mq: dead-letter topic must be provided")
+	}
+	return nil
+}
+
+// ReplayDeadLetters moves messages from a dead-letter topic back onto their original
+// topic with the dead-letter headers stripped, committing each one after it was
+// re-published. It returns the number of replayed messages.
+func ReplayDeadLetters(ctx context.Context, cfg ReplayConfig) (int, error) {
+	normalized := cfg.normalize()
+	if err := normalized.Validate(); err != nil {
+		return 0, err
+	}
+
+	readerCfg := kafka.ReaderConfig{
+		Brokers: normalized.Brokers,
+		Topic:   normalized.DeadLetterTopic,
+		GroupID: normalized.GroupID,
+	}
+	writer := &kafka.Writer{
+		Addr:                   kafka.TCP(normalized.Brokers...),
+		AllowAutoTopicCreation: true,
+		RequiredAcks:           kafka.RequireAll,
+		BatchSize:              1,
+	}
+	if normalized.ClientID != "" {
+		readerCfg.Dialer = &kafka.Dialer{ClientID: normalized.ClientID}
+		writer.Transport = &kafka.Transport{ClientID: normalized.ClientID}
+	}
+
+	reader := kafka.NewReader(readerCfg)
+	defer reader.Close()
+	defer writer.Close()
+
+	return replay(ctx, reader, writer, normalized)
+}
+
+func replay(ctx context.Context, reader messageReader, writer messageWriter, cfg ReplayConfig) (int, error) {
+	replayed := 0
+	for cfg.Limit <= 0 || replayed < cfg.Limit {
+		fetchCtx, cancel := context.WithTimeout(ctx, cfg.IdleTimeout)
+		msg, err := reader.FetchMessage(fetchCtx)
+		cancel()
+		if err != nil {
+			if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+				return replayed, nil
+			}
+			return replayed, err
+		}
+
+		target := cfg.Topic
+		if target == "" {
+			for _, header := range msg.Headers {
+				if header.Key == HeaderDeadLetterTopic {
+					target = string(header.Value)
+				}
+			}
+		}
+		if target == "" {
+			return replayed, fmt.Errorf("mq: dead-letter offset %d has no original topic; set a replay topic", msg.Offset)
+		}
+
+		out := kafka.Message{Topic: target, Key: msg.Key, Value: msg.Value}
+		for _, header := range msg.Headers {
+			if !isDeadLetterHeader(header.Key) {
+				out.Headers = append(out.Headers, header)
+			}
+		}
+
+		if err := writer.WriteMessages(ctx, out); err != nil {
+			return replayed, fmt.Errorf("mq: replay offset %d to %s: %w", msg.Offset, target, err)
+		}
+		if err := reader.CommitMessages(ctx, msg); err != nil {
+			return replayed, fmt.Errorf("mq: commit dead-letter offset %d: %w", msg.Offset, err)
+		}
+		replayed++
+	}
+
+	log.Printf("mq: replay limit of %d messages reached", cfg.Limit)
+	return replayed, nil
+}
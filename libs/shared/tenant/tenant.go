@@ -0,0 +1,99 @@
+// Package tenant resolves the tenant a request acts on and carries it through
+// request contexts so repositories can scope every query to it.
+package tenant
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/pflow/shared/auth"
+	"github.com/pflow/shared/httpx"
+)
+
+const (
+	// Header selects the tenant for callers whose principal carries none.
+	Header = "X-Tenant-ID"
+	// Default is the tenant of requests that name none, and of all rows that
+	// existed before tenants were introduced.
+	Default = "default"
+)
+
+var (
+	// ErrMismatch is returned when the header names another tenant than the
+	// authenticated principal belongs to.
+	ErrMismatch = errors.New("tenant does not match the authenticated principal")
+	// ErrNoTenant is returned when an authenticated principal carries no
+	// tenant and may not select one.
+	ErrNoTenant = errors.New("the authenticated principal belongs to no tenant")
+	// ErrInvalid is returned for malformed tenant IDs.
+	ErrInvalid = errors.New("tenant id must be 1-64 letters, digits, '-' or '_'")
+)
+
+var validID = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+type tenantKey struct{}
+
+// WithID returns a copy of ctx acting on the given tenant.
+func WithID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, id)
+}
+
+// FromContext returns the tenant stored by Middleware or WithID, if any.
+func FromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(tenantKey{}).(string)
+	return id, ok && id != ""
+}
+
+// ID returns the tenant of ctx, falling back to Default.
+func ID(ctx context.Context) string {
+	if id, ok := FromContext(ctx); ok {
+		return id
+	}
+	return Default
+}
+
+// Resolve determines the tenant of a request: the tenant of the authenticated
+// principal wins and the header may only repeat it. An authenticated
+// principal without a tenant needs auth.PermissionTenantSelect and then
+// chooses one with the header; unauthenticated callers choose one with it too.
+func Resolve(r *http.Request) (string, error) {
+	requested := strings.TrimSpace(r.Header.Get(Header))
+	if requested != "" && !validID.MatchString(requested) {
+		return "", ErrInvalid
+	}
+	if principal, ok := auth.FromContext(r.Context()); ok {
+		if principal.TenantID != "" {
+			if requested != "" && requested != principal.TenantID {
+				return "", ErrMismatch
+			}
+			return principal.TenantID, nil
+		}
+		if !principal.Can(auth.PermissionTenantSelect) {
+			return "", ErrNoTenant
+		}
+	}
+	if requested != "" {
+		return requested, nil
+	}
+	return Default, nil
+}
+
+// Middleware resolves the tenant of each request and stores it in the request
+// context. It must run after the authentication middleware.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := Resolve(r)
+		switch {
+		case errors.Is(err, ErrMismatch), errors.Is(err, ErrNoTenant):
+			httpx.Error(w, http.StatusForbidden, err.Error())
+			return
+		case err != nil:
+			httpx.Error(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(WithID(r.Context(), id)))
+	})
+}
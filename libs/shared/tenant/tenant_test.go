@@ -0,0 +1,55 @@
+package tenant
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pflow/shared/auth"
+)
+
+func TestMiddlewareResolvesTenant(t *testing.T) {
+	cases := []struct {
+		name      string
+		principal *auth.Principal
+		header    string
+		status    int
+		tenant    string
+	}{
+		{name: "default", status: http.StatusOK, tenant: Default},
+		{name: "header", header: "acme", status: http.StatusOK, tenant: "acme"},
+		{name: "principal", principal: &auth.Principal{UserID: "u1", TenantID: "acme"}, status: http.StatusOK, tenant: "acme"},
+		{name: "principal repeated in header", principal: &auth.Principal{UserID: "u1", TenantID: "acme"}, header: "acme", status: http.StatusOK, tenant: "acme"},
+		{name: "principal without tenant", principal: &auth.Principal{UserID: "u1"}, header: "globex", status: http.StatusForbidden},
+		{name: "principal without tenant or header", principal: &auth.Principal{UserID: "u1"}, status: http.StatusForbidden},
+		{name: "platform principal", principal: &auth.Principal{UserID: "u1", Permissions: []string{auth.PermissionTenantSelect}}, header: "globex", status: http.StatusOK, tenant: "globex"},
+		{name: "mismatch", principal: &auth.Principal{UserID: "u1", TenantID: "acme"}, header: "globex", status: http.StatusForbidden},
+		{name: "invalid", header: "acme corp", status: http.StatusBadRequest},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var got string
+			handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = ID(r.Context())
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/tickets", nil)
+			if tc.header != "" {
+				req.Header.Set(Header, tc.header)
+			}
+			if tc.principal != nil {
+				req = req.WithContext(auth.WithPrincipal(req.Context(), *tc.principal))
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tc.status {
+				t.Fatalf("expected status %d, got %d", tc.status, rec.Code)
+			}
+			if got != tc.tenant {
+				t.Fatalf("expected tenant %q, got %q", tc.tenant, got)
+			}
+		})
+	}
+}
@@ -1,15 +1,21 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
 
 	formcmp "github.com/pflow/components/form"
+	"github.com/pflow/components/outbox"
 
+	"github.com/pflow/shared/audit"
+	"github.com/pflow/shared/auth"
 	"github.com/pflow/shared/config"
 	"github.com/pflow/shared/database"
 	"github.com/pflow/shared/httpx"
+	"github.com/pflow/shared/mq"
+	"github.com/pflow/shared/tenant"
 )
 
 func main() {
@@ -17,14 +23,73 @@ func main() {
 	dsn := cfg.DatabaseDSN("form")
 	db := database.ConnectWithDSN("form", dsn)
 
-	if err := db.AutoMigrate(&formcmp.Form{}); err != nil {
+	if err := formcmp.Migrate(db); err != nil {
 		log.Fatalf("form service: failed to run migrations: %v", err)
 	}
 
+	// Events are recorded in the outbox in the transaction of each change and
+	// relayed to KAFKA_TOPIC; without a topic or brokers none are recorded.
+	producer, err := mq.EventProducerFromConfig(cfg, "form")
+	if err != nil {
+		log.Fatalf("form service: failed to configure event publishing: %v", err)
+	}
+	var events *outbox.EventStore
+	if producer != nil {
+		defer producer.Close(context.Background())
+		events = formcmp.NewEventOutbox(db)
+
+		messages := outbox.NewRepository(db)
+		relay := outbox.NewRelay(messages, outbox.Routes{outbox.Events: producer}, outbox.RelayConfig{})
+		go func() {
+			if err := relay.Run(context.Background()); err != nil {
+				log.Printf("form service: outbox relay stopped: %v", err)
+			}
+		}()
+
+		// Sent events are only kept for OUTBOX_RETENTION.
+		outboxPurger := database.NewPurger("form outbox", messages.PurgeSent, database.PurgerConfig{Retention: cfg.OutboxRetention})
+		go func() {
+			if err := outboxPurger.Run(context.Background()); err != nil {
+				log.Printf("form service: outbox purger stopped: %v", err)
+			}
+		}()
+	}
+
+	auditLog, err := audit.RepositoryFromConfig(cfg)
+	if err != nil {
+		log.Fatalf("form service: failed to configure the audit log: %v", err)
+	}
+
+	authn, err := auth.ServiceMiddleware(context.Background(), cfg)
+	if err != nil {
+		log.Fatalf("form service: failed to configure authentication: %v", err)
+	}
+
+	options := []formcmp.HandlerOption{
+		formcmp.WithEvents(events),
+		formcmp.WithAudit(audit.NewRecorder(auditLog, "form")),
+	}
+	if authn != nil {
+		options = append(options, formcmp.WithAuthorization())
+	}
+	// Forms that open tickets were submitted through are only deleted when
+	// forced; the ticket service counts them when it is configured.
+	if config.IsEnvSet("TICKET_SERVICE_URL") {
+		references := formcmp.NewRemoteReferences(cfg.TicketServiceURL, nil, []byte(cfg.AuthForwardSecret))
+		options = append(options, formcmp.WithReferences(references, cfg.FormReferencesAllowOnError))
+	}
 	repository := formcmp.NewGormRepository(db)
-	handler := formcmp.NewHandler(repository)
+	handler := formcmp.NewHandler(repository, options...)
+
+	// Deleted forms stay restorable for SOFT_DELETE_RETENTION_DAYS.
+	purger := database.NewPurger("form", repository.PurgeDeleted, database.PurgerConfig{Retention: cfg.SoftDeleteRetention()})
+	go func() {
+		if err := purger.Run(context.Background()); err != nil {
+			log.Printf("form service: purger stopped: %v", err)
+		}
+	}()
 
-	server := httpx.New()
+	server := httpx.New(httpx.WithMiddleware(authn, tenant.Middleware, audit.Middleware))
 	handler.Mount(server.Router, "")
 
 	port := cfg.ResolveServiceHTTPPort("form", "8081")
@@ -19,7 +19,13 @@ func main() {
 		log.Fatalf("failed to load configuration: %v", err)
 	}
 
-	srv := server.New(cfg)
+	srv, err := server.New(cfg)
+	if err != nil {
+		log.Fatalf("failed to configure authentication: %v", err)
+	}
+	if cfg.AuthDisabled {
+		log.Println("authentication is disabled; every API route is open")
+	}
 
 	go func() {
 		log.Printf("gateway listening on %s", srv.Addr)
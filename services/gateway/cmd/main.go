@@ -9,16 +9,23 @@ import (
 	"log"
 	"net"
 	"net/http"
+	"net/url"
 	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
 
+	"github.com/pflow/shared/auth"
 	"github.com/pflow/shared/config"
 	"github.com/pflow/shared/httpx"
 	"github.com/pflow/shared/observability"
+	"github.com/pflow/shared/tenant"
 )
 
+// publicAuthPaths are the login and token routes reachable without an access token.
+var publicAuthPaths = []string{"/api/auth/login", "/api/auth/refresh", "/api/auth/logout", "/api/auth/password-reset"}
+
 type gateway struct {
 	client       *http.Client
 	serviceName  string
@@ -26,6 +33,9 @@ type gateway struct {
 	identityBase string
 	ticketBase   string
 	workflowBase string
+
+	// forwardSecret signs the principal headers sent to upstream services.
+	forwardSecret []byte
 }
 
 func newGateway(cfg *config.AppConfig) *gateway {
@@ -36,6 +46,8 @@ func newGateway(cfg *config.AppConfig) *gateway {
 		identityBase: trimTrailingSlash(cfg.IdentityServiceURL),
 		ticketBase:   trimTrailingSlash(cfg.TicketServiceURL),
 		workflowBase: trimTrailingSlash(cfg.WorkflowServiceURL),
+
+		forwardSecret: []byte(cfg.AuthForwardSecret),
 	}
 }
 
@@ -50,7 +62,25 @@ func main() {
 		httpx.JSON(w, http.StatusOK, map[string]any{"status": "ok", "service": gw.serviceName})
 	})
 
-	server.Router.Route("/api", gw.registerRoutes)
+	verifier, err := auth.FromConfig(context.Background(), cfg)
+	if err != nil {
+		log.Fatalf("gateway: failed to configure authentication: %v", err)
+	}
+	switch {
+	case verifier != nil:
+	case cfg.AuthDisabled:
+		log.Printf("gateway: authentication is disabled; every API route is open")
+	default:
+		log.Fatalf("gateway: AUTH_JWT_SECRET or AUTH_JWKS is required (set AUTH_DISABLED=true to run without authentication)")
+	}
+
+	server.Router.Route("/api", func(api chi.Router) {
+		if verifier != nil {
+			api.Use(auth.Middleware(verifier, auth.ForwardSigned(gw.forwardSecret), auth.PublicPaths(publicAuthPaths...)))
+		}
+		api.Use(tenant.Middleware)
+		gw.registerRoutes(api)
+	})
 
 	port := cfg.ResolveServiceHTTPPort("gateway", "8080")
 	addr := fmt.Sprintf(":%s", port)
@@ -77,6 +107,15 @@ func (g *gateway) registerRoutes(router chi.Router) {
 	router.Delete("/forms/{id}", g.proxy(http.MethodDelete, func(r *http.Request) string {
 		return g.formBase + "/forms/" + chi.URLParam(r, "id")
 	}))
+	router.Post("/forms/{id}/restore", g.proxy(http.MethodPost, func(r *http.Request) string {
+		return g.formBase + "/forms/" + chi.URLParam(r, "id") + "/restore"
+	}))
+	router.Get("/forms/{id}/versions", g.proxy(http.MethodGet, func(r *http.Request) string {
+		return g.formBase + "/forms/" + chi.URLParam(r, "id") + "/versions"
+	}))
+	router.Get("/forms/{id}/versions/{version}", g.proxy(http.MethodGet, func(r *http.Request) string {
+		return g.formBase + "/forms/" + chi.URLParam(r, "id") + "/versions/" + chi.URLParam(r, "version")
+	}))
 
 	router.Get("/tickets", g.proxy(http.MethodGet, func(r *http.Request) string {
 		return g.ticketBase + "/tickets"
@@ -84,15 +123,81 @@ func (g *gateway) registerRoutes(router chi.Router) {
 	router.Post("/tickets", g.proxy(http.MethodPost, func(r *http.Request) string {
 		return g.ticketBase + "/tickets"
 	}))
+	router.Get("/tickets/submissions", g.proxy(http.MethodGet, func(r *http.Request) string {
+		return g.ticketBase + "/tickets/submissions"
+	}))
 	router.Post("/tickets/submissions", g.proxy(http.MethodPost, func(r *http.Request) string {
 		return g.ticketBase + "/tickets/submissions"
 	}))
 	router.Get("/tickets/submissions/{id}", g.proxy(http.MethodGet, func(r *http.Request) string {
 		return g.ticketBase + "/tickets/submissions/" + chi.URLParam(r, "id")
 	}))
+	router.Post("/tickets/submissions/{id}/requeue", g.proxy(http.MethodPost, func(r *http.Request) string {
+		return g.ticketBase + "/tickets/submissions/" + chi.URLParam(r, "id") + "/requeue"
+	}))
+	router.Get("/tickets/submissions/{id}/attachments", g.proxy(http.MethodGet, func(r *http.Request) string {
+		return g.ticketBase + "/tickets/submissions/" + chi.URLParam(r, "id") + "/attachments"
+	}))
+	router.Post("/tickets/submissions/{id}/attachments", g.proxy(http.MethodPost, func(r *http.Request) string {
+		return g.ticketBase + "/tickets/submissions/" + chi.URLParam(r, "id") + "/attachments"
+	}))
 	router.Get("/tickets/queue-metrics", g.proxy(http.MethodGet, func(r *http.Request) string {
 		return g.ticketBase + "/tickets/queue-metrics"
 	}))
+	router.Get("/tickets/sla-policies", g.proxy(http.MethodGet, func(r *http.Request) string {
+		return g.ticketBase + "/tickets/sla-policies"
+	}))
+	router.Post("/tickets/sla-policies", g.proxy(http.MethodPost, func(r *http.Request) string {
+		return g.ticketBase + "/tickets/sla-policies"
+	}))
+	router.Get("/tickets/sla-policies/{policyId}", g.proxy(http.MethodGet, func(r *http.Request) string {
+		return g.ticketBase + "/tickets/sla-policies/" + chi.URLParam(r, "policyId")
+	}))
+	router.Patch("/tickets/sla-policies/{policyId}", g.proxy(http.MethodPatch, func(r *http.Request) string {
+		return g.ticketBase + "/tickets/sla-policies/" + chi.URLParam(r, "policyId")
+	}))
+	router.Delete("/tickets/sla-policies/{policyId}", g.proxy(http.MethodDelete, func(r *http.Request) string {
+		return g.ticketBase + "/tickets/sla-policies/" + chi.URLParam(r, "policyId")
+	}))
+	router.Get("/tickets/assignment-rules", g.proxy(http.MethodGet, func(r *http.Request) string {
+		return g.ticketBase + "/tickets/assignment-rules"
+	}))
+	router.Post("/tickets/assignment-rules", g.proxy(http.MethodPost, func(r *http.Request) string {
+		return g.ticketBase + "/tickets/assignment-rules"
+	}))
+	router.Get("/tickets/assignment-rules/{ruleId}", g.proxy(http.MethodGet, func(r *http.Request) string {
+		return g.ticketBase + "/tickets/assignment-rules/" + chi.URLParam(r, "ruleId")
+	}))
+	router.Patch("/tickets/assignment-rules/{ruleId}", g.proxy(http.MethodPatch, func(r *http.Request) string {
+		return g.ticketBase + "/tickets/assignment-rules/" + chi.URLParam(r, "ruleId")
+	}))
+	router.Delete("/tickets/assignment-rules/{ruleId}", g.proxy(http.MethodDelete, func(r *http.Request) string {
+		return g.ticketBase + "/tickets/assignment-rules/" + chi.URLParam(r, "ruleId")
+	}))
+	router.Get("/tickets/webhooks", g.proxy(http.MethodGet, func(r *http.Request) string {
+		return g.ticketBase + "/tickets/webhooks"
+	}))
+	router.Post("/tickets/webhooks", g.proxy(http.MethodPost, func(r *http.Request) string {
+		return g.ticketBase + "/tickets/webhooks"
+	}))
+	router.Get("/tickets/webhooks/{webhookId}", g.proxy(http.MethodGet, func(r *http.Request) string {
+		return g.ticketBase + "/tickets/webhooks/" + chi.URLParam(r, "webhookId")
+	}))
+	router.Patch("/tickets/webhooks/{webhookId}", g.proxy(http.MethodPatch, func(r *http.Request) string {
+		return g.ticketBase + "/tickets/webhooks/" + chi.URLParam(r, "webhookId")
+	}))
+	router.Delete("/tickets/webhooks/{webhookId}", g.proxy(http.MethodDelete, func(r *http.Request) string {
+		return g.ticketBase + "/tickets/webhooks/" + chi.URLParam(r, "webhookId")
+	}))
+	router.Get("/tickets/webhooks/{webhookId}/deliveries", g.proxy(http.MethodGet, func(r *http.Request) string {
+		return g.ticketBase + "/tickets/webhooks/" + chi.URLParam(r, "webhookId") + "/deliveries"
+	}))
+	router.Get("/tickets/webhooks/{webhookId}/deliveries/{deliveryId}", g.proxy(http.MethodGet, func(r *http.Request) string {
+		return g.ticketBase + "/tickets/webhooks/" + chi.URLParam(r, "webhookId") + "/deliveries/" + chi.URLParam(r, "deliveryId")
+	}))
+	router.Post("/tickets/webhooks/{webhookId}/deliveries/{deliveryId}/redeliver", g.proxy(http.MethodPost, func(r *http.Request) string {
+		return g.ticketBase + "/tickets/webhooks/" + chi.URLParam(r, "webhookId") + "/deliveries/" + chi.URLParam(r, "deliveryId") + "/redeliver"
+	}))
 	router.Get("/tickets/{id}", g.proxy(http.MethodGet, func(r *http.Request) string {
 		return g.ticketBase + "/tickets/" + chi.URLParam(r, "id")
 	}))
@@ -102,9 +207,48 @@ func (g *gateway) registerRoutes(router chi.Router) {
 	router.Delete("/tickets/{id}", g.proxy(http.MethodDelete, func(r *http.Request) string {
 		return g.ticketBase + "/tickets/" + chi.URLParam(r, "id")
 	}))
+	router.Post("/tickets/{id}/restore", g.proxy(http.MethodPost, func(r *http.Request) string {
+		return g.ticketBase + "/tickets/" + chi.URLParam(r, "id") + "/restore"
+	}))
 	router.Post("/tickets/{id}/resolve", g.proxy(http.MethodPost, func(r *http.Request) string {
 		return g.ticketBase + "/tickets/" + chi.URLParam(r, "id") + "/resolve"
 	}))
+	router.Post("/tickets/{id}/transitions", g.proxy(http.MethodPost, func(r *http.Request) string {
+		return g.ticketBase + "/tickets/" + chi.URLParam(r, "id") + "/transitions"
+	}))
+	router.Post("/tickets/{id}/assign", g.proxy(http.MethodPost, func(r *http.Request) string {
+		return g.ticketBase + "/tickets/" + chi.URLParam(r, "id") + "/assign"
+	}))
+	router.Get("/tickets/{id}/history", g.proxy(http.MethodGet, func(r *http.Request) string {
+		return g.ticketBase + "/tickets/" + chi.URLParam(r, "id") + "/history"
+	}))
+	router.Get("/tickets/{id}/timeline", g.proxy(http.MethodGet, func(r *http.Request) string {
+		return g.ticketBase + "/tickets/" + chi.URLParam(r, "id") + "/timeline"
+	}))
+	router.Get("/tickets/{id}/comments", g.proxy(http.MethodGet, func(r *http.Request) string {
+		return g.ticketBase + "/tickets/" + chi.URLParam(r, "id") + "/comments"
+	}))
+	router.Post("/tickets/{id}/comments", g.proxy(http.MethodPost, func(r *http.Request) string {
+		return g.ticketBase + "/tickets/" + chi.URLParam(r, "id") + "/comments"
+	}))
+	router.Patch("/tickets/{id}/comments/{commentId}", g.proxy(http.MethodPatch, func(r *http.Request) string {
+		return g.ticketBase + "/tickets/" + chi.URLParam(r, "id") + "/comments/" + chi.URLParam(r, "commentId")
+	}))
+	router.Delete("/tickets/{id}/comments/{commentId}", g.proxy(http.MethodDelete, func(r *http.Request) string {
+		return g.ticketBase + "/tickets/" + chi.URLParam(r, "id") + "/comments/" + chi.URLParam(r, "commentId")
+	}))
+	router.Get("/tickets/{id}/attachments", g.proxy(http.MethodGet, func(r *http.Request) string {
+		return g.ticketBase + "/tickets/" + chi.URLParam(r, "id") + "/attachments"
+	}))
+	router.Post("/tickets/{id}/attachments", g.proxy(http.MethodPost, func(r *http.Request) string {
+		return g.ticketBase + "/tickets/" + chi.URLParam(r, "id") + "/attachments"
+	}))
+	router.Get("/tickets/{id}/attachments/{attachmentId}", g.proxy(http.MethodGet, func(r *http.Request) string {
+		return g.ticketBase + "/tickets/" + chi.URLParam(r, "id") + "/attachments/" + chi.URLParam(r, "attachmentId")
+	}))
+	router.Delete("/tickets/{id}/attachments/{attachmentId}", g.proxy(http.MethodDelete, func(r *http.Request) string {
+		return g.ticketBase + "/tickets/" + chi.URLParam(r, "id") + "/attachments/" + chi.URLParam(r, "attachmentId")
+	}))
 
 	router.Get("/users", g.proxy(http.MethodGet, func(r *http.Request) string {
 		return g.identityBase + "/identity/users"
@@ -121,6 +265,42 @@ func (g *gateway) registerRoutes(router chi.Router) {
 	router.Delete("/users/{id}", g.proxy(http.MethodDelete, func(r *http.Request) string {
 		return g.identityBase + "/identity/users/" + chi.URLParam(r, "id")
 	}))
+	router.Post("/users/{id}/restore", g.proxy(http.MethodPost, func(r *http.Request) string {
+		return g.identityBase + "/identity/users/" + chi.URLParam(r, "id") + "/restore"
+	}))
+
+	router.Get("/roles", g.proxy(http.MethodGet, func(r *http.Request) string {
+		return g.identityBase + "/roles"
+	}))
+	router.Post("/roles", g.proxy(http.MethodPost, func(r *http.Request) string {
+		return g.identityBase + "/roles"
+	}))
+	router.Get("/roles/permissions", g.proxy(http.MethodGet, func(r *http.Request) string {
+		return g.identityBase + "/roles/permissions"
+	}))
+	router.Get("/roles/{id}", g.proxy(http.MethodGet, func(r *http.Request) string {
+		return g.identityBase + "/roles/" + chi.URLParam(r, "id")
+	}))
+	router.Put("/roles/{id}", g.proxy(http.MethodPut, func(r *http.Request) string {
+		return g.identityBase + "/roles/" + chi.URLParam(r, "id")
+	}))
+	router.Delete("/roles/{id}", g.proxy(http.MethodDelete, func(r *http.Request) string {
+		return g.identityBase + "/roles/" + chi.URLParam(r, "id")
+	}))
+
+	router.Get("/audit", g.proxy(http.MethodGet, func(r *http.Request) string {
+		return g.identityBase + "/audit"
+	}))
+	router.Get("/audit/verify", g.proxy(http.MethodGet, func(r *http.Request) string {
+		return g.identityBase + "/audit/verify"
+	}))
+
+	for _, route := range []string{"/auth/login", "/auth/refresh", "/auth/logout", "/auth/password", "/auth/password-reset", "/auth/password-reset/confirm"} {
+		target := g.identityBase + route
+		router.Post(route, g.proxy(http.MethodPost, func(r *http.Request) string {
+			return target
+		}))
+	}
 
 	router.Get("/workflows", g.proxy(http.MethodGet, func(r *http.Request) string {
 		return g.workflowBase + "/workflows"
@@ -128,6 +308,12 @@ func (g *gateway) registerRoutes(router chi.Router) {
 	router.Post("/workflows", g.proxy(http.MethodPost, func(r *http.Request) string {
 		return g.workflowBase + "/workflows"
 	}))
+	router.Post("/workflows/validate", g.proxy(http.MethodPost, func(r *http.Request) string {
+		return g.workflowBase + "/workflows/validate"
+	}))
+	router.Post("/workflows/import", g.proxy(http.MethodPost, func(r *http.Request) string {
+		return g.workflowBase + "/workflows/import"
+	}))
 	router.Get("/workflows/{id}", g.proxy(http.MethodGet, func(r *http.Request) string {
 		return g.workflowBase + "/workflows/" + chi.URLParam(r, "id")
 	}))
@@ -137,9 +323,36 @@ func (g *gateway) registerRoutes(router chi.Router) {
 	router.Delete("/workflows/{id}", g.proxy(http.MethodDelete, func(r *http.Request) string {
 		return g.workflowBase + "/workflows/" + chi.URLParam(r, "id")
 	}))
+	router.Post("/workflows/{id}/restore", g.proxy(http.MethodPost, func(r *http.Request) string {
+		return g.workflowBase + "/workflows/" + chi.URLParam(r, "id") + "/restore"
+	}))
 	router.Post("/workflows/{id}/publish", g.proxy(http.MethodPost, func(r *http.Request) string {
 		return g.workflowBase + "/workflows/" + chi.URLParam(r, "id") + "/publish"
 	}))
+	router.Get("/workflows/{id}/bpmn", g.proxy(http.MethodGet, func(r *http.Request) string {
+		return g.workflowBase + "/workflows/" + chi.URLParam(r, "id") + "/bpmn"
+	}))
+	router.Get("/workflows/{id}/versions", g.proxy(http.MethodGet, func(r *http.Request) string {
+		return g.workflowBase + "/workflows/" + chi.URLParam(r, "id") + "/versions"
+	}))
+	router.Get("/workflows/{id}/versions/{version}", g.proxy(http.MethodGet, func(r *http.Request) string {
+		return g.workflowBase + "/workflows/" + chi.URLParam(r, "id") + "/versions/" + chi.URLParam(r, "version")
+	}))
+	router.Post("/workflows/{id}/versions/{version}/rollback", g.proxy(http.MethodPost, func(r *http.Request) string {
+		return g.workflowBase + "/workflows/" + chi.URLParam(r, "id") + "/versions/" + chi.URLParam(r, "version") + "/rollback"
+	}))
+	router.Get("/workflows/{id}/diff", g.proxy(http.MethodGet, func(r *http.Request) string {
+		return g.workflowBase + "/workflows/" + chi.URLParam(r, "id") + "/diff"
+	}))
+	router.Post("/workflows/{id}/instances", g.proxy(http.MethodPost, func(r *http.Request) string {
+		return g.workflowBase + "/workflows/" + chi.URLParam(r, "id") + "/instances"
+	}))
+	router.Get("/instances/{id}", g.proxy(http.MethodGet, func(r *http.Request) string {
+		return g.workflowBase + "/instances/" + chi.URLParam(r, "id")
+	}))
+	router.Post("/instances/{id}/tasks/{taskId}/complete", g.proxy(http.MethodPost, func(r *http.Request) string {
+		return g.workflowBase + "/instances/" + chi.URLParam(r, "id") + "/tasks/" + chi.URLParam(r, "taskId") + "/complete"
+	}))
 
 	router.Get("/overview", g.overviewHandler)
 }
@@ -199,91 +412,108 @@ func (g *gateway) forward(w http.ResponseWriter, r *http.Request, method, target
 	}
 }
 
+var overviewTicketStatuses = []string{"open", "in_progress", "resolved", "cancelled"}
+
 func (g *gateway) overviewHandler(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
-	forms, err := g.fetchList(ctx, g.formBase+"/forms")
+	forms, err := g.fetchTotal(ctx, g.formBase+"/forms", nil)
 	if err != nil {
 		g.renderUpstreamError(w, err)
 		return
 	}
 
-	tickets, err := g.fetchList(ctx, g.ticketBase+"/tickets")
+	tickets, err := g.fetchTotal(ctx, g.ticketBase+"/tickets", nil)
 	if err != nil {
 		g.renderUpstreamError(w, err)
 		return
 	}
 
-	users, err := g.fetchList(ctx, g.identityBase+"/identity/users")
+	ticketStatus := map[string]int64{}
+	for _, status := range overviewTicketStatuses {
+		total, err := g.fetchTotal(ctx, g.ticketBase+"/tickets", url.Values{"status": {status}})
+		if err != nil {
+			g.renderUpstreamError(w, err)
+			return
+		}
+		if total > 0 {
+			ticketStatus[status] = total
+		}
+	}
+
+	users, err := g.fetchTotal(ctx, g.identityBase+"/identity/users", nil)
 	if err != nil {
 		g.renderUpstreamError(w, err)
 		return
 	}
 
-	workflows, err := g.fetchList(ctx, g.workflowBase+"/workflows")
+	workflows, err := g.fetchTotal(ctx, g.workflowBase+"/workflows", nil)
 	if err != nil {
 		g.renderUpstreamError(w, err)
 		return
 	}
 
-	ticketStatus := map[string]int{}
-	for _, ticket := range tickets {
-		if status, ok := ticket["status"].(string); ok {
-			ticketStatus[status]++
-		}
-	}
-
-	publishedWorkflows := 0
-	for _, wf := range workflows {
-		if published, ok := wf["published"].(bool); ok && published {
-			publishedWorkflows++
-		}
+	publishedWorkflows, err := g.fetchTotal(ctx, g.workflowBase+"/workflows", url.Values{"published": {"true"}})
+	if err != nil {
+		g.renderUpstreamError(w, err)
+		return
 	}
 
 	httpx.JSON(w, http.StatusOK, map[string]any{
 		"data": map[string]any{
 			"forms": map[string]any{
-				"total": len(forms),
+				"total": forms,
 			},
 			"tickets": map[string]any{
-				"total":    len(tickets),
+				"total":    tickets,
 				"byStatus": ticketStatus,
 			},
 			"users": map[string]any{
-				"total": len(users),
+				"total": users,
 			},
 			"workflows": map[string]any{
-				"total":     len(workflows),
+				"total":     workflows,
 				"published": publishedWorkflows,
 			},
 		},
 	})
 }
 
-func (g *gateway) fetchList(ctx context.Context, target string) ([]map[string]any, error) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+// fetchTotal asks an upstream list endpoint for a single row and returns the reported total.
+func (g *gateway) fetchTotal(ctx context.Context, target string, filters url.Values) (int64, error) {
+	query := url.Values{}
+	for key, values := range filters {
+		query[key] = values
+	}
+	query.Set("limit", "1")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target+"?"+query.Encode(), nil)
 	if err != nil {
-		return nil, err
+		return 0, err
+	}
+	if principal, ok := auth.FromContext(ctx); ok {
+		auth.Forward(req.Header, principal, g.forwardSecret)
 	}
+	req.Header.Set(tenant.Header, tenant.ID(ctx))
 
 	resp, err := g.client.Do(req)
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode >= 400 {
 		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
-		return nil, fmt.Errorf("upstream %s responded with %d: %s", target, resp.StatusCode, strings.TrimSpace(string(body)))
+		return 0, fmt.Errorf("upstream %s responded with %d: %s", target, resp.StatusCode, strings.TrimSpace(string(body)))
 	}
 
 	var payload struct {
-		Data []map[string]any `json:"data"`
+		Total int64 `json:"total"`
 	}
 	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
-		return nil, err
+		return 0, err
 	}
-	return payload.Data, nil
+	return payload.Total, nil
 }
 
 func (g *gateway) renderUpstreamError(w http.ResponseWriter, err error) {
@@ -342,6 +572,10 @@ func appendForwardedHeaders(dst http.Header, r *http.Request) {
 		dst.Set("X-Forwarded-Host", r.Host)
 	}
 
+	if id := middleware.GetReqID(r.Context()); id != "" {
+		dst.Set(middleware.RequestIDHeader, id)
+	}
+
 	if proto := forwardedProto(r); proto != "" {
 		dst.Set("X-Forwarded-Proto", proto)
 	}
@@ -16,6 +16,16 @@ type Config struct {
 	WorkflowServiceURL  string
 	RequestTimeout      time.Duration
 	ShutdownGracePeriod time.Duration
+
+	// Bearer tokens are verified with JWTSecret (HS256) and/or the RS256 keys
+	// served by JWKS, a file path or URL. ForwardSecret signs the principal
+	// headers sent upstream.
+	JWTSecret     string
+	JWKS          string
+	JWTIssuer     string
+	JWTAudience   string
+	ForwardSecret string
+	AuthDisabled  bool
 }
 
 const (
@@ -36,6 +46,13 @@ func Load() (Config, error) {
 		WorkflowServiceURL:  os.Getenv("WORKFLOW_SERVICE_URL"),
 		RequestTimeout:      parseDuration("GATEWAY_REQUEST_TIMEOUT", defaultRequestTimeout),
 		ShutdownGracePeriod: parseDuration("GATEWAY_SHUTDOWN_GRACE", defaultShutdownGrace),
+
+		JWTSecret:     os.Getenv("AUTH_JWT_SECRET"),
+		JWKS:          os.Getenv("AUTH_JWKS"),
+		JWTIssuer:     os.Getenv("AUTH_JWT_ISSUER"),
+		JWTAudience:   os.Getenv("AUTH_JWT_AUDIENCE"),
+		ForwardSecret: os.Getenv("AUTH_FORWARD_SECRET"),
+		AuthDisabled:  os.Getenv("AUTH_DISABLED") == "true",
 	}
 
 	if cfg.FormServiceURL == "" {
@@ -50,6 +67,9 @@ func Load() (Config, error) {
 	if cfg.WorkflowServiceURL == "" {
 		return Config{}, fmt.Errorf("WORKFLOW_SERVICE_URL is required")
 	}
+	if cfg.JWTSecret == "" && cfg.JWKS == "" && !cfg.AuthDisabled {
+		return Config{}, fmt.Errorf("AUTH_JWT_SECRET or AUTH_JWKS is required (set AUTH_DISABLED=true to run without authentication)")
+	}
 
 	return cfg, nil
 }
@@ -5,9 +5,12 @@ import (
 	"encoding/json"
 	"errors"
 	"io"
+	"net"
 	"net/http"
 	"net/url"
 	"strings"
+
+	"github.com/go-chi/chi/v5/middleware"
 )
 
 // Forward relays an incoming request to the provided upstream base URL and
@@ -33,6 +36,7 @@ func Forward(w http.ResponseWriter, r *http.Request, client *http.Client, base s
 	}
 
 	copyRequestHeaders(req.Header, r.Header)
+	setForwardedHeaders(req.Header, r)
 
 	resp, err := client.Do(req)
 	if err != nil {
@@ -86,7 +90,7 @@ func readRequestBody(r *http.Request) (io.ReadCloser, error) {
 func copyRequestHeaders(dst http.Header, src http.Header) {
 	for key, values := range src {
 		lower := strings.ToLower(key)
-		if lower == "content-type" || strings.HasPrefix(lower, "x-") {
+		if lower == "content-type" || lower == "if-none-match" || strings.HasPrefix(lower, "x-") {
 			for _, value := range values {
 				dst.Add(key, value)
 			}
@@ -94,33 +98,35 @@ func copyRequestHeaders(dst http.Header, src http.Header) {
 	}
 }
 
+// setForwardedHeaders passes the request ID on and appends the client address
+// to X-Forwarded-For, so upstream services can attribute the request.
+func setForwardedHeaders(dst http.Header, r *http.Request) {
+	if id := middleware.GetReqID(r.Context()); id != "" {
+		dst.Set(middleware.RequestIDHeader, id)
+	}
+
+	host, _, err := net.SplitHostPort(strings.TrimSpace(r.RemoteAddr))
+	if err != nil {
+		host = strings.TrimSpace(r.RemoteAddr)
+	}
+	if host == "" {
+		return
+	}
+	if prior := dst.Values("X-Forwarded-For"); len(prior) > 0 {
+		host = strings.Join(prior, ", ") + ", " + host
+	}
+	dst.Set("X-Forwarded-For", host)
+}
+
 func copyResponseHeaders(dst http.Header, src http.Header) {
 	for key, values := range src {
 		lower := strings.ToLower(key)
-		if lower == "content-type" || lower == "content-length" || strings.HasPrefix(lower, "x-") {
+		if lower == "content-type" || lower == "content-length" || lower == "content-disposition" || lower == "etag" || strings.HasPrefix(lower, "x-") {
 			dst[key] = append([]string(nil), values...)
 		}
 	}
 }
 
-// DecodeJSONArray normalizes upstream payloads that may either be a bare array
-// or a paginated envelope (with a top-level "results" field). It returns an
-// empty slice when decoding fails.
-func DecodeJSONArray(payload []byte) []map[string]any {
-	var arr []map[string]any
-	if err := json.Unmarshal(payload, &arr); err == nil {
-		return arr
-	}
-
-	var envelope struct {
-		Results []map[string]any `json:"results"`
-	}
-	if err := json.Unmarshal(payload, &envelope); err == nil && envelope.Results != nil {
-		return envelope.Results
-	}
-	return []map[string]any{}
-}
-
 // DecodeObject parses payload into a map. When decoding fails it returns an
 // empty map and the encountered error to allow callers to fall back to defaults.
 func DecodeObject(payload []byte) (map[string]any, error) {
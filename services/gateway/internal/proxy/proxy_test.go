@@ -5,6 +5,8 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+
+	"github.com/go-chi/chi/v5/middleware"
 )
 
 func TestForwardCopiesJSON(t *testing.T) {
@@ -36,3 +38,62 @@ func TestForwardCopiesJSON(t *testing.T) {
 		t.Fatalf("unexpected content type: %s", ct)
 	}
 }
+
+func TestForwardKeepsDownloadHeaders(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"abc"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Content-Type", "image/png")
+		w.Header().Set("Content-Disposition", "attachment; filename=shot.png")
+		w.Header().Set("ETag", `"abc"`)
+		io.WriteString(w, "png")
+	}))
+	defer upstream.Close()
+
+	recorder := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "http://localhost/api/tickets/1/attachments/2", nil)
+	Forward(recorder, req, upstream.Client(), upstream.URL+"/api/tickets", "/1/attachments/2")
+
+	if got := recorder.Header().Get("Content-Disposition"); got != "attachment; filename=shot.png" {
+		t.Fatalf("unexpected content disposition: %q", got)
+	}
+	if got := recorder.Header().Get("ETag"); got != `"abc"` {
+		t.Fatalf("unexpected etag: %q", got)
+	}
+
+	recorder = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, "http://localhost/api/tickets/1/attachments/2", nil)
+	req.Header.Set("If-None-Match", `"abc"`)
+	Forward(recorder, req, upstream.Client(), upstream.URL+"/api/tickets", "/1/attachments/2")
+
+	if recorder.Code != http.StatusNotModified {
+		t.Fatalf("expected conditional request to reach upstream, got %d", recorder.Code)
+	}
+}
+
+func TestForwardAttributesRequest(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get(middleware.RequestIDHeader); got != "req-7" {
+			t.Errorf("unexpected request id: %q", got)
+		}
+		if got := r.Header.Get("X-Forwarded-For"); got != "198.51.100.7, 192.0.2.1" {
+			t.Errorf("unexpected forwarded for: %q", got)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer upstream.Close()
+
+	recorder := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodDelete, "http://localhost/api/forms/123", nil)
+	req.Header.Set(middleware.RequestIDHeader, "req-7")
+	req.Header.Set("X-Forwarded-For", "198.51.100.7")
+	middleware.RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Forward(w, r, upstream.Client(), upstream.URL+"/api/forms", "/123")
+	})).ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusNoContent {
+		t.Fatalf("expected status 204, got %d", recorder.Code)
+	}
+}
@@ -1,9 +1,11 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
 	"strings"
 	"time"
 
@@ -12,12 +14,20 @@ import (
 
 	"github.com/pflow/gateway/internal/config"
 	"github.com/pflow/gateway/internal/proxy"
+	"github.com/pflow/shared/auth"
+	"github.com/pflow/shared/tenant"
 )
 
-// New constructs the HTTP server wiring for the gateway.
-func New(cfg config.Config) *http.Server {
+// New constructs the HTTP server wiring for the gateway. It fails when the
+// configured JWKS source cannot be loaded.
+func New(cfg config.Config) (*http.Server, error) {
 	client := &http.Client{Timeout: cfg.RequestTimeout}
 
+	authn, err := authMiddleware(cfg)
+	if err != nil {
+		return nil, err
+	}
+
 	router := chi.NewRouter()
 	router.Use(middleware.RequestID)
 	router.Use(middleware.RealIP)
@@ -33,12 +43,19 @@ func New(cfg config.Config) *http.Server {
 	})
 
 	router.Route("/api", func(api chi.Router) {
+		if authn != nil {
+			api.Use(authn)
+		}
+		api.Use(tenant.Middleware)
 		api.Get("/overview", overviewHandler(client, cfg))
 
-		mountCollectionProxy(api, "/forms", ensureTrailingSlash(cfg.FormServiceURL+"/api/forms"), client)
-		mountCollectionProxy(api, "/users", ensureTrailingSlash(cfg.IdentityServiceURL+"/api/users"), client)
+		mountAuthRoutes(api, cfg, client)
+		mountFormRoutes(api, cfg, client)
+		mountUserRoutes(api, cfg, client)
+		mountRoleRoutes(api, cfg, client)
+		mountAuditRoutes(api, cfg, client)
 		mountTicketRoutes(api, cfg, client)
-		mountCollectionProxy(api, "/workflows", ensureTrailingSlash(cfg.WorkflowServiceURL+"/api/workflows"), client)
+		mountWorkflowRoutes(api, cfg, client)
 	})
 
 	addr := fmt.Sprintf(":%s", cfg.Port)
@@ -48,7 +65,25 @@ func New(cfg config.Config) *http.Server {
 		ReadTimeout:  cfg.RequestTimeout + time.Second,
 		WriteTimeout: cfg.RequestTimeout + time.Second,
 		IdleTimeout:  60 * time.Second,
+	}, nil
+}
+
+// authMiddleware returns nil only when authentication is explicitly disabled.
+func authMiddleware(cfg config.Config) (func(http.Handler) http.Handler, error) {
+	if cfg.AuthDisabled && cfg.JWTSecret == "" && cfg.JWKS == "" {
+		return nil, nil
 	}
+	verifier, err := auth.NewVerifier(context.Background(), auth.Options{
+		HMACSecret: []byte(cfg.JWTSecret),
+		JWKS:       cfg.JWKS,
+		Issuer:     cfg.JWTIssuer,
+		Audience:   cfg.JWTAudience,
+		HTTPClient: &http.Client{Timeout: cfg.RequestTimeout},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return auth.Middleware(verifier, auth.ForwardSigned([]byte(cfg.ForwardSecret)), auth.PublicPaths(publicAuthPaths...)), nil
 }
 
 func mountCollectionProxy(api chi.Router, prefix string, upstream string, client *http.Client) {
@@ -60,6 +95,61 @@ func mountCollectionProxy(api chi.Router, prefix string, upstream string, client
 	api.MethodFunc(http.MethodDelete, prefix+"/{id}", proxyHandler(prefix, upstream, client))
 }
 
+// publicAuthPaths are the login and token routes reachable without an access token.
+var publicAuthPaths = []string{"/api/auth/login", "/api/auth/refresh", "/api/auth/logout", "/api/auth/password-reset"}
+
+func mountAuthRoutes(api chi.Router, cfg config.Config, client *http.Client) {
+	base := ensureTrailingSlash(cfg.IdentityServiceURL + "/api/auth")
+	for _, route := range []string{"/auth/login", "/auth/refresh", "/auth/logout", "/auth/password", "/auth/password-reset", "/auth/password-reset/confirm"} {
+		api.MethodFunc(http.MethodPost, route, proxyHandler("/auth", base, client))
+	}
+}
+
+func mountUserRoutes(api chi.Router, cfg config.Config, client *http.Client) {
+	base := ensureTrailingSlash(cfg.IdentityServiceURL + "/api/users")
+	mountCollectionProxy(api, "/users", base, client)
+	api.MethodFunc(http.MethodPost, "/users/{id}/restore", proxyHandler("/users", base, client))
+}
+
+func mountRoleRoutes(api chi.Router, cfg config.Config, client *http.Client) {
+	base := ensureTrailingSlash(cfg.IdentityServiceURL + "/api/roles")
+	mountCollectionProxy(api, "/roles", base, client)
+	api.MethodFunc(http.MethodGet, "/roles/permissions", proxyHandler("/roles", base, client))
+}
+
+func mountAuditRoutes(api chi.Router, cfg config.Config, client *http.Client) {
+	base := ensureTrailingSlash(cfg.IdentityServiceURL + "/api/audit")
+	api.MethodFunc(http.MethodGet, "/audit", proxyHandler("/audit", base, client))
+	api.MethodFunc(http.MethodGet, "/audit/verify", proxyHandler("/audit", base, client))
+}
+
+func mountFormRoutes(api chi.Router, cfg config.Config, client *http.Client) {
+	base := ensureTrailingSlash(cfg.FormServiceURL + "/api/forms")
+	mountCollectionProxy(api, "/forms", base, client)
+	api.MethodFunc(http.MethodPost, "/forms/{id}/restore", proxyHandler("/forms", base, client))
+	api.MethodFunc(http.MethodGet, "/forms/{id}/versions", proxyHandler("/forms", base, client))
+	api.MethodFunc(http.MethodGet, "/forms/{id}/versions/{version}", proxyHandler("/forms", base, client))
+}
+
+func mountWorkflowRoutes(api chi.Router, cfg config.Config, client *http.Client) {
+	base := ensureTrailingSlash(cfg.WorkflowServiceURL + "/api/workflows")
+	api.MethodFunc(http.MethodPost, "/workflows/validate", proxyHandler("/workflows", base, client))
+	api.MethodFunc(http.MethodPost, "/workflows/import", proxyHandler("/workflows", base, client))
+	mountCollectionProxy(api, "/workflows", base, client)
+	api.MethodFunc(http.MethodPost, "/workflows/{id}/restore", proxyHandler("/workflows", base, client))
+	api.MethodFunc(http.MethodPost, "/workflows/{id}/publish", proxyHandler("/workflows", base, client))
+	api.MethodFunc(http.MethodGet, "/workflows/{id}/bpmn", proxyHandler("/workflows", base, client))
+	api.MethodFunc(http.MethodGet, "/workflows/{id}/versions", proxyHandler("/workflows", base, client))
+	api.MethodFunc(http.MethodGet, "/workflows/{id}/versions/{version}", proxyHandler("/workflows", base, client))
+	api.MethodFunc(http.MethodPost, "/workflows/{id}/versions/{version}/rollback", proxyHandler("/workflows", base, client))
+	api.MethodFunc(http.MethodGet, "/workflows/{id}/diff", proxyHandler("/workflows", base, client))
+	api.MethodFunc(http.MethodPost, "/workflows/{id}/instances", proxyHandler("/workflows", base, client))
+
+	instances := ensureTrailingSlash(cfg.WorkflowServiceURL + "/api/instances")
+	api.MethodFunc(http.MethodGet, "/instances/{id}", proxyHandler("/instances", instances, client))
+	api.MethodFunc(http.MethodPost, "/instances/{id}/tasks/{taskId}/complete", proxyHandler("/instances", instances, client))
+}
+
 func mountTicketRoutes(api chi.Router, cfg config.Config, client *http.Client) {
 	base := ensureTrailingSlash(cfg.TicketServiceURL + "/api/tickets")
 	api.MethodFunc(http.MethodGet, "/tickets", proxyHandler("/tickets", base, client))
@@ -68,15 +158,53 @@ func mountTicketRoutes(api chi.Router, cfg config.Config, client *http.Client) {
 	api.MethodFunc(http.MethodPut, "/tickets/{ticketID}", proxyHandler("/tickets", base, client))
 	api.MethodFunc(http.MethodPatch, "/tickets/{ticketID}", proxyHandler("/tickets", base, client))
 	api.MethodFunc(http.MethodDelete, "/tickets/{ticketID}", proxyHandler("/tickets", base, client))
+	api.MethodFunc(http.MethodPost, "/tickets/{ticketID}/restore", proxyHandler("/tickets", base, client))
 	api.MethodFunc(http.MethodPost, "/tickets/{ticketID}/resolve", proxyHandler("/tickets", base, client))
+	api.MethodFunc(http.MethodPost, "/tickets/{ticketID}/transitions", proxyHandler("/tickets", base, client))
+	api.MethodFunc(http.MethodPost, "/tickets/{ticketID}/assign", proxyHandler("/tickets", base, client))
+	api.MethodFunc(http.MethodGet, "/tickets/{ticketID}/history", proxyHandler("/tickets", base, client))
+	api.MethodFunc(http.MethodGet, "/tickets/{ticketID}/timeline", proxyHandler("/tickets", base, client))
+	api.MethodFunc(http.MethodGet, "/tickets/{ticketID}/comments", proxyHandler("/tickets", base, client))
+	api.MethodFunc(http.MethodPost, "/tickets/{ticketID}/comments", proxyHandler("/tickets", base, client))
+	api.MethodFunc(http.MethodPatch, "/tickets/{ticketID}/comments/{commentID}", proxyHandler("/tickets", base, client))
+	api.MethodFunc(http.MethodDelete, "/tickets/{ticketID}/comments/{commentID}", proxyHandler("/tickets", base, client))
+	api.MethodFunc(http.MethodGet, "/tickets/{ticketID}/attachments", proxyHandler("/tickets", base, client))
+	api.MethodFunc(http.MethodPost, "/tickets/{ticketID}/attachments", proxyHandler("/tickets", base, client))
+	api.MethodFunc(http.MethodGet, "/tickets/{ticketID}/attachments/{attachmentID}", proxyHandler("/tickets", base, client))
+	api.MethodFunc(http.MethodDelete, "/tickets/{ticketID}/attachments/{attachmentID}", proxyHandler("/tickets", base, client))
 
 	submissionsBase := ensureTrailingSlash(cfg.TicketServiceURL + "/api/tickets/submissions")
 	api.MethodFunc(http.MethodGet, "/tickets/submissions", proxyHandler("/tickets/submissions", submissionsBase, client))
 	api.MethodFunc(http.MethodPost, "/tickets/submissions", proxyHandler("/tickets/submissions", submissionsBase, client))
 	api.MethodFunc(http.MethodGet, "/tickets/submissions/{submissionID}", proxyHandler("/tickets/submissions", submissionsBase, client))
+	api.MethodFunc(http.MethodPost, "/tickets/submissions/{submissionID}/requeue", proxyHandler("/tickets/submissions", submissionsBase, client))
 
 	queueMetrics := ensureTrailingSlash(cfg.TicketServiceURL + "/api/tickets/queue-metrics")
 	api.MethodFunc(http.MethodGet, "/tickets/queue-metrics", proxyHandler("/tickets/queue-metrics", queueMetrics, client))
+
+	slaBase := ensureTrailingSlash(cfg.TicketServiceURL + "/api/tickets/sla-policies")
+	api.MethodFunc(http.MethodGet, "/tickets/sla-policies", proxyHandler("/tickets/sla-policies", slaBase, client))
+	api.MethodFunc(http.MethodPost, "/tickets/sla-policies", proxyHandler("/tickets/sla-policies", slaBase, client))
+	api.MethodFunc(http.MethodGet, "/tickets/sla-policies/{policyID}", proxyHandler("/tickets/sla-policies", slaBase, client))
+	api.MethodFunc(http.MethodPatch, "/tickets/sla-policies/{policyID}", proxyHandler("/tickets/sla-policies", slaBase, client))
+	api.MethodFunc(http.MethodDelete, "/tickets/sla-policies/{policyID}", proxyHandler("/tickets/sla-policies", slaBase, client))
+
+	rulesBase := ensureTrailingSlash(cfg.TicketServiceURL + "/api/tickets/assignment-rules")
+	api.MethodFunc(http.MethodGet, "/tickets/assignment-rules", proxyHandler("/tickets/assignment-rules", rulesBase, client))
+	api.MethodFunc(http.MethodPost, "/tickets/assignment-rules", proxyHandler("/tickets/assignment-rules", rulesBase, client))
+	api.MethodFunc(http.MethodGet, "/tickets/assignment-rules/{ruleID}", proxyHandler("/tickets/assignment-rules", rulesBase, client))
+	api.MethodFunc(http.MethodPatch, "/tickets/assignment-rules/{ruleID}", proxyHandler("/tickets/assignment-rules", rulesBase, client))
+	api.MethodFunc(http.MethodDelete, "/tickets/assignment-rules/{ruleID}", proxyHandler("/tickets/assignment-rules", rulesBase, client))
+
+	webhooksBase := ensureTrailingSlash(cfg.TicketServiceURL + "/api/tickets/webhooks")
+	api.MethodFunc(http.MethodGet, "/tickets/webhooks", proxyHandler("/tickets/webhooks", webhooksBase, client))
+	api.MethodFunc(http.MethodPost, "/tickets/webhooks", proxyHandler("/tickets/webhooks", webhooksBase, client))
+	api.MethodFunc(http.MethodGet, "/tickets/webhooks/{webhookID}", proxyHandler("/tickets/webhooks", webhooksBase, client))
+	api.MethodFunc(http.MethodPatch, "/tickets/webhooks/{webhookID}", proxyHandler("/tickets/webhooks", webhooksBase, client))
+	api.MethodFunc(http.MethodDelete, "/tickets/webhooks/{webhookID}", proxyHandler("/tickets/webhooks", webhooksBase, client))
+	api.MethodFunc(http.MethodGet, "/tickets/webhooks/{webhookID}/deliveries", proxyHandler("/tickets/webhooks", webhooksBase, client))
+	api.MethodFunc(http.MethodGet, "/tickets/webhooks/{webhookID}/deliveries/{deliveryID}", proxyHandler("/tickets/webhooks", webhooksBase, client))
+	api.MethodFunc(http.MethodPost, "/tickets/webhooks/{webhookID}/deliveries/{deliveryID}/redeliver", proxyHandler("/tickets/webhooks", webhooksBase, client))
 }
 
 func proxyHandler(prefix string, upstream string, client *http.Client) http.HandlerFunc {
@@ -93,12 +221,50 @@ func proxyHandler(prefix string, upstream string, client *http.Client) http.Hand
 	}
 }
 
+var overviewTicketStatuses = []string{"open", "in_progress", "resolved", "cancelled"}
+
+// overviewHandler reports how many forms, tickets, users and workflows the
+// caller's tenant has. The counts come from the totals of the upstream list
+// endpoints, so no collection is transferred.
 func overviewHandler(client *http.Client, cfg config.Config) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		forms := fetchCollection(client, ensureTrailingSlash(cfg.FormServiceURL+"/api/forms"))
-		tickets := fetchCollection(client, ensureTrailingSlash(cfg.TicketServiceURL+"/api/tickets"))
-		users := fetchCollection(client, ensureTrailingSlash(cfg.IdentityServiceURL+"/api/users"))
-		workflows := fetchCollection(client, ensureTrailingSlash(cfg.WorkflowServiceURL+"/api/workflows"))
+		ctx := r.Context()
+		secret := []byte(cfg.ForwardSecret)
+		ticketsURL := ensureTrailingSlash(cfg.TicketServiceURL + "/api/tickets")
+		workflowsURL := ensureTrailingSlash(cfg.WorkflowServiceURL + "/api/workflows")
+
+		counts := map[string]int64{}
+		for name, target := range map[string]string{
+			"forms":     ensureTrailingSlash(cfg.FormServiceURL + "/api/forms"),
+			"tickets":   ticketsURL,
+			"users":     ensureTrailingSlash(cfg.IdentityServiceURL + "/api/users"),
+			"workflows": workflowsURL,
+		} {
+			total, err := fetchTotal(ctx, client, target, secret, nil)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadGateway)
+				return
+			}
+			counts[name] = total
+		}
+
+		ticketStatusCounts := map[string]int64{}
+		for _, status := range overviewTicketStatuses {
+			total, err := fetchTotal(ctx, client, ticketsURL, secret, url.Values{"status": {status}})
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadGateway)
+				return
+			}
+			if total > 0 {
+				ticketStatusCounts[status] = total
+			}
+		}
+
+		published, err := fetchTotal(ctx, client, workflowsURL, secret, url.Values{"published": {"true"}})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
 
 		queueMetrics := map[string]any{
 			"pending":              0,
@@ -108,34 +274,20 @@ func overviewHandler(client *http.Client, cfg config.Config) http.HandlerFunc {
 			"oldestPendingSeconds": 0,
 		}
 
-		if metrics, err := fetchObject(client, ensureTrailingSlash(cfg.TicketServiceURL+"/api/tickets/queue-metrics")); err == nil {
+		if metrics, err := fetchObject(ctx, client, ensureTrailingSlash(cfg.TicketServiceURL+"/api/tickets/queue-metrics"), secret); err == nil {
 			queueMetrics = metrics
 		}
 
-		ticketStatusCounts := map[string]int{}
-		for _, ticket := range tickets {
-			if status, ok := ticket["status"].(string); ok {
-				ticketStatusCounts[status]++
-			}
-		}
-
-		published := 0
-		for _, workflow := range workflows {
-			if active, ok := workflow["is_active"].(bool); ok && active {
-				published++
-			}
-		}
-
 		payload := map[string]any{
-			"forms": map[string]any{"total": len(forms)},
+			"forms": map[string]any{"total": counts["forms"]},
 			"tickets": map[string]any{
-				"total":    len(tickets),
+				"total":    counts["tickets"],
 				"byStatus": ticketStatusCounts,
 				"queue":    queueMetrics,
 			},
-			"users": map[string]any{"total": len(users)},
+			"users": map[string]any{"total": counts["users"]},
 			"workflows": map[string]any{
-				"total":     len(workflows),
+				"total":     counts["workflows"],
 				"published": published,
 			},
 		}
@@ -145,26 +297,42 @@ func overviewHandler(client *http.Client, cfg config.Config) http.HandlerFunc {
 	}
 }
 
-func fetchCollection(client *http.Client, url string) []map[string]any {
-	req, err := http.NewRequest(http.MethodGet, url, http.NoBody)
-	if err != nil {
-		return []map[string]any{}
+// fetchTotal asks an upstream list endpoint for a single row and returns the
+// total it reports.
+func fetchTotal(ctx context.Context, client *http.Client, target string, secret []byte, filters url.Values) (int64, error) {
+	query := url.Values{}
+	for key, values := range filters {
+		query[key] = values
 	}
+	query.Set("limit", "1")
 
+	req, err := newUpstreamRequest(ctx, target+"?"+query.Encode(), secret)
+	if err != nil {
+		return 0, err
+	}
 	resp, err := client.Do(req)
 	if err != nil {
-		return []map[string]any{}
+		return 0, err
 	}
 	body, err := proxy.ReadBody(resp)
 	if err != nil {
-		return []map[string]any{}
+		return 0, err
+	}
+	if resp.StatusCode >= 400 {
+		return 0, fmt.Errorf("upstream %s responded with %d: %s", target, resp.StatusCode, strings.TrimSpace(string(body)))
 	}
 
-	return proxy.DecodeJSONArray(body)
+	var payload struct {
+		Total int64 `json:"total"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return 0, err
+	}
+	return payload.Total, nil
 }
 
-func fetchObject(client *http.Client, url string) (map[string]any, error) {
-	req, err := http.NewRequest(http.MethodGet, url, http.NoBody)
+func fetchObject(ctx context.Context, client *http.Client, url string, secret []byte) (map[string]any, error) {
+	req, err := newUpstreamRequest(ctx, url, secret)
 	if err != nil {
 		return nil, err
 	}
@@ -184,6 +352,20 @@ func fetchObject(client *http.Client, url string) (map[string]any, error) {
 	return obj, nil
 }
 
+// newUpstreamRequest builds a GET on behalf of the caller, forwarding the
+// principal the auth middleware stored on ctx and the tenant it acts on.
+func newUpstreamRequest(ctx context.Context, url string, secret []byte) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+	if principal, ok := auth.FromContext(ctx); ok {
+		auth.Forward(req.Header, principal, secret)
+	}
+	req.Header.Set(tenant.Header, tenant.ID(ctx))
+	return req, nil
+}
+
 func ensureTrailingSlash(value string) string {
 	if strings.HasSuffix(value, "/") {
 		return value
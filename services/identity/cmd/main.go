@@ -1,15 +1,21 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
 
 	identitycmp "github.com/pflow/components/identity"
+	"github.com/pflow/components/outbox"
 
+	"github.com/pflow/shared/audit"
+	"github.com/pflow/shared/auth"
 	"github.com/pflow/shared/config"
 	"github.com/pflow/shared/database"
 	"github.com/pflow/shared/httpx"
+	"github.com/pflow/shared/mq"
+	"github.com/pflow/shared/tenant"
 )
 
 func main() {
@@ -17,15 +23,104 @@ func main() {
 	dsn := cfg.DatabaseDSN("identity")
 	db := database.ConnectWithDSN("identity", dsn)
 
-	if err := db.AutoMigrate(&identitycmp.User{}); err != nil {
+	if err := identitycmp.Migrate(db); err != nil {
 		log.Fatalf("identity service: failed to run migrations: %v", err)
 	}
 
 	repository := identitycmp.NewGormRepository(db)
-	handler := identitycmp.NewHandler(repository)
+	if cfg.IdentityBootstrapEmail != "" {
+		bootstrapCtx := context.Background()
+		if cfg.IdentityBootstrapTenant != "" {
+			bootstrapCtx = tenant.WithID(bootstrapCtx, cfg.IdentityBootstrapTenant)
+		}
+		roles, err := repository.FindRolesByName(bootstrapCtx, []string{identitycmp.AdminRole})
+		if err != nil {
+			log.Fatalf("identity service: failed to load the admin role: %v", err)
+		}
+		admin := identitycmp.User{Name: "Administrator", Email: cfg.IdentityBootstrapEmail, Roles: roles}
+		created, err := identitycmp.EnsureUser(bootstrapCtx, repository, repository, admin, cfg.IdentityBootstrapPassword)
+		if err != nil {
+			log.Fatalf("identity service: failed to bootstrap %s: %v", cfg.IdentityBootstrapEmail, err)
+		}
+		if created {
+			log.Printf("identity service: created bootstrap administrator %s", cfg.IdentityBootstrapEmail)
+		}
+	}
+
+	// Events are recorded in the outbox in the transaction of each change and
+	// relayed to KAFKA_TOPIC; without a topic or brokers none are recorded.
+	producer, err := mq.EventProducerFromConfig(cfg, "identity")
+	if err != nil {
+		log.Fatalf("identity service: failed to configure event publishing: %v", err)
+	}
+	var events *outbox.EventStore
+	if producer != nil {
+		defer producer.Close(context.Background())
+		events = identitycmp.NewEventOutbox(db)
+
+		messages := outbox.NewRepository(db)
+		relay := outbox.NewRelay(messages, outbox.Routes{outbox.Events: producer}, outbox.RelayConfig{})
+		go func() {
+			if err := relay.Run(context.Background()); err != nil {
+				log.Printf("identity service: outbox relay stopped: %v", err)
+			}
+		}()
+
+		// Sent events are only kept for OUTBOX_RETENTION.
+		outboxPurger := database.NewPurger("identity outbox", messages.PurgeSent, database.PurgerConfig{Retention: cfg.OutboxRetention})
+		go func() {
+			if err := outboxPurger.Run(context.Background()); err != nil {
+				log.Printf("identity service: outbox purger stopped: %v", err)
+			}
+		}()
+	}
+
+	auditLog, err := audit.RepositoryFromConfig(cfg)
+	if err != nil {
+		log.Fatalf("identity service: failed to configure the audit log: %v", err)
+	}
+
+	signer, err := auth.SignerFromConfig(cfg)
+	if err != nil {
+		log.Fatalf("identity service: failed to configure token signing: %v", err)
+	}
+	authn, err := auth.ServiceMiddleware(context.Background(), cfg, identitycmp.AuthPublicPaths("")...)
+	if err != nil {
+		log.Fatalf("identity service: failed to configure authentication: %v", err)
+	}
+
+	options := []identitycmp.HandlerOption{
+		identitycmp.WithRefreshTokenTTL(cfg.AuthRefreshTokenTTL),
+		identitycmp.WithRoles(repository),
+		identitycmp.WithEvents(events),
+		identitycmp.WithAudit(audit.NewRecorder(auditLog, "identity")),
+	}
+	if signer != nil {
+		options = append(options, identitycmp.WithAuthentication(repository, signer))
+	} else {
+		log.Printf("identity service: AUTH_JWT_SECRET is not set; login endpoints are disabled")
+	}
+	var auditOptions []audit.HandlerOption
+	if authn != nil {
+		options = append(options, identitycmp.WithAuthorization())
+		auditOptions = append(auditOptions, audit.WithAuthorization())
+	}
+	handler := identitycmp.NewHandler(repository, options...)
+
+	// Deleted users stay restorable for SOFT_DELETE_RETENTION_DAYS.
+	purger := database.NewPurger("identity", repository.PurgeDeleted, database.PurgerConfig{Retention: cfg.SoftDeleteRetention()})
+	go func() {
+		if err := purger.Run(context.Background()); err != nil {
+			log.Printf("identity service: purger stopped: %v", err)
+		}
+	}()
 
-	server := httpx.New()
+	server := httpx.New(httpx.WithMiddleware(authn, tenant.Middleware, audit.Middleware))
 	handler.Mount(server.Router, "")
+	handler.MountAuth(server.Router, "")
+	handler.MountRoles(server.Router, "")
+	// The identity service answers audit queries for all services.
+	audit.NewHandler(auditLog, auditOptions...).Mount(server.Router, "")
 
 	port := cfg.ResolveServiceHTTPPort("identity", "8082")
 	addr := fmt.Sprintf(":%s", port)
@@ -3,13 +3,15 @@ module github.com/pflow/identity
 go 1.21
 
 require (
+	github.com/go-chi/chi/v5 v5.1.0
+	github.com/google/uuid v1.6.0
 	github.com/pflow/components v0.0.0
 	github.com/pflow/shared v0.0.0
+	gorm.io/gorm v1.30.0
 )
 
 require (
-	github.com/go-chi/chi/v5 v5.1.0 // indirect
-	github.com/google/uuid v1.6.0 // indirect
+	github.com/golang-jwt/jwt/v5 v5.2.1 // indirect
 	github.com/jackc/pgpassfile v1.0.0 // indirect
 	github.com/jackc/pgservicefile v0.0.0-20231201235250-de7065d80cb9 // indirect
 	github.com/jackc/pgx/v5 v5.5.5 // indirect
@@ -21,7 +23,6 @@ require (
 	golang.org/x/sync v0.9.0 // indirect
 	golang.org/x/text v0.20.0 // indirect
 	gorm.io/driver/postgres v1.5.5 // indirect
-	gorm.io/gorm v1.30.0 // indirect
 )
 
 replace (
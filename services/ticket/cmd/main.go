@@ -1,6 +1,8 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -9,12 +11,17 @@ import (
 	"syscall"
 	"time"
 
+	formcmp "github.com/pflow/components/form"
+	identitycmp "github.com/pflow/components/identity"
 	ticketcmp "github.com/pflow/components/ticket"
+	"github.com/pflow/components/ticket/s3blob"
 
+	"github.com/pflow/shared/audit"
+	"github.com/pflow/shared/auth"
 	"github.com/pflow/shared/config"
 	"github.com/pflow/shared/database"
 	"github.com/pflow/shared/httpx"
-	"github.com/pflow/shared/mq"
+	"github.com/pflow/shared/tenant"
 )
 
 func main() {
@@ -26,35 +33,82 @@ func main() {
 	dsn := cfg.DatabaseDSN("ticket")
 	db := database.ConnectWithDSN("ticket", dsn)
 
-	if err := db.AutoMigrate(&ticketcmp.Ticket{}, &ticketcmp.TicketSubmission{}); err != nil {
+	if err := ticketcmp.Migrate(db); err != nil {
 		log.Fatalf("ticket service: failed to run migrations: %v", err)
 	}
 
 	repository := ticketcmp.NewGormRepository(db)
 	submissionStore := ticketcmp.NewSubmissionRepository(db)
 
-	brokers := cfg.KafkaBrokerList("ticket")
-	topic := cfg.ResolveServiceQueueTopic("ticket", cfg.KafkaTopic)
-	if len(brokers) == 0 || strings.TrimSpace(topic) == "" {
-		log.Fatalf("ticket service: kafka brokers/topic must be configured (brokers=%v topic=%s)", brokers, topic)
+	coordinator := ticketcmp.NewQueueCoordinator(submissionStore)
+
+	transitions := ticketcmp.DefaultTransitionRules()
+	if spec := strings.TrimSpace(cfg.TicketStatusTransitions); spec != "" {
+		parsed, err := ticketcmp.ParseTransitionRules(spec)
+		if err != nil {
+			log.Fatalf("ticket service: invalid TICKET_STATUS_TRANSITIONS: %v", err)
+		}
+		transitions = parsed
+	}
+
+	blobs, err := newBlobStore(cfg)
+	if err != nil {
+		log.Fatalf("ticket service: failed to configure attachment storage: %v", err)
+	}
+	limits := ticketcmp.AttachmentLimits{MaxBytes: cfg.TicketAttachmentMaxBytes}
+	for _, contentType := range strings.Split(cfg.TicketAttachmentTypes, ",") {
+		if contentType = strings.TrimSpace(contentType); contentType != "" {
+			limits.AllowedTypes = append(limits.AllowedTypes, contentType)
+		}
+	}
+
+	// Assignment rules route to identity roles, whose members are listed by
+	// the identity service.
+	directory := identitycmp.NewRemoteDirectory(cfg.IdentityServiceURL, nil, []byte(cfg.AuthForwardSecret))
+	router := ticketcmp.NewRouter(ticketcmp.NewRoutingRepository(db), directory)
+
+	// Events are written to the outbox with the change they announce; the
+	// worker relays them to webhooks and the event topic.
+	webhooks := ticketcmp.NewWebhookRepository(db)
+	targets := ticketcmp.WebhookTargets{AllowedHosts: cfg.TicketWebhookHosts(), AllowPrivate: cfg.TicketWebhookAllowPrivate}
+
+	auditLog, err := audit.RepositoryFromConfig(cfg)
+	if err != nil {
+		log.Fatalf("ticket service: failed to configure the audit log: %v", err)
 	}
 
-	producer, err := mq.NewProducer(mq.ProducerConfig{
-		Brokers:  brokers,
-		Topic:    topic,
-		ClientID: fmt.Sprintf("%s-ticket-api", cfg.ServiceName),
-		Timeout:  2 * time.Second,
-	})
+	authn, err := auth.ServiceMiddleware(ctx, cfg)
 	if err != nil {
-		log.Fatalf("ticket service: failed to initialise producer: %v", err)
+		log.Fatalf("ticket service: failed to configure authentication: %v", err)
 	}
-	defer producer.Close(context.Background())
 
-	coordinator := ticketcmp.NewQueueCoordinator(submissionStore, producer)
+	options := []ticketcmp.HandlerOption{
+		ticketcmp.WithSubmissionCoordinator(coordinator),
+		ticketcmp.WithTransitionRules(transitions),
+		ticketcmp.WithFormValidator(formcmp.NewRemoteValidator(cfg.FormServiceURL, nil, []byte(cfg.AuthForwardSecret))),
+		ticketcmp.WithComments(repository),
+		ticketcmp.WithAttachments(repository, blobs, limits),
+		ticketcmp.WithSLAPolicies(ticketcmp.NewSLARepository(db)),
+		ticketcmp.WithRouting(router),
+		ticketcmp.WithWebhooks(webhooks, targets),
+		ticketcmp.WithEvents(ticketcmp.NewEventOutbox(db)),
+		ticketcmp.WithAudit(audit.NewRecorder(auditLog, "ticket")),
+	}
+	if authn != nil {
+		options = append(options, ticketcmp.WithAuthorization())
+	}
+	handler := ticketcmp.NewHandler(repository, options...)
 
-	handler := ticketcmp.NewHandler(repository, ticketcmp.WithSubmissionCoordinator(coordinator))
+	// Deleted tickets stay restorable, attachments included, for
+	// SOFT_DELETE_RETENTION_DAYS.
+	purger := database.NewPurger("ticket", ticketcmp.DeletedTicketPurge(repository, blobs), database.PurgerConfig{Retention: cfg.SoftDeleteRetention()})
+	go func() {
+		if err := purger.Run(ctx); err != nil && !errors.Is(err, context.Canceled) {
+			log.Printf("ticket service: purger stopped: %v", err)
+		}
+	}()
 
-	server := httpx.New()
+	server := httpx.New(httpx.WithMiddleware(authn, tenant.Middleware, audit.Middleware))
 	handler.Mount(server.Router, "")
 
 	port := cfg.ResolveServiceHTTPPort("ticket", "8083")
@@ -76,3 +130,20 @@ func main() {
 
 	log.Println("ticket service stopped")
 }
+
+func newBlobStore(cfg *config.AppConfig) (ticketcmp.BlobStore, error) {
+	switch cfg.TicketAttachmentStore {
+	case "", "file":
+		return ticketcmp.NewFileBlobStore(cfg.TicketAttachmentDir)
+	case "s3":
+		return s3blob.New(s3blob.Config{
+			Endpoint:  cfg.S3Endpoint,
+			Region:    cfg.S3Region,
+			Bucket:    cfg.S3Bucket,
+			AccessKey: cfg.S3AccessKey,
+			SecretKey: cfg.S3SecretKey,
+		})
+	default:
+		return nil, fmt.Errorf("unknown TICKET_ATTACHMENT_STORE %q", cfg.TicketAttachmentStore)
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/pflow/shared/config"
+	"github.com/pflow/shared/mq"
+)
+
+// replay pushes dead-lettered ticket submissions back onto the ticket queue.
+func main() {
+	limit := flag.Int("limit", 0, "maximum number of messages to replay (0 replays everything)")
+	flag.Parse()
+
+	cfg := config.Load()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	brokers := cfg.KafkaBrokerList("ticket")
+	topic := cfg.ResolveServiceQueueTopic("ticket", cfg.KafkaTopic)
+	dlq := cfg.ResolveServiceDeadLetterTopic("ticket", topic+".dlq")
+	if len(brokers) == 0 || strings.TrimSpace(topic) == "" {
+		log.Fatalf("ticket replay: kafka brokers/topic must be configured (brokers=%v topic=%s)", brokers, topic)
+	}
+
+	replayed, err := mq.ReplayDeadLetters(ctx, mq.ReplayConfig{
+		Brokers:         brokers,
+		DeadLetterTopic: dlq,
+		Topic:           topic,
+		GroupID:         fmt.Sprintf("%s-ticket-replay", cfg.ServiceName),
+		ClientID:        fmt.Sprintf("%s-ticket-replay", cfg.ServiceName),
+		Limit:           *limit,
+	})
+	if err != nil {
+		log.Fatalf("ticket replay: stopped after %d messages: %v", replayed, err)
+	}
+
+	log.Printf("ticket replay: moved %d messages from %s to %s", replayed, dlq, topic)
+}
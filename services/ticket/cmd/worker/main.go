@@ -2,12 +2,17 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os/signal"
 	"strings"
 	"syscall"
+	"time"
 
+	formcmp "github.com/pflow/components/form"
+	identitycmp "github.com/pflow/components/identity"
+	"github.com/pflow/components/outbox"
 	ticketcmp "github.com/pflow/components/ticket"
 
 	"github.com/pflow/shared/config"
@@ -24,7 +29,7 @@ func main() {
 	dsn := cfg.DatabaseDSN("ticket")
 	db := database.ConnectWithDSN("ticket-worker", dsn)
 
-	if err := db.AutoMigrate(&ticketcmp.Ticket{}, &ticketcmp.TicketSubmission{}); err != nil {
+	if err := ticketcmp.Migrate(db); err != nil {
 		log.Fatalf("ticket worker: failed to run migrations: %v", err)
 	}
 
@@ -37,19 +42,106 @@ func main() {
 
 	store := ticketcmp.NewSubmissionRepository(db)
 	repo := ticketcmp.NewGormRepository(db)
-	worker := ticketcmp.NewQueueWorker(store, repo)
+	directory := identitycmp.NewRemoteDirectory(cfg.IdentityServiceURL, nil, []byte(cfg.AuthForwardSecret))
+
+	// The outbox relay hands domain events and SLA breaches to the webhook
+	// dispatcher and the shared event topic; publishing them onto the
+	// submission queue would hand them to the workers below.
+	targets := ticketcmp.WebhookTargets{AllowedHosts: cfg.TicketWebhookHosts(), AllowPrivate: cfg.TicketWebhookAllowPrivate}
+	dispatcher := ticketcmp.NewWebhookDispatcher(ticketcmp.NewWebhookRepository(db), nil, ticketcmp.WebhookDispatcherConfig{Targets: targets})
+	var publisher mq.Publisher = dispatcher
+	if eventTopic := strings.TrimSpace(cfg.KafkaTopic); eventTopic != "" && eventTopic != topic {
+		eventProducer, err := mq.NewProducer(mq.ProducerConfig{
+			Brokers:  brokers,
+			Topic:    eventTopic,
+			ClientID: fmt.Sprintf("%s-ticket-events", cfg.ServiceName),
+			Timeout:  2 * time.Second,
+		})
+		if err != nil {
+			log.Fatalf("ticket worker: failed to initialise event producer: %v", err)
+		}
+		defer eventProducer.Close(context.Background())
+		publisher = mq.FanOut(dispatcher, eventProducer)
+	} else {
+		log.Printf("ticket worker: KAFKA_TOPIC is the submission queue, events only reach webhooks")
+	}
+	events := ticketcmp.NewEventOutbox(db)
+
+	worker := ticketcmp.NewQueueWorker(store, repo,
+		ticketcmp.WithWorkerFormValidator(formcmp.NewRemoteValidator(cfg.FormServiceURL, nil, []byte(cfg.AuthForwardSecret))),
+		ticketcmp.WithWorkerRouting(ticketcmp.NewRouter(ticketcmp.NewRoutingRepository(db), directory)),
+		ticketcmp.WithWorkerEvents(events),
+		ticketcmp.WithWorkerAttachments(repo),
+	)
 
 	consumer, err := mq.NewConsumer(mq.ConsumerConfig{
-		Brokers:  brokers,
-		Topic:    topic,
-		GroupID:  group,
-		ClientID: fmt.Sprintf("%s-ticket-worker", cfg.ServiceName),
+		Brokers:         brokers,
+		Topic:           topic,
+		GroupID:         group,
+		ClientID:        fmt.Sprintf("%s-ticket-worker", cfg.ServiceName),
+		MaxAttempts:     cfg.ResolveServiceQueueMaxAttempts("ticket", 5),
+		DeadLetterTopic: cfg.ResolveServiceDeadLetterTopic("ticket", topic+".dlq"),
+		Concurrency:     cfg.ResolveServiceQueueConcurrency("ticket", 1),
 	}, worker.HandleMessage)
 	if err != nil {
 		log.Fatalf("ticket worker: failed to create consumer: %v", err)
 	}
 	defer consumer.Close()
 
+	producer, err := mq.NewProducer(mq.ProducerConfig{
+		Brokers:  brokers,
+		Topic:    topic,
+		ClientID: fmt.Sprintf("%s-ticket-outbox", cfg.ServiceName),
+		Timeout:  2 * time.Second,
+	})
+	if err != nil {
+		log.Fatalf("ticket worker: failed to initialise producer: %v", err)
+	}
+	defer producer.Close(context.Background())
+
+	messages := outbox.NewRepository(db)
+	relay := outbox.NewRelay(messages, outbox.Routes{
+		ticketcmp.OutboxSubmissions: producer,
+		ticketcmp.OutboxEvents:      publisher,
+	}, outbox.RelayConfig{})
+	go func() {
+		if err := relay.Run(ctx); err != nil && !errors.Is(err, context.Canceled) {
+			log.Printf("ticket worker: outbox relay stopped: %v", err)
+		}
+	}()
+
+	// Sent messages are only kept for TICKET_OUTBOX_RETENTION.
+	outboxPurger := database.NewPurger("ticket outbox", messages.PurgeSent, database.PurgerConfig{Retention: cfg.TicketOutboxRetention})
+	go func() {
+		if err := outboxPurger.Run(ctx); err != nil && !errors.Is(err, context.Canceled) {
+			log.Printf("ticket worker: outbox purger stopped: %v", err)
+		}
+	}()
+
+	reaper := ticketcmp.NewSubmissionReaper(store, ticketcmp.ReaperConfig{
+		PendingAfter:    cfg.TicketSubmissionPendingAfter,
+		ProcessingAfter: cfg.TicketSubmissionProcessingAfter,
+		MaxRequeues:     int(cfg.TicketSubmissionMaxRequeues),
+	})
+	go func() {
+		if err := reaper.Run(ctx); err != nil && !errors.Is(err, context.Canceled) {
+			log.Printf("ticket worker: submission reaper stopped: %v", err)
+		}
+	}()
+
+	go func() {
+		if err := dispatcher.Run(ctx); err != nil && !errors.Is(err, context.Canceled) {
+			log.Printf("ticket worker: webhook dispatcher stopped: %v", err)
+		}
+	}()
+
+	evaluator := ticketcmp.NewSLAEvaluator(ticketcmp.NewSLARepository(db), events, ticketcmp.SLAEvaluatorConfig{})
+	go func() {
+		if err := evaluator.Run(ctx); err != nil && !errors.Is(err, context.Canceled) {
+			log.Printf("ticket worker: sla evaluator stopped: %v", err)
+		}
+	}()
+
 	log.Printf("ticket worker consuming topic=%s group=%s", topic, group)
 
 	if err := consumer.Run(ctx); err != nil && err != context.Canceled {
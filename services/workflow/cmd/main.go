@@ -1,15 +1,22 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
 
+	"github.com/pflow/components/outbox"
 	workflowcmp "github.com/pflow/components/workflow"
+	"github.com/pflow/components/workflow/zeebe"
 
+	"github.com/pflow/shared/audit"
+	"github.com/pflow/shared/auth"
 	"github.com/pflow/shared/config"
 	"github.com/pflow/shared/database"
 	"github.com/pflow/shared/httpx"
+	"github.com/pflow/shared/mq"
+	"github.com/pflow/shared/tenant"
 )
 
 func main() {
@@ -17,14 +24,84 @@ func main() {
 	dsn := cfg.DatabaseDSN("workflow")
 	db := database.ConnectWithDSN("workflow", dsn)
 
-	if err := db.AutoMigrate(&workflowcmp.Definition{}); err != nil {
+	if err := workflowcmp.Migrate(db); err != nil {
 		log.Fatalf("workflow service: failed to run migrations: %v", err)
 	}
 
 	repository := workflowcmp.NewGormRepository(db)
-	handler := workflowcmp.NewHandler(repository)
+	engine := workflowcmp.NewEngine(repository, workflowcmp.NewInstanceRepository(db))
+	options := []workflowcmp.HandlerOption{workflowcmp.WithEngine(engine)}
 
-	server := httpx.New()
+	switch cfg.ProcessEngine {
+	case "", "embedded":
+	case "zeebe":
+		processEngine, err := zeebe.New(zeebe.Config{GatewayAddress: cfg.CamundaURL, Plaintext: cfg.CamundaPlaintext})
+		if err != nil {
+			log.Fatalf("workflow service: %v", err)
+		}
+		defer processEngine.Close()
+		options = append(options, workflowcmp.WithProcessEngine(processEngine))
+		log.Printf("workflow service: deploying published workflows to Zeebe at %s", cfg.CamundaURL)
+	default:
+		log.Fatalf("workflow service: unknown process engine %q", cfg.ProcessEngine)
+	}
+
+	// Events are recorded in the outbox in the transaction of each change and
+	// relayed to KAFKA_TOPIC; without a topic or brokers none are recorded.
+	producer, err := mq.EventProducerFromConfig(cfg, "workflow")
+	if err != nil {
+		log.Fatalf("workflow service: failed to configure event publishing: %v", err)
+	}
+	var events *outbox.EventStore
+	if producer != nil {
+		defer producer.Close(context.Background())
+		events = workflowcmp.NewEventOutbox(db)
+
+		messages := outbox.NewRepository(db)
+		relay := outbox.NewRelay(messages, outbox.Routes{outbox.Events: producer}, outbox.RelayConfig{})
+		go func() {
+			if err := relay.Run(context.Background()); err != nil {
+				log.Printf("workflow service: outbox relay stopped: %v", err)
+			}
+		}()
+
+		// Sent events are only kept for OUTBOX_RETENTION.
+		outboxPurger := database.NewPurger("workflow outbox", messages.PurgeSent, database.PurgerConfig{Retention: cfg.OutboxRetention})
+		go func() {
+			if err := outboxPurger.Run(context.Background()); err != nil {
+				log.Printf("workflow service: outbox purger stopped: %v", err)
+			}
+		}()
+	}
+
+	options = append(options, workflowcmp.WithEvents(events))
+
+	auditLog, err := audit.RepositoryFromConfig(cfg)
+	if err != nil {
+		log.Fatalf("workflow service: failed to configure the audit log: %v", err)
+	}
+
+	options = append(options, workflowcmp.WithAudit(audit.NewRecorder(auditLog, "workflow")))
+
+	authn, err := auth.ServiceMiddleware(context.Background(), cfg)
+	if err != nil {
+		log.Fatalf("workflow service: failed to configure authentication: %v", err)
+	}
+	if authn != nil {
+		options = append(options, workflowcmp.WithAuthorization())
+	}
+
+	handler := workflowcmp.NewHandler(repository, options...)
+
+	// Deleted workflows stay restorable for SOFT_DELETE_RETENTION_DAYS.
+	purger := database.NewPurger("workflow", repository.PurgeDeleted, database.PurgerConfig{Retention: cfg.SoftDeleteRetention()})
+	go func() {
+		if err := purger.Run(context.Background()); err != nil {
+			log.Printf("workflow service: purger stopped: %v", err)
+		}
+	}()
+
+	server := httpx.New(httpx.WithMiddleware(authn, tenant.Middleware, audit.Middleware))
 	handler.Mount(server.Router, "")
 
 	port := cfg.ResolveServiceHTTPPort("workflow", "8084")